@@ -0,0 +1,198 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/uyuni-project/inter-server-sync/dumper"
+	"github.com/uyuni-project/inter-server-sync/entityDumper"
+	"github.com/uyuni-project/inter-server-sync/exportArchive"
+	"github.com/uyuni-project/inter-server-sync/syncEngine"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+var benchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Measure the export throughput of this server",
+	Long: "Measure the SQL generation throughput on synthetic rows, the file copy bandwidth of the scratch location and,\n" +
+		"with --channels, the rows per second of every table of a sample export, to size hub hardware and compare versions.",
+	Args: cobra.NoArgs,
+	Run:  runBench,
+}
+
+var benchChannels []string
+var benchScratchDir string
+var benchRows int
+var benchFileSize string
+var benchReportFile string
+
+func init() {
+	benchCmd.Flags().StringSliceVar(&benchChannels, "channels", nil, "Channels exported as sample dataset (default no sample export)")
+	benchCmd.Flags().StringVar(&benchScratchDir, "scratchDir", "", "Location the sample export and the copied files are written to, and removed from (default the system temporary directory)")
+	benchCmd.Flags().IntVar(&benchRows, "syntheticRows", 100000, "Number of synthetic rows the SQL statements are generated for")
+	benchCmd.Flags().StringVar(&benchFileSize, "fileSize", "256M", "Size of the file copied to measure the file copy bandwidth, like 1G")
+	benchCmd.Flags().StringVar(&benchReportFile, "reportFile", "", "File the JSON report is written to")
+	rootCmd.AddCommand(benchCmd)
+}
+
+// benchReport is the result of a bench run, comparable between servers and tool versions
+type benchReport struct {
+	Version string    `json:"version"`
+	Date    time.Time `json:"date"`
+	CPUs    int       `json:"cpus"`
+	// synthetic statements generated without database
+	SqlRows           int     `json:"sqlRows"`
+	SqlRowsPerSecond  float64 `json:"sqlRowsPerSecond"`
+	SqlBytesPerSecond float64 `json:"sqlBytesPerSecond"`
+	// copy of a file in the scratch location
+	CopyBytes          int64   `json:"copyBytes"`
+	CopyBytesPerSecond float64 `json:"copyBytesPerSecond"`
+	// sample export of the channels
+	SampleChannels []string           `json:"sampleChannels,omitempty"`
+	SampleSeconds  float64            `json:"sampleSeconds,omitempty"`
+	SampleTables   []benchTableReport `json:"sampleTables,omitempty"`
+	SampleFiles    *benchFilesReport  `json:"sampleFiles,omitempty"`
+}
+
+type benchTableReport struct {
+	Table         string  `json:"table"`
+	Rows          int     `json:"rows"`
+	Seconds       float64 `json:"seconds"`
+	RowsPerSecond float64 `json:"rowsPerSecond"`
+}
+
+type benchFilesReport struct {
+	Files          int     `json:"files"`
+	Bytes          int64   `json:"bytes"`
+	BytesPerSecond float64 `json:"bytesPerSecond"`
+}
+
+func runBench(cmd *cobra.Command, args []string) {
+	fileSize, ok := exportArchive.ParseSize(benchFileSize)
+	if !ok || benchRows < 1 {
+		log.Fatal().Int(utils.ExitCodeField, utils.ExitConfigError).Msg("Invalid bench size. --fileSize is a number of bytes with an optional K, M, G or T suffix, and --syntheticRows a positive number")
+	}
+	scratchDir, err := os.MkdirTemp(benchScratchDir, "inter-server-sync-bench-")
+	if err != nil {
+		log.Fatal().Err(err).Int(utils.ExitCodeField, utils.ExitConfigError).Msg("Error creating the bench scratch directory")
+	}
+	defer os.RemoveAll(scratchDir)
+
+	report := benchReport{Version: rootCmd.Version, Date: time.Now().UTC(), CPUs: runtime.NumCPU(), SqlRows: benchRows}
+	benchSqlGeneration(&report)
+	benchFileCopy(&report, scratchDir, fileSize)
+	if len(benchChannels) > 0 {
+		benchSampleExport(&report, path.Join(scratchDir, "export"))
+	}
+	printBenchReport(report)
+	if len(benchReportFile) > 0 {
+		content, err := json.MarshalIndent(report, "", "  ")
+		if err == nil {
+			err = os.WriteFile(utils.GetAbsPath(benchReportFile), content, 0644)
+		}
+		if err != nil {
+			log.Fatal().Err(err).Msg("Error writing the bench report")
+		}
+	}
+}
+
+func benchSqlGeneration(report *benchReport) {
+	start := time.Now()
+	written, err := dumper.WriteSyntheticStatements(io.Discard, report.SqlRows)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Error generating the synthetic statements")
+	}
+	seconds := time.Since(start).Seconds()
+	report.SqlRowsPerSecond = float64(report.SqlRows) / seconds
+	report.SqlBytesPerSecond = float64(written) / seconds
+}
+
+func benchFileCopy(report *benchReport, scratchDir string, fileSize int64) {
+	source := path.Join(scratchDir, "source")
+	file, err := os.Create(source)
+	if err == nil {
+		// random content, so compressing file systems cannot shortcut the copy
+		_, err = io.CopyN(file, rand.Reader, fileSize)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		log.Fatal().Err(err).Msgf("Error writing the bench file %s", source)
+	}
+	start := time.Now()
+	copied, err := dumper.Copy(source, path.Join(scratchDir, "copy", "target"))
+	if err != nil {
+		log.Fatal().Err(err).Msg("Error copying the bench file")
+	}
+	report.CopyBytes = copied
+	report.CopyBytesPerSecond = float64(copied) / time.Since(start).Seconds()
+}
+
+func benchSampleExport(report *benchReport, outputFolder string) {
+	start := time.Now()
+	err := syncEngine.NewExporter().Export(syncEngine.ExportOptions{
+		DumperOptions: entityDumper.DumperOptions{
+			ServerConfig:  serverConfig,
+			ChannelLabels: benchChannels,
+			OutputFolder:  outputFolder,
+		},
+		Context: cancelOnSignal(),
+	})
+	if err != nil {
+		exitWithFailure(err)
+	}
+	report.SampleChannels = benchChannels
+	report.SampleSeconds = time.Since(start).Seconds()
+	for tableName, statistics := range dumper.WriteStatistics() {
+		table := benchTableReport{Table: tableName, Rows: statistics.Rows, Seconds: statistics.Duration.Seconds()}
+		if statistics.Duration > 0 {
+			table.RowsPerSecond = float64(statistics.Rows) / statistics.Duration.Seconds()
+		}
+		report.SampleTables = append(report.SampleTables, table)
+	}
+	// slowest tables first
+	sort.Slice(report.SampleTables, func(i, j int) bool {
+		return report.SampleTables[i].Seconds > report.SampleTables[j].Seconds
+	})
+	copies := dumper.CopyStatistics()
+	report.SampleFiles = &benchFilesReport{Files: copies.Files, Bytes: copies.Bytes}
+	if copies.Duration > 0 {
+		report.SampleFiles.BytesPerSecond = float64(copies.Bytes) / copies.Duration.Seconds()
+	}
+}
+
+func printBenchReport(report benchReport) {
+	fmt.Printf("inter-server-sync %s, %d CPUs\n", report.Version, report.CPUs)
+	fmt.Printf("SQL generation: %.0f rows/s, %s/s (%d synthetic rows)\n",
+		report.SqlRowsPerSecond, formatBytes(report.SqlBytesPerSecond), report.SqlRows)
+	fmt.Printf("File copy: %s/s (%s)\n", formatBytes(report.CopyBytesPerSecond), formatBytes(float64(report.CopyBytes)))
+	if len(report.SampleChannels) == 0 {
+		return
+	}
+	fmt.Printf("Sample export of %v: %.1fs\n", report.SampleChannels, report.SampleSeconds)
+	for _, table := range report.SampleTables {
+		fmt.Printf("  %-40s %10d rows %10.0f rows/s\n", table.Table, table.Rows, table.RowsPerSecond)
+	}
+	fmt.Printf("  copied files: %d, %s/s\n", report.SampleFiles.Files, formatBytes(report.SampleFiles.BytesPerSecond))
+}
+
+// formatBytes formats a number of bytes with a binary unit
+func formatBytes(bytes float64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	unit := 0
+	for bytes >= 1024 && unit < len(units)-1 {
+		bytes /= 1024
+		unit++
+	}
+	return fmt.Sprintf("%.1f %s", bytes, units[unit])
+}
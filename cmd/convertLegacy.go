@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"bufio"
+	"compress/gzip"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/uyuni-project/inter-server-sync/entityDumper"
+	"github.com/uyuni-project/inter-server-sync/legacyXml"
+	"github.com/uyuni-project/inter-server-sync/syncEngine"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+var convertLegacyCmd = &cobra.Command{
+	Use:   "convert-legacy",
+	Short: "Convert a legacy satellite-sync XML dump into an export which can be imported",
+	Long: "Convert channels, errata and their package lists of a legacy satellite-sync XML dump into an export.\n" +
+		"Packages are only linked to channels and errata if they already exist on the server the export is imported into.",
+	Args: cobra.NoArgs,
+	Run:  runConvertLegacy,
+}
+
+var legacyDir string
+
+func init() {
+	convertLegacyCmd.Flags().StringVar(&legacyDir, "legacyDir", "", "Location of the legacy XML dump")
+	convertLegacyCmd.Flags().StringVar(&outputDir, "outputDir", ".", "Location for generated data")
+	convertLegacyCmd.MarkFlagRequired("legacyDir")
+	rootCmd.AddCommand(convertLegacyCmd)
+}
+
+func runConvertLegacy(cmd *cobra.Command, args []string) {
+	outputFolderAbs := utils.GetAbsPath(outputDir)
+	entityDumper.ValidateExportFolder(outputFolderAbs)
+	dump := legacyXml.ReadDump(utils.GetAbsPath(legacyDir))
+	log.Info().Msgf("Converting %d channels and %d errata", len(dump.Channels), len(dump.Errata))
+
+	file, err := os.Create(path.Join(outputFolderAbs, "sql_statements.sql.gz"))
+	if err != nil {
+		log.Panic().Err(err).Msg("error creating sql file")
+	}
+	defer file.Close()
+	gzipFile := gzip.NewWriter(file)
+	defer gzipFile.Close()
+	bufferWriter := bufio.NewWriterSize(gzipFile, 32768)
+	defer bufferWriter.Flush()
+
+	bufferWriter.WriteString("BEGIN;\n")
+	legacyXml.WriteSql(bufferWriter, dump)
+	bufferWriter.WriteString("COMMIT;\n")
+	syncEngine.WriteVersionFile(serverConfig, outputDir)
+
+	// the channels are processed as any other imported channel
+	channelLabels := make([]string, 0, len(dump.Channels))
+	for _, channel := range dump.Channels {
+		channelLabels = append(channelLabels, channel.Label+"\n")
+	}
+	if err := os.WriteFile(path.Join(outputFolderAbs, "exportedChannels.txt"), []byte(strings.Join(channelLabels, "")), 0644); err != nil {
+		log.Panic().Err(err).Msg("error writing exported channels file")
+	}
+
+	log.Info().Msgf("Conversion done. Directory: %s", outputDir)
+}
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// terminalPrompt returns the prompt asking the passwords of sealed credentials on the terminal, nil when the
+// standard input is not a terminal
+func terminalPrompt() func(description string) (string, error) {
+	info, err := os.Stdin.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return nil
+	}
+	reader := bufio.NewReader(os.Stdin)
+	return func(description string) (string, error) {
+		fmt.Fprintf(os.Stderr, "Password of the image store credentials %s: ", description)
+		setTerminalEcho(false)
+		defer setTerminalEcho(true)
+		password, err := reader.ReadString('\n')
+		fmt.Fprintln(os.Stderr)
+		return strings.TrimRight(password, "\r\n"), err
+	}
+}
+
+// setTerminalEcho shows or hides the characters typed on the terminal
+func setTerminalEcho(enabled bool) {
+	mode := "-echo"
+	if enabled {
+		mode = "echo"
+	}
+	cmd := exec.Command("stty", mode)
+	cmd.Stdin = os.Stdin
+	cmd.Run()
+}
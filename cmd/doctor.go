@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/uyuni-project/inter-server-sync/exportArchive"
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the environment before running an export or import",
+	Args:  cobra.NoArgs,
+	Run:   runDoctor,
+}
+
+var doctorOutputDir string
+var doctorMinFreeSpace string
+
+func init() {
+	doctorCmd.Flags().StringVar(&doctorOutputDir, "outputDir", ".", "Location the export is written to, or the import is read from")
+	doctorCmd.Flags().StringVar(&doctorMinFreeSpace, "minFreeSpace", "10G", "Free space required on the output location, like 500M or 10G")
+	rootCmd.AddCommand(doctorCmd)
+}
+
+// doctorCheck returns a description of what was found, or an error with the action fixing the failure
+type doctorCheck struct {
+	name string
+	run  func() (string, error)
+}
+
+func runDoctor(cmd *cobra.Command, args []string) {
+	var db *sql.DB
+	defer func() {
+		if db != nil {
+			db.Close()
+		}
+	}()
+	absOutputDir := utils.GetAbsPath(doctorOutputDir)
+
+	checks := []doctorCheck{
+		{"configuration", func() (string, error) {
+			if err := schemareader.CheckDataSource(serverConfig); err != nil {
+				return "", fmt.Errorf("%v: set --serverConfig to the rhn.conf of the server", err)
+			}
+			return serverConfig, nil
+		}},
+		{"database connection", func() (string, error) {
+			connection, err := sql.Open("postgres", schemareader.GetConnectionString(serverConfig))
+			if err == nil {
+				err = connection.Ping()
+			}
+			if err != nil {
+				return "", fmt.Errorf("%v: check the database is running and the db_* settings of %s", err, serverConfig)
+			}
+			db = connection
+			return "connected", nil
+		}},
+		{"server version", func() (string, error) {
+			version, product, err := utils.ReadCurrentServerVersion(serverConfig)
+			if err != nil {
+				return "", fmt.Errorf("%v: the server configuration files are missing or incomplete", err)
+			}
+			return fmt.Sprintf("%s %s", product, version), nil
+		}},
+		{"schema version", func() (string, error) {
+			var schemaVersion string
+			err := db.QueryRow(`SELECT evr.version || '-' || evr.release FROM rhnversioninfo vi
+				JOIN rhnpackageevr evr ON evr.id = vi.evr_id WHERE vi.label = 'schema'`).Scan(&schemaVersion)
+			if err != nil {
+				return "", fmt.Errorf("%v: the database schema is not installed or not upgraded", err)
+			}
+			return schemaVersion, nil
+		}},
+		{"sequences", func() (string, error) {
+			missing, err := missingPKSequences(db)
+			if err != nil {
+				return "", err
+			}
+			if len(missing) > 0 {
+				return "", fmt.Errorf("sequences not found: %s: the database schema does not match this version of inter-server-sync",
+					strings.Join(missing, ", "))
+			}
+			return "all sequences found", nil
+		}},
+		{"output location", func() (string, error) {
+			file, err := os.CreateTemp(absOutputDir, ".inter-server-sync-doctor-")
+			if err != nil {
+				return "", fmt.Errorf("%v: create %s or fix its permissions", err, absOutputDir)
+			}
+			file.Close()
+			os.Remove(file.Name())
+			return absOutputDir + " is writable", nil
+		}},
+		{"disk space", func() (string, error) {
+			required, ok := exportArchive.ParseSize(doctorMinFreeSpace)
+			if !ok {
+				return "", fmt.Errorf("invalid size %s: set --minFreeSpace to a size like 10G", doctorMinFreeSpace)
+			}
+			var stat syscall.Statfs_t
+			if err := syscall.Statfs(absOutputDir, &stat); err != nil {
+				return "", fmt.Errorf("%v: cannot read the free space of %s", err, absOutputDir)
+			}
+			free := int64(stat.Bavail) * int64(stat.Bsize)
+			if free < required {
+				return "", fmt.Errorf("%d MB free, %d MB required: free some space on %s or use another location",
+					free>>20, required>>20, absOutputDir)
+			}
+			return fmt.Sprintf("%d MB free", free>>20), nil
+		}},
+	}
+
+	failures := 0
+	for _, check := range checks {
+		if db == nil && failures > 0 && needsDatabase(check.name) {
+			fmt.Printf("[SKIP] %s: needs the failed checks above\n", check.name)
+			continue
+		}
+		result, err := check.run()
+		if err != nil {
+			failures++
+			fmt.Printf("[FAIL] %s: %v\n", check.name, err)
+			continue
+		}
+		fmt.Printf("[ OK ] %s: %s\n", check.name, result)
+	}
+	if failures > 0 {
+		log.Fatal().Msgf("%d checks failed", failures)
+	}
+}
+
+// needsDatabase tells if a check cannot run after the database connection check failed
+func needsDatabase(checkName string) bool {
+	return checkName == "database connection" || checkName == "schema version" || checkName == "sequences"
+}
+
+// missingPKSequences returns the sequences the table filters rely on which are not in the database
+func missingPKSequences(db *sql.DB) ([]string, error) {
+	rows, err := db.Query(`SELECT relname FROM pg_class WHERE relkind = 'S'`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	sequences := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		sequences[name] = true
+	}
+	missing := make([]string, 0)
+	for tableName, sequence := range schemareader.ReadFilteredPKSequences(db) {
+		// sequence names are not quoted in the generated statements, so they are folded to lower case
+		if !sequences[strings.ToLower(sequence)] {
+			missing = append(missing, fmt.Sprintf("%s (%s)", sequence, tableName))
+		}
+	}
+	sort.Strings(missing)
+	return missing, nil
+}
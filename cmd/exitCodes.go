@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime/debug"
+
+	"github.com/rs/zerolog"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+var errorJson bool
+
+// exit code of the failures not setting one, changed when a failure leaves incomplete output behind
+var defaultExitCode = utils.ExitError
+
+// exit code of the last panic event, used when the panic stops the command
+var panicExitCode = 0
+
+// exitCodeWriter must be the last log writer: it exits with the exit code of fatal events and panics on panic
+// events, after the other writers logged them
+type exitCodeWriter struct{}
+
+func (w exitCodeWriter) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func (w exitCodeWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	if level != zerolog.FatalLevel && level != zerolog.PanicLevel {
+		return len(p), nil
+	}
+	failure := utils.ParseFailure(p, defaultExitCode)
+	if errorJson {
+		printFailure(failure)
+	}
+	if level == zerolog.FatalLevel {
+		os.Exit(failure.ExitCode)
+	}
+	panicExitCode = failure.ExitCode
+	failure.Stack = string(debug.Stack())
+	panic(failure)
+}
+
+// printFailure prints the failure as JSON on the standard error
+func printFailure(failure *utils.Failure) {
+	content, err := json.Marshal(failure)
+	if err != nil {
+		return
+	}
+	fmt.Fprintln(os.Stderr, string(content))
+}
+
+// exitWithFailure exits with the exit code of the failure an export or import returned
+func exitWithFailure(err error) {
+	failure, ok := err.(*utils.Failure)
+	if !ok {
+		failure = utils.NewFailure(defaultExitCode, err.Error())
+	}
+	if len(failure.Stack) > 0 {
+		fmt.Fprintf(os.Stderr, "panic: %s\n\n%s", failure.Message, failure.Stack)
+	}
+	if panicExitCode != 0 {
+		// the panic event was already printed
+		os.Exit(panicExitCode)
+	}
+	if errorJson {
+		printFailure(failure)
+	}
+	os.Exit(failure.ExitCode)
+}
+
+// exitOnPanic exits with the exit code of the panic event which stopped the command
+func exitOnPanic() {
+	recovered := recover()
+	if recovered == nil {
+		return
+	}
+	failure := utils.NewFailure(defaultExitCode, fmt.Sprintf("%v", recovered))
+	failure.Stack = string(debug.Stack())
+	exitWithFailure(failure)
+}
@@ -1,12 +1,20 @@
 package cmd
 
 import (
+	"fmt"
+	"io"
 	"os"
-	"path"
+	"strings"
+	"time"
 
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
+	"github.com/uyuni-project/inter-server-sync/dumper"
 	"github.com/uyuni-project/inter-server-sync/entityDumper"
+	"github.com/uyuni-project/inter-server-sync/exportArchive"
+	"github.com/uyuni-project/inter-server-sync/placeholders"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+	"github.com/uyuni-project/inter-server-sync/syncEngine"
 	"github.com/uyuni-project/inter-server-sync/utils"
 )
 
@@ -18,24 +26,150 @@ var exportCmd = &cobra.Command{
 
 var channels []string
 var channelWithChildren []string
+var includeChildren bool
+var includeCloneOrigins bool
+var repoSyncSchedules bool
+var includeFileLists bool
+var changelogLimit int
+var anonymizeExport bool
+var exportCredentialsKeyFile string
 var configChannels []string
 var outputDir string
 var metadataOnly bool
 var startingDate string
 var includeImages bool
+var imageSelection []string
 var includeContainers bool
 var orgs []uint
+var whereFilters []string
+var maxDepth int
+var pruneTables []string
+var exportedKeysCache string
+var exportedKeysImported bool
+var targetSchema string
+var targetServerConfig string
+var skipExistingOnTarget bool
+var peripheralFQDN string
+var exportFormat string
+var includeRepodata bool
+var includeAutoinstall bool
+var includeBootstrapRepositories bool
+var includeRepoCredentials bool
+var includeProducts bool
+var includeMaintenance bool
+var includeProxies bool
+var includeUsers bool
+var includeUserPasswords bool
+var exportPlaceholders []string
+var placeholderColumns []string
+var pillarRewriteRules string
+var sensitiveColumns []string
+var hotStandbySource bool
+var sourceStatementTimeout time.Duration
+var advisoryLock bool
+var pauseBunches []string
+var singleSnapshot bool
+var channelSubdirectories bool
+var archiveFile string
+var splitMedia string
+var kmsKey string
+
+var copyWorkers int
+
+var keyMemoryLimit string
+var keySpillDirectory string
+var dedup string
+var idStrategy string
+var verifyReferences bool
+var statisticsStore string
+var packageInventory string
+var packageStore string
+var imageStore string
+var referenceRules string
+
+// limits of the time spent waiting for the database
+var exportStatementTimeout time.Duration
+var exportConnectTimeout time.Duration
+var exportDeadline time.Duration
+
+// repetition of the queries failing with transient errors
+var queryAttempts int
+var queryRetryBackoff time.Duration
+var queryRetryMaxBackoff time.Duration
+var queryRetryErrors []string
+
+var exportOtlpEndpoint string
 
 func init() {
 	exportCmd.Flags().StringSliceVar(&channels, "channels", nil, "Channels to be exported")
 	exportCmd.Flags().StringSliceVar(&channelWithChildren, "channel-with-children", nil, "Channels to be exported")
+	exportCmd.Flags().BoolVar(&includeChildren, "include-children", false, "Also export the child channels of the --channels")
+	exportCmd.Flags().BoolVar(&repoSyncSchedules, "repoSyncSchedules", false, "Export the repository synchronization schedules of the exported channels, created again on the target server through the API")
+	exportCmd.Flags().BoolVar(&includeCloneOrigins, "include-clone-origins", false, "Also export the channels the exported channels were cloned from, keeping the clone relationships")
 	exportCmd.Flags().StringVar(&outputDir, "outputDir", ".", "Location for generated data")
+	exportCmd.Flags().StringVar(&archiveFile, "archive", "", "Write the export as a single "+exportArchive.Extension+" archive at this path, instead of the output directory, or - to stream it to the standard output")
+	exportCmd.Flags().StringVar(&kmsKey, "kms-key", "", "Encrypt the archive with a key wrapped by this key management service key: vault:<mount>/<key>, awskms:<key id> or exec:<command>")
+	exportCmd.Flags().StringVar(&splitMedia, "split-media", "", "Split the archive into volumes no larger than this size, like 25G, to transfer it on removable media")
 	exportCmd.Flags().BoolVar(&metadataOnly, "metadataOnly", false, "export only metadata")
+	exportCmd.Flags().BoolVar(&includeFileLists, "include-filelists", true, "Export the file lists of the packages, which triple the size of the export")
+	exportCmd.Flags().StringVar(&exportCredentialsKeyFile, "credentialsKeyFile", "", "File with a hex encoded 32 bytes key sealing the passwords of the exported image store credentials, which are not exported without key")
+	exportCmd.Flags().BoolVar(&anonymizeExport, "anonymize", false, "Replace host names, user names and e-mail addresses, IP addresses, organization names, system descriptions and pillar strings by pseudonyms, to attach the export to support cases. Cannot be used with --images")
+	exportCmd.Flags().IntVar(&changelogLimit, "changelog-limit", 0, "Export only the newest N changelog entries of every package (0 for all)")
 	exportCmd.Flags().StringVar(&startingDate, "packagesOnlyAfter", "", "Only export packages added or modified after the specified date (date format can be 'YYYY-MM-DD' or 'YYYY-MM-DD hh:mm:ss')")
+	exportCmd.Flags().BoolVar(&channelSubdirectories, "channelSubdirectories", false, "Export each channel into its own subdirectory, so channels can be imported selectively")
+	exportCmd.Flags().BoolVar(&includeRepodata, "includeRepodata", false, "Export the repository metadata of the channels, so it doesn't need to be generated on import")
+	exportCmd.Flags().BoolVar(&includeRepoCredentials, "include-repo-credentials", false, "Export the credentials and SSL client certificates and keys of the custom repositories of the channels, which are removed by default")
 	exportCmd.Flags().StringSliceVar(&configChannels, "configChannels", nil, "Configuration Channels to be exported")
-	exportCmd.Flags().BoolVar(&includeImages, "images", false, "Export OS images and associated metadata")
+	exportCmd.Flags().StringSliceVar(&imageSelection, "images", nil, "Export OS images and associated metadata, only the images matching the filters with --images=name:version:arch, like --images='sles15*:*:x86_64'")
+	exportCmd.Flags().Lookup("images").NoOptDefVal = "*"
 	exportCmd.Flags().BoolVar(&includeContainers, "containers", false, "Export containers metadata")
+	exportCmd.Flags().BoolVar(&includeProducts, "products", false, "Export SUSE product data, to set up servers without SCC access")
+	exportCmd.Flags().BoolVar(&includeAutoinstall, "autoinstall", false, "Export autoinstallable distributions and autoinstallation profiles")
+	exportCmd.Flags().BoolVar(&includeBootstrapRepositories, "bootstrapRepos", false, "Export the bootstrap repositories clients are onboarded from")
+	exportCmd.Flags().BoolVar(&includeMaintenance, "maintenanceSchedules", false, "Export maintenance schedules and calendars, and their assignment to systems known by the target server")
+	exportCmd.Flags().BoolVar(&includeProxies, "proxies", false, "Export the proxy versions and the proxy paths of the systems known by the target server")
+	exportCmd.Flags().BoolVar(&includeUsers, "users", false, "Export users with their roles and organizations")
+	exportCmd.Flags().BoolVar(&includeUserPasswords, "include-user-passwords", false, "Export the password hashes of the users, which are removed by default")
 	exportCmd.Flags().UintSliceVar(&orgs, "orgLimit", nil, "Export only for specified organizations")
+	exportCmd.Flags().StringArrayVar(&whereFilters, "where", nil, "Export only rows of a table matching a predicate, in the format 'table: predicate' (can be repeated)")
+	exportCmd.Flags().StringSliceVar(&placeholderColumns, "placeholderColumns", nil, "Columns whose values are replaced with placeholders, in addition to the URLs and paths of the source server, in the format 'table.column'")
+	exportCmd.Flags().IntVar(&maxDepth, "max-depth", 0, "Maximum number of references followed from the exported entities (0 for unlimited)")
+	exportCmd.Flags().StringSliceVar(&pruneTables, "prune-at", nil, "Tables not to be followed when looking for related data")
+	exportCmd.Flags().StringVar(&referenceRules, "reference-rules", "", "JSON file with rules not following single foreign keys, or following them only some levels")
+	exportCmd.Flags().StringVar(&targetSchema, "targetSchema", "", "Schema dump of the target server, to check for schema differences before exporting")
+	exportCmd.Flags().StringVar(&targetServerConfig, "targetServerConfig", "", "Configuration file with the database connection of the target server, to check for schema differences before exporting")
+	exportCmd.Flags().BoolVar(&skipExistingOnTarget, "skipExistingOnTarget", false, "Skip the rows already present on the target server, read from the database of --targetServerConfig")
+	exportCmd.Flags().StringVar(&packageInventory, "package-inventory", "", "Package inventory written by the inventory command on the target server, whose package files are not exported")
+	exportCmd.Flags().StringVar(&packageStore, "package-store", "", "File store the package files are read from instead of /var/spacewalk: a folder, nfs://host/path, s3://bucket/prefix or an http(s):// URL")
+	exportCmd.Flags().StringVar(&imageStore, "image-store", "", "File store the OS image files are read from instead of /srv/www/os-images: a folder, nfs://host/path, s3://bucket/prefix or an http(s):// URL")
+	exportCmd.Flags().StringVar(&peripheralFQDN, "registerPeripheral", "", "Register the target server FQDN as an ISS peripheral (slave) of this server")
+	exportCmd.Flags().StringVar(&exportFormat, "format", "sql", "Export format: 'sql', or 'legacy-xml' for servers using satellite-sync (channels only)")
+	exportCmd.Flags().StringArrayVar(&exportPlaceholders, "placeholder", nil, "Replace a value of the source server with a placeholder substituted on import, in the format 'TOKEN=value', or 'TOKEN' for the detected SERVER_FQDN and MOUNT_POINT (can be repeated)")
+	exportCmd.Flags().StringVar(&pillarRewriteRules, "pillarRewriteRules", "", "JSON file with rules rewriting host specific values of the exported pillars")
+	exportCmd.Flags().StringSliceVar(&sensitiveColumns, "sensitiveColumns", nil, "Additional columns, as 'table.column' or 'column', whose values are masked in the trace log")
+	exportCmd.Flags().BoolVar(&hotStandbySource, "hot-standby-source", false, "Export from a streaming replica of the database: connections are read-only and statements time out")
+	exportCmd.Flags().DurationVar(&sourceStatementTimeout, "source-statement-timeout", time.Hour, "Maximum duration of a statement on the source database with --hot-standby-source")
+	exportCmd.Flags().BoolVar(&singleSnapshot, "snapshot", true, "Read the whole export in a single REPEATABLE READ snapshot, so rows changed during the export don't leave references to rows exported before or after them")
+	exportCmd.Flags().BoolVar(&advisoryLock, "advisory-lock", false, "Hold the export advisory lock during the export, waiting for the services changing channel content which take it")
+	exportCmd.Flags().StringSliceVar(&pauseBunches, "pause-bunches", nil, "Taskomatic bunches whose periodic schedules are paused during the export, like repo-sync-bunch")
+	exportCmd.Flags().DurationVar(&exportStatementTimeout, "statement-timeout", 0, "Maximum duration of a query, like 10m (0 for unlimited)")
+	exportCmd.Flags().DurationVar(&exportConnectTimeout, "connect-timeout", 0, "Maximum duration of opening a database connection, like 30s (0 for unlimited)")
+	exportCmd.Flags().DurationVar(&exportDeadline, "deadline", 0, "Maximum duration of the whole export, like 4h (0 for unlimited)")
+	exportCmd.Flags().IntVar(&queryAttempts, "query-attempts", 3, "Attempts of a read query failing with a transient error, like a lock conflict, before the export fails (1 to never repeat)")
+	exportCmd.Flags().DurationVar(&queryRetryBackoff, "query-retry-backoff", time.Second, "Wait before repeating a failed query, doubled at every repetition")
+	exportCmd.Flags().DurationVar(&queryRetryMaxBackoff, "query-retry-max-backoff", 30*time.Second, "Maximum wait before repeating a failed query")
+	exportCmd.Flags().StringSliceVar(&queryRetryErrors, "query-retry-errors", sqlUtil.DefaultRetryableErrors, "SQLSTATE codes of the transient errors repeating a query")
+	exportCmd.Flags().IntVar(&copyWorkers, "copyWorkers", dumper.DefaultCopyWorkers, "Number of package and image files copied at the same time, verifying their checksums")
+	exportCmd.Flags().StringVar(&keyMemoryLimit, "key-memory-limit", "1G", "Memory of the keys of the processed rows above which they are spilled to disk, like 512M")
+	exportCmd.Flags().StringVar(&keySpillDirectory, "key-spill-dir", "", "Directory the keys of the processed rows are spilled to (default the system temporary directory)")
+	exportCmd.Flags().StringVar(&idStrategy, "id-strategy", dumper.IdsAlwaysNew, "How the ids generated by sequences are exported: always-new-ids, preserve-source-ids keeping the ids of this server, or lookup-by-unique-index taking new ids for new rows only")
+	exportCmd.Flags().StringVar(&statisticsStore, "statistics-store", "", "File collecting the rows and write durations of the tables across exports, ordering the tables and estimating the duration of the next exports")
+	exportCmd.Flags().BoolVar(&verifyReferences, "verify-references", false, "Report the references of the exported rows to rows which are neither exported nor vendor data, so they must exist on the target")
+	exportCmd.Flags().StringVar(&dedup, "dedup", "exact", "How the processed rows are remembered: exact, or approximate using bloom filters which need much less memory")
+	addNotificationFlags(exportCmd, &exportNotifications)
+	exportCmd.Flags().StringVar(&exportOtlpEndpoint, "otlp-endpoint", "", "OTLP/HTTP collector the phases of the export are traced to, like http://localhost:4318 (default $OTEL_EXPORTER_OTLP_ENDPOINT)")
+	exportCmd.Flags().StringVar(&exportedKeysCache, "exportedKeysCache", "", "File with the rows exported to the same target before, which are skipped if unchanged")
+	exportCmd.Flags().BoolVar(&exportedKeysImported, "exportedKeysImported", false, "The previous export recorded in the exported keys cache was imported on the target, so its rows are skipped too")
 	exportCmd.Args = cobra.NoArgs
 
 	rootCmd.AddCommand(exportCmd)
@@ -43,12 +177,67 @@ func init() {
 
 func runExport(cmd *cobra.Command, args []string) {
 	log.Info().Msg("Export started")
-	// check output dir existence and create it if needed.
+	options := exportOptions()
+	options.OnOutputStarted = func() {
+		// failures from now on leave an incomplete export behind
+		defaultExitCode = utils.ExitPartialExport
+	}
+	if err := syncEngine.NewExporter().Export(options); err != nil {
+		exitWithFailure(err)
+	}
+}
+
+// archiveStream is the standard output of the process the archive is streamed to with --archive=-
+var archiveStream *os.File
 
+// redirectStandardOutput keeps the standard output for the archive streamed by the export command, the log and
+// the reports printed by the export are written to the standard error instead
+func redirectStandardOutput(cmd *cobra.Command) {
+	if cmd != exportCmd || archiveFile != exportArchive.StreamPath || archiveStream != nil {
+		return
+	}
+	archiveStream = os.Stdout
+	os.Stdout = os.Stderr
+}
+
+// exportOptions returns the options of the export selected by the flags, shared by the export and plan commands
+func exportOptions() syncEngine.ExportOptions {
 	// Validate data
 	validatedDate, ok := utils.ValidateDate(startingDate)
 	if !ok {
-		log.Fatal().Msg("Unable to validate the date. Allowed formats are 'YYYY-MM-DD' or 'YYYY-MM-DD hh:mm:ss'")
+		log.Fatal().Int(utils.ExitCodeField, utils.ExitConfigError).Msg("Unable to validate the date. Allowed formats are 'YYYY-MM-DD' or 'YYYY-MM-DD hh:mm:ss'")
+	}
+
+	parsedWhereFilters, ok := parseWhereFilters(whereFilters)
+	if !ok {
+		log.Fatal().Int(utils.ExitCodeField, utils.ExitConfigError).Msg("Unable to parse the table filters. Allowed format is 'table: predicate'")
+	}
+
+	parsedPlaceholders, ok := placeholders.ParseValues(exportPlaceholders, serverConfig)
+	if !ok {
+		log.Fatal().Int(utils.ExitCodeField, utils.ExitConfigError).Msg("Unable to parse the placeholders. Allowed format is 'TOKEN=value' or 'TOKEN', with upper case tokens")
+	}
+	parsedPlaceholderColumns, ok := placeholders.NewColumnSet(placeholderColumns)
+	if !ok {
+		log.Fatal().Int(utils.ExitCodeField, utils.ExitConfigError).Msg("Unable to parse the placeholder columns. Allowed format is 'table.column'")
+	}
+
+	osImages, imageFilters, ok := parseImageSelection(imageSelection)
+	if !ok {
+		log.Fatal().Int(utils.ExitCodeField, utils.ExitConfigError).Msg("Unable to parse the image filters. Allowed format is 'name:version:arch', with shell patterns")
+	}
+
+	var volumeSize int64
+	if len(splitMedia) > 0 {
+		volumeSize, ok = exportArchive.ParseSize(splitMedia)
+		if !ok {
+			log.Fatal().Int(utils.ExitCodeField, utils.ExitConfigError).Msgf("Unable to parse the volume size %s. Allowed format is a number of bytes with an optional K, M, G or T suffix", splitMedia)
+		}
+	}
+
+	parsedKeyMemoryLimit, ok := exportArchive.ParseSize(keyMemoryLimit)
+	if !ok {
+		log.Fatal().Int(utils.ExitCodeField, utils.ExitConfigError).Msgf("Unable to parse the key memory limit %s. Allowed format is a number of bytes with an optional K, M, G or T suffix", keyMemoryLimit)
 	}
 
 	options := entityDumper.DumperOptions{
@@ -56,27 +245,123 @@ func runExport(cmd *cobra.Command, args []string) {
 		ChannelLabels:             channels,
 		ConfigLabels:              configChannels,
 		ChannelWithChildrenLabels: channelWithChildren,
+		IncludeChildren:           includeChildren,
+		IncludeCloneOrigins:       includeCloneOrigins,
+		RepoSyncSchedules:         repoSyncSchedules,
+		ExcludeFileLists:          !includeFileLists,
+		ChangelogLimit:            changelogLimit,
+		Anonymize:                 anonymizeExport,
+		CredentialsKeyFile:        exportCredentialsKeyFile,
 		OutputFolder:              outputDir,
 		MetadataOnly:              metadataOnly,
+		IncludeRepodata:           includeRepodata,
+		IncludeRepoCredentials:    includeRepoCredentials,
 		StartingDate:              validatedDate,
-		OSImages:                  includeImages,
+		OSImages:                  osImages,
+		ImageFilters:              imageFilters,
 		Containers:                includeContainers,
+		Autoinstall:               includeAutoinstall,
+		BootstrapRepositories:     includeBootstrapRepositories,
+		Products:                  includeProducts,
+		MaintenanceSchedules:      includeMaintenance,
+		Proxies:                   includeProxies,
+		Users:                     includeUsers,
+		IncludeUserPasswords:      includeUserPasswords,
 		Orgs:                      orgs,
+		WhereFilters:              parsedWhereFilters,
+		MaxDepth:                  maxDepth,
+		PruneTables:               pruneTables,
+		ReferenceRules:            referenceRules,
+		ExportedKeysCache:         exportedKeysCache,
+		ExportedKeysImported:      exportedKeysImported,
+		TargetSchema:              targetSchema,
+		TargetServerConfig:        targetServerConfig,
+		SkipExistingOnTarget:      skipExistingOnTarget,
+		PackageInventory:          packageInventory,
+		PackageStore:              packageStore,
+		ImageStore:                imageStore,
+		Placeholders:              parsedPlaceholders,
+		PlaceholderColumns:        parsedPlaceholderColumns,
+		ChannelSubdirectories:     channelSubdirectories,
+		HotStandbySource:          hotStandbySource,
+		SourceStatementTimeout:    sourceStatementTimeout,
+		AdvisoryLock:              advisoryLock,
+		PauseBunches:              pauseBunches,
+		NoSnapshot:                !singleSnapshot,
+		KeyMemoryLimit:            parsedKeyMemoryLimit,
+		KeySpillDirectory:         keySpillDirectory,
+		Dedup:                     dedup,
+		IdStrategy:                idStrategy,
+		VerifyReferences:          verifyReferences,
+		StatisticsStore:           statisticsStore,
+		CopyWorkers:               copyWorkers,
+	}
+	var stream io.Writer
+	if archiveFile == exportArchive.StreamPath {
+		if archiveStream == nil {
+			log.Fatal().Int(utils.ExitCodeField, utils.ExitConfigError).Msg("Only the export command streams the archive to the standard output")
+		}
+		archiveFile = ""
+		stream = archiveStream
 	}
-	entityDumper.DumpAllEntities(options)
-	var versionfile string
-	versionfile = path.Join(utils.GetAbsPath(outputDir), "version.txt")
-	vf, err := os.Open(versionfile)
-	defer vf.Close()
-	if os.IsNotExist(err) {
-		f, err := os.Create(versionfile)
-		if err != nil {
-			log.Panic().Msg("Unable to create version file")
+	retries := sqlUtil.RetryPolicy{RetryableErrors: queryRetryErrors, MaxAttempts: queryAttempts,
+		Backoff: queryRetryBackoff, MaxBackoff: queryRetryMaxBackoff}
+	return syncEngine.ExportOptions{
+		DumperOptions:      options,
+		Format:             exportFormat,
+		Archive:            archiveFile,
+		ArchiveStream:      stream,
+		VolumeSize:         volumeSize,
+		KmsKey:             kmsKey,
+		SensitiveColumns:   sensitiveColumns,
+		PillarRewriteRules: pillarRewriteRules,
+		RegisterPeripheral: peripheralFQDN,
+		Context:            cancelOnSignal(),
+		Deadline:           exportDeadline,
+		Timeouts:           sqlUtil.Timeouts{Statement: exportStatementTimeout, Connect: exportConnectTimeout},
+		Retries:            retries,
+		OtlpEndpoint:       exportOtlpEndpoint,
+		Notifications:      exportNotifications,
+	}
+}
+
+// parseImageSelection returns whether images are exported, and the filters selecting them. --images alone exports
+// all images, like the boolean flag it replaces.
+func parseImageSelection(values []string) (bool, []entityDumper.ImageFilter, bool) {
+	if len(values) == 0 || (len(values) == 1 && values[0] == "false") {
+		return false, nil, true
+	}
+	if len(values) == 1 && (values[0] == "*" || values[0] == "true") {
+		return true, nil, true
+	}
+	filters := make([]entityDumper.ImageFilter, 0, len(values))
+	for _, value := range values {
+		filter, ok := entityDumper.ParseImageFilter(value)
+		if !ok {
+			return false, nil, false
 		}
-		vf = f
+		filters = append(filters, filter)
 	}
-	version, product := utils.GetCurrentServerVersion(serverConfig)
-	vf.WriteString("product_name = " + product + "\n" + "version = " + version + "\n")
+	return true, filters, true
+}
 
-	log.Info().Msgf("Export done. Directory: %s", outputDir)
+// parseWhereFilters parses 'table: predicate' filters, joining multiple predicates for the same table
+func parseWhereFilters(filters []string) (map[string]string, bool) {
+	result := make(map[string]string)
+	for _, filter := range filters {
+		separator := strings.Index(filter, ":")
+		if separator <= 0 {
+			return nil, false
+		}
+		tableName := strings.ToLower(strings.TrimSpace(filter[:separator]))
+		predicate := strings.TrimSpace(filter[separator+1:])
+		if len(tableName) == 0 || len(predicate) == 0 {
+			return nil, false
+		}
+		if existing, ok := result[tableName]; ok {
+			predicate = fmt.Sprintf("(%s) AND (%s)", existing, predicate)
+		}
+		result[tableName] = predicate
+	}
+	return result, true
 }
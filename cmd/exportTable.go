@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/uyuni-project/inter-server-sync/entityDumper"
+	"github.com/uyuni-project/inter-server-sync/syncEngine"
+)
+
+var exportTableCmd = &cobra.Command{
+	Use:   "export-table",
+	Short: "Export rows of any table, with all the data they reference, to be imported in other server",
+	Run:   runExportTable,
+}
+
+var exportTableName string
+var exportTableFilter string
+var exportTableOutputDir string
+var exportTableWhereFilters []string
+var exportTableMaxDepth int
+var exportTablePruneTables []string
+var exportTableReferenceRules string
+
+func init() {
+	exportTableCmd.Flags().StringVar(&exportTableName, "table", "", "Table to be exported")
+	exportTableCmd.Flags().StringVar(&exportTableFilter, "filter", "", "Export only the rows matching the predicate, e.g. \"id = 1000\"")
+	exportTableCmd.Flags().StringVar(&exportTableOutputDir, "outputDir", ".", "Location for generated data")
+	exportTableCmd.Flags().StringArrayVar(&exportTableWhereFilters, "where", nil, "Export only rows of a table matching a predicate, in the format 'table: predicate' (can be repeated)")
+	exportTableCmd.Flags().IntVar(&exportTableMaxDepth, "max-depth", 0, "Maximum number of references followed from the exported entities (0 for unlimited)")
+	exportTableCmd.Flags().StringSliceVar(&exportTablePruneTables, "prune-at", nil, "Tables not to be followed when looking for related data")
+	exportTableCmd.Flags().StringVar(&exportTableReferenceRules, "reference-rules", "", "JSON file with rules not following single foreign keys, or following them only some levels")
+	exportTableCmd.MarkFlagRequired("table")
+	exportTableCmd.Args = cobra.NoArgs
+
+	rootCmd.AddCommand(exportTableCmd)
+}
+
+func runExportTable(cmd *cobra.Command, args []string) {
+	log.Info().Msg("Table export started")
+
+	parsedWhereFilters, ok := parseWhereFilters(exportTableWhereFilters)
+	if !ok {
+		log.Fatal().Msg("Unable to parse the table filters. Allowed format is 'table: predicate'")
+	}
+
+	options := entityDumper.DumperOptions{
+		ServerConfig:   serverConfig,
+		OutputFolder:   exportTableOutputDir,
+		WhereFilters:   parsedWhereFilters,
+		TableName:      strings.ToLower(exportTableName),
+		TableFilter:    exportTableFilter,
+		MaxDepth:       exportTableMaxDepth,
+		PruneTables:    exportTablePruneTables,
+		ReferenceRules: exportTableReferenceRules,
+	}
+	if err := syncEngine.NewExporter().Export(syncEngine.ExportOptions{DumperOptions: options, Context: cancelOnSignal()}); err != nil {
+		exitWithFailure(err)
+	}
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/uyuni-project/inter-server-sync/exportArchive"
+	"github.com/uyuni-project/inter-server-sync/utils"
+	"github.com/uyuni-project/inter-server-sync/wsTransport"
+)
+
+var fetchCmd = &cobra.Command{
+	Use:   "fetch",
+	Short: "Fetch an export from a hub serving exports, through an outbound WebSocket connection",
+	Long: "Connect to a hub running the serve command and store the requested export as an archive,\n" +
+		"which can be imported with the import command.",
+	Args: cobra.NoArgs,
+	Run:  runFetch,
+}
+
+var hubURL string
+var caCertFile string
+
+func init() {
+	fetchCmd.Flags().StringVar(&hubURL, "hub", "", "Hub serving the exports, as wss://host[:port]")
+	fetchCmd.Flags().StringVar(&tokenFile, "tokenFile", "", "File with the token to authenticate to the hub")
+	fetchCmd.Flags().StringVar(&caCertFile, "caCert", "", "CA certificate of the hub, if not trusted by the system")
+	fetchCmd.Flags().StringVar(&archiveFile, "archive", "", "Path of the fetched "+exportArchive.Extension+" archive")
+	fetchCmd.Flags().StringSliceVar(&channels, "channels", nil, "Channels to be exported")
+	fetchCmd.Flags().StringSliceVar(&channelWithChildren, "channel-with-children", nil, "Channels to be exported")
+	fetchCmd.Flags().BoolVar(&includeChildren, "include-children", false, "Also export the child channels of the --channels")
+	fetchCmd.Flags().BoolVar(&includeCloneOrigins, "include-clone-origins", false, "Also export the channels the exported channels were cloned from, keeping the clone relationships")
+	fetchCmd.Flags().StringSliceVar(&configChannels, "configChannels", nil, "Configuration Channels to be exported")
+	fetchCmd.Flags().BoolVar(&metadataOnly, "metadataOnly", false, "export only metadata")
+	fetchCmd.Flags().BoolVar(&includeRepodata, "includeRepodata", false, "Export the repository metadata of the channels, so it doesn't need to be generated on import")
+	fetchCmd.Flags().StringVar(&startingDate, "packagesOnlyAfter", "", "Only export packages added or modified after the specified date (date format can be 'YYYY-MM-DD' or 'YYYY-MM-DD hh:mm:ss')")
+	fetchCmd.Flags().BoolVar(&includeImages, "images", false, "Export OS images and associated metadata")
+	fetchCmd.Flags().BoolVar(&includeContainers, "containers", false, "Export containers metadata")
+	fetchCmd.Flags().BoolVar(&includeProducts, "products", false, "Export SUSE product data, to set up servers without SCC access")
+	fetchCmd.Flags().BoolVar(&includeAutoinstall, "autoinstall", false, "Export autoinstallable distributions and autoinstallation profiles")
+	fetchCmd.Flags().UintSliceVar(&orgs, "orgLimit", nil, "Export only for specified organizations")
+	fetchCmd.MarkFlagRequired("hub")
+	fetchCmd.MarkFlagRequired("tokenFile")
+	fetchCmd.MarkFlagRequired("archive")
+	rootCmd.AddCommand(fetchCmd)
+}
+
+func runFetch(cmd *cobra.Command, args []string) {
+	request, err := json.Marshal(exportRequest{
+		Channels:            channels,
+		ChannelWithChildren: channelWithChildren,
+		IncludeChildren:     includeChildren,
+		IncludeCloneOrigins: includeCloneOrigins,
+		ConfigChannels:      configChannels,
+		MetadataOnly:        metadataOnly,
+		IncludeRepodata:     includeRepodata,
+		PackagesOnlyAfter:   startingDate,
+		Images:              includeImages,
+		Containers:          includeContainers,
+		Products:            includeProducts,
+		Autoinstall:         includeAutoinstall,
+		Orgs:                orgs,
+	})
+	if err != nil {
+		log.Fatal().Err(err).Msg("Error encoding the export request")
+	}
+	archivePath := utils.GetAbsPath(archiveFile)
+	if !strings.HasSuffix(archivePath, exportArchive.Extension) {
+		archivePath += exportArchive.Extension
+	}
+
+	tlsConfig := utils.RestrictTLS(&tls.Config{})
+	if len(caCertFile) > 0 {
+		caCert, err := os.ReadFile(utils.GetAbsPath(caCertFile))
+		if err != nil {
+			log.Fatal().Err(err).Msg("Error reading the CA certificate")
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caCert) {
+			log.Fatal().Msgf("No certificate found in %s", caCertFile)
+		}
+	}
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+readToken(tokenFile))
+	conn, err := wsTransport.Dial(strings.TrimSuffix(hubURL, "/")+exportEndpoint, header, tlsConfig)
+	if err != nil {
+		log.Fatal().Err(err).Msgf("Error connecting to %s", hubURL)
+	}
+	if err := conn.WriteText(request); err != nil {
+		log.Fatal().Err(err).Msg("Error sending the export request")
+	}
+
+	file, err := os.OpenFile(archivePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Error creating the archive")
+	}
+	defer file.Close()
+	log.Info().Msgf("Waiting for the export of %s", hubURL)
+	written, err := io.Copy(file, conn)
+	if err != nil {
+		file.Close()
+		os.Remove(archivePath)
+		log.Fatal().Err(err).Msg("Error fetching the export")
+	}
+	log.Info().Msgf("Export fetched: %s (%d bytes)", archivePath, written)
+}
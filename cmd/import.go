@@ -1,18 +1,14 @@
 package cmd
 
 import (
-	"fmt"
 	"io"
 	"os"
-	"os/exec"
-	"path"
-	"strings"
+	"time"
 
-	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
-	"github.com/uyuni-project/inter-server-sync/dumper/pillarDumper"
-	"github.com/uyuni-project/inter-server-sync/utils"
-	"github.com/uyuni-project/inter-server-sync/xmlrpc"
+	"github.com/uyuni-project/inter-server-sync/exportArchive"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+	"github.com/uyuni-project/inter-server-sync/syncEngine"
 )
 
 var importCmd = &cobra.Command{
@@ -24,208 +20,112 @@ var importCmd = &cobra.Command{
 var importDir string
 var xmlRpcUser string
 var xmlRpcPassword string
+var targetSSH string
+var hubRegistration bool
+var orgMappingEntries []string
+var orgMappingFile string
+var groupMappingEntries []string
+var groupMappingFile string
+var channelRenameEntries []string
+var importPlaceholders []string
+var reportFile string
+var refreshReportDb bool
+var onlyTables []string
+var batchSize int
+var resumeImport bool
+var progressFile string
+var bulkLoadImport bool
+var strictImport bool
+var importCredentialsKeyFile string
+var importKmsKey string
+var createMissingOrgs bool
+var importPackageStore string
+var importImageStore string
+
+// limits of the time spent waiting for the database
+var importStatementTimeout time.Duration
+var importConnectTimeout time.Duration
+var importDeadline time.Duration
+
+var importOtlpEndpoint string
 
 func init() {
 
-	importCmd.Flags().StringVar(&importDir, "importDir", ".", "Location import data from")
+	importCmd.Flags().StringVar(&importDir, "importDir", ".", "Location import data from: export directory, archive, volume of a split archive, or - to read a streamed archive from the standard input")
 	importCmd.Flags().StringVar(&xmlRpcUser, "xmlRpcUser", "admin", "A username to access the XML-RPC Api")
 	importCmd.Flags().StringVar(&xmlRpcPassword, "xmlRpcPassword", "admin", "A password to access the XML-RPC Api")
+	importCmd.Flags().BoolVar(&hubRegistration, "registerHub", false, "Register the server the data was exported from as ISS hub (master) of this server")
+	importCmd.Flags().StringArrayVar(&orgMappingEntries, "org-map", nil, "Import the data of a source organization into another organization, in the format 'source_id=target_id' (can be repeated)")
+	importCmd.Flags().StringVar(&orgMappingFile, "org-map-file", "", "File with one 'source_id=target_id' organization mapping per line")
+	importCmd.Flags().BoolVar(&createMissingOrgs, "create-missing-orgs", false, "Create the organizations the export references which neither exist on this server nor are mapped")
+	importCmd.Flags().StringArrayVar(&groupMappingEntries, "group-map", nil, "Attach the group scoped pillars of a source system group to another group, in the format 'source_id=target_id' (can be repeated)")
+	importCmd.Flags().StringVar(&groupMappingFile, "group-map-file", "", "File with one 'source_id=target_id' system group mapping per line")
+	importCmd.Flags().StringArrayVar(&channelRenameEntries, "rename-channel", nil, "Import a channel with another label, in the format 'old-label=new-label' (can be repeated)")
+	importCmd.Flags().StringArrayVar(&importPlaceholders, "placeholder", nil, "Value substituted for a placeholder of the export, in the format 'TOKEN=value' (can be repeated). SERVER_FQDN and MOUNT_POINT are detected when not set")
+	importCmd.Flags().StringSliceVar(&onlyTables, "only-tables", nil, "Import only the statements of these tables from the export")
+	importCmd.Flags().IntVar(&batchSize, "batchSize", 0, "Commit the statements in batches of this size, recording the progress so the import can be resumed (0 for a single transaction)")
+	importCmd.Flags().BoolVar(&resumeImport, "resume", false, "Resume an import run with --batchSize after the last committed batch")
+	importCmd.Flags().StringVar(&progressFile, "progressFile", "", "File the progress of an import in batches is recorded in (default importProgress.json in the import directory, or next to the archive)")
+	importCmd.Flags().BoolVar(&strictImport, "strict", false, "Roll back the whole import when a statement fails, instead of the statements of its table only")
+	importCmd.Flags().BoolVar(&bulkLoadImport, "bulk-load", false, "Drop the foreign keys and secondary indexes of the imported tables during the import, then restore and validate them")
+	importCmd.Flags().StringVar(&reportFile, "reportFile", "", "File the JSON report of the imported rows is written to (default importReport.json in the import directory, or next to the archive)")
+	importCmd.Flags().BoolVar(&refreshReportDb, "reportdb", false, "Refresh the rows of the imported channels, their packages and patches in the reporting database of this server")
+	importCmd.Flags().DurationVar(&importStatementTimeout, "statement-timeout", 0, "Maximum duration of a query checking the target server, like 10m (0 for unlimited). The statements of the export are limited by --deadline only")
+	importCmd.Flags().DurationVar(&importConnectTimeout, "connect-timeout", 0, "Maximum duration of opening a database connection, like 30s (0 for unlimited)")
+	importCmd.Flags().DurationVar(&importDeadline, "deadline", 0, "Maximum duration of the whole import, like 4h (0 for unlimited)")
+	addNotificationFlags(importCmd, &importNotifications)
+	importCmd.Flags().StringVar(&importOtlpEndpoint, "otlp-endpoint", "", "OTLP/HTTP collector the phases of the import are traced to, like http://localhost:4318 (default $OTEL_EXPORTER_OTLP_ENDPOINT)")
+	importCmd.Flags().StringVar(&importKmsKey, "kms-key", "", "Key management service key of the export's --kms-key decrypting the archive: vault:<mount>/<key>, awskms:<key id> or exec:<command>. Archives encrypted with another key are refused")
+	importCmd.Flags().StringVar(&importCredentialsKeyFile, "credentialsKeyFile", "", "File with the hex encoded key of --credentialsKeyFile of the export, opening the sealed image store passwords. Without key, they are asked on the terminal")
+	importCmd.Flags().StringVar(&importPackageStore, "package-store", "", "File store the package files are uploaded to instead of /var/spacewalk: a folder, nfs://host/path, s3://bucket/prefix or an http(s):// URL")
+	importCmd.Flags().StringVar(&importImageStore, "image-store", "", "File store the OS image files are uploaded to instead of /srv/www/os-images: a folder, nfs://host/path, s3://bucket/prefix or an http(s):// URL")
+	importCmd.Flags().StringVar(&targetSSH, "target-ssh", "", "Import into a remote server through ssh (user@host), instead of the local one")
 	importCmd.Args = cobra.NoArgs
 
 	rootCmd.AddCommand(importCmd)
 }
 
 func runImport(cmd *cobra.Command, args []string) {
-	absImportDir := utils.GetAbsPath(importDir)
-	log.Info().Msg(fmt.Sprintf("starting import from dir %s", absImportDir))
-	fversion, fproduct := getImportVersionProduct(absImportDir)
-	sversion, sproduct := utils.GetCurrentServerVersion(serverConfig)
-	if fversion != sversion || fproduct != sproduct {
-		log.Panic().Msgf("Wrong version detected. Fileversion = %s ; Serverversion = %s", fversion, sversion)
+	var importStream io.Reader
+	if importDir == exportArchive.StreamPath {
+		// terminalPrompt asks no passwords then, the standard input is not a terminal
+		importStream = os.Stdin
 	}
-	validateFolder(absImportDir)
-	runPackageFileSync(absImportDir)
-
-	runImageFileSync(absImportDir, serverConfig)
-
-	runImportSql(absImportDir, serverConfig)
-	log.Info().Msg("import finished")
-}
-
-func getImportVersionProduct(path string) (string, string) {
-	var versionfile string
-	versionfile = path + "/version.txt"
-	version, err := utils.ScannerFunc(versionfile, "version")
-	if err != nil {
-		log.Error().Msg("Version not found.")
-	}
-	product, err := utils.ScannerFunc(versionfile, "product_name")
-	if err != nil {
-		log.Fatal().Msg("Product not found")
-	}
-	log.Debug().Msgf("Import Product: %s; Version: %s", product, version)
-	return version, product
-}
-
-func validateFolder(absImportDir string) {
-	_, err := os.Stat(fmt.Sprintf("%s/sql_statements.sql.gz", absImportDir))
-	if err != nil {
-		if os.IsNotExist(err) {
-			_, err = os.Stat(fmt.Sprintf("%s/sql_statements.sql", absImportDir))
-			if err != nil {
-				log.Fatal().Err(err).Msg("No usable .sql or .gz file found in import directory")
-			}
-		} else {
-			log.Fatal().Err(err)
-		}
-	}
-}
-
-func hasConfigChannels(absImportDir string) bool {
-	_, err := os.Stat(fmt.Sprintf("%s/exportedConfigs.txt", absImportDir))
-	log.Info().Err(err).Msg(fmt.Sprintf("no export config file found: %s/exportedConfigs.txt", absImportDir))
-	return err == nil || os.IsExist(err)
-}
-
-func runPackageFileSync(absImportDir string) {
-	packagesImportDir := fmt.Sprintf("%s/packages/", absImportDir)
-	err := utils.FolderExists(packagesImportDir)
-	if err != nil {
-		if os.IsNotExist(err) {
-			log.Info().Msg("no package files to import")
-			return
-		} else {
-			log.Fatal().Err(err).Msg("Error getting import packages folder")
-		}
-	}
-
-	rsyncParams := make([]string, 0)
-	if log.Debug().Enabled() {
-		rsyncParams = append(rsyncParams, "-v")
-	}
-
-	rsyncParams = append(rsyncParams, "-og", "--chown=wwwrun:www", "-r",
-		packagesImportDir, "/var/spacewalk/packages/")
-
-	cmd := exec.Command("rsync", rsyncParams...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	log.Info().Msg("starting importing package files")
-	err = cmd.Run()
-	if err != nil {
-		log.Fatal().Err(err).Msg("error importing package files")
-	}
-}
-
-func runConfigFilesSync(labels []string, user string, password string) (interface{}, error) {
-	client := xmlrpc.NewClient(user, password)
-	return client.SyncConfigFiles(labels)
-}
-
-func runImageFileSync(absImportDir string, serverConfig string) {
-	imagesImportDir := path.Join(absImportDir, "images")
-	err := utils.FolderExists(imagesImportDir)
+	err := syncEngine.NewImporter().Import(syncEngine.ImportOptions{
+		ImportDir:          importDir,
+		ImportStream:       importStream,
+		KmsKey:             importKmsKey,
+		ServerConfig:       serverConfig,
+		TargetSSH:          targetSSH,
+		XmlRpcUser:         xmlRpcUser,
+		XmlRpcPassword:     xmlRpcPassword,
+		RegisterHub:        hubRegistration,
+		OrgMapping:         orgMappingEntries,
+		OrgMappingFile:     orgMappingFile,
+		CreateMissingOrgs:  createMissingOrgs,
+		GroupMapping:       groupMappingEntries,
+		GroupMappingFile:   groupMappingFile,
+		ChannelRenames:     channelRenameEntries,
+		Placeholders:       importPlaceholders,
+		OnlyTables:         onlyTables,
+		BatchSize:          batchSize,
+		Resume:             resumeImport,
+		ProgressFile:       progressFile,
+		BulkLoad:           bulkLoadImport,
+		Strict:             strictImport,
+		ReportFile:         reportFile,
+		ReportDb:           refreshReportDb,
+		CredentialsKeyFile: importCredentialsKeyFile,
+		CredentialsPrompt:  terminalPrompt(),
+		Context:            cancelOnSignal(),
+		Deadline:           importDeadline,
+		Timeouts:           sqlUtil.Timeouts{Statement: importStatementTimeout, Connect: importConnectTimeout},
+		PackageStore:       importPackageStore,
+		ImageStore:         importImageStore,
+		OtlpEndpoint:       importOtlpEndpoint,
+		Notifications:      importNotifications,
+	})
 	if err != nil {
-		if os.IsNotExist(err) {
-			log.Info().Msg("No image files to import")
-			return
-		} else {
-			log.Fatal().Err(err).Msg("Error reading import folder for images")
-		}
-	}
-
-	rsyncParams := make([]string, 0)
-	if log.Debug().Enabled() {
-		rsyncParams = append(rsyncParams, "-v")
-	}
-	rsyncParams = append(rsyncParams, "-og", "--chown=salt:susemanager", "--chmod=Du=rwx,Dgo=rx,Fu=rw,Fgo=r",
-		"-r", "--exclude=pillars", imagesImportDir+"/", "/srv/www/os-images")
-
-	cmd := exec.Command("rsync", rsyncParams...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	log.Info().Msg("Copying image files")
-	err = cmd.Run()
-	if err != nil {
-		log.Fatal().Err(err).Msg("Error importing image files")
-	}
-
-	pillarImportDir := path.Join(absImportDir, "images", "pillars")
-	err = utils.FolderExists(pillarImportDir)
-	if err != nil {
-		if os.IsNotExist(err) {
-			log.Debug().Msg("No pillar files to import")
-			return
-		} else {
-			log.Fatal().Err(err).Msg("Error reading import folder for pillars")
-		}
-	}
-
-	log.Info().Msg("Copying image pillar files")
-	pillarDumper.ImportImagePillars(pillarImportDir, utils.GetCurrentServerFQDN(serverConfig))
-}
-
-func importSqlFile(absImportDir string) {
-	cmd := exec.Command("spacewalk-sql", fmt.Sprintf("%s/sql_statements.sql", absImportDir))
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	log.Info().Msg("Starting SQL import")
-	err := cmd.Run()
-	if err != nil {
-		log.Fatal().Err(err).Msgf("Error running the SQL script")
-	}
-}
-
-func importGzFile(absImportDir string) {
-	cUnzip := exec.Command("gunzip", "-c", fmt.Sprintf("%s/sql_statements.sql.gz", absImportDir))
-	cImport := exec.Command("spacewalk-sql", "-")
-
-	pr, pw := io.Pipe()
-	cUnzip.Stdout = pw
-	cUnzip.Stderr = os.Stderr
-
-	cImport.Stdin = pr
-	cImport.Stdout = os.Stdout
-	cImport.Stderr = os.Stderr
-
-	log.Info().Msg("Starting SQL/GZ import")
-	cUnzip.Start()
-	cImport.Start()
-
-	go func() {
-		defer pw.Close()
-		cUnzip.Wait()
-	}()
-	err := cImport.Wait()
-	if err != nil {
-		log.Fatal().Err(err).Msgf("Error running the SQL script")
-	}
-}
-
-func runImportSql(absImportDir string, serverConfig string) {
-
-	if _, err := os.Stat(fmt.Sprintf("%s/sql_statements.sql.gz", absImportDir)); err == nil {
-		importGzFile(absImportDir)
-	} else {
-		if _, err := os.Stat(fmt.Sprintf("%s/sql_statements.sql", absImportDir)); err == nil {
-			importSqlFile(absImportDir)
-		}
-	}
-
-	pillarDumper.UpdateImagePillars(serverConfig)
-
-	if hasConfigChannels(absImportDir) {
-		labels := utils.ReadFileByLine(fmt.Sprintf("%s/exportedConfigs.txt", absImportDir))
-		log.Debug().Msg("Will call xml-rpc API to update filesystem")
-		_, err := runConfigFilesSync(labels, xmlRpcUser, xmlRpcPassword)
-		if err != nil {
-			log.Error().Err(err).Msgf(
-				"Error recreating configuration files. Please run spacecmd api configchannel.syncSaltFilesOnDisk -A '[[%s]]'",
-				strings.Join(labels, ", "),
-			)
-		}
-	} else {
-		log.Debug().Msg("No configuration channels, NO CALL to xml-rpc API")
+		exitWithFailure(err)
 	}
 }
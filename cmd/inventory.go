@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/uyuni-project/inter-server-sync/dumper/packageDumper"
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/storage"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+var inventoryCmd = &cobra.Command{
+	Use:   "inventory",
+	Short: "Write the inventory of the package files of this server, left out of the exports for it",
+	Long: "Write the checksums and paths of the package files of this server into a compact inventory file.\n" +
+		"Exports with --package-inventory on the source server leave out the package files the inventory lists.",
+	Args: cobra.NoArgs,
+	Run:  runInventory,
+}
+
+var inventoryFile string
+var inventoryPackageStore string
+
+func init() {
+	inventoryCmd.Flags().StringVar(&inventoryFile, "output", "", "File the package inventory is written to, compressed with gzip")
+	inventoryCmd.Flags().StringVar(&inventoryPackageStore, "package-store", "", "File store the package files are looked up in instead of /var/spacewalk, see export --package-store")
+	inventoryCmd.MarkFlagRequired("output")
+	rootCmd.AddCommand(inventoryCmd)
+}
+
+func runInventory(cmd *cobra.Command, args []string) {
+	db := schemareader.GetReadOnlyDBconnection(serverConfig)
+	defer db.Close()
+	if len(inventoryPackageStore) > 0 {
+		store, err := storage.Open(inventoryPackageStore)
+		if err != nil {
+			utils.Fatal().Err(err).Int(utils.ExitCodeField, utils.ExitConfigError).Msg("Error opening the package store")
+		}
+		defer store.Close()
+		packageDumper.SetPackageStore(store)
+	}
+	count := packageDumper.WriteInventory(db, utils.GetAbsPath(inventoryFile))
+	log.Info().Msgf("Package inventory written to %s: %d package files", inventoryFile, count)
+}
@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/uyuni-project/inter-server-sync/exportMerger"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+var mergeCmd = &cobra.Command{
+	Use:   "merge <exportDir> <exportDir>...",
+	Short: "Merge several exports into a single export",
+	Long: "Merge exports of several hubs or export runs into a single export which can be imported at once.\n" +
+		"Statements are applied in the order of the given exports, duplicated statements are written once.",
+	Args: cobra.MinimumNArgs(2),
+	Run:  runMerge,
+}
+
+func init() {
+	mergeCmd.Flags().StringVar(&outputDir, "outputDir", ".", "Location for generated data")
+	rootCmd.AddCommand(mergeCmd)
+}
+
+func runMerge(cmd *cobra.Command, args []string) {
+	inputDirs := make([]string, 0, len(args))
+	for _, arg := range args {
+		inputDirs = append(inputDirs, utils.GetAbsPath(arg))
+	}
+	exportMerger.MergeExports(inputDirs, utils.GetAbsPath(outputDir))
+}
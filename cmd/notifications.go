@@ -0,0 +1,17 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/uyuni-project/inter-server-sync/notify"
+)
+
+// notification targets of the export, apply and import commands
+var exportNotifications notify.Config
+var importNotifications notify.Config
+
+// addNotificationFlags adds the flags selecting the targets notified when the command ends
+func addNotificationFlags(cmd *cobra.Command, config *notify.Config) {
+	cmd.Flags().StringArrayVar(&config.Targets, "notify", nil, "Notify the outcome of the "+cmd.Name()+" with its summary: https:// webhooks receive it as JSON, slack+https:// webhooks as Slack message and mailto: recipients as e-mail (can be repeated)")
+	cmd.Flags().StringVar(&config.SmtpServer, "notify-smtp-server", notify.DefaultSmtpServer, "Mail server the e-mail notifications are sent through, as host:port")
+	cmd.Flags().StringVar(&config.Sender, "notify-sender", "", "Sender of the e-mail notifications (default inter-server-sync@<host name>)")
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/uyuni-project/inter-server-sync/dumper"
+	"github.com/uyuni-project/inter-server-sync/syncEngine"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+var planCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Describe an export for review, without writing it",
+	Long: "Describe everything the export selected by the export flags would write as a JSON plan: the tables with\n" +
+		"their number of rows, the filters and the transformations of the rows. The approved plan is run by apply.",
+	Args: cobra.NoArgs,
+	Run:  runPlan,
+}
+
+var applyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Run the export of an approved plan",
+	Long: "Run the export described by a plan unchanged. Plans changed after they were made, or made by another\n" +
+		"version, are refused. Tables exporting another number of rows than planned are logged.",
+	Args: cobra.NoArgs,
+	Run:  runApply,
+}
+
+var planFile string
+
+func init() {
+	planCmd.Flags().AddFlagSet(exportCmd.Flags())
+	planCmd.Flags().StringVar(&planFile, "planFile", "", "File the plan is written to")
+	planCmd.MarkFlagRequired("planFile")
+	rootCmd.AddCommand(planCmd)
+
+	applyCmd.Flags().StringVar(&planFile, "plan", "", "Approved plan file")
+	applyCmd.MarkFlagRequired("plan")
+	addNotificationFlags(applyCmd, &exportNotifications)
+	rootCmd.AddCommand(applyCmd)
+}
+
+func runPlan(cmd *cobra.Command, args []string) {
+	log.Info().Msg("Export plan started")
+	plan, err := syncEngine.NewPlanner().Plan(exportOptions())
+	if err != nil {
+		exitWithFailure(err)
+	}
+	syncEngine.WriteExportPlan(plan, planFile)
+	log.Info().Msgf("Export plan done: %d tables", len(plan.Tables))
+	if plan.EstimatedDuration > 0 {
+		log.Info().Msgf("Writing the tables is estimated to take %s", plan.EstimatedDuration.Round(time.Second))
+	}
+}
+
+func runApply(cmd *cobra.Command, args []string) {
+	plan := syncEngine.ReadExportPlan(planFile)
+	log.Info().Msgf("Applying the export plan made on %s", plan.Created.Format("2006-01-02 15:04:05"))
+	options := plan.ExportOptions(syncEngine.ExportOptions{
+		Context:       cancelOnSignal(),
+		Notifications: exportNotifications,
+		OnOutputStarted: func() {
+			// failures from now on leave an incomplete export behind
+			defaultExitCode = utils.ExitPartialExport
+		},
+	})
+	if err := syncEngine.NewExporter().Export(options); err != nil {
+		exitWithFailure(err)
+	}
+	if differences := plan.WarnDifferences(dumper.WriteStatistics()); differences > 0 {
+		log.Warn().Msgf("%d tables differ from the plan, the data of the source server changed since it was made", differences)
+	}
+}
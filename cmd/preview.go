@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/uyuni-project/inter-server-sync/entityDumper"
+	"github.com/uyuni-project/inter-server-sync/syncEngine"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+var previewCmd = &cobra.Command{
+	Use:   "preview",
+	Short: "Print the statements exported for some tables of a channel, without writing an export",
+	Long: "Print the statements the export of a channel generates for the tables, in the order of the export, on\n" +
+		"the standard output. The source database is only read, and nothing is written.",
+	Args: cobra.NoArgs,
+	Run:  runPreview,
+}
+
+var previewChannel string
+var previewTables []string
+
+func init() {
+	previewCmd.Flags().StringVar(&previewChannel, "channel", "", "Channel whose export is previewed")
+	previewCmd.Flags().StringSliceVar(&previewTables, "table", nil, "Tables whose statements are printed")
+	previewCmd.Flags().StringArrayVar(&whereFilters, "where", nil, "Export only rows of a table matching a predicate, in the format 'table: predicate' (can be repeated)")
+	previewCmd.Flags().BoolVar(&includeFileLists, "include-filelists", true, "Export the file lists of the packages")
+	previewCmd.Flags().IntVar(&changelogLimit, "changelog-limit", 0, "Export only the newest N changelog entries of every package (0 for all)")
+	previewCmd.MarkFlagRequired("channel")
+	previewCmd.MarkFlagRequired("table")
+	rootCmd.AddCommand(previewCmd)
+}
+
+func runPreview(cmd *cobra.Command, args []string) {
+	parsedWhereFilters, ok := parseWhereFilters(whereFilters)
+	if !ok {
+		log.Fatal().Int(utils.ExitCodeField, utils.ExitConfigError).Msg("Unable to parse the table filters. Allowed format is 'table: predicate'")
+	}
+	options := entityDumper.DumperOptions{
+		ServerConfig:     serverConfig,
+		ChannelLabels:    []string{previewChannel},
+		WhereFilters:     parsedWhereFilters,
+		ExcludeFileLists: !includeFileLists,
+		ChangelogLimit:   changelogLimit,
+	}
+	err := syncEngine.NewPreviewer().Preview(syncEngine.ExportOptions{DumperOptions: options, Context: cancelOnSignal()}, previewTables, os.Stdout)
+	if err != nil {
+		exitWithFailure(err)
+	}
+}
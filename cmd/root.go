@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"log/syslog"
 	"os"
 	"runtime/pprof"
@@ -12,16 +13,26 @@ import (
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
+	"github.com/uyuni-project/inter-server-sync/syncEngine"
+	"github.com/uyuni-project/inter-server-sync/utils"
 )
 
 var rootCmd = &cobra.Command{
 	Use:     "inter-server-sync",
 	Short:   "Uyuni Inter Server Sync tool",
-	Version: "0.2.7",
+	Version: syncEngine.Version,
 }
 
 func Execute() {
-	cobra.CheckErr(rootCmd.Execute())
+	defer exitOnPanic()
+	// commands only fail on invalid arguments, other failures exit from the log events
+	if err := rootCmd.Execute(); err != nil {
+		// the flags may not be parsed
+		if errorJson || utils.Contains(os.Args, "--error-json") {
+			printFailure(utils.NewFailure(utils.ExitConfigError, err.Error()))
+		}
+		os.Exit(utils.ExitConfigError)
+	}
 }
 
 //var cfgFile string
@@ -29,10 +40,17 @@ var logLevel string
 var serverConfig string
 var cpuProfile string
 var memProfile string
+var logFile string
+var logMaxSize int
+var logMaxBackups int
+var fips bool
+var digest string
 
 func init() {
 	rootCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		redirectStandardOutput(cmd)
 		logInit()
+		cryptoInit()
 		cpuProfileInit()
 		memProfileDump()
 	}
@@ -43,6 +61,12 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&serverConfig, "serverConfig", "/etc/rhn/rhn.conf", "Server configuration file")
 	rootCmd.PersistentFlags().StringVar(&cpuProfile, "cpuProfile", "", "cpuProfile export folder location")
 	rootCmd.PersistentFlags().StringVar(&memProfile, "memProfile", "", "memProfile export folder location")
+	rootCmd.PersistentFlags().BoolVar(&errorJson, "error-json", false, "Print the details of a failure as JSON on the standard error")
+	rootCmd.PersistentFlags().StringVar(&logFile, "log-file", "", "File the log is written to, in addition to syslog and the standard output")
+	rootCmd.PersistentFlags().IntVar(&logMaxSize, "log-max-size", 100, "Size in MB the log file is rotated at (0 to never rotate)")
+	rootCmd.PersistentFlags().IntVar(&logMaxBackups, "log-max-backups", 5, "Number of rotated log files kept")
+	rootCmd.PersistentFlags().BoolVar(&fips, "fips", false, "Restrict the TLS of serve and fetch and the checksum digests to FIPS 140 approved algorithms (default true on hosts enforcing FIPS mode)")
+	rootCmd.PersistentFlags().StringVar(&digest, "digest", utils.DigestSha256, "Digest of the checksums of archive volumes, import progress and exported keys cache: sha256, sha384, sha512, or sha1 outside of FIPS mode")
 }
 
 func logCallerMarshalFunction(file string, line int) string {
@@ -68,10 +92,25 @@ func logCallerMarshalFunction(file string, line int) string {
 func logInit() {
 	syslogger, err := syslog.New(syslog.LOG_INFO|syslog.LOG_DEBUG|syslog.LOG_WARNING|syslog.LOG_ERR, "inter-server-sync")
 
-	syslogwriter := zerolog.SyslogLevelWriter(syslogger)
-
-	multi := zerolog.MultiLevelWriter(syslogwriter, os.Stdout)
-	log.Logger = zerolog.New(multi).With().Timestamp().Caller().Logger()
+	writers := []io.Writer{os.Stdout}
+	// without syslog the writer would panic, before the failures reach the exit code writer
+	if err == nil {
+		writers = append([]io.Writer{zerolog.SyslogLevelWriter(syslogger)}, writers...)
+	}
+	if len(logFile) > 0 {
+		rotatingFile, err := utils.OpenRotatingFile(utils.GetAbsPath(logFile), int64(logMaxSize)*1024*1024, logMaxBackups)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "could not open log file %s: %v\n", logFile, err)
+		} else {
+			writers = append(writers, rotatingFile)
+		}
+	}
+	// exits after the other writers logged the failure
+	writers = append(writers, exitCodeWriter{})
+	multi := zerolog.MultiLevelWriter(writers...)
+	// the run identifier groups the lines of one run in logs shared by several runs
+	runId := fmt.Sprintf("%s-%d", time.Now().Format("20060102150405"), os.Getpid())
+	log.Logger = zerolog.New(multi).With().Timestamp().Str("run", runId).Caller().Logger()
 	zerolog.CallerMarshalFunc = logCallerMarshalFunction
 	level, err := zerolog.ParseLevel(logLevel)
 	if err != nil {
@@ -81,6 +120,16 @@ func logInit() {
 	log.Info().Msg("Inter server sync started")
 }
 
+func cryptoInit() {
+	if !fips && utils.HostFipsEnabled() {
+		log.Info().Msg("The host enforces FIPS mode, only FIPS 140 approved algorithms are used")
+		fips = true
+	}
+	if err := utils.SetCrypto(digest, fips); err != nil {
+		log.Fatal().Err(err).Int(utils.ExitCodeField, utils.ExitConfigError).Msg("Invalid crypto configuration")
+	}
+}
+
 func cpuProfileInit() {
 	if cpuProfile != "" {
 		f, err := os.Create(cpuProfile + "end_cpu_profile.prof")
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+)
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Inspect the database schema as seen by the exporter",
+}
+
+var schemaDumpTables []string
+
+var schemaDumpCmd = &cobra.Command{
+	Use:   "dump",
+	Short: "Print the resolved schema metadata as JSON",
+	Args:  cobra.NoArgs,
+	Run:   runSchemaDump,
+}
+
+func init() {
+	schemaDumpCmd.Flags().StringSliceVar(&schemaDumpTables, "tables", nil, "Tables to be printed (all tables if not set)")
+	schemaCmd.AddCommand(schemaDumpCmd)
+	rootCmd.AddCommand(schemaCmd)
+}
+
+func runSchemaDump(cmd *cobra.Command, args []string) {
+	db := schemareader.GetDBconnection(serverConfig)
+	defer db.Close()
+
+	var tables map[string]schemareader.Table
+	if len(schemaDumpTables) == 0 {
+		tables = schemareader.ReadAllTablesSchema(db)
+	} else {
+		allTables := schemareader.ReadTablesSchema(db, schemaDumpTables)
+		tables = make(map[string]schemareader.Table)
+		for _, tableName := range schemaDumpTables {
+			tableName = strings.ToLower(tableName)
+			table, ok := allTables[tableName]
+			if !ok {
+				log.Fatal().Msgf("table %s not found", tableName)
+			}
+			tables[tableName] = table
+		}
+	}
+	if err := schemareader.DumpToJSON(os.Stdout, tables); err != nil {
+		log.Fatal().Err(err).Msg("error printing schema")
+	}
+}
@@ -0,0 +1,250 @@
+package cmd
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/uyuni-project/inter-server-sync/entityDumper"
+	"github.com/uyuni-project/inter-server-sync/exportArchive"
+	"github.com/uyuni-project/inter-server-sync/jobQueue"
+	"github.com/uyuni-project/inter-server-sync/syncEngine"
+	"github.com/uyuni-project/inter-server-sync/utils"
+	"github.com/uyuni-project/inter-server-sync/wsTransport"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve exports to peripheral servers connecting through WebSocket",
+	Long: "Serve exports to peripheral servers which can only open outbound connections.\n" +
+		"Peripherals connect with the fetch command, and the export archive is streamed back over the connection.",
+	Args: cobra.NoArgs,
+	Run:  runServe,
+}
+
+var listenAddress string
+var tlsCertFile string
+var tlsKeyFile string
+var tokenFile string
+var maxExports int
+var maxExportsPerPeripheral int
+var queueFile string
+
+// path of the WebSocket endpoint streaming the exports
+const exportEndpoint = "/inter-server-sync/export"
+
+// path of the endpoint listing the export jobs with GET, and canceling the job of /<id> with DELETE
+const jobsEndpoint = "/inter-server-sync/jobs"
+
+// interval of the pings keeping the connection open while the export runs
+const keepAliveInterval = 30 * time.Second
+
+// exportRequest selects the entities a peripheral fetches from the hub
+type exportRequest struct {
+	Channels            []string `json:"channels,omitempty"`
+	ChannelWithChildren []string `json:"channel_with_children,omitempty"`
+	IncludeChildren     bool     `json:"include_children,omitempty"`
+	IncludeCloneOrigins bool     `json:"include_clone_origins,omitempty"`
+	ConfigChannels      []string `json:"config_channels,omitempty"`
+	MetadataOnly        bool     `json:"metadata_only,omitempty"`
+	IncludeRepodata     bool     `json:"include_repodata,omitempty"`
+	PackagesOnlyAfter   string   `json:"packages_only_after,omitempty"`
+	Images              bool     `json:"images,omitempty"`
+	Containers          bool     `json:"containers,omitempty"`
+	Products            bool     `json:"products,omitempty"`
+	Autoinstall         bool     `json:"autoinstall,omitempty"`
+	Orgs                []uint   `json:"orgs,omitempty"`
+}
+
+func init() {
+	serveCmd.Flags().StringVar(&listenAddress, "listen", ":443", "Address the hub listens on for peripheral connections")
+	serveCmd.Flags().StringVar(&tlsCertFile, "tlsCert", "", "TLS certificate of the hub")
+	serveCmd.Flags().StringVar(&tlsKeyFile, "tlsKey", "", "Private key of the TLS certificate")
+	serveCmd.Flags().StringVar(&tokenFile, "tokenFile", "", "File with the token peripherals authenticate with")
+	serveCmd.Flags().IntVar(&maxExports, "maxExports", 2, "Export jobs running at the same time, the next ones are queued (0 for unlimited). Exports still run one at a time, while the archives of the previous ones are streamed")
+	serveCmd.Flags().IntVar(&maxExportsPerPeripheral, "maxExportsPerPeripheral", 1, "Export jobs of one peripheral running at the same time (0 for unlimited)")
+	serveCmd.Flags().StringVar(&queueFile, "queueFile", "", "File the export jobs are persisted in, so the jobs queued before a restart keep their place when their peripherals request them again")
+	serveCmd.MarkFlagRequired("tlsCert")
+	serveCmd.MarkFlagRequired("tlsKey")
+	serveCmd.MarkFlagRequired("tokenFile")
+	rootCmd.AddCommand(serveCmd)
+}
+
+func readToken(tokenFile string) string {
+	content, err := os.ReadFile(utils.GetAbsPath(tokenFile))
+	if err != nil {
+		log.Fatal().Err(err).Msg("Error reading the token file")
+	}
+	token := strings.TrimSpace(string(content))
+	if len(token) == 0 {
+		log.Fatal().Msgf("Token file %s is empty", tokenFile)
+	}
+	return token
+}
+
+// authorized checks the token of the request, answering unauthorized requests
+func authorized(token string, w http.ResponseWriter, r *http.Request) bool {
+	authorization := []byte(r.Header.Get("Authorization"))
+	if subtle.ConstantTimeCompare(authorization, []byte("Bearer "+token)) != 1 {
+		log.Warn().Msgf("Unauthorized request of %s from %s", r.URL.Path, r.RemoteAddr)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+func runServe(cmd *cobra.Command, args []string) {
+	token := readToken(tokenFile)
+	ctx := cancelOnSignal()
+	// exports running when the hub stops are canceled, then the hub waits for them to clean up
+	var runningExports sync.WaitGroup
+	queue := jobQueue.Open(utils.GetAbsPath(queueFile), jobQueue.Limits{MaxRunning: maxExports, MaxPerTarget: maxExportsPerPeripheral})
+	mux := http.NewServeMux()
+	mux.HandleFunc(exportEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(token, w, r) {
+			return
+		}
+		runningExports.Add(1)
+		defer runningExports.Done()
+		serveExport(ctx, queue, w, r)
+	})
+	mux.HandleFunc(jobsEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		if authorized(token, w, r) {
+			serveJobs(queue, w, r)
+		}
+	})
+	mux.HandleFunc(jobsEndpoint+"/", func(w http.ResponseWriter, r *http.Request) {
+		if authorized(token, w, r) {
+			serveJobs(queue, w, r)
+		}
+	})
+	server := &http.Server{Addr: listenAddress, Handler: mux, TLSConfig: utils.RestrictTLS(&tls.Config{})}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	log.Info().Msgf("Serving exports on %s%s", listenAddress, exportEndpoint)
+	err := server.ListenAndServeTLS(utils.GetAbsPath(tlsCertFile), utils.GetAbsPath(tlsKeyFile))
+	if err == http.ErrServerClosed {
+		runningExports.Wait()
+		log.Info().Msg("Stopped serving exports")
+		return
+	}
+	log.Fatal().Err(err).Msg("Error serving exports")
+}
+
+// serveExport queues the export requested by the peripheral, then runs it and streams its archive over the
+// connection once the limits of the queue allow it
+func serveExport(ctx context.Context, queue *jobQueue.Queue, w http.ResponseWriter, r *http.Request) {
+	conn, err := wsTransport.Upgrade(w, r)
+	if err != nil {
+		log.Warn().Err(err).Msgf("Invalid export request from %s", r.RemoteAddr)
+		return
+	}
+	message, err := conn.ReadMessage()
+	if err != nil {
+		log.Warn().Err(err).Msgf("Error reading the export request of %s", r.RemoteAddr)
+		conn.Close(wsTransport.CloseError, "invalid export request")
+		return
+	}
+	request := exportRequest{}
+	if err := json.Unmarshal(message, &request); err != nil {
+		conn.Close(wsTransport.CloseError, "invalid export request")
+		return
+	}
+	validatedDate, ok := utils.ValidateDate(request.PackagesOnlyAfter)
+	if !ok {
+		conn.Close(wsTransport.CloseError, "invalid packages date")
+		return
+	}
+
+	stopKeepAlive := conn.KeepAlive(keepAliveInterval)
+	defer stopKeepAlive()
+	job, err := queue.Run(ctx, peripheralHost(r), message, func(ctx context.Context, job jobQueue.Job) error {
+		return exportTo(ctx, conn, request, validatedDate, job)
+	})
+	if err == jobQueue.ErrCanceled {
+		conn.Close(wsTransport.CloseError, "export canceled")
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msgf("Error exporting job %s for %s", job.Id, r.RemoteAddr)
+		conn.Close(wsTransport.CloseError, "export failed")
+		return
+	}
+	conn.Close(wsTransport.CloseNormal, "")
+	log.Info().Msgf("Export of job %s streamed to %s", job.Id, r.RemoteAddr)
+}
+
+// exportTo runs the export of the job and streams its archive over the connection
+func exportTo(ctx context.Context, conn *wsTransport.Conn, request exportRequest, validatedDate string, job jobQueue.Job) error {
+	stagingDir, err := os.MkdirTemp("", "inter-server-sync-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(stagingDir)
+
+	log.Info().Msgf("Exporting %s for %s", job.Request, job.Target)
+	err = syncEngine.NewExporter().Export(syncEngine.ExportOptions{DumperOptions: entityDumper.DumperOptions{
+		ServerConfig:              serverConfig,
+		ChannelLabels:             request.Channels,
+		ChannelWithChildrenLabels: request.ChannelWithChildren,
+		IncludeChildren:           request.IncludeChildren,
+		IncludeCloneOrigins:       request.IncludeCloneOrigins,
+		ConfigLabels:              request.ConfigChannels,
+		OutputFolder:              stagingDir,
+		MetadataOnly:              request.MetadataOnly,
+		IncludeRepodata:           request.IncludeRepodata,
+		StartingDate:              validatedDate,
+		OSImages:                  request.Images,
+		Containers:                request.Containers,
+		Products:                  request.Products,
+		Autoinstall:               request.Autoinstall,
+		Orgs:                      request.Orgs,
+	}, Context: ctx})
+	if err != nil {
+		return err
+	}
+	if err := exportArchive.WriteTo(stagingDir, conn); err != nil {
+		return fmt.Errorf("error streaming the export: %w", err)
+	}
+	return nil
+}
+
+// peripheralHost returns the host of the peripheral, whose jobs are limited by --maxExportsPerPeripheral
+func peripheralHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// serveJobs lists the jobs of the queue, or cancels the job of the path
+func serveJobs(queue *jobQueue.Queue, w http.ResponseWriter, r *http.Request) {
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, jobsEndpoint), "/")
+	switch {
+	case r.Method == http.MethodGet && len(id) == 0:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(queue.List())
+	case r.Method == http.MethodDelete && len(id) > 0:
+		if err := queue.Cancel(id); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		log.Info().Msgf("Job %s canceled by %s", id, r.RemoteAddr)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,25 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/rs/zerolog/log"
+)
+
+// cancelOnSignal returns a context canceled on SIGINT or SIGTERM, stopping the running export or import.
+// The signal handler is removed once the context is canceled, so a second signal exits immediately.
+func cancelOnSignal() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		received := <-signals
+		signal.Stop(signals)
+		log.Warn().Msgf("Received %s, stopping. Send it again to exit immediately", received)
+		cancel()
+	}()
+	return ctx
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+	"github.com/uyuni-project/inter-server-sync/syncEngine"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check that the rows of an export are on this server, as its import wrote them",
+	Long: "Look up every row of the export on this server by its unique key, and report per table how many rows\n" +
+		"are present, missing, or differ in the other columns. The database is only read. The verification fails\n" +
+		"with the verification_failure code when rows are missing or differ.",
+	Args: cobra.NoArgs,
+	Run:  runVerify,
+}
+
+var verifyDir string
+var verifyReportFile string
+var verifyStatementTimeout time.Duration
+var verifyDeadline time.Duration
+
+func init() {
+	verifyCmd.Flags().StringVar(&verifyDir, "importDir", ".", "Export directory to verify")
+	verifyCmd.Flags().StringArrayVar(&orgMappingEntries, "org-map", nil, "Organization mapping of the import, in the format 'source_id=target_id' (can be repeated)")
+	verifyCmd.Flags().StringVar(&orgMappingFile, "org-map-file", "", "File with one 'source_id=target_id' organization mapping per line")
+	verifyCmd.Flags().StringArrayVar(&groupMappingEntries, "group-map", nil, "System group mapping of the import, in the format 'source_id=target_id' (can be repeated)")
+	verifyCmd.Flags().StringVar(&groupMappingFile, "group-map-file", "", "File with one 'source_id=target_id' system group mapping per line")
+	verifyCmd.Flags().StringArrayVar(&channelRenameEntries, "rename-channel", nil, "Channel rename of the import, in the format 'old-label=new-label' (can be repeated)")
+	verifyCmd.Flags().StringArrayVar(&importPlaceholders, "placeholder", nil, "Value substituted for a placeholder of the export, in the format 'TOKEN=value' (can be repeated)")
+	verifyCmd.Flags().StringSliceVar(&onlyTables, "only-tables", nil, "Verify only the rows of these tables")
+	verifyCmd.Flags().StringVar(&verifyReportFile, "reportFile", "", "File the JSON report of the verification is written to")
+	verifyCmd.Flags().DurationVar(&verifyStatementTimeout, "statement-timeout", 0, "Maximum duration of a query looking up rows, like 1m (0 for unlimited)")
+	verifyCmd.Flags().DurationVar(&verifyDeadline, "deadline", 0, "Maximum duration of the whole verification, like 1h (0 for unlimited)")
+	rootCmd.AddCommand(verifyCmd)
+}
+
+func runVerify(cmd *cobra.Command, args []string) {
+	_, err := syncEngine.NewVerifier().Verify(syncEngine.VerifyOptions{
+		ImportDir:        verifyDir,
+		ServerConfig:     serverConfig,
+		OrgMapping:       orgMappingEntries,
+		OrgMappingFile:   orgMappingFile,
+		GroupMapping:     groupMappingEntries,
+		GroupMappingFile: groupMappingFile,
+		ChannelRenames:   channelRenameEntries,
+		Placeholders:     importPlaceholders,
+		OnlyTables:       onlyTables,
+		ReportFile:       verifyReportFile,
+		Context:          cancelOnSignal(),
+		Deadline:         verifyDeadline,
+		Timeouts:         sqlUtil.Timeouts{Statement: verifyStatementTimeout},
+	})
+	if err != nil {
+		exitWithFailure(err)
+	}
+}
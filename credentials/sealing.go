@@ -0,0 +1,83 @@
+// Package credentials seals the credentials of an export with a key shared by the source and target servers,
+// so their secrets are never written in clear text
+package credentials
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// SealedPrefix starts the sealed values written instead of the secrets
+const SealedPrefix = "iss-sealed:v1:"
+
+// KeySize is the size of the AES-256 keys sealing the credentials, stored hex encoded in key files
+const KeySize = 32
+
+// ReadKeyFile reads the hex encoded key of the file, like one created with 'openssl rand -hex 32'
+func ReadKeyFile(path string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(content)))
+	if err != nil || len(key) != KeySize {
+		return nil, fmt.Errorf("the key of %s is not %d hex encoded bytes", path, KeySize)
+	}
+	return key, nil
+}
+
+// Seal encrypts the value with AES-GCM, returning it as a sealed value
+func Seal(key []byte, value string) (string, error) {
+	aead, err := newAead(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(value), []byte(SealedPrefix))
+	return SealedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// IsSealed checks if the value was sealed by Seal
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, SealedPrefix)
+}
+
+// Unseal decrypts the sealed value, failing when the key is not the one sealing it
+func Unseal(key []byte, value string) (string, error) {
+	if !IsSealed(value) {
+		return "", fmt.Errorf("the value is not sealed")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, SealedPrefix))
+	if err != nil {
+		return "", err
+	}
+	aead, err := newAead(key)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("the sealed value is truncated")
+	}
+	opened, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(SealedPrefix))
+	if err != nil {
+		return "", fmt.Errorf("the sealed value cannot be opened with the key: %v", err)
+	}
+	return string(opened), nil
+}
+
+func newAead(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
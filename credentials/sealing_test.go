@@ -0,0 +1,34 @@
+package credentials
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSealAndUnseal(t *testing.T) {
+	key := []byte(strings.Repeat("k", KeySize))
+	sealed, err := Seal(key, "secret")
+	if err != nil || !IsSealed(sealed) || strings.Contains(sealed, "secret") {
+		t.Fatalf("Unexpected sealed value %s: %v", sealed, err)
+	}
+	if opened, err := Unseal(key, sealed); err != nil || opened != "secret" {
+		t.Errorf("Expected the secret, got %s: %v", opened, err)
+	}
+	if _, err := Unseal([]byte(strings.Repeat("x", KeySize)), sealed); err == nil {
+		t.Error("Sealed value opened with another key")
+	}
+}
+
+func TestReadKeyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "iss.key")
+	os.WriteFile(path, []byte(strings.Repeat("ab", KeySize)+"\n"), 0600)
+	if key, err := ReadKeyFile(path); err != nil || len(key) != KeySize {
+		t.Errorf("Expected a key of %d bytes, got %v: %v", KeySize, key, err)
+	}
+	os.WriteFile(path, []byte("abcd"), 0600)
+	if _, err := ReadKeyFile(path); err == nil {
+		t.Error("Short key accepted")
+	}
+}
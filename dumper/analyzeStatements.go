@@ -0,0 +1,44 @@
+package dumper
+
+import (
+	"bufio"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// tables which received rows since the last ANALYZE statements were written
+var writtenTables = make(map[string]bool)
+
+func recordWrittenTable(tableName string) {
+	writtenTables[tableName] = true
+}
+
+// WriteAnalyzeStatements writes an ANALYZE statement for every table which received rows, so the planner of the
+// target server has statistics for the imported rows without waiting for autovacuum. It must be written after
+// the transaction of the rows is committed.
+func WriteAnalyzeStatements(writer *bufio.Writer) {
+	tableNames := make([]string, 0, len(writtenTables))
+	for tableName := range writtenTables {
+		tableNames = append(tableNames, tableName)
+	}
+	sort.Strings(tableNames)
+	for _, tableName := range tableNames {
+		writer.WriteString(FormatAnalyzeStatement(tableName) + "\n")
+	}
+	writtenTables = make(map[string]bool)
+}
+
+// FormatAnalyzeStatement returns the statement updating the planner statistics of the table
+func FormatAnalyzeStatement(tableName string) string {
+	return fmt.Sprintf("ANALYZE %s;", tableName)
+}
+
+// AnalyzedTable returns the table of an ANALYZE statement written by WriteAnalyzeStatements
+func AnalyzedTable(line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "ANALYZE ") || !strings.HasSuffix(line, ";") {
+		return "", false
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(line, "ANALYZE "), ";"), true
+}
@@ -0,0 +1,223 @@
+package dumper
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+)
+
+// kinds of the anonymized values, the values of a kind share their pseudonyms across all the tables
+const (
+	anonymizedOrg       = "org"
+	anonymizedUser      = "user"
+	anonymizedUserUpper = "user-upper"
+	anonymizedHost      = "host"
+	anonymizedAddress   = "address"
+	anonymizedUrl       = "url"
+	anonymizedEmail     = "email"
+	anonymizedName      = "name"
+	anonymizedText      = "text"
+	// JSON documents keep their structure, their strings are replaced by text pseudonyms
+	anonymizedJson = "json"
+)
+
+// columns replaced by pseudonyms in anonymized exports, in the format 'table.column'
+var anonymizedColumns = map[string]string{
+	"web_customer.name":                  anonymizedOrg,
+	"web_contact.login":                  anonymizedUser,
+	"web_contact.login_uc":               anonymizedUserUpper,
+	"web_user_personal_info.email":       anonymizedEmail,
+	"web_user_personal_info.first_names": anonymizedName,
+	"web_user_personal_info.last_name":   anonymizedName,
+	"rhnserver.name":                     anonymizedHost,
+	"rhnserver.hostname":                 anonymizedHost,
+	"rhnserver.description":              anonymizedText,
+	"rhnserver.info":                     anonymizedText,
+	"rhnserverfqdn.name":                 anonymizedHost,
+	"rhnservernetwork.hostname":          anonymizedHost,
+	"rhnservernetwork.ipaddr":            anonymizedAddress,
+	"rhnservernetwork.ip6addr":           anonymizedAddress,
+	"rhnservernetaddress4.address":       anonymizedAddress,
+	"rhnservernetaddress6.address":       anonymizedAddress,
+	"rhnserverpath.hostname":             anonymizedHost,
+	"suseminioninfo.minion_id":           anonymizedHost,
+	"suseimagestore.uri":                 anonymizedUrl,
+	"rhncontentsource.source_url":        anonymizedUrl,
+	"rhnkickstartabletree.base_path":     anonymizedUrl,
+	"susesaltpillar.pillar":              anonymizedJson,
+}
+
+var anonymize bool
+
+// pseudonyms of the anonymized values, indexed by kind and value
+var pseudonyms = make(map[string]map[string]string)
+
+// SetAnonymize enables replacing host names, user names, IP addresses and organization names by pseudonyms
+func SetAnonymize(enabled bool) {
+	anonymize = enabled
+	pseudonyms = make(map[string]map[string]string)
+}
+
+// AnonymizeValue returns the pseudonym of a value of the column when anonymizing, the value otherwise
+func AnonymizeValue(tableName string, columnName string, value string) string {
+	if !anonymize {
+		return value
+	}
+	kind := anonymizedColumns[tableName+"."+columnName]
+	if len(kind) == 0 {
+		return value
+	}
+	return anonymizedValue(kind, value)
+}
+
+// AnonymizeHost returns the pseudonym of the host name when anonymizing, the host name otherwise
+func AnonymizeHost(host string) string {
+	if !anonymize || len(host) == 0 {
+		return host
+	}
+	return anonymizedValue(anonymizedHost, host)
+}
+
+// AnonymizeDocument returns the decoded JSON document with its strings replaced by pseudonyms when anonymizing.
+// Object keys are kept, so the document keeps its structure.
+func AnonymizeDocument(document interface{}) interface{} {
+	if !anonymize {
+		return document
+	}
+	switch value := document.(type) {
+	case string:
+		return pseudonym(anonymizedText, value)
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			result[key] = AnonymizeDocument(item)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(value))
+		for i, item := range value {
+			result[i] = AnonymizeDocument(item)
+		}
+		return result
+	}
+	return document
+}
+
+// anonymizeField returns the column value of the table replaced by its pseudonym when anonymizing
+func anonymizeField(table schemareader.Table, column sqlUtil.RowDataStructure) sqlUtil.RowDataStructure {
+	if !anonymize || column.Value == nil || column.ColumnType == "SQL" {
+		return column
+	}
+	kind := anonymizedColumns[table.Name+"."+column.ColumnName]
+	if len(kind) == 0 {
+		return column
+	}
+	column.Value = anonymizedValue(kind, formatValue(column.Value))
+	return column
+}
+
+// anonymizeRow returns a copy of the row with the anonymized values replaced by their pseudonyms
+func anonymizeRow(table schemareader.Table, row []sqlUtil.RowDataStructure) []sqlUtil.RowDataStructure {
+	if !anonymize {
+		return row
+	}
+	result := make([]sqlUtil.RowDataStructure, len(row))
+	for i, column := range row {
+		result[i] = anonymizeField(table, column)
+	}
+	return result
+}
+
+func anonymizedValue(kind string, value string) string {
+	switch kind {
+	case anonymizedUserUpper:
+		// login_uc is the upper case login, so it shares the pseudonyms of the logins
+		return strings.ToUpper(pseudonym(anonymizedUser, value))
+	case anonymizedUrl:
+		return anonymizeUrl(value)
+	case anonymizedJson:
+		return anonymizeJson(value)
+	}
+	return pseudonym(kind, value)
+}
+
+// pseudonym returns the pseudonym of the value, the same one for every occurrence of the value
+func pseudonym(kind string, value string) string {
+	kindPseudonyms, ok := pseudonyms[kind]
+	if !ok {
+		kindPseudonyms = make(map[string]string)
+		pseudonyms[kind] = kindPseudonyms
+	}
+	key := value
+	if kind == anonymizedUser || kind == anonymizedHost || kind == anonymizedEmail {
+		key = strings.ToLower(value)
+	}
+	if result, ok := kindPseudonyms[key]; ok {
+		return result
+	}
+	n := len(kindPseudonyms) + 1
+	result := ""
+	switch kind {
+	case anonymizedHost:
+		result = fmt.Sprintf("host-%d.example.invalid", n)
+	case anonymizedEmail:
+		result = fmt.Sprintf("user-%d@example.invalid", n)
+	case anonymizedAddress:
+		if ip := net.ParseIP(value); ip != nil && ip.To4() == nil {
+			result = fmt.Sprintf("fd00::%x", n)
+		} else {
+			result = fmt.Sprintf("10.%d.%d.%d", n>>16&255, n>>8&255, n&255)
+		}
+	default:
+		result = fmt.Sprintf("%s-%d", kind, n)
+	}
+	kindPseudonyms[key] = result
+	return result
+}
+
+// anonymizeUrl replaces the host of the URL, or of the host[:port][/path] location, and removes its user information
+func anonymizeUrl(value string) string {
+	if strings.Contains(value, "://") {
+		parsed, err := url.Parse(value)
+		if err != nil || len(parsed.Hostname()) == 0 {
+			return value
+		}
+		parsed.User = nil
+		host := pseudonym(anonymizedHost, parsed.Hostname())
+		if port := parsed.Port(); len(port) > 0 {
+			host = net.JoinHostPort(host, port)
+		}
+		parsed.Host = host
+		return parsed.String()
+	}
+	if strings.HasPrefix(value, "/") || len(value) == 0 {
+		return value
+	}
+	location := value
+	path := ""
+	if i := strings.Index(value, "/"); i >= 0 {
+		location, path = value[:i], value[i:]
+	}
+	if host, port, err := net.SplitHostPort(location); err == nil {
+		return net.JoinHostPort(pseudonym(anonymizedHost, host), port) + path
+	}
+	return pseudonym(anonymizedHost, location) + path
+}
+
+// anonymizeJson replaces the strings of the JSON document, which is replaced entirely when it cannot be decoded
+func anonymizeJson(value string) string {
+	var document interface{}
+	if err := json.Unmarshal([]byte(value), &document); err != nil {
+		return "{}"
+	}
+	anonymized, err := json.Marshal(AnonymizeDocument(document))
+	if err != nil {
+		return "{}"
+	}
+	return string(anonymized)
+}
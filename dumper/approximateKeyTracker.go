@@ -0,0 +1,81 @@
+package dumper
+
+// Key deduplication modes of the data crawler
+const (
+	// DedupExact remembers every processed key, spilling them to disk above the key memory limit
+	DedupExact = "exact"
+	// DedupApproximate remembers the processed keys in bloom filters, a fraction of the memory of DedupExact
+	DedupApproximate = "approximate"
+)
+
+// rate of the new keys taken for processed ones by all the filters of an approximate tracker
+const approximateDedupErrorRate = 1e-6
+
+// keys of the first filter of an approximate tracker, each new filter holds twice the keys of the previous one
+const approximateDedupInitialKeys = 1024
+
+var dedupMode = DedupExact
+
+// SetDedupMode sets how the processed keys are remembered, DedupExact or DedupApproximate
+func SetDedupMode(mode string) {
+	if mode == DedupApproximate {
+		dedupMode = DedupApproximate
+	} else {
+		dedupMode = DedupExact
+	}
+}
+
+// approximateKeyTracker keeps the keys in a growing list of bloom filters. Each new filter gets half the error
+// rate of the previous one, so the error rate of all the filters stays below approximateDedupErrorRate.
+type approximateKeyTracker struct {
+	filters []*bloomFilter
+	// keys added to the last filter, and the keys it was sized for
+	lastCount    int
+	lastCapacity int
+	count        int
+}
+
+func newApproximateKeyTracker() *approximateKeyTracker {
+	tracker := &approximateKeyTracker{}
+	tracker.addFilter(approximateDedupInitialKeys)
+	return tracker
+}
+
+func (tracker *approximateKeyTracker) addFilter(capacity int) {
+	errorRate := approximateDedupErrorRate / float64(uint64(2)<<uint(len(tracker.filters)))
+	tracker.filters = append(tracker.filters, newBloomFilter(capacity, errorRate))
+	tracker.lastCount = 0
+	tracker.lastCapacity = capacity
+}
+
+func (tracker *approximateKeyTracker) Add(key string) bool {
+	for _, filter := range tracker.filters {
+		if filter.mayContain(key) {
+			return false
+		}
+	}
+	if tracker.lastCount >= tracker.lastCapacity {
+		tracker.addFilter(tracker.lastCapacity * 2)
+	}
+	tracker.filters[len(tracker.filters)-1].add(key)
+	tracker.lastCount++
+	tracker.count++
+	return true
+}
+
+func (tracker *approximateKeyTracker) Len() int {
+	return tracker.count
+}
+
+func (tracker *approximateKeyTracker) Close() {
+	tracker.filters = nil
+}
+
+// sizeBytes returns the memory used by the filters
+func (tracker *approximateKeyTracker) sizeBytes() int64 {
+	size := int64(0)
+	for _, filter := range tracker.filters {
+		size += filter.sizeBytes()
+	}
+	return size
+}
@@ -0,0 +1,47 @@
+package dumper
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestApproximateKeyTracker(t *testing.T) {
+	SetDedupMode(DedupApproximate)
+	defer ResetExportState()
+
+	tracker := newKeyTracker()
+	defer tracker.Close()
+	for i := 0; i < 10000; i++ {
+		tracker.Add(fmt.Sprintf("key-%d", i))
+	}
+	if tracker.Len() < 9990 {
+		t.Errorf("expected almost all the 10000 keys to be new, got %d", tracker.Len())
+	}
+	for i := 0; i < 10000; i++ {
+		if tracker.Add(fmt.Sprintf("key-%d", i)) {
+			t.Fatalf("key-%d should be known", i)
+		}
+	}
+	approximate := tracker.(*approximateKeyTracker)
+	if len(approximate.filters) < 2 {
+		t.Errorf("filters should grow with the keys, got %d", len(approximate.filters))
+	}
+	if approximate.sizeBytes() > 10000*8 {
+		t.Errorf("expected less than 8 bytes per key, got %d bytes", approximate.sizeBytes())
+	}
+}
+
+func TestSetDedupMode(t *testing.T) {
+	defer ResetExportState()
+
+	SetDedupMode(DedupApproximate)
+	if _, ok := newKeyTracker().(*approximateKeyTracker); !ok {
+		t.Error("approximate mode should track keys in bloom filters")
+	}
+	SetDedupMode("")
+	tracker := newKeyTracker()
+	defer tracker.Close()
+	if _, ok := tracker.(*spillingKeyTracker); !ok {
+		t.Error("exact mode should be the default")
+	}
+}
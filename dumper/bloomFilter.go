@@ -0,0 +1,61 @@
+package dumper
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+)
+
+// bloomFilter tells if a key may have been added, with a rate of false positives depending on its size
+type bloomFilter struct {
+	bits   []uint64
+	hashes uint64
+}
+
+// newBloomFilter returns a filter for the expected number of keys, with the expected rate of false positives
+func newBloomFilter(expectedKeys int, falsePositiveRate float64) *bloomFilter {
+	if expectedKeys < 1 {
+		expectedKeys = 1
+	}
+	bitCount := math.Ceil(-float64(expectedKeys) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2))
+	hashes := math.Round(bitCount / float64(expectedKeys) * math.Ln2)
+	if hashes < 1 {
+		hashes = 1
+	}
+	return &bloomFilter{bits: make([]uint64, int(bitCount)/64+1), hashes: uint64(hashes)}
+}
+
+// positions returns the two hashes combined into the bit positions of the key
+func (filter *bloomFilter) positions(key string) (uint64, uint64) {
+	hash := fnv.New128a()
+	hash.Write([]byte(key))
+	sum := hash.Sum(nil)
+	// a step of 0 would give the same position for every hash
+	return binary.BigEndian.Uint64(sum[:8]), binary.BigEndian.Uint64(sum[8:]) | 1
+}
+
+func (filter *bloomFilter) add(key string) {
+	first, second := filter.positions(key)
+	size := uint64(len(filter.bits)) * 64
+	for i := uint64(0); i < filter.hashes; i++ {
+		position := (first + i*second) % size
+		filter.bits[position/64] |= 1 << (position % 64)
+	}
+}
+
+func (filter *bloomFilter) mayContain(key string) bool {
+	first, second := filter.positions(key)
+	size := uint64(len(filter.bits)) * 64
+	for i := uint64(0); i < filter.hashes; i++ {
+		position := (first + i*second) % size
+		if filter.bits[position/64]&(1<<(position%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// sizeBytes returns the memory used by the filter
+func (filter *bloomFilter) sizeBytes() int64 {
+	return int64(len(filter.bits)) * 8
+}
@@ -0,0 +1,104 @@
+package dumper
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+)
+
+// bytea values bigger than this are not escaped in memory, but hex encoded while writing them to the output.
+// Only the encoding is streamed: the values are read whole with their row, so each row still has to fit in memory.
+const streamedByteaThreshold = 64 * 1024
+
+// size of the chunks used to hex encode streamed bytea values
+const streamedByteaChunkSize = 32 * 1024
+
+// LargeObjectType is the column type of the large object contents, created as new large objects on import
+const LargeObjectType = "LARGE_OBJECT"
+
+// streamedByteaMarker starts the markers of the streamed values in the statement texts, followed by the index of
+// the value. PostgreSQL text types don't allow NUL characters, and the random part is unknown to the exported data.
+var streamedByteaMarker = newStreamedByteaMarker()
+
+func newStreamedByteaMarker() string {
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	return "\x00bytea:" + hex.EncodeToString(nonce) + ":"
+}
+
+// exportStatement is a statement written to the output, whose large bytea values are encoded when writing it
+type exportStatement struct {
+	text string
+	// values replaced by markers in the text, by index
+	streamed [][]byte
+}
+
+// streamedFields collects the large bytea values of a statement while its text is formatted
+type streamedFields struct {
+	values [][]byte
+}
+
+// formatExportField formats a field for a statement written to the output.
+// Large bytea values are replaced by a marker which is expanded when writing the statement.
+func (fields *streamedFields) formatExportField(col sqlUtil.RowDataStructure) string {
+	if col.Value == nil || (col.ColumnType != "BYTEA" && col.ColumnType != LargeObjectType) {
+		return formatField(col)
+	}
+	value := col.Value.([]byte)
+	if len(value) <= streamedByteaThreshold {
+		return formatField(col)
+	}
+	marker := fmt.Sprintf("%s%d\x00", streamedByteaMarker, len(fields.values))
+	fields.values = append(fields.values, value)
+	if col.ColumnType == LargeObjectType {
+		return formatLargeObject(marker)
+	}
+	return marker
+}
+
+// statement returns the statement of the text formatted with the fields
+func (fields *streamedFields) statement(text string) exportStatement {
+	return exportStatement{text: text, streamed: fields.values}
+}
+
+// formatLargeObject formats the creation of a large object with the bytea literal as content
+func formatLargeObject(content string) string {
+	return fmt.Sprintf("(SELECT lo_from_bytea(0, %s))", content)
+}
+
+// writeStatement writes the statement followed by a new line, encoding the large bytea values it refers to
+// into the writer
+func writeStatement(writer *bufio.Writer, statement exportStatement) {
+	text := statement.text
+	for len(statement.streamed) > 0 {
+		start := strings.Index(text, streamedByteaMarker)
+		if start < 0 {
+			break
+		}
+		end := strings.Index(text[start+len(streamedByteaMarker):], "\x00") + start + len(streamedByteaMarker)
+		var index int
+		fmt.Sscanf(text[start+len(streamedByteaMarker):end], "%d", &index)
+		writer.WriteString(text[:start])
+		writeBytea(writer, statement.streamed[index])
+		text = text[end+1:]
+	}
+	writer.WriteString(text + "\n")
+}
+
+// writeBytea writes a bytea literal in hex format chunk by chunk
+func writeBytea(writer *bufio.Writer, value []byte) {
+	writer.WriteString(`'\x`)
+	encoder := hex.NewEncoder(writer)
+	for start := 0; start < len(value); start += streamedByteaChunkSize {
+		end := start + streamedByteaChunkSize
+		if end > len(value) {
+			end = len(value)
+		}
+		encoder.Write(value[start:end])
+	}
+	writer.WriteString(`'::bytea`)
+}
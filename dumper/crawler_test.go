@@ -1,7 +1,9 @@
 package dumper
 
 import (
+	"fmt"
 	"reflect"
+	"sort"
 	"testing"
 
 	"github.com/uyuni-project/inter-server-sync/schemareader"
@@ -37,11 +39,17 @@ func TestShouldCreateDataDumper(t *testing.T) {
 
 	// the data repository expect these statements in the exact same order
 	testCase.repo.Expect("SELECT * FROM root WHERE CUSTOM ;", testCase.schemaMetadata["root"].Columns, 1)
+	testCase.repo.ExpectPrepare("SELECT id, v35_fk_id, v36_fk_id FROM v31 WHERE id = $1;")
 	testCase.repo.Expect("SELECT id, v35_fk_id, v36_fk_id FROM v31 WHERE id = $1;", testCase.schemaMetadata["v31"].Columns, 1)
+	testCase.repo.ExpectPrepare("SELECT id, v33_fk_id FROM v32 WHERE id = $1;")
 	testCase.repo.Expect("SELECT id, v33_fk_id FROM v32 WHERE id = $1;", testCase.schemaMetadata["v32"].Columns, 1)
+	testCase.repo.ExpectPrepare("SELECT id, v34_fk_id FROM v33 WHERE id = $1;")
 	testCase.repo.Expect("SELECT id, v34_fk_id FROM v33 WHERE id = $1;", testCase.schemaMetadata["v33"].Columns, 1)
+	testCase.repo.ExpectPrepare("SELECT id, v35_fk_id, v36_fk_id FROM v34 WHERE id = $1;")
 	testCase.repo.Expect("SELECT id, v35_fk_id, v36_fk_id FROM v34 WHERE id = $1;", testCase.schemaMetadata["v34"].Columns, 1)
+	testCase.repo.ExpectPrepare("SELECT id, v34_fk_id FROM v35 WHERE id = $1;")
 	testCase.repo.Expect("SELECT id, v34_fk_id FROM v35 WHERE id = $1;", testCase.schemaMetadata["v35"].Columns, 1)
+	testCase.repo.ExpectPrepare("SELECT id FROM v36 WHERE id = $1;")
 	testCase.repo.Expect("SELECT id FROM v36 WHERE id = $1;", testCase.schemaMetadata["v36"].Columns, 1)
 
 	testCase.repo.Expect("SELECT id, v34_fk_id FROM v35 WHERE id = $1;", testCase.schemaMetadata["v35"].Columns, 1)
@@ -227,3 +235,135 @@ func TestShouldNotFollowReferencedLinkingTable(t *testing.T) {
 		t.Errorf("Should not follow the referencedTable if it is a linking table but also is referenced by others")
 	}
 }
+
+func TestFormatRowsFilter(t *testing.T) {
+	// Arrange
+	graph := TablesGraph{
+		"root": []string{"v41"},
+		"v41":  []string{},
+	}
+	schemaMetadata, _ := initializeMetaDataGraph(graph, "root")
+	filtered := schemaMetadata["v41"]
+	filtered.WhereFilter = "id > 10"
+	schemaMetadata["v41"] = filtered
+
+	// Act
+	ownFilter := formatRowsFilter(schemaMetadata, schemaMetadata["v41"])
+	dependentFilter := formatRowsFilter(schemaMetadata, schemaMetadata["root"])
+
+	// Assert
+	expectedOwn := []string{"(id > 10)"}
+	if !reflect.DeepEqual(ownFilter, expectedOwn) {
+		t.Errorf("Expected %s, got %s", expectedOwn, ownFilter)
+	}
+	expectedDependent := []string{"(v41_fk_id IS NULL OR (v41_fk_id) IN (SELECT id FROM v41 WHERE id > 10))"}
+	if !reflect.DeepEqual(dependentFilter, expectedDependent) {
+		t.Errorf("Expected %s, got %s", expectedDependent, dependentFilter)
+	}
+}
+
+func TestShouldPrune(t *testing.T) {
+	// Arrange
+	options := CrawlerOptions{MaxDepth: 2, PruneTables: []string{"v41"}}
+
+	// Act
+	pruneReason, pruneTable := shouldPrune(options, []string{"root"}, "v41")
+	depthReason, pruneDepth := shouldPrune(options, []string{"root", "v11", "v21"}, "v31")
+	_, pruneAllowed := shouldPrune(options, []string{"root", "v11"}, "v21")
+
+	// Assert
+	if !pruneTable || pruneReason != "prune-at" {
+		t.Errorf("Expected v41 to be pruned by prune-at, got %t %s", pruneTable, pruneReason)
+	}
+	if !pruneDepth || depthReason != "max-depth 2" {
+		t.Errorf("Expected v31 to be pruned by max-depth, got %t %s", pruneDepth, depthReason)
+	}
+	if pruneAllowed {
+		t.Errorf("Should not prune v21 within the maximum depth")
+	}
+}
+
+func TestFormatStartingDateFilter(t *testing.T) {
+	// Act
+	errataFilter := formatStartingDateFilter("rhnchannelerrata", 2)
+	packageFilter := formatStartingDateFilter("rhnchannelpackage", 1)
+
+	// Assert
+	expectedErrata := "(modified >= $2::timestamp OR errata_id IN (SELECT id FROM rhnerrata WHERE modified >= $2::timestamp))"
+	if errataFilter != expectedErrata {
+		t.Errorf("Expected %s, got %s", expectedErrata, errataFilter)
+	}
+	if packageFilter != "modified >= $1::timestamp" {
+		t.Errorf("Expected modified >= $1::timestamp, got %s", packageFilter)
+	}
+}
+
+func TestShouldFollowAutoinstallContents(t *testing.T) {
+
+	// Arrange
+	contents := map[string][]string{
+		"rhnkickstartabletree": {"rhnkstreefile"},
+		"rhnksdata":            {"rhnkickstartdefaults", "rhnkickstartcommand", "rhnkickstartscript", "rhnkickstartpackage", "rhnkickstartchildchannel"},
+	}
+
+	for parent, children := range contents {
+		for _, child := range children {
+			testCase := followLinkTestCase{
+				path:         []string{parent},
+				currentTable: schemareader.Table{Name: parent},
+				referencedTable: schemareader.Table{
+					Name:         child,
+					References:   []schemareader.Reference{{TableName: parent}},
+					ReferencedBy: []schemareader.Reference{{TableName: "other"}},
+				},
+			}
+
+			// Act
+			shouldFollow := shouldFollowReferenceToLink(
+				testCase.path,
+				testCase.currentTable,
+				testCase.referencedTable,
+			)
+
+			// Assert
+			if !shouldFollow {
+				t.Errorf("Should follow from %s to its contents in %s", parent, child)
+			}
+		}
+	}
+}
+
+func TestShouldCrawlAutoinstallProfile(t *testing.T) {
+
+	// Arrange
+	graph := TablesGraph{
+		"rhnksdata":           []string{"rhnkickstartabletree"},
+		"rhnkickstartcommand": []string{"rhnksdata"},
+		"rhnkickstartscript":  []string{"rhnksdata"},
+	}
+	schemaMetadata, _ := createMetaDataGraph(graph)
+	// the contents of the profile are read in the order of the references
+	profile := schemaMetadata["rhnksdata"]
+	sort.Slice(profile.ReferencedBy, func(i, j int) bool {
+		return profile.ReferencedBy[i].TableName < profile.ReferencedBy[j].TableName
+	})
+	repo := tests.CreateDataRepository()
+	repo.Expect("SELECT * FROM rhnksdata WHERE CUSTOM ;", profile.Columns, 1)
+	for _, tableName := range []string{"rhnkickstartcommand", "rhnkickstartscript"} {
+		query := fmt.Sprintf("SELECT id, rhnksdata_fk_id FROM %s WHERE rhnksdata_fk_id = $1;", tableName)
+		repo.ExpectPrepare(query)
+		repo.Expect(query, schemaMetadata[tableName].Columns, 1)
+	}
+	repo.ExpectPrepare("SELECT id FROM rhnkickstartabletree WHERE id = $1;")
+	repo.Expect("SELECT id FROM rhnkickstartabletree WHERE id = $1;", schemaMetadata["rhnkickstartabletree"].Columns, 1)
+
+	// Act
+	dataDumper := DataCrawler(repo.DB, schemaMetadata, schemaMetadata["rhnksdata"], "CUSTOM", "")
+
+	// Assert
+	for _, tableName := range []string{"rhnksdata", "rhnkickstartabletree", "rhnkickstartcommand", "rhnkickstartscript"} {
+		if len(dataDumper.TableData[tableName].Keys) != 1 {
+			t.Errorf("Expected one row of %s in the profile export, got %v", tableName, dataDumper.TableData[tableName].Keys)
+		}
+	}
+}
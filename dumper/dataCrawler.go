@@ -10,6 +10,8 @@ import (
 
 	"github.com/uyuni-project/inter-server-sync/schemareader"
 	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+	"github.com/uyuni-project/inter-server-sync/tracing"
+	"github.com/uyuni-project/inter-server-sync/utils"
 )
 
 // DataCrawler will go through all the elements in the initialDataSet an extract related data
@@ -17,8 +19,24 @@ import (
 // The result will be a structure containing ID of each row which should be exported per table
 func DataCrawler(db *sql.DB, schemaMetadata map[string]schemareader.Table, startTable schemareader.Table,
 	startQueryFilter string, startingDate string) DataDumper {
+	return DataCrawlerWithOptions(db, schemaMetadata, startTable, startQueryFilter, CrawlerOptions{StartingDate: startingDate})
+}
 
-	result := DataDumper{make(map[string]TableDump, 0), make(map[string]bool)}
+// DataCrawlerWithOptions is a DataCrawler which can limit the references followed
+func DataCrawlerWithOptions(db *sql.DB, schemaMetadata map[string]schemareader.Table, startTable schemareader.Table,
+	startQueryFilter string, options CrawlerOptions) DataDumper {
+	span := tracing.StartSpan("graph traversal")
+	span.SetAttribute("table", startTable.Name)
+	defer span.End()
+
+	result := DataDumper{make(map[string]TableDump, 0), make(map[string]bool), make(map[string]string)}
+	// keys of the processed rows, indexed by table
+	processedKeys := make(map[string]KeyTracker)
+	defer func() {
+		for _, keys := range processedKeys {
+			keys.Close()
+		}
+	}()
 
 	itemsToProcess := initialDataSet(db, startTable, startQueryFilter)
 
@@ -34,9 +52,9 @@ func DataCrawler(db *sql.DB, schemaMetadata map[string]schemareader.Table, start
 				maxSize := 0
 				table := ""
 				for key, value := range result.TableData {
-					keysSize = keysSize + len(value.KeyMap)
-					if len(value.KeyMap) > maxSize {
-						maxSize = len(value.KeyMap)
+					keysSize = keysSize + len(value.Keys)
+					if len(value.Keys) > maxSize {
+						maxSize = len(value.Keys)
 						table = key
 					}
 				}
@@ -62,16 +80,18 @@ IterateItemsLoop:
 		keyColumnData := extractRowKeyData(table, itemToProcess)
 		keyIdToMap := generateKeyIdToMap(keyColumnData)
 
+		tableKeys, ok := processedKeys[table.Name]
+		if !ok {
+			tableKeys = newKeyTracker()
+			processedKeys[table.Name] = tableKeys
+		}
+		if !tableKeys.Add(keyIdToMap) {
+			continue IterateItemsLoop
+		}
 		resultTableValues, resultExists := result.TableData[table.Name]
-		if resultExists {
-			_, rowProcessed := resultTableValues.KeyMap[keyIdToMap]
-			if rowProcessed {
-				continue IterateItemsLoop
-			}
-		} else {
-			resultTableValues = TableDump{TableName: table.Name, KeyMap: make(map[string]bool), Keys: make([]TableKey, 0)}
+		if !resultExists {
+			resultTableValues = TableDump{TableName: table.Name, Keys: make([]TableKey, 0)}
 		}
-		resultTableValues.KeyMap[keyIdToMap] = true
 		resultTableValues.Keys = append(resultTableValues.Keys, keyColumnData)
 
 		result.TableData[table.Name] = resultTableValues
@@ -80,11 +100,18 @@ IterateItemsLoop:
 			result.Paths[strings.Join(itemToProcess.path, ",")] = true
 		}
 
-		newItems := append(followReferencesTo(db, schemaMetadata, table, itemToProcess, startingDate),
-			followReferencesFrom(db, schemaMetadata, table, itemToProcess, startingDate)...)
+		if itemToProcess.levels == 0 {
+			continue IterateItemsLoop
+		}
+		newItems := append(followReferencesTo(db, schemaMetadata, table, itemToProcess, options, result.Pruned),
+			followReferencesFrom(db, schemaMetadata, table, itemToProcess, options, result.Pruned)...)
 		itemsToProcess = append(itemsToProcess, newItems...)
 
 	}
+	// the rows of the tables are discovered together, the span counts them by table
+	for tableName, tableData := range result.TableData {
+		span.SetAttribute("rows."+tableName, len(tableData.Keys))
+	}
 	return result
 }
 
@@ -93,11 +120,18 @@ func initialDataSet(db *sql.DB, startTable schemareader.Table, whereFilter strin
 	if len(whereFilter) > 0 {
 		whereClause = fmt.Sprintf("WHERE %s", whereFilter)
 	}
+	if len(startTable.WhereFilter) > 0 {
+		if len(whereClause) > 0 {
+			whereClause = fmt.Sprintf("%s AND (%s)", whereClause, startTable.WhereFilter)
+		} else {
+			whereClause = fmt.Sprintf("WHERE (%s)", startTable.WhereFilter)
+		}
+	}
 	sql := fmt.Sprintf(`SELECT * FROM %s %s ;`, startTable.Name, whereClause)
 	rows := sqlUtil.ExecuteQueryWithResults(db, sql)
 	initialDataSet := make([]processItem, 0)
 	for _, row := range rows {
-		initialDataSet = append(initialDataSet, processItem{startTable.Name, row, []string{startTable.Name}})
+		initialDataSet = append(initialDataSet, processItem{startTable.Name, row, []string{startTable.Name}, -1})
 	}
 	return initialDataSet
 }
@@ -114,7 +148,7 @@ func generateKeyIdToMap(data TableKey) string {
 func extractRowKeyData(table schemareader.Table, itemToProcess processItem) TableKey {
 	keys := make([]RowKey, 0)
 	if len(table.PKColumns) > 0 {
-		for pkColumn, _ := range table.PKColumns {
+		for _, pkColumn := range sortedColumnSet(table.PKColumns) {
 			keys = append(keys, RowKey{pkColumn, formatField(itemToProcess.row[table.ColumnIndexes[pkColumn]])})
 		}
 	} else {
@@ -131,7 +165,31 @@ func shouldApplyStartingDate(startingDate string, tableName string) bool {
 			tableName == "susemddata" || tableName == "rhnerratafilechannel")
 }
 
-func followReferencesFrom(db *sql.DB, schemaMetadata map[string]schemareader.Table, table schemareader.Table, row processItem, startingDate string) []processItem {
+// formatStartingDateFilter returns the condition selecting the rows modified after the starting date parameter.
+// Errata of the channel modified since, like retracted patches, are exported even if their channel link is older.
+func formatStartingDateFilter(tableName string, parameter int) string {
+	if tableName == "rhnchannelerrata" {
+		return fmt.Sprintf("(modified >= $%d::timestamp OR errata_id IN (SELECT id FROM rhnerrata WHERE modified >= $%d::timestamp))",
+			parameter, parameter)
+	}
+	return fmt.Sprintf("%s >= $%d::timestamp", "modified", parameter)
+}
+
+// shouldPrune checks if the crawler must stop before following the path into the table
+func shouldPrune(options CrawlerOptions, path []string, tableName string) (string, bool) {
+	if utils.Contains(options.PruneTables, tableName) {
+		return "prune-at", true
+	}
+	// the path contains the start table, which is at depth 0
+	if options.MaxDepth > 0 && len(path) > options.MaxDepth {
+		return fmt.Sprintf("max-depth %d", options.MaxDepth), true
+	}
+	return "", false
+}
+
+func followReferencesFrom(db *sql.DB, schemaMetadata map[string]schemareader.Table, table schemareader.Table, row processItem,
+	options CrawlerOptions, pruned map[string]string) []processItem {
+	startingDate := options.StartingDate
 	result := make([]processItem, 0)
 
 	for _, reference := range table.References {
@@ -149,10 +207,19 @@ func followReferencesFrom(db *sql.DB, schemaMetadata map[string]schemareader.Tab
 		if targetTableVisited {
 			continue
 		}
+		if reason, prune := shouldPrune(options, row.path, foreignTable.Name); prune {
+			pruned[foreignTable.Name] = reason
+			continue
+		}
+		levels, follow := followLevels(options, row, table.Name, reference.ColumnMapping, foreignTable.Name, pruned, foreignTable.Name)
+		if !follow {
+			continue
+		}
 
 		whereParameters := make([]string, 0)
 		scanParameters := make([]interface{}, 0)
-		for localColumn, foreignColumn := range reference.ColumnMapping {
+		for _, localColumn := range sortedColumnMapping(reference.ColumnMapping) {
+			foreignColumn := reference.ColumnMapping[localColumn]
 			whereParameters = append(whereParameters, fmt.Sprintf("%s = $%d", foreignColumn, len(whereParameters)+1))
 			scanParameters = append(scanParameters, row.row[table.ColumnIndexes[localColumn]].Value)
 		}
@@ -162,23 +229,51 @@ func followReferencesFrom(db *sql.DB, schemaMetadata map[string]schemareader.Tab
 			scanParameters = append(scanParameters, startingDate)
 		}
 
+		whereParameters = append(whereParameters, formatRowsFilter(schemaMetadata, foreignTable)...)
+
 		formattedColumns := strings.Join(foreignTable.Columns, ", ")
 		formattedWhereParameters := strings.Join(whereParameters, " and ")
 		sql := fmt.Sprintf(`SELECT %s FROM %s WHERE %s;`, formattedColumns, reference.TableName, formattedWhereParameters)
-		followRows := sqlUtil.ExecuteQueryWithResults(db, sql, scanParameters...)
+		followRows := sqlUtil.ExecutePreparedQueryWithResults(db, sql, scanParameters...)
 
 		if len(followRows) > 0 {
 			for _, followRow := range followRows {
 				newPath := make([]string, 0)
 				newPath = append(newPath, row.path...)
 				newPath = append(newPath, foreignTable.Name)
-				result = append(result, processItem{foreignTable.Name, followRow, newPath})
+				result = append(result, processItem{foreignTable.Name, followRow, newPath, levels})
 			}
 		}
 	}
 	return result
 }
 
+// formatRowsFilter returns the conditions restricting the rows of a table to the ones allowed by the user filters:
+// rows matching the table own filter, and referencing only rows which match the filters of the referenced tables
+func formatRowsFilter(schemaMetadata map[string]schemareader.Table, table schemareader.Table) []string {
+	result := make([]string, 0)
+	if len(table.WhereFilter) > 0 {
+		result = append(result, fmt.Sprintf("(%s)", table.WhereFilter))
+	}
+	for _, reference := range table.References {
+		foreignTable, ok := schemaMetadata[reference.TableName]
+		if !ok || len(foreignTable.WhereFilter) == 0 || strings.Compare(foreignTable.Name, table.Name) == 0 {
+			continue
+		}
+		localColumns := sortedColumnMapping(reference.ColumnMapping)
+		foreignColumns := make([]string, 0)
+		nullChecks := make([]string, 0)
+		for _, localColumn := range localColumns {
+			foreignColumns = append(foreignColumns, reference.ColumnMapping[localColumn])
+			nullChecks = append(nullChecks, fmt.Sprintf("%s IS NULL", localColumn))
+		}
+		result = append(result, fmt.Sprintf("(%s OR (%s) IN (SELECT %s FROM %s WHERE %s))",
+			strings.Join(nullChecks, " OR "), strings.Join(localColumns, ", "),
+			strings.Join(foreignColumns, ", "), foreignTable.Name, foreignTable.WhereFilter))
+	}
+	return result
+}
+
 func shouldFollowToLinkPreOrder(path []string, currentTable schemareader.Table, referencedTable schemareader.Table) bool {
 	forbiddenNavigations := map[string][]string{
 		"rhnconfigfile": {"rhnconfigrevision"},
@@ -211,6 +306,11 @@ func shouldFollowReferenceToLink(path []string, currentTable schemareader.Table,
 		"rhnerrata":        {"rhnerratafile"},
 		"rhnconfigchannel": {"rhnconfigfile"},
 		"rhnconfigfile":    {"rhnconfigrevision"},
+		"web_contact":      {"web_user_personal_info", "rhnuserinfo", "rhnusergroupmembers"},
+		// autoinstallation contents which are not prefixed by the name of their distribution or profile
+		"rhnkickstartabletree": {"rhnkstreefile"},
+		"rhnksdata": {"rhnkickstartdefaults", "rhnkickstartcommand", "rhnkickstartscript", "rhnkickstartpackage",
+			"rhnkickstartchildchannel"},
 	}
 
 	if tableNavigation, ok := forcedNavigations[currentTable.Name]; ok {
@@ -242,7 +342,9 @@ func shouldFollowReferenceToLink(path []string, currentTable schemareader.Table,
 	return false
 }
 
-func followReferencesTo(db *sql.DB, schemaMetadata map[string]schemareader.Table, table schemareader.Table, row processItem, startingDate string) []processItem {
+func followReferencesTo(db *sql.DB, schemaMetadata map[string]schemareader.Table, table schemareader.Table, row processItem,
+	options CrawlerOptions, pruned map[string]string) []processItem {
+	startingDate := options.StartingDate
 	result := make([]processItem, 0)
 
 	for _, reference := range table.ReferencedBy {
@@ -253,30 +355,41 @@ func followReferencesTo(db *sql.DB, schemaMetadata map[string]schemareader.Table
 		if !shouldFollowReferenceToLink(row.path, table, referencedTable) {
 			continue
 		}
+		if reason, prune := shouldPrune(options, row.path, referencedTable.Name); prune {
+			pruned[referencedTable.Name] = reason
+			continue
+		}
+		levels, follow := followLevels(options, row, referencedTable.Name, reference.ColumnMapping, table.Name, pruned, referencedTable.Name)
+		if !follow {
+			continue
+		}
 
 		whereParameters := make([]string, 0)
 		scanParameters := make([]interface{}, 0)
-		for localColumn, foreignColumn := range reference.ColumnMapping {
+		for _, localColumn := range sortedColumnMapping(reference.ColumnMapping) {
+			foreignColumn := reference.ColumnMapping[localColumn]
 			whereParameters = append(whereParameters, fmt.Sprintf("%s = $%d", localColumn, len(whereParameters)+1))
 			scanParameters = append(scanParameters, row.row[table.ColumnIndexes[foreignColumn]].Value)
 		}
 
 		if shouldApplyStartingDate(startingDate, referencedTable.Name) {
-			whereParameters = append(whereParameters, fmt.Sprintf("%s >= $%d::timestamp", "modified", len(whereParameters)+1))
+			whereParameters = append(whereParameters, formatStartingDateFilter(referencedTable.Name, len(whereParameters)+1))
 			scanParameters = append(scanParameters, startingDate)
 		}
 
+		whereParameters = append(whereParameters, formatRowsFilter(schemaMetadata, referencedTable)...)
+
 		formattedColumns := strings.Join(referencedTable.Columns, ", ")
 		formattedWhereParameters := strings.Join(whereParameters, " and ")
 		sql := fmt.Sprintf(`SELECT %s FROM %s WHERE %s;`, formattedColumns, reference.TableName, formattedWhereParameters)
-		followRows := sqlUtil.ExecuteQueryWithResults(db, sql, scanParameters...)
+		followRows := sqlUtil.ExecutePreparedQueryWithResults(db, sql, scanParameters...)
 
 		if len(followRows) > 0 {
 			for _, followRow := range followRows {
 				newPath := make([]string, 0)
 				newPath = append(newPath, row.path...)
 				newPath = append(newPath, referencedTable.Name)
-				result = append(result, processItem{referencedTable.Name, followRow, newPath})
+				result = append(result, processItem{referencedTable.Name, followRow, newPath, levels})
 			}
 		}
 	}
@@ -3,8 +3,12 @@ package dumper
 import (
 	"bufio"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"math"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -13,6 +17,7 @@ import (
 
 	"github.com/lib/pq"
 	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/tracing"
 	"github.com/uyuni-project/inter-server-sync/utils"
 )
 
@@ -20,6 +25,19 @@ var cache = make(map[string]string)
 
 var referrencesCall = make(map[string]int)
 
+// number of exported rows not satisfying the CHECK constraints of their table
+var checkConstraintViolations = 0
+
+// CheckConstraintViolations returns how many exported rows violate a CHECK constraint of the target table
+func CheckConstraintViolations() int {
+	return checkConstraintViolations
+}
+
+// limits of the modified rows validated against the CHECK constraints of their table with one query: the number
+// of rows, and the length of their formatted values, which large values like pillars reach first
+const checkConstraintBatchRows = 500
+const checkConstraintBatchBytes = 4 * 1024 * 1024
+
 func PrintTableDataOrdered(db *sql.DB, writer *bufio.Writer, schemaMetadata map[string]schemareader.Table,
 	startingTable schemareader.Table, data DataDumper, options PrintSqlOptions) {
 
@@ -105,7 +123,14 @@ func exportTablesData(db *sql.DB, writer *bufio.Writer, schemaMetadata map[strin
 		// export current table data
 		log.Debug().Msg(fmt.Sprintf("Writing data for table [%d/%d] %s", tableCount, len(tablesOrdered), table.Name))
 		tableCount++
-		totalExportedRecords += exportCurrentTableData(db, writer, schemaMetadata, table, data, options)
+		span := tracing.StartSpan("export table")
+		span.SetAttribute("table", table.Name)
+		start := time.Now()
+		tableRecords := exportCurrentTableData(db, writer, schemaMetadata, table, data, options)
+		recordTableWrite(table.Name, tableRecords, time.Since(start))
+		span.SetAttribute("rows", tableRecords)
+		span.End()
+		totalExportedRecords += tableRecords
 	}
 	// post-processing callback
 	for _, table := range tablesOrdered {
@@ -131,6 +156,7 @@ func exportCurrentTableData(db *sql.DB, writer *bufio.Writer, schemaMetadata map
 	totalExportedRecords := 0
 	tableData, dataOK := data.TableData[table.Name]
 	if dataOK {
+		sortTableKeys(tableData.Keys)
 		exportPoint := 0
 		batch := 100
 		for len(tableData.Keys) > exportPoint {
@@ -140,12 +166,16 @@ func exportCurrentTableData(db *sql.DB, writer *bufio.Writer, schemaMetadata map
 			}
 			rows := GetRowsFromKeys(db, table, tableData.Keys[exportPoint:upperLimit])
 			totalExportedRecords = totalExportedRecords + len(rows)
+			checks := newCheckConstraintBatch(db, table)
 			for _, rowValue := range rows {
-				rowToInsert := generateRowInsertStatement(db, rowValue, table, schemaMetadata, options.OnlyIfParentExistsTables)
-				writer.WriteString(rowToInsert + "\n")
+				writeRowInsertStatement(db, writer, rowValue, table, schemaMetadata, options.OnlyIfParentExistsTables, checks)
 			}
+			checks.validate()
 			exportPoint = upperLimit
 		}
+		if idStrategy == IdsPreserveSource && hasGeneratedId(table) && totalExportedRecords > 0 {
+			writer.WriteString(formatSequenceAdvance(table) + "\n")
+		}
 	}
 	return totalExportedRecords
 }
@@ -172,13 +202,17 @@ func getTablesExportOrder(schemaMetadata map[string]schemareader.Table,
 	}
 
 	// follow reference by
-	tableReferencesBy := make([]schemareader.Table, 0)
+	referencingTables := make([]schemareader.Table, 0)
 	for _, reference := range table.ReferencedBy {
 		tableReference, ok := schemaMetadata[reference.TableName]
 		if ok && tableReference.Export && shouldFollowReferenceToLink(path, table, tableReference) {
-			tableReferencesBy = append(tableReferencesBy, getTablesExportOrder(schemaMetadata, tableReference, processedTables, path)...)
+			referencingTables = append(referencingTables, tableReference)
 		}
 	}
+	tableReferencesBy := make([]schemareader.Table, 0)
+	for _, tableReference := range orderIndependentTables(referencingTables) {
+		tableReferencesBy = append(tableReferencesBy, getTablesExportOrder(schemaMetadata, tableReference, processedTables, path)...)
+	}
 
 	return append(append(tableReferences, table), tableReferencesBy...)
 }
@@ -215,13 +249,38 @@ func GetRowsFromKeys(db *sql.DB, table schemareader.Table, keys []TableKey) [][]
 		where_clause = fmt.Sprintf("WHERE (%s) IN (%s)", strings.Join(columnsFilter, ", "), strings.Join(values, ","))
 	}
 
-	sql := fmt.Sprintf(`SELECT %s FROM %s %s;`, formattedColumns, table.Name, where_clause)
+	sql := fmt.Sprintf(`SELECT %s FROM %s %s%s;`, formattedColumns, table.Name, where_clause, formatOrderBy(table))
+	// batches of keys are small, starting psql for them would cost more than reading them with the driver
 	return sqlUtil.ExecuteQueryWithResults(db, sql)
 }
 
-func filterRowData(value []sqlUtil.RowDataStructure, table schemareader.Table) []sqlUtil.RowDataStructure {
+// formatOrderBy orders rows by the main unique index, or the primary key when there is none,
+// so exports of the same data are identical
+func formatOrderBy(table schemareader.Table) string {
+	orderColumns := table.UniqueIndexes[table.MainUniqueIndexName].Columns
+	if len(orderColumns) == 0 {
+		orderColumns = sortedColumnSet(table.PKColumns)
+	}
+	if len(orderColumns) == 0 {
+		return ""
+	}
+	return " ORDER BY " + strings.Join(orderColumns, ", ")
+}
+
+// sortTableKeys sorts the keys in a stable order which does not depend on the crawling order
+func sortTableKeys(keys []TableKey) {
+	sort.SliceStable(keys, func(i, j int) bool {
+		return generateKeyIdToMap(keys[i]) < generateKeyIdToMap(keys[j])
+	})
+}
+
+// filterRowData applies the row callback of the table, adding the modified row to the CHECK constraint checks,
+// and leaves out the columns which are not exported
+func filterRowData(db *sql.DB, value []sqlUtil.RowDataStructure, table schemareader.Table, checks *checkConstraintBatch) []sqlUtil.RowDataStructure {
 	if table.RowModCallback != nil {
 		value = table.RowModCallback(value, table)
+		// rows read from the database already satisfy its constraints, only modified rows need to be checked
+		checks.add(value)
 	}
 	if table.UnexportColumns != nil {
 		returnValues := make([]sqlUtil.RowDataStructure, 0)
@@ -236,16 +295,104 @@ func filterRowData(value []sqlUtil.RowDataStructure, table schemareader.Table) [
 	return value
 }
 
+// checkConstraintBatch collects the modified rows of a table, validating them against its CHECK constraints once
+// the batch is full and when validate is called. A nil batch checks nothing.
+type checkConstraintBatch struct {
+	db    *sql.DB
+	table schemareader.Table
+	// columns and formatted values of the collected rows, and the length of the values
+	columns []string
+	rows    [][]string
+	size    int
+}
+
+// newCheckConstraintBatch returns the batch of the table, nil when its rows are exported unchanged or it has no
+// CHECK constraints
+func newCheckConstraintBatch(db *sql.DB, table schemareader.Table) *checkConstraintBatch {
+	if table.RowModCallback == nil || len(table.CheckConstraints) == 0 {
+		return nil
+	}
+	return &checkConstraintBatch{db: db, table: table}
+}
+
+func (batch *checkConstraintBatch) add(row []sqlUtil.RowDataStructure) {
+	if batch == nil || len(row) == 0 {
+		return
+	}
+	if len(batch.rows) == 0 {
+		batch.columns = make([]string, 0, len(row))
+		for _, column := range row {
+			batch.columns = append(batch.columns, column.ColumnName)
+		}
+	}
+	fields := make([]string, 0, len(row))
+	for _, column := range row {
+		field := formatField(column)
+		fields = append(fields, field)
+		batch.size += len(field)
+	}
+	batch.rows = append(batch.rows, fields)
+	if len(batch.rows) >= checkConstraintBatchRows || batch.size >= checkConstraintBatchBytes {
+		batch.validate()
+	}
+}
+
+// validate evaluates the CHECK constraints of the table against the collected rows with one query, and forgets them
+func (batch *checkConstraintBatch) validate() {
+	if batch == nil || len(batch.rows) == 0 {
+		return
+	}
+	rows := batch.rows
+	table := batch.table
+	batch.rows = nil
+	batch.size = 0
+	columns := []string{"exported_row_index"}
+	for _, column := range batch.columns {
+		columns = append(columns, pq.QuoteIdentifier(column))
+	}
+	values := make([]string, 0, len(rows))
+	for i, row := range rows {
+		values = append(values, "("+strconv.Itoa(i)+", "+strings.Join(row, ", ")+")")
+	}
+	constraintNames := make([]string, 0)
+	for name := range table.CheckConstraints {
+		constraintNames = append(constraintNames, name)
+	}
+	sort.Strings(constraintNames)
+	checks := make([]string, 0, len(constraintNames))
+	conditions := make([]string, 0, len(constraintNames))
+	for _, name := range constraintNames {
+		// like the CHECK constraints, NULL results are satisfied
+		checks = append(checks, fmt.Sprintf("(NOT %s) IS TRUE", table.CheckConstraints[name]))
+		conditions = append(conditions, fmt.Sprintf("NOT %s", table.CheckConstraints[name]))
+	}
+	sql := fmt.Sprintf(`SELECT exported_row_index, %s FROM (VALUES %s) AS exported_row (%s) WHERE %s ORDER BY exported_row_index;`,
+		strings.Join(checks, ", "), strings.Join(values, ", "), strings.Join(columns, ", "), strings.Join(conditions, " OR "))
+	for _, violation := range sqlUtil.ExecuteQueryWithResults(batch.db, sql) {
+		index, err := strconv.Atoi(fmt.Sprintf("%v", violation[0].Value))
+		if err != nil || index < 0 || index >= len(rows) {
+			continue
+		}
+		for i, name := range constraintNames {
+			if violated, _ := violation[i+1].Value.(bool); violated {
+				checkConstraintViolations++
+				log.Error().Msgf("Exported row of table %s violates check constraint %s %s: (%s)",
+					table.Name, name, table.CheckConstraints[name], strings.Join(rows[index], ","))
+			}
+		}
+	}
+}
+
 func substituteKeys(db *sql.DB, table schemareader.Table, row []sqlUtil.RowDataStructure, tableMap map[string]schemareader.Table) []sqlUtil.RowDataStructure {
 	values := substitutePrimaryKey(table, row)
 	values = SubstituteForeignKey(db, table, tableMap, values)
-	return values
+	return lookupPrimaryKey(table, values)
 }
 
 func substitutePrimaryKey(table schemareader.Table, row []sqlUtil.RowDataStructure) []sqlUtil.RowDataStructure {
 	rowResult := make([]sqlUtil.RowDataStructure, 0)
 	pkSequence := false
-	if len(table.PKSequence) > 0 {
+	if len(table.PKSequence) > 0 && idStrategy != IdsPreserveSource {
 		pkSequence = true
 	}
 	for _, column := range row {
@@ -260,6 +407,27 @@ func substitutePrimaryKey(table schemareader.Table, row []sqlUtil.RowDataStructu
 	return rowResult
 }
 
+// substituteLargeObjects replaces large object references with their content, so a new large object
+// with its own OID is created on import and referenced by the row instead of the source OID
+func substituteLargeObjects(db *sql.DB, table schemareader.Table, row []sqlUtil.RowDataStructure) []sqlUtil.RowDataStructure {
+	if len(table.LargeObjectColumns) == 0 {
+		return row
+	}
+	for i, column := range row {
+		if !table.LargeObjectColumns[column.ColumnName] || column.Value == nil || column.ColumnType == "SQL" || column.ColumnType == LargeObjectType {
+			continue
+		}
+		content := sqlUtil.ExecutePreparedQueryWithResults(db, `SELECT lo_get($1);`, formatValue(column.Value))
+		if len(content) == 0 || content[0][0].Value == nil {
+			log.Warn().Msgf("Large object %s referenced by table %s not found", formatValue(column.Value), table.Name)
+			continue
+		}
+		row[i].ColumnType = LargeObjectType
+		row[i].Value = content[0][0].Value
+	}
+	return row
+}
+
 func SubstituteForeignKey(db *sql.DB, table schemareader.Table, tables map[string]schemareader.Table, row []sqlUtil.RowDataStructure) []sqlUtil.RowDataStructure {
 	for _, reference := range table.References {
 		row = substituteForeignKeyReference(db, table, tables, reference, row)
@@ -277,7 +445,8 @@ func substituteForeignKeyReference(db *sql.DB, table schemareader.Table,
 
 	whereParameters := make([]string, 0)
 	scanParameters := make([]interface{}, 0)
-	for localColumn, foreignColumn := range reference.ColumnMapping {
+	for _, localColumn := range sortedColumnMapping(reference.ColumnMapping) {
+		foreignColumn := reference.ColumnMapping[localColumn]
 		localColumns = append(localColumns, localColumn)
 		foreignColumns = append(foreignColumns, foreignColumn)
 
@@ -298,7 +467,7 @@ func substituteForeignKeyReference(db *sql.DB, table schemareader.Table,
 		row[table.ColumnIndexes[localColumns[0]]].Value = cachedValue
 		row[table.ColumnIndexes[localColumns[0]]].ColumnType = "SQL"
 	} else {
-		rows := sqlUtil.ExecuteQueryWithResults(db, sql, scanParameters...)
+		rows := sqlUtil.ExecutePreparedQueryWithResults(db, sql, scanParameters...)
 		// we will only change for a sub query if we were able to find the target Value
 		// other wise we keep the pre existing Value.
 		// this can happen when the column for the reference is null. Example rhnchanel->org_id
@@ -322,8 +491,8 @@ func substituteForeignKeyReference(db *sql.DB, table schemareader.Table,
 						} else {
 							foreignReference := foreignTable.GetFirstReferenceFromColumn(foreignColumn)
 							if strings.Compare(foreignReference.TableName, "") == 0 {
-								whereParameters = append(whereParameters, fmt.Sprintf("%s = %s",
-									foreignColumn, formatField(c)))
+								whereParameters = append(whereParameters,
+									formatKeyComparison(foreignTable, foreignColumn, formatField(placeholderField(foreignTable, anonymizeField(foreignTable, c)))))
 							} else {
 								//copiedrow := make([]sqlUtil.RowDataStructure, len(rows[0]))
 								//copy(copiedrow, rows[0])
@@ -335,8 +504,8 @@ func substituteForeignKeyReference(db *sql.DB, table schemareader.Table,
 										break
 									}
 								}
-								whereParameters = append(whereParameters, fmt.Sprintf("%s = %s",
-									foreignColumn, fieldToUpdate))
+								whereParameters = append(whereParameters,
+									formatKeyComparison(foreignTable, foreignColumn, fieldToUpdate))
 							}
 
 						}
@@ -356,6 +525,15 @@ func substituteForeignKeyReference(db *sql.DB, table schemareader.Table,
 	return row
 }
 
+// formatKeyComparison matches a unique index column with a value. Nullable columns are compared with
+// IS NOT DISTINCT FROM, since "=" never matches NULL, which sub queries can also resolve to
+func formatKeyComparison(table schemareader.Table, column string, value string) string {
+	if table.NullableColumns[column] {
+		return fmt.Sprintf("%s IS NOT DISTINCT FROM %s", column, value)
+	}
+	return fmt.Sprintf("%s = %s", column, value)
+}
+
 func formatRowValue(value []sqlUtil.RowDataStructure) string {
 	result := make([]string, 0)
 	for _, col := range value {
@@ -364,34 +542,106 @@ func formatRowValue(value []sqlUtil.RowDataStructure) string {
 	return strings.Join(result, ",")
 }
 
+// explicitCasts maps the database type of a column to the cast used for its generated values,
+// so the import does not depend on implicit casts which differ between PostgreSQL versions and locales
+var explicitCasts = map[string]string{
+	"NUMERIC":     "numeric",
+	"INT2":        "int2",
+	"INT4":        "int4",
+	"INT8":        "int8",
+	"FLOAT4":      "float4",
+	"FLOAT8":      "float8",
+	"BOOL":        "bool",
+	"DATE":        "date",
+	"TIMESTAMP":   "timestamp",
+	"TIMESTAMPTZ": "timestamptz",
+	"BYTEA":       "bytea",
+	"JSON":        "json",
+	"JSONB":       "jsonb",
+}
+
+// formatExportRowValue formats the row values for a statement written to the output
+func formatExportRowValue(value []sqlUtil.RowDataStructure, fields *streamedFields) string {
+	result := make([]string, 0)
+	for _, col := range value {
+		result = append(result, fields.formatExportField(col))
+	}
+	return strings.Join(result, ",")
+}
+
 func formatField(col sqlUtil.RowDataStructure) string {
 	if col.Value == nil {
 		return "null"
 	}
 	val := ""
 	switch col.ColumnType {
-	case "NUMERIC":
-		val = fmt.Sprintf(`%s`, col.Value)
-	case "TIMESTAMPTZ", "TIMESTAMP":
-		val = pq.QuoteLiteral(string(pq.FormatTimestamp(col.Value.(time.Time))))
+	case "NUMERIC", "INT2", "INT4", "INT8", "FLOAT4", "FLOAT8", "BOOL":
+		// quoted, so the NaN and Infinity floats are literals and not identifiers
+		val = fmt.Sprintf(`%s::%s`, pq.QuoteLiteral(formatNumber(col.Value)), explicitCasts[col.ColumnType])
+	case "TIMESTAMPTZ", "TIMESTAMP", "DATE":
+		val = fmt.Sprintf(`%s::%s`, pq.QuoteLiteral(formatTimestamp(col.ColumnType, col.Value.(time.Time))),
+			explicitCasts[col.ColumnType])
+	case "BYTEA":
+		val = fmt.Sprintf(`'\x%s'::bytea`, hex.EncodeToString(col.Value.([]byte)))
+	case LargeObjectType:
+		val = formatLargeObject(fmt.Sprintf(`'\x%s'::bytea`, hex.EncodeToString(col.Value.([]byte))))
 	case "SQL":
 		val = fmt.Sprintf(`(%s)`, col.Value)
+	case "JSON", "JSONB":
+		val = fmt.Sprintf(`%s::%s`, pq.QuoteLiteral(formatValue(col.Value)), explicitCasts[col.ColumnType])
 	default:
-		val = pq.QuoteLiteral(fmt.Sprintf("%s", col.Value))
+		val = pq.QuoteLiteral(formatValue(col.Value))
 	}
 	return val
 }
 
+// formatValue returns the textual representation of a value read from the database
+func formatValue(value interface{}) string {
+	if bytes, ok := value.([]byte); ok {
+		return string(bytes)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// formatNumber formats the numeric values, with the PostgreSQL spelling of the special float values
+func formatNumber(value interface{}) string {
+	if number, ok := value.(float64); ok {
+		switch {
+		case math.IsNaN(number):
+			return "NaN"
+		case math.IsInf(number, 1):
+			return "Infinity"
+		case math.IsInf(number, -1):
+			return "-Infinity"
+		}
+	}
+	return formatValue(value)
+}
+
 func formatColumnAssignment(table schemareader.Table) string {
 	assignments := make([]string, 0)
 	for _, column := range table.Columns {
-		if !table.PKColumns[column] && !table.UnexportColumns[column] {
+		if table.LargeObjectColumns[column] && !table.UnexportColumns[column] {
+			assignments = append(assignments, formatLargeObjectAssignment(table, column))
+		} else if !table.PKColumns[column] && !table.UnexportColumns[column] {
 			assignments = append(assignments, fmt.Sprintf("%s = excluded.%s", column, column))
 		}
 	}
 	return strings.Join(assignments, ",")
 }
 
+// formatLargeObjectAssignment formats the update of a large object reference, removing the large object of the
+// replaced row so updates don't leave orphans behind. The CASE conditions are evaluated in order, and only
+// existing large objects are removed.
+func formatLargeObjectAssignment(table schemareader.Table, column string) string {
+	current := table.InsertTableName() + "." + column
+	return fmt.Sprintf("%s = CASE WHEN NOT EXISTS (SELECT 1 FROM pg_largeobject_metadata WHERE oid = %s) THEN excluded.%s "+
+		"WHEN lo_unlink(%s) = 1 THEN excluded.%s ELSE excluded.%s END", column, current, column, current, column, column)
+}
+
+// LockedPassword is exported instead of the user password hashes, it doesn't match any password
+const LockedPassword = "!"
+
 func formatOnConflict(row []sqlUtil.RowDataStructure, table schemareader.Table) string {
 	constraint := "(" + strings.Join(table.UniqueIndexes[table.MainUniqueIndexName].Columns, ", ") + ")"
 	switch table.Name {
@@ -453,7 +703,16 @@ func formatOnConflict(row []sqlUtil.RowDataStructure, table schemareader.Table)
 		} else {
 			return "(version, release, epoch, ((evr).type)) WHERE epoch IS NOT NULL DO NOTHING"
 		}
-
+	case "web_contact":
+		// users existing on the target keep their password when the password hashes are not exported
+		columnAssignment := strings.Replace(formatColumnAssignment(table), "password = excluded.password",
+			fmt.Sprintf("password = CASE WHEN excluded.password = '%s' THEN web_contact.password ELSE excluded.password END", LockedPassword), 1)
+		return fmt.Sprintf("%s DO UPDATE SET %s", constraint, columnAssignment)
+	case "susecredentials":
+		// credentials existing on the target keep their password when the passwords are not exported
+		columnAssignment := strings.Replace(formatColumnAssignment(table), "password = excluded.password",
+			fmt.Sprintf("password = CASE WHEN excluded.password = '%s' THEN susecredentials.password ELSE excluded.password END", LockedPassword), 1)
+		return fmt.Sprintf("%s DO UPDATE SET %s", constraint, columnAssignment)
 	}
 	columnAssignment := formatColumnAssignment(table)
 	return fmt.Sprintf("%s DO UPDATE SET %s", constraint, columnAssignment)
@@ -469,16 +728,19 @@ func generateClearTable(db *sql.DB, writer *bufio.Writer, table schemareader.Tab
 	cleanEmptyTable := fmt.Sprintf("\nDELETE FROM %s WHERE (%s) IN (%s);",
 		table.Name, mainUniqueColumns, existingRecords)
 	writer.WriteString(cleanEmptyTable + "\n")
+	recordWrittenTable(table.Name)
 
 	// repopulate all pre-existing data
-	allTableRecordsSql := fmt.Sprintf("SELECT * FROM %s WHERE (%s) IN (%s);",
-		table.Name, mainUniqueColumns, existingRecords)
-	allTableRecords := sqlUtil.ExecuteQueryWithResults(db, allTableRecordsSql)
+	allTableRecordsSql := fmt.Sprintf("SELECT * FROM %s WHERE (%s) IN (%s)%s;",
+		table.Name, mainUniqueColumns, existingRecords, formatOrderBy(table))
+	allTableRecords := sqlUtil.ExecuteBulkQueryWithResults(db, allTableRecordsSql)
+	checks := newCheckConstraintBatch(db, table)
 	for _, record := range allTableRecords {
-		insertStatement := generateRowInsertStatement(db, record, table, schemaMetadata, []string{table.Name})
-		writer.WriteString(insertStatement + "\n")
+		insertStatement := formatRowInsertStatement(table, prepareRowValues(db, record, table, schemaMetadata, checks), []string{table.Name})
+		writeStatement(writer, insertStatement)
 		//fmt.Println(insertStatement)
 	}
+	checks.validate()
 }
 
 func buildQueryToGetExistingRecords(path []string, table schemareader.Table, schemaMetadata map[string]schemareader.Table, cleanWhereClause string) string {
@@ -508,7 +770,8 @@ func getJoinsClause(path []string, schemaMetadata map[string]schemareader.Table)
 			relationFound = findRelationInfo(schemaMetadata[firstTable].References, firstTable, secondTable)
 			reverseRelationLookup = true
 		}
-		for key, value := range relationFound {
+		for _, key := range sortedColumnMapping(relationFound) {
+			value := relationFound[key]
 			if reverseRelationLookup {
 				result.WriteString(fmt.Sprintf(` INNER JOIN %s on %s.%s = %s.%s`, secondTable, secondTable, value, firstTable, key))
 			} else {
@@ -554,12 +817,29 @@ func prepareColumnNames(table schemareader.Table) string {
 }
 
 func generateRowInsertStatement(db *sql.DB, values []sqlUtil.RowDataStructure, table schemareader.Table,
-	schemaMetadata map[string]schemareader.Table, onlyIfParentExistsTables []string) string {
+	schemaMetadata map[string]schemareader.Table, onlyIfParentExistsTables []string) exportStatement {
+
+	return formatRowInsertStatement(table, prepareRowValues(db, values, table, schemaMetadata, nil), onlyIfParentExistsTables)
+}
+
+// prepareRowValues replaces the row values which cannot be exported as read from the database, adding the rows
+// modified by the callback of the table to the checks
+func prepareRowValues(db *sql.DB, values []sqlUtil.RowDataStructure, table schemareader.Table,
+	schemaMetadata map[string]schemareader.Table, checks *checkConstraintBatch) []sqlUtil.RowDataStructure {
+
+	rowKeysProcessed := substituteKeys(db, table, placeholderRow(table, anonymizeRow(table, values)), schemaMetadata)
+	valueFiltered := filterRowData(db, rowKeysProcessed, table, checks)
+	return substituteLargeObjects(db, table, valueFiltered)
+}
+
+func formatRowInsertStatement(table schemareader.Table, valueFiltered []sqlUtil.RowDataStructure,
+	onlyIfParentExistsTables []string) exportStatement {
 
-	tableName := table.Name
+	fields := &streamedFields{}
+	table, valueFiltered = omitIdentityId(table, valueFiltered)
+	tableName := table.InsertTableName()
 	columnNames := prepareColumnNames(table)
-	rowKeysProcessed := substituteKeys(db, table, values, schemaMetadata)
-	valueFiltered := filterRowData(rowKeysProcessed, table)
+	overriding := formatOverridingClause(table, valueFiltered)
 
 	if strings.Compare(table.MainUniqueIndexName, schemareader.VirtualIndexName) == 0 || utils.Contains(onlyIfParentExistsTables, table.Name) {
 		whereClauseList := make([]string, 0)
@@ -570,8 +850,8 @@ func generateRowInsertStatement(db *sql.DB, values []sqlUtil.RowDataStructure, t
 					if value.Value == nil {
 						whereClauseList = append(whereClauseList, fmt.Sprintf(" %s IS NULL", value.ColumnName))
 					} else {
-						whereClauseList = append(whereClauseList, fmt.Sprintf(" %s = %s",
-							value.ColumnName, formatField(value)))
+						whereClauseList = append(whereClauseList,
+							" "+formatKeyComparison(table, value.ColumnName, fields.formatExportField(value)))
 					}
 				}
 			}
@@ -582,7 +862,7 @@ func generateRowInsertStatement(db *sql.DB, values []sqlUtil.RowDataStructure, t
 
 			parentsRecordsCheckList := make([]string, 0)
 			for _, reference := range table.References {
-				for localColumn, _ := range reference.ColumnMapping {
+				for _, localColumn := range sortedColumnMapping(reference.ColumnMapping) {
 					for _, value := range valueFiltered {
 						if strings.Compare(localColumn, value.ColumnName) == 0 {
 							if value.Value != nil && value.ColumnType == "SQL" {
@@ -593,17 +873,17 @@ func generateRowInsertStatement(db *sql.DB, values []sqlUtil.RowDataStructure, t
 				}
 			}
 			parentRecordsExistsClause := strings.Join(parentsRecordsCheckList, " AND ")
-			return fmt.Sprintf(`INSERT INTO %s (%s)	SELECT %s WHERE NOT EXISTS (SELECT 1 FROM %s WHERE %s) AND %s;`,
-				tableName, columnNames, formatRowValue(valueFiltered), tableName, whereClause, parentRecordsExistsClause)
+			return fields.statement(fmt.Sprintf(`INSERT INTO %s (%s)%s	SELECT %s WHERE NOT EXISTS (SELECT 1 FROM %s WHERE %s) AND %s;`,
+				tableName, columnNames, overriding, formatExportRowValue(valueFiltered, fields), tableName, whereClause, parentRecordsExistsClause))
 		}
 
-		return fmt.Sprintf(`INSERT INTO %s (%s)	SELECT %s WHERE NOT EXISTS (SELECT 1 FROM %s WHERE %s);`,
-			tableName, columnNames, formatRowValue(valueFiltered), tableName, whereClause)
+		return fields.statement(fmt.Sprintf(`INSERT INTO %s (%s)%s	SELECT %s WHERE NOT EXISTS (SELECT 1 FROM %s WHERE %s);`,
+			tableName, columnNames, overriding, formatExportRowValue(valueFiltered, fields), tableName, whereClause))
 
 	} else {
 		onConflictFormatted := formatOnConflict(valueFiltered, table)
-		return fmt.Sprintf(`INSERT INTO %s (%s)	VALUES (%s) ON CONFLICT %s;`,
-			tableName, columnNames, formatRowValue(valueFiltered), onConflictFormatted)
+		return fields.statement(fmt.Sprintf(`INSERT INTO %s (%s)%s	VALUES (%s) ON CONFLICT %s;`,
+			tableName, columnNames, overriding, formatExportRowValue(valueFiltered, fields), onConflictFormatted))
 	}
 
 }
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"database/sql"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/rs/zerolog/log"
@@ -17,7 +18,13 @@ func DumpAllTablesData(db *sql.DB, writer *bufio.Writer, schemaMetadata map[stri
 	// exporting from the starting tables.
 	processedTables := DumpReachableTablesData(db, writer, schemaMetadata, startingTables, whereFilterClause, onlyIfParentExistsTables, make(map[string]bool))
 	// Export tables not visited when exporting the starting tables
-	for schemaTableName, schemaTable := range schemaMetadata {
+	schemaTableNames := make([]string, 0, len(schemaMetadata))
+	for schemaTableName := range schemaMetadata {
+		schemaTableNames = append(schemaTableNames, schemaTableName)
+	}
+	sort.Strings(schemaTableNames)
+	for _, schemaTableName := range schemaTableNames {
+		schemaTable := schemaMetadata[schemaTableName]
 		if !schemaTable.Export {
 			continue
 		}
@@ -85,11 +92,21 @@ func exportAllTableData(db *sql.DB, writer *bufio.Writer, schemaMetadata map[str
 
 	log.Trace().Msgf("Exporting data for table %s", table.Name)
 	formattedColumns := strings.Join(table.Columns, ", ")
-	sql := fmt.Sprintf(`SELECT %s FROM %s %s;`, formattedColumns, table.Name, whereFilterClause(table))
-	rows := sqlUtil.ExecuteQueryWithResults(db, sql)
+	whereClause := whereFilterClause(table)
+	if rowsFilter := formatRowsFilter(schemaMetadata, table); len(rowsFilter) > 0 {
+		if len(strings.TrimSpace(whereClause)) > 0 {
+			whereClause = fmt.Sprintf("%s AND %s", whereClause, strings.Join(rowsFilter, " AND "))
+		} else {
+			whereClause = fmt.Sprintf("WHERE %s", strings.Join(rowsFilter, " AND "))
+		}
+	}
+	sql := fmt.Sprintf(`SELECT %s FROM %s %s%s;`, formattedColumns, table.Name, whereClause, formatOrderBy(table))
+	rows := sqlUtil.ExecuteBulkQueryWithResults(db, sql)
 
+	checks := newCheckConstraintBatch(db, table)
 	for _, row := range rows {
-		writer.WriteString(generateRowInsertStatement(db, row, table, schemaMetadata, onlyIfParentExistsTables) + "\n")
+		writeRowInsertStatement(db, writer, row, table, schemaMetadata, onlyIfParentExistsTables, checks)
 	}
+	checks.validate()
 
 }
@@ -0,0 +1,93 @@
+package dumper
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+)
+
+// AddErrataCloneOrigins adds the errata the exported errata were cloned from, and their own clone relationships,
+// to the exported rows. The rhnerratacloned rows are only imported when both errata exist on the target server,
+// and the original errata of cloned channels usually belong to channels which are not exported.
+func AddErrataCloneOrigins(db *sql.DB, schemaMetadata map[string]schemareader.Table, data DataDumper) {
+	errataTable, ok := schemaMetadata["rhnerrata"]
+	if !ok {
+		return
+	}
+	clonedTable, ok := schemaMetadata["rhnerratacloned"]
+	if !ok {
+		return
+	}
+	errata, ok := data.TableData[errataTable.Name]
+	if !ok || len(errata.Keys) == 0 {
+		return
+	}
+	exported := make(map[string]bool)
+	for _, key := range errata.Keys {
+		exported[generateKeyIdToMap(key)] = true
+	}
+	cloned := data.TableData[clonedTable.Name]
+	if cloned.TableName == "" {
+		cloned = TableDump{TableName: clonedTable.Name, Keys: make([]TableKey, 0)}
+	}
+	exportedCloned := make(map[string]bool)
+	for _, key := range cloned.Keys {
+		exportedCloned[generateKeyIdToMap(key)] = true
+	}
+
+	added := 0
+	clones := errataIds(errata.Keys)
+	// clones of clones are followed up to the vendor errata
+	for len(clones) > 0 {
+		conditions := append([]string{fmt.Sprintf("id IN (SELECT original_id FROM rhnerratacloned WHERE id IN (%s))",
+			strings.Join(clones, ","))}, formatRowsFilter(schemaMetadata, errataTable)...)
+		sql := fmt.Sprintf(`SELECT %s FROM rhnerrata WHERE %s;`,
+			strings.Join(errataTable.Columns, ", "), strings.Join(conditions, " and "))
+		origins := make([]TableKey, 0)
+		for _, row := range sqlUtil.ExecuteQueryWithResults(db, sql) {
+			key := extractRowKeyData(errataTable, processItem{errataTable.Name, row, nil, -1})
+			if keyId := generateKeyIdToMap(key); !exported[keyId] {
+				exported[keyId] = true
+				origins = append(origins, key)
+			}
+		}
+		if len(origins) == 0 {
+			break
+		}
+		errata.Keys = append(errata.Keys, origins...)
+		added += len(origins)
+		clones = errataIds(origins)
+
+		sql = fmt.Sprintf(`SELECT %s FROM rhnerratacloned WHERE id IN (%s);`,
+			strings.Join(clonedTable.Columns, ", "), strings.Join(clones, ","))
+		for _, row := range sqlUtil.ExecuteQueryWithResults(db, sql) {
+			key := extractRowKeyData(clonedTable, processItem{clonedTable.Name, row, nil, -1})
+			if keyId := generateKeyIdToMap(key); !exportedCloned[keyId] {
+				exportedCloned[keyId] = true
+				cloned.Keys = append(cloned.Keys, key)
+			}
+		}
+	}
+	if added > 0 {
+		log.Debug().Msgf("Exporting %d original errata of the cloned errata", added)
+		data.TableData[errataTable.Name] = errata
+		data.TableData[clonedTable.Name] = cloned
+	}
+}
+
+// errataIds returns the formatted ids of the errata keys
+func errataIds(keys []TableKey) []string {
+	ids := make([]string, 0, len(keys))
+	for _, key := range keys {
+		for _, column := range key.Key {
+			if column.Column == "id" {
+				ids = append(ids, column.Value)
+			}
+		}
+	}
+	return ids
+}
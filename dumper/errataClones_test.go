@@ -0,0 +1,49 @@
+package dumper
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/tests"
+)
+
+func TestAddErrataCloneOrigins(t *testing.T) {
+	repo := tests.CreateDataRepository()
+	schemaMetadata := map[string]schemareader.Table{
+		"rhnerrata": {Name: "rhnerrata", Export: true, Columns: []string{"id", "advisory"},
+			ColumnIndexes: map[string]int{"id": 0, "advisory": 1}, PKColumns: map[string]bool{"id": true}},
+		"rhnerratacloned": {Name: "rhnerratacloned", Export: true, Columns: []string{"original_id", "id"},
+			ColumnIndexes: map[string]int{"original_id": 0, "id": 1}, PKColumns: map[string]bool{"id": true}},
+	}
+	data := DataDumper{TableData: map[string]TableDump{
+		"rhnerrata":       {TableName: "rhnerrata", Keys: []TableKey{{[]RowKey{{"id", "'3'"}}}}},
+		"rhnerratacloned": {TableName: "rhnerratacloned", Keys: []TableKey{{[]RowKey{{"id", "'3'"}}}}},
+	}}
+	// errata 3 is a clone of 2, itself a clone of the vendor errata 1
+	repo.ExpectWithRecords("SELECT id, advisory FROM rhnerrata WHERE id IN (SELECT original_id FROM rhnerratacloned WHERE id IN ('3'));",
+		sqlmock.NewRows([]string{"id", "advisory"}).AddRow("2", "CL-SUSE-1"))
+	repo.ExpectWithRecords("SELECT original_id, id FROM rhnerratacloned WHERE id IN ('2');",
+		sqlmock.NewRows([]string{"original_id", "id"}).AddRow("1", "2"))
+	repo.ExpectWithRecords("SELECT id, advisory FROM rhnerrata WHERE id IN (SELECT original_id FROM rhnerratacloned WHERE id IN ('2'));",
+		sqlmock.NewRows([]string{"id", "advisory"}).AddRow("1", "SUSE-1"))
+	repo.ExpectWithRecords("SELECT original_id, id FROM rhnerratacloned WHERE id IN ('1');",
+		sqlmock.NewRows([]string{"original_id", "id"}))
+	repo.ExpectWithRecords("SELECT id, advisory FROM rhnerrata WHERE id IN (SELECT original_id FROM rhnerratacloned WHERE id IN ('1'));",
+		sqlmock.NewRows([]string{"id", "advisory"}))
+
+	AddErrataCloneOrigins(repo.DB, schemaMetadata, data)
+
+	expectedErrata := []TableKey{{[]RowKey{{"id", "'3'"}}}, {[]RowKey{{"id", "'2'"}}}, {[]RowKey{{"id", "'1'"}}}}
+	if !reflect.DeepEqual(data.TableData["rhnerrata"].Keys, expectedErrata) {
+		t.Errorf("expected errata %v, got %v", expectedErrata, data.TableData["rhnerrata"].Keys)
+	}
+	expectedCloned := []TableKey{{[]RowKey{{"id", "'3'"}}}, {[]RowKey{{"id", "'2'"}}}}
+	if !reflect.DeepEqual(data.TableData["rhnerratacloned"].Keys, expectedCloned) {
+		t.Errorf("expected clone relationships %v, got %v", expectedCloned, data.TableData["rhnerratacloned"].Keys)
+	}
+	if err := repo.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
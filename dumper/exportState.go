@@ -0,0 +1,28 @@
+package dumper
+
+// ResetExportState restores the state kept between the statements of an export, like the written tables,
+// the placeholders, the exported keys cache and the target database, to the state before the first export.
+// Programs running several exports in the same process call it before each export.
+func ResetExportState() {
+	writtenTables = make(map[string]bool)
+	cache = make(map[string]string)
+	referrencesCall = make(map[string]int)
+	checkConstraintViolations = 0
+	exportedKeys = nil
+	placeholderValues = nil
+	placeholderColumns = nil
+	targetDB = nil
+	targetRowsSkipped = make(map[string]int)
+	references = nil
+	tableSizes = nil
+	vendorReferences = make(map[string]map[string]map[string]bool)
+	resetSensitiveColumns()
+	keyMemory = 0
+	spillingTrackers = make(map[*spillingKeyTracker]bool)
+	SetKeyMemoryLimit(DefaultKeyMemoryLimit, "")
+	SetDedupMode(DedupExact)
+	SetAnonymize(false)
+	SetCopyWorkers(DefaultCopyWorkers)
+	SetIdStrategy(IdsAlwaysNew)
+	resetStatistics()
+}
@@ -0,0 +1,64 @@
+package dumper
+
+import (
+	"sync"
+	"time"
+)
+
+// TableWriteStatistics is the time spent reading and writing the exported rows of a table
+type TableWriteStatistics struct {
+	Rows     int
+	Duration time.Duration
+}
+
+// FileCopyStatistics is the time spent copying files into the export
+type FileCopyStatistics struct {
+	Files    int
+	Bytes    int64
+	Duration time.Duration
+}
+
+// statistics of the running export
+var tableWriteStatistics = make(map[string]TableWriteStatistics)
+var fileCopyStatistics FileCopyStatistics
+
+// files are copied by several goroutines
+var fileCopyMutex sync.Mutex
+
+// WriteStatistics returns the statistics of the tables written by the last export, indexed by table
+func WriteStatistics() map[string]TableWriteStatistics {
+	result := make(map[string]TableWriteStatistics, len(tableWriteStatistics))
+	for tableName, statistics := range tableWriteStatistics {
+		result[tableName] = statistics
+	}
+	return result
+}
+
+// CopyStatistics returns the statistics of the files copied by the last export
+func CopyStatistics() FileCopyStatistics {
+	fileCopyMutex.Lock()
+	defer fileCopyMutex.Unlock()
+	return fileCopyStatistics
+}
+
+func recordTableWrite(tableName string, rows int, duration time.Duration) {
+	statistics := tableWriteStatistics[tableName]
+	statistics.Rows += rows
+	statistics.Duration += duration
+	tableWriteStatistics[tableName] = statistics
+}
+
+func recordFileCopy(bytes int64, duration time.Duration) {
+	fileCopyMutex.Lock()
+	defer fileCopyMutex.Unlock()
+	fileCopyStatistics.Files++
+	fileCopyStatistics.Bytes += bytes
+	fileCopyStatistics.Duration += duration
+}
+
+func resetStatistics() {
+	tableWriteStatistics = make(map[string]TableWriteStatistics)
+	fileCopyMutex.Lock()
+	defer fileCopyMutex.Unlock()
+	fileCopyStatistics = FileCopyStatistics{}
+}
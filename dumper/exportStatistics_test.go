@@ -0,0 +1,37 @@
+package dumper
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestWriteStatistics(t *testing.T) {
+	ResetExportState()
+	recordTableWrite("rhnpackage", 10, time.Second)
+	recordTableWrite("rhnpackage", 5, time.Second)
+	statistics := WriteStatistics()["rhnpackage"]
+	if statistics.Rows != 15 || statistics.Duration != 2*time.Second {
+		t.Errorf("Expected the rows and duration of both writes, got %v", statistics)
+	}
+	ResetExportState()
+	if len(WriteStatistics()) != 0 {
+		t.Errorf("Expected no statistics after reset")
+	}
+}
+
+func TestCopyStatistics(t *testing.T) {
+	ResetExportState()
+	dir := t.TempDir()
+	source := path.Join(dir, "source")
+	if err := os.WriteFile(source, []byte("package"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Copy(source, path.Join(dir, "target")); err != nil {
+		t.Fatal(err)
+	}
+	if copies := CopyStatistics(); copies.Files != 1 || copies.Bytes != 7 {
+		t.Errorf("Expected one copied file of 7 bytes, got %v", copies)
+	}
+}
@@ -0,0 +1,146 @@
+package dumper
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// ExportedKeysCache stores the rows exported in previous runs for one target server, by table and
+// main unique index key, together with a hash of the exported values.
+// The rows of the last export are pending until its import is confirmed by the next export.
+type ExportedKeysCache struct {
+	path    string
+	Tables  map[string]map[string]string `json:"tables"`
+	Pending map[string]map[string]string `json:"pending,omitempty"`
+	// digest of the hashes, SHA-256 when empty
+	Digest string `json:"digest,omitempty"`
+}
+
+// cache used by the current export, nil when rows are always exported
+var exportedKeys *ExportedKeysCache
+
+// LoadExportedKeysCache enables skipping rows already exported to the same target. The cache file is created
+// on the first export and must be removed whenever the target loses data exported to it.
+// The rows of the previous export are only skipped once it was imported, otherwise they are exported again.
+func LoadExportedKeysCache(path string, previousImported bool) {
+	exportedKeys = &ExportedKeysCache{path: path, Tables: make(map[string]map[string]string)}
+	defer func() { exportedKeys.Pending = make(map[string]map[string]string) }()
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		utils.Panic().Err(err).Msg("error reading exported keys cache")
+	}
+	if err := json.Unmarshal(content, exportedKeys); err != nil {
+		utils.Panic().Err(err).Msgf("exported keys cache %s is corrupted", path)
+	}
+	cacheDigest := exportedKeys.Digest
+	if len(cacheDigest) == 0 {
+		cacheDigest = utils.DigestSha256
+	}
+	if cacheDigest != utils.Digest() {
+		// the hashes cannot match, all rows are exported and recorded again
+		log.Info().Msgf("Exported keys cache %s was written with another digest, rows are exported again", path)
+		exportedKeys.Tables = make(map[string]map[string]string)
+		return
+	}
+	if !previousImported {
+		if len(exportedKeys.Pending) > 0 {
+			log.Info().Msgf("Rows of the previous export recorded in %s are exported again, it was not confirmed as imported", path)
+		}
+		return
+	}
+	for tableName, pendingKeys := range exportedKeys.Pending {
+		tableKeys, ok := exportedKeys.Tables[tableName]
+		if !ok {
+			tableKeys = make(map[string]string)
+			exportedKeys.Tables[tableName] = tableKeys
+		}
+		for key, hash := range pendingKeys {
+			tableKeys[key] = hash
+		}
+	}
+}
+
+// SaveExportedKeysCache records the rows of the completed export as pending in the cache file, if the cache is enabled.
+// It is only called once the export is complete, rows of a failed export are never recorded.
+func SaveExportedKeysCache() {
+	if exportedKeys == nil {
+		return
+	}
+	exportedKeys.Digest = utils.Digest()
+	content, err := json.Marshal(exportedKeys)
+	if err != nil {
+		utils.Panic().Err(err).Msg("error encoding exported keys cache")
+	}
+	if err := os.WriteFile(exportedKeys.path, content, 0600); err != nil {
+		utils.Panic().Err(err).Msg("error writing exported keys cache")
+	}
+}
+
+// writeRowInsertStatement writes the insert statement of the row, unless the same row was exported to the target before
+// or is already on the target database. Rows modified before being exported are added to the checks.
+func writeRowInsertStatement(db *sql.DB, writer *bufio.Writer, values []sqlUtil.RowDataStructure, table schemareader.Table,
+	schemaMetadata map[string]schemareader.Table, onlyIfParentExistsTables []string, checks *checkConstraintBatch) {
+
+	// the references are recorded with the source values, before they are substituted
+	recordReferences(table, schemaMetadata, values)
+	recordVendorReferences(table, values)
+	rowValues := prepareRowValues(db, values, table, schemaMetadata, checks)
+	if isRowAlreadyExported(table, rowValues) || isRowOnTarget(table, rowValues) {
+		return
+	}
+	traceRowInsertStatement(table, rowValues, onlyIfParentExistsTables)
+	writeStatement(writer, formatRowInsertStatement(table, rowValues, onlyIfParentExistsTables))
+	recordWrittenTable(table.Name)
+}
+
+// isRowAlreadyExported checks the row against the cache, recording it as pending when it is new or changed.
+// Rows of tables without a unique index cannot be identified, they are always exported.
+func isRowAlreadyExported(table schemareader.Table, rowValues []sqlUtil.RowDataStructure) bool {
+	if exportedKeys == nil {
+		return false
+	}
+	index, ok := table.UniqueIndexes[table.MainUniqueIndexName]
+	if !ok || len(index.Columns) == 0 {
+		return false
+	}
+	// the values of key columns are natural keys, since references were replaced by sub queries
+	keyColumns := make(map[string]bool)
+	for _, column := range index.Columns {
+		keyColumns[column] = true
+	}
+	key := make([]string, 0)
+	hash := utils.NewDigest()
+	for _, value := range rowValues {
+		formattedValue := formatField(value)
+		if keyColumns[value.ColumnName] {
+			key = append(key, formattedValue)
+		}
+		hash.Write([]byte(formattedValue))
+		hash.Write([]byte{0})
+	}
+	rowKey := strings.Join(key, ",")
+	rowHash := hex.EncodeToString(hash.Sum(nil))
+
+	if exportedKeys.Tables[table.Name][rowKey] == rowHash || exportedKeys.Pending[table.Name][rowKey] == rowHash {
+		return true
+	}
+	pendingKeys, ok := exportedKeys.Pending[table.Name]
+	if !ok {
+		pendingKeys = make(map[string]string)
+		exportedKeys.Pending[table.Name] = pendingKeys
+	}
+	pendingKeys[rowKey] = rowHash
+	return false
+}
@@ -0,0 +1,215 @@
+package dumper
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/storage"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// DefaultCopyWorkers is the number of files copied at the same time by default
+const DefaultCopyWorkers = 4
+
+var copyWorkers = DefaultCopyWorkers
+
+// SetCopyWorkers sets the number of files copied at the same time, DefaultCopyWorkers when not positive
+func SetCopyWorkers(workers int) {
+	if workers <= 0 {
+		workers = DefaultCopyWorkers
+	}
+	copyWorkers = workers
+}
+
+// FileCopy is a file copied into the export, verified while copying when its checksum is known
+type FileCopy struct {
+	Source string
+	Target string
+	// label of the rhnchecksumtype of the checksum, like sha256, and the hex encoded checksum
+	ChecksumType string
+	Checksum     string
+	// stores the source is read from and the target is written to, by their paths in the store. The local file
+	// system when nil
+	SourceStore storage.Store
+	TargetStore storage.Store
+}
+
+// ChecksumError is the error of a copied file whose content doesn't match its checksum
+type ChecksumError struct {
+	Path     string
+	Expected string
+	Actual   string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("%s is corrupted: checksum %s, expected %s", e.Path, e.Actual, e.Expected)
+}
+
+// FileCopier copies files with several goroutines. Failures are collected, so the caller stops the export once
+// all the files were copied.
+type FileCopier struct {
+	files   chan FileCopy
+	workers sync.WaitGroup
+	mutex   sync.Mutex
+	// source files not matching their checksum, which are not copied
+	corrupted []string
+	err       error
+}
+
+// NewFileCopier returns a copier with the configured number of goroutines, which must be waited for
+func NewFileCopier() *FileCopier {
+	copier := &FileCopier{files: make(chan FileCopy, copyWorkers)}
+	for i := 0; i < copyWorkers; i++ {
+		copier.workers.Add(1)
+		go copier.work()
+	}
+	return copier
+}
+
+func (copier *FileCopier) work() {
+	defer copier.workers.Done()
+	for file := range copier.files {
+		copier.mutex.Lock()
+		failed := copier.err != nil
+		copier.mutex.Unlock()
+		if failed {
+			continue
+		}
+		err := copyVerified(file)
+		if err == nil {
+			continue
+		}
+		copier.mutex.Lock()
+		if checksumErr, ok := err.(*ChecksumError); ok {
+			log.Error().Msg(checksumErr.Error())
+			copier.corrupted = append(copier.corrupted, file.Source)
+		} else if copier.err == nil {
+			copier.err = fmt.Errorf("could not copy file %s: %w", file.Source, err)
+		}
+		copier.mutex.Unlock()
+	}
+}
+
+// Copy queues the file, waiting while all goroutines are busy
+func (copier *FileCopier) Copy(file FileCopy) {
+	copier.files <- file
+}
+
+// Wait waits for the queued files to be copied, returning the corrupted files and the first failure
+func (copier *FileCopier) Wait() ([]string, error) {
+	close(copier.files)
+	copier.workers.Wait()
+	return copier.corrupted, copier.err
+}
+
+// Finish waits for the queued files to be copied, stopping the export when a copy failed or files are corrupted
+func (copier *FileCopier) Finish(kind string) {
+	corrupted, err := copier.Wait()
+	// copies fail once the export is canceled
+	utils.CheckCanceled()
+	if err != nil {
+		utils.Panic().Err(err).Msgf("could not copy the %s", kind)
+	}
+	if len(corrupted) > 0 {
+		utils.Fatal().Int(utils.ExitCodeField, utils.ExitVerificationFailure).Strs("files", corrupted).
+			Msgf("%d %s don't match their checksum", len(corrupted), kind)
+	}
+}
+
+// copyVerified copies the file, comparing its content with its checksum. Corrupted copies are removed.
+// Checksums of digests which are unknown or not approved in FIPS mode are not verified.
+func copyVerified(file FileCopy) error {
+	if len(file.ChecksumType) == 0 || len(file.Checksum) == 0 {
+		_, err := file.copy(nil)
+		return err
+	}
+	hash, err := utils.NewDigestOf(strings.ToLower(file.ChecksumType))
+	if err != nil {
+		log.Trace().Msgf("%s not verified: %s", file.Source, err)
+		_, err := file.copy(nil)
+		return err
+	}
+	if _, err := file.copy(hash); err != nil {
+		return err
+	}
+	actual := hex.EncodeToString(hash.Sum(nil))
+	if !strings.EqualFold(actual, file.Checksum) {
+		file.removeTarget()
+		return &ChecksumError{Path: file.Source, Expected: file.Checksum, Actual: actual}
+	}
+	return nil
+}
+
+// copy copies the file between its stores, writing its content to the hash as well when set
+func (file FileCopy) copy(hash io.Writer) (int64, error) {
+	if file.SourceStore == nil && file.TargetStore == nil {
+		return copyFile(file.Source, file.Target, hash)
+	}
+	var source io.ReadCloser
+	var err error
+	if file.SourceStore != nil {
+		source, err = file.SourceStore.Open(file.Source)
+	} else {
+		source, err = openRegularFile(file.Source)
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer source.Close()
+	var destination storage.Writer
+	if file.TargetStore != nil {
+		destination, err = file.TargetStore.Create(file.Target)
+	} else {
+		destination, err = createLocalWriter(file.Target)
+	}
+	if err != nil {
+		return 0, err
+	}
+	var writer io.Writer = destination
+	if hash != nil {
+		writer = io.MultiWriter(destination, hash)
+	}
+	start := time.Now()
+	nBytes, err := io.Copy(writer, utils.CancelableReader(source))
+	recordFileCopy(nBytes, time.Since(start))
+	if err != nil {
+		// truncated files, like the ones of canceled copies, are never stored
+		destination.Abort()
+		return nBytes, err
+	}
+	return nBytes, destination.Close()
+}
+
+// localTargetWriter writes a target of the local file system in place, removing it when aborted
+type localTargetWriter struct {
+	*os.File
+}
+
+func createLocalWriter(path string) (storage.Writer, error) {
+	file, err := create(path)
+	if err != nil {
+		return nil, err
+	}
+	return localTargetWriter{File: file}, nil
+}
+
+func (writer localTargetWriter) Abort() {
+	writer.File.Close()
+	os.Remove(writer.File.Name())
+}
+
+func (file FileCopy) removeTarget() {
+	if file.TargetStore != nil {
+		if err := file.TargetStore.Remove(file.Target); err != nil {
+			log.Warn().Err(err).Msgf("Error removing the corrupted copy %s from %s", file.Target, file.TargetStore)
+		}
+		return
+	}
+	os.Remove(file.Target)
+}
@@ -0,0 +1,148 @@
+package dumper
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/uyuni-project/inter-server-sync/storage"
+)
+
+func TestFileCopierVerifiesChecksums(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	content := []byte("package content")
+	sum := sha256.Sum256(content)
+	checksum := hex.EncodeToString(sum[:])
+	if err := os.MkdirAll(filepath.Join(dir, "pool"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"good.rpm", "corrupted.rpm", "unverified.rpm"} {
+		if err := os.WriteFile(filepath.Join(dir, "pool", name), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	SetCopyWorkers(2)
+	defer SetCopyWorkers(DefaultCopyWorkers)
+
+	// Act
+	copier := NewFileCopier()
+	copier.Copy(FileCopy{Source: filepath.Join(dir, "pool", "good.rpm"), Target: filepath.Join(dir, "export", "good.rpm"),
+		ChecksumType: "sha256", Checksum: checksum})
+	copier.Copy(FileCopy{Source: filepath.Join(dir, "pool", "corrupted.rpm"), Target: filepath.Join(dir, "export", "corrupted.rpm"),
+		ChecksumType: "sha256", Checksum: "00" + checksum[2:]})
+	copier.Copy(FileCopy{Source: filepath.Join(dir, "pool", "unverified.rpm"), Target: filepath.Join(dir, "export", "unverified.rpm"),
+		ChecksumType: "md5", Checksum: "d41d8cd98f00b204e9800998ecf8427e"})
+	corrupted, err := copier.Wait()
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Unexpected copy failure: %v", err)
+	}
+	if len(corrupted) != 1 || corrupted[0] != filepath.Join(dir, "pool", "corrupted.rpm") {
+		t.Errorf("Expected the corrupted file to be flagged, got %v", corrupted)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "export", "corrupted.rpm")); !os.IsNotExist(err) {
+		t.Error("Expected the corrupted copy to be removed")
+	}
+	for _, name := range []string{"good.rpm", "unverified.rpm"} {
+		if _, err := os.Stat(filepath.Join(dir, "export", name)); err != nil {
+			t.Errorf("Expected %s to be copied: %v", name, err)
+		}
+	}
+}
+
+func TestFileCopierReportsMissingFiles(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+
+	// Act
+	copier := NewFileCopier()
+	copier.Copy(FileCopy{Source: filepath.Join(dir, "missing.rpm"), Target: filepath.Join(dir, "export", "missing.rpm")})
+	_, err := copier.Wait()
+
+	// Assert
+	if err == nil {
+		t.Error("Expected a failure copying a missing file")
+	}
+}
+
+func TestFileCopierStores(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	content := []byte("package content")
+	sum := sha256.Sum256(content)
+	checksum := hex.EncodeToString(sum[:])
+	for _, folder := range []string{"pool", "export"} {
+		if err := os.MkdirAll(filepath.Join(dir, folder), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	source, _ := storage.Open(filepath.Join(dir, "pool"))
+	target, _ := storage.Open(filepath.Join(dir, "export"))
+	if err := os.WriteFile(filepath.Join(dir, "pool", "vim.rpm"), content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Act
+	copier := NewFileCopier()
+	copier.Copy(FileCopy{Source: "vim.rpm", Target: "packages/1/vim.rpm", SourceStore: source, TargetStore: target,
+		ChecksumType: "sha256", Checksum: checksum})
+	copier.Copy(FileCopy{Source: "vim.rpm", Target: "packages/1/corrupted.rpm", SourceStore: source, TargetStore: target,
+		ChecksumType: "sha256", Checksum: "00" + checksum[2:]})
+	corrupted, err := copier.Wait()
+
+	// Assert
+	if err != nil || len(corrupted) != 1 {
+		t.Fatalf("Expected the corrupted file to be flagged, got %v %v", corrupted, err)
+	}
+	if copied, err := os.ReadFile(filepath.Join(dir, "export", "packages", "1", "vim.rpm")); err != nil || string(copied) != string(content) {
+		t.Errorf("Expected the file to be copied into the target store, got %v", err)
+	}
+	if exists, _ := target.Exists("packages/1/corrupted.rpm"); exists {
+		t.Error("Expected the corrupted copy to be removed from the target store")
+	}
+}
+
+// failingStore is a source store whose files fail to be read after their first bytes
+type failingStore struct {
+	storage.Store
+}
+
+func (store failingStore) Open(filePath string) (io.ReadCloser, error) {
+	return io.NopCloser(io.MultiReader(strings.NewReader("first bytes"), failingReader{})), nil
+}
+
+type failingReader struct{}
+
+func (failingReader) Read(data []byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func TestFileCopierStoresNothingOnFailure(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	target, _ := storage.Open(dir)
+
+	// Act
+	copier := NewFileCopier()
+	copier.Copy(FileCopy{Source: "vim.rpm", Target: "packages/1/vim.rpm", SourceStore: failingStore{}, TargetStore: target})
+	copier.Copy(FileCopy{Source: "gcc.rpm", Target: filepath.Join(dir, "local", "gcc.rpm"), SourceStore: failingStore{}})
+	_, err := copier.Wait()
+
+	// Assert
+	if err == nil {
+		t.Fatal("Expected the copy to fail")
+	}
+	if files, err := target.List(""); err != nil || len(files) != 0 {
+		t.Errorf("Expected nothing to be stored, got %v %v", files, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "local", "gcc.rpm")); !os.IsNotExist(err) {
+		t.Error("Expected the truncated local copy to be removed")
+	}
+}
@@ -0,0 +1,132 @@
+package dumper
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+)
+
+// Strategies of the primary keys generated by a sequence, of the tables with a PKSequence
+const (
+	// IdsAlwaysNew takes an id from the sequence of the target for every exported row. Rows existing on the
+	// target keep their id, the sequence value taken for them is lost.
+	IdsAlwaysNew = "always-new-ids"
+	// IdsPreserveSource exports the ids of the source server, and moves the sequences of the target past them
+	IdsPreserveSource = "preserve-source-ids"
+	// IdsLookupUniqueIndex looks up the id of the rows existing on the target by their main unique index, and
+	// takes an id from the sequence for the new rows only
+	IdsLookupUniqueIndex = "lookup-by-unique-index"
+)
+
+var idStrategy = IdsAlwaysNew
+
+// SetIdStrategy sets how the primary keys generated by sequences are exported, IdsAlwaysNew when empty
+func SetIdStrategy(strategy string) {
+	if strategy == IdsPreserveSource || strategy == IdsLookupUniqueIndex {
+		idStrategy = strategy
+	} else {
+		idStrategy = IdsAlwaysNew
+	}
+}
+
+// hasGeneratedId tells if the only column of the primary key of the table is generated by a sequence
+func hasGeneratedId(table schemareader.Table) bool {
+	return len(table.PKSequence) > 0 && len(table.PKColumns) == 1
+}
+
+// lookupPrimaryKey replaces the id taken from the sequence by the id of the row with the same main unique index
+// on the target, once the references of the row are substituted. Rows whose unique index contains the id keep
+// the sequence value.
+func lookupPrimaryKey(table schemareader.Table, row []sqlUtil.RowDataStructure) []sqlUtil.RowDataStructure {
+	if idStrategy != IdsLookupUniqueIndex || !hasGeneratedId(table) {
+		return row
+	}
+	values := make(map[string]sqlUtil.RowDataStructure, len(row))
+	for _, column := range row {
+		values[column.ColumnName] = column
+	}
+	indexColumns := table.UniqueIndexes[table.MainUniqueIndexName].Columns
+	conditions := make([]string, 0, len(indexColumns))
+	for _, indexColumn := range indexColumns {
+		value, ok := values[indexColumn]
+		if !ok || table.PKColumns[indexColumn] {
+			return row
+		}
+		conditions = append(conditions, formatKeyComparison(table, indexColumn, formatField(value)))
+	}
+	if len(conditions) == 0 {
+		return row
+	}
+	for i, column := range row {
+		if table.PKColumns[column.ColumnName] {
+			row[i].ColumnType = "SQL"
+			row[i].Value = fmt.Sprintf("SELECT COALESCE((SELECT %s FROM %s WHERE %s LIMIT 1), nextval('%s'))",
+				column.ColumnName, table.InsertTableName(), strings.Join(conditions, " AND "), table.PKSequence)
+		}
+	}
+	return row
+}
+
+// formatSequenceAdvance returns the statement moving the sequence of the table past the preserved source ids.
+// DO blocks print no rows, which would be read as output of the import.
+func formatSequenceAdvance(table schemareader.Table) string {
+	idColumn := ""
+	for column := range table.PKColumns {
+		idColumn = column
+	}
+	return fmt.Sprintf("DO $$ BEGIN PERFORM setval('%[1]s', max(%[2]s)) FROM %[3]s HAVING max(%[2]s) > (SELECT last_value FROM %[1]s); END $$;",
+		table.PKSequence, idColumn, table.InsertTableName())
+}
+
+// identityId returns the id column of the table when it is an identity column, empty otherwise
+func identityId(table schemareader.Table) string {
+	if !hasGeneratedId(table) {
+		return ""
+	}
+	for column := range table.PKColumns {
+		if len(table.IdentityColumns[column]) > 0 {
+			return column
+		}
+	}
+	return ""
+}
+
+// omitIdentityId leaves the identity id column out of the inserted columns when the target generates new ids,
+// so the identity generates them. The id is kept when it is part of the unique index matching existing rows.
+func omitIdentityId(table schemareader.Table, row []sqlUtil.RowDataStructure) (schemareader.Table, []sqlUtil.RowDataStructure) {
+	idColumn := identityId(table)
+	if idStrategy != IdsAlwaysNew || len(idColumn) == 0 {
+		return table, row
+	}
+	for _, indexColumn := range table.UniqueIndexes[table.MainUniqueIndexName].Columns {
+		if indexColumn == idColumn {
+			return table, row
+		}
+	}
+	unexportColumns := make(map[string]bool, len(table.UnexportColumns)+1)
+	for column, unexport := range table.UnexportColumns {
+		unexportColumns[column] = unexport
+	}
+	unexportColumns[idColumn] = true
+	table.UnexportColumns = unexportColumns
+	values := make([]sqlUtil.RowDataStructure, 0, len(row))
+	for _, column := range row {
+		if column.ColumnName != idColumn {
+			values = append(values, column)
+		}
+	}
+	return table, values
+}
+
+// formatOverridingClause returns the clause allowing the insert of the values of GENERATED ALWAYS identity
+// columns, empty when no such column is written
+func formatOverridingClause(table schemareader.Table, row []sqlUtil.RowDataStructure) string {
+	for _, column := range row {
+		if table.IdentityColumns[column.ColumnName] == schemareader.IdentityAlways {
+			return " OVERRIDING SYSTEM VALUE"
+		}
+	}
+	return ""
+}
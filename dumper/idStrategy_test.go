@@ -0,0 +1,133 @@
+package dumper
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+	"github.com/uyuni-project/inter-server-sync/tests"
+)
+
+func idStrategyTable() schemareader.Table {
+	indexName := "rhn_channel_label_uq"
+	return schemareader.Table{
+		Name:                "rhnchannel",
+		Columns:             []string{"id", "label"},
+		ColumnIndexes:       map[string]int{"id": 0, "label": 1},
+		PKColumns:           map[string]bool{"id": true},
+		PKSequence:          "rhn_channel_id_seq",
+		MainUniqueIndexName: indexName,
+		UniqueIndexes:       map[string]schemareader.UniqueIndex{indexName: {Name: indexName, Columns: []string{"label"}}},
+	}
+}
+
+func idStrategyRow() []sqlUtil.RowDataStructure {
+	return []sqlUtil.RowDataStructure{
+		{ColumnName: "id", ColumnType: "INT8", Value: int64(101)},
+		{ColumnName: "label", ColumnType: "VARCHAR", Value: "channel"},
+	}
+}
+
+func TestIdStrategies(t *testing.T) {
+	defer SetIdStrategy(IdsAlwaysNew)
+	cases := map[string]string{
+		IdsAlwaysNew:         "(SELECT nextval('rhn_channel_id_seq'))",
+		"":                   "(SELECT nextval('rhn_channel_id_seq'))",
+		IdsPreserveSource:    "'101'::int8",
+		IdsLookupUniqueIndex: "(SELECT COALESCE((SELECT id FROM rhnchannel WHERE label = 'channel' LIMIT 1), nextval('rhn_channel_id_seq')))",
+	}
+	for strategy, expectedId := range cases {
+		// 01 Arrange
+		repo := tests.CreateDataRepository()
+		table := idStrategyTable()
+		SetIdStrategy(strategy)
+
+		// 02 Act
+		result := generateRowInsertStatement(repo.DB, idStrategyRow(), table, MetaDataGraph{"rhnchannel": table}, []string{})
+
+		// 03 Assert
+		expected := fmt.Sprintf("INSERT INTO rhnchannel (id, label)\tVALUES (%s,'channel') ON CONFLICT (label) DO UPDATE SET label = excluded.label;", expectedId)
+		if strings.Compare(result.text, expected) != 0 {
+			t.Errorf("Strategy %s: expected %s, but got %s", strategy, expected, result.text)
+		}
+	}
+}
+
+func TestLookupPrimaryKeyInUniqueIndex(t *testing.T) {
+	// 01 Arrange
+	defer SetIdStrategy(IdsAlwaysNew)
+	SetIdStrategy(IdsLookupUniqueIndex)
+	table := idStrategyTable()
+	table.UniqueIndexes[table.MainUniqueIndexName] = schemareader.UniqueIndex{Name: table.MainUniqueIndexName, Columns: []string{"id"}}
+	row := substitutePrimaryKey(table, idStrategyRow())
+
+	// 02 Act
+	result := lookupPrimaryKey(table, row)
+
+	// 03 Assert
+	if result[0].Value != "SELECT nextval('rhn_channel_id_seq')" {
+		t.Errorf("Ids in the unique index cannot be looked up, got %v", result[0].Value)
+	}
+}
+
+func TestFormatSequenceAdvance(t *testing.T) {
+	// 01 Arrange
+	table := idStrategyTable()
+
+	// 02 Act
+	result := formatSequenceAdvance(table)
+
+	// 03 Assert
+	expected := "DO $$ BEGIN PERFORM setval('rhn_channel_id_seq', max(id)) FROM rhnchannel HAVING max(id) > " +
+		"(SELECT last_value FROM rhn_channel_id_seq); END $$;"
+	if strings.Compare(result, expected) != 0 {
+		t.Errorf("Expected %s, but got %s", expected, result)
+	}
+}
+
+func TestIdStrategiesIdentityColumn(t *testing.T) {
+	defer SetIdStrategy(IdsAlwaysNew)
+	cases := map[string]string{
+		IdsAlwaysNew:      "INSERT INTO rhnchannel (label)\tVALUES ('channel') ON CONFLICT (label) DO UPDATE SET label = excluded.label;",
+		IdsPreserveSource: "INSERT INTO rhnchannel (id, label) OVERRIDING SYSTEM VALUE\tVALUES ('101'::int8,'channel') ON CONFLICT (label) DO UPDATE SET label = excluded.label;",
+		IdsLookupUniqueIndex: "INSERT INTO rhnchannel (id, label) OVERRIDING SYSTEM VALUE\tVALUES ((SELECT COALESCE((SELECT id FROM rhnchannel WHERE label = 'channel' LIMIT 1), " +
+			"nextval('rhn_channel_id_seq'))),'channel') ON CONFLICT (label) DO UPDATE SET label = excluded.label;",
+	}
+	for strategy, expected := range cases {
+		// 01 Arrange
+		repo := tests.CreateDataRepository()
+		table := idStrategyTable()
+		table.IdentityColumns = map[string]string{"id": schemareader.IdentityAlways}
+		SetIdStrategy(strategy)
+
+		// 02 Act
+		result := generateRowInsertStatement(repo.DB, idStrategyRow(), table, MetaDataGraph{"rhnchannel": table}, []string{})
+
+		// 03 Assert
+		if strings.Compare(result.text, expected) != 0 {
+			t.Errorf("Strategy %s: expected %s, but got %s", strategy, expected, result.text)
+		}
+		if table.UnexportColumns["id"] {
+			t.Errorf("Strategy %s: the table of the schema should not be modified", strategy)
+		}
+	}
+}
+
+func TestIdentityColumnByDefault(t *testing.T) {
+	// 01 Arrange
+	defer SetIdStrategy(IdsAlwaysNew)
+	SetIdStrategy(IdsPreserveSource)
+	table := idStrategyTable()
+	table.IdentityColumns = map[string]string{"id": schemareader.IdentityByDefault}
+
+	// 02 Act
+	result := formatRowInsertStatement(table, idStrategyRow(), []string{})
+
+	// 03 Assert
+	expected := "INSERT INTO rhnchannel (id, label)\tVALUES ('101'::int8,'channel') ON CONFLICT (label) DO UPDATE SET label = excluded.label;"
+	if strings.Compare(result.text, expected) != 0 {
+		t.Errorf("Identity columns by default take the exported values, expected %s, but got %s", expected, result.text)
+	}
+}
@@ -0,0 +1,269 @@
+package dumper
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// KeyTracker records the keys of the rows processed by the data crawler
+type KeyTracker interface {
+	// Add records the key, returning false if it was recorded before
+	Add(key string) bool
+	// Len returns the number of recorded keys
+	Len() int
+	// Close releases the memory and files of the keys
+	Close()
+}
+
+// DefaultKeyMemoryLimit is the memory of the processed keys above which they are spilled to disk
+const DefaultKeyMemoryLimit = 1 << 30
+
+// estimated memory of a key in a map, besides the key itself
+const keyMapOverhead = 64
+
+// keys between two entries of the index of a spilled run
+const keyRunIndexInterval = 64
+
+// spilled runs of a tracker merged into one when there are more
+const maxKeyRuns = 8
+
+// rate of the lookups of new keys reading a spilled run
+const keyRunFalsePositiveRate = 0.01
+
+// memory of the keys kept in memory by all the trackers, and its limit
+var keyMemoryLimit int64 = DefaultKeyMemoryLimit
+var keyMemory int64
+
+// directory the keys are spilled to, the system temporary directory when empty
+var keySpillDirectory string
+
+// trackers keeping keys in memory, the biggest one is spilled when the limit is reached
+var spillingTrackers = make(map[*spillingKeyTracker]bool)
+
+// SetKeyMemoryLimit sets the memory of the processed keys above which they are spilled to files in the
+// directory, or in the system temporary directory when empty. Limits of 0 or less keep all keys in memory.
+func SetKeyMemoryLimit(limit int64, spillDirectory string) {
+	keyMemoryLimit = limit
+	keySpillDirectory = spillDirectory
+}
+
+func newKeyTracker() KeyTracker {
+	if dedupMode == DedupApproximate {
+		return newApproximateKeyTracker()
+	}
+	tracker := &spillingKeyTracker{pending: make(map[string]bool)}
+	spillingTrackers[tracker] = true
+	return tracker
+}
+
+// spillingKeyTracker keeps the keys in memory, and spills them to sorted files when the memory limit is reached
+type spillingKeyTracker struct {
+	pending      map[string]bool
+	pendingBytes int64
+	runs         []*keyRun
+	count        int
+}
+
+func (tracker *spillingKeyTracker) Add(key string) bool {
+	if tracker.pending[key] {
+		return false
+	}
+	for _, run := range tracker.runs {
+		if run.contains(key) {
+			return false
+		}
+	}
+	tracker.pending[key] = true
+	tracker.count++
+	size := int64(len(key)) + keyMapOverhead
+	tracker.pendingBytes += size
+	keyMemory += size
+	if keyMemoryLimit > 0 && keyMemory > keyMemoryLimit {
+		spillBiggestTracker()
+	}
+	return true
+}
+
+func (tracker *spillingKeyTracker) Len() int {
+	return tracker.count
+}
+
+func (tracker *spillingKeyTracker) Close() {
+	keyMemory -= tracker.pendingBytes
+	tracker.pending = nil
+	tracker.pendingBytes = 0
+	for _, run := range tracker.runs {
+		run.remove()
+	}
+	tracker.runs = nil
+	delete(spillingTrackers, tracker)
+}
+
+func spillBiggestTracker() {
+	var biggest *spillingKeyTracker
+	for tracker := range spillingTrackers {
+		if biggest == nil || tracker.pendingBytes > biggest.pendingBytes {
+			biggest = tracker
+		}
+	}
+	if biggest != nil && len(biggest.pending) > 0 {
+		biggest.spill()
+	}
+}
+
+// spill writes the keys in memory into a new run, merging the runs when there are too many
+func (tracker *spillingKeyTracker) spill() {
+	keys := make([]string, 0, len(tracker.pending))
+	for key := range tracker.pending {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	log.Debug().Msgf("Spilling %d processed keys to disk", len(keys))
+	tracker.runs = append(tracker.runs, writeKeyRun(len(keys), func(yield func(key string)) {
+		for _, key := range keys {
+			yield(key)
+		}
+	}))
+	keyMemory -= tracker.pendingBytes
+	tracker.pending = make(map[string]bool)
+	tracker.pendingBytes = 0
+	if len(tracker.runs) > maxKeyRuns {
+		tracker.runs = []*keyRun{mergeKeyRuns(tracker.runs)}
+	}
+}
+
+// keyRun is a file of sorted keys, each one prefixed with its length
+type keyRun struct {
+	file   *os.File
+	size   int64
+	count  int
+	index  []keyRunIndexEntry
+	filter *bloomFilter
+}
+
+type keyRunIndexEntry struct {
+	key    string
+	offset int64
+}
+
+// writeKeyRun writes the sorted keys produced by the function into a new run
+func writeKeyRun(count int, produce func(yield func(key string))) *keyRun {
+	file, err := os.CreateTemp(keySpillDirectory, "inter-server-sync-keys-")
+	if err != nil {
+		utils.Fatal().Err(err).Msg("Error creating the processed keys file")
+	}
+	// the file is only read through the open descriptor
+	os.Remove(file.Name())
+	run := &keyRun{file: file, filter: newBloomFilter(count, keyRunFalsePositiveRate)}
+	writer := bufio.NewWriterSize(file, 1<<20)
+	length := make([]byte, binary.MaxVarintLen64)
+	produce(func(key string) {
+		if run.count%keyRunIndexInterval == 0 {
+			run.index = append(run.index, keyRunIndexEntry{key, run.size})
+		}
+		n := binary.PutUvarint(length, uint64(len(key)))
+		writer.Write(length[:n])
+		writer.WriteString(key)
+		run.size += int64(n + len(key))
+		run.filter.add(key)
+		run.count++
+	})
+	if err := writer.Flush(); err != nil {
+		utils.Fatal().Err(err).Msg("Error writing the processed keys file")
+	}
+	return run
+}
+
+// contains tells if the key is in the run, reading the block of the index it belongs to
+func (run *keyRun) contains(key string) bool {
+	if !run.filter.mayContain(key) {
+		return false
+	}
+	// first index entry after the key
+	next := sort.Search(len(run.index), func(i int) bool { return run.index[i].key > key })
+	if next == 0 {
+		return false
+	}
+	start := run.index[next-1].offset
+	end := run.size
+	if next < len(run.index) {
+		end = run.index[next].offset
+	}
+	block := make([]byte, end-start)
+	if _, err := run.file.ReadAt(block, start); err != nil {
+		utils.Fatal().Err(err).Msg("Error reading the processed keys file")
+	}
+	for len(block) > 0 {
+		length, n := binary.Uvarint(block)
+		current := string(block[n : n+int(length)])
+		if current == key {
+			return true
+		}
+		if current > key {
+			return false
+		}
+		block = block[n+int(length):]
+	}
+	return false
+}
+
+// keys returns a function reading the keys of the run in order
+func (run *keyRun) keys() func() (string, bool) {
+	reader := bufio.NewReaderSize(io.NewSectionReader(run.file, 0, run.size), 1<<20)
+	return func() (string, bool) {
+		length, err := binary.ReadUvarint(reader)
+		if err == io.EOF {
+			return "", false
+		}
+		if err != nil {
+			utils.Fatal().Err(err).Msg("Error reading the processed keys file")
+		}
+		key := make([]byte, length)
+		if _, err := io.ReadFull(reader, key); err != nil {
+			utils.Fatal().Err(err).Msg("Error reading the processed keys file")
+		}
+		return string(key), true
+	}
+}
+
+func (run *keyRun) remove() {
+	run.file.Close()
+}
+
+// mergeKeyRuns merges the runs into a single one, removing them
+func mergeKeyRuns(runs []*keyRun) *keyRun {
+	count := 0
+	readers := make([]func() (string, bool), len(runs))
+	heads := make([]string, len(runs))
+	valid := make([]bool, len(runs))
+	for i, run := range runs {
+		count += run.count
+		readers[i] = run.keys()
+		heads[i], valid[i] = readers[i]()
+	}
+	merged := writeKeyRun(count, func(yield func(key string)) {
+		for {
+			smallest := -1
+			for i := range runs {
+				if valid[i] && (smallest < 0 || heads[i] < heads[smallest]) {
+					smallest = i
+				}
+			}
+			if smallest < 0 {
+				return
+			}
+			yield(heads[smallest])
+			heads[smallest], valid[smallest] = readers[smallest]()
+		}
+	})
+	for _, run := range runs {
+		run.remove()
+	}
+	return merged
+}
@@ -0,0 +1,74 @@
+package dumper
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSpillingKeyTracker(t *testing.T) {
+	SetKeyMemoryLimit(2000, t.TempDir())
+	defer ResetExportState()
+
+	tracker := newKeyTracker()
+	defer tracker.Close()
+	for i := 0; i < 1000; i++ {
+		if !tracker.Add(fmt.Sprintf("key-%d", i)) {
+			t.Fatalf("key-%d should be new", i)
+		}
+	}
+	spilling := tracker.(*spillingKeyTracker)
+	if len(spilling.runs) == 0 || len(spilling.runs) > maxKeyRuns {
+		t.Errorf("keys should be spilled to at most %d runs, got %d", maxKeyRuns, len(spilling.runs))
+	}
+	for i := 0; i < 1000; i++ {
+		if tracker.Add(fmt.Sprintf("key-%d", i)) {
+			t.Errorf("key-%d should be known", i)
+		}
+	}
+	if !tracker.Add("key-1000") {
+		t.Error("key-1000 should be new")
+	}
+	if tracker.Len() != 1001 {
+		t.Errorf("expected 1001 keys, got %d", tracker.Len())
+	}
+}
+
+func TestKeyTrackerWithoutLimit(t *testing.T) {
+	SetKeyMemoryLimit(0, "")
+	defer ResetExportState()
+
+	tracker := newKeyTracker()
+	tracker.Add("1")
+	tracker.Add("2")
+	if tracker.Add("1") {
+		t.Error("key 1 should be known")
+	}
+	if len(tracker.(*spillingKeyTracker).runs) != 0 {
+		t.Error("keys should stay in memory without limit")
+	}
+	tracker.Close()
+	if keyMemory != 0 {
+		t.Errorf("closed trackers should release their memory, got %d bytes", keyMemory)
+	}
+}
+
+func TestBloomFilter(t *testing.T) {
+	filter := newBloomFilter(1000, 0.01)
+	for i := 0; i < 1000; i++ {
+		filter.add(fmt.Sprintf("key-%d", i))
+	}
+	for i := 0; i < 1000; i++ {
+		if !filter.mayContain(fmt.Sprintf("key-%d", i)) {
+			t.Fatalf("key-%d should be in the filter", i)
+		}
+	}
+	falsePositives := 0
+	for i := 1000; i < 11000; i++ {
+		if filter.mayContain(fmt.Sprintf("key-%d", i)) {
+			falsePositives++
+		}
+	}
+	if falsePositives > 300 {
+		t.Errorf("expected about 1%% false positives, got %d in 10000", falsePositives)
+	}
+}
@@ -2,23 +2,39 @@ package osImageDumper
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path"
+	"strings"
 
 	"github.com/rs/zerolog/log"
 	"github.com/uyuni-project/inter-server-sync/dumper"
+	"github.com/uyuni-project/inter-server-sync/storage"
+	"github.com/uyuni-project/inter-server-sync/utils"
 )
 
 var serverDataFolder = "/srv/www/os-images/"
 
+// store holding the image files by their path relative to serverDataFolder, nil to read them from serverDataFolder
+var imageStore storage.Store
+
+// SetImageStore sets the store the image files are read from, nil to read them from the image folder
+func SetImageStore(store storage.Store) {
+	imageStore = store
+}
+
 //FIXME: we have no relation from db tables to actial data so for now copy content of serverDataFolder
 //func DumpOsImages(db *sql.DB, schemaMetadata map[string]schemareader.Table, data dumper.DataDumper, outputFolder string) {
 func DumpOsImages(outputFolder string, orgIds []uint) {
 	log.Debug().Msg("Images data dump")
+	if imageStore != nil {
+		dumpStoredOsImages(outputFolder, orgIds)
+		return
+	}
 
 	imagesDir, err := os.Open(serverDataFolder)
 	if err != nil {
-		log.Fatal().Err(err)
+		utils.Fatal().Err(err)
 	}
 	defer imagesDir.Close()
 	orgDirInfo, err := imagesDir.ReadDir(-1)
@@ -27,33 +43,108 @@ func DumpOsImages(outputFolder string, orgIds []uint) {
 		orgIds = []uint{0}
 	}
 
+	copier := dumper.NewFileCopier()
+
 	for _, org := range orgDirInfo {
 		for _, orgId := range orgIds {
 			if org.Type().IsDir() && (orgId == 0 || org.Name() == fmt.Sprint(orgId)) {
 				var orgDirPath = path.Join(serverDataFolder, org.Name())
 				orgDir, err := os.Open(orgDirPath)
 				if err != nil {
-					log.Fatal().Err(err)
+					utils.Fatal().Err(err)
 				}
 				defer orgDir.Close()
 				orgDirInfo, err := orgDir.ReadDir(-1)
 
 				for _, image := range orgDirInfo {
 					if image.Type().IsRegular() {
-						DumpOsImage(path.Join(outputFolder, org.Name(), image.Name()), path.Join(orgDirPath, image.Name()))
+						copier.Copy(dumper.FileCopy{Source: path.Join(orgDirPath, image.Name()), Target: path.Join(outputFolder, org.Name(), image.Name())})
 					}
 				}
 			}
 		}
 	}
+	copier.Finish("image files")
+}
+
+// dumpStoredOsImages copies the image files of the org folders of the image store
+func dumpStoredOsImages(outputFolder string, orgIds []uint) {
+	files, err := imageStore.List("")
+	if err != nil {
+		utils.Fatal().Err(err).Msgf("Error listing the image files of %s", imageStore)
+	}
+	if len(orgIds) == 0 {
+		orgIds = []uint{0}
+	}
+	copier := dumper.NewFileCopier()
+	for _, file := range files {
+		segments := strings.Split(file, "/")
+		if len(segments) != 2 {
+			continue
+		}
+		for _, orgId := range orgIds {
+			if orgId == 0 || segments[0] == fmt.Sprint(orgId) {
+				copier.Copy(dumper.FileCopy{Source: file, Target: path.Join(outputFolder, file), SourceStore: imageStore})
+			}
+		}
+	}
+	copier.Finish("image files")
 }
 
 func DumpOsImage(outputFolder string, source string) {
 	log.Trace().Msgf("Copying image %s to %s", source, outputFolder)
 	_, err := dumper.Copy(source, outputFolder)
 	if err != nil {
-		log.Fatal().Err(err)
+		utils.Fatal().Err(err).Msgf("Error copying image file %s", source)
+	}
+}
+
+// checksum files kiwi creates next to the image bundles, used by saltboot to verify the downloads
+var checksumSuffixes = []string{".sha256", ".md5"}
+
+// QueueOsImageBundle queues the copies of an image file and of its checksum files, verifying the image file
+// with its sha256 checksum file
+func QueueOsImageBundle(copier *dumper.FileCopier, outputFolder string, source string) {
+	if imageStore != nil {
+		source = strings.TrimPrefix(strings.TrimPrefix(source, serverDataFolder), "/")
+	}
+	image := dumper.FileCopy{Source: source, Target: outputFolder, SourceStore: imageStore}
+	if content, err := readImageFile(source + ".sha256"); err == nil {
+		if fields := strings.Fields(string(content)); len(fields) > 0 {
+			image.ChecksumType, image.Checksum = utils.DigestSha256, fields[0]
+		}
+	}
+	copier.Copy(image)
+	for _, suffix := range checksumSuffixes {
+		if imageFileExists(source + suffix) {
+			copier.Copy(dumper.FileCopy{Source: source + suffix, Target: outputFolder + suffix, SourceStore: imageStore})
+		}
+	}
+}
+
+// readImageFile reads the small image file, like checksum files, from the image store when set
+func readImageFile(source string) ([]byte, error) {
+	if imageStore == nil {
+		return os.ReadFile(source)
+	}
+	reader, err := imageStore.Open(source)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+func imageFileExists(source string) bool {
+	if imageStore == nil {
+		_, err := os.Stat(source)
+		return err == nil
+	}
+	exists, err := imageStore.Exists(source)
+	if err != nil {
+		log.Warn().Err(err).Msgf("Error looking up %s in %s", source, imageStore)
 	}
+	return exists
 }
 
 func GetImagePathForImage(filepath string, org_id string, prefixOpt ...string) string {
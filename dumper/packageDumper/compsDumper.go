@@ -0,0 +1,41 @@
+package packageDumper
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/dumper"
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// DumpCompsFiles copies the comps and modules.yaml files of the exported channels into the output folder,
+// returning their paths. Modular channels cannot be used by dnf clients without their modules.yaml file.
+func DumpCompsFiles(db *sql.DB, schemaMetadata map[string]schemareader.Table, data dumper.DataDumper, outputFolder string) []string {
+	compsKeysData, ok := data.TableData["rhnchannelcomps"]
+	if !ok || len(compsKeysData.Keys) == 0 {
+		return nil
+	}
+	table := schemaMetadata[compsKeysData.TableName]
+	pathIndex, ok := table.ColumnIndexes["relative_filename"]
+	if !ok {
+		return nil
+	}
+
+	compsPaths := make([]string, 0, len(compsKeysData.Keys))
+	for _, row := range dumper.GetRowsFromKeys(db, table, compsKeysData.Keys) {
+		if row[pathIndex].Value == nil {
+			continue
+		}
+		path := fmt.Sprintf("%s", row[pathIndex].Value)
+		_, err := dumper.Copy(filepath.Join(serverDataFolder, path), filepath.Join(outputFolder, path))
+		if err != nil {
+			utils.Panic().Err(err).Msgf("could not copy the comps file %s", path)
+		}
+		compsPaths = append(compsPaths, path)
+	}
+	log.Debug().Msgf("Exported %d comps and modules files", len(compsPaths))
+	return compsPaths
+}
@@ -0,0 +1,130 @@
+package packageDumper
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// InventoryHeader is the first line of a package inventory, followed by one "checksum_type checksum path" line
+// for each package file of the pool, the path relative to the data folder of the server
+const InventoryHeader = "# inter-server-sync package inventory 1"
+
+var inventorySql = `SELECT p.path, ct.label, c.checksum FROM rhnpackage p
+	JOIN rhnchecksum c ON c.id = p.checksum_id
+	JOIN rhnchecksumtype ct ON ct.id = c.checksum_type_id
+	WHERE p.path IS NOT NULL ORDER BY p.path;`
+
+// PackageInventory lists the package files a server has, so exports to it can leave them out
+type PackageInventory struct {
+	// checksum type and checksum of the files, indexed by path
+	files map[string]string
+}
+
+// package files of the target server, which are not copied into the export, nil without inventory
+var targetInventory *PackageInventory
+
+// SetTargetInventory sets the package files present on the target server, nil to export all of them
+func SetTargetInventory(inventory *PackageInventory) {
+	targetInventory = inventory
+}
+
+// Contains checks if the inventory has the file with the same checksum
+func (inventory *PackageInventory) Contains(path string, checksumType string, checksum string) bool {
+	if inventory == nil || len(checksum) == 0 {
+		return false
+	}
+	return inventory.files[path] == checksumType+" "+checksum
+}
+
+// Len returns the number of files of the inventory
+func (inventory *PackageInventory) Len() int {
+	return len(inventory.files)
+}
+
+// WriteInventory writes the inventory of the package files of the server which exist in its data folder or package store,
+// compressed with gzip, and returns the number of files
+func WriteInventory(db *sql.DB, outputFile string) int {
+	file, err := os.Create(outputFile)
+	if err != nil {
+		utils.Fatal().Err(err).Msg("Error creating the package inventory")
+	}
+	defer file.Close()
+	gzipFile := gzip.NewWriter(file)
+	writer := bufio.NewWriter(gzipFile)
+	writer.WriteString(InventoryHeader + "\n")
+	count := 0
+	missing := 0
+	for _, row := range sqlUtil.ExecuteQueryWithResults(db, inventorySql) {
+		path := fmt.Sprintf("%s", row[0].Value)
+		if !packageExists(path) {
+			missing++
+			continue
+		}
+		writer.WriteString(fmt.Sprintf("%s %s %s\n", row[1].Value, row[2].Value, path))
+		count++
+	}
+	if missing > 0 {
+		source := serverDataFolder
+		if packageStore != nil {
+			source = packageStore.String()
+		}
+		log.Info().Msgf("%d package files are missing from %s and not listed", missing, source)
+	}
+	if err := writer.Flush(); err != nil {
+		utils.Fatal().Err(err).Msg("Error writing the package inventory")
+	}
+	if err := gzipFile.Close(); err != nil {
+		utils.Fatal().Err(err).Msg("Error writing the package inventory")
+	}
+	return count
+}
+
+// ReadInventory reads a package inventory written by WriteInventory, or uncompressed
+func ReadInventory(path string) *PackageInventory {
+	file, err := os.Open(path)
+	if err != nil {
+		utils.Fatal().Err(err).Msg("Error opening the package inventory")
+	}
+	defer file.Close()
+	inventory, err := readInventory(file)
+	if err != nil {
+		utils.Fatal().Int(utils.ExitCodeField, utils.ExitConfigError).Err(err).Msgf("%s is not a valid package inventory", path)
+	}
+	log.Info().Msgf("Package inventory %s lists %d package files of the target", path, inventory.Len())
+	return inventory
+}
+
+func readInventory(reader io.Reader) (*PackageInventory, error) {
+	buffered := bufio.NewReader(reader)
+	if magic, _ := buffered.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gzipReader, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, err
+		}
+		defer gzipReader.Close()
+		buffered = bufio.NewReader(gzipReader)
+	}
+	scanner := bufio.NewScanner(buffered)
+	if !scanner.Scan() || scanner.Text() != InventoryHeader {
+		return nil, fmt.Errorf("the first line is not %q", InventoryHeader)
+	}
+	inventory := &PackageInventory{files: make(map[string]string)}
+	for line := 2; scanner.Scan(); line++ {
+		fields := strings.SplitN(scanner.Text(), " ", 3)
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("line %d is not 'checksum_type checksum path'", line)
+		}
+		inventory.files[fields[2]] = fields[0] + " " + fields[1]
+	}
+	return inventory, scanner.Err()
+}
@@ -0,0 +1,59 @@
+package packageDumper
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/uyuni-project/inter-server-sync/tests"
+)
+
+func TestWriteAndReadInventory(t *testing.T) {
+	dataFolder := t.TempDir()
+	defer func(folder string) { serverDataFolder = folder }(serverDataFolder)
+	serverDataFolder = dataFolder
+	presentPath := "packages/1/abc/vim/9.0-1/x86_64/vim.rpm"
+	os.MkdirAll(filepath.Join(dataFolder, filepath.Dir(presentPath)), 0755)
+	os.WriteFile(filepath.Join(dataFolder, presentPath), []byte("rpm"), 0644)
+	repo := tests.CreateDataRepository()
+	repo.ExpectWithRecords(inventorySql, sqlmock.NewRows([]string{"path", "label", "checksum"}).
+		AddRow(presentPath, "sha256", "abc123").
+		AddRow("packages/1/def/emacs/29-1/x86_64/emacs.rpm", "sha256", "def456"))
+	inventoryFile := filepath.Join(t.TempDir(), "inventory.txt.gz")
+
+	count := WriteInventory(repo.DB, inventoryFile)
+	inventory := ReadInventory(inventoryFile)
+
+	if count != 1 || inventory.Len() != 1 {
+		t.Fatalf("only the files of the data folder should be listed, got %d", inventory.Len())
+	}
+	if !inventory.Contains(presentPath, "sha256", "abc123") {
+		t.Errorf("the file should be in the inventory")
+	}
+	if inventory.Contains(presentPath, "sha256", "other") || inventory.Contains(presentPath, "md5", "abc123") {
+		t.Errorf("files with another checksum should not be in the inventory")
+	}
+	var noInventory *PackageInventory
+	if noInventory.Contains(presentPath, "sha256", "abc123") {
+		t.Errorf("no file is in a missing inventory")
+	}
+}
+
+func TestReadUncompressedInventory(t *testing.T) {
+	inventory, err := readInventory(strings.NewReader(InventoryHeader + "\nsha1 0a1b packages/NULL/0a1/my package.rpm\n"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !inventory.Contains("packages/NULL/0a1/my package.rpm", "sha1", "0a1b") {
+		t.Errorf("paths with spaces should be read")
+	}
+	if _, err := readInventory(strings.NewReader("sha1 0a1b packages/a.rpm\n")); err == nil {
+		t.Errorf("inventories without header should be refused")
+	}
+	if _, err := readInventory(strings.NewReader(InventoryHeader + "\nsha1 packages/a.rpm\n")); err == nil {
+		t.Errorf("lines without checksum should be refused")
+	}
+}
@@ -4,25 +4,67 @@ import (
 	"database/sql"
 	"fmt"
 	"github.com/rs/zerolog/log"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/uyuni-project/inter-server-sync/dumper"
 	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+	"github.com/uyuni-project/inter-server-sync/storage"
+	"github.com/uyuni-project/inter-server-sync/tracing"
 )
 
 var serverDataFolder = "/var/spacewalk"
 
-func DumpPackageFiles(db *sql.DB, schemaMetadata map[string]schemareader.Table, data dumper.DataDumper, outputFolder string) {
+// store holding the package files by their path relative to the data folder, nil to read them from the data folder
+var packageStore storage.Store
+
+// SetPackageStore sets the store the package files are read from, nil to read them from the data folder
+func SetPackageStore(store storage.Store) {
+	packageStore = store
+}
+
+// packageSource returns where the package file with the path is read from
+func packageSource(path string) string {
+	if packageStore != nil {
+		return path
+	}
+	return fmt.Sprintf("%s/%s", serverDataFolder, path)
+}
+
+// packageExists checks if the package file with the path is in the data folder or the package store
+func packageExists(path string) bool {
+	if packageStore != nil {
+		exists, err := packageStore.Exists(path)
+		if err != nil {
+			log.Warn().Err(err).Msgf("Error looking up %s in %s", path, packageStore)
+		}
+		return exists
+	}
+	_, err := os.Stat(filepath.Join(serverDataFolder, path))
+	return err == nil
+}
+
+// DumpPackageFiles copies the package files of the exported packages into the output folder, returning their paths.
+// The files of the package inventory of the target are left out.
+func DumpPackageFiles(db *sql.DB, schemaMetadata map[string]schemareader.Table, data dumper.DataDumper, outputFolder string) []string {
 
 	packageKeysData := data.TableData["rhnpackage"]
 	table := schemaMetadata[packageKeysData.TableName]
 	pathIndex := table.ColumnIndexes["path"]
 
 	totalPackages := len(packageKeysData.Keys)
+	span := tracing.StartSpan("copy package files")
+	span.SetAttribute("files", totalPackages)
+	defer span.End()
 	log.Debug().Msgf("Total package files to copy: %d", totalPackages)
 
 	exportedpackages := 0
+	skippedPackages := 0
 	processing := true
+	packagePaths := make([]string, 0, totalPackages)
 
 	if log.Debug().Enabled() {
 		go func() {
@@ -41,6 +83,8 @@ func DumpPackageFiles(db *sql.DB, schemaMetadata map[string]schemareader.Table,
 
 	exportPoint := 0
 	batchSize := 500
+	checksumIndex := table.ColumnIndexes["checksum_id"]
+	copier := dumper.NewFileCopier()
 
 	for len(packageKeysData.Keys) > exportPoint {
 		upperLimit := exportPoint + batchSize
@@ -48,17 +92,51 @@ func DumpPackageFiles(db *sql.DB, schemaMetadata map[string]schemareader.Table,
 			upperLimit = len(packageKeysData.Keys)
 		}
 		rows := dumper.GetRowsFromKeys(db, table, packageKeysData.Keys[exportPoint:upperLimit])
+		checksums := readChecksums(db, rows, checksumIndex)
 		for _, rowPackage := range rows {
 			path := rowPackage[pathIndex]
-			source := fmt.Sprintf("%s/%s", serverDataFolder, path.Value)
-			target := fmt.Sprintf("%s/%s", outputFolder, path.Value)
-			_, error := dumper.Copy(source, target)
-			if error != nil {
-				log.Panic().Err(error).Msg("could not Copy File")
+			file := dumper.FileCopy{
+				Source:      packageSource(fmt.Sprintf("%s", path.Value)),
+				Target:      fmt.Sprintf("%s/%s", outputFolder, path.Value),
+				SourceStore: packageStore,
 			}
+			if checksum, ok := checksums[fmt.Sprintf("%v", rowPackage[checksumIndex].Value)]; ok {
+				file.ChecksumType, file.Checksum = checksum[0], checksum[1]
+			}
+			if targetInventory.Contains(fmt.Sprintf("%s", path.Value), file.ChecksumType, file.Checksum) {
+				skippedPackages++
+				continue
+			}
+			copier.Copy(file)
+			packagePaths = append(packagePaths, fmt.Sprintf("%s", path.Value))
 			exportedpackages++
 		}
 		exportPoint = upperLimit
 	}
+	copier.Finish("package files of the pool")
 	processing = false
+	if skippedPackages > 0 {
+		log.Info().Msgf("%d package files listed in the package inventory of the target are not exported", skippedPackages)
+	}
+	return packagePaths
+}
+
+// readChecksums returns the checksum type and checksum of the packages, indexed by checksum id
+func readChecksums(db *sql.DB, rows [][]sqlUtil.RowDataStructure, checksumIndex int) map[string][2]string {
+	checksums := make(map[string][2]string)
+	ids := make([]string, 0, len(rows))
+	for _, row := range rows {
+		if row[checksumIndex].Value != nil {
+			ids = append(ids, fmt.Sprintf("%v", row[checksumIndex].Value))
+		}
+	}
+	if len(ids) == 0 {
+		return checksums
+	}
+	query := fmt.Sprintf(`SELECT c.id, ct.label, c.checksum FROM rhnchecksum c
+	JOIN rhnchecksumtype ct ON ct.id = c.checksum_type_id WHERE c.id IN (%s);`, strings.Join(ids, ", "))
+	for _, checksum := range sqlUtil.ExecuteQueryWithResults(db, query) {
+		checksums[fmt.Sprintf("%v", checksum[0].Value)] = [2]string{fmt.Sprintf("%s", checksum[1].Value), fmt.Sprintf("%s", checksum[2].Value)}
+	}
+	return checksums
 }
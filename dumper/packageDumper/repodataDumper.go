@@ -0,0 +1,52 @@
+package packageDumper
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/dumper"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+var repodataFolder = "/var/cache/rhn/repodata"
+
+// index files of the repository metadata of rpm channels, and of Debian and Ubuntu channels
+var repodataIndexFiles = []string{"repomd.xml", "Release"}
+
+// HasRepodata checks if the folder holds the repository metadata of a channel
+func HasRepodata(folder string) bool {
+	for _, indexFile := range repodataIndexFiles {
+		if _, err := os.Stat(filepath.Join(folder, indexFile)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// DumpRepodata copies the generated repository metadata of the channel to the export, the repomd.xml and its
+// files for rpm channels, the Release and Packages files for Debian and Ubuntu channels.
+// It returns false when the channel has no repository metadata to export.
+func DumpRepodata(channelLabel string, outputFolder string) bool {
+	source := filepath.Join(repodataFolder, channelLabel)
+	if !HasRepodata(source) {
+		log.Warn().Msgf("No repository metadata found for channel %s, it will be generated on import", channelLabel)
+		return false
+	}
+	target := filepath.Join(outputFolder, "repodata", channelLabel)
+	err := filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		relativePath, err := filepath.Rel(source, path)
+		if err != nil {
+			return err
+		}
+		_, err = dumper.Copy(path, filepath.Join(target, relativePath))
+		return err
+	})
+	if err != nil {
+		utils.Panic().Err(err).Msgf("could not copy repository metadata of channel %s", channelLabel)
+	}
+	return true
+}
@@ -0,0 +1,25 @@
+package packageDumper
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHasRepodata(t *testing.T) {
+	rpmFolder := t.TempDir()
+	debFolder := t.TempDir()
+	emptyFolder := t.TempDir()
+	os.WriteFile(filepath.Join(rpmFolder, "repomd.xml"), []byte("<repomd/>"), 0644)
+	os.WriteFile(filepath.Join(debFolder, "Release"), []byte("Origin: test"), 0644)
+
+	if !HasRepodata(rpmFolder) {
+		t.Error("rpm repository metadata should be found")
+	}
+	if !HasRepodata(debFolder) {
+		t.Error("Debian repository metadata should be found")
+	}
+	if HasRepodata(emptyFolder) {
+		t.Error("folders without index file have no repository metadata")
+	}
+}
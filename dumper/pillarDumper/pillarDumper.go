@@ -9,12 +9,13 @@ import (
 
 	"github.com/rs/zerolog/log"
 	"github.com/uyuni-project/inter-server-sync/dumper"
+	"github.com/uyuni-project/inter-server-sync/placeholders"
 	"github.com/uyuni-project/inter-server-sync/schemareader"
 	"github.com/uyuni-project/inter-server-sync/utils"
 )
 
 var serverDataDir = "/srv/susemanager/pillar_data/"
-var replacePattern = "{SERVER_FQDN}"
+var replacePattern = placeholders.Format(placeholders.ServerFQDN)
 
 func DumpImagePillars(outputDir string, orgIds []uint, serverConfig string) {
 	log.Debug().Msgf("Dumping pillars to %s", outputDir)
@@ -23,7 +24,7 @@ func DumpImagePillars(outputDir string, orgIds []uint, serverConfig string) {
 	sourceDir := filepath.Join(serverDataDir, "images")
 	orgDir, err := os.Open(sourceDir)
 	if err != nil {
-		log.Fatal().Err(err)
+		utils.Fatal().Err(err)
 	}
 	defer orgDir.Close()
 	orgDirInfo, err := orgDir.ReadDir(-1)
@@ -48,7 +49,7 @@ func DumpPillars(sourceDir, outputDir, sourceFQDN, targetFQDN string) {
 
 	pillarDir, err := os.Open(sourceDir)
 	if err != nil {
-		log.Fatal().Err(err)
+		utils.Fatal().Err(err)
 	}
 	defer pillarDir.Close()
 	pillarDirInfo, err := pillarDir.ReadDir(-1)
@@ -63,7 +64,7 @@ func DumpPillars(sourceDir, outputDir, sourceFQDN, targetFQDN string) {
 				pillarTargetPath,
 				sourceFQDN, targetFQDN)
 			if err != nil {
-				log.Fatal().Err(err)
+				utils.Fatal().Err(err)
 			}
 			os.Chmod(pillarTargetPath, 0640)
 			cmd := exec.Command("chown", "salt:susemanager", pillarTargetPath)
@@ -71,7 +72,7 @@ func DumpPillars(sourceDir, outputDir, sourceFQDN, targetFQDN string) {
 			cmd.Stderr = os.Stderr
 			err = cmd.Run()
 			if err != nil {
-				log.Fatal().Err(err).Msg("Error processing image pillar files")
+				utils.Fatal().Err(err).Msg("Error processing image pillar files")
 			}
 		}
 	}
@@ -83,7 +84,7 @@ func ImportImagePillars(sourceDir string, fqdn string) {
 	log.Debug().Msgf("Importing image pillars from %s", sourceDir)
 	orgDir, err := os.Open(sourceDir)
 	if err != nil {
-		log.Fatal().Err(err)
+		utils.Fatal().Err(err)
 	}
 	defer orgDir.Close()
 	orgDirInfo, err := orgDir.ReadDir(-1)
@@ -98,7 +99,7 @@ func ImportImagePillars(sourceDir string, fqdn string) {
 			cmd.Stderr = os.Stderr
 			err = cmd.Run()
 			if err != nil {
-				log.Fatal().Err(err).Msg("Error importing image pillar files")
+				utils.Fatal().Err(err).Msg("Error importing image pillar files")
 
 			}
 		}
@@ -112,29 +113,30 @@ func UpdateImagePillars(serverConfig string) {
 
 	checkQuery := "SELECT EXISTS (SELECT FROM pg_tables WHERE schemaname = 'public' AND tablename = 'susesaltpillar')"
 	db := schemareader.GetDBconnection(serverConfig)
-	rows, err := db.Query(checkQuery)
+	rows, err := db.QueryContext(utils.Context(), checkQuery)
 	if err != nil {
-		log.Fatal().Err(err).Msgf("Error while executing '%s'", checkQuery)
+		utils.Fatal().Err(err).Msgf("Error while executing '%s'", checkQuery)
 	}
 	if !rows.Next() {
-		log.Fatal().Msgf("No return on pillar database table check")
+		utils.Fatal().Msgf("No return on pillar database table check")
 	}
 	var hasPillars bool
 	err = rows.Scan(&hasPillars)
 	if err != nil {
-		log.Fatal().Err(err).Msgf("Unexpected query result")
+		utils.Fatal().Err(err).Msgf("Unexpected query result")
 	}
 	if !hasPillars {
 		log.Debug().Msgf("Pillars not backed by database")
 		return
 	}
 
-	sqlQuery := fmt.Sprintf("UPDATE susesaltpillar SET pillar = REPLACE(pillar::text, '%s', '%s')::jsonb WHERE category LIKE 'Image%%';",
-		replacePattern, fqdn)
+	// pillar rewrite rules can set the placeholder in pillars of any category
+	sqlQuery := fmt.Sprintf("UPDATE susesaltpillar SET pillar = REPLACE(pillar::text, '%s', '%s')::jsonb WHERE pillar::text LIKE '%%%s%%';",
+		replacePattern, fqdn, replacePattern)
 	log.Trace().Msgf("Updating pillar files using query '%s'", sqlQuery)
-	log.Info().Msg("Updating image pillars if needed")
-	rows, err = db.Query(sqlQuery)
+	log.Info().Msg("Updating pillars if needed")
+	rows, err = db.QueryContext(utils.Context(), sqlQuery)
 	if err != nil {
-		log.Fatal().Err(err).Msgf("Error updating image pillars")
+		utils.Fatal().Err(err).Msgf("Error updating image pillars")
 	}
 }
@@ -0,0 +1,46 @@
+package dumper
+
+import (
+	"github.com/uyuni-project/inter-server-sync/placeholders"
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+)
+
+// values of the source server replaced with placeholders in the exported text values, indexed by token
+var placeholderValues map[string]string
+
+// columns whose values are replaced with placeholders
+var placeholderColumns placeholders.ColumnSet
+
+// SetPlaceholders sets the values replaced with placeholders in the columns of the statements written afterwards
+func SetPlaceholders(values map[string]string, columns placeholders.ColumnSet) {
+	placeholderValues = values
+	placeholderColumns = columns
+}
+
+// placeholderField returns the column value of the table with the values replaced by their placeholders,
+// if the column can contain placeholders
+func placeholderField(table schemareader.Table, column sqlUtil.RowDataStructure) sqlUtil.RowDataStructure {
+	if len(placeholderValues) == 0 || column.Value == nil || !placeholderColumns.Contains(table.Name, column.ColumnName) {
+		return column
+	}
+	switch column.ColumnType {
+	case "NUMERIC", "INT2", "INT4", "INT8", "FLOAT4", "FLOAT8", "BOOL", "TIMESTAMPTZ", "TIMESTAMP", "DATE", "BYTEA",
+		LargeObjectType, "SQL":
+		return column
+	}
+	column.Value = placeholders.Replace(formatValue(column.Value), placeholderValues)
+	return column
+}
+
+// placeholderRow returns a copy of the row with the values of the placeholder columns replaced by their placeholders
+func placeholderRow(table schemareader.Table, row []sqlUtil.RowDataStructure) []sqlUtil.RowDataStructure {
+	if len(placeholderValues) == 0 {
+		return row
+	}
+	result := make([]sqlUtil.RowDataStructure, len(row))
+	for i, column := range row {
+		result[i] = placeholderField(table, column)
+	}
+	return result
+}
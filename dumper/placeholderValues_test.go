@@ -0,0 +1,36 @@
+package dumper
+
+import (
+	"testing"
+
+	"github.com/uyuni-project/inter-server-sync/placeholders"
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+)
+
+func TestPlaceholderRow(t *testing.T) {
+	// Arrange
+	columns, _ := placeholders.NewColumnSet(nil)
+	SetPlaceholders(map[string]string{placeholders.ServerFQDN: "hub.example.com"}, columns)
+	defer ResetExportState()
+
+	table := schemareader.Table{Name: "rhncontentsource"}
+	row := []sqlUtil.RowDataStructure{
+		{ColumnName: "label", ColumnType: "VARCHAR", Value: "hub.example.com repo"},
+		{ColumnName: "source_url", ColumnType: "VARCHAR", Value: "https://hub.example.com/repo"},
+	}
+
+	// Act
+	result := placeholderRow(table, row)
+
+	// Assert
+	if result[0].Value != "hub.example.com repo" {
+		t.Errorf("label is not a placeholder column, got %v", result[0].Value)
+	}
+	if result[1].Value != "https://{SERVER_FQDN}/repo" {
+		t.Errorf("source_url should have placeholders, got %v", result[1].Value)
+	}
+	if row[1].Value != "https://hub.example.com/repo" {
+		t.Errorf("original row should not be modified, got %v", row[1].Value)
+	}
+}
@@ -0,0 +1,59 @@
+package dumper
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+)
+
+// capabilities provided by the master package of a PTF (Program Temporary Fix), and by the packages which are part of it.
+// The target server recognizes PTF packages by them, so they are exported with rhnpackageprovides.
+const (
+	ptfMasterCapability  = "ptf()"
+	ptfPackageCapability = "ptf-package()"
+)
+
+// CheckPtfPackages logs the PTF packages of the exported rows, and warns when PTF master packages are exported
+// without the packages which are part of them or the other way around, which makes the PTF uninstallable on the target
+func CheckPtfPackages(db *sql.DB, data DataDumper, channelLabel string) {
+	packages, ok := data.TableData["rhnpackage"]
+	if !ok || len(packages.Keys) == 0 {
+		return
+	}
+	ids := make([]string, 0, len(packages.Keys))
+	for _, key := range packages.Keys {
+		for _, column := range key.Key {
+			if column.Column == "id" {
+				ids = append(ids, column.Value)
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	masters, parts := countPtfPackages(db, ids)
+	if masters == 0 && parts == 0 {
+		return
+	}
+	log.Info().Msgf("Channel %s: exporting %d PTFs with %d packages", channelLabel, masters, parts)
+	if masters == 0 || parts == 0 {
+		log.Warn().Msgf("Channel %s: PTF master packages and the packages which are part of PTFs are not exported together, check the filters of the export", channelLabel)
+	}
+}
+
+// countPtfPackages returns the number of PTF master packages and of packages part of PTFs among the packages
+func countPtfPackages(db *sql.DB, packageIds []string) (int, int) {
+	query := fmt.Sprintf(`SELECT rhnpackagecapability.name, count(DISTINCT rhnpackageprovides.package_id) FROM rhnpackageprovides
+		INNER JOIN rhnpackagecapability ON rhnpackagecapability.id = rhnpackageprovides.capability_id
+		WHERE rhnpackagecapability.name IN ('%s', '%s') AND rhnpackageprovides.package_id IN (%s)
+		GROUP BY rhnpackagecapability.name;`, ptfMasterCapability, ptfPackageCapability, strings.Join(packageIds, ","))
+	counts := make(map[string]int)
+	for _, row := range sqlUtil.ExecuteQueryWithResults(db, query) {
+		counts[formatValue(row[0].Value)], _ = strconv.Atoi(formatValue(row[1].Value))
+	}
+	return counts[ptfMasterCapability], counts[ptfPackageCapability]
+}
@@ -0,0 +1,24 @@
+package dumper
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestCountPtfPackages(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	mock.ExpectQuery(regexp.QuoteMeta("rhnpackageprovides.package_id IN (1::bigint,2::bigint,3::bigint)")).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "count"}).AddRow("ptf()", "1").AddRow("ptf-package()", "2"))
+
+	masters, parts := countPtfPackages(db, []string{"1::bigint", "2::bigint", "3::bigint"})
+
+	if masters != 1 || parts != 2 {
+		t.Errorf("expected 1 PTF with 2 packages, got %d with %d", masters, parts)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,193 @@
+package dumper
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// maximum number of referenced values shown for every uncovered reference
+const uncoveredReferenceSamples = 5
+
+// UncoveredReference is a foreign key of exported rows to rows which are neither exported nor vendor data, so
+// they must exist on the target for the import to resolve the reference
+type UncoveredReference struct {
+	Table           string   `json:"table"`
+	Columns         []string `json:"columns"`
+	ReferencedTable string   `json:"referencedTable"`
+	// exported rows with the reference, and values of the first referenced rows
+	Rows    int      `json:"rows"`
+	Samples []string `json:"samples"`
+}
+
+// referenceCheck tracks the references of the written rows to the rows written before or after them
+type referenceCheck struct {
+	// written rows, by table, referenced columns and values
+	covered map[string]bool
+	// rows referenced by written rows, by table, referenced columns and values, with the references to them
+	referenced map[string][]*UncoveredReference
+	references map[string]*UncoveredReference
+	// column sets of every table referenced by foreign keys, by table
+	referencedColumns map[string][][]string
+}
+
+// nil unless the export verifies the references
+var references *referenceCheck
+
+// EnableReferenceCheck tracks the references of the written rows, reported by ReportUncoveredReferences
+func EnableReferenceCheck() {
+	references = &referenceCheck{covered: make(map[string]bool), referenced: make(map[string][]*UncoveredReference),
+		references: make(map[string]*UncoveredReference), referencedColumns: make(map[string][][]string)}
+}
+
+// formatReferencedRow returns the key of a referenced row, the columns sorted by name
+func formatReferencedRow(tableName string, values map[string]interface{}) (string, string) {
+	columns := make([]string, 0, len(values))
+	for column := range values {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	formattedValues := make([]string, 0, len(columns))
+	for _, column := range columns {
+		formattedValues = append(formattedValues, column+"="+formatValue(values[column]))
+	}
+	value := strings.Join(formattedValues, ", ")
+	return tableName + "|" + value, value
+}
+
+// recordReferences records the written row, and the rows it references. It takes the values as read from the
+// source database, before the references are substituted.
+func recordReferences(table schemareader.Table, tables map[string]schemareader.Table, row []sqlUtil.RowDataStructure) {
+	if references == nil {
+		return
+	}
+	values := make(map[string]interface{}, len(row))
+	for _, column := range row {
+		values[column.ColumnName] = column.Value
+	}
+	for _, columns := range references.columnsReferencedIn(table, tables) {
+		referencedValues := make(map[string]interface{}, len(columns))
+		for _, column := range columns {
+			referencedValues[column] = values[column]
+		}
+		key, _ := formatReferencedRow(table.Name, referencedValues)
+		references.covered[key] = true
+	}
+	for _, reference := range table.References {
+		if IsVendorTable(reference.TableName) {
+			continue
+		}
+		localColumns := sortedColumnMapping(reference.ColumnMapping)
+		referencedValues := make(map[string]interface{}, len(localColumns))
+		for _, localColumn := range localColumns {
+			if values[localColumn] == nil {
+				referencedValues = nil
+				break
+			}
+			referencedValues[reference.ColumnMapping[localColumn]] = values[localColumn]
+		}
+		if referencedValues == nil {
+			continue
+		}
+		referenceKey := table.Name + "|" + strings.Join(localColumns, ",") + "|" + reference.TableName
+		uncovered, ok := references.references[referenceKey]
+		if !ok {
+			uncovered = &UncoveredReference{Table: table.Name, Columns: localColumns, ReferencedTable: reference.TableName}
+			references.references[referenceKey] = uncovered
+		}
+		key, _ := formatReferencedRow(reference.TableName, referencedValues)
+		references.referenced[key] = append(references.referenced[key], uncovered)
+	}
+}
+
+// columnsReferencedIn returns the column sets of the table referenced by the foreign keys of the tables
+func (check *referenceCheck) columnsReferencedIn(table schemareader.Table, tables map[string]schemareader.Table) [][]string {
+	if columns, ok := check.referencedColumns[table.Name]; ok {
+		return columns
+	}
+	columnSets := make(map[string][]string)
+	for _, referencingTable := range tables {
+		for _, reference := range referencingTable.References {
+			if reference.TableName != table.Name {
+				continue
+			}
+			columns := make([]string, 0, len(reference.ColumnMapping))
+			for _, column := range reference.ColumnMapping {
+				columns = append(columns, column)
+			}
+			sort.Strings(columns)
+			columnSets[strings.Join(columns, ",")] = columns
+		}
+	}
+	result := make([][]string, 0, len(columnSets))
+	for _, columns := range columnSets {
+		result = append(result, columns)
+	}
+	check.referencedColumns[table.Name] = result
+	return result
+}
+
+// UncoveredReferences returns the references of the written rows to rows which were not written, by table and columns
+func UncoveredReferences() []UncoveredReference {
+	if references == nil {
+		return nil
+	}
+	found := make(map[*UncoveredReference]*UncoveredReference)
+	for key, referencing := range references.referenced {
+		if references.covered[key] {
+			continue
+		}
+		value := key[strings.Index(key, "|")+1:]
+		for _, reference := range referencing {
+			uncovered, ok := found[reference]
+			if !ok {
+				uncovered = &UncoveredReference{Table: reference.Table, Columns: reference.Columns, ReferencedTable: reference.ReferencedTable}
+				found[reference] = uncovered
+			}
+			uncovered.Rows++
+			if len(uncovered.Samples) < uncoveredReferenceSamples && !utils.Contains(uncovered.Samples, value) {
+				uncovered.Samples = append(uncovered.Samples, value)
+			}
+		}
+	}
+	result := make([]UncoveredReference, 0, len(found))
+	for _, uncovered := range found {
+		sort.Strings(uncovered.Samples)
+		result = append(result, *uncovered)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Table != result[j].Table {
+			return result[i].Table < result[j].Table
+		}
+		return strings.Join(result[i].Columns, ",") < strings.Join(result[j].Columns, ",")
+	})
+	return result
+}
+
+// ReportUncoveredReferences prints the references of the written rows to rows which are neither exported nor vendor
+// data, returning their number. The import fails or silently loses the reference if the target lacks those rows.
+func ReportUncoveredReferences() int {
+	uncovered := UncoveredReferences()
+	if references == nil {
+		return 0
+	}
+	if len(uncovered) == 0 {
+		log.Info().Msg("All references of the exported rows are exported or vendor data")
+		return 0
+	}
+	var report strings.Builder
+	report.WriteString(fmt.Sprintf("%-60s %-30s %8s  %s\n", "Reference", "Referenced table", "Rows", "Referenced rows"))
+	for _, reference := range uncovered {
+		report.WriteString(fmt.Sprintf("%-60s %-30s %8d  %s\n", reference.Table+"."+strings.Join(reference.Columns, ","),
+			reference.ReferencedTable, reference.Rows, strings.Join(reference.Samples, "; ")))
+	}
+	report.WriteString(fmt.Sprintf("%d references to rows neither exported nor vendor data, which must exist on the target", len(uncovered)))
+	fmt.Println(report.String())
+	log.Warn().Msgf("%d references of the exported rows point to rows which are neither exported nor vendor data", len(uncovered))
+	return len(uncovered)
+}
@@ -0,0 +1,73 @@
+package dumper
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+)
+
+func referenceCheckSchema() map[string]schemareader.Table {
+	channel := schemareader.Table{
+		Name:    "rhnchannel",
+		Columns: []string{"id", "parent_channel", "org_id", "channel_arch_id"},
+		References: []schemareader.Reference{
+			{TableName: "rhnchannel", ColumnMapping: map[string]string{"parent_channel": "id"}},
+			{TableName: "web_customer", ColumnMapping: map[string]string{"org_id": "id"}},
+			{TableName: "rhnchannelarch", ColumnMapping: map[string]string{"channel_arch_id": "id"}},
+		},
+	}
+	return map[string]schemareader.Table{
+		"rhnchannel":     channel,
+		"web_customer":   {Name: "web_customer", Columns: []string{"id", "name"}},
+		"rhnchannelarch": {Name: "rhnchannelarch", Columns: []string{"id", "label"}},
+	}
+}
+
+func referenceCheckRow(id int64, parent interface{}, org interface{}) []sqlUtil.RowDataStructure {
+	return []sqlUtil.RowDataStructure{
+		{ColumnName: "id", Value: id},
+		{ColumnName: "parent_channel", Value: parent},
+		{ColumnName: "org_id", Value: org},
+		{ColumnName: "channel_arch_id", Value: int64(500)},
+	}
+}
+
+func TestUncoveredReferences(t *testing.T) {
+	// 01 Arrange
+	defer ResetExportState()
+	EnableReferenceCheck()
+	schema := referenceCheckSchema()
+
+	// 02 Act
+	// the clone is written before its original, which is written later in the export
+	recordReferences(schema["rhnchannel"], schema, referenceCheckRow(2, int64(1), nil))
+	recordReferences(schema["rhnchannel"], schema, referenceCheckRow(1, nil, nil))
+	recordReferences(schema["rhnchannel"], schema, referenceCheckRow(3, int64(99), int64(5)))
+	recordReferences(schema["rhnchannel"], schema, referenceCheckRow(4, nil, int64(5)))
+	result := UncoveredReferences()
+
+	// 03 Assert
+	expected := []UncoveredReference{
+		{Table: "rhnchannel", Columns: []string{"org_id"}, ReferencedTable: "web_customer", Rows: 2, Samples: []string{"id=5"}},
+		{Table: "rhnchannel", Columns: []string{"parent_channel"}, ReferencedTable: "rhnchannel", Rows: 1, Samples: []string{"id=99"}},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected uncovered references %v, but got %v", expected, result)
+	}
+}
+
+func TestReferenceCheckDisabled(t *testing.T) {
+	// 01 Arrange
+	ResetExportState()
+	schema := referenceCheckSchema()
+
+	// 02 Act
+	recordReferences(schema["rhnchannel"], schema, referenceCheckRow(3, int64(99), int64(5)))
+
+	// 03 Assert
+	if result := UncoveredReferences(); result != nil {
+		t.Errorf("References are recorded without reference check: %v", result)
+	}
+}
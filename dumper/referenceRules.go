@@ -0,0 +1,95 @@
+package dumper
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// ReferenceRule limits how far the crawler follows a foreign key, in both directions: from the rows of Table to
+// the referenced rows, and from the referenced rows to the rows of Table referencing them
+type ReferenceRule struct {
+	Table string `json:"table"`
+	// columns of Table in the foreign key, any foreign key of Table to ReferencedTable when empty
+	Columns         []string `json:"columns,omitempty"`
+	ReferencedTable string   `json:"referencedTable"`
+	// number of references followed along the foreign key and from the rows reached through it. 0 never
+	// follows the foreign key, 1 exports the rows reached through it but doesn't follow their references
+	Levels int `json:"levels"`
+}
+
+func (rule ReferenceRule) String() string {
+	columns := ""
+	if len(rule.Columns) > 0 {
+		columns = "(" + strings.Join(rule.Columns, ", ") + ")"
+	}
+	return fmt.Sprintf("%s%s -> %s", rule.Table, columns, rule.ReferencedTable)
+}
+
+// ReadReferenceRules reads a JSON file with a list of rules
+func ReadReferenceRules(path string) []ReferenceRule {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		utils.Fatal().Err(err).Int(utils.ExitCodeField, utils.ExitConfigError).Msgf("Error reading reference rules %s", path)
+	}
+	rules := make([]ReferenceRule, 0)
+	if err := json.Unmarshal(content, &rules); err != nil {
+		utils.Fatal().Err(err).Int(utils.ExitCodeField, utils.ExitConfigError).Msgf("Error parsing reference rules %s", path)
+	}
+	for i, rule := range rules {
+		if len(rule.Table) == 0 || len(rule.ReferencedTable) == 0 || rule.Levels < 0 {
+			utils.Fatal().Int(utils.ExitCodeField, utils.ExitConfigError).
+				Msgf("Invalid reference rule %s: the table and referenced table are needed, and the levels cannot be negative", rule)
+		}
+		rules[i].Table = strings.ToLower(rule.Table)
+		rules[i].ReferencedTable = strings.ToLower(rule.ReferencedTable)
+		columns := make([]string, 0, len(rule.Columns))
+		for _, column := range rule.Columns {
+			columns = append(columns, strings.ToLower(column))
+		}
+		sort.Strings(columns)
+		rules[i].Columns = columns
+	}
+	return rules
+}
+
+// matchReferenceRule returns the first rule of the foreign key of the table to the referenced table, with the
+// column mapping of the foreign key
+func matchReferenceRule(rules []ReferenceRule, tableName string, columnMapping map[string]string, referencedTableName string) (ReferenceRule, bool) {
+	for _, rule := range rules {
+		if rule.Table != tableName || rule.ReferencedTable != referencedTableName {
+			continue
+		}
+		if len(rule.Columns) == 0 || strings.Join(rule.Columns, ",") == strings.Join(sortedColumnMapping(columnMapping), ",") {
+			return rule, true
+		}
+	}
+	return ReferenceRule{}, false
+}
+
+// followLevels returns whether the crawler follows the foreign key from the row, and the levels left to the rows
+// reached through it, -1 when unlimited
+func followLevels(options CrawlerOptions, row processItem, tableName string, columnMapping map[string]string,
+	referencedTableName string, pruned map[string]string, reachedTableName string) (int, bool) {
+
+	levels := -1
+	if row.levels > 0 {
+		levels = row.levels - 1
+	}
+	rule, ok := matchReferenceRule(options.ReferenceRules, tableName, columnMapping, referencedTableName)
+	if !ok {
+		return levels, true
+	}
+	if rule.Levels == 0 {
+		pruned[reachedTableName] = "reference-rule " + rule.String()
+		return 0, false
+	}
+	if levels < 0 || rule.Levels-1 < levels {
+		levels = rule.Levels - 1
+	}
+	return levels, true
+}
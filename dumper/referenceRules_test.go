@@ -0,0 +1,56 @@
+package dumper
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadReferenceRules(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "rules.json")
+	os.WriteFile(path, []byte(`[{"table": "suseImageProfile", "columns": ["token_id"], "referencedTable": "rhnActivationKey"},
+		{"table": "rhnchannel", "referencedTable": "rhnchannel", "levels": 2}]`), 0644)
+
+	// Act
+	rules := ReadReferenceRules(path)
+
+	// Assert
+	expected := []ReferenceRule{
+		{Table: "suseimageprofile", Columns: []string{"token_id"}, ReferencedTable: "rhnactivationkey"},
+		{Table: "rhnchannel", Columns: []string{}, ReferencedTable: "rhnchannel", Levels: 2},
+	}
+	if !reflect.DeepEqual(rules, expected) {
+		t.Errorf("Expected rules %v, got %v", expected, rules)
+	}
+}
+
+func TestFollowLevels(t *testing.T) {
+	// Arrange
+	options := CrawlerOptions{ReferenceRules: []ReferenceRule{
+		{Table: "suseimageprofile", Columns: []string{"token_id"}, ReferencedTable: "rhnactivationkey"},
+		{Table: "rhnchannel", ReferencedTable: "rhnchannel", Levels: 2},
+	}}
+	unlimited := processItem{tableName: "rhnchannel", levels: -1}
+	limited := processItem{tableName: "rhnchannel", levels: 1}
+	pruned := make(map[string]string)
+
+	// Act
+	_, followToken := followLevels(options, unlimited, "suseimageprofile", map[string]string{"token_id": "reg_token_id"}, "rhnactivationkey", pruned, "rhnactivationkey")
+	_, followOther := followLevels(options, unlimited, "suseimageprofile", map[string]string{"other_id": "id"}, "rhnactivationkey", pruned, "rhnactivationkey")
+	parentLevels, followParent := followLevels(options, unlimited, "rhnchannel", map[string]string{"parent_channel": "id"}, "rhnchannel", pruned, "rhnchannel")
+	limitedLevels, _ := followLevels(options, limited, "rhnchannel", map[string]string{"parent_channel": "id"}, "rhnchannel", pruned, "rhnchannel")
+	unruledLevels, _ := followLevels(options, unlimited, "rhnchannel", map[string]string{"org_id": "id"}, "web_customer", pruned, "web_customer")
+
+	// Assert
+	if followToken || pruned["rhnactivationkey"] != "reference-rule suseimageprofile(token_id) -> rhnactivationkey" {
+		t.Errorf("Expected the token reference not to be followed, got %t %v", followToken, pruned)
+	}
+	if !followOther {
+		t.Errorf("Rules with columns must only match the foreign key of these columns")
+	}
+	if !followParent || parentLevels != 1 || limitedLevels != 0 || unruledLevels != -1 {
+		t.Errorf("Unexpected levels %t %d %d %d", followParent, parentLevels, limitedLevels, unruledLevels)
+	}
+}
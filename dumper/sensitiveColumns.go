@@ -0,0 +1,77 @@
+package dumper
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+)
+
+// RedactedValue replaces the values of sensitive columns in the logged statements
+const RedactedValue = "<redacted>"
+
+// columns holding credentials, in the format 'table.column', or 'column' for any table
+var defaultSensitiveColumns = []string{
+	"password",
+	"secret",
+	"token",
+	"web_contact.password",
+	"susecredentials.password",
+	"susecredentials.username",
+	"rhncryptokey.key",
+	"rhnserver.secret",
+}
+
+var sensitiveColumns = make(map[string]bool)
+
+func init() {
+	resetSensitiveColumns()
+}
+
+func resetSensitiveColumns() {
+	sensitiveColumns = make(map[string]bool)
+	AddSensitiveColumns(defaultSensitiveColumns)
+}
+
+// AddSensitiveColumns adds columns, in the format 'table.column' or 'column', whose values are never logged
+func AddSensitiveColumns(columns []string) {
+	for _, column := range columns {
+		sensitiveColumns[strings.ToLower(strings.TrimSpace(column))] = true
+	}
+}
+
+func isSensitiveColumn(tableName string, columnName string) bool {
+	return sensitiveColumns[columnName] || sensitiveColumns[tableName+"."+columnName]
+}
+
+// redactRowValues returns a copy of the row with the values of sensitive columns masked.
+// Large bytea values are summarized, so they are neither logged nor streamed
+func redactRowValues(table schemareader.Table, row []sqlUtil.RowDataStructure) []sqlUtil.RowDataStructure {
+	result := make([]sqlUtil.RowDataStructure, len(row))
+	for i, col := range row {
+		result[i] = col
+		if col.Value == nil || col.ColumnType == "SQL" {
+			continue
+		}
+		if isSensitiveColumn(table.Name, col.ColumnName) {
+			result[i].ColumnType = "TEXT"
+			result[i].Value = RedactedValue
+		} else if bytes, ok := col.Value.([]byte); ok && (col.ColumnType == "BYTEA" || col.ColumnType == LargeObjectType) &&
+			len(bytes) > streamedByteaThreshold {
+			result[i].ColumnType = "TEXT"
+			result[i].Value = fmt.Sprintf("<%d bytes>", len(bytes))
+		}
+	}
+	return result
+}
+
+// traceRowInsertStatement logs the insert statement of the row with the sensitive values masked
+func traceRowInsertStatement(table schemareader.Table, rowValues []sqlUtil.RowDataStructure, onlyIfParentExistsTables []string) {
+	event := log.Trace()
+	if !event.Enabled() {
+		return
+	}
+	event.Msg(formatRowInsertStatement(table, redactRowValues(table, rowValues), onlyIfParentExistsTables).text)
+}
@@ -0,0 +1,68 @@
+package dumper
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+)
+
+func TestRedactRowValues(t *testing.T) {
+	table := schemareader.Table{Name: "susecredentials"}
+	row := []sqlUtil.RowDataStructure{
+		{ColumnName: "id", ColumnType: "INT8", Value: 1},
+		{ColumnName: "username", ColumnType: "VARCHAR", Value: "admin"},
+		{ColumnName: "password", ColumnType: "VARCHAR", Value: "s3cr3t"},
+		{ColumnName: "url", ColumnType: "VARCHAR", Value: nil},
+	}
+
+	redacted := redactRowValues(table, row)
+
+	if redacted[0].Value != 1 {
+		t.Errorf("id should not be redacted, got %v", redacted[0].Value)
+	}
+	for _, i := range []int{1, 2} {
+		if redacted[i].Value != RedactedValue {
+			t.Errorf("%s should be redacted, got %v", row[i].ColumnName, redacted[i].Value)
+		}
+	}
+	if redacted[3].Value != nil {
+		t.Errorf("null values should stay null, got %v", redacted[3].Value)
+	}
+	if row[2].Value != "s3cr3t" {
+		t.Errorf("original row should not be modified, got %v", row[2].Value)
+	}
+}
+
+func TestAddSensitiveColumns(t *testing.T) {
+	AddSensitiveColumns([]string{" rhnServer.Description "})
+	defer delete(sensitiveColumns, "rhnserver.description")
+
+	row := []sqlUtil.RowDataStructure{
+		{ColumnName: "description", ColumnType: "VARCHAR", Value: "internal host"},
+	}
+	if redactRowValues(schemareader.Table{Name: "rhnserver"}, row)[0].Value != RedactedValue {
+		t.Error("configured column should be redacted")
+	}
+	if redactRowValues(schemareader.Table{Name: "rhnchannel"}, row)[0].Value == RedactedValue {
+		t.Error("configured column should only be redacted in its table")
+	}
+}
+
+func TestRedactLargeBytea(t *testing.T) {
+	row := []sqlUtil.RowDataStructure{
+		{ColumnName: "content", ColumnType: "BYTEA", Value: make([]byte, streamedByteaThreshold+1)},
+	}
+
+	redacted := redactRowValues(schemareader.Table{Name: "rhnconfigcontent"}, row)
+
+	if !strings.HasPrefix(formatField(redacted[0]), "'<") {
+		t.Errorf("large bytea values should be summarized, got %s", formatField(redacted[0])[:10])
+	}
+	fields := &streamedFields{}
+	fields.formatExportField(redacted[0])
+	if len(fields.values) != 0 {
+		t.Error("summarized values should not be streamed")
+	}
+}
@@ -0,0 +1,72 @@
+package dumper
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+var statementTablePattern = regexp.MustCompile(`^(?:INSERT INTO|DELETE FROM|UPDATE) ([a-z0-9_]+)`)
+
+// StatementTable returns the table changed by the exported statement starting on the line, if any
+func StatementTable(line string) (string, bool) {
+	match := statementTablePattern.FindStringSubmatch(strings.TrimLeft(line, " \t\n"))
+	if match == nil {
+		return "", false
+	}
+	return match[1], true
+}
+
+// IsDeleteStatement checks if the exported statement starting on the line deletes rows
+func IsDeleteStatement(line string) bool {
+	return strings.HasPrefix(strings.TrimLeft(line, " \t\n"), "DELETE FROM ")
+}
+
+// SqlScript reads a SQL file of the export, decompressing it if needed
+type SqlScript struct {
+	file   *os.File
+	reader io.Reader
+}
+
+// OpenSqlScript opens a SQL file of an export, compressed or not
+func OpenSqlScript(sqlFile string) *SqlScript {
+	file, err := os.Open(sqlFile)
+	if err != nil {
+		utils.Fatal().Err(err).Msg("Error opening the SQL script")
+	}
+	script := &SqlScript{file: file, reader: file}
+	if strings.HasSuffix(sqlFile, ".gz") {
+		gzipReader, err := gzip.NewReader(file)
+		if err != nil {
+			utils.Fatal().Err(err).Msg("Error reading the SQL/GZ script")
+		}
+		script.reader = gzipReader
+	}
+	return script
+}
+
+// ForEachLine calls the function for every line of the script, including the line break
+func (script *SqlScript) ForEachLine(lineFunc func(line string)) {
+	bufferReader := bufio.NewReaderSize(script.reader, 32768)
+	for {
+		line, err := bufferReader.ReadString('\n')
+		if len(line) > 0 {
+			lineFunc(line)
+		}
+		if err != nil {
+			if err != io.EOF {
+				utils.Fatal().Err(err).Msg("Error reading the SQL script")
+			}
+			return
+		}
+	}
+}
+
+func (script *SqlScript) Close() {
+	script.file.Close()
+}
@@ -0,0 +1,156 @@
+package dumper
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// number of runs the write duration of a row is averaged over, so the estimates follow the changes of the server
+const statisticsAveragedRuns = 5
+
+// TableStatistics are the rows and write durations of a table recorded by the previous exports
+type TableStatistics struct {
+	Runs     int `json:"runs"`
+	LastRows int `json:"lastRows"`
+	// moving average of the time spent reading and writing one row
+	RowDuration time.Duration `json:"rowDuration"`
+	Updated     time.Time     `json:"updated"`
+}
+
+// StatisticsStore collects the statistics of the exported tables across runs. They order the traversal of the
+// tables and estimate the duration of the next exports.
+type StatisticsStore struct {
+	path   string
+	Tables map[string]TableStatistics `json:"tables"`
+}
+
+// tables exported by the previous exports by their last rows, nil without statistics store
+var tableSizes map[string]int
+
+// ReadStatisticsStore reads the statistics of the previous exports, empty when the file does not exist yet.
+// The statistics only tune the export, a corrupted file is started over.
+func ReadStatisticsStore(path string) *StatisticsStore {
+	store := &StatisticsStore{path: path, Tables: make(map[string]TableStatistics)}
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store
+	}
+	if err != nil {
+		utils.Fatal().Err(err).Msgf("Error reading the statistics store %s", path)
+	}
+	if err := json.Unmarshal(content, store); err != nil || store.Tables == nil {
+		log.Warn().Err(err).Msgf("Statistics store %s is corrupted, the statistics are collected again", path)
+		store.Tables = make(map[string]TableStatistics)
+	}
+	return store
+}
+
+// Record adds the statistics of the tables written by an export
+func (store *StatisticsStore) Record(statistics map[string]TableWriteStatistics, now time.Time) {
+	for tableName, written := range statistics {
+		tableStatistics := store.Tables[tableName]
+		tableStatistics.Runs++
+		tableStatistics.LastRows = written.Rows
+		tableStatistics.Updated = now
+		if written.Rows > 0 {
+			rowDuration := written.Duration / time.Duration(written.Rows)
+			runs := tableStatistics.Runs
+			if runs > statisticsAveragedRuns {
+				runs = statisticsAveragedRuns
+			}
+			tableStatistics.RowDuration += (rowDuration - tableStatistics.RowDuration) / time.Duration(runs)
+		}
+		store.Tables[tableName] = tableStatistics
+	}
+}
+
+// Save writes the store, replacing the previous file only once it is complete
+func (store *StatisticsStore) Save() {
+	content, err := json.Marshal(store)
+	if err != nil {
+		utils.Fatal().Err(err).Msg("Error encoding the statistics store")
+	}
+	temporaryPath := store.path + ".tmp"
+	if err := os.WriteFile(temporaryPath, content, 0600); err != nil {
+		utils.Fatal().Err(err).Msgf("Error writing the statistics store %s", store.path)
+	}
+	if err := os.Rename(temporaryPath, store.path); err != nil {
+		utils.Fatal().Err(err).Msgf("Error writing the statistics store %s", store.path)
+	}
+}
+
+// EstimateDuration returns the time writing the rows of the table is estimated to take, false when the table
+// has no statistics
+func (store *StatisticsStore) EstimateDuration(tableName string, rows int) (time.Duration, bool) {
+	tableStatistics, ok := store.Tables[tableName]
+	if !ok || tableStatistics.RowDuration <= 0 {
+		return 0, false
+	}
+	return tableStatistics.RowDuration * time.Duration(rows), true
+}
+
+// EstimateLastRows returns the time writing the rows of all tables is estimated to take, when they have as many
+// rows as in the last export
+func (store *StatisticsStore) EstimateLastRows() time.Duration {
+	total := time.Duration(0)
+	for tableName, tableStatistics := range store.Tables {
+		if duration, ok := store.EstimateDuration(tableName, tableStatistics.LastRows); ok {
+			total += duration
+		}
+	}
+	return total
+}
+
+// SetTableStatistics orders the traversal of the tables by the rows of the previous exports, see
+// orderIndependentTables
+func SetTableStatistics(store *StatisticsStore) {
+	tableSizes = make(map[string]int, len(store.Tables))
+	for tableName, tableStatistics := range store.Tables {
+		tableSizes[tableName] = tableStatistics.LastRows
+	}
+}
+
+// orderIndependentTables moves the tables without reference to the other tables first, the largest ones of the
+// previous exports first, so the long writes start early. The other tables keep their order, which satisfies
+// their references. Without statistics the order is not changed.
+func orderIndependentTables(tables []schemareader.Table) []schemareader.Table {
+	if tableSizes == nil || len(tables) < 2 {
+		return tables
+	}
+	names := make(map[string]bool, len(tables))
+	for _, table := range tables {
+		names[table.Name] = true
+	}
+	independent := func(table schemareader.Table) bool {
+		for _, reference := range table.References {
+			if reference.TableName != table.Name && names[reference.TableName] {
+				return false
+			}
+		}
+		for _, reference := range table.ReferencedBy {
+			if reference.TableName != table.Name && names[reference.TableName] {
+				return false
+			}
+		}
+		return true
+	}
+	independentTables := make([]schemareader.Table, 0, len(tables))
+	dependentTables := make([]schemareader.Table, 0, len(tables))
+	for _, table := range tables {
+		if independent(table) {
+			independentTables = append(independentTables, table)
+		} else {
+			dependentTables = append(dependentTables, table)
+		}
+	}
+	sort.SliceStable(independentTables, func(i, j int) bool {
+		return tableSizes[independentTables[i].Name] > tableSizes[independentTables[j].Name]
+	})
+	return append(independentTables, dependentTables...)
+}
@@ -0,0 +1,63 @@
+package dumper
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+)
+
+func TestStatisticsStoreAveragesRowDurations(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "statistics.json")
+	store := ReadStatisticsStore(path)
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	// Act
+	store.Record(map[string]TableWriteStatistics{"rhnpackage": {Rows: 100, Duration: time.Second}}, now)
+	store.Record(map[string]TableWriteStatistics{"rhnpackage": {Rows: 100, Duration: 3 * time.Second}}, now)
+	store.Save()
+	saved := ReadStatisticsStore(path)
+
+	// Assert
+	expected := TableStatistics{Runs: 2, LastRows: 100, RowDuration: 20 * time.Millisecond, Updated: now}
+	if statistics := saved.Tables["rhnpackage"]; !reflect.DeepEqual(statistics, expected) {
+		t.Errorf("Expected statistics %v, got %v", expected, statistics)
+	}
+	if duration, ok := saved.EstimateDuration("rhnpackage", 50); !ok || duration != time.Second {
+		t.Errorf("Expected an estimate of 1s, got %s", duration)
+	}
+	if _, ok := saved.EstimateDuration("rhnchannel", 50); ok {
+		t.Errorf("Expected no estimate for a table without statistics")
+	}
+	if duration := saved.EstimateLastRows(); duration != 2*time.Second {
+		t.Errorf("Expected an estimate of the last rows of 2s, got %s", duration)
+	}
+}
+
+func TestOrderIndependentTables(t *testing.T) {
+	// Arrange
+	defer func() { tableSizes = nil }()
+	tables := []schemareader.Table{
+		{Name: "rhnchannelpackage", References: []schemareader.Reference{{TableName: "rhnchannel"}}},
+		{Name: "rhnchannelcomps"},
+		{Name: "rhnerrata", ReferencedBy: []schemareader.Reference{{TableName: "rhnchannelpackage"}}},
+		{Name: "rhnchannelproduct"},
+	}
+	tableSizes = map[string]int{"rhnchannelcomps": 2, "rhnchannelproduct": 10, "rhnerrata": 100}
+
+	// Act
+	ordered := orderIndependentTables(tables)
+
+	// Assert
+	names := make([]string, 0, len(ordered))
+	for _, table := range ordered {
+		names = append(names, table.Name)
+	}
+	expected := []string{"rhnchannelproduct", "rhnchannelcomps", "rhnchannelpackage", "rhnerrata"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected order %v, got %v", expected, names)
+	}
+}
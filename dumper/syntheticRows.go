@@ -0,0 +1,47 @@
+package dumper
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+)
+
+// syntheticTable is shaped like the package tables: a key from a sequence, a natural key and a few values
+var syntheticTable = schemareader.Table{
+	Name:                "isssyntheticpackage",
+	Export:              true,
+	Columns:             []string{"id", "name", "version", "summary", "created"},
+	ColumnIndexes:       map[string]int{"id": 0, "name": 1, "version": 2, "summary": 3, "created": 4},
+	PKColumns:           map[string]bool{"id": true},
+	PKSequence:          "isssyntheticpackage_id_seq",
+	UniqueIndexes:       map[string]schemareader.UniqueIndex{"isssyntheticpackage_nv_uq": {Name: "isssyntheticpackage_nv_uq", Columns: []string{"name", "version"}}},
+	MainUniqueIndexName: "isssyntheticpackage_nv_uq",
+}
+
+// WriteSyntheticStatements writes the insert statements of synthetic rows, as written for the rows read from
+// the database, returning the bytes written. It measures the statement generation without a database.
+func WriteSyntheticStatements(writer io.Writer, rows int) (int64, error) {
+	buffered := bufio.NewWriter(writer)
+	created := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	var written int64
+	for i := 0; i < rows; i++ {
+		values := []sqlUtil.RowDataStructure{
+			{ColumnName: "id", ColumnType: "INT8", Value: int64(i + 1)},
+			{ColumnName: "name", ColumnType: "VARCHAR", Value: fmt.Sprintf("package-%d", i%1000)},
+			{ColumnName: "version", ColumnType: "VARCHAR", Value: fmt.Sprintf("1.%d", i/1000)},
+			{ColumnName: "summary", ColumnType: "VARCHAR", Value: "Synthetic package written to measure the export throughput"},
+			{ColumnName: "created", ColumnType: "TIMESTAMPTZ", Value: created.Add(time.Duration(i) * time.Second)},
+		}
+		statement := formatRowInsertStatement(syntheticTable, prepareRowValues(nil, values, syntheticTable, nil, nil), nil)
+		n, err := buffered.WriteString(statement.text + "\n")
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, buffered.Flush()
+}
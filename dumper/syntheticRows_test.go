@@ -0,0 +1,23 @@
+package dumper
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteSyntheticStatements(t *testing.T) {
+	var output bytes.Buffer
+	written, err := WriteSyntheticStatements(&output, 3)
+	if err != nil || written != int64(output.Len()) {
+		t.Fatalf("Expected %d bytes written, got %d: %v", output.Len(), written, err)
+	}
+	lines := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 statements, got %d", len(lines))
+	}
+	expected := "INSERT INTO isssyntheticpackage (id, name, version, summary, created)\tVALUES ((SELECT nextval('isssyntheticpackage_id_seq')),'package-0','1.0',"
+	if !strings.HasPrefix(lines[0], expected) || !strings.Contains(lines[0], "ON CONFLICT (name, version)") {
+		t.Errorf("Unexpected statement %s", lines[0])
+	}
+}
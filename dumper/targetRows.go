@@ -0,0 +1,85 @@
+package dumper
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+)
+
+// database of the target server, rows already present on it are not exported when set
+var targetDB *sql.DB
+
+// number of rows not exported because they are on the target, indexed by table
+var targetRowsSkipped = make(map[string]int)
+
+// SetTargetDatabase enables skipping rows already present on the target database
+func SetTargetDatabase(db *sql.DB) {
+	targetDB = db
+}
+
+// TargetRowsSkipped returns the number of rows not exported because they are on the target, indexed by table
+func TargetRowsSkipped() map[string]int {
+	return targetRowsSkipped
+}
+
+// isRowOnTarget checks if the target database has a row with the same values. Generated ids are ignored,
+// since they differ between servers, while references were replaced by sub queries on natural keys.
+func isRowOnTarget(table schemareader.Table, rowValues []sqlUtil.RowDataStructure) bool {
+	if targetDB == nil {
+		return false
+	}
+	query := formatTargetRowQuery(table, rowValues)
+	if len(query) == 0 {
+		return false
+	}
+	if len(sqlUtil.ExecuteQueryWithResults(targetDB, query)) == 0 {
+		return false
+	}
+	targetRowsSkipped[table.Name]++
+	return true
+}
+
+// formatTargetRowQuery returns the query finding the row on the target, or an empty string if the row cannot be
+// identified without its generated id
+func formatTargetRowQuery(table schemareader.Table, rowValues []sqlUtil.RowDataStructure) string {
+	keyColumns := make(map[string]bool)
+	for _, column := range table.UniqueIndexes[table.MainUniqueIndexName].Columns {
+		keyColumns[column] = true
+	}
+	conditions := make([]string, 0, len(rowValues))
+	identified := false
+	for _, value := range rowValues {
+		// generated ids and large object contents are new on import
+		if len(table.PKSequence) > 0 && table.PKColumns[value.ColumnName] && len(table.PKColumns) == 1 {
+			continue
+		}
+		if table.LargeObjectColumns[value.ColumnName] {
+			continue
+		}
+		if keyColumns[value.ColumnName] {
+			identified = true
+		}
+		conditions = append(conditions, fmt.Sprintf("%s IS NOT DISTINCT FROM %s", value.ColumnName, formatField(value)))
+	}
+	if !identified {
+		return ""
+	}
+	return fmt.Sprintf("SELECT 1 FROM %s WHERE %s LIMIT 1;", table.InsertTableName(), strings.Join(conditions, " AND "))
+}
+
+// LogTargetRowsSkipped reports the rows not exported because they are on the target
+func LogTargetRowsSkipped() {
+	if targetDB == nil {
+		return
+	}
+	total := 0
+	for tableName, count := range targetRowsSkipped {
+		log.Debug().Msgf("%d rows of %s already on the target", count, tableName)
+		total += count
+	}
+	log.Info().Msgf("%d rows already on the target were not exported", total)
+}
@@ -0,0 +1,29 @@
+package dumper
+
+import (
+	"bufio"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// SessionSettings are the settings of the import session the exported values are written for, so the values
+// are read the same whatever the time zone and date style of the target server
+var SessionSettings = []string{"SET DateStyle = 'ISO, YMD';", "SET TimeZone = 'UTC';"}
+
+// WriteSessionSettings writes the settings of the import session, before the transaction of the export
+func WriteSessionSettings(writer *bufio.Writer) {
+	for _, setting := range SessionSettings {
+		writer.WriteString(setting + "\n")
+	}
+}
+
+// formatTimestamp formats the timestamps with time zone in UTC with an explicit offset, so they don't depend on
+// the time zone of the exporting session. Timestamps without time zone and dates are written as read.
+func formatTimestamp(columnType string, value time.Time) string {
+	if columnType != "TIMESTAMPTZ" {
+		return string(pq.FormatTimestamp(value))
+	}
+	return strings.Replace(string(pq.FormatTimestamp(value.UTC())), "Z", "+00:00", 1)
+}
@@ -18,19 +18,33 @@ type TableKey struct {
 
 type TableDump struct {
 	TableName string
-	KeyMap    map[string]bool
 	Keys      []TableKey
 }
 
 type DataDumper struct {
 	TableData map[string]TableDump
 	Paths     map[string]bool
+	// tables not followed by the crawler, with the reason
+	Pruned map[string]string
+}
+
+// CrawlerOptions controls how far the crawler follows references
+type CrawlerOptions struct {
+	StartingDate string
+	// maximum number of references followed from the start table, 0 means unlimited
+	MaxDepth int
+	// tables which are never followed into, together with everything reachable only through them
+	PruneTables []string
+	// limits of the crawler for single foreign keys
+	ReferenceRules []ReferenceRule
 }
 
 type processItem struct {
 	tableName string
 	row       []sqlUtil.RowDataStructure
 	path      []string
+	// references still followed from the row, -1 when unlimited
+	levels int
 }
 
 type PrintSqlOptions struct {
@@ -5,20 +5,20 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
+
+	"github.com/uyuni-project/inter-server-sync/utils"
 )
 
 func Copy(src, dst string) (int64, error) {
-	sourceFileStat, err := os.Stat(src)
-	if err != nil {
-		return 0, err
-	}
-
-	if !sourceFileStat.Mode().IsRegular() {
-		return 0, fmt.Errorf("%s is not a regular file", src)
-	}
+	return copyFile(src, dst, nil)
+}
 
-	source, err := os.Open(src)
+// copyFile copies the file, writing its content to the hash as well when set
+func copyFile(src, dst string, hash io.Writer) (int64, error) {
+	source, err := openRegularFile(src)
 	if err != nil {
 		return 0, err
 	}
@@ -29,10 +29,30 @@ func Copy(src, dst string) (int64, error) {
 		return 0, err
 	}
 	defer destination.Close()
-	nBytes, err := io.Copy(destination, source)
+	var writer io.Writer = destination
+	if hash != nil {
+		writer = io.MultiWriter(destination, hash)
+	}
+	start := time.Now()
+	nBytes, err := io.Copy(writer, utils.CancelableReader(source))
+	recordFileCopy(nBytes, time.Since(start))
 	return nBytes, err
 }
 
+// openRegularFile opens the file, refusing folders and devices
+func openRegularFile(src string) (*os.File, error) {
+	sourceFileStat, err := os.Stat(src)
+	if err != nil {
+		return nil, err
+	}
+
+	if !sourceFileStat.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a regular file", src)
+	}
+
+	return os.Open(src)
+}
+
 func ModifyCopy(src, dst, pattern, replace string) (int64, error) {
 	sourceFileStat, err := os.Stat(src)
 	if err != nil {
@@ -66,3 +86,24 @@ func create(p string) (*os.File, error) {
 	}
 	return os.Create(p)
 }
+
+// sortedColumnMapping returns the local columns of a reference column mapping sorted,
+// so generated statements do not depend on the map iteration order
+func sortedColumnMapping(columnMapping map[string]string) []string {
+	columns := make([]string, 0, len(columnMapping))
+	for column := range columnMapping {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// sortedColumnSet returns the columns of a set sorted by name
+func sortedColumnSet(columnSet map[string]bool) []string {
+	columns := make([]string, 0, len(columnSet))
+	for column := range columnSet {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	return columns
+}
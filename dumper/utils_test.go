@@ -41,7 +41,7 @@ func createMetaDataGraph(graph TablesGraph) (MetaDataGraph, DataDumper) {
 				PKColumns:           map[string]bool{"id": true},
 				ColumnIndexes:       map[string]int{"id": 0},
 				MainUniqueIndexName: indexName,
-				UniqueIndexes:       map[string]schemareader.UniqueIndex{indexName: {indexName, []string{"id"}}},
+				UniqueIndexes:       map[string]schemareader.UniqueIndex{indexName: {Name: indexName, Columns: []string{"id"}}},
 				References:          []schemareader.Reference{},
 				ReferencedBy:        []schemareader.Reference{},
 			}
@@ -69,7 +69,6 @@ func createMetaDataGraph(graph TablesGraph) (MetaDataGraph, DataDumper) {
 			k := []RowKey{{"id", fmt.Sprintf("'%04d'", 1)}}
 			dataDumper.TableData[child] = TableDump{
 				TableName: child,
-				KeyMap:    map[string]bool{fmt.Sprintf("'%04d'", 1): true},
 				Keys:      []TableKey{{Key: k}},
 			}
 		}
@@ -77,7 +76,6 @@ func createMetaDataGraph(graph TablesGraph) (MetaDataGraph, DataDumper) {
 		k := []RowKey{{"id", fmt.Sprintf("'%04d'", 1)}}
 		dataDumper.TableData[parent] = TableDump{
 			TableName: parent,
-			KeyMap:    map[string]bool{fmt.Sprintf("'%04d'", 1): true},
 			Keys:      []TableKey{{Key: k}},
 		}
 	}
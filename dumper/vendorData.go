@@ -0,0 +1,179 @@
+package dumper
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// VendorRowsFileName is the file of the export listing the vendor rows its statements reference
+const VendorRowsFileName = "vendorRows.json"
+
+// vendorTables are the tables filled by the schema of the target server, whose rows are assumed to be identical
+// on the source and the target, with their natural key. Their rows are never exported, references to them are
+// resolved by natural key.
+var vendorTables = map[string][]string{
+	"rhnarchtype":                    {"label"},
+	"rhnchannelarch":                 {"label"},
+	"rhnpackagearch":                 {"label"},
+	"rhnserverarch":                  {"label"},
+	"rhncpuarch":                     {"label"},
+	"rhnchecksumtype":                {"label"},
+	"rhnerrataseverity":              {"label"},
+	"rhncontentsourcetype":           {"label"},
+	"rhnconfigfiletype":              {"label"},
+	"rhnservergrouptype":             {"label"},
+	"rhncryptokeytype":               {"label"},
+	"rhnkickstartvirtualizationtype": {"label"},
+	"rhnkickstartcommandname":        {"name"},
+	"rhnkstreetype":                  {"label"},
+	"rhnpackageprovider":             {"name"},
+	"rhnpackagekeytype":              {"label"},
+}
+
+// IsVendorTable returns whether the rows of the table are assumed to exist on the target server
+func IsVendorTable(tableName string) bool {
+	_, ok := vendorTables[tableName]
+	return ok
+}
+
+// VendorTableRows are the natural keys of the vendor rows of a table referenced by an export
+type VendorTableRows struct {
+	Columns []string   `json:"columns"`
+	Rows    [][]string `json:"rows"`
+}
+
+// VendorRows are the vendor rows referenced by an export, by table
+type VendorRows map[string]VendorTableRows
+
+// values of the vendor rows referenced by the written rows, by table and referenced column
+var vendorReferences = make(map[string]map[string]map[string]bool)
+
+// recordVendorReferences records the vendor rows referenced by the row, with the values read from the source database
+func recordVendorReferences(table schemareader.Table, row []sqlUtil.RowDataStructure) {
+	for _, reference := range table.References {
+		if !IsVendorTable(reference.TableName) || len(reference.ColumnMapping) != 1 {
+			continue
+		}
+		for localColumn, foreignColumn := range reference.ColumnMapping {
+			for _, column := range row {
+				if column.ColumnName != localColumn || column.Value == nil {
+					continue
+				}
+				columns, ok := vendorReferences[reference.TableName]
+				if !ok {
+					columns = make(map[string]map[string]bool)
+					vendorReferences[reference.TableName] = columns
+				}
+				if columns[foreignColumn] == nil {
+					columns[foreignColumn] = make(map[string]bool)
+				}
+				columns[foreignColumn][formatValue(column.Value)] = true
+			}
+		}
+	}
+}
+
+// ReadReferencedVendorRows returns the natural keys of the vendor rows referenced by the written rows, read from
+// the source database
+func ReadReferencedVendorRows(db *sql.DB) VendorRows {
+	result := make(VendorRows)
+	for tableName, columns := range vendorReferences {
+		keyColumns := vendorTables[tableName]
+		seen := make(map[string]bool)
+		tableRows := VendorTableRows{Columns: keyColumns, Rows: make([][]string, 0)}
+		for _, foreignColumn := range sortedColumnSet(columnsOf(columns)) {
+			values := make([]string, 0, len(columns[foreignColumn]))
+			for value := range columns[foreignColumn] {
+				values = append(values, value)
+			}
+			query := fmt.Sprintf("SELECT %s FROM %s WHERE %s::text = ANY($1);", strings.Join(keyColumns, ", "), tableName, foreignColumn)
+			for _, row := range sqlUtil.ExecuteQueryWithResults(db, query, pq.Array(values)) {
+				key := make([]string, 0, len(row))
+				for _, column := range row {
+					key = append(key, formatValue(column.Value))
+				}
+				if joined := strings.Join(key, "\x00"); !seen[joined] {
+					seen[joined] = true
+					tableRows.Rows = append(tableRows.Rows, key)
+				}
+			}
+		}
+		result[tableName] = tableRows.sorted()
+	}
+	return result
+}
+
+func columnsOf(columns map[string]map[string]bool) map[string]bool {
+	result := make(map[string]bool, len(columns))
+	for column := range columns {
+		result[column] = true
+	}
+	return result
+}
+
+func (rows VendorTableRows) sorted() VendorTableRows {
+	sort.Slice(rows.Rows, func(i, j int) bool {
+		return strings.Join(rows.Rows[i], "\x00") < strings.Join(rows.Rows[j], "\x00")
+	})
+	return rows
+}
+
+// Merge adds the vendor rows of another export
+func (vendorRows VendorRows) Merge(other VendorRows) {
+	for tableName, otherRows := range other {
+		tableRows, ok := vendorRows[tableName]
+		if !ok {
+			vendorRows[tableName] = otherRows
+			continue
+		}
+		seen := make(map[string]bool, len(tableRows.Rows))
+		for _, row := range tableRows.Rows {
+			seen[strings.Join(row, "\x00")] = true
+		}
+		for _, row := range otherRows.Rows {
+			if !seen[strings.Join(row, "\x00")] {
+				tableRows.Rows = append(tableRows.Rows, row)
+			}
+		}
+		vendorRows[tableName] = tableRows.sorted()
+	}
+}
+
+// WriteVendorRows writes the vendor rows into the export folder, so the import verifies them on the target
+func WriteVendorRows(outputFolderAbs string, vendorRows VendorRows) {
+	content, err := json.MarshalIndent(vendorRows, "", "  ")
+	if err != nil {
+		utils.Panic().Err(err).Msg("error encoding the vendor rows")
+	}
+	if err := os.WriteFile(filepath.Join(outputFolderAbs, VendorRowsFileName), append(content, '\n'), 0644); err != nil {
+		utils.Panic().Err(err).Msg("error creating the vendor rows file")
+	}
+	log.Debug().Msgf("The export references the vendor rows of %d tables", len(vendorRows))
+}
+
+// ReadVendorRows reads the vendor rows referenced by an export, nil for exports without them
+func ReadVendorRows(folder string) (VendorRows, error) {
+	content, err := os.ReadFile(filepath.Join(folder, VendorRowsFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	vendorRows := make(VendorRows)
+	if err := json.Unmarshal(content, &vendorRows); err != nil {
+		return nil, fmt.Errorf("%s is corrupted: %w", VendorRowsFileName, err)
+	}
+	return vendorRows, nil
+}
@@ -0,0 +1,58 @@
+package dumper
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+)
+
+func TestRecordVendorReferences(t *testing.T) {
+	// 01 Arrange
+	defer ResetExportState()
+	ResetExportState()
+	table := schemareader.Table{
+		Name: "rhnpackage",
+		References: []schemareader.Reference{
+			{TableName: "rhnpackagearch", ColumnMapping: map[string]string{"package_arch_id": "id"}},
+			{TableName: "rhnpackagename", ColumnMapping: map[string]string{"name_id": "id"}},
+		},
+	}
+
+	// 02 Act
+	recordVendorReferences(table, []sqlUtil.RowDataStructure{{ColumnName: "package_arch_id", Value: int64(100)}, {ColumnName: "name_id", Value: int64(1)}})
+	recordVendorReferences(table, []sqlUtil.RowDataStructure{{ColumnName: "package_arch_id", Value: nil}, {ColumnName: "name_id", Value: int64(2)}})
+
+	// 03 Assert
+	expected := map[string]map[string]map[string]bool{"rhnpackagearch": {"id": {"100": true}}}
+	if !reflect.DeepEqual(vendorReferences, expected) {
+		t.Errorf("Expected vendor references %v, but got %v", expected, vendorReferences)
+	}
+}
+
+func TestVendorRowsRoundTrip(t *testing.T) {
+	// 01 Arrange
+	folder := t.TempDir()
+	vendorRows := VendorRows{"rhnpackagearch": {Columns: []string{"label"}, Rows: [][]string{{"x86_64"}}}}
+	vendorRows.Merge(VendorRows{
+		"rhnpackagearch":  {Columns: []string{"label"}, Rows: [][]string{{"noarch"}, {"x86_64"}}},
+		"rhnchecksumtype": {Columns: []string{"label"}, Rows: [][]string{{"sha256"}}},
+	})
+
+	// 02 Act
+	WriteVendorRows(folder, vendorRows)
+	result, err := ReadVendorRows(folder)
+
+	// 03 Assert
+	expected := VendorRows{
+		"rhnpackagearch":  {Columns: []string{"label"}, Rows: [][]string{{"noarch"}, {"x86_64"}}},
+		"rhnchecksumtype": {Columns: []string{"label"}, Rows: [][]string{{"sha256"}}},
+	}
+	if err != nil || !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected vendor rows %v, but got %v (%v)", expected, result, err)
+	}
+	if missing, err := ReadVendorRows(t.TempDir()); missing != nil || err != nil {
+		t.Errorf("Exports without vendor rows must not be verified, got %v (%v)", missing, err)
+	}
+}
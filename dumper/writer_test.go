@@ -2,10 +2,14 @@ package dumper
 
 import (
 	"fmt"
+	"math"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/uyuni-project/inter-server-sync/schemareader"
 	"github.com/uyuni-project/inter-server-sync/sqlUtil"
 	"github.com/uyuni-project/inter-server-sync/tests"
@@ -42,16 +46,21 @@ func TestPrintAllTableData(t *testing.T) {
 	testCase := createTestCase(graph, root, PrintSqlOptions{})
 
 	// the data repository expect these statements in the exact same order
-	testCase.repo.Expect("SELECT id, v05_fk_id FROM v04 ;", testCase.schemaMetadata["v04"].Columns, 1)
+	testCase.repo.Expect("SELECT id, v05_fk_id FROM v04  ORDER BY id;", testCase.schemaMetadata["v04"].Columns, 1)
+	testCase.repo.ExpectPrepare("SELECT id, v04_fk_id FROM v05 WHERE id = $1;")
 	testCase.repo.Expect("SELECT id, v04_fk_id FROM v05 WHERE id = $1;", testCase.schemaMetadata["v05"].Columns, 1)
-	testCase.repo.Expect("SELECT id, v04_fk_id FROM v05 ;", testCase.schemaMetadata["v05"].Columns, 1)
+	testCase.repo.Expect("SELECT id, v04_fk_id FROM v05  ORDER BY id;", testCase.schemaMetadata["v05"].Columns, 1)
+	testCase.repo.ExpectPrepare("SELECT id, v05_fk_id FROM v04 WHERE id = $1;")
 	testCase.repo.Expect("SELECT id, v05_fk_id FROM v04 WHERE id = $1;", testCase.schemaMetadata["v04"].Columns, 1)
-	testCase.repo.Expect("SELECT id, v05_fk_id FROM v01 ;", testCase.schemaMetadata["v01"].Columns, 1)
-	testCase.repo.Expect("SELECT id, v04_fk_id FROM v03 ;", testCase.schemaMetadata["v03"].Columns, 1)
-	testCase.repo.Expect("SELECT id, v03_fk_id FROM v02 ;", testCase.schemaMetadata["v02"].Columns, 1)
+	testCase.repo.Expect("SELECT id, v05_fk_id FROM v01  ORDER BY id;", testCase.schemaMetadata["v01"].Columns, 1)
+	testCase.repo.Expect("SELECT id, v04_fk_id FROM v03  ORDER BY id;", testCase.schemaMetadata["v03"].Columns, 1)
+	testCase.repo.Expect("SELECT id, v03_fk_id FROM v02  ORDER BY id;", testCase.schemaMetadata["v02"].Columns, 1)
+	testCase.repo.ExpectPrepare("SELECT id, v04_fk_id FROM v03 WHERE id = $1;")
 	testCase.repo.Expect("SELECT id, v04_fk_id FROM v03 WHERE id = $1;", testCase.schemaMetadata["v03"].Columns, 1)
-	testCase.repo.Expect("SELECT id, v01_fk_id, v02_fk_id FROM root ;", testCase.schemaMetadata["root"].Columns, 1)
+	testCase.repo.Expect("SELECT id, v01_fk_id, v02_fk_id FROM root  ORDER BY id;", testCase.schemaMetadata["root"].Columns, 1)
+	testCase.repo.ExpectPrepare("SELECT id, v05_fk_id FROM v01 WHERE id = $1;")
 	testCase.repo.Expect("SELECT id, v05_fk_id FROM v01 WHERE id = $1;", testCase.schemaMetadata["v01"].Columns, 1)
+	testCase.repo.ExpectPrepare("SELECT id, v03_fk_id FROM v02 WHERE id = $1;")
 	testCase.repo.Expect("SELECT id, v03_fk_id FROM v02 WHERE id = $1;", testCase.schemaMetadata["v02"].Columns, 1)
 
 	// 02 Act
@@ -107,32 +116,38 @@ func TestPrintCleanTables(t *testing.T) {
 		PrintSqlOptions{TablesToClean: keys},
 	)
 
-	testCase.repo.Expect("SELECT * FROM root WHERE (id) IN (SELECT root.id FROM root  );", testCase.schemaMetadata["root"].Columns, 1)
+	testCase.repo.Expect("SELECT * FROM root WHERE (id) IN (SELECT root.id FROM root  ) ORDER BY id;", testCase.schemaMetadata["root"].Columns, 1)
+	testCase.repo.ExpectPrepare("SELECT id, v15_fk_id, v16_fk_id FROM v11 WHERE id = $1;")
 	testCase.repo.Expect("SELECT id, v15_fk_id, v16_fk_id FROM v11 WHERE id = $1;", testCase.schemaMetadata["v11"].Columns, 1)
+	testCase.repo.ExpectPrepare("SELECT id, v13_fk_id FROM v12 WHERE id = $1;")
 	testCase.repo.Expect("SELECT id, v13_fk_id FROM v12 WHERE id = $1;", testCase.schemaMetadata["v12"].Columns, 1)
 	testCase.repo.Expect("SELECT * FROM v11 WHERE (id) IN (SELECT v11.id FROM v11  "+
-		"INNER JOIN root on root.v11_fk_id = v11.id );", testCase.schemaMetadata["v11"].Columns, 1)
+		"INNER JOIN root on root.v11_fk_id = v11.id ) ORDER BY id;", testCase.schemaMetadata["v11"].Columns, 1)
+	testCase.repo.ExpectPrepare("SELECT id, v14_fk_id FROM v15 WHERE id = $1;")
 	testCase.repo.Expect("SELECT id, v14_fk_id FROM v15 WHERE id = $1;", testCase.schemaMetadata["v15"].Columns, 1)
+	testCase.repo.ExpectPrepare("SELECT id FROM v16 WHERE id = $1;")
 	testCase.repo.Expect("SELECT id FROM v16 WHERE id = $1;", testCase.schemaMetadata["v16"].Columns, 1)
 	testCase.repo.Expect("SELECT * FROM v15 WHERE (id) IN (SELECT v15.id FROM v15  "+
 		"INNER JOIN v11 on v11.v15_fk_id = v15.id "+
-		"INNER JOIN root on root.v11_fk_id = v11.id );", testCase.schemaMetadata["v15"].Columns, 1)
+		"INNER JOIN root on root.v11_fk_id = v11.id ) ORDER BY id;", testCase.schemaMetadata["v15"].Columns, 1)
+	testCase.repo.ExpectPrepare("SELECT id, v15_fk_id, v16_fk_id FROM v14 WHERE id = $1;")
 	testCase.repo.Expect("SELECT id, v15_fk_id, v16_fk_id FROM v14 WHERE id = $1;", testCase.schemaMetadata["v14"].Columns, 1)
 	testCase.repo.Expect("SELECT * FROM v14 WHERE (id) IN (SELECT v14.id FROM v14  "+
 		"INNER JOIN v15 on v15.v14_fk_id = v14.id "+
 		"INNER JOIN v11 on v11.v15_fk_id = v15.id "+
-		"INNER JOIN root on root.v11_fk_id = v11.id );", testCase.schemaMetadata["v14"].Columns, 1)
+		"INNER JOIN root on root.v11_fk_id = v11.id ) ORDER BY id;", testCase.schemaMetadata["v14"].Columns, 1)
 	testCase.repo.Expect("SELECT * FROM v16 WHERE (id) IN (SELECT v16.id FROM v16  "+
 		"INNER JOIN v14 on v14.v16_fk_id = v16.id "+
 		"INNER JOIN v15 on v15.v14_fk_id = v14.id "+
 		"INNER JOIN v11 on v11.v15_fk_id = v15.id "+
-		"INNER JOIN root on root.v11_fk_id = v11.id );", testCase.schemaMetadata["v16"].Columns, 1)
+		"INNER JOIN root on root.v11_fk_id = v11.id ) ORDER BY id;", testCase.schemaMetadata["v16"].Columns, 1)
 	testCase.repo.Expect("SELECT * FROM v12 WHERE (id) IN (SELECT v12.id FROM v12  "+
-		"INNER JOIN root on root.v12_fk_id = v12.id );", testCase.schemaMetadata["v12"].Columns, 1)
+		"INNER JOIN root on root.v12_fk_id = v12.id ) ORDER BY id;", testCase.schemaMetadata["v12"].Columns, 1)
+	testCase.repo.ExpectPrepare("SELECT id, v14_fk_id FROM v13 WHERE id = $1;")
 	testCase.repo.Expect("SELECT id, v14_fk_id FROM v13 WHERE id = $1;", testCase.schemaMetadata["v13"].Columns, 1)
 	testCase.repo.Expect("SELECT * FROM v13 WHERE (id) IN (SELECT v13.id FROM v13  "+
 		"INNER JOIN v12 on v12.v13_fk_id = v13.id "+
-		"INNER JOIN root on root.v12_fk_id = v12.id );", testCase.schemaMetadata["v13"].Columns, 1)
+		"INNER JOIN root on root.v12_fk_id = v12.id ) ORDER BY id;", testCase.schemaMetadata["v13"].Columns, 1)
 
 	expectedWrittenBuffer := []string{
 		"" +
@@ -261,18 +276,24 @@ func TestPrintTableData(t *testing.T) {
 	testCase := createTestCase(graph, root, PrintSqlOptions{PostOrderCallback: createCallback()})
 
 	// the data repository expect these statements in the exact same order
-	testCase.repo.Expect("SELECT id FROM v26 WHERE (id) IN (('0001'));", testCase.schemaMetadata["v26"].Columns, 1)
-	testCase.repo.Expect("SELECT id, v25_fk_id, v26_fk_id FROM v24 WHERE (id) IN (('0001'));", testCase.schemaMetadata["v24"].Columns, 1)
+	testCase.repo.Expect("SELECT id FROM v26 WHERE (id) IN (('0001')) ORDER BY id;", testCase.schemaMetadata["v26"].Columns, 1)
+	testCase.repo.Expect("SELECT id, v25_fk_id, v26_fk_id FROM v24 WHERE (id) IN (('0001')) ORDER BY id;", testCase.schemaMetadata["v24"].Columns, 1)
+	testCase.repo.ExpectPrepare("SELECT id, v24_fk_id FROM v25 WHERE id = $1;")
 	testCase.repo.Expect("SELECT id, v24_fk_id FROM v25 WHERE id = $1;", testCase.schemaMetadata["v25"].Columns, 1)
+	testCase.repo.ExpectPrepare("SELECT id FROM v26 WHERE id = $1;")
 	testCase.repo.Expect("SELECT id FROM v26 WHERE id = $1;", testCase.schemaMetadata["v26"].Columns, 1)
-	testCase.repo.Expect("SELECT id, v24_fk_id FROM v25 WHERE (id) IN (('0001'));", testCase.schemaMetadata["v25"].Columns, 1)
+	testCase.repo.Expect("SELECT id, v24_fk_id FROM v25 WHERE (id) IN (('0001')) ORDER BY id;", testCase.schemaMetadata["v25"].Columns, 1)
+	testCase.repo.ExpectPrepare("SELECT id, v25_fk_id, v26_fk_id FROM v24 WHERE id = $1;")
 	testCase.repo.Expect("SELECT id, v25_fk_id, v26_fk_id FROM v24 WHERE id = $1;", testCase.schemaMetadata["v24"].Columns, 1)
-	testCase.repo.Expect("SELECT id, v25_fk_id, v26_fk_id FROM v21 WHERE (id) IN (('0001'));", testCase.schemaMetadata["v21"].Columns, 1)
-	testCase.repo.Expect("SELECT id, v24_fk_id FROM v23 WHERE (id) IN (('0001'));", testCase.schemaMetadata["v23"].Columns, 1)
-	testCase.repo.Expect("SELECT id, v23_fk_id FROM v22 WHERE (id) IN (('0001'));", testCase.schemaMetadata["v22"].Columns, 1)
+	testCase.repo.Expect("SELECT id, v25_fk_id, v26_fk_id FROM v21 WHERE (id) IN (('0001')) ORDER BY id;", testCase.schemaMetadata["v21"].Columns, 1)
+	testCase.repo.Expect("SELECT id, v24_fk_id FROM v23 WHERE (id) IN (('0001')) ORDER BY id;", testCase.schemaMetadata["v23"].Columns, 1)
+	testCase.repo.Expect("SELECT id, v23_fk_id FROM v22 WHERE (id) IN (('0001')) ORDER BY id;", testCase.schemaMetadata["v22"].Columns, 1)
+	testCase.repo.ExpectPrepare("SELECT id, v24_fk_id FROM v23 WHERE id = $1;")
 	testCase.repo.Expect("SELECT id, v24_fk_id FROM v23 WHERE id = $1;", testCase.schemaMetadata["v23"].Columns, 1)
-	testCase.repo.Expect("SELECT id, v21_fk_id, v22_fk_id FROM root WHERE (id) IN (('0001'));", testCase.schemaMetadata["root"].Columns, 1)
+	testCase.repo.Expect("SELECT id, v21_fk_id, v22_fk_id FROM root WHERE (id) IN (('0001')) ORDER BY id;", testCase.schemaMetadata["root"].Columns, 1)
+	testCase.repo.ExpectPrepare("SELECT id, v25_fk_id, v26_fk_id FROM v21 WHERE id = $1;")
 	testCase.repo.Expect("SELECT id, v25_fk_id, v26_fk_id FROM v21 WHERE id = $1;", testCase.schemaMetadata["v21"].Columns, 1)
+	testCase.repo.ExpectPrepare("SELECT id, v23_fk_id FROM v22 WHERE id = $1;")
 	testCase.repo.Expect("SELECT id, v23_fk_id FROM v22 WHERE id = $1;", testCase.schemaMetadata["v22"].Columns, 1)
 
 	// 02 Act
@@ -318,6 +339,27 @@ func TestFormatOnConflict(t *testing.T) {
 	}
 }
 
+func TestFormatOnConflictWebContact(t *testing.T) {
+	// 01 Arrange
+	table := schemareader.Table{
+		Name:                "web_contact",
+		Columns:             []string{"id", "login_uc", "password"},
+		PKColumns:           map[string]bool{"id": true},
+		MainUniqueIndexName: "web_contact_login_uc_unq",
+		UniqueIndexes:       map[string]schemareader.UniqueIndex{"web_contact_login_uc_unq": {Columns: []string{"login_uc"}}},
+	}
+	expectedResult := "(login_uc) DO UPDATE SET login_uc = excluded.login_uc," +
+		"password = CASE WHEN excluded.password = '!' THEN web_contact.password ELSE excluded.password END"
+
+	// 02 Act
+	result := formatOnConflict([]sqlUtil.RowDataStructure{}, table)
+
+	// 03 Assert
+	if strings.Compare(result, expectedResult) != 0 {
+		t.Errorf(fmt.Sprintf("Expected %s, but got %s", expectedResult, result))
+	}
+}
+
 func TestFormatOnConflictRhnConfigInfo(t *testing.T) {
 	// 01 Arrange
 	table := schemareader.Table{Name: "rhnconfiginfo"}
@@ -421,3 +463,345 @@ func createTestCase(graph TablesGraph, root string, options PrintSqlOptions) wri
 		options,
 	}
 }
+
+func TestValidateCheckConstraints(t *testing.T) {
+	// 01 Arrange
+	ResetExportState()
+	defer ResetExportState()
+	repo := tests.CreateDataRepository()
+	table := schemareader.Table{
+		Name:             "rhnerrata",
+		CheckConstraints: map[string]string{"rhn_errata_adv_type_ck": "((advisory_type IN ('A', 'B')))"},
+		RowModCallback: func(value []sqlUtil.RowDataStructure, table schemareader.Table) []sqlUtil.RowDataStructure {
+			return value
+		},
+	}
+	checks := newCheckConstraintBatch(repo.DB, table)
+	checks.add([]sqlUtil.RowDataStructure{{ColumnName: "advisory_type", ColumnType: "VARCHAR", Value: "A"}})
+	checks.add([]sqlUtil.RowDataStructure{{ColumnName: "advisory_type", ColumnType: "VARCHAR", Value: "C"}})
+	repo.ExpectWithRecords("SELECT exported_row_index, (NOT ((advisory_type IN ('A', 'B')))) IS TRUE "+
+		"FROM (VALUES (0, 'A'), (1, 'C')) AS exported_row (exported_row_index, \"advisory_type\") "+
+		"WHERE NOT ((advisory_type IN ('A', 'B'))) ORDER BY exported_row_index;",
+		sqlmock.NewRows([]string{"exported_row_index", "check"}).AddRow(1, true))
+
+	// 02 Act
+	checks.validate()
+
+	// 03 Assert
+	if CheckConstraintViolations() != 1 {
+		t.Errorf("Expected 1 check constraint violation, got %d", CheckConstraintViolations())
+	}
+	if err := repo.ExpectationsWereMet(); err != nil {
+		t.Errorf("Check constraint was not evaluated. Error message: %s", err)
+	}
+	if len(checks.rows) != 0 || checks.size != 0 {
+		t.Errorf("Validated rows should be forgotten, got %v", checks.rows)
+	}
+}
+
+func TestCheckConstraintBatchIsBounded(t *testing.T) {
+	// 01 Arrange
+	ResetExportState()
+	defer ResetExportState()
+	repo := tests.CreateDataRepository()
+	table := schemareader.Table{
+		Name:             "susesaltpillar",
+		CheckConstraints: map[string]string{"suse_salt_pillar_ck": "((category IS NOT NULL))"},
+		RowModCallback: func(value []sqlUtil.RowDataStructure, table schemareader.Table) []sqlUtil.RowDataStructure {
+			return value
+		},
+	}
+	largePillar := strings.Repeat("x", checkConstraintBatchBytes/2)
+	for i := 0; i < 2; i++ {
+		repo.ExpectWithRecords("SELECT exported_row_index, (NOT ((category IS NOT NULL))) IS TRUE FROM (VALUES (0, '"+largePillar+"'), (1, '"+largePillar+"')) "+
+			"AS exported_row (exported_row_index, \"pillar\") WHERE NOT ((category IS NOT NULL)) ORDER BY exported_row_index;",
+			sqlmock.NewRows([]string{"exported_row_index", "check"}))
+	}
+
+	// 02 Act
+	checks := newCheckConstraintBatch(repo.DB, table)
+	for i := 0; i < 4; i++ {
+		checks.add([]sqlUtil.RowDataStructure{{ColumnName: "pillar", ColumnType: "TEXT", Value: largePillar}})
+	}
+
+	// 03 Assert
+	if err := repo.ExpectationsWereMet(); err != nil {
+		t.Errorf("Rows should be validated once their values reach the batch size. Error message: %s", err)
+	}
+	if len(checks.rows) != 0 {
+		t.Errorf("Validated rows should be forgotten, got %d", len(checks.rows))
+	}
+	if newCheckConstraintBatch(repo.DB, schemareader.Table{Name: "rhnerrata", CheckConstraints: table.CheckConstraints}) != nil {
+		t.Errorf("Rows of tables without row callback are exported unchanged and need no checks")
+	}
+}
+
+func TestFormatFieldExplicitCasts(t *testing.T) {
+	// 01 Arrange
+	testCases := map[string]sqlUtil.RowDataStructure{
+		"'12'::numeric":       {ColumnType: "NUMERIC", Value: []byte("12")},
+		"'NaN'::numeric":      {ColumnType: "NUMERIC", Value: []byte("NaN")},
+		"'7'::int8":           {ColumnType: "INT8", Value: int64(7)},
+		"'-Infinity'::float8": {ColumnType: "FLOAT8", Value: math.Inf(-1)},
+		"'true'::bool":        {ColumnType: "BOOL", Value: true},
+		`'\x00ff'::bytea`:     {ColumnType: "BYTEA", Value: []byte{0, 255}},
+		`'{"a": 1}'::jsonb`:   {ColumnType: "JSONB", Value: []byte(`{"a": 1}`)},
+		"'2022-01-02 03:04:05+00:00'::timestamptz": {ColumnType: "TIMESTAMPTZ",
+			Value: time.Date(2022, 1, 2, 4, 4, 5, 0, time.FixedZone("CET", 3600))},
+		"'text'": {ColumnType: "VARCHAR", Value: "text"},
+		"null":   {ColumnType: "NUMERIC", Value: nil},
+	}
+
+	for expected, col := range testCases {
+		// 02 Act
+		result := formatField(col)
+
+		// 03 Assert
+		if strings.Compare(result, expected) != 0 {
+			t.Errorf(fmt.Sprintf("Expected %s, but got %s", expected, result))
+		}
+	}
+}
+
+func TestWriteStatementStreamsLargeBytea(t *testing.T) {
+	// 01 Arrange
+	repo := tests.CreateDataRepository()
+	content := []byte(strings.Repeat("a", streamedByteaThreshold+1))
+	col := sqlUtil.RowDataStructure{ColumnName: "contents", ColumnType: "BYTEA", Value: content}
+	largeObject := sqlUtil.RowDataStructure{ColumnName: "content_oid", ColumnType: LargeObjectType, Value: content}
+	fields := &streamedFields{}
+	where := fields.formatExportField(col)
+	statement := fields.statement(fmt.Sprintf("INSERT INTO rhnconfigcontent (contents, content_oid) SELECT %s, %s WHERE NOT EXISTS "+
+		"(SELECT 1 FROM rhnconfigcontent WHERE contents = %s);", fields.formatExportField(col), fields.formatExportField(largeObject), where))
+
+	// 02 Act
+	writeStatement(repo.Writer, statement)
+	written := strings.Join(repo.GetWriterBuffer(), "")
+
+	// 03 Assert
+	if len(statement.streamed) != 3 || strings.Contains(statement.text, "aaaa") {
+		t.Errorf("Large values should be streamed, got %d streamed values", len(statement.streamed))
+	}
+	expected := fmt.Sprintf("INSERT INTO rhnconfigcontent (contents, content_oid) SELECT %s, %s WHERE NOT EXISTS "+
+		"(SELECT 1 FROM rhnconfigcontent WHERE contents = %s);\n", formatField(col), formatField(largeObject), formatField(col))
+	if strings.Compare(written, expected) != 0 {
+		t.Errorf("Streamed statement does not match the in memory one")
+	}
+}
+
+func TestWriteStatementKeepsNulCharacters(t *testing.T) {
+	// 01 Arrange
+	repo := tests.CreateDataRepository()
+	statement := exportStatement{text: "SELECT '\x00bytea:0\x00';"}
+
+	// 02 Act
+	writeStatement(repo.Writer, statement)
+
+	// 03 Assert
+	if written := strings.Join(repo.GetWriterBuffer(), ""); written != statement.text+"\n" {
+		t.Errorf("Statement without streamed values should be written as is, got %q", written)
+	}
+}
+
+func TestSubstituteLargeObjects(t *testing.T) {
+	// 01 Arrange
+	repo := tests.CreateDataRepository()
+	table := schemareader.Table{Name: "lotable", LargeObjectColumns: map[string]bool{"content_oid": true}}
+	row := []sqlUtil.RowDataStructure{
+		{ColumnName: "id", ColumnType: "NUMERIC", Value: []byte("1")},
+		{ColumnName: "content_oid", ColumnType: "OID", Value: int64(16400)},
+	}
+	repo.ExpectPrepare("SELECT lo_get($1);")
+	repo.ExpectWithRecords("SELECT lo_get($1);", sqlmock.NewRows([]string{"lo_get"}).AddRow([]byte("lo")), "16400")
+
+	// 02 Act
+	result := substituteLargeObjects(repo.DB, table, row)
+
+	// 03 Assert
+	expected := "(SELECT lo_from_bytea(0, '\\x6c6f'::bytea))"
+	if result[1].ColumnType != LargeObjectType || strings.Compare(formatField(result[1]), expected) != 0 {
+		t.Errorf(fmt.Sprintf("Expected %s, but got %s", expected, formatField(result[1])))
+	}
+	if err := repo.ExpectationsWereMet(); err != nil {
+		t.Errorf("Large object content was not read. Error message: %s", err)
+	}
+}
+
+func TestFormatColumnAssignmentLargeObjects(t *testing.T) {
+	// 01 Arrange
+	table := schemareader.Table{
+		Name:               "lotable",
+		Columns:            []string{"id", "label", "content_oid"},
+		PKColumns:          map[string]bool{"id": true},
+		LargeObjectColumns: map[string]bool{"content_oid": true},
+	}
+
+	// 02 Act
+	result := formatColumnAssignment(table)
+
+	// 03 Assert
+	expected := "label = excluded.label,content_oid = CASE WHEN NOT EXISTS (SELECT 1 FROM pg_largeobject_metadata " +
+		"WHERE oid = lotable.content_oid) THEN excluded.content_oid WHEN lo_unlink(lotable.content_oid) = 1 " +
+		"THEN excluded.content_oid ELSE excluded.content_oid END"
+	if result != expected {
+		t.Errorf("Expected %s, but got %s", expected, result)
+	}
+}
+
+func TestGenerateRowInsertStatementNullableIndexColumn(t *testing.T) {
+	// 01 Arrange
+	repo := tests.CreateDataRepository()
+	indexName := schemareader.VirtualIndexName
+	table := schemareader.Table{
+		Name:                "rhnpackage",
+		Columns:             []string{"name", "org_id"},
+		ColumnIndexes:       map[string]int{"name": 0, "org_id": 1},
+		NullableColumns:     map[string]bool{"org_id": true},
+		MainUniqueIndexName: indexName,
+		UniqueIndexes:       map[string]schemareader.UniqueIndex{indexName: {Name: indexName, Columns: []string{"name", "org_id"}}},
+	}
+	row := []sqlUtil.RowDataStructure{
+		{ColumnName: "name", ColumnType: "VARCHAR", Value: "pkg"},
+		{ColumnName: "org_id", ColumnType: "SQL", Value: "SELECT id FROM web_customer WHERE name = 'org' LIMIT 1"},
+	}
+
+	// 02 Act
+	result := generateRowInsertStatement(repo.DB, row, table, MetaDataGraph{"rhnpackage": table}, []string{})
+
+	// 03 Assert
+	expected := "INSERT INTO rhnpackage (name, org_id)\tSELECT 'pkg',(SELECT id FROM web_customer WHERE name = 'org' LIMIT 1) " +
+		"WHERE NOT EXISTS (SELECT 1 FROM rhnpackage WHERE  name = 'pkg' AND  " +
+		"org_id IS NOT DISTINCT FROM (SELECT id FROM web_customer WHERE name = 'org' LIMIT 1));"
+	if strings.Compare(result.text, expected) != 0 {
+		t.Errorf(fmt.Sprintf("Expected %s, but got %s", expected, result))
+	}
+}
+
+func TestIsRowAlreadyExported(t *testing.T) {
+	// 01 Arrange
+	exportedKeys = &ExportedKeysCache{Tables: make(map[string]map[string]string), Pending: make(map[string]map[string]string)}
+	defer func() { exportedKeys = nil }()
+	table := schemareader.Table{
+		Name:                "rhnpackagename",
+		MainUniqueIndexName: "rhn_pn_name_uq",
+		UniqueIndexes:       map[string]schemareader.UniqueIndex{"rhn_pn_name_uq": {Name: "rhn_pn_name_uq", Columns: []string{"name"}}},
+	}
+	row := func(id string) []sqlUtil.RowDataStructure {
+		return []sqlUtil.RowDataStructure{
+			{ColumnName: "id", ColumnType: "NUMERIC", Value: []byte(id)},
+			{ColumnName: "name", ColumnType: "VARCHAR", Value: "vim"},
+		}
+	}
+
+	// 02 Act
+	first := isRowAlreadyExported(table, row("1"))
+	repeated := isRowAlreadyExported(table, row("1"))
+	changed := isRowAlreadyExported(table, row("2"))
+
+	// 03 Assert
+	if first || changed {
+		t.Errorf("New and changed rows should be exported")
+	}
+	if !repeated {
+		t.Errorf("Unchanged row should be skipped")
+	}
+	if len(exportedKeys.Pending["rhnpackagename"]) != 1 || len(exportedKeys.Tables) != 0 {
+		t.Errorf("Expected one pending key, got %v", exportedKeys)
+	}
+}
+
+func TestIsRowAlreadyExportedWithoutUniqueIndex(t *testing.T) {
+	// 01 Arrange
+	exportedKeys = &ExportedKeysCache{Tables: make(map[string]map[string]string), Pending: make(map[string]map[string]string)}
+	defer func() { exportedKeys = nil }()
+	table := schemareader.Table{Name: "rhnchannelcomps"}
+	row := []sqlUtil.RowDataStructure{{ColumnName: "relative_filename", ColumnType: "VARCHAR", Value: "comps.xml"}}
+
+	// 02 Act
+	first := isRowAlreadyExported(table, row)
+	repeated := isRowAlreadyExported(table, row)
+
+	// 03 Assert
+	if first || repeated {
+		t.Errorf("Rows of tables without unique index should always be exported")
+	}
+	if len(exportedKeys.Pending) != 0 {
+		t.Errorf("Rows of tables without unique index should not be cached, got %v", exportedKeys.Pending)
+	}
+}
+
+func TestLoadExportedKeysCachePending(t *testing.T) {
+	// 01 Arrange
+	path := filepath.Join(t.TempDir(), "target.keys")
+	defer func() { exportedKeys = nil }()
+	LoadExportedKeysCache(path, false)
+	exportedKeys.Pending["rhnpackagename"] = map[string]string{"'vim'": "hash"}
+	SaveExportedKeysCache()
+
+	// 02 Act
+	LoadExportedKeysCache(path, false)
+	notImported := len(exportedKeys.Tables["rhnpackagename"])
+	LoadExportedKeysCache(path, true)
+	imported := len(exportedKeys.Tables["rhnpackagename"])
+
+	// 03 Assert
+	if notImported != 0 {
+		t.Errorf("Rows of an export not confirmed as imported should be exported again")
+	}
+	if imported != 1 || len(exportedKeys.Pending) != 0 {
+		t.Errorf("Rows of an imported export should be skipped, got %v", exportedKeys)
+	}
+}
+
+func TestFormatTargetRowQuery(t *testing.T) {
+	// 01 Arrange
+	indexName := "rhn_channel_label_uq"
+	table := schemareader.Table{
+		Name:                "rhnchannel",
+		Columns:             []string{"id", "label", "org_id"},
+		PKColumns:           map[string]bool{"id": true},
+		PKSequence:          "rhn_channel_id_seq",
+		MainUniqueIndexName: indexName,
+		UniqueIndexes:       map[string]schemareader.UniqueIndex{indexName: {Name: indexName, Columns: []string{"label"}}},
+	}
+	row := []sqlUtil.RowDataStructure{
+		{ColumnName: "id", ColumnType: "SQL", Value: "SELECT nextval('rhn_channel_id_seq')"},
+		{ColumnName: "label", ColumnType: "VARCHAR", Value: "channel"},
+		{ColumnName: "org_id", ColumnType: "SQL", Value: "SELECT id FROM web_customer WHERE name = 'org' LIMIT 1"},
+	}
+
+	// 02 Act
+	result := formatTargetRowQuery(table, row)
+
+	// 03 Assert
+	expected := "SELECT 1 FROM rhnchannel WHERE label IS NOT DISTINCT FROM 'channel' AND " +
+		"org_id IS NOT DISTINCT FROM (SELECT id FROM web_customer WHERE name = 'org' LIMIT 1) LIMIT 1;"
+	if strings.Compare(result, expected) != 0 {
+		t.Errorf(fmt.Sprintf("Expected %s, but got %s", expected, result))
+	}
+}
+
+func TestWriteAnalyzeStatements(t *testing.T) {
+	// 01 Arrange
+	repo := tests.CreateDataRepository()
+	writtenTables = make(map[string]bool)
+	recordWrittenTable("rhnpackage")
+	recordWrittenTable("rhnchannel")
+	recordWrittenTable("rhnpackage")
+
+	// 02 Act
+	WriteAnalyzeStatements(repo.Writer)
+	written := strings.Join(repo.GetWriterBuffer(), "")
+
+	// 03 Assert
+	expected := "ANALYZE rhnchannel;\nANALYZE rhnpackage;\n"
+	if strings.Compare(written, expected) != 0 {
+		t.Errorf(fmt.Sprintf("Expected %s, but got %s", expected, written))
+	}
+	if tableName, ok := AnalyzedTable("ANALYZE rhnchannel;\n"); !ok || tableName != "rhnchannel" {
+		t.Errorf("Expected the analyzed table rhnchannel, got %s", tableName)
+	}
+	if len(writtenTables) != 0 {
+		t.Errorf("Written tables not reset")
+	}
+}
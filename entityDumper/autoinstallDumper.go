@@ -0,0 +1,135 @@
+package entityDumper
+
+import (
+	"bufio"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/dumper"
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+var autoinstallTableNames = []string{
+	// autoinstallable distributions
+	"rhnkickstartabletree",
+	"rhnkstreefile",
+	"rhnkstreetype",
+	"rhnksinstalltype",
+	// autoinstallation profiles
+	"rhnksdata",
+	"rhnkickstartdefaults",
+	"rhnkickstartcommand",
+	"rhnkickstartcommandname",
+	"rhnkickstartscript",
+	"rhnkickstartpackage",
+	"rhnkickstartchildchannel",
+	"rhnkickstartvirtualizationtype",
+	// GPG keys and SSL certificates of the profiles
+	"rhncryptokeykickstart",
+	"rhncryptokey",
+	"rhncryptokeytype",
+}
+
+// profile contents which are replaced as a whole, so entries removed on the source are removed on the target
+var autoinstallTablesToClean = []string{"rhnkickstartcommand", "rhnkickstartscript", "rhnkickstartpackage",
+	"rhnkickstartchildchannel", "rhnkstreefile"}
+
+// Cobbler collections of the distributions and profiles, by table
+var cobblerCollections = map[string]string{
+	"rhnkickstartabletree": "distros",
+	"rhnksdata":            "profiles",
+}
+
+func AutoinstallTableNames() []string {
+	return autoinstallTableNames
+}
+
+// formatOrgFilter returns the filter restricting rows to the organizations to export
+func formatOrgFilter(options DumperOptions) string {
+	if len(options.Orgs) == 0 {
+		return ""
+	}
+	orgs := make([]string, 0, len(options.Orgs))
+	for _, org := range options.Orgs {
+		orgs = append(orgs, fmt.Sprintf("%d", org))
+	}
+	return fmt.Sprintf(" AND t.org_id IN (%s)", strings.Join(orgs, ", "))
+}
+
+func dumpAutoinstallData(db *sql.DB, writer *bufio.Writer, options DumperOptions) {
+	schemaMetadata := schemareader.ReadTablesSchema(db, AutoinstallTableNames())
+	applyWhereFilters(schemaMetadata, options)
+	applyCryptoKeyFilters(schemaMetadata, options)
+	log.Debug().Msg("autoinstall schema metadata loaded")
+
+	// distributions first, so profiles only reference them
+	variables := make([]AutoinstallVariables, 0)
+	labels := dumpAutoinstallRows(db, writer, schemaMetadata, options, "rhnkickstartabletree", "autoinstallable distributions", &variables)
+	markAsUnexported(schemaMetadata, []string{"rhnkickstartabletree", "rhnkstreefile"})
+	labels = append(labels, dumpAutoinstallRows(db, writer, schemaMetadata, options, "rhnksdata", "autoinstallation profiles", &variables)...)
+	writeAutoinstallVariables(options.GetOutputFolderAbsPath(), variables)
+
+	// tells the import to synchronize cobbler with the imported distributions and profiles
+	if len(labels) > 0 {
+		content := strings.Join(labels, "\n") + "\n"
+		if err := os.WriteFile(options.GetOutputFolderAbsPath()+"/exportedAutoinstall.txt", []byte(content), 0644); err != nil {
+			utils.Panic().Err(err).Msg("error creating exportedAutoinstall file")
+		}
+	}
+}
+
+// dumpAutoinstallRows writes the distributions or profiles of the table, adding their Cobbler variables
+func dumpAutoinstallRows(db *sql.DB, writer *bufio.Writer, schemaMetadata map[string]schemareader.Table,
+	options DumperOptions, tableName string, description string, variables *[]AutoinstallVariables) []string {
+
+	sqlForExistingRows := fmt.Sprintf(`SELECT t.id, t.label, wc.name, t.cobbler_id FROM %s t LEFT JOIN web_customer wc ON wc.id = t.org_id
+		WHERE 1 = 1%s`, tableName, formatOrgFilter(options))
+	if options.StartingDate != "" {
+		sqlForExistingRows = fmt.Sprintf("%s AND t.modified > '%s'::timestamp", sqlForExistingRows, options.StartingDate)
+	}
+	rows := sqlUtil.ExecuteQueryWithResults(db, sqlForExistingRows+" ORDER BY t.id;")
+	if len(rows) == 0 {
+		log.Info().Msgf("No %s found to export", description)
+		return nil
+	}
+	log.Info().Msgf("%d %s to process", len(rows), description)
+	writer.WriteString(fmt.Sprintf("-- %s\n", description))
+	cobblerVariables := readCobblerVariables(cobblerCollections[tableName])
+	labels := make([]string, 0, len(rows))
+	for _, row := range rows {
+		labels = append(labels, fmt.Sprintf("%s", row[1].Value))
+		if row[3].Value != nil {
+			if rowVariables, ok := cobblerVariables[fmt.Sprintf("%s", row[3].Value)]; ok {
+				org := ""
+				if row[2].Value != nil {
+					org = dumper.AnonymizeValue("web_customer", "name", fmt.Sprintf("%s", row[2].Value))
+				}
+				// the values of the variables are anonymized like the exported pillars
+				rowVariables = dumper.AnonymizeDocument(rowVariables).(map[string]interface{})
+				*variables = append(*variables, AutoinstallVariables{Table: tableName, Label: labels[len(labels)-1], Org: org, Variables: rowVariables})
+			}
+		}
+		log.Debug().Msgf("Exporting %s %s", tableName, row[1].Value)
+		whereClause := fmt.Sprintf("id = '%s'", row[0].Value)
+		tableData := crawlTableData(db, schemaMetadata, schemaMetadata[tableName], whereClause, options)
+		// organizations are matched by name on import, so the ids are not the same
+		orgFilter := "org_id IS NULL"
+		if row[2].Value != nil {
+			orgFilter = fmt.Sprintf("org_id = (SELECT id FROM web_customer WHERE name = %s)", pq.QuoteLiteral(fmt.Sprintf("%s", row[2].Value)))
+		}
+		printOptions := dumper.PrintSqlOptions{
+			TablesToClean: autoinstallTablesToClean,
+			CleanWhereClause: fmt.Sprintf(`WHERE %s.id = (SELECT id FROM %s WHERE label = %s AND %s)`,
+				tableName, tableName, pq.QuoteLiteral(fmt.Sprintf("%s", row[1].Value)), orgFilter),
+		}
+		dumper.PrintTableDataOrdered(db, writer, schemaMetadata, schemaMetadata[tableName], tableData, printOptions)
+		writer.Flush()
+	}
+	return labels
+}
@@ -0,0 +1,114 @@
+package entityDumper
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// AutoinstallVariablesFileName lists the variables of the exported distributions and profiles, which are stored by Cobbler
+const AutoinstallVariablesFileName = "autoinstallVariables.json"
+
+// collections of the Cobbler records, one JSON file per distribution or profile
+var cobblerCollectionsDir = "/var/lib/cobbler/collections"
+
+// AutoinstallVariables are the variables of one distribution or profile, found by label in the organization
+type AutoinstallVariables struct {
+	Table     string                 `json:"table"`
+	Label     string                 `json:"label"`
+	Org       string                 `json:"org,omitempty"`
+	Variables map[string]interface{} `json:"variables"`
+}
+
+// cobblerRecord is the part of a Cobbler collection file holding the variables, named ks_meta before Cobbler 3.3
+type cobblerRecord struct {
+	Uid             string      `json:"uid"`
+	AutoinstallMeta interface{} `json:"autoinstall_meta"`
+	KsMeta          interface{} `json:"ks_meta"`
+}
+
+// readCobblerVariables reads the variables of the Cobbler records of a collection by uid
+func readCobblerVariables(collection string) map[string]map[string]interface{} {
+	variables := make(map[string]map[string]interface{})
+	files, err := filepath.Glob(filepath.Join(cobblerCollectionsDir, collection, "*.json"))
+	if err != nil || len(files) == 0 {
+		log.Warn().Msgf("No Cobbler %s found in %s, variables are not exported", collection, cobblerCollectionsDir)
+		return variables
+	}
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			utils.Panic().Err(err).Msgf("error reading Cobbler record %s", file)
+		}
+		record := cobblerRecord{}
+		if err := json.Unmarshal(content, &record); err != nil {
+			log.Warn().Err(err).Msgf("Cobbler record %s cannot be read, its variables are not exported", file)
+			continue
+		}
+		meta := record.AutoinstallMeta
+		if meta == nil {
+			meta = record.KsMeta
+		}
+		if recordVariables := parseCobblerVariables(meta); len(recordVariables) > 0 {
+			variables[record.Uid] = recordVariables
+		}
+	}
+	return variables
+}
+
+// parseCobblerVariables reads the variables of a record, stored as an object or as "key=value" words.
+// Inherited variables are not part of the record.
+func parseCobblerVariables(meta interface{}) map[string]interface{} {
+	switch value := meta.(type) {
+	case map[string]interface{}:
+		return value
+	case string:
+		variables := make(map[string]interface{})
+		for _, word := range strings.Fields(value) {
+			if word == "<<inherit>>" {
+				continue
+			}
+			if equal := strings.Index(word, "="); equal > 0 {
+				variables[word[:equal]] = word[equal+1:]
+			} else {
+				variables[word] = ""
+			}
+		}
+		return variables
+	}
+	return nil
+}
+
+// writeAutoinstallVariables writes the variables of the exported distributions and profiles, if any
+func writeAutoinstallVariables(outputFolder string, variables []AutoinstallVariables) {
+	if len(variables) == 0 {
+		return
+	}
+	content, err := json.MarshalIndent(variables, "", "  ")
+	if err != nil {
+		utils.Panic().Err(err).Msg("error encoding autoinstallation variables")
+	}
+	if err := os.WriteFile(filepath.Join(outputFolder, AutoinstallVariablesFileName), content, 0644); err != nil {
+		utils.Panic().Err(err).Msg("error writing autoinstallation variables")
+	}
+}
+
+// ReadAutoinstallVariables reads the variables of the imported distributions and profiles, nil without variables
+func ReadAutoinstallVariables(importFolder string) []AutoinstallVariables {
+	content, err := os.ReadFile(filepath.Join(importFolder, AutoinstallVariablesFileName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		utils.Fatal().Err(err).Msg("error reading autoinstallation variables")
+	}
+	variables := make([]AutoinstallVariables, 0)
+	if err := json.Unmarshal(content, &variables); err != nil {
+		utils.Fatal().Err(err).Msg("autoinstallation variables are corrupted")
+	}
+	return variables
+}
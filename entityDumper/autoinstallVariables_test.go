@@ -0,0 +1,79 @@
+package entityDumper
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/tests"
+)
+
+func writeCobblerRecord(t *testing.T, collection string, name string, content string) {
+	folder := filepath.Join(cobblerCollectionsDir, collection)
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(folder, name+".json"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadCobblerVariables(t *testing.T) {
+	defer func(dir string) { cobblerCollectionsDir = dir }(cobblerCollectionsDir)
+	cobblerCollectionsDir = t.TempDir()
+	writeCobblerRecord(t, "profiles", "web", `{"uid": "a1", "autoinstall_meta": {"org": "web", "port": 8080}}`)
+	writeCobblerRecord(t, "profiles", "legacy", `{"uid": "b2", "ks_meta": "<<inherit>> org=db debug"}`)
+	writeCobblerRecord(t, "profiles", "plain", `{"uid": "c3", "autoinstall_meta": {}}`)
+
+	result := readCobblerVariables("profiles")
+
+	expected := map[string]map[string]interface{}{
+		"a1": {"org": "web", "port": float64(8080)},
+		"b2": {"org": "db", "debug": ""},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+}
+
+func TestDumpAutoinstallRowsWithVariables(t *testing.T) {
+	defer func(dir string) { cobblerCollectionsDir = dir }(cobblerCollectionsDir)
+	cobblerCollectionsDir = t.TempDir()
+	writeCobblerRecord(t, "profiles", "web", `{"uid": "a1", "autoinstall_meta": {"org": "web"}}`)
+	repo := tests.CreateDataRepository()
+	schemaMetadata := map[string]schemareader.Table{"rhnksdata": {
+		Name:                "rhnksdata",
+		Export:              true,
+		Columns:             []string{"id", "label"},
+		PKColumns:           map[string]bool{"id": true},
+		ColumnIndexes:       map[string]int{"id": 0, "label": 1},
+		MainUniqueIndexName: "rhn_ks_label_uq",
+		UniqueIndexes:       map[string]schemareader.UniqueIndex{"rhn_ks_label_uq": {Name: "rhn_ks_label_uq", Columns: []string{"label"}}},
+	}}
+	repo.ExpectWithRecords(`SELECT t.id, t.label, wc.name, t.cobbler_id FROM rhnksdata t LEFT JOIN web_customer wc ON wc.id = t.org_id
+		WHERE 1 = 1 ORDER BY t.id;`, sqlmock.NewRows([]string{"id", "label", "name", "cobbler_id"}).
+		AddRow("1", "web-profile", "org", "a1").
+		AddRow("2", "db-profile", nil, nil))
+	for _, row := range [][]string{{"1", "web-profile"}, {"2", "db-profile"}} {
+		repo.ExpectWithRecords("SELECT * FROM rhnksdata WHERE id = '"+row[0]+"' ;", sqlmock.NewRows([]string{"id", "label"}).AddRow(row[0], row[1]))
+		repo.ExpectWithRecords("SELECT id, label FROM rhnksdata WHERE (id) IN (('"+row[0]+"')) ORDER BY label;",
+			sqlmock.NewRows([]string{"id", "label"}).AddRow(row[0], row[1]))
+	}
+	var output bytes.Buffer
+	variables := make([]AutoinstallVariables, 0)
+
+	labels := dumpAutoinstallRows(repo.DB, bufio.NewWriter(&output), schemaMetadata, DumperOptions{}, "rhnksdata", "autoinstallation profiles", &variables)
+
+	if !reflect.DeepEqual(labels, []string{"web-profile", "db-profile"}) {
+		t.Errorf("labels of the cobbler sync should be the exported profiles, got %v", labels)
+	}
+	expected := []AutoinstallVariables{{Table: "rhnksdata", Label: "web-profile", Org: "org", Variables: map[string]interface{}{"org": "web"}}}
+	if !reflect.DeepEqual(variables, expected) {
+		t.Errorf("expected %v, got %v", expected, variables)
+	}
+}
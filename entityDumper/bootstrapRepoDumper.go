@@ -0,0 +1,60 @@
+package entityDumper
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/dumper"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// BootstrapRepositoriesDir holds the bootstrap repositories created by mgr-create-bootstrap-repo, which clients
+// are onboarded from
+const BootstrapRepositoriesDir = "/srv/www/htdocs/pub/repositories"
+
+// BootstrapRepositoriesFolder is the folder of the export with the files of the bootstrap repositories
+const BootstrapRepositoriesFolder = "bootstrapRepositories"
+
+// BootstrapRepositoriesFileName lists the exported bootstrap repositories, by path relative to BootstrapRepositoriesDir
+const BootstrapRepositoriesFileName = "bootstrapRepositories.txt"
+
+var bootstrapRepositoriesDir = BootstrapRepositoriesDir
+
+// dumpBootstrapRepositories copies the bootstrap repositories with their packages and metadata, and lists them
+func dumpBootstrapRepositories(outputFolderAbs string) {
+	if _, err := os.Stat(bootstrapRepositoriesDir); os.IsNotExist(err) {
+		log.Info().Msgf("No bootstrap repositories found in %s", bootstrapRepositoriesDir)
+		return
+	}
+	outputFolder := filepath.Join(outputFolderAbs, BootstrapRepositoriesFolder)
+	repositories := make([]string, 0)
+	err := filepath.Walk(bootstrapRepositoriesDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relativePath, _ := filepath.Rel(bootstrapRepositoriesDir, path)
+		if info.IsDir() {
+			// a repository is a folder with repository metadata
+			if _, err := os.Stat(filepath.Join(path, "repodata", "repomd.xml")); err == nil {
+				repositories = append(repositories, relativePath)
+			}
+			return nil
+		}
+		if stat, err := os.Stat(path); err != nil || !stat.Mode().IsRegular() {
+			log.Debug().Msgf("Bootstrap repository entry %s is not a file, not exported", path)
+			return nil
+		}
+		if _, err := dumper.Copy(path, filepath.Join(outputFolder, relativePath)); err != nil {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		utils.Fatal().Err(err).Msg("Error copying the bootstrap repositories")
+	}
+	sort.Strings(repositories)
+	writeLines(filepath.Join(outputFolderAbs, BootstrapRepositoriesFileName), repositories)
+	log.Info().Msgf("%d bootstrap repositories exported", len(repositories))
+}
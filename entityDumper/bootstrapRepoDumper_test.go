@@ -0,0 +1,31 @@
+package entityDumper
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+func TestDumpBootstrapRepositories(t *testing.T) {
+	defer func(dir string) { bootstrapRepositoriesDir = dir }(bootstrapRepositoriesDir)
+	bootstrapRepositoriesDir = t.TempDir()
+	repository := filepath.Join(bootstrapRepositoriesDir, "sle", "15", "4", "bootstrap")
+	os.MkdirAll(filepath.Join(repository, "repodata"), 0755)
+	os.WriteFile(filepath.Join(repository, "repodata", "repomd.xml"), []byte("<repomd/>"), 0644)
+	os.MkdirAll(filepath.Join(repository, "x86_64"), 0755)
+	os.WriteFile(filepath.Join(repository, "x86_64", "venv-salt-minion.rpm"), []byte("rpm"), 0644)
+	outputFolder := t.TempDir()
+
+	dumpBootstrapRepositories(outputFolder)
+
+	copied, err := os.ReadFile(filepath.Join(outputFolder, BootstrapRepositoriesFolder, "sle", "15", "4", "bootstrap", "x86_64", "venv-salt-minion.rpm"))
+	if err != nil || string(copied) != "rpm" {
+		t.Errorf("Package of the bootstrap repository not copied: %v", err)
+	}
+	repositories := utils.ReadFileByLine(filepath.Join(outputFolder, BootstrapRepositoriesFileName))
+	if len(repositories) != 1 || repositories[0] != "sle/15/4/bootstrap" {
+		t.Errorf("Unexpected bootstrap repositories %v", repositories)
+	}
+}
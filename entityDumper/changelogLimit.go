@@ -0,0 +1,35 @@
+package entityDumper
+
+import (
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+)
+
+// applyChangelogLimit restricts the exported changelog entries of every package to the newest ones
+func applyChangelogLimit(schemaMetadata map[string]schemareader.Table, options DumperOptions) {
+	if options.ChangelogLimit <= 0 {
+		return
+	}
+	table, ok := schemaMetadata["rhnpackagechangelogrec"]
+	if !ok {
+		return
+	}
+	filter := formatChangelogLimitFilter(options.ChangelogLimit)
+	log.Debug().Msgf("Exporting the newest %d changelog entries of every package", options.ChangelogLimit)
+	if len(table.WhereFilter) > 0 {
+		table.WhereFilter = "(" + table.WhereFilter + ") AND " + filter
+	} else {
+		table.WhereFilter = filter
+	}
+	schemaMetadata["rhnpackagechangelogrec"] = table
+}
+
+// formatChangelogLimitFilter returns the condition selecting the newest changelog entries of the package of the row
+func formatChangelogLimitFilter(limit int) string {
+	return fmt.Sprintf("changelog_data_id IN (SELECT newest.changelog_data_id FROM rhnpackagechangelogrec newest "+
+		"INNER JOIN rhnpackagechangelogdata ON rhnpackagechangelogdata.id = newest.changelog_data_id "+
+		"WHERE newest.package_id = rhnpackagechangelogrec.package_id "+
+		"ORDER BY rhnpackagechangelogdata.time DESC, rhnpackagechangelogdata.id DESC LIMIT %d)", limit)
+}
@@ -0,0 +1,25 @@
+package entityDumper
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+)
+
+func TestApplyChangelogLimit(t *testing.T) {
+	schemaMetadata := map[string]schemareader.Table{
+		"rhnpackagechangelogrec": {Name: "rhnpackagechangelogrec", WhereFilter: "package_id > 10"},
+	}
+
+	applyChangelogLimit(schemaMetadata, DumperOptions{})
+	if schemaMetadata["rhnpackagechangelogrec"].WhereFilter != "package_id > 10" {
+		t.Errorf("changelogs should not be limited by default, got %s", schemaMetadata["rhnpackagechangelogrec"].WhereFilter)
+	}
+
+	applyChangelogLimit(schemaMetadata, DumperOptions{ChangelogLimit: 5})
+	filter := schemaMetadata["rhnpackagechangelogrec"].WhereFilter
+	if !strings.HasPrefix(filter, "(package_id > 10) AND changelog_data_id IN (") || !strings.HasSuffix(filter, "LIMIT 5)") {
+		t.Errorf("unexpected changelog filter %s", filter)
+	}
+}
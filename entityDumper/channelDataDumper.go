@@ -41,7 +41,12 @@ func SoftwareChannelTableNames() []string {
 		"rhnreleasechannelmap", // clean
 		"rhndistchannelmap",    // clean
 		"rhnchannelcomps",
+		// modules of the modular channels, on servers supporting AppStreams
+		"suseappstream",
+		"suseappstreampackage",
+		"suseappstreamapi",
 		"rhnchannelfamilymembers",
+		"rhnchanneltrust", // organizations the protected channels are shared with
 		"rhnerrata",
 		"rhnerratacloned",  // add only if there are corresponding rows in rhnerrata
 		"rhnchannelerrata", // clean
@@ -57,8 +62,11 @@ func SoftwareChannelTableNames() []string {
 		"rhnerratafilechannel",       // clean
 		"rhnerratafilepackage",       // clean
 		"rhnerratafilepackagesource", // clean
+		// signature keys of the packages, whose providers tell vendor and custom packages apart
 		"rhnpackagekeyassociation",
 		"rhnpackagekey",
+		"rhnpackagekeytype",
+		"rhnpackageprovider",
 		"rhnerratabuglist", // clean
 		"rhncve",
 		"rhnerratacve",     // clean
@@ -83,6 +91,35 @@ func SoftwareChannelTableNames() []string {
 		"rhnpackagesupplements",
 		"susemddata",    // clean
 		"susemdkeyword", // clean
+		// custom repositories, credentials are scrubbed unless requested
+		"rhncontentsource",
+		"rhnchannelcontentsource",
+	}
+}
+
+// fileListTableName is the table with the file lists of the packages
+const fileListTableName = "rhnpackagefile"
+
+// channelTableNames returns the software channel tables exported with the options
+func channelTableNames(options DumperOptions) []string {
+	tableNames := SoftwareChannelTableNames()
+	if !options.ExcludeFileLists {
+		return tableNames
+	}
+	result := make([]string, 0, len(tableNames))
+	for _, tableName := range tableNames {
+		if tableName != fileListTableName {
+			result = append(result, tableName)
+		}
+	}
+	return result
+}
+
+// repositoryCredentialsTableNames are the tables with the SSL certificates and keys of the repositories
+func repositoryCredentialsTableNames() []string {
+	return []string{
+		"rhncontentsourcessl",
+		"rhncryptokey",
 	}
 }
 
@@ -100,23 +137,29 @@ func ProductsTableNames() []string {
 	}
 }
 
+// productBootstrapTableNames returns the product tables exported without channels, for servers without SCC access.
+// Product channels are only inserted for the channels already available on the target server.
+func productBootstrapTableNames() []string {
+	return append(ProductsTableNames(), "suseproductchannel")
+}
+
 func validateExportFolder(outputFolderAbs string) {
 	err := utils.FolderExists(outputFolderAbs)
 	if err != nil {
 		if os.IsNotExist(err) {
 			err := os.MkdirAll(outputFolderAbs, 0755)
 			if err != nil {
-				log.Fatal().Err(err).Msg("Error creating directory")
+				utils.Fatal().Err(err).Msg("Error creating directory")
 			}
 		} else {
-			log.Fatal().Err(err).Msg("Error getting output folder")
+			utils.Fatal().Err(err).Msg("Error getting output folder")
 		}
 	}
 	outputFolder, _ := os.Open(outputFolderAbs)
 	defer outputFolder.Close()
 	_, errEmpty := outputFolder.Readdirnames(1) // Or f.Readdir(1)
 	if errEmpty != io.EOF {
-		log.Fatal().Msg(fmt.Sprintf("export location is not empty: %s", outputFolderAbs))
+		utils.Fatal().Int(utils.ExitCodeField, utils.ExitConfigError).Msg(fmt.Sprintf("export location is not empty: %s", outputFolderAbs))
 	}
 }
 
@@ -129,21 +172,22 @@ var singleChannelSql = "select label from rhnchannel " +
 func loadChannelsToProcess(db *sql.DB, options DumperOptions) []string {
 	log.Trace().Msg("Loading channel list")
 	channels := channelsProcess{make(map[string]bool), make([]string, 0)}
-	for _, singleChannel := range options.ChannelLabels {
+	singleChannels, channelsWithChildren := options.ChannelSelection()
+	for _, singleChannel := range singleChannels {
 		if _, ok := channels.channelsMap[singleChannel]; !ok {
 			dbChannel := sqlUtil.ExecuteQueryWithResults(db, singleChannelSql, singleChannel)
 			if len(dbChannel) == 0 {
-				log.Fatal().Msgf("Channel not found: %s", singleChannel)
+				utils.Fatal().Int(utils.ExitCodeField, utils.ExitConfigError).Msgf("Channel not found: %s", singleChannel)
 			}
 			channels.addChannelLabel(singleChannel)
 		}
 	}
 
-	for _, channelChildren := range options.ChannelWithChildrenLabels {
+	for _, channelChildren := range channelsWithChildren {
 		if _, ok := channels.channelsMap[channelChildren]; !ok {
 			dbChannel := sqlUtil.ExecuteQueryWithResults(db, singleChannelSql, channelChildren)
 			if len(dbChannel) == 0 {
-				log.Fatal().Msgf("Channel not found: %s", channelChildren)
+				utils.Fatal().Int(utils.ExitCodeField, utils.ExitConfigError).Msgf("Channel not found: %s", channelChildren)
 			}
 			channels.addChannelLabel(channelChildren)
 			childrenChannels := sqlUtil.ExecuteQueryWithResults(db, childChannelSql, channelChildren)
@@ -156,13 +200,53 @@ func loadChannelsToProcess(db *sql.DB, options DumperOptions) []string {
 
 		}
 	}
-	log.Debug().Msgf("Channels to export: %s", strings.Join(channels.channels, ","))
-	return channels.channels
+	exportedChannels := withCloneOrigins(db, channels, options.IncludeCloneOrigins)
+	log.Debug().Msgf("Channels to export: %s", strings.Join(exportedChannels, ","))
+	return exportedChannels
 }
 
-func processAndInsertProducts(db *sql.DB, writer *bufio.Writer) {
+var cloneOriginSql = "select original.label from rhnchannelcloned " +
+	"inner join rhnchannel clone on clone.id = rhnchannelcloned.id " +
+	"inner join rhnchannel original on original.id = rhnchannelcloned.original_id " +
+	"where clone.label = $1"
+
+// withCloneOrigins returns the channels preceded by the channels they were cloned from, when requested.
+// The clone relationships are only imported when both channels exist on the target server, so the clones
+// whose origins are not exported get a warning otherwise.
+func withCloneOrigins(db *sql.DB, channels channelsProcess, includeOrigins bool) []string {
+	result := channelsProcess{make(map[string]bool), make([]string, 0)}
+	var addChannel func(label string)
+	addChannel = func(label string) {
+		if result.channelsMap[label] {
+			return
+		}
+		// marked before the origins, so clone cycles stop
+		result.channelsMap[label] = true
+		for _, origin := range sqlUtil.ExecuteQueryWithResults(db, cloneOriginSql, label) {
+			originLabel := fmt.Sprintf("%v", origin[0].Value)
+			if includeOrigins {
+				addChannel(originLabel)
+			} else if !channels.channelsMap[originLabel] {
+				log.Warn().Msgf("Channel %s is a clone of %s, which is not exported: the clone relationship is only imported if %s exists on the target server",
+					label, originLabel, originLabel)
+			}
+		}
+		result.channels = append(result.channels, label)
+	}
+	for _, label := range channels.channels {
+		addChannel(label)
+	}
+	return result.channels
+}
+
+func processAndInsertProducts(db *sql.DB, writer *bufio.Writer, options DumperOptions) {
 	log.Trace().Msg("Processing product tables")
-	schemaMetadata := schemareader.ReadTablesSchema(db, ProductsTableNames())
+	tableNames := ProductsTableNames()
+	if options.Products {
+		tableNames = productBootstrapTableNames()
+	}
+	schemaMetadata := schemareader.ReadTablesSchema(db, tableNames)
+	applyWhereFilters(schemaMetadata, options)
 	startingTables := []schemareader.Table{schemaMetadata["suseproducts"]}
 
 	var whereFilterClause = func(table schemareader.Table) string {
@@ -184,12 +268,26 @@ func processAndInsertChannels(db *sql.DB, writer *bufio.Writer, options DumperOp
 	channels := loadChannelsToProcess(db, options)
 	log.Info().Msg(fmt.Sprintf("%d channels to process", len(channels)))
 
-	schemaMetadata := schemareader.ReadTablesSchema(db, SoftwareChannelTableNames())
+	tableNames := append(channelTableNames(options), channelCryptoKeyTableNames()...)
+	schemaMetadata := schemareader.ReadTablesSchema(db, tableNames)
+	applyWhereFilters(schemaMetadata, options)
+	applyRepositoryFilters(schemaMetadata, options)
+	applyCryptoKeyFilters(schemaMetadata, options)
+	applyChannelTrustFilters(schemaMetadata, options)
+	applyChangelogLimit(schemaMetadata, options)
 	log.Debug().Msg("channel schema metadata loaded")
 
+	if options.ChannelSubdirectories {
+		for count, channelLabel := range channels {
+			log.Info().Msg(fmt.Sprintf("Processing channel [%d/%d] %s", count+1, len(channels), channelLabel))
+			processChannelSubdirectory(db, channelLabel, schemaMetadata, options)
+		}
+		return
+	}
+
 	fileChannels, err := os.Create(options.GetOutputFolderAbsPath() + "/exportedChannels.txt")
 	if err != nil {
-		log.Panic().Err(err).Msg("error creating sql file")
+		utils.Panic().Err(err).Msg("error creating sql file")
 	}
 
 	defer fileChannels.Close()
@@ -204,17 +302,23 @@ func processAndInsertChannels(db *sql.DB, writer *bufio.Writer, options DumperOp
 		writer.Flush()
 		bufferWriterChannels.WriteString(fmt.Sprintf("%s\n", channelLabel))
 	}
+	if options.RepoSyncSchedules {
+		dumpRepoSyncSchedules(db, options.GetOutputFolderAbsPath(), channels)
+	}
 }
 
+// processChannel writes the data of the channel, returning the paths of the exported package files
 func processChannel(db *sql.DB, writer *bufio.Writer, channelLabel string,
-	schemaMetadata map[string]schemareader.Table, options DumperOptions) {
+	schemaMetadata map[string]schemareader.Table, options DumperOptions) []string {
 	whereFilter := fmt.Sprintf("label = '%s'", channelLabel)
-	tableData := dumper.DataCrawler(db, schemaMetadata, schemaMetadata["rhnchannel"], whereFilter, options.StartingDate)
+	tableData := crawlTableData(db, schemaMetadata, schemaMetadata["rhnchannel"], whereFilter, options)
+	dumper.AddErrataCloneOrigins(db, schemaMetadata, tableData)
+	dumper.CheckPtfPackages(db, tableData, channelLabel)
 
 	if log.Debug().Enabled() {
 		totalRows := 0
 		for _, value := range tableData.TableData {
-			totalRows = totalRows + len(value.KeyMap)
+			totalRows = totalRows + len(value.Keys)
 		}
 		log.Debug().Msgf("finished table data crawler. Total database rows to export: %d", totalRows)
 	}
@@ -229,14 +333,19 @@ func processChannel(db *sql.DB, writer *bufio.Writer, channelLabel string,
 		tableData, printOptions)
 	log.Debug().Msg("finished print table order")
 
+	if options.IncludeRepodata {
+		packageDumper.DumpRepodata(channelLabel, options.GetOutputFolderAbsPath())
+	}
 	generateCacheCalculation(channelLabel, writer)
 
+	// the comps and modules files are metadata of the channel, needed even without the packages
+	packagePaths := packageDumper.DumpCompsFiles(db, schemaMetadata, tableData, options.GetOutputFolderAbsPath())
 	if !options.MetadataOnly {
 		log.Debug().Msg("dumping all package files")
-		packageDumper.DumpPackageFiles(db, schemaMetadata, tableData, options.GetOutputFolderAbsPath())
+		packagePaths = append(packagePaths, packageDumper.DumpPackageFiles(db, schemaMetadata, tableData, options.GetOutputFolderAbsPath())...)
 	}
 	log.Debug().Msg("channel export finished")
-
+	return packagePaths
 }
 
 func generateCacheCalculation(channelLabel string, writer *bufio.Writer) {
@@ -251,12 +360,4 @@ func generateCacheCalculation(channelLabel string, writer *bufio.Writer) {
 	// refreshes the package newest page
 	channelNewPackages := fmt.Sprintf("select rhn_channel.refresh_newest_package((select id from rhnchannel where label ='%s'), 'inter-server-sync');", channelLabel)
 	writer.WriteString(channelNewPackages + "\n")
-
-	// generates the repository metadata on disk
-	repoMetadata := fmt.Sprintf(`
-		INSERT INTO rhnRepoRegenQueue
-		(id, channel_label, client, reason, force, bypass_filters, next_action, created, modified)
-		VALUES (null, '%s', 'inter server sync v2', 'channel sync', 'N', 'N', current_timestamp, current_timestamp, current_timestamp);
-	`, channelLabel)
-	writer.WriteString(repoMetadata + "\n")
 }
@@ -0,0 +1,74 @@
+package entityDumper
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/uyuni-project/inter-server-sync/tests"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+func expectCloneOrigins(repo *tests.DataRepository, label string, origins ...string) {
+	rows := sqlmock.NewRows([]string{"label"})
+	for _, origin := range origins {
+		rows.AddRow(origin)
+	}
+	repo.ExpectWithRecords(cloneOriginSql, rows, label)
+}
+
+func TestWithCloneOrigins(t *testing.T) {
+	repo := tests.CreateDataRepository()
+	expectCloneOrigins(repo, "dev-clone", "test-clone")
+	expectCloneOrigins(repo, "test-clone", "vendor")
+	expectCloneOrigins(repo, "vendor")
+	expectCloneOrigins(repo, "other")
+	channels := channelsProcess{make(map[string]bool), make([]string, 0)}
+	channels.addChannelLabel("dev-clone")
+	channels.addChannelLabel("other")
+
+	result := withCloneOrigins(repo.DB, channels, true)
+
+	expected := []string{"vendor", "test-clone", "dev-clone", "other"}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+	if err := repo.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWithoutCloneOrigins(t *testing.T) {
+	repo := tests.CreateDataRepository()
+	expectCloneOrigins(repo, "dev-clone", "vendor")
+	channels := channelsProcess{make(map[string]bool), make([]string, 0)}
+	channels.addChannelLabel("dev-clone")
+
+	result := withCloneOrigins(repo.DB, channels, false)
+
+	if !reflect.DeepEqual(result, []string{"dev-clone"}) {
+		t.Errorf("origins should only be exported when requested, got %v", result)
+	}
+}
+
+func TestChannelTableNamesWithoutFileLists(t *testing.T) {
+	if !utils.Contains(channelTableNames(DumperOptions{}), fileListTableName) {
+		t.Error("file lists should be exported by default")
+	}
+	tableNames := channelTableNames(DumperOptions{ExcludeFileLists: true})
+	if utils.Contains(tableNames, fileListTableName) {
+		t.Error("file lists should not be exported")
+	}
+	if !utils.Contains(tableNames, "rhnpackagecapability") {
+		t.Error("capabilities are needed by the package dependencies")
+	}
+}
+
+func TestChannelTableNamesWithSignatureKeys(t *testing.T) {
+	tableNames := channelTableNames(DumperOptions{})
+	for _, tableName := range []string{"rhnpackagekeyassociation", "rhnpackagekey", "rhnpackagekeytype", "rhnpackageprovider"} {
+		if !utils.Contains(tableNames, tableName) {
+			t.Errorf("%s should be exported with the packages", tableName)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package entityDumper
+
+import (
+	"bufio"
+	"compress/gzip"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/uyuni-project/inter-server-sync/dumper"
+	"github.com/uyuni-project/inter-server-sync/placeholders"
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// ChannelSubdirectoriesFolder is the folder of the export with one subdirectory per channel
+const ChannelSubdirectoriesFolder = "channels"
+
+// PackageFilesListName is the file of a channel subdirectory listing the package files of the channel,
+// which are stored once in the packages folder of the export
+const PackageFilesListName = "packageFiles.txt"
+
+// processChannelSubdirectory writes the channel with its own statements and manifest, so it can be imported alone
+func processChannelSubdirectory(db *sql.DB, channelLabel string, schemaMetadata map[string]schemareader.Table,
+	options DumperOptions) {
+
+	channelFolder := filepath.Join(options.GetOutputFolderAbsPath(), ChannelSubdirectoriesFolder, channelLabel)
+	validateExportFolder(channelFolder)
+
+	file, err := os.Create(filepath.Join(channelFolder, "sql_statements.sql.gz"))
+	if err != nil {
+		utils.Panic().Err(err).Msg("error creating sql file")
+	}
+	defer file.Close()
+	gzipFile := gzip.NewWriter(file)
+	defer gzipFile.Close()
+	writer := bufio.NewWriterSize(gzipFile, 32768)
+	defer writer.Flush()
+
+	channelOptions := options
+	channelOptions.manifest = newExportManifest(options)
+	dumper.WriteSessionSettings(writer)
+	writer.WriteString("BEGIN;\n")
+	packagePaths := processChannel(db, writer, channelLabel, schemaMetadata, channelOptions)
+	writer.WriteString("COMMIT;\n")
+	dumper.WriteAnalyzeStatements(writer)
+
+	writeManifest(channelFolder, channelOptions.manifest)
+	writeExportedOrgs(db, channelFolder)
+	writeExportedGroups(db, channelFolder)
+	if len(options.Placeholders) > 0 {
+		placeholders.WriteTokens(channelFolder, options.Placeholders)
+		placeholders.WriteColumns(channelFolder, options.PlaceholderColumns)
+	}
+	writeLines(filepath.Join(channelFolder, "exportedChannels.txt"), []string{channelLabel})
+	if options.RepoSyncSchedules {
+		dumpRepoSyncSchedules(db, channelFolder, []string{channelLabel})
+	}
+	writeLines(filepath.Join(channelFolder, PackageFilesListName), packagePaths)
+}
+
+// ChannelSubdirectories returns the folders of the channels exported in their own subdirectory
+func ChannelSubdirectories(outputFolderAbs string) []string {
+	entries, err := os.ReadDir(filepath.Join(outputFolderAbs, ChannelSubdirectoriesFolder))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		utils.Fatal().Err(err).Msg("Error reading channel subdirectories")
+	}
+	folders := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			folders = append(folders, filepath.Join(outputFolderAbs, ChannelSubdirectoriesFolder, entry.Name()))
+		}
+	}
+	return folders
+}
+
+func writeLines(path string, lines []string) {
+	content := strings.Join(lines, "\n")
+	if len(lines) > 0 {
+		content += "\n"
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		utils.Panic().Err(err).Msgf("error creating %s file", filepath.Base(path))
+	}
+}
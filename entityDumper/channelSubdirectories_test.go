@@ -0,0 +1,25 @@
+package entityDumper
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestChannelSubdirectories(t *testing.T) {
+	outputFolder := t.TempDir()
+	if folders := ChannelSubdirectories(outputFolder); len(folders) != 0 {
+		t.Errorf("Unexpected channel subdirectories %v", folders)
+	}
+	for _, label := range []string{"sles15-sp4-pool", "sles15-sp4-updates"} {
+		if err := os.MkdirAll(filepath.Join(outputFolder, ChannelSubdirectoriesFolder, label), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeLines(filepath.Join(outputFolder, ChannelSubdirectoriesFolder, "README"), []string{"not a channel"})
+
+	folders := ChannelSubdirectories(outputFolder)
+	if len(folders) != 2 || filepath.Base(folders[0]) != "sles15-sp4-pool" || filepath.Base(folders[1]) != "sles15-sp4-updates" {
+		t.Errorf("Unexpected channel subdirectories %v", folders)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/uyuni-project/inter-server-sync/dumper"
 	"github.com/uyuni-project/inter-server-sync/schemareader"
 	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+	"github.com/uyuni-project/inter-server-sync/utils"
 )
 
 func ConfigTableNames() []string {
@@ -46,10 +47,11 @@ func processConfigs(db *sql.DB, writer *bufio.Writer, options DumperOptions) {
 	configs := loadConfigsToProcess(db, options)
 	log.Info().Msg(fmt.Sprintf("%d configuration channels to process", len(configs)))
 	schemaMetadata := schemareader.ReadTablesSchema(db, ConfigTableNames())
+	applyWhereFilters(schemaMetadata, options)
 	log.Debug().Msg("channel schema metadata loaded")
 	configLabels, err := os.Create(options.GetOutputFolderAbsPath() + "/exportedConfigs.txt")
 	if err != nil {
-		log.Panic().Err(err).Msg("error creating exportedConfigChannel file")
+		utils.Panic().Err(err).Msg("error creating exportedConfigChannel file")
 	}
 	defer configLabels.Close()
 	bufferWriterChannels := bufio.NewWriter(configLabels)
@@ -69,7 +71,7 @@ func processConfigs(db *sql.DB, writer *bufio.Writer, options DumperOptions) {
 func processConfigChannel(db *sql.DB, writer *bufio.Writer, channelLabel string,
 	schemaMetadata map[string]schemareader.Table, options DumperOptions) {
 	whereFilter := fmt.Sprintf("label = '%s'", channelLabel)
-	tableData := dumper.DataCrawler(db, schemaMetadata, schemaMetadata["rhnconfigchannel"], whereFilter, options.StartingDate)
+	tableData := crawlTableData(db, schemaMetadata, schemaMetadata["rhnconfigchannel"], whereFilter, options)
 	log.Debug().Msg("finished table data crawler")
 
 	cleanWhereClause := fmt.Sprintf(`WHERE rhnconfigchannel.id = (SELECT id FROM rhnconfigchannel WHERE label = '%s')`, channelLabel)
@@ -0,0 +1,57 @@
+package entityDumper
+
+import (
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/credentials"
+	"github.com/uyuni-project/inter-server-sync/dumper"
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// readCredentialsKey reads the key sealing the exported credentials, nil when the credentials are not exported
+func readCredentialsKey(options DumperOptions) []byte {
+	if len(options.CredentialsKeyFile) == 0 {
+		return nil
+	}
+	key, err := credentials.ReadKeyFile(utils.GetAbsPath(options.CredentialsKeyFile))
+	if err != nil {
+		utils.Fatal().Err(err).Int(utils.ExitCodeField, utils.ExitConfigError).Msg("Invalid credentials key file")
+	}
+	return key
+}
+
+// applyCredentialSealing seals the passwords of the exported image store credentials with the credentials key.
+// Without key they are replaced by dumper.LockedPassword: new stores need their password on import, and the
+// existing ones keep theirs.
+func applyCredentialSealing(schemaMetadata map[string]schemareader.Table, options DumperOptions) {
+	table, ok := schemaMetadata["susecredentials"]
+	if !ok {
+		return
+	}
+	if options.credentialsKey == nil {
+		log.Info().Msg("Image store passwords are not exported, set --credentialsKeyFile to seal them in the export")
+	}
+	table.RowModCallback = func(value []sqlUtil.RowDataStructure, table schemareader.Table) []sqlUtil.RowDataStructure {
+		return sealCredentialPassword(value, options.credentialsKey)
+	}
+	schemaMetadata["susecredentials"] = table
+}
+
+func sealCredentialPassword(value []sqlUtil.RowDataStructure, key []byte) []sqlUtil.RowDataStructure {
+	for i, column := range value {
+		if column.ColumnName != "password" || column.Value == nil {
+			continue
+		}
+		if key == nil {
+			value[i].Value = dumper.LockedPassword
+			continue
+		}
+		sealed, err := credentials.Seal(key, formatText(column.Value))
+		if err != nil {
+			utils.Panic().Err(err).Msg("error sealing the image store credentials")
+		}
+		value[i].Value = sealed
+	}
+	return value
+}
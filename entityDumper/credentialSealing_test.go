@@ -0,0 +1,39 @@
+package entityDumper
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/uyuni-project/inter-server-sync/credentials"
+	"github.com/uyuni-project/inter-server-sync/dumper"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+)
+
+func credentialsRow() []sqlUtil.RowDataStructure {
+	return []sqlUtil.RowDataStructure{
+		{ColumnName: "username", ColumnType: "VARCHAR", Value: "builder"},
+		{ColumnName: "password", ColumnType: "VARCHAR", Value: "c2VjcmV0"},
+	}
+}
+
+func TestSealCredentialPassword(t *testing.T) {
+	key := []byte(strings.Repeat("k", credentials.KeySize))
+
+	row := sealCredentialPassword(credentialsRow(), key)
+
+	sealed := row[1].Value.(string)
+	if opened, err := credentials.Unseal(key, sealed); err != nil || opened != "c2VjcmV0" {
+		t.Errorf("Expected the sealed password, got %s: %v", sealed, err)
+	}
+	if row[0].Value != "builder" {
+		t.Errorf("Only the password should be sealed, got %v", row[0].Value)
+	}
+}
+
+func TestSealCredentialPasswordWithoutKey(t *testing.T) {
+	row := sealCredentialPassword(credentialsRow(), nil)
+
+	if row[1].Value != dumper.LockedPassword {
+		t.Errorf("Password should not be exported without key, got %v", row[1].Value)
+	}
+}
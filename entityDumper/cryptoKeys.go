@@ -0,0 +1,34 @@
+package entityDumper
+
+import (
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+)
+
+// repositoryClientKeysFilter excludes the SSL client certificates and keys of the repositories, which are
+// credentials of the source server
+const repositoryClientKeysFilter = `id NOT IN (SELECT ssl_client_cert_id FROM rhncontentsourcessl WHERE ssl_client_cert_id IS NOT NULL
+	UNION SELECT ssl_client_key_id FROM rhncontentsourcessl WHERE ssl_client_key_id IS NOT NULL)`
+
+// channelCryptoKeyTableNames returns the tables of the SSL certificates and keys of the repositories of the channels.
+// Without the repository credentials, only the CA certificates are exported.
+func channelCryptoKeyTableNames() []string {
+	return append(repositoryCredentialsTableNames(), "rhncryptokeytype")
+}
+
+// applyCryptoKeyFilters restricts the exported keys to CA certificates and GPG keys unless the repository
+// credentials are exported. The SSL settings of repositories with client certificates reference filtered keys,
+// so they are not exported either: their repositories use the SSL settings of the target server.
+func applyCryptoKeyFilters(schemaMetadata map[string]schemareader.Table, options DumperOptions) {
+	table, ok := schemaMetadata["rhncryptokey"]
+	if !ok || options.IncludeRepoCredentials {
+		return
+	}
+	if len(table.WhereFilter) > 0 {
+		table.WhereFilter = "(" + table.WhereFilter + ") AND " + repositoryClientKeysFilter
+	} else {
+		table.WhereFilter = repositoryClientKeysFilter
+	}
+	log.Debug().Msg("SSL client certificates and keys of the repositories are not exported")
+	schemaMetadata["rhncryptokey"] = table
+}
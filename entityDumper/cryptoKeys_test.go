@@ -0,0 +1,26 @@
+package entityDumper
+
+import (
+	"testing"
+
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+)
+
+func TestApplyCryptoKeyFilters(t *testing.T) {
+	schemaMetadata := map[string]schemareader.Table{"rhncryptokey": {Name: "rhncryptokey", WhereFilter: "org_id = 1"}}
+	applyCryptoKeyFilters(schemaMetadata, DumperOptions{})
+
+	expected := "(org_id = 1) AND " + repositoryClientKeysFilter
+	if filter := schemaMetadata["rhncryptokey"].WhereFilter; filter != expected {
+		t.Errorf("Expected filter %s, got %s", expected, filter)
+	}
+}
+
+func TestApplyCryptoKeyFiltersWithRepositoryCredentials(t *testing.T) {
+	schemaMetadata := map[string]schemareader.Table{"rhncryptokey": {Name: "rhncryptokey"}}
+	applyCryptoKeyFilters(schemaMetadata, DumperOptions{IncludeRepoCredentials: true})
+
+	if filter := schemaMetadata["rhncryptokey"].WhereFilter; len(filter) > 0 {
+		t.Errorf("Expected all keys with the repository credentials, got filter %s", filter)
+	}
+}
@@ -3,19 +3,37 @@ package entityDumper
 import (
 	"bufio"
 	"compress/gzip"
+	"database/sql"
 	"os"
+	"time"
 
 	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/dumper"
+	"github.com/uyuni-project/inter-server-sync/dumper/osImageDumper"
+	"github.com/uyuni-project/inter-server-sync/dumper/packageDumper"
+	"github.com/uyuni-project/inter-server-sync/placeholders"
 	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+	"github.com/uyuni-project/inter-server-sync/storage"
+	"github.com/uyuni-project/inter-server-sync/utils"
 )
 
 func DumpAllEntities(options DumperOptions) {
+	if options.Anonymize && options.OSImages {
+		utils.Fatal().Int(utils.ExitCodeField, utils.ExitConfigError).
+			Msg("OS images cannot be anonymized: their image and pillar files are copied unchanged. Export them without --anonymize")
+	}
+	options.credentialsKey = readCredentialsKey(options)
+	if len(options.ReferenceRules) > 0 {
+		options.referenceRules = dumper.ReadReferenceRules(utils.GetAbsPath(options.ReferenceRules))
+	}
 	var outputFolderAbs = options.GetOutputFolderAbsPath()
 	validateExportFolder(outputFolderAbs)
+	options.manifest = newExportManifest(options)
 
 	file, err := os.OpenFile(outputFolderAbs+"/sql_statements.sql.gz", os.O_WRONLY|os.O_CREATE, 0600)
 	if err != nil {
-		log.Panic().Err(err).Msg("error creating sql file")
+		utils.Panic().Err(err).Msg("error creating sql file")
 	}
 	defer file.Close()
 
@@ -25,12 +43,61 @@ func DumpAllEntities(options DumperOptions) {
 	bufferWriter := bufio.NewWriterSize(gzipFile, 32768)
 	defer bufferWriter.Flush()
 
-	db := schemareader.GetDBconnection(options.ServerConfig)
+	keyMemoryLimit := options.KeyMemoryLimit
+	if keyMemoryLimit == 0 {
+		keyMemoryLimit = dumper.DefaultKeyMemoryLimit
+	}
+	dumper.SetKeyMemoryLimit(keyMemoryLimit, options.KeySpillDirectory)
+	dumper.SetDedupMode(options.Dedup)
+	dumper.SetIdStrategy(options.IdStrategy)
+	dumper.SetAnonymize(options.Anonymize)
+	if options.VerifyReferences {
+		dumper.EnableReferenceCheck()
+	}
+	var statisticsStore *dumper.StatisticsStore
+	if len(options.StatisticsStore) > 0 {
+		statisticsStore = dumper.ReadStatisticsStore(utils.GetAbsPath(options.StatisticsStore))
+		dumper.SetTableStatistics(statisticsStore)
+		if estimate := statisticsStore.EstimateLastRows(); estimate > 0 {
+			log.Info().Msgf("Writing the tables is estimated to take %s from the previous exports", estimate.Round(time.Second))
+		}
+	}
+
+	defer quiesceSource(options)()
+	db, closeSnapshot := openSourceDatabase(options)
 	defer db.Close()
+	defer closeSnapshot()
+	defer sqlUtil.ClosePreparedStatements(db)
+	if len(options.ExportedKeysCache) > 0 {
+		dumper.LoadExportedKeysCache(utils.GetAbsPath(options.ExportedKeysCache), options.ExportedKeysImported)
+	}
+	validateWhereFilters(db, options)
+	checkSchemaDrift(db, options)
+	if options.SkipExistingOnTarget {
+		if len(options.TargetServerConfig) == 0 {
+			utils.Fatal().Int(utils.ExitCodeField, utils.ExitConfigError).Msg("The target server configuration is needed to skip the rows existing on the target")
+		}
+		targetDB := schemareader.GetReadOnlyDBconnection(options.TargetServerConfig)
+		defer targetDB.Close()
+		dumper.SetTargetDatabase(targetDB)
+	}
+	if len(options.PackageInventory) > 0 {
+		packageDumper.SetTargetInventory(packageDumper.ReadInventory(utils.GetAbsPath(options.PackageInventory)))
+	} else {
+		packageDumper.SetTargetInventory(nil)
+	}
+	defer setFileStores(options)()
+	dumper.SetPlaceholders(options.Placeholders, options.PlaceholderColumns)
+	dumper.SetCopyWorkers(options.CopyWorkers)
+	dumper.WriteSessionSettings(bufferWriter)
 	bufferWriter.WriteString("BEGIN;\n")
-	if len(options.ChannelLabels) > 0 || len(options.ChannelWithChildrenLabels) > 0 {
-		processAndInsertProducts(db, bufferWriter)
+	exportChannels := len(options.ChannelLabels) > 0 || len(options.ChannelWithChildrenLabels) > 0
+	if exportChannels || options.Products {
+		processAndInsertProducts(db, bufferWriter, options)
+	}
+	if exportChannels {
 		processAndInsertChannels(db, bufferWriter, options)
+		dumpOrgTrusts(db, bufferWriter, options)
 	}
 	if len(options.ConfigLabels) > 0 {
 		processConfigs(db, bufferWriter, options)
@@ -40,5 +107,103 @@ func DumpAllEntities(options DumperOptions) {
 		dumpImageData(db, bufferWriter, options)
 	}
 
+	if options.Autoinstall {
+		dumpAutoinstallData(db, bufferWriter, options)
+	}
+
+	if options.MaintenanceSchedules {
+		dumpMaintenanceData(db, bufferWriter, options)
+	}
+
+	if options.Proxies {
+		dumpProxyData(db, bufferWriter, options)
+	}
+
+	if options.Users {
+		dumpUserData(db, bufferWriter, options)
+	}
+
+	if len(options.TableName) > 0 {
+		processTableData(db, bufferWriter, options)
+	}
+
+	if options.BootstrapRepositories {
+		dumpBootstrapRepositories(outputFolderAbs)
+	}
+
+	if violations := dumper.CheckConstraintViolations(); violations > 0 {
+		utils.Fatal().Int(utils.ExitCodeField, utils.ExitVerificationFailure).Msgf("%d exported rows violate check constraints and would fail on import", violations)
+	}
 	bufferWriter.WriteString("COMMIT;\n")
+	dumper.WriteAnalyzeStatements(bufferWriter)
+	dumper.LogTargetRowsSkipped()
+	dumper.ReportUncoveredReferences()
+	writeManifest(outputFolderAbs, options.manifest)
+	writeExportedOrgs(db, outputFolderAbs)
+	writeExportedGroups(db, outputFolderAbs)
+	dumper.WriteVendorRows(outputFolderAbs, dumper.ReadReferencedVendorRows(db))
+	if len(options.Placeholders) > 0 {
+		placeholders.WriteTokens(outputFolderAbs, options.Placeholders)
+		placeholders.WriteColumns(outputFolderAbs, options.PlaceholderColumns)
+	}
+	dumper.SaveExportedKeysCache()
+	if statisticsStore != nil {
+		statisticsStore.Record(dumper.WriteStatistics(), time.Now())
+		statisticsStore.Save()
+	}
+}
+
+// openSourceDatabase connects to the exported server, with the function ending the snapshot the export reads
+// unless NoSnapshot. In hot standby mode the connection is checked to be read-only with a statement timeout, so
+// the export can run on a streaming replica of the database
+func openSourceDatabase(options DumperOptions) (*sql.DB, func()) {
+	if !options.HotStandbySource {
+		sqlUtil.EnableCopyReads(schemareader.GetConnectionEnvironment(options.ServerConfig))
+		if options.NoSnapshot {
+			return schemareader.GetDBconnection(options.ServerConfig), func() {}
+		}
+		return sqlUtil.OpenSnapshot(schemareader.GetConnectionString(options.ServerConfig))
+	}
+	if options.SourceStatementTimeout <= 0 {
+		utils.Fatal().Int(utils.ExitCodeField, utils.ExitConfigError).Msg("A statement timeout is needed to export from a hot standby server")
+	}
+	db, closeSnapshot := schemareader.GetHotStandbyDBconnection(options.ServerConfig, options.SourceStatementTimeout), func() {}
+	if !options.NoSnapshot {
+		db, closeSnapshot = sqlUtil.OpenSnapshot(schemareader.GetHotStandbyConnectionString(options.ServerConfig, options.SourceStatementTimeout))
+	}
+	if err := schemareader.VerifyHotStandbyConnection(db); err != nil {
+		closeSnapshot()
+		utils.Fatal().Err(err).Int(utils.ExitCodeField, utils.ExitConfigError).Msg("The source connection is not safe for a hot standby server")
+	}
+	sqlUtil.EnableCopyReads(schemareader.GetHotStandbyConnectionEnvironment(options.ServerConfig, options.SourceStatementTimeout))
+	return db, closeSnapshot
+}
+
+// setFileStores opens the stores the package and image files are read from, returning the function closing them
+func setFileStores(options DumperOptions) func() {
+	packageStore := openFileStore(options.PackageStore)
+	imageStore := openFileStore(options.ImageStore)
+	packageDumper.SetPackageStore(packageStore)
+	osImageDumper.SetImageStore(imageStore)
+	return func() {
+		for _, store := range []storage.Store{packageStore, imageStore} {
+			if store != nil {
+				if err := store.Close(); err != nil {
+					log.Warn().Err(err).Msgf("Error closing the file store %s", store)
+				}
+			}
+		}
+	}
+}
+
+// openFileStore opens the store of the location, nil when empty
+func openFileStore(location string) storage.Store {
+	if len(location) == 0 {
+		return nil
+	}
+	store, err := storage.Open(location)
+	if err != nil {
+		utils.Fatal().Err(err).Int(utils.ExitCodeField, utils.ExitConfigError).Msg("Error opening the file store")
+	}
+	return store
 }
@@ -0,0 +1,131 @@
+package entityDumper
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+	"github.com/uyuni-project/inter-server-sync/utils"
+	"github.com/uyuni-project/inter-server-sync/xmlrpc"
+)
+
+// exportLockKey is the key of the advisory lock held in shared mode by the exports with AdvisoryLock. Services and
+// scripts changing channel content take it in exclusive mode to wait for the running exports, and exports wait for
+// them: SELECT pg_advisory_lock(hashtext('inter-server-sync export'));
+const exportLockKey = "hashtext('inter-server-sync export')"
+
+// the active periodic schedules of the bunches
+var pausedSchedulesSql = `SELECT b.name, s.job_label, s.cron_expr, COALESCE(s.org_id, 0), encode(s.data, 'base64')
+	FROM rhntaskoschedule s
+	JOIN rhntaskobunch b ON b.id = s.bunch_id
+	WHERE b.name = ANY($1) AND s.cron_expr IS NOT NULL AND (s.active_till IS NULL OR s.active_till > current_timestamp)
+	ORDER BY s.id;`
+
+// pausedSchedule is a taskomatic schedule stopped during the export, and scheduled again after it
+type pausedSchedule struct {
+	bunchName string
+	jobLabel  string
+	cronExpr  string
+	orgId     int64
+	params    map[string]interface{}
+}
+
+// quiesceSource waits for the services changing the content of the source server and keeps them from starting
+// until the returned function is called, as configured by the options. It runs on its own connection, before the
+// snapshot of the export is taken.
+func quiesceSource(options DumperOptions) func() {
+	if !options.AdvisoryLock && len(options.PauseBunches) == 0 {
+		return func() {}
+	}
+	if options.AdvisoryLock && options.HotStandbySource {
+		utils.Fatal().Int(utils.ExitCodeField, utils.ExitConfigError).Msg("The export lock cannot be taken on a hot standby server, the services of the primary server don't see it")
+	}
+	db := schemareader.GetDBconnection(options.ServerConfig)
+	release := func() {}
+	if options.AdvisoryLock {
+		release = lockExport(db)
+	}
+	resume := func() {}
+	if len(options.PauseBunches) > 0 {
+		resume = pauseBunches(db, xmlrpc.NewClient("", ""), options.PauseBunches)
+	}
+	return func() {
+		resume()
+		release()
+		db.Close()
+	}
+}
+
+// lockExport takes the export lock in shared mode on its own connection, which keeps it until the returned
+// function releases it
+func lockExport(db *sql.DB) func() {
+	conn, err := db.Conn(utils.Context())
+	if err != nil {
+		utils.Fatal().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("Error opening the connection of the export lock")
+	}
+	locked := false
+	if err := conn.QueryRowContext(utils.Context(), fmt.Sprintf("SELECT pg_try_advisory_lock_shared(%s);", exportLockKey)).Scan(&locked); err != nil {
+		conn.Close()
+		utils.Fatal().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("Error taking the export lock")
+	}
+	if !locked {
+		log.Info().Msg("Waiting for the services holding the export lock")
+		if _, err := conn.ExecContext(utils.Context(), fmt.Sprintf("SELECT pg_advisory_lock_shared(%s);", exportLockKey)); err != nil {
+			conn.Close()
+			utils.Fatal().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("Error taking the export lock")
+		}
+	}
+	log.Info().Msg("Export lock taken")
+	return func() {
+		if _, err := conn.ExecContext(utils.Context(), fmt.Sprintf("SELECT pg_advisory_unlock_shared(%s);", exportLockKey)); err != nil {
+			log.Warn().Err(err).Msg("Error releasing the export lock, it is released with its connection")
+		}
+		conn.Close()
+		log.Info().Msg("Export lock released")
+	}
+}
+
+// pauseBunches stops the periodic schedules of the taskomatic bunches, and returns the function scheduling them
+// again. Runs already started are not interrupted.
+func pauseBunches(db *sql.DB, client xmlrpc.Client, bunchNames []string) func() {
+	paused := make([]pausedSchedule, 0)
+	resume := func() {
+		for _, schedule := range paused {
+			if _, err := client.ScheduleBunch(schedule.orgId, schedule.bunchName, schedule.jobLabel, schedule.cronExpr, schedule.params); err != nil {
+				log.Error().Err(err).Msgf("Error scheduling %s again, schedule the %s bunch with the cron expression '%s'",
+					schedule.jobLabel, schedule.bunchName, schedule.cronExpr)
+				continue
+			}
+			log.Info().Msgf("Taskomatic schedule %s resumed", schedule.jobLabel)
+		}
+	}
+	for _, row := range sqlUtil.ExecuteQueryWithResults(db, pausedSchedulesSql, pq.Array(bunchNames)) {
+		orgId, _ := strconv.ParseInt(fmt.Sprintf("%v", row[3].Value), 10, 64)
+		schedule := pausedSchedule{bunchName: fmt.Sprintf("%s", row[0].Value), jobLabel: fmt.Sprintf("%s", row[1].Value),
+			cronExpr: fmt.Sprintf("%s", row[2].Value), orgId: orgId, params: make(map[string]interface{})}
+		if row[4].Value != nil {
+			params, err := decodeScheduleData(fmt.Sprintf("%s", row[4].Value))
+			if err != nil {
+				log.Warn().Err(err).Msgf("Parameters of the taskomatic schedule %s cannot be read, it is not paused", schedule.jobLabel)
+				continue
+			}
+			for name, value := range params {
+				schedule.params[name] = value
+			}
+		}
+		if _, err := client.UnscheduleBunch(schedule.orgId, schedule.jobLabel); err != nil {
+			resume()
+			utils.Fatal().Err(err).Msgf("Error pausing the taskomatic schedule %s", schedule.jobLabel)
+		}
+		log.Info().Msgf("Taskomatic schedule %s of the %s bunch paused during the export", schedule.jobLabel, schedule.bunchName)
+		paused = append(paused, schedule)
+	}
+	if len(paused) == 0 {
+		log.Warn().Msgf("No active schedules of the bunches %v to pause", bunchNames)
+	}
+	return resume
+}
@@ -0,0 +1,103 @@
+package entityDumper
+
+import (
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/uyuni-project/inter-server-sync/tests"
+)
+
+// taskomaticCalls records the schedules of the taskomatic calls, failing the unschedule calls of failingLabel
+type taskomaticCalls struct {
+	unscheduled  []string
+	scheduled    []string
+	params       map[string]interface{}
+	failingLabel string
+}
+
+func (calls *taskomaticCalls) SyncConfigFiles(labels []string) (interface{}, error) { return nil, nil }
+func (calls *taskomaticCalls) SyncCobbler() (interface{}, error)                    { return nil, nil }
+func (calls *taskomaticCalls) SetProfileVariables(label string, variables map[string]interface{}) (interface{}, error) {
+	return nil, nil
+}
+func (calls *taskomaticCalls) ScheduleRepoSync(label string, cronExpr string, params map[string]interface{}) (interface{}, error) {
+	return nil, nil
+}
+
+func (calls *taskomaticCalls) UnscheduleBunch(orgId int64, jobLabel string) (interface{}, error) {
+	if jobLabel == calls.failingLabel {
+		return nil, errors.New("taskomatic is not running")
+	}
+	calls.unscheduled = append(calls.unscheduled, jobLabel)
+	return 1, nil
+}
+
+func (calls *taskomaticCalls) ScheduleBunch(orgId int64, bunchName string, jobLabel string, cronExpr string, params map[string]interface{}) (interface{}, error) {
+	calls.scheduled = append(calls.scheduled, bunchName+" "+jobLabel+" "+cronExpr)
+	if len(params) > 0 {
+		calls.params = params
+	}
+	return nil, nil
+}
+
+func TestPauseBunches(t *testing.T) {
+	repo := tests.CreateDataRepository()
+	data := base64.StdEncoding.EncodeToString(serializedStringMap([][2]string{{"channel_id", "105"}}))
+	repo.ExpectWithRecords(pausedSchedulesSql, sqlmock.NewRows([]string{"name", "job_label", "cron_expr", "org_id", "data"}).
+		AddRow("repo-sync-bunch", "repo-sync-1-105", "0 0 2 ? * *", int64(1), data).
+		AddRow("errata-cache-bunch", "errata-cache-default", "0 * * * * ?", int64(0), nil))
+	calls := &taskomaticCalls{}
+
+	resume := pauseBunches(repo.DB, calls, []string{"repo-sync-bunch", "errata-cache-bunch"})
+	if expected := []string{"repo-sync-1-105", "errata-cache-default"}; !reflect.DeepEqual(calls.unscheduled, expected) {
+		t.Errorf("expected the schedules %v to be paused, got %v", expected, calls.unscheduled)
+	}
+	if len(calls.scheduled) > 0 {
+		t.Errorf("the schedules should be resumed after the export, got %v", calls.scheduled)
+	}
+	resume()
+
+	expected := []string{"repo-sync-bunch repo-sync-1-105 0 0 2 ? * *", "errata-cache-bunch errata-cache-default 0 * * * * ?"}
+	if !reflect.DeepEqual(calls.scheduled, expected) {
+		t.Errorf("expected the schedules %v to be resumed, got %v", expected, calls.scheduled)
+	}
+	if !reflect.DeepEqual(calls.params, map[string]interface{}{"channel_id": "105"}) {
+		t.Errorf("the parameters of the schedules should be kept, got %v", calls.params)
+	}
+}
+
+func TestPauseBunchesFailure(t *testing.T) {
+	repo := tests.CreateDataRepository()
+	repo.ExpectWithRecords(pausedSchedulesSql, sqlmock.NewRows([]string{"name", "job_label", "cron_expr", "org_id", "data"}).
+		AddRow("repo-sync-bunch", "repo-sync-1-105", "0 0 2 ? * *", int64(1), nil).
+		AddRow("repo-sync-bunch", "repo-sync-1-106", "0 0 3 ? * *", int64(1), nil))
+	calls := &taskomaticCalls{failingLabel: "repo-sync-1-106"}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("the export should stop when a schedule cannot be paused")
+		}
+		if expected := []string{"repo-sync-bunch repo-sync-1-105 0 0 2 ? * *"}; !reflect.DeepEqual(calls.scheduled, expected) {
+			t.Errorf("expected the paused schedules %v to be resumed, got %v", expected, calls.scheduled)
+		}
+	}()
+
+	pauseBunches(repo.DB, calls, []string{"repo-sync-bunch"})
+}
+
+func TestLockExportWaits(t *testing.T) {
+	repo := tests.CreateDataRepository()
+	repo.ExpectWithRecords("SELECT pg_try_advisory_lock_shared(hashtext('inter-server-sync export'));",
+		sqlmock.NewRows([]string{"pg_try_advisory_lock_shared"}).AddRow(false))
+	repo.ExpectExec("SELECT pg_advisory_lock_shared(hashtext('inter-server-sync export'));")
+	repo.ExpectExec("SELECT pg_advisory_unlock_shared(hashtext('inter-server-sync export'));")
+
+	release := lockExport(repo.DB)
+	release()
+
+	if err := repo.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
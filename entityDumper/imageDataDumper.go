@@ -54,7 +54,8 @@ func ImageTableNames() []string {
 	return imagesTableNames
 }
 
-func markAsExported(schema map[string]schemareader.Table, tables []string) {
+// markAsUnexported excludes the tables from the export, their rows are referenced as existing on the target
+func markAsUnexported(schema map[string]schemareader.Table, tables []string) {
 	for _, table := range tables {
 		tmp := schema[table]
 		tmp.Export = false
@@ -62,7 +63,8 @@ func markAsExported(schema map[string]schemareader.Table, tables []string) {
 	}
 }
 
-func markAsUnexported(schema map[string]schemareader.Table, tables []string) {
+// markAsExported includes the tables in the export, even those which are assumed on the target by default
+func markAsExported(schema map[string]schemareader.Table, tables []string) {
 	for _, table := range tables {
 		tmp := schema[table]
 		tmp.Export = true
@@ -97,12 +99,12 @@ func dumpImageStores(db *sql.DB, writer *bufio.Writer, schemaMetadata map[string
 		for _, store := range stores {
 			log.Trace().Msgf("Exporting store id %s", store[0].Value)
 			whereClause := fmt.Sprintf("id = '%s'", store[0].Value)
-			tableProfilesData := dumper.DataCrawler(db, schemaMetadata, schemaMetadata["suseimagestore"], whereClause, options.StartingDate)
+			tableProfilesData := crawlTableData(db, schemaMetadata, schemaMetadata["suseimagestore"], whereClause, options)
 
 			dumper.PrintTableDataOrdered(db, writer, schemaMetadata, schemaMetadata["suseimagestore"], tableProfilesData, dumper.PrintSqlOptions{})
 		}
 		// Mark tables as exported so they are not transitively exported by profiles
-		markAsExported(schemaMetadata, []string{"suseimagestore"})
+		markAsUnexported(schemaMetadata, []string{"suseimagestore"})
 	} else {
 		log.Info().Msg("No image stores found to export")
 	}
@@ -116,8 +118,10 @@ func dumpImageStores(db *sql.DB, writer *bufio.Writer, schemaMetadata map[string
 func dumpOSImageTables(db *sql.DB, writer *bufio.Writer, schemaMetadata map[string]schemareader.Table,
 	options DumperOptions, outputFolderImagesAbs string) bool {
 
+	imageIds, profileIds := selectImages(db, schemaMetadata, options, "kiwi")
+
 	// Image profiles
-	sqlForExistingProfiles := "SELECT profile_id FROM suseimageprofile WHERE image_type = 'kiwi'"
+	sqlForExistingProfiles := "SELECT profile_id FROM suseimageprofile WHERE image_type = 'kiwi'" + imageProfilesFilter(options, profileIds)
 	for _, org := range options.Orgs {
 		sqlForExistingProfiles = fmt.Sprintf("%s AND org_id = %d", sqlForExistingProfiles, org)
 	}
@@ -131,50 +135,38 @@ func dumpOSImageTables(db *sql.DB, writer *bufio.Writer, schemaMetadata map[stri
 		for _, profile := range profiles {
 			log.Trace().Msgf("Exporting profile id %s", profile[0].Value)
 			whereClause := fmt.Sprintf("profile_id = '%s'", profile[0].Value)
-			tableProfilesData := dumper.DataCrawler(db, schemaMetadata, schemaMetadata["susekiwiprofile"], whereClause, options.StartingDate)
+			tableProfilesData := crawlTableData(db, schemaMetadata, schemaMetadata["susekiwiprofile"], whereClause, options)
 
 			dumper.PrintTableDataOrdered(db, writer, schemaMetadata, schemaMetadata["susekiwiprofile"], tableProfilesData, dumper.PrintSqlOptions{})
 		}
 		// Mark tables as exported so they are not transitively exported by images
-		markAsExported(schemaMetadata, []string{"suseimageprofile"})
+		markAsUnexported(schemaMetadata, []string{"suseimageprofile"})
 	} else {
 		log.Info().Msg("No Kiwi profiles found to export")
 	}
 
 	// Images
 	needExtraExport := false
-	sqlForExistingImages := "SELECT id FROM suseimageinfo WHERE image_type = 'kiwi'"
-	if isColumnInTable(schemaMetadata, "suseimageinfo", "built") {
-		// For 4.3 and newer export only succesfuly built images
-		sqlForExistingImages = fmt.Sprintf("%s AND built = 'Y'", sqlForExistingImages)
-	}
-	for _, org := range options.Orgs {
-		sqlForExistingImages = fmt.Sprintf("%s AND org_id = %d", sqlForExistingImages, org)
-	}
-	if options.StartingDate != "" {
-		sqlForExistingImages = fmt.Sprintf("%s AND modified > '%s'::timestamp", sqlForExistingImages, options.StartingDate)
-	}
-	images := sqlUtil.ExecuteQueryWithResults(db, sqlForExistingImages)
-	if len(images) > 0 {
+	if len(imageIds) > 0 {
 		log.Debug().Msg("Dumping Image tables")
 		writer.WriteString("-- OS Images\n")
-		for _, image := range images {
-			log.Trace().Msgf("Exporting image id %s", image[0].Value)
-			whereClause := fmt.Sprintf("id = '%s'", image[0].Value)
-			tableImageData := dumper.DataCrawler(db, schemaMetadata, schemaMetadata["suseimageinfo"], whereClause, options.StartingDate)
+		copier := dumper.NewFileCopier()
+		for _, imageId := range imageIds {
+			log.Trace().Msgf("Exporting image id %s", imageId)
+			whereClause := fmt.Sprintf("id = '%s'", imageId)
+			tableImageData := crawlTableData(db, schemaMetadata, schemaMetadata["suseimageinfo"], whereClause, options)
 			dumper.PrintTableDataOrdered(db, writer, schemaMetadata, schemaMetadata["suseimageinfo"], tableImageData, dumper.PrintSqlOptions{})
 			// Check if pillars are already in database
 			if _, ok := tableImageData.TableData["susesaltpillar"]; ok && !options.MetadataOnly {
 				// pillars in database, files must be as well
 				// export all metadata about images
-				whereClauseImageFiles := fmt.Sprintf("image_info_id = '%s'", image[0].Value)
-				tableImageFilesData := dumper.DataCrawler(db, schemaMetadata, schemaMetadata["suseimagefile"],
-					whereClauseImageFiles, options.StartingDate)
+				whereClauseImageFiles := fmt.Sprintf("image_info_id = '%s'", imageId)
+				tableImageFilesData := crawlTableData(db, schemaMetadata, schemaMetadata["suseimagefile"], whereClauseImageFiles, options)
 				dumper.PrintTableDataOrdered(db, writer, schemaMetadata, schemaMetadata["suseimagefile"],
 					tableImageFilesData, dumper.PrintSqlOptions{})
 				// find all local (not-external) image files for the image and export their files
 				sqlForExistingLocalImageFiles := fmt.Sprintf("SELECT file, org_id FROM suseimagefile AS sif JOIN suseimageinfo AS sii "+
-					"ON sif.image_info_id = sii.id WHERE sii.id = '%s' AND external = 'N'", image[0].Value)
+					"ON sif.image_info_id = sii.id WHERE sii.id = '%s' AND external = 'N'", imageId)
 				imageFiles := sqlUtil.ExecuteQueryWithResults(db, sqlForExistingLocalImageFiles)
 				for _, imageFile := range imageFiles {
 					// source is taken from basedir + org + filename from db
@@ -183,7 +175,7 @@ func dumpOSImageTables(db *sql.DB, writer *bufio.Writer, schemaMetadata map[stri
 					org := fmt.Sprintf("%s", imageFile[1].Value)
 					source := osImageDumper.GetImagePathForImage(file, org)
 					target := osImageDumper.GetImagePathForImage(file, org, outputFolderImagesAbs)
-					osImageDumper.DumpOsImage(target, source)
+					osImageDumper.QueueOsImageBundle(copier, target, source)
 				}
 
 			} else {
@@ -191,6 +183,7 @@ func dumpOSImageTables(db *sql.DB, writer *bufio.Writer, schemaMetadata map[stri
 				needExtraExport = true
 			}
 		}
+		copier.Finish("image files")
 	}
 
 	log.Info().Msg("Kiwi image export done")
@@ -199,8 +192,10 @@ func dumpOSImageTables(db *sql.DB, writer *bufio.Writer, schemaMetadata map[stri
 
 func dumpContainerImageTables(db *sql.DB, writer *bufio.Writer, schemaMetadata map[string]schemareader.Table, options DumperOptions) {
 
+	imageIds, profileIds := selectImages(db, schemaMetadata, options, "dockerfile")
+
 	// Image profiles
-	sqlForExistingProfiles := "SELECT profile_id FROM suseimageprofile WHERE image_type = 'dockerfile'"
+	sqlForExistingProfiles := "SELECT profile_id FROM suseimageprofile WHERE image_type = 'dockerfile'" + imageProfilesFilter(options, profileIds)
 	for _, org := range options.Orgs {
 		sqlForExistingProfiles = fmt.Sprintf("%s AND org_id = %d", sqlForExistingProfiles, org)
 	}
@@ -214,35 +209,23 @@ func dumpContainerImageTables(db *sql.DB, writer *bufio.Writer, schemaMetadata m
 		for _, profile := range profiles {
 			log.Trace().Msgf("Exporting profile id %s", profile[0].Value)
 			whereClause := fmt.Sprintf("profile_id = '%s'", profile[0].Value)
-			tableProfilesData := dumper.DataCrawler(db, schemaMetadata, schemaMetadata["susedockerfileprofile"], whereClause, options.StartingDate)
+			tableProfilesData := crawlTableData(db, schemaMetadata, schemaMetadata["susedockerfileprofile"], whereClause, options)
 
 			dumper.PrintTableDataOrdered(db, writer, schemaMetadata, schemaMetadata["susedockerfileprofile"], tableProfilesData, dumper.PrintSqlOptions{})
 		}
-		markAsExported(schemaMetadata, []string{"suseimageprofile"})
+		markAsUnexported(schemaMetadata, []string{"suseimageprofile"})
 	} else {
 		log.Info().Msg("No profiles found to export")
 	}
 
 	// Images
-	sqlForExistingImages := "SELECT id FROM suseimageinfo WHERE image_type = 'dockerfile'"
-	if isColumnInTable(schemaMetadata, "suseimageinfo", "built") {
-		// For 4.3 and newer export only succesfuly built images
-		sqlForExistingImages = fmt.Sprintf("%s AND built = 'Y'", sqlForExistingImages)
-	}
-	for _, org := range options.Orgs {
-		sqlForExistingImages = fmt.Sprintf("%s AND org_id = %d", sqlForExistingImages, org)
-	}
-	if options.StartingDate != "" {
-		sqlForExistingImages = fmt.Sprintf("%s AND modified > '%s'::timestamp", sqlForExistingImages, options.StartingDate)
-	}
-	images := sqlUtil.ExecuteQueryWithResults(db, sqlForExistingImages)
-	if len(images) > 0 {
+	if len(imageIds) > 0 {
 		log.Debug().Msg("Dumping Image tables")
 		writer.WriteString("-- Dockerfile Images\n")
-		for _, image := range images {
-			log.Trace().Msgf("Exporting image id %s", image[0].Value)
-			whereClause := fmt.Sprintf("id = '%s'", image[0].Value)
-			tableImageData := dumper.DataCrawler(db, schemaMetadata, schemaMetadata["suseimageinfo"], whereClause, options.StartingDate)
+		for _, imageId := range imageIds {
+			log.Trace().Msgf("Exporting image id %s", imageId)
+			whereClause := fmt.Sprintf("id = '%s'", imageId)
+			tableImageData := crawlTableData(db, schemaMetadata, schemaMetadata["suseimageinfo"], whereClause, options)
 			dumper.PrintTableDataOrdered(db, writer, schemaMetadata, schemaMetadata["suseimageinfo"], tableImageData, dumper.PrintSqlOptions{})
 		}
 	}
@@ -258,6 +241,8 @@ func dumpImageData(db *sql.DB, writer *bufio.Writer, options DumperOptions) {
 	// export DB data about images
 	log.Trace().Msg("Loading table schema")
 	schemaMetadata := schemareader.ReadTablesSchema(db, imagesTableNames)
+	applyWhereFilters(schemaMetadata, options)
+	applyCredentialSealing(schemaMetadata, options)
 
 	if options.OSImages {
 		var outputFolderImagesAbs = filepath.Join(outputFolderAbs, "images")
@@ -266,13 +251,16 @@ func dumpImageData(db *sql.DB, writer *bufio.Writer, options DumperOptions) {
 		if dumpOSImageTables(db, writer, schemaMetadata, options, outputFolderImagesAbs) {
 			var outputFolderPillarAbs = filepath.Join(outputFolderAbs, "images", "pillars")
 			ValidateExportFolder(outputFolderPillarAbs)
+			if len(options.ImageFilters) > 0 {
+				log.Warn().Msg("Image pillars and files are not stored in the database of this server, they are exported for all images of the organizations")
+			}
 			pillarDumper.DumpImagePillars(outputFolderPillarAbs, options.Orgs, options.ServerConfig)
 			if !options.MetadataOnly {
 				osImageDumper.DumpOsImages(outputFolderImagesAbs, options.Orgs)
 			}
 		}
 		// This is needed for containers to be able to export their respective tables
-		markAsUnexported(schemaMetadata, []string{"suseimagestore", "suseimageprofile"})
+		markAsExported(schemaMetadata, []string{"suseimagestore", "suseimageprofile"})
 	}
 	if options.Containers {
 		dumpImageStores(db, writer, schemaMetadata, options, "registry")
@@ -0,0 +1,117 @@
+package entityDumper
+
+import (
+	"database/sql"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+)
+
+// ImageFilter selects the exported images by name, version and architecture, with shell patterns like sles15*
+type ImageFilter struct {
+	Name    string
+	Version string
+	// label of the architecture, like x86_64-redhat-linux, or its name, like x86_64
+	Arch string
+}
+
+func (filter ImageFilter) String() string {
+	return filter.Name + ":" + filter.Version + ":" + filter.Arch
+}
+
+// ParseImageFilter parses a 'name:version:arch' filter. Missing parts match any value.
+func ParseImageFilter(value string) (ImageFilter, bool) {
+	parts := strings.Split(strings.TrimSpace(value), ":")
+	if len(parts) > 3 || len(parts[0]) == 0 {
+		return ImageFilter{}, false
+	}
+	for len(parts) < 3 {
+		parts = append(parts, "*")
+	}
+	filter := ImageFilter{Name: parts[0], Version: parts[1], Arch: parts[2]}
+	for _, pattern := range parts {
+		if _, err := path.Match(pattern, ""); err != nil || len(pattern) == 0 {
+			return ImageFilter{}, false
+		}
+	}
+	return filter, true
+}
+
+func matchPattern(pattern string, values ...string) bool {
+	for _, value := range values {
+		if matched, _ := path.Match(pattern, value); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// matchImageFilters checks the image against the filters, all images match without filters
+func matchImageFilters(filters []ImageFilter, name string, version string, archLabel string, archName string) bool {
+	if len(filters) == 0 {
+		return true
+	}
+	for _, filter := range filters {
+		if matchPattern(filter.Name, name) && matchPattern(filter.Version, version) && matchPattern(filter.Arch, archLabel, archName) {
+			return true
+		}
+	}
+	return false
+}
+
+// selectImages returns the ids of the images of the type to export, and the ids of their profiles
+func selectImages(db *sql.DB, schemaMetadata map[string]schemareader.Table, options DumperOptions, imageType string) ([]string, []string) {
+	sqlForExistingImages := fmt.Sprintf(`SELECT i.id, i.profile_id, i.name, i.version, a.label, a.name FROM suseimageinfo i
+	LEFT JOIN rhnserverarch a ON a.id = i.image_arch_id WHERE i.image_type = '%s'`, imageType)
+	if isColumnInTable(schemaMetadata, "suseimageinfo", "built") {
+		// For 4.3 and newer export only succesfuly built images
+		sqlForExistingImages = fmt.Sprintf("%s AND i.built = 'Y'", sqlForExistingImages)
+	}
+	for _, org := range options.Orgs {
+		sqlForExistingImages = fmt.Sprintf("%s AND i.org_id = %d", sqlForExistingImages, org)
+	}
+	if options.StartingDate != "" {
+		sqlForExistingImages = fmt.Sprintf("%s AND i.modified > '%s'::timestamp", sqlForExistingImages, options.StartingDate)
+	}
+	imageIds := make([]string, 0)
+	profileIds := make([]string, 0)
+	profiles := make(map[string]bool)
+	for _, image := range sqlUtil.ExecuteQueryWithResults(db, sqlForExistingImages+" ORDER BY i.id;") {
+		values := make([]string, 0, len(image))
+		for _, column := range image {
+			if column.Value == nil {
+				values = append(values, "")
+			} else {
+				values = append(values, fmt.Sprintf("%v", column.Value))
+			}
+		}
+		if !matchImageFilters(options.ImageFilters, values[2], values[3], values[4], values[5]) {
+			log.Trace().Msgf("Image %s:%s:%s does not match the image filters", values[2], values[3], values[4])
+			continue
+		}
+		imageIds = append(imageIds, values[0])
+		if len(values[1]) > 0 && !profiles[values[1]] {
+			profiles[values[1]] = true
+			profileIds = append(profileIds, values[1])
+		}
+	}
+	if len(options.ImageFilters) > 0 {
+		log.Info().Msgf("%d %s images match the image filters", len(imageIds), imageType)
+	}
+	return imageIds, profileIds
+}
+
+// imageProfilesFilter restricts the exported profiles to the ones of the selected images when images are filtered
+func imageProfilesFilter(options DumperOptions, profileIds []string) string {
+	if len(options.ImageFilters) == 0 {
+		return ""
+	}
+	if len(profileIds) == 0 {
+		return " AND false"
+	}
+	return fmt.Sprintf(" AND profile_id IN (%s)", strings.Join(profileIds, ", "))
+}
@@ -0,0 +1,52 @@
+package entityDumper
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/tests"
+)
+
+func TestParseImageFilter(t *testing.T) {
+	if filter, ok := ParseImageFilter("sles15*:1.*:x86_64"); !ok || filter != (ImageFilter{Name: "sles15*", Version: "1.*", Arch: "x86_64"}) {
+		t.Errorf("unexpected filter %v", filter)
+	}
+	if filter, ok := ParseImageFilter("POS_Image_JeOS7"); !ok || filter != (ImageFilter{Name: "POS_Image_JeOS7", Version: "*", Arch: "*"}) {
+		t.Errorf("missing parts should match any value, got %v", filter)
+	}
+	for _, value := range []string{"", ":1.0", "a:b:c:d", "[a:1.0", "a::x86_64"} {
+		if _, ok := ParseImageFilter(value); ok {
+			t.Errorf("filter %s should be invalid", value)
+		}
+	}
+}
+
+func TestSelectImages(t *testing.T) {
+	repo := tests.CreateDataRepository()
+	schemaMetadata := map[string]schemareader.Table{"suseimageinfo": {Name: "suseimageinfo", Columns: []string{"id", "built"}}}
+	repo.ExpectWithRecords(`SELECT i.id, i.profile_id, i.name, i.version, a.label, a.name FROM suseimageinfo i
+	LEFT JOIN rhnserverarch a ON a.id = i.image_arch_id WHERE i.image_type = 'kiwi' AND i.built = 'Y' AND i.org_id = 1 ORDER BY i.id;`,
+		sqlmock.NewRows([]string{"id", "profile_id", "name", "version", "label", "name"}).
+			AddRow("1", "10", "sles15sp4-jeos", "1.0.0", "x86_64-redhat-linux", "x86_64").
+			AddRow("2", "10", "sles15sp4-jeos", "1.0.0", "aarch64-redhat-linux", "aarch64").
+			AddRow("3", "11", "sles15sp5-jeos", "2.1.0", "x86_64-redhat-linux", "x86_64").
+			AddRow("4", nil, "sles12-jeos", "1.0.0", "x86_64-redhat-linux", "x86_64"))
+	options := DumperOptions{Orgs: []uint{1}, ImageFilters: []ImageFilter{{Name: "sles15*", Version: "*", Arch: "x86_64"}}}
+
+	imageIds, profileIds := selectImages(repo.DB, schemaMetadata, options, "kiwi")
+
+	if !reflect.DeepEqual(imageIds, []string{"1", "3"}) {
+		t.Errorf("expected the x86_64 images of SLES 15, got %v", imageIds)
+	}
+	if !reflect.DeepEqual(profileIds, []string{"10", "11"}) {
+		t.Errorf("expected the profiles of the selected images, got %v", profileIds)
+	}
+	if filter := imageProfilesFilter(options, profileIds); filter != " AND profile_id IN (10, 11)" {
+		t.Errorf("unexpected profiles filter %s", filter)
+	}
+	if filter := imageProfilesFilter(DumperOptions{}, profileIds); filter != "" {
+		t.Errorf("profiles should not be filtered without image filters, got %s", filter)
+	}
+}
@@ -0,0 +1,62 @@
+package entityDumper
+
+import (
+	"bufio"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/dumper"
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+)
+
+func MaintenanceTableNames() []string {
+	return []string{
+		"susemaintenanceschedule",
+		"susemaintenancecalendar", // ical data
+	}
+}
+
+// systems are not exported, so schedules are assigned to the systems already registered to the target server
+var maintenanceAssignmentsSql = `SELECT s.machine_id, ms.name, wc.name FROM rhnserver s
+	JOIN susemaintenanceschedule ms ON ms.id = s.maintenance_schedule_id
+	JOIN web_customer wc ON wc.id = ms.org_id
+	WHERE s.machine_id IS NOT NULL`
+
+func dumpMaintenanceData(db *sql.DB, writer *bufio.Writer, options DumperOptions) {
+	log.Trace().Msg("Processing maintenance schedules")
+	schemaMetadata := schemareader.ReadTablesSchema(db, MaintenanceTableNames())
+	applyWhereFilters(schemaMetadata, options)
+	startingTables := []schemareader.Table{schemaMetadata["susemaintenanceschedule"]}
+
+	orgFilter := formatOrgFilter(options)
+	var whereFilterClause = func(table schemareader.Table) string {
+		if _, ok := table.ColumnIndexes["org_id"]; ok && len(orgFilter) > 0 {
+			return strings.Replace(orgFilter, " AND t.", " where ", 1)
+		}
+		return ""
+	}
+
+	writer.WriteString("-- maintenance schedules\n")
+	dumper.DumpAllTablesData(db, writer, schemaMetadata, startingTables, whereFilterClause, nil)
+
+	assignments := sqlUtil.ExecuteQueryWithResults(db, maintenanceAssignmentsSql+
+		strings.Replace(orgFilter, " t.", " ms.", 1)+" ORDER BY s.id;")
+	log.Info().Msgf("%d system maintenance schedule assignments to process", len(assignments))
+	for _, assignment := range assignments {
+		writer.WriteString(formatMaintenanceAssignment(fmt.Sprintf("%s", assignment[0].Value),
+			fmt.Sprintf("%s", assignment[1].Value), fmt.Sprintf("%s", assignment[2].Value)) + "\n")
+	}
+	writer.WriteString("-- end of maintenance schedules\n")
+	log.Debug().Msg("maintenance schedules export done")
+}
+
+// formatMaintenanceAssignment returns the statement assigning a schedule to a system, if the target server knows it
+func formatMaintenanceAssignment(machineId string, scheduleName string, orgName string) string {
+	return fmt.Sprintf(`UPDATE rhnserver SET maintenance_schedule_id = (SELECT id FROM susemaintenanceschedule
+	WHERE name = %s AND org_id = (SELECT id FROM web_customer WHERE name = %s)) WHERE machine_id = %s;`,
+		pq.QuoteLiteral(scheduleName), pq.QuoteLiteral(orgName), pq.QuoteLiteral(machineId))
+}
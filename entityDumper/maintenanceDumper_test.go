@@ -0,0 +1,12 @@
+package entityDumper
+
+import "testing"
+
+func TestFormatMaintenanceAssignment(t *testing.T) {
+	expected := `UPDATE rhnserver SET maintenance_schedule_id = (SELECT id FROM susemaintenanceschedule
+	WHERE name = 'weekly' AND org_id = (SELECT id FROM web_customer WHERE name = 'Tom''s org')) WHERE machine_id = 'a1b2c3';`
+	result := formatMaintenanceAssignment("a1b2c3", "weekly", "Tom's org")
+	if result != expected {
+		t.Errorf("Unexpected assignment statement: %s", result)
+	}
+}
@@ -0,0 +1,67 @@
+package entityDumper
+
+import (
+	"database/sql"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/dumper"
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+const manifestFileName = "manifest.json"
+
+// ExportManifest describes how an export was produced
+type ExportManifest struct {
+	MaxDepth    int      `json:"max_depth,omitempty"`
+	PruneTables []string `json:"prune_tables,omitempty"`
+	// rules limiting how far single foreign keys were followed
+	ReferenceRules []dumper.ReferenceRule `json:"reference_rules,omitempty"`
+	// tables the crawler did not follow into, with the reason
+	Pruned map[string]string `json:"pruned,omitempty"`
+}
+
+func newExportManifest(options DumperOptions) *ExportManifest {
+	return &ExportManifest{
+		MaxDepth:       options.MaxDepth,
+		PruneTables:    options.PruneTables,
+		ReferenceRules: options.referenceRules,
+		Pruned:         make(map[string]string),
+	}
+}
+
+// crawlTableData runs the data crawler with the traversal limits of the options, recording what was pruned
+func crawlTableData(db *sql.DB, schemaMetadata map[string]schemareader.Table, startTable schemareader.Table,
+	whereFilter string, options DumperOptions) dumper.DataDumper {
+
+	crawlerOptions := dumper.CrawlerOptions{
+		StartingDate:   options.StartingDate,
+		MaxDepth:       options.MaxDepth,
+		PruneTables:    options.PruneTables,
+		ReferenceRules: options.referenceRules,
+	}
+	tableData := dumper.DataCrawlerWithOptions(db, schemaMetadata, startTable, whereFilter, crawlerOptions)
+	if options.manifest != nil {
+		for table, reason := range tableData.Pruned {
+			log.Debug().Msgf("Table %s pruned from %s export: %s", table, startTable.Name, reason)
+			options.manifest.Pruned[table] = reason
+		}
+	}
+	return tableData
+}
+
+func writeManifest(outputFolderAbs string, manifest *ExportManifest) {
+	file, err := os.Create(filepath.Join(outputFolderAbs, manifestFileName))
+	if err != nil {
+		utils.Panic().Err(err).Msg("error creating manifest file")
+	}
+	defer file.Close()
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(manifest); err != nil {
+		utils.Panic().Err(err).Msg("error writing manifest file")
+	}
+}
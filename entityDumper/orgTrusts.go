@@ -0,0 +1,68 @@
+package entityDumper
+
+import (
+	"bufio"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/dumper"
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+)
+
+// the trusts between organizations, which protected channels are shared with
+var orgTrustsSql = `SELECT o.name, t.name FROM rhntrustedorgs tr
+	JOIN web_customer o ON o.id = tr.org_id
+	JOIN web_customer t ON t.id = tr.org_trust_id`
+
+// formatOrgsList returns the ids of the exported organizations as SQL list, empty when all are exported
+func formatOrgsList(options DumperOptions) string {
+	orgs := make([]string, 0, len(options.Orgs))
+	for _, org := range options.Orgs {
+		orgs = append(orgs, fmt.Sprintf("%d", org))
+	}
+	return strings.Join(orgs, ", ")
+}
+
+// applyChannelTrustFilters restricts the organizations the protected channels are shared with to the exported ones
+func applyChannelTrustFilters(schemaMetadata map[string]schemareader.Table, options DumperOptions) {
+	table, ok := schemaMetadata["rhnchanneltrust"]
+	if !ok || len(options.Orgs) == 0 {
+		return
+	}
+	filter := fmt.Sprintf("org_trust_id IN (%s)", formatOrgsList(options))
+	if len(table.WhereFilter) > 0 {
+		table.WhereFilter = "(" + table.WhereFilter + ") AND " + filter
+	} else {
+		table.WhereFilter = filter
+	}
+	schemaMetadata["rhnchanneltrust"] = table
+}
+
+// dumpOrgTrusts writes the trusts between the exported organizations, so the shared channels are accessible to the
+// same organizations on the target server
+func dumpOrgTrusts(db *sql.DB, writer *bufio.Writer, options DumperOptions) {
+	orgFilter := ""
+	if orgs := formatOrgsList(options); len(orgs) > 0 {
+		orgFilter = fmt.Sprintf(" WHERE tr.org_id IN (%s) AND tr.org_trust_id IN (%s)", orgs, orgs)
+	}
+	trusts := sqlUtil.ExecuteQueryWithResults(db, orgTrustsSql+orgFilter+" ORDER BY tr.org_id, tr.org_trust_id;")
+	log.Info().Msgf("%d organization trusts to process", len(trusts))
+	writer.WriteString("-- organization trusts\n")
+	for _, trust := range trusts {
+		writer.WriteString(formatOrgTrust(dumper.AnonymizeValue("web_customer", "name", fmt.Sprintf("%s", trust[0].Value)),
+			dumper.AnonymizeValue("web_customer", "name", fmt.Sprintf("%s", trust[1].Value))) + "\n")
+	}
+	writer.WriteString("-- end of organization trusts\n")
+}
+
+// formatOrgTrust returns the statement adding the trust between the organizations, if the target server has both
+func formatOrgTrust(orgName string, trustedOrgName string) string {
+	return fmt.Sprintf(`INSERT INTO rhntrustedorgs (org_id, org_trust_id, created, modified)
+	SELECT o.id, t.id, current_timestamp, current_timestamp FROM web_customer o, web_customer t
+	WHERE o.id = (SELECT id FROM web_customer WHERE name = %s LIMIT 1) AND t.id = (SELECT id FROM web_customer WHERE name = %s LIMIT 1)
+	ON CONFLICT DO NOTHING;`, pq.QuoteLiteral(orgName), pq.QuoteLiteral(trustedOrgName))
+}
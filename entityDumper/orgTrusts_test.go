@@ -0,0 +1,31 @@
+package entityDumper
+
+import (
+	"testing"
+
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+)
+
+func TestFormatOrgTrust(t *testing.T) {
+	expected := `INSERT INTO rhntrustedorgs (org_id, org_trust_id, created, modified)
+	SELECT o.id, t.id, current_timestamp, current_timestamp FROM web_customer o, web_customer t
+	WHERE o.id = (SELECT id FROM web_customer WHERE name = 'hub' LIMIT 1) AND t.id = (SELECT id FROM web_customer WHERE name = 'Tom''s org' LIMIT 1)
+	ON CONFLICT DO NOTHING;`
+	result := formatOrgTrust("hub", "Tom's org")
+	if result != expected {
+		t.Errorf("Unexpected trust statement: %s", result)
+	}
+}
+
+func TestApplyChannelTrustFilters(t *testing.T) {
+	schemaMetadata := map[string]schemareader.Table{"rhnchanneltrust": {Name: "rhnchanneltrust"}}
+	applyChannelTrustFilters(schemaMetadata, DumperOptions{})
+	if filter := schemaMetadata["rhnchanneltrust"].WhereFilter; len(filter) > 0 {
+		t.Errorf("Expected the trusts of all organizations, got filter %s", filter)
+	}
+
+	applyChannelTrustFilters(schemaMetadata, DumperOptions{Orgs: []uint{1, 3}})
+	if filter := schemaMetadata["rhnchanneltrust"].WhereFilter; filter != "org_trust_id IN (1, 3)" {
+		t.Errorf("Unexpected filter %s", filter)
+	}
+}
@@ -0,0 +1,115 @@
+package entityDumper
+
+import (
+	"bufio"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/dumper"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+)
+
+// systems are not exported, so the proxy information is set on the systems already registered to the target server
+var proxyInfoSql = `SELECT s.machine_id, evr.epoch, evr.version, evr.release FROM rhnproxyinfo pi
+	JOIN rhnserver s ON s.id = pi.server_id
+	LEFT JOIN rhnpackageevr evr ON evr.id = pi.proxy_evr_id
+	WHERE s.machine_id IS NOT NULL`
+
+// the proxies the systems connect through, the proxy closest to the system first
+var proxyPathsSql = `SELECT s.machine_id, p.machine_id, sp.position, sp.hostname FROM rhnserverpath sp
+	JOIN rhnserver s ON s.id = sp.server_id
+	JOIN rhnserver p ON p.id = sp.proxy_server_id
+	WHERE s.machine_id IS NOT NULL`
+
+// proxyHop is a proxy of the path of a system, by machine id, with its position and the host name the system uses
+type proxyHop struct {
+	machineId string
+	position  string
+	hostname  string
+}
+
+func dumpProxyData(db *sql.DB, writer *bufio.Writer, options DumperOptions) {
+	log.Trace().Msg("Processing proxies")
+	orgFilter := strings.Replace(formatOrgFilter(options), " t.", " s.", 1)
+	writer.WriteString("-- proxies\n")
+
+	proxies := sqlUtil.ExecuteQueryWithResults(db, proxyInfoSql+orgFilter+" ORDER BY s.id;")
+	log.Info().Msgf("%d proxies to process", len(proxies))
+	for _, proxy := range proxies {
+		writer.WriteString(formatProxyInfo(fmt.Sprintf("%s", proxy[0].Value), formatNullableText(proxy[1].Value),
+			formatNullableText(proxy[2].Value), formatNullableText(proxy[3].Value)) + "\n")
+	}
+
+	paths := make(map[string][]proxyHop)
+	systems := make([]string, 0)
+	// proxies without machine id cannot be found on the target server, so the paths through them are not exported
+	incomplete := make(map[string]bool)
+	for _, hop := range sqlUtil.ExecuteQueryWithResults(db, proxyPathsSql+orgFilter+" ORDER BY s.id, sp.position;") {
+		machineId := fmt.Sprintf("%s", hop[0].Value)
+		if _, ok := paths[machineId]; !ok {
+			systems = append(systems, machineId)
+		}
+		if hop[1].Value == nil {
+			incomplete[machineId] = true
+		}
+		paths[machineId] = append(paths[machineId], proxyHop{machineId: formatNullableText(hop[1].Value),
+			position: fmt.Sprintf("%v", hop[2].Value),
+			hostname: dumper.AnonymizeValue("rhnserverpath", "hostname", fmt.Sprintf("%s", hop[3].Value))})
+	}
+	if len(incomplete) > 0 {
+		log.Warn().Msgf("%d systems connect through proxies without machine id, their proxy paths are not exported", len(incomplete))
+	}
+	log.Info().Msgf("%d system proxy paths to process", len(systems)-len(incomplete))
+	for _, machineId := range systems {
+		if !incomplete[machineId] {
+			writer.WriteString(formatProxyPath(machineId, paths[machineId]) + "\n")
+		}
+	}
+	writer.WriteString("-- end of proxies\n")
+	log.Debug().Msg("proxies export done")
+}
+
+// formatNullableText returns the text of a nullable column value, empty for NULL
+func formatNullableText(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s", value)
+}
+
+// formatProxyInfo returns the statement marking a system as proxy with its version, if the target server knows it.
+// The version is unset when the target server has no package with it.
+func formatProxyInfo(machineId string, epoch string, version string, release string) string {
+	evr := "NULL"
+	if len(version) > 0 {
+		epochLiteral := "NULL"
+		if len(epoch) > 0 {
+			epochLiteral = pq.QuoteLiteral(epoch)
+		}
+		evr = fmt.Sprintf("(SELECT id FROM rhnpackageevr WHERE epoch IS NOT DISTINCT FROM %s AND version = %s AND release = %s LIMIT 1)",
+			epochLiteral, pq.QuoteLiteral(version), pq.QuoteLiteral(release))
+	}
+	return fmt.Sprintf(`INSERT INTO rhnproxyinfo (server_id, proxy_evr_id) SELECT id, %s FROM rhnserver WHERE machine_id = %s
+	ON CONFLICT (server_id) DO UPDATE SET proxy_evr_id = EXCLUDED.proxy_evr_id;`, evr, pq.QuoteLiteral(machineId))
+}
+
+// formatProxyPath returns the statements replacing the proxy path of a system, if the target server knows the
+// system and all the proxies of the path
+func formatProxyPath(machineId string, path []proxyHop) string {
+	proxies := make([]string, 0, len(path))
+	for _, hop := range path {
+		proxies = append(proxies, pq.QuoteLiteral(hop.machineId))
+	}
+	knownPath := fmt.Sprintf("(SELECT count(*) FROM rhnserver WHERE machine_id IN (%s)) = %d", strings.Join(proxies, ", "), len(path))
+	statements := []string{fmt.Sprintf(`DELETE FROM rhnserverpath WHERE server_id = (SELECT id FROM rhnserver WHERE machine_id = %s)
+	AND %s;`, pq.QuoteLiteral(machineId), knownPath)}
+	for _, hop := range path {
+		statements = append(statements, fmt.Sprintf(`INSERT INTO rhnserverpath (server_id, proxy_server_id, position, hostname)
+	SELECT s.id, p.id, %s, %s FROM rhnserver s, rhnserver p WHERE s.machine_id = %s AND p.machine_id = %s AND %s;`,
+			hop.position, pq.QuoteLiteral(hop.hostname), pq.QuoteLiteral(machineId), pq.QuoteLiteral(hop.machineId), knownPath))
+	}
+	return strings.Join(statements, "\n")
+}
@@ -0,0 +1,38 @@
+package entityDumper
+
+import "testing"
+
+func TestFormatProxyInfo(t *testing.T) {
+	expected := `INSERT INTO rhnproxyinfo (server_id, proxy_evr_id) SELECT id, (SELECT id FROM rhnpackageevr WHERE epoch IS NOT DISTINCT FROM NULL AND version = '4.3.8' AND release = '150400.3.1' LIMIT 1) FROM rhnserver WHERE machine_id = 'a1b2c3'
+	ON CONFLICT (server_id) DO UPDATE SET proxy_evr_id = EXCLUDED.proxy_evr_id;`
+	result := formatProxyInfo("a1b2c3", "", "4.3.8", "150400.3.1")
+	if result != expected {
+		t.Errorf("Unexpected proxy statement: %s", result)
+	}
+}
+
+func TestFormatProxyInfoWithoutVersion(t *testing.T) {
+	expected := `INSERT INTO rhnproxyinfo (server_id, proxy_evr_id) SELECT id, NULL FROM rhnserver WHERE machine_id = 'a1b2c3'
+	ON CONFLICT (server_id) DO UPDATE SET proxy_evr_id = EXCLUDED.proxy_evr_id;`
+	result := formatProxyInfo("a1b2c3", "", "", "")
+	if result != expected {
+		t.Errorf("Unexpected proxy statement: %s", result)
+	}
+}
+
+func TestFormatProxyPath(t *testing.T) {
+	known := "(SELECT count(*) FROM rhnserver WHERE machine_id IN ('p1', 'p2')) = 2"
+	expected := `DELETE FROM rhnserverpath WHERE server_id = (SELECT id FROM rhnserver WHERE machine_id = 'a1b2c3')
+	AND ` + known + `;
+INSERT INTO rhnserverpath (server_id, proxy_server_id, position, hostname)
+	SELECT s.id, p.id, 0, 'proxy1.example.com' FROM rhnserver s, rhnserver p WHERE s.machine_id = 'a1b2c3' AND p.machine_id = 'p1' AND ` + known + `;
+INSERT INTO rhnserverpath (server_id, proxy_server_id, position, hostname)
+	SELECT s.id, p.id, 1, 'proxy2.example.com' FROM rhnserver s, rhnserver p WHERE s.machine_id = 'a1b2c3' AND p.machine_id = 'p2' AND ` + known + `;`
+	result := formatProxyPath("a1b2c3", []proxyHop{
+		{machineId: "p1", position: "0", hostname: "proxy1.example.com"},
+		{machineId: "p2", position: "1", hostname: "proxy2.example.com"},
+	})
+	if result != expected {
+		t.Errorf("Unexpected proxy path statements: %s", result)
+	}
+}
@@ -0,0 +1,260 @@
+package entityDumper
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// RepoSyncSchedulesFileName lists the repository synchronization schedules of the exported channels, which are
+// taskomatic schedules created again on the target server through the API
+const RepoSyncSchedulesFileName = "repoSyncSchedules.json"
+
+// RepoSyncSchedule is the repository synchronization schedule of a channel, with the parameters of the
+// synchronization like no-errata or latest
+type RepoSyncSchedule struct {
+	Channel  string            `json:"channel"`
+	CronExpr string            `json:"cronExpr"`
+	Params   map[string]string `json:"params,omitempty"`
+}
+
+// the active schedules of the repo-sync bunch, named repo-sync-<org id>-<channel id> by the server
+var repoSyncSchedulesSql = `SELECT c.label, s.cron_expr, encode(s.data, 'base64') FROM rhntaskoschedule s
+	JOIN rhntaskobunch b ON b.id = s.bunch_id
+	JOIN rhnchannel c ON s.job_label LIKE 'repo-sync-%-' || c.id
+	WHERE b.name = 'repo-sync-bunch' AND s.cron_expr IS NOT NULL AND (s.active_till IS NULL OR s.active_till > current_timestamp)
+	AND c.label = ANY($1)
+	ORDER BY c.label, s.id;`
+
+// dumpRepoSyncSchedules writes the schedules of the channels, if any
+func dumpRepoSyncSchedules(db *sql.DB, outputFolder string, channelLabels []string) {
+	schedules := make([]RepoSyncSchedule, 0)
+	seen := make(map[string]bool)
+	for _, row := range sqlUtil.ExecuteQueryWithResults(db, repoSyncSchedulesSql, pq.Array(channelLabels)) {
+		channelLabel := fmt.Sprintf("%s", row[0].Value)
+		if seen[channelLabel] {
+			log.Warn().Msgf("Channel %s has several repository synchronization schedules, only the first one is exported", channelLabel)
+			continue
+		}
+		seen[channelLabel] = true
+		schedule := RepoSyncSchedule{Channel: channelLabel, CronExpr: fmt.Sprintf("%s", row[1].Value)}
+		if row[2].Value != nil {
+			params, err := decodeScheduleData(fmt.Sprintf("%s", row[2].Value))
+			if err != nil {
+				log.Warn().Err(err).Msgf("Parameters of the repository synchronization schedule of %s cannot be read, the default ones are used", channelLabel)
+			}
+			// the channel is selected by label on the target server
+			delete(params, "channel_id")
+			if len(params) > 0 {
+				schedule.Params = params
+			}
+		}
+		schedules = append(schedules, schedule)
+	}
+	log.Info().Msgf("%d repository synchronization schedules exported", len(schedules))
+	WriteRepoSyncSchedules(outputFolder, schedules)
+}
+
+// WriteRepoSyncSchedules writes the schedules, if any
+func WriteRepoSyncSchedules(outputFolder string, schedules []RepoSyncSchedule) {
+	if len(schedules) == 0 {
+		return
+	}
+	content, err := json.MarshalIndent(schedules, "", "  ")
+	if err != nil {
+		utils.Panic().Err(err).Msg("error encoding repository synchronization schedules")
+	}
+	if err := os.WriteFile(filepath.Join(outputFolder, RepoSyncSchedulesFileName), content, 0644); err != nil {
+		utils.Panic().Err(err).Msg("error writing repository synchronization schedules")
+	}
+}
+
+// ReadRepoSyncSchedules reads the schedules of the imported channels, nil without schedules
+func ReadRepoSyncSchedules(importFolder string) []RepoSyncSchedule {
+	content, err := os.ReadFile(filepath.Join(importFolder, RepoSyncSchedulesFileName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		utils.Fatal().Err(err).Msg("error reading repository synchronization schedules")
+	}
+	schedules := make([]RepoSyncSchedule, 0)
+	if err := json.Unmarshal(content, &schedules); err != nil {
+		utils.Fatal().Err(err).Msg("repository synchronization schedules are corrupted")
+	}
+	return schedules
+}
+
+func decodeScheduleData(encoded string) (map[string]string, error) {
+	// base64 lines of postgres are wrapped
+	data, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(encoded, "\n", ""))
+	if err != nil {
+		return nil, err
+	}
+	return readSerializedStringMap(data)
+}
+
+// serialized Java objects, see the Object Serialization Stream Protocol
+const (
+	javaStreamMagic   = 0xaced
+	javaNull          = 0x70
+	javaReference     = 0x71
+	javaClassDesc     = 0x72
+	javaObject        = 0x73
+	javaString        = 0x74
+	javaBlockData     = 0x77
+	javaEndBlockData  = 0x78
+	javaBaseHandle    = 0x7e0000
+	javaHashMapClass  = "java.util.HashMap"
+	javaStreamVersion = 5
+)
+
+// lengths of the values of the primitive fields by type code
+var javaFieldLengths = map[byte]int{'B': 1, 'C': 2, 'D': 8, 'F': 4, 'I': 4, 'J': 8, 'S': 2, 'Z': 1}
+
+var errUnsupportedSerialization = errors.New("the schedule data is not a serialized map of strings")
+
+// javaStream reads the data of a serialized Java object
+type javaStream struct {
+	data    []byte
+	handles []string
+}
+
+func (stream *javaStream) read(length int) ([]byte, error) {
+	if len(stream.data) < length {
+		return nil, errUnsupportedSerialization
+	}
+	result := stream.data[:length]
+	stream.data = stream.data[length:]
+	return result, nil
+}
+
+func (stream *javaStream) readByte() (byte, error) {
+	value, err := stream.read(1)
+	if err != nil {
+		return 0, err
+	}
+	return value[0], nil
+}
+
+func (stream *javaStream) readUtf() (string, error) {
+	length, err := stream.read(2)
+	if err != nil {
+		return "", err
+	}
+	value, err := stream.read(int(binary.BigEndian.Uint16(length)))
+	return string(value), err
+}
+
+// readString reads a new string, or a reference to a string read before
+func (stream *javaStream) readString() (string, error) {
+	tag, err := stream.readByte()
+	if err != nil {
+		return "", err
+	}
+	switch tag {
+	case javaString:
+		value, err := stream.readUtf()
+		stream.handles = append(stream.handles, value)
+		return value, err
+	case javaReference:
+		handle, err := stream.read(4)
+		if err != nil {
+			return "", err
+		}
+		index := int(binary.BigEndian.Uint32(handle)) - javaBaseHandle
+		if index < 0 || index >= len(stream.handles) {
+			return "", errUnsupportedSerialization
+		}
+		return stream.handles[index], nil
+	}
+	return "", errUnsupportedSerialization
+}
+
+// readSerializedStringMap reads the serialized HashMap<String, String> taskomatic stores the parameters of the
+// schedules in
+func readSerializedStringMap(data []byte) (map[string]string, error) {
+	stream := &javaStream{data: data}
+	header, err := stream.read(4)
+	if err != nil || binary.BigEndian.Uint16(header) != javaStreamMagic || binary.BigEndian.Uint16(header[2:]) != javaStreamVersion {
+		return nil, errUnsupportedSerialization
+	}
+	if tag, err := stream.readByte(); err != nil || tag != javaObject {
+		return nil, errUnsupportedSerialization
+	}
+	if tag, err := stream.readByte(); err != nil || tag != javaClassDesc {
+		return nil, errUnsupportedSerialization
+	}
+	className, err := stream.readUtf()
+	if err != nil || className != javaHashMapClass {
+		return nil, errUnsupportedSerialization
+	}
+	// serial version and flags
+	if _, err := stream.read(9); err != nil {
+		return nil, err
+	}
+	stream.handles = append(stream.handles, className)
+	fieldCount, err := stream.read(2)
+	if err != nil {
+		return nil, err
+	}
+	fieldsLength := 0
+	for i := 0; i < int(binary.BigEndian.Uint16(fieldCount)); i++ {
+		fieldType, err := stream.readByte()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := stream.readUtf(); err != nil {
+			return nil, err
+		}
+		// fields of objects would be followed by their class name
+		length, ok := javaFieldLengths[fieldType]
+		if !ok {
+			return nil, errUnsupportedSerialization
+		}
+		fieldsLength += length
+	}
+	// no class annotation and no serializable super class
+	if end, err := stream.read(2); err != nil || end[0] != javaEndBlockData || end[1] != javaNull {
+		return nil, errUnsupportedSerialization
+	}
+	// the map object itself
+	stream.handles = append(stream.handles, "")
+	if _, err := stream.read(fieldsLength); err != nil {
+		return nil, err
+	}
+	// capacity and size of the map, written by HashMap.writeObject
+	block, err := stream.read(2)
+	if err != nil || block[0] != javaBlockData || block[1] != 8 {
+		return nil, errUnsupportedSerialization
+	}
+	sizes, err := stream.read(8)
+	if err != nil {
+		return nil, err
+	}
+	size := int(binary.BigEndian.Uint32(sizes[4:]))
+	result := make(map[string]string, size)
+	for i := 0; i < size; i++ {
+		key, err := stream.readString()
+		if err != nil {
+			return nil, err
+		}
+		value, err := stream.readString()
+		if err != nil {
+			return nil, err
+		}
+		result[key] = value
+	}
+	return result, nil
+}
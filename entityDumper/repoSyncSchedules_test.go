@@ -0,0 +1,84 @@
+package entityDumper
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"reflect"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/uyuni-project/inter-server-sync/tests"
+)
+
+// serializedStringMap returns the entries serialized like a java.util.HashMap, a value of "" referring to the
+// previous value
+func serializedStringMap(entries [][2]string) []byte {
+	var data bytes.Buffer
+	writeUtf := func(value string) {
+		binary.Write(&data, binary.BigEndian, uint16(len(value)))
+		data.WriteString(value)
+	}
+	data.Write([]byte{0xac, 0xed, 0x00, 0x05, javaObject, javaClassDesc})
+	writeUtf(javaHashMapClass)
+	data.Write([]byte{0x05, 0x07, 0xda, 0xc1, 0xc3, 0x16, 0x60, 0xd1, 0x03, 0x00, 0x02, 'F'})
+	writeUtf("loadFactor")
+	data.WriteByte('I')
+	writeUtf("threshold")
+	data.Write([]byte{javaEndBlockData, javaNull, 0x3f, 0x40, 0x00, 0x00, 0x00, 0x00, 0x00, 0x0c, javaBlockData, 0x08})
+	binary.Write(&data, binary.BigEndian, uint32(16))
+	binary.Write(&data, binary.BigEndian, uint32(len(entries)))
+	handle := uint32(javaBaseHandle + 2)
+	for _, entry := range entries {
+		data.WriteByte(javaString)
+		writeUtf(entry[0])
+		handle++
+		if len(entry[1]) == 0 {
+			data.WriteByte(javaReference)
+			binary.Write(&data, binary.BigEndian, handle-2)
+			continue
+		}
+		data.WriteByte(javaString)
+		writeUtf(entry[1])
+		handle++
+	}
+	data.WriteByte(javaEndBlockData)
+	return data.Bytes()
+}
+
+func TestReadSerializedStringMap(t *testing.T) {
+	data := serializedStringMap([][2]string{{"channel_id", "105"}, {"no-errata", "true"}, {"latest", ""}})
+
+	result, err := readSerializedStringMap(data)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"channel_id": "105", "no-errata": "true", "latest": "true"}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+	if _, err := readSerializedStringMap(data[:len(data)-10]); err == nil {
+		t.Errorf("truncated data should not be read")
+	}
+}
+
+func TestDumpRepoSyncSchedules(t *testing.T) {
+	repo := tests.CreateDataRepository()
+	data := base64.StdEncoding.EncodeToString(serializedStringMap([][2]string{{"channel_id", "105"}, {"fail", "true"}}))
+	repo.ExpectWithRecords(repoSyncSchedulesSql, sqlmock.NewRows([]string{"label", "cron_expr", "data"}).
+		AddRow("custom-channel", "0 0 2 ? * *", data[:20]+"\n"+data[20:]).
+		AddRow("custom-channel", "0 0 4 ? * *", nil).
+		AddRow("other-channel", "0 30 1 ? * SAT", nil))
+	outputFolder := t.TempDir()
+
+	dumpRepoSyncSchedules(repo.DB, outputFolder, []string{"custom-channel", "other-channel"})
+
+	expected := []RepoSyncSchedule{
+		{Channel: "custom-channel", CronExpr: "0 0 2 ? * *", Params: map[string]string{"fail": "true"}},
+		{Channel: "other-channel", CronExpr: "0 30 1 ? * SAT"},
+	}
+	if schedules := ReadRepoSyncSchedules(outputFolder); !reflect.DeepEqual(schedules, expected) {
+		t.Errorf("expected %v, got %v", expected, schedules)
+	}
+}
@@ -0,0 +1,72 @@
+package entityDumper
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+)
+
+// applyRepositoryFilters restricts the exported repositories to the custom ones, since vendor repositories
+// are created by the product synchronization of the target, and removes their credentials unless requested
+func applyRepositoryFilters(schemaMetadata map[string]schemareader.Table, options DumperOptions) {
+	table, ok := schemaMetadata["rhncontentsource"]
+	if !ok {
+		return
+	}
+	if len(table.WhereFilter) > 0 {
+		table.WhereFilter = "(" + table.WhereFilter + ") AND org_id IS NOT NULL"
+	} else {
+		table.WhereFilter = "org_id IS NOT NULL"
+	}
+	// the URL can be changed when scrubbing credentials, so repositories are only identified by label
+	table.UniqueIndexes[schemareader.VirtualIndexName] = schemareader.UniqueIndex{
+		Name: schemareader.VirtualIndexName, Columns: []string{"org_id", "label"}}
+	table.MainUniqueIndexName = schemareader.VirtualIndexName
+	if !options.IncludeRepoCredentials {
+		table.RowModCallback = scrubRepositoryCredentials
+	}
+	schemaMetadata["rhncontentsource"] = table
+}
+
+func scrubRepositoryCredentials(value []sqlUtil.RowDataStructure, table schemareader.Table) []sqlUtil.RowDataStructure {
+	for i, column := range value {
+		if column.ColumnName == "source_url" && column.Value != nil {
+			value[i].Value = scrubRepositoryUrl(formatText(column.Value))
+		}
+	}
+	return value
+}
+
+// scrubRepositoryUrl removes user information, the credentials parameter and authentication tokens from the URL
+func scrubRepositoryUrl(repositoryUrl string) string {
+	parsedUrl, err := url.Parse(repositoryUrl)
+	if err != nil {
+		log.Warn().Msgf("Repository URL %s cannot be parsed, exported as is", repositoryUrl)
+		return repositoryUrl
+	}
+	parsedUrl.User = nil
+	if len(parsedUrl.RawQuery) > 0 {
+		// query strings without parameters are authentication tokens
+		if !strings.Contains(parsedUrl.RawQuery, "=") {
+			parsedUrl.RawQuery = ""
+		} else {
+			query := parsedUrl.Query()
+			query.Del("credentials")
+			parsedUrl.RawQuery = query.Encode()
+		}
+	}
+	return parsedUrl.String()
+}
+
+func formatText(value interface{}) string {
+	if bytes, ok := value.([]byte); ok {
+		return string(bytes)
+	}
+	if text, ok := value.(string); ok {
+		return text
+	}
+	return ""
+}
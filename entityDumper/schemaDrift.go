@@ -0,0 +1,83 @@
+package entityDumper
+
+import (
+	"database/sql"
+	"os"
+
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// exportedTableNames returns the root tables of the entities selected for export
+func exportedTableNames(options DumperOptions) []string {
+	tableNames := make([]string, 0)
+	if len(options.ChannelLabels) > 0 || len(options.ChannelWithChildrenLabels) > 0 {
+		tableNames = append(tableNames, ProductsTableNames()...)
+		tableNames = append(tableNames, channelTableNames(options)...)
+		tableNames = append(tableNames, channelCryptoKeyTableNames()...)
+	} else if options.Products {
+		tableNames = append(tableNames, productBootstrapTableNames()...)
+	}
+	if len(options.ConfigLabels) > 0 {
+		tableNames = append(tableNames, ConfigTableNames()...)
+	}
+	if options.OSImages || options.Containers {
+		tableNames = append(tableNames, ImageTableNames()...)
+	}
+	if options.Autoinstall {
+		tableNames = append(tableNames, AutoinstallTableNames()...)
+	}
+	if options.MaintenanceSchedules {
+		tableNames = append(tableNames, MaintenanceTableNames()...)
+	}
+	if options.Users {
+		tableNames = append(tableNames, UserTableNames()...)
+	}
+	if len(options.TableName) > 0 {
+		tableNames = append(tableNames, options.TableName)
+	}
+	return tableNames
+}
+
+func readTargetSchema(options DumperOptions, tableNames []string) map[string]schemareader.Table {
+	if len(options.TargetSchema) > 0 {
+		file, err := os.Open(utils.GetAbsPath(options.TargetSchema))
+		if err != nil {
+			utils.Fatal().Err(err).Msg("error opening target schema")
+		}
+		defer file.Close()
+		tables, err := schemareader.ReadFromJSON(file)
+		if err != nil {
+			utils.Fatal().Err(err).Msg("error reading target schema")
+		}
+		return tables
+	}
+	targetDB := schemareader.GetDBconnection(options.TargetServerConfig)
+	defer targetDB.Close()
+	return schemareader.ReadTablesSchema(targetDB, tableNames)
+}
+
+// checkSchemaDrift compares the exported tables with the target ones, if a target schema is configured,
+// and stops the export when the import would fail because of them
+func checkSchemaDrift(db *sql.DB, options DumperOptions) {
+	if len(options.TargetSchema) == 0 && len(options.TargetServerConfig) == 0 {
+		return
+	}
+	tableNames := exportedTableNames(options)
+	differences := schemareader.CompareSchemas(schemareader.ReadTablesSchema(db, tableNames),
+		readTargetSchema(options, tableNames))
+
+	blocking := 0
+	for _, difference := range differences {
+		if difference.Blocking {
+			blocking++
+			log.Error().Msgf("Schema drift: %s", difference)
+		} else {
+			log.Warn().Msgf("Schema drift: %s", difference)
+		}
+	}
+	if blocking > 0 {
+		utils.Fatal().Int(utils.ExitCodeField, utils.ExitSchemaMismatch).Msgf("%d schema differences with the target would make the import fail", blocking)
+	}
+}
@@ -0,0 +1,42 @@
+package entityDumper
+
+import (
+	"bufio"
+	"database/sql"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/dumper"
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// processTableData exports the rows of an arbitrary table matching the filter,
+// together with all the rows they reference through foreign keys
+func processTableData(db *sql.DB, writer *bufio.Writer, options DumperOptions) {
+	log.Info().Msgf("Processing table %s", options.TableName)
+	schemaMetadata := schemareader.ReadTablesSchema(db, []string{options.TableName})
+	startTable, ok := schemaMetadata[options.TableName]
+	if !ok {
+		utils.Fatal().Msgf("Table not found: %s", options.TableName)
+	}
+	// referenced tables are not exportable by default, since entity exporters assume them on the target,
+	// while the table export writes all the rows the table references
+	tableNames := make([]string, 0)
+	for tableName := range schemaMetadata {
+		tableNames = append(tableNames, tableName)
+	}
+	markAsExported(schemaMetadata, tableNames)
+	applyWhereFilters(schemaMetadata, options)
+	startTable = schemaMetadata[options.TableName]
+	log.Debug().Msgf("%d tables reachable from %s", len(tableNames), options.TableName)
+
+	tableData := crawlTableData(db, schemaMetadata, startTable, options.TableFilter, options)
+	if _, ok := tableData.TableData[options.TableName]; !ok {
+		log.Warn().Msgf("No rows found in table %s matching the filter", options.TableName)
+	}
+
+	writer.WriteString(fmt.Sprintf("-- %s table data\n", options.TableName))
+	dumper.PrintTableDataOrdered(db, writer, schemaMetadata, startTable, tableData, dumper.PrintSqlOptions{})
+	log.Debug().Msg("table export finished")
+}
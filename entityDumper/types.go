@@ -1,6 +1,10 @@
 package entityDumper
 
 import (
+	"time"
+
+	"github.com/uyuni-project/inter-server-sync/dumper"
+	"github.com/uyuni-project/inter-server-sync/placeholders"
 	"github.com/uyuni-project/inter-server-sync/utils"
 )
 
@@ -12,10 +16,102 @@ type DumperOptions struct {
 	OutputFolder              string
 	outputFolderAbsPath       string
 	MetadataOnly              bool
+	IncludeRepodata           bool
+	IncludeRepoCredentials    bool
 	StartingDate              string
 	Containers                bool
 	OSImages                  bool
+	Autoinstall               bool
+	Products                  bool
+	MaintenanceSchedules      bool
+	Proxies                   bool
+	Users                     bool
+	IncludeUserPasswords      bool
 	Orgs                      []uint
+	// images exported with OSImages and Containers, all of them when empty
+	ImageFilters []ImageFilter
+	// user provided predicates restricting the exported rows, indexed by table name
+	WhereFilters map[string]string
+	// root table and rows filter for the generic table export
+	TableName   string
+	TableFilter string
+	// traversal limits of the data crawler
+	MaxDepth    int
+	PruneTables []string
+	// JSON file with rules limiting how far single foreign keys are followed
+	ReferenceRules string
+	referenceRules []dumper.ReferenceRule
+	manifest       *ExportManifest
+	// file with the rows exported to the target in previous runs, which are skipped
+	ExportedKeysCache string
+	// the export recorded last in ExportedKeysCache was imported on the target, so its rows can be skipped
+	ExportedKeysImported bool
+	// schema of the target server, as a schema dump file or a database configuration file
+	TargetSchema       string
+	TargetServerConfig string
+	// skip the rows already present on the target database of TargetServerConfig
+	SkipExistingOnTarget bool
+	// package inventory of the target server, see packageDumper.WriteInventory, whose package files are not exported
+	PackageInventory string
+	// file stores the package files, by their path relative to /var/spacewalk, and the image files, by their path
+	// relative to the image folder, are read from instead of the local folders, see storage.Open
+	PackageStore string
+	ImageStore   string
+	// values of the source server replaced with placeholders, indexed by token
+	Placeholders map[string]string
+	// columns whose values are replaced with placeholders
+	PlaceholderColumns placeholders.ColumnSet
+	// write each channel into its own export subdirectory, sharing the package files
+	ChannelSubdirectories bool
+	// the source database may be a hot standby: connections are read-only and statements time out
+	HotStandbySource       bool
+	SourceStatementTimeout time.Duration
+	// take the export lock, waiting for the services changing channel content which hold it
+	AdvisoryLock bool
+	// taskomatic bunches whose periodic schedules are paused during the export
+	PauseBunches []string
+	// read each query in its own snapshot, instead of reading the whole export in a single snapshot
+	NoSnapshot bool
+	// memory of the keys of the processed rows above which they are spilled to files in KeySpillDirectory,
+	// dumper.DefaultKeyMemoryLimit when 0
+	KeyMemoryLimit    int64
+	KeySpillDirectory string
+	// dumper.DedupExact (the default) or dumper.DedupApproximate
+	Dedup string
+	// dumper.IdsAlwaysNew (the default), dumper.IdsPreserveSource or dumper.IdsLookupUniqueIndex
+	IdStrategy string
+	// report the references of the exported rows to rows which are neither exported nor vendor data
+	VerifyReferences bool
+	// file collecting the rows and write durations of the exported tables across exports, ordering the traversal
+	// of the tables and estimating the duration of the next exports
+	StatisticsStore string
+	// export the child channels of all the ChannelLabels, as if they were ChannelWithChildrenLabels
+	IncludeChildren bool
+	// also export the channels the exported channels were cloned from, before their clones
+	IncludeCloneOrigins bool
+	// export the repository synchronization schedules of the exported channels, created again on import
+	RepoSyncSchedules bool
+	// do not export the file lists of the packages, which are most of the size of the package metadata
+	ExcludeFileLists bool
+	// number of the newest changelog entries exported for every package, all of them when 0
+	ChangelogLimit int
+	// replace host names, user names, IP addresses and organization names by pseudonyms
+	Anonymize bool
+	// number of files copied at the same time, dumper.DefaultCopyWorkers when 0
+	CopyWorkers int
+	// copy the bootstrap repositories of the server, which clients are onboarded from
+	BootstrapRepositories bool
+	// file with the hex encoded key sealing the passwords of the exported image store credentials
+	CredentialsKeyFile string
+	credentialsKey     []byte
+}
+
+// ChannelSelection returns the channels exported alone, and the channels exported with their children
+func (opt *DumperOptions) ChannelSelection() ([]string, []string) {
+	if opt.IncludeChildren {
+		return nil, append(append([]string{}, opt.ChannelLabels...), opt.ChannelWithChildrenLabels...)
+	}
+	return opt.ChannelLabels, opt.ChannelWithChildrenLabels
 }
 
 func (opt *DumperOptions) GetOutputFolderAbsPath() string {
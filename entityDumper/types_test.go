@@ -0,0 +1,20 @@
+package entityDumper
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestChannelSelection(t *testing.T) {
+	options := DumperOptions{ChannelLabels: []string{"base"}, ChannelWithChildrenLabels: []string{"other"}}
+	single, withChildren := options.ChannelSelection()
+	if !reflect.DeepEqual(single, []string{"base"}) || !reflect.DeepEqual(withChildren, []string{"other"}) {
+		t.Errorf("unexpected channels %v and %v with children", single, withChildren)
+	}
+
+	options.IncludeChildren = true
+	single, withChildren = options.ChannelSelection()
+	if len(single) != 0 || !reflect.DeepEqual(withChildren, []string{"base", "other"}) {
+		t.Errorf("all channels should be exported with children, got %v and %v with children", single, withChildren)
+	}
+}
@@ -0,0 +1,57 @@
+package entityDumper
+
+import (
+	"bufio"
+	"database/sql"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/dumper"
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+)
+
+func UserTableNames() []string {
+	return []string{
+		"web_contact",
+		"web_user_personal_info",
+		"rhnuserinfo",
+		// roles
+		"rhnusergroupmembers",
+		"rhnusergroup",
+	}
+}
+
+func dumpUserData(db *sql.DB, writer *bufio.Writer, options DumperOptions) {
+	log.Trace().Msg("Processing users")
+	schemaMetadata := schemareader.ReadTablesSchema(db, UserTableNames())
+	applyWhereFilters(schemaMetadata, options)
+	if !options.IncludeUserPasswords {
+		table := schemaMetadata["web_contact"]
+		table.RowModCallback = lockUserPassword
+		schemaMetadata["web_contact"] = table
+	}
+	log.Debug().Msg("user schema metadata loaded")
+
+	whereFilter := "1 = 1"
+	if len(options.Orgs) > 0 {
+		whereFilter = strings.Replace(formatOrgFilter(options), " AND t.", "", 1)
+	}
+	tableData := crawlTableData(db, schemaMetadata, schemaMetadata["web_contact"], whereFilter, options)
+	log.Info().Msgf("%d users to export", len(tableData.TableData["web_contact"].Keys))
+
+	writer.WriteString("-- users\n")
+	dumper.PrintTableDataOrdered(db, writer, schemaMetadata, schemaMetadata["web_contact"], tableData, dumper.PrintSqlOptions{})
+	log.Debug().Msg("users export done")
+}
+
+// lockUserPassword replaces the password hash, so new users need a password reset on the target server
+// and existing users keep their password
+func lockUserPassword(value []sqlUtil.RowDataStructure, table schemareader.Table) []sqlUtil.RowDataStructure {
+	for i, column := range value {
+		if column.ColumnName == "password" {
+			value[i].Value = dumper.LockedPassword
+		}
+	}
+	return value
+}
@@ -1,11 +1,17 @@
 package entityDumper
 
 import (
+	"database/sql"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/dumper"
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
 	"github.com/uyuni-project/inter-server-sync/utils"
 )
 
@@ -15,20 +21,92 @@ func ValidateExportFolder(outputFolderAbs string) {
 	defer outputFolder.Close()
 	_, errEmpty := outputFolder.Readdirnames(1) // Or f.Readdir(1)
 	if errEmpty != io.EOF {
-		log.Fatal().Msg(fmt.Sprintf("Export location is not empty: %s", outputFolderAbs))
+		utils.Fatal().Int(utils.ExitCodeField, utils.ExitConfigError).Msg(fmt.Sprintf("Export location is not empty: %s", outputFolderAbs))
 	}
 }
 
+// IncompleteMarkerFileName is written into exports which stopped before completion, so they are not imported
+const IncompleteMarkerFileName = "export_incomplete.txt"
+
+// MarkIncomplete records that the export in the folder, and its channel subdirectories, stopped before completion
+func MarkIncomplete(outputFolderAbs string) {
+	folders := append([]string{outputFolderAbs}, ChannelSubdirectories(outputFolderAbs)...)
+	for _, folder := range folders {
+		content := "The export stopped before completion, export the data again.\n"
+		if err := os.WriteFile(filepath.Join(folder, IncompleteMarkerFileName), []byte(content), 0644); err != nil {
+			log.Error().Err(err).Msgf("Error marking the export in %s incomplete", folder)
+		}
+	}
+}
+
+// IsIncomplete tells if the export in the folder stopped before completion
+func IsIncomplete(folder string) bool {
+	_, err := os.Stat(filepath.Join(folder, IncompleteMarkerFileName))
+	return err == nil
+}
+
 func ValidateExistingFolder(outputFolderAbs string) {
 	err := utils.FolderExists(outputFolderAbs)
 	if err != nil {
 		if os.IsNotExist(err) {
 			err := os.MkdirAll(outputFolderAbs, 0755)
 			if err != nil {
-				log.Fatal().Err(err).Msg("Error creating directory")
+				utils.Fatal().Err(err).Msg("Error creating directory")
 			}
 		} else {
-			log.Fatal().Err(err).Msg("Error getting output folder")
+			utils.Fatal().Err(err).Msg("Error getting output folder")
+		}
+	}
+}
+
+// validateWhereFilters stops the export when row filters are set on tables which don't exist, since they would be
+// ignored and all the rows exported
+func validateWhereFilters(db *sql.DB, options DumperOptions) {
+	if len(options.WhereFilters) == 0 {
+		return
+	}
+	tableNames := make([]string, 0, len(options.WhereFilters))
+	for tableName := range options.WhereFilters {
+		tableNames = append(tableNames, tableName)
+	}
+	if unknown := schemareader.UnknownTables(db, tableNames); len(unknown) > 0 {
+		utils.Fatal().Int(utils.ExitCodeField, utils.ExitConfigError).Msgf("Row filters set on unknown tables: %s", strings.Join(unknown, ", "))
+	}
+}
+
+// applyWhereFilters sets the user provided row filters on the tables of the schema
+func applyWhereFilters(schemaMetadata map[string]schemareader.Table, options DumperOptions) {
+	for tableName, filter := range options.WhereFilters {
+		table, ok := schemaMetadata[tableName]
+		if !ok {
+			continue
 		}
+		log.Debug().Msgf("Filtering table %s with: %s", tableName, filter)
+		table.WhereFilter = filter
+		schemaMetadata[tableName] = table
+	}
+}
+
+// writeExportedOrgs stores the ids of the source organizations, which are referenced by name in the export,
+// so the import can map them to other organizations
+func writeExportedOrgs(db *sql.DB, outputFolderAbs string) {
+	var content strings.Builder
+	for _, org := range sqlUtil.ExecuteQueryWithResults(db, "SELECT id, name FROM web_customer ORDER BY id;") {
+		content.WriteString(fmt.Sprintf("%v,%s\n", org[0].Value, dumper.AnonymizeValue("web_customer", "name", fmt.Sprintf("%s", org[1].Value))))
+	}
+	if err := os.WriteFile(outputFolderAbs+"/exportedOrgs.txt", []byte(content.String()), 0644); err != nil {
+		utils.Panic().Err(err).Msg("error creating exportedOrgs file")
+	}
+}
+
+// writeExportedGroups stores the ids of the source system groups with the id of their organization, since group
+// scoped pillars refer to the groups by name in the export, so the import can map them to other groups
+func writeExportedGroups(db *sql.DB, outputFolderAbs string) {
+	var content strings.Builder
+	for _, group := range sqlUtil.ExecuteQueryWithResults(db, "SELECT id, org_id, name FROM rhnservergroup ORDER BY id;") {
+		content.WriteString(fmt.Sprintf("%v,%v,%s\n", group[0].Value, group[1].Value, group[2].Value))
+	}
+	if err := os.WriteFile(outputFolderAbs+"/exportedGroups.txt", []byte(content.String()), 0644); err != nil {
+		utils.Panic().Err(err).Msg("error creating exportedGroups file")
 	}
 }
@@ -0,0 +1,35 @@
+package entityDumper
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/tests"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+func TestValidateWhereFiltersUnknownTables(t *testing.T) {
+	repo := tests.CreateDataRepository()
+	repo.ExpectWithRecords(schemareader.ReadTableNames, sqlmock.NewRows([]string{"table_name"}).AddRow("rhnpackage").AddRow("rhnchannel"))
+	options := DumperOptions{WhereFilters: map[string]string{"rhnpackage": "id > 1", "rhnpakage": "id > 1", "rhnchanel": "id = 1"}}
+
+	defer func() {
+		failure, ok := recover().(*utils.Failure)
+		if !ok || failure.ExitCode != utils.ExitConfigError || failure.Message != "Row filters set on unknown tables: rhnchanel, rhnpakage" {
+			t.Errorf("Expected a configuration error listing the unknown tables, got %v", failure)
+		}
+	}()
+	validateWhereFilters(repo.DB, options)
+	t.Errorf("Filters on unknown tables were accepted")
+}
+
+func TestValidateWhereFilters(t *testing.T) {
+	repo := tests.CreateDataRepository()
+	repo.ExpectWithRecords(schemareader.ReadTableNames, sqlmock.NewRows([]string{"table_name"}).AddRow("rhnpackage"))
+
+	validateWhereFilters(repo.DB, DumperOptions{WhereFilters: map[string]string{"rhnpackage": "id > 1"}})
+	if err := repo.ExpectationsWereMet(); err != nil {
+		t.Errorf("Tables were not read: %s", err)
+	}
+}
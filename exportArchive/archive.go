@@ -0,0 +1,311 @@
+package exportArchive
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// Extension of the export archives, a tar stream compressed with zstd
+const Extension = ".tar.zst"
+
+// StreamPath is the archive path selecting the standard input or output instead of a file
+const StreamPath = "-"
+
+// magic numbers starting the compressed streams
+var (
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	gzipMagic = []byte{0x1f, 0x8b}
+)
+
+// the SQL statements are the last entry, so files are in place before the statements referring to them run
+var sqlFileNames = []string{"sql_statements.sql.gz", "sql_statements.sql"}
+
+// folders with the files of the exported entities, in archive order
+var fileFolderNames = []string{"channels", "packages", "rhn", "repodata", "images"}
+
+// IsArchive checks if the path is an export archive instead of an export directory
+func IsArchive(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular() && strings.HasSuffix(path, Extension)
+}
+
+// entryRank orders the top level entries of the archive: the files describing the export first, then the
+// folders with the exported files, and the SQL statements last
+func entryRank(name string) int {
+	for i, sqlFileName := range sqlFileNames {
+		if name == sqlFileName {
+			return len(fileFolderNames) + 1 + i
+		}
+	}
+	for i, folderName := range fileFolderNames {
+		if name == folderName {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// sortedEntries returns the top level entries of the export directory in archive order
+func sortedEntries(exportDir string) []string {
+	entries, err := os.ReadDir(exportDir)
+	if err != nil {
+		utils.Fatal().Err(err).Msg("Error reading export directory")
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.SliceStable(names, func(i, j int) bool {
+		if entryRank(names[i]) != entryRank(names[j]) {
+			return entryRank(names[i]) < entryRank(names[j])
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// Write packs the export directory into the archive, compressing the tar stream with the zstd command. The archive
+// is encrypted with a key wrapped by the key management service key kmsKey, unless it is empty.
+func Write(exportDir string, archivePath string, kmsKey string) {
+	file, err := os.OpenFile(archivePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		utils.Fatal().Err(err).Msg("Error creating the archive")
+	}
+	defer file.Close()
+	if err := writeEncryptedTo(exportDir, file, kmsKey); err != nil {
+		utils.Fatal().Err(err).Msg("Error writing the archive")
+	}
+	log.Info().Msgf("Archive written: %s", archivePath)
+}
+
+// WriteTo streams the archive of the export directory to the writer
+func WriteTo(exportDir string, writer io.Writer) error {
+	cmd := exec.CommandContext(utils.Context(), "zstd", "-q", "-T0", "-c")
+	cmd.Stdout = writer
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("zstd is needed to create archives: %v", err)
+	}
+	tarErr := writeTar(stdin, exportDir)
+	stdin.Close()
+	if err := cmd.Wait(); err != nil {
+		return err
+	}
+	return tarErr
+}
+
+// WriteStream streams the archive of the export directory to the writer, like the standard output, encrypted
+// unless kmsKey is empty
+func WriteStream(exportDir string, writer io.Writer, kmsKey string) {
+	if err := writeEncryptedTo(exportDir, writer, kmsKey); err != nil {
+		utils.Fatal().Err(err).Msg("Error streaming the archive")
+	}
+	log.Info().Msg("Archive streamed")
+}
+
+// writeEncryptedTo streams the archive of the export directory to the writer, encrypted unless kmsKey is empty
+func writeEncryptedTo(exportDir string, writer io.Writer, kmsKey string) error {
+	if len(kmsKey) == 0 {
+		return WriteTo(exportDir, writer)
+	}
+	encryptingWriter, err := newEncryptingWriter(writer, kmsKey)
+	if err != nil {
+		return fmt.Errorf("error wrapping the archive key: %w", err)
+	}
+	if err := WriteTo(exportDir, encryptingWriter); err != nil {
+		return err
+	}
+	return encryptingWriter.Close()
+}
+
+func writeTar(writer io.Writer, exportDir string) error {
+	tarWriter := tar.NewWriter(writer)
+	for _, name := range sortedEntries(exportDir) {
+		err := filepath.Walk(filepath.Join(exportDir, name), func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && !info.Mode().IsRegular() {
+				return nil
+			}
+			relativePath, err := filepath.Rel(exportDir, path)
+			if err != nil {
+				return err
+			}
+			header, err := tar.FileInfoHeader(info, "")
+			if err != nil {
+				return err
+			}
+			header.Name = filepath.ToSlash(relativePath)
+			if info.IsDir() {
+				header.Name += "/"
+			}
+			if err := tarWriter.WriteHeader(header); err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+			file, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			_, err = io.Copy(tarWriter, file)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return tarWriter.Close()
+}
+
+// Extract unpacks the archive into the target folder while decompressing it. The files of the top level folders
+// in directFolders are written to the mapped folder instead, and the paths written there are returned.
+// Encrypted archives are decrypted with the key wrapped by the key management service key kmsKey, they are
+// refused when it is empty or the archive names another key.
+func Extract(archivePath string, targetFolder string, directFolders map[string]string, kmsKey string) []string {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		utils.Fatal().Err(err).Msg("Error reading the archive")
+	}
+	defer file.Close()
+	return extractFrom(file, targetFolder, directFolders, kmsKey)
+}
+
+// ExtractStream unpacks the archive read from the reader, like the standard input, as Extract does. Besides the
+// archives written by the export, the stream can be a tar compressed with gzip, or not compressed.
+func ExtractStream(reader io.Reader, targetFolder string, directFolders map[string]string, kmsKey string) []string {
+	return extractFrom(reader, targetFolder, directFolders, kmsKey)
+}
+
+func extractFrom(reader io.Reader, targetFolder string, directFolders map[string]string, kmsKey string) []string {
+	reader, err := decryptIfEncrypted(reader, kmsKey)
+	if err != nil {
+		utils.Fatal().Err(err).Int(utils.ExitCodeField, utils.ExitVerificationFailure).Msg("Error decrypting the archive")
+	}
+	buffered := bufio.NewReader(reader)
+	start, _ := buffered.Peek(len(zstdMagic))
+	switch {
+	case hasMagic(start, zstdMagic):
+		return extractZstd(buffered, targetFolder, directFolders)
+	case hasMagic(start, gzipMagic):
+		gzipReader, err := gzip.NewReader(buffered)
+		if err != nil {
+			utils.Fatal().Err(err).Int(utils.ExitCodeField, utils.ExitVerificationFailure).Msg("Error decompressing the archive")
+		}
+		reader = gzipReader
+	default:
+		reader = buffered
+	}
+	directFiles, err := extractTar(reader, targetFolder, directFolders)
+	if err != nil {
+		utils.Fatal().Err(err).Msg("Error extracting the archive")
+	}
+	// the encrypted and split archives are verified when read to the end
+	if _, err := io.Copy(io.Discard, reader); err != nil {
+		utils.Fatal().Err(err).Int(utils.ExitCodeField, utils.ExitVerificationFailure).Msg("Error reading the archive")
+	}
+	return directFiles
+}
+
+func hasMagic(start []byte, magic []byte) bool {
+	return len(start) >= len(magic) && string(start[:len(magic)]) == string(magic)
+}
+
+func extractZstd(reader io.Reader, targetFolder string, directFolders map[string]string) []string {
+	cmd := exec.CommandContext(utils.Context(), "zstd", "-q", "-d", "-c")
+	cmd.Stdin = reader
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		utils.Fatal().Err(err).Msg("Error reading the archive")
+	}
+	if err := cmd.Start(); err != nil {
+		utils.Fatal().Err(err).Msg("Error running zstd, which is needed to read archives")
+	}
+	directFiles, err := extractTar(stdout, targetFolder, directFolders)
+	if err != nil {
+		utils.Fatal().Err(err).Msg("Error extracting the archive")
+	}
+	if err := cmd.Wait(); err != nil {
+		utils.Fatal().Err(err).Int(utils.ExitCodeField, utils.ExitVerificationFailure).Msg("Error decompressing the archive")
+	}
+	return directFiles
+}
+
+func extractTar(reader io.Reader, targetFolder string, directFolders map[string]string) ([]string, error) {
+	directFiles := make([]string, 0)
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return directFiles, nil
+		}
+		if err != nil {
+			return directFiles, err
+		}
+		name := filepath.Clean(filepath.FromSlash(header.Name))
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			log.Warn().Msgf("Skipping archive entry %s outside of the export", header.Name)
+			continue
+		}
+		path := filepath.Join(targetFolder, name)
+		direct := false
+		topFolder := strings.SplitN(name, string(filepath.Separator), 2)
+		if folder, ok := directFolders[topFolder[0]]; ok {
+			if len(topFolder) == 1 {
+				continue
+			}
+			path = filepath.Join(folder, topFolder[1])
+			direct = true
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return directFiles, err
+			}
+			if direct {
+				directFiles = append(directFiles, path)
+			}
+		case tar.TypeReg:
+			if err := writeFile(path, tarReader, header.FileInfo().Mode().Perm()); err != nil {
+				return directFiles, err
+			}
+			if direct {
+				directFiles = append(directFiles, path)
+			}
+		default:
+			log.Warn().Msgf("Skipping archive entry %s which is not a file", header.Name)
+		}
+	}
+}
+
+func writeFile(path string, reader io.Reader, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(file, reader)
+	return err
+}
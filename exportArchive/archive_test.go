@@ -0,0 +1,94 @@
+package exportArchive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func createExport(t *testing.T, files map[string]string) string {
+	exportDir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(exportDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return exportDir
+}
+
+func TestSortedEntries(t *testing.T) {
+	exportDir := createExport(t, map[string]string{
+		"sql_statements.sql.gz":        "sql",
+		"packages/1/a/pkg.rpm":         "rpm",
+		"version.txt":                  "version = 4.3",
+		"exportedChannels.txt":         "channel",
+		"repodata/channel/primary.xml": "xml",
+	})
+	expected := []string{"exportedChannels.txt", "version.txt", "packages", "repodata", "sql_statements.sql.gz"}
+	if entries := sortedEntries(exportDir); !reflect.DeepEqual(entries, expected) {
+		t.Errorf("Expected %v, got %v", expected, entries)
+	}
+}
+
+func TestWriteAndExtractTar(t *testing.T) {
+	exportDir := createExport(t, map[string]string{
+		"sql_statements.sql.gz": "sql",
+		"version.txt":           "version = 4.3",
+		"packages/1/a/pkg.rpm":  "rpm",
+	})
+	var archive bytes.Buffer
+	if err := writeTar(&archive, exportDir); err != nil {
+		t.Fatal(err)
+	}
+
+	importDir := t.TempDir()
+	packagesDir := t.TempDir()
+	directPaths, err := extractTar(&archive, importDir, map[string]string{"packages": packagesDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content, err := os.ReadFile(filepath.Join(importDir, "version.txt")); err != nil || string(content) != "version = 4.3" {
+		t.Errorf("Unexpected version file %s: %v", content, err)
+	}
+	if content, err := os.ReadFile(filepath.Join(packagesDir, "1", "a", "pkg.rpm")); err != nil || string(content) != "rpm" {
+		t.Errorf("Unexpected package file %s: %v", content, err)
+	}
+	if _, err := os.Stat(filepath.Join(importDir, "packages")); !os.IsNotExist(err) {
+		t.Errorf("Package files extracted to the import directory")
+	}
+	if len(directPaths) != 3 || directPaths[2] != filepath.Join(packagesDir, "1", "a", "pkg.rpm") {
+		t.Errorf("Unexpected paths %v", directPaths)
+	}
+}
+
+func TestExtractUncompressedAndGzipStreams(t *testing.T) {
+	exportDir := createExport(t, map[string]string{
+		"sql_statements.sql": "sql",
+		"version.txt":        "version = 4.3",
+	})
+	var plain bytes.Buffer
+	if err := writeTar(&plain, exportDir); err != nil {
+		t.Fatal(err)
+	}
+	var compressed bytes.Buffer
+	gzipWriter := gzip.NewWriter(&compressed)
+	if _, err := gzipWriter.Write(plain.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	gzipWriter.Close()
+
+	for name, stream := range map[string][]byte{"tar": plain.Bytes(), "gzip": compressed.Bytes()} {
+		importDir := t.TempDir()
+		ExtractStream(bytes.NewReader(stream), importDir, map[string]string{}, "")
+		if content, err := os.ReadFile(filepath.Join(importDir, "sql_statements.sql")); err != nil || string(content) != "sql" {
+			t.Errorf("Unexpected statements extracted from the %s stream %s: %v", name, content, err)
+		}
+	}
+}
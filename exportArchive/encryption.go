@@ -0,0 +1,236 @@
+package exportArchive
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/uyuni-project/inter-server-sync/kms"
+)
+
+// magic starting the encrypted archives, followed by the length and the JSON of the envelope, and the chunks
+var encryptionMagic = []byte("ISSAES1\n")
+
+// size of the chunks of the archive encrypted with their own nonce, so the archive is streamed
+const encryptionChunkSize = 64 * 1024
+
+// envelope holds the key encrypting the archive, wrapped by the key management service
+type envelope struct {
+	Kms        string `json:"kms"`
+	WrappedKey []byte `json:"wrapped_key"`
+	Cipher     string `json:"cipher"`
+	// first bytes of the nonces of the chunks, followed by the chunk number
+	NoncePrefix []byte `json:"nonce_prefix"`
+}
+
+// encryptingWriter encrypts the stream with AES-256-GCM in chunks. The last chunk is marked, so truncated
+// archives are detected.
+type encryptingWriter struct {
+	writer io.Writer
+	aead   cipher.AEAD
+	prefix []byte
+	chunk  uint64
+	buffer []byte
+}
+
+// newEncryptingWriter writes the envelope with a new key wrapped by the key management service, and returns the
+// writer encrypting the stream with the key, which must be closed to write the last chunk
+func newEncryptingWriter(writer io.Writer, kmsKey string) (io.WriteCloser, error) {
+	wrapper, err := kms.NewKeyWrapper(kmsKey)
+	if err != nil {
+		return nil, err
+	}
+	key := make([]byte, 32)
+	prefix := make([]byte, 4)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, err
+	}
+	wrappedKey, err := wrapper.Wrap(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newArchiveAead(key)
+	if err != nil {
+		return nil, err
+	}
+	content, err := json.Marshal(envelope{Kms: kmsKey, WrappedKey: wrappedKey, Cipher: "AES-256-GCM", NoncePrefix: prefix})
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, len(encryptionMagic)+4)
+	copy(header, encryptionMagic)
+	binary.BigEndian.PutUint32(header[len(encryptionMagic):], uint32(len(content)))
+	if _, err := writer.Write(append(header, content...)); err != nil {
+		return nil, err
+	}
+	return &encryptingWriter{writer: writer, aead: aead, prefix: prefix, buffer: make([]byte, 0, encryptionChunkSize)}, nil
+}
+
+func newArchiveAead(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce returns the nonce of the chunk, and the additional data telling whether it is the last chunk
+func chunkNonce(prefix []byte, chunk uint64, last bool) ([]byte, []byte) {
+	nonce := make([]byte, len(prefix)+8)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint64(nonce[len(prefix):], chunk)
+	if last {
+		return nonce, []byte{1}
+	}
+	return nonce, []byte{0}
+}
+
+func (writer *encryptingWriter) writeChunk(last bool) error {
+	nonce, additionalData := chunkNonce(writer.prefix, writer.chunk, last)
+	sealed := writer.aead.Seal(nil, nonce, writer.buffer, additionalData)
+	record := make([]byte, 4, 4+len(sealed))
+	binary.BigEndian.PutUint32(record, uint32(len(sealed)))
+	if _, err := writer.writer.Write(append(record, sealed...)); err != nil {
+		return err
+	}
+	writer.chunk++
+	writer.buffer = writer.buffer[:0]
+	return nil
+}
+
+func (writer *encryptingWriter) Write(data []byte) (int, error) {
+	total := 0
+	for len(data) > 0 {
+		// full chunks are written once more data follows, the last chunk when the writer is closed
+		if len(writer.buffer) == encryptionChunkSize {
+			if err := writer.writeChunk(false); err != nil {
+				return total, err
+			}
+		}
+		n := copy(writer.buffer[len(writer.buffer):encryptionChunkSize], data)
+		writer.buffer = writer.buffer[:len(writer.buffer)+n]
+		data = data[n:]
+		total += n
+	}
+	return total, nil
+}
+
+func (writer *encryptingWriter) Close() error {
+	return writer.writeChunk(true)
+}
+
+// decryptingReader decrypts the chunks of an encrypted archive
+type decryptingReader struct {
+	reader io.Reader
+	aead   cipher.AEAD
+	prefix []byte
+	chunk  uint64
+	plain  []byte
+	last   bool
+}
+
+// decryptIfEncrypted returns the reader of the decrypted archive, unwrapping its key with the key management
+// service key kmsKey of the importer, or the reader itself when the archive is not encrypted. The envelope comes
+// with the archive: the key it names must be kmsKey, it never selects the service, like a command, by itself.
+func decryptIfEncrypted(reader io.Reader, kmsKey string) (io.Reader, error) {
+	buffered := bufio.NewReader(reader)
+	start, err := buffered.Peek(len(encryptionMagic))
+	if err != nil || string(start) != string(encryptionMagic) {
+		return buffered, nil
+	}
+	buffered.Discard(len(encryptionMagic))
+	var length uint32
+	if err := binary.Read(buffered, binary.BigEndian, &length); err != nil {
+		return nil, fmt.Errorf("envelope of the encrypted archive is truncated: %w", err)
+	}
+	content := make([]byte, length)
+	if _, err := io.ReadFull(buffered, content); err != nil {
+		return nil, fmt.Errorf("envelope of the encrypted archive is truncated: %w", err)
+	}
+	archiveEnvelope := envelope{}
+	if err := json.Unmarshal(content, &archiveEnvelope); err != nil {
+		return nil, fmt.Errorf("envelope of the encrypted archive is corrupted: %w", err)
+	}
+	if len(kmsKey) == 0 {
+		return nil, fmt.Errorf("the archive is encrypted with a key wrapped by %q, set the key management service key to decrypt it", archiveEnvelope.Kms)
+	}
+	if archiveEnvelope.Kms != kmsKey {
+		return nil, fmt.Errorf("the archive is encrypted with a key wrapped by %q, not by %s", archiveEnvelope.Kms, kmsKey)
+	}
+	wrapper, err := kms.NewKeyWrapper(kmsKey)
+	if err != nil {
+		return nil, err
+	}
+	key, err := wrapper.Unwrap(archiveEnvelope.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("error unwrapping the key of the archive with %s: %w", kmsKey, err)
+	}
+	aead, err := newArchiveAead(key)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptingReader{reader: buffered, aead: aead, prefix: archiveEnvelope.NoncePrefix}, nil
+}
+
+func (reader *decryptingReader) Read(data []byte) (int, error) {
+	for len(reader.plain) == 0 {
+		if reader.last {
+			// the underlying reader verifies the volumes when it is read to the end
+			trailing, err := io.Copy(io.Discard, reader.reader)
+			if err != nil {
+				return 0, err
+			}
+			if trailing > 0 {
+				return 0, errors.New("encrypted archive has data after its last chunk")
+			}
+			return 0, io.EOF
+		}
+		var length uint32
+		if err := binary.Read(reader.reader, binary.BigEndian, &length); err != nil {
+			if errors.Is(err, io.EOF) {
+				return 0, errors.New("encrypted archive is truncated")
+			}
+			return 0, err
+		}
+		if length > encryptionChunkSize+uint32(reader.aead.Overhead()) {
+			return 0, errors.New("encrypted archive is corrupted")
+		}
+		sealed := make([]byte, length)
+		if _, err := io.ReadFull(reader.reader, sealed); err != nil {
+			return 0, errors.New("encrypted archive is truncated")
+		}
+		plain, err := reader.openChunk(sealed)
+		if err != nil {
+			return 0, err
+		}
+		reader.plain = plain
+		reader.chunk++
+	}
+	n := copy(data, reader.plain)
+	reader.plain = reader.plain[n:]
+	return n, nil
+}
+
+// openChunk decrypts a chunk, which is the last one when it was sealed as such
+func (reader *decryptingReader) openChunk(sealed []byte) ([]byte, error) {
+	nonce, additionalData := chunkNonce(reader.prefix, reader.chunk, false)
+	if plain, err := reader.aead.Open(nil, nonce, sealed, additionalData); err == nil {
+		return plain, nil
+	}
+	nonce, additionalData = chunkNonce(reader.prefix, reader.chunk, true)
+	plain, err := reader.aead.Open(nil, nonce, sealed, additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("chunk %d of the encrypted archive cannot be decrypted, it was changed or the key is wrong", reader.chunk)
+	}
+	reader.last = true
+	return plain, nil
+}
@@ -0,0 +1,113 @@
+package exportArchive
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// identityKms returns the key of a key management service command returning the keys unchanged
+func identityKms(t *testing.T) string {
+	command := filepath.Join(t.TempDir(), "kms")
+	if err := os.WriteFile(command, []byte("#!/bin/sh\ncat\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return "exec:" + command
+}
+
+func encrypt(t *testing.T, kmsKey string, content []byte) []byte {
+	var archive bytes.Buffer
+	writer, err := newEncryptingWriter(&archive, kmsKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// several writes, crossing the chunks
+	for len(content) > 0 {
+		n := 1000
+		if n > len(content) {
+			n = len(content)
+		}
+		writer.Write(content[:n])
+		content = content[n:]
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return archive.Bytes()
+}
+
+func TestEncryptionRoundTrip(t *testing.T) {
+	kmsKey := identityKms(t)
+	for _, size := range []int{0, 10, encryptionChunkSize, 3*encryptionChunkSize + 17} {
+		content := make([]byte, size)
+		rand.Read(content)
+		encrypted := encrypt(t, kmsKey, content)
+
+		reader, err := decryptIfEncrypted(bytes.NewReader(encrypted), kmsKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decrypted, err := io.ReadAll(reader)
+		if err != nil || !bytes.Equal(decrypted, content) {
+			t.Errorf("Size %d: decrypted %d bytes, expected %d: %v", size, len(decrypted), size, err)
+		}
+		if !bytes.Contains(encrypted, []byte(kmsKey)) {
+			t.Errorf("The envelope must record the key management service key")
+		}
+	}
+}
+
+func TestEncryptionDetectsChanges(t *testing.T) {
+	kmsKey := identityKms(t)
+	content := make([]byte, 2*encryptionChunkSize+100)
+	encrypted := encrypt(t, kmsKey, content)
+
+	// the last chunk is missing
+	truncated := encrypted[:len(encrypted)-150]
+	tampered := append([]byte{}, encrypted...)
+	tampered[len(tampered)-20] ^= 1
+	for name, archive := range map[string][]byte{"truncated": truncated, "tampered": tampered} {
+		reader, err := decryptIfEncrypted(bytes.NewReader(archive), kmsKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.ReadAll(reader); err == nil {
+			t.Errorf("The %s archive was decrypted", name)
+		}
+	}
+}
+
+func TestDecryptPlainArchive(t *testing.T) {
+	reader, err := decryptIfEncrypted(strings.NewReader("plain tar stream"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := io.ReadAll(reader); string(content) != "plain tar stream" {
+		t.Errorf("Plain archives must be read unchanged, got %s", content)
+	}
+}
+
+func TestDecryptRefusesEnvelopeKms(t *testing.T) {
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "unwrapped")
+	command := filepath.Join(dir, "kms")
+	// the command of the envelope records if the importer runs it to unwrap the key
+	script := "#!/bin/sh\nif [ \"$1\" = unwrap ]; then touch " + marker + "; fi\ncat\n"
+	if err := os.WriteFile(command, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	encrypted := encrypt(t, "exec:"+command, []byte("content"))
+
+	for _, kmsKey := range []string{"", identityKms(t), "vault:transit/iss"} {
+		if _, err := decryptIfEncrypted(bytes.NewReader(encrypted), kmsKey); err == nil {
+			t.Errorf("The archive naming an unconfigured exec: key was decrypted with %q", kmsKey)
+		}
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Errorf("The command of the envelope must not be run")
+	}
+}
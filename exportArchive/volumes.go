@@ -0,0 +1,267 @@
+package exportArchive
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// VolumeDescriptor describes a volume of an archive split for media with a limited size. Each volume is
+// stored next to its descriptor, so any volume tells which others are needed to read the archive.
+type VolumeDescriptor struct {
+	Archive       string `json:"archive"`
+	Volume        int    `json:"volume"`
+	Volumes       int    `json:"volumes"`
+	Size          int64  `json:"size"`
+	Sha256        string `json:"sha256"`
+	ArchiveSize   int64  `json:"archive_size"`
+	ArchiveSha256 string `json:"archive_sha256"`
+	// digest of the checksums of the volume and of the archive, SHA-256 when empty
+	Digest string `json:"digest,omitempty"`
+}
+
+// ParseSize parses a size in bytes, with an optional K, M, G or T suffix
+func ParseSize(size string) (int64, bool) {
+	size = strings.ToUpper(strings.TrimSpace(size))
+	multiplier := int64(1)
+	for i, suffix := range []string{"K", "M", "G", "T"} {
+		if strings.HasSuffix(size, suffix) {
+			size = strings.TrimSuffix(size, suffix)
+			multiplier = int64(1) << (10 * uint(i+1))
+			break
+		}
+	}
+	value, err := strconv.ParseInt(size, 10, 64)
+	if err != nil || value <= 0 {
+		return 0, false
+	}
+	return value * multiplier, true
+}
+
+func volumePath(archivePath string, volume int) string {
+	return fmt.Sprintf("%s.%03d", archivePath, volume)
+}
+
+func descriptorPath(volumePath string) string {
+	return volumePath + ".json"
+}
+
+// volumeWriter writes a stream into volumes of at most maxSize bytes
+type volumeWriter struct {
+	archivePath string
+	maxSize     int64
+	file        *os.File
+	written     int64
+	hash        hash.Hash
+	archiveHash hash.Hash
+	archiveSize int64
+	volumes     []VolumeDescriptor
+}
+
+func (writer *volumeWriter) finishVolume() error {
+	if writer.file == nil {
+		return nil
+	}
+	err := writer.file.Close()
+	writer.file = nil
+	writer.volumes[len(writer.volumes)-1].Size = writer.written
+	writer.volumes[len(writer.volumes)-1].Sha256 = hex.EncodeToString(writer.hash.Sum(nil))
+	return err
+}
+
+func (writer *volumeWriter) nextVolume() error {
+	if err := writer.finishVolume(); err != nil {
+		return err
+	}
+	volume := len(writer.volumes) + 1
+	file, err := os.OpenFile(volumePath(writer.archivePath, volume), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	writer.file = file
+	writer.written = 0
+	writer.hash = utils.NewDigest()
+	writer.volumes = append(writer.volumes, VolumeDescriptor{Volume: volume, Digest: utils.Digest()})
+	return nil
+}
+
+func (writer *volumeWriter) Write(data []byte) (int, error) {
+	total := 0
+	for len(data) > 0 {
+		if writer.file == nil || writer.written == writer.maxSize {
+			if err := writer.nextVolume(); err != nil {
+				return total, err
+			}
+		}
+		chunk := data
+		if int64(len(chunk)) > writer.maxSize-writer.written {
+			chunk = chunk[:writer.maxSize-writer.written]
+		}
+		n, err := writer.file.Write(chunk)
+		writer.hash.Write(chunk[:n])
+		writer.archiveHash.Write(chunk[:n])
+		writer.written += int64(n)
+		writer.archiveSize += int64(n)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		data = data[n:]
+	}
+	return total, nil
+}
+
+// close finishes the last volume and writes the descriptors of all volumes
+func (writer *volumeWriter) close() error {
+	if err := writer.finishVolume(); err != nil {
+		return err
+	}
+	archiveName := filepath.Base(writer.archivePath)
+	for _, volume := range writer.volumes {
+		volume.Archive = archiveName
+		volume.Volumes = len(writer.volumes)
+		volume.ArchiveSize = writer.archiveSize
+		volume.ArchiveSha256 = hex.EncodeToString(writer.archiveHash.Sum(nil))
+		content, err := json.MarshalIndent(volume, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(descriptorPath(volumePath(writer.archivePath, volume.Volume)), append(content, '\n'), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteVolumes packs the export directory into an archive split into volumes of at most maxSize bytes,
+// named after the archive with the volume number as extension, and encrypted as Write does
+func WriteVolumes(exportDir string, archivePath string, maxSize int64, kmsKey string) {
+	writer := &volumeWriter{archivePath: archivePath, maxSize: maxSize, archiveHash: utils.NewDigest()}
+	if err := writeEncryptedTo(exportDir, writer, kmsKey); err != nil {
+		utils.Fatal().Err(err).Msg("Error writing the archive volumes")
+	}
+	if err := writer.close(); err != nil {
+		utils.Fatal().Err(err).Msg("Error writing the archive volumes")
+	}
+	log.Info().Msgf("Archive written in %d volumes: %s", len(writer.volumes), volumePath(archivePath, 1))
+}
+
+// IsVolume checks if the path is a volume of a split archive
+func IsVolume(path string) bool {
+	info, err := os.Stat(descriptorPath(path))
+	return err == nil && info.Mode().IsRegular()
+}
+
+func readDescriptor(path string) (VolumeDescriptor, error) {
+	descriptor := VolumeDescriptor{}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return descriptor, err
+	}
+	err = json.Unmarshal(content, &descriptor)
+	return descriptor, err
+}
+
+// ArchivePath returns the path of the archive the volume belongs to
+func ArchivePath(volumePath string) string {
+	return volumePath[:strings.LastIndex(volumePath, ".")]
+}
+
+// readVolumeSet returns the descriptors of all volumes of the archive, checking none is missing
+func readVolumeSet(anyVolumePath string) ([]VolumeDescriptor, error) {
+	first, err := readDescriptor(descriptorPath(anyVolumePath))
+	if err != nil {
+		return nil, err
+	}
+	archivePath := ArchivePath(anyVolumePath)
+	volumes := make([]VolumeDescriptor, 0, first.Volumes)
+	for volume := 1; volume <= first.Volumes; volume++ {
+		path := volumePath(archivePath, volume)
+		descriptor, err := readDescriptor(descriptorPath(path))
+		if err != nil {
+			return nil, fmt.Errorf("volume %d of %d is missing: %v", volume, first.Volumes, err)
+		}
+		if descriptor.Volume != volume || descriptor.ArchiveSha256 != first.ArchiveSha256 || descriptor.Digest != first.Digest {
+			return nil, fmt.Errorf("%s is not volume %d of archive %s", path, volume, first.Archive)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("volume %d of %d is missing: %v", volume, first.Volumes, err)
+		}
+		if info.Size() != descriptor.Size {
+			return nil, fmt.Errorf("volume %d has %d bytes instead of %d", volume, info.Size(), descriptor.Size)
+		}
+		volumes = append(volumes, descriptor)
+	}
+	return volumes, nil
+}
+
+// volumeReader reads the volumes of an archive one after the other, validating their checksums
+type volumeReader struct {
+	archivePath string
+	volumes     []VolumeDescriptor
+	current     int
+	file        *os.File
+	hash        hash.Hash
+	archiveHash hash.Hash
+}
+
+func (reader *volumeReader) Read(data []byte) (int, error) {
+	for {
+		if reader.file == nil {
+			if reader.current == len(reader.volumes) {
+				if hex.EncodeToString(reader.archiveHash.Sum(nil)) != reader.volumes[0].ArchiveSha256 {
+					return 0, fmt.Errorf("checksum of archive %s does not match", reader.volumes[0].Archive)
+				}
+				return 0, io.EOF
+			}
+			file, err := os.Open(volumePath(reader.archivePath, reader.current+1))
+			if err != nil {
+				return 0, err
+			}
+			reader.file = file
+			reader.hash, _ = utils.NewDigestOf(reader.volumes[0].Digest)
+		}
+		n, err := reader.file.Read(data)
+		reader.hash.Write(data[:n])
+		reader.archiveHash.Write(data[:n])
+		if err == io.EOF {
+			reader.file.Close()
+			reader.file = nil
+			volume := reader.volumes[reader.current]
+			if hex.EncodeToString(reader.hash.Sum(nil)) != volume.Sha256 {
+				return n, fmt.Errorf("checksum of volume %d does not match", volume.Volume)
+			}
+			log.Info().Msgf("Volume %d of %d read", volume.Volume, volume.Volumes)
+			reader.current++
+			err = nil
+		}
+		if n > 0 || err != nil {
+			return n, err
+		}
+	}
+}
+
+// ExtractVolumes unpacks the archive split in volumes as Extract does, from the path of any of its volumes.
+// The import stops if a volume is missing or damaged.
+func ExtractVolumes(anyVolumePath string, targetFolder string, directFolders map[string]string, kmsKey string) []string {
+	volumes, err := readVolumeSet(anyVolumePath)
+	if err != nil {
+		utils.Fatal().Err(err).Int(utils.ExitCodeField, utils.ExitVerificationFailure).Msg("Archive volumes are not complete")
+	}
+	archiveHash, err := utils.NewDigestOf(volumes[0].Digest)
+	if err != nil {
+		utils.Fatal().Err(err).Int(utils.ExitCodeField, utils.ExitVerificationFailure).Msg("Archive volumes cannot be verified")
+	}
+	reader := &volumeReader{archivePath: ArchivePath(anyVolumePath), volumes: volumes, archiveHash: archiveHash}
+	return extractFrom(reader, targetFolder, directFolders, kmsKey)
+}
@@ -0,0 +1,105 @@
+package exportArchive
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+func TestParseSize(t *testing.T) {
+	sizes := map[string]int64{"25G": 25 << 30, "700m": 700 << 20, "4096": 4096, "1T": 1 << 40}
+	for size, expected := range sizes {
+		if parsed, ok := ParseSize(size); !ok || parsed != expected {
+			t.Errorf("Expected %d for %s, got %d", expected, size, parsed)
+		}
+	}
+	for _, size := range []string{"", "G", "-1G", "25X"} {
+		if _, ok := ParseSize(size); ok {
+			t.Errorf("Invalid size %s accepted", size)
+		}
+	}
+}
+
+func writeTestVolumes(t *testing.T, data []byte, maxSize int64) string {
+	archivePath := filepath.Join(t.TempDir(), "export.tar.zst")
+	writer := &volumeWriter{archivePath: archivePath, maxSize: maxSize, archiveHash: sha256.New()}
+	if _, err := writer.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.close(); err != nil {
+		t.Fatal(err)
+	}
+	return archivePath
+}
+
+func TestVolumesRoundTrip(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 25)
+	archivePath := writeTestVolumes(t, data, 100)
+
+	if !IsVolume(volumePath(archivePath, 2)) || IsVolume(volumePath(archivePath, 4)) {
+		t.Errorf("Expected three volumes")
+	}
+	if ArchivePath(volumePath(archivePath, 2)) != archivePath {
+		t.Errorf("Unexpected archive path %s", ArchivePath(volumePath(archivePath, 2)))
+	}
+	volumes, err := readVolumeSet(volumePath(archivePath, 3))
+	if err != nil || len(volumes) != 3 || volumes[2].Size != 50 {
+		t.Fatalf("Unexpected volumes %v: %v", volumes, err)
+	}
+	reader := &volumeReader{archivePath: archivePath, volumes: volumes, archiveHash: sha256.New()}
+	read, err := io.ReadAll(reader)
+	if err != nil || !bytes.Equal(read, data) {
+		t.Errorf("Unexpected archive content %s: %v", read, err)
+	}
+}
+
+func TestVolumesValidation(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 25)
+	archivePath := writeTestVolumes(t, data, 100)
+	os.Remove(volumePath(archivePath, 2))
+	if _, err := readVolumeSet(volumePath(archivePath, 1)); err == nil {
+		t.Errorf("Missing volume not detected")
+	}
+
+	archivePath = writeTestVolumes(t, data, 100)
+	os.WriteFile(volumePath(archivePath, 2), bytes.Repeat([]byte("x"), 100), 0600)
+	volumes, err := readVolumeSet(volumePath(archivePath, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := &volumeReader{archivePath: archivePath, volumes: volumes, archiveHash: sha256.New()}
+	if _, err := io.ReadAll(reader); err == nil {
+		t.Errorf("Damaged volume not detected")
+	}
+}
+
+func TestVolumesDigest(t *testing.T) {
+	if err := utils.SetCrypto(utils.DigestSha512, true); err != nil {
+		t.Fatal(err)
+	}
+	defer utils.SetCrypto(utils.DigestSha256, false)
+	data := bytes.Repeat([]byte("0123456789"), 25)
+	archivePath := filepath.Join(t.TempDir(), "export.tar.zst")
+	writer := &volumeWriter{archivePath: archivePath, maxSize: 100, archiveHash: utils.NewDigest()}
+	if _, err := writer.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.close(); err != nil {
+		t.Fatal(err)
+	}
+	volumes, err := readVolumeSet(volumePath(archivePath, 1))
+	if err != nil || volumes[0].Digest != utils.DigestSha512 || len(volumes[0].Sha256) != 128 {
+		t.Fatalf("Expected SHA-512 checksums, got %v: %v", volumes, err)
+	}
+	archiveHash, _ := utils.NewDigestOf(volumes[0].Digest)
+	reader := &volumeReader{archivePath: archivePath, volumes: volumes, archiveHash: archiveHash}
+	read, err := io.ReadAll(reader)
+	if err != nil || !bytes.Equal(read, data) {
+		t.Errorf("Unexpected archive content %s: %v", read, err)
+	}
+}
@@ -0,0 +1,345 @@
+package exportMerger
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/dumper"
+	"github.com/uyuni-project/inter-server-sync/entityDumper"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// lists of imported entities, the lines of all exports are kept once
+var listFileNames = []string{"exportedChannels.txt", "exportedConfigs.txt", "exportedAutoinstall.txt",
+	"exportedOrgs.txt", "exportedGroups.txt", "placeholders.txt", "placeholderColumns.txt", "bootstrapRepositories.txt"}
+
+// folders with the files of the exported entities
+var fileFolderNames = []string{"packages", "rhn", "repodata", "images", "bootstrapRepositories"}
+
+// MergeExports combines several exports of servers with the same version into one export, applying their
+// statements in the given order
+func MergeExports(inputDirs []string, outputDir string) {
+	checkVersions(inputDirs)
+	entityDumper.ValidateExportFolder(outputDir)
+
+	if _, err := dumper.Copy(filepath.Join(inputDirs[0], "version.txt"), filepath.Join(outputDir, "version.txt")); err != nil {
+		utils.Fatal().Err(err).Msg("Error copying the version file")
+	}
+	mergeSqlStatements(inputDirs, filepath.Join(outputDir, "sql_statements.sql.gz"))
+	for _, fileName := range listFileNames {
+		mergeLists(inputDirs, outputDir, fileName)
+	}
+	mergeManifests(inputDirs, outputDir)
+	mergeVendorRows(inputDirs, outputDir)
+	mergeRepoSyncSchedules(inputDirs, outputDir)
+	for _, folderName := range fileFolderNames {
+		for _, inputDir := range inputDirs {
+			copyFolder(filepath.Join(inputDir, folderName), filepath.Join(outputDir, folderName))
+		}
+	}
+}
+
+// checkVersions stops the merge if the exports were produced by different products or versions
+func checkVersions(inputDirs []string) {
+	version, product := readVersion(inputDirs[0])
+	hubFQDN, _ := utils.ScannerFunc(filepath.Join(inputDirs[0], "version.txt"), "hub_fqdn")
+	for _, inputDir := range inputDirs[1:] {
+		otherVersion, otherProduct := readVersion(inputDir)
+		if otherVersion != version || otherProduct != product {
+			utils.Fatal().Msgf("Export %s is from %s %s, while %s is from %s %s",
+				inputDir, otherProduct, otherVersion, inputDirs[0], product, version)
+		}
+		if otherHubFQDN, _ := utils.ScannerFunc(filepath.Join(inputDir, "version.txt"), "hub_fqdn"); otherHubFQDN != hubFQDN {
+			log.Warn().Msgf("Exports are from different hubs, only %s is registered as hub on import", hubFQDN)
+		}
+	}
+}
+
+func readVersion(inputDir string) (string, string) {
+	versionFile := filepath.Join(inputDir, "version.txt")
+	if _, err := os.Stat(versionFile); err != nil {
+		utils.Fatal().Err(err).Msgf("%s is not an export", inputDir)
+	}
+	version, _ := utils.ScannerFunc(versionFile, "version")
+	product, _ := utils.ScannerFunc(versionFile, "product_name")
+	return version, product
+}
+
+func sqlFile(inputDir string) string {
+	sqlFile := filepath.Join(inputDir, "sql_statements.sql.gz")
+	if _, err := os.Stat(sqlFile); err != nil {
+		sqlFile = filepath.Join(inputDir, "sql_statements.sql")
+	}
+	return sqlFile
+}
+
+// mergeSqlStatements writes the statements of all exports in a single transaction
+func mergeSqlStatements(inputDirs []string, outputFile string) {
+	file, err := os.Create(outputFile)
+	if err != nil {
+		utils.Panic().Err(err).Msg("error creating sql file")
+	}
+	defer file.Close()
+	gzipFile := gzip.NewWriter(file)
+	defer gzipFile.Close()
+	bufferWriter := bufio.NewWriterSize(gzipFile, 32768)
+	defer bufferWriter.Flush()
+
+	merger := newStatementMerger(bufferWriter)
+	bufferWriter.WriteString("BEGIN;\n")
+	for _, inputDir := range inputDirs {
+		log.Info().Msgf("Merging statements of %s", inputDir)
+		script := dumper.OpenSqlScript(sqlFile(inputDir))
+		script.ForEachLine(merger.addLine)
+		script.Close()
+		merger.flush()
+	}
+	bufferWriter.WriteString("COMMIT;\n")
+	merger.writeAnalyzeStatements()
+	log.Info().Msgf("%d statements merged, %d duplicated statements removed", merger.written, merger.duplicates)
+}
+
+// statementMerger removes the statements already written for a table, unless rows of the table were deleted since
+type statementMerger struct {
+	writer     *bufio.Writer
+	table      string
+	statement  strings.Builder
+	written    int
+	duplicates int
+	// hashes of the statements written for each table
+	seen map[string]map[string]bool
+	// tables analyzed after the import of any export, in order
+	analyzed []string
+}
+
+func newStatementMerger(writer *bufio.Writer) *statementMerger {
+	return &statementMerger{writer: writer, seen: make(map[string]map[string]bool)}
+}
+
+// writeAnalyzeStatements analyzes the tables once, after the merged transaction
+func (merger *statementMerger) writeAnalyzeStatements() {
+	written := make(map[string]bool)
+	for _, tableName := range merger.analyzed {
+		if !written[tableName] {
+			written[tableName] = true
+			merger.writer.WriteString(dumper.FormatAnalyzeStatement(tableName) + "\n")
+		}
+	}
+}
+
+func (merger *statementMerger) addLine(line string) {
+	trimmedLine := strings.TrimSpace(line)
+	if tableName, ok := dumper.StatementTable(line); ok {
+		merger.flush()
+		merger.table = tableName
+		if dumper.IsDeleteStatement(line) {
+			// rows written before can be deleted, so they must be written again
+			delete(merger.seen, tableName)
+			merger.table = ""
+		}
+	} else if tableName, ok := dumper.AnalyzedTable(line); ok {
+		merger.flush()
+		merger.analyzed = append(merger.analyzed, tableName)
+		return
+	} else if trimmedLine == "BEGIN;" || trimmedLine == "COMMIT;" {
+		merger.flush()
+		return
+	} else if strings.HasPrefix(trimmedLine, "--") && merger.statementEnded() {
+		merger.flush()
+		merger.writer.WriteString(line)
+		return
+	}
+	merger.statement.WriteString(line)
+}
+
+// statementEnded checks if the pending statement is complete, so the next comment line is not part of a value
+func (merger *statementMerger) statementEnded() bool {
+	return merger.statement.Len() == 0 || strings.HasSuffix(strings.TrimSpace(merger.statement.String()), ";")
+}
+
+// flush writes the pending statement, if it was not written before
+func (merger *statementMerger) flush() {
+	if merger.statement.Len() == 0 {
+		return
+	}
+	statement := merger.statement.String()
+	merger.statement.Reset()
+	if len(merger.table) > 0 {
+		digest := utils.NewDigest()
+		digest.Write([]byte(strings.TrimSpace(statement)))
+		hash := string(digest.Sum(nil))
+		tableStatements, ok := merger.seen[merger.table]
+		if !ok {
+			tableStatements = make(map[string]bool)
+			merger.seen[merger.table] = tableStatements
+		}
+		if tableStatements[hash] {
+			merger.duplicates++
+			return
+		}
+		tableStatements[hash] = true
+	}
+	merger.writer.WriteString(statement)
+	merger.written++
+}
+
+// mergeLists writes the lines of a list file of all exports, without duplicates
+func mergeLists(inputDirs []string, outputDir string, fileName string) {
+	lines := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, inputDir := range inputDirs {
+		listFile := filepath.Join(inputDir, fileName)
+		if _, err := os.Stat(listFile); err != nil {
+			continue
+		}
+		for _, line := range utils.ReadFileByLine(listFile) {
+			if len(strings.TrimSpace(line)) > 0 && !seen[line] {
+				seen[line] = true
+				lines = append(lines, line)
+			}
+		}
+	}
+	if len(lines) == 0 {
+		return
+	}
+	if fileName == "exportedOrgs.txt" {
+		checkOrgIds(lines)
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, fileName), []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		utils.Panic().Err(err).Msgf("error creating %s file", fileName)
+	}
+}
+
+// checkOrgIds warns about organizations with the same id on different hubs, which cannot be mapped on import
+func checkOrgIds(lines []string) {
+	names := make(map[string]string)
+	for _, line := range lines {
+		separator := strings.Index(line, ",")
+		if separator < 0 {
+			continue
+		}
+		id, name := line[:separator], line[separator+1:]
+		if otherName, ok := names[id]; ok && otherName != name {
+			log.Warn().Msgf("Organizations %s and %s have the same id %s, they cannot be mapped with --org-map", otherName, name, id)
+		}
+		names[id] = name
+	}
+}
+
+// mergeVendorRows lists the vendor rows referenced by any of the exports, verified on import
+func mergeVendorRows(inputDirs []string, outputDir string) {
+	var merged dumper.VendorRows
+	for _, inputDir := range inputDirs {
+		vendorRows, err := dumper.ReadVendorRows(inputDir)
+		if err != nil {
+			utils.Fatal().Err(err).Msgf("Error reading the vendor rows of %s", inputDir)
+		}
+		if vendorRows == nil {
+			continue
+		}
+		if merged == nil {
+			merged = make(dumper.VendorRows)
+		}
+		merged.Merge(vendorRows)
+	}
+	if merged != nil {
+		dumper.WriteVendorRows(outputDir, merged)
+	}
+}
+
+// mergeRepoSyncSchedules lists the schedules of the channels of all exports, the first export of a channel wins
+func mergeRepoSyncSchedules(inputDirs []string, outputDir string) {
+	merged := make([]entityDumper.RepoSyncSchedule, 0)
+	channels := make(map[string]bool)
+	for _, inputDir := range inputDirs {
+		for _, schedule := range entityDumper.ReadRepoSyncSchedules(inputDir) {
+			if !channels[schedule.Channel] {
+				channels[schedule.Channel] = true
+				merged = append(merged, schedule)
+			}
+		}
+	}
+	entityDumper.WriteRepoSyncSchedules(outputDir, merged)
+}
+
+// mergeManifests describes the merged export with the traversal limits of all exports
+func mergeManifests(inputDirs []string, outputDir string) {
+	merged := entityDumper.ExportManifest{Pruned: make(map[string]string)}
+	pruneTables := make(map[string]bool)
+	referenceRules := make(map[string]bool)
+	found := false
+	for i, inputDir := range inputDirs {
+		content, err := os.ReadFile(filepath.Join(inputDir, "manifest.json"))
+		if err != nil {
+			continue
+		}
+		manifest := entityDumper.ExportManifest{}
+		if err := json.Unmarshal(content, &manifest); err != nil {
+			log.Warn().Err(err).Msgf("Manifest of %s cannot be read", inputDir)
+			continue
+		}
+		found = true
+		// no limit in any export means no limit in the merged one
+		if i == 0 || manifest.MaxDepth == 0 || (merged.MaxDepth != 0 && manifest.MaxDepth > merged.MaxDepth) {
+			merged.MaxDepth = manifest.MaxDepth
+		}
+		for _, table := range manifest.PruneTables {
+			if !pruneTables[table] {
+				pruneTables[table] = true
+				merged.PruneTables = append(merged.PruneTables, table)
+			}
+		}
+		for _, rule := range manifest.ReferenceRules {
+			if !referenceRules[rule.String()] {
+				referenceRules[rule.String()] = true
+				merged.ReferenceRules = append(merged.ReferenceRules, rule)
+			}
+		}
+		for table, reason := range manifest.Pruned {
+			merged.Pruned[table] = reason
+		}
+	}
+	if !found {
+		return
+	}
+	content, err := json.MarshalIndent(merged, "", "  ")
+	if err != nil {
+		utils.Panic().Err(err).Msg("error encoding manifest")
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, "manifest.json"), append(content, '\n'), 0644); err != nil {
+		utils.Panic().Err(err).Msg("error writing manifest file")
+	}
+}
+
+// copyFolder copies the files of the folder which are not in the target folder yet
+func copyFolder(source string, target string) {
+	if err := utils.FolderExists(source); err != nil {
+		return
+	}
+	err := filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		relativePath, err := filepath.Rel(source, path)
+		if err != nil {
+			return err
+		}
+		targetPath := filepath.Join(target, relativePath)
+		if targetInfo, err := os.Stat(targetPath); err == nil {
+			if targetInfo.Size() != info.Size() {
+				log.Warn().Msgf("File %s differs between exports, keeping the first one", relativePath)
+			}
+			return nil
+		}
+		_, err = dumper.Copy(path, targetPath)
+		return err
+	})
+	if err != nil {
+		utils.Fatal().Err(err).Msgf("Error copying files of %s", source)
+	}
+}
@@ -0,0 +1,75 @@
+package exportMerger
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func mergeScripts(scripts ...string) string {
+	var output strings.Builder
+	writer := bufio.NewWriter(&output)
+	merger := newStatementMerger(writer)
+	for _, script := range scripts {
+		for _, line := range strings.SplitAfter(script, "\n") {
+			if len(line) > 0 {
+				merger.addLine(line)
+			}
+		}
+		merger.flush()
+	}
+	writer.Flush()
+	return output.String()
+}
+
+func TestMergeStatementsRemovesDuplicates(t *testing.T) {
+	first := "BEGIN;\n-- channels\nINSERT INTO rhnchannel (id, label) VALUES (1, 'base');\n" +
+		"INSERT INTO rhnchannelcomps (id, relative_filename) VALUES (1,\n'comps.xml');\nCOMMIT;\n"
+	second := "BEGIN;\nINSERT INTO rhnchannel (id, label) VALUES (1, 'base');\n" +
+		"INSERT INTO rhnchannelcomps (id, relative_filename) VALUES (1,\n'comps.xml');\n" +
+		"INSERT INTO rhnchannel (id, label) VALUES (2, 'child');\nCOMMIT;\n"
+
+	merged := mergeScripts(first, second)
+	expected := "-- channels\nINSERT INTO rhnchannel (id, label) VALUES (1, 'base');\n" +
+		"INSERT INTO rhnchannelcomps (id, relative_filename) VALUES (1,\n'comps.xml');\n" +
+		"INSERT INTO rhnchannel (id, label) VALUES (2, 'child');\n"
+	if merged != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, merged)
+	}
+}
+
+func TestMergeStatementsAfterDelete(t *testing.T) {
+	first := "INSERT INTO rhnchannelpackage (channel_id, package_id) VALUES (1, 1);\n"
+	second := "DELETE FROM rhnchannelpackage WHERE channel_id = 1;\n" +
+		"INSERT INTO rhnchannelpackage (channel_id, package_id) VALUES (1, 1);\n"
+
+	merged := mergeScripts(first, second)
+	if strings.Count(merged, "INSERT INTO rhnchannelpackage") != 2 {
+		t.Errorf("Rows deleted by a later export must be inserted again:\n%s", merged)
+	}
+}
+
+func TestMergeAnalyzeStatements(t *testing.T) {
+	first := "BEGIN;\nINSERT INTO rhnchannel (id, label) VALUES (1, 'base');\nCOMMIT;\nANALYZE rhnchannel;\n"
+	second := "BEGIN;\nINSERT INTO rhnerrata (id) VALUES (1);\nCOMMIT;\nANALYZE rhnchannel;\nANALYZE rhnerrata;\n"
+
+	var output strings.Builder
+	writer := bufio.NewWriter(&output)
+	merger := newStatementMerger(writer)
+	for _, script := range []string{first, second} {
+		for _, line := range strings.SplitAfter(script, "\n") {
+			if len(line) > 0 {
+				merger.addLine(line)
+			}
+		}
+		merger.flush()
+	}
+	merger.writeAnalyzeStatements()
+	writer.Flush()
+
+	expected := "INSERT INTO rhnchannel (id, label) VALUES (1, 'base');\nINSERT INTO rhnerrata (id) VALUES (1);\n" +
+		"ANALYZE rhnchannel;\nANALYZE rhnerrata;\n"
+	if output.String() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, output.String())
+	}
+}
@@ -0,0 +1,345 @@
+package jobQueue
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// State is the state of a job in the queue
+type State string
+
+const (
+	Queued   State = "queued"
+	Running  State = "running"
+	Done     State = "done"
+	Failed   State = "failed"
+	Canceled State = "canceled"
+)
+
+// number of finished jobs kept in the list of the jobs
+const finishedJobsKept = 100
+
+// time a job queued before a restart keeps its place until its peripheral requests it again
+const reclaimTimeout = time.Hour
+
+// ErrCanceled is returned for the jobs canceled through the queue
+var ErrCanceled = errors.New("job canceled")
+
+// ErrUnknownJob is returned when canceling a job which is not queued or running
+var ErrUnknownJob = errors.New("no queued or running job with this id")
+
+// Job is a requested export, run once the limits allow it. Jobs of the same target with the same request
+// are the same job, so a peripheral requesting its export again keeps its place in the queue.
+type Job struct {
+	Id       string          `json:"id"`
+	Target   string          `json:"target"`
+	Request  json.RawMessage `json:"request"`
+	State    State           `json:"state"`
+	Created  time.Time       `json:"created"`
+	Started  *time.Time      `json:"started,omitempty"`
+	Finished *time.Time      `json:"finished,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// Limits are the jobs running at the same time, in total and by target. 0 is unlimited.
+type Limits struct {
+	MaxRunning   int
+	MaxPerTarget int
+}
+
+// attachment is the waiting or running request of a job
+type attachment struct {
+	ready  chan struct{}
+	cancel context.CancelFunc
+	// the job was canceled through the queue, and not by its request
+	canceled bool
+}
+
+// Queue orders the jobs and runs them within the limits. The jobs are persisted in its file, if any, so the
+// jobs queued before a restart keep their place once requested again. Until then, they don't hold back the
+// other jobs.
+type Queue struct {
+	mutex    sync.Mutex
+	path     string
+	limits   Limits
+	jobs     []*Job
+	attached map[string]*attachment
+	// jobs of the previous run are dropped when not requested again until then
+	reclaimDeadline time.Time
+}
+
+// Open returns the queue of the file, or an in-memory queue without file. Jobs running when the previous run
+// stopped are queued again.
+func Open(path string, limits Limits) *Queue {
+	queue := &Queue{path: path, limits: limits, jobs: make([]*Job, 0), attached: make(map[string]*attachment),
+		reclaimDeadline: time.Now().Add(reclaimTimeout)}
+	if len(path) == 0 {
+		return queue
+	}
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return queue
+	}
+	if err == nil {
+		err = json.Unmarshal(content, &queue.jobs)
+	}
+	if err != nil {
+		log.Warn().Err(err).Msgf("Job queue %s cannot be read, the queue is started empty", path)
+		queue.jobs = make([]*Job, 0)
+		return queue
+	}
+	for _, job := range queue.jobs {
+		if job.State == Running {
+			job.State = Queued
+			job.Started = nil
+		}
+	}
+	return queue
+}
+
+// List returns a copy of the jobs, the queued and running ones in their order first
+func (queue *Queue) List() []Job {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	result := make([]Job, 0, len(queue.jobs))
+	for _, job := range queue.jobs {
+		result = append(result, *job)
+	}
+	return result
+}
+
+// Cancel cancels a queued or a running job
+func (queue *Queue) Cancel(id string) error {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	job := queue.find(id)
+	if job == nil || (job.State != Queued && job.State != Running) {
+		return ErrUnknownJob
+	}
+	if attached, ok := queue.attached[id]; ok {
+		// the request finishes the job
+		attached.canceled = true
+		attached.cancel()
+		return nil
+	}
+	queue.finish(job, Canceled, ErrCanceled)
+	return nil
+}
+
+// Run queues the request of the target, or takes over the queued job with the same request, then runs it
+// once the limits allow it. When the context is done before the job finishes, like when the server stops,
+// the job is queued again for the next request.
+func (queue *Queue) Run(ctx context.Context, target string, request []byte, run func(ctx context.Context, job Job) error) (Job, error) {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	attached := &attachment{ready: make(chan struct{}), cancel: cancel}
+	job := queue.attach(target, request, attached)
+
+	select {
+	case <-attached.ready:
+	case <-runCtx.Done():
+		return queue.detach(job, attached, runCtx.Err(), ctx.Err() != nil)
+	}
+	log.Info().Msgf("Running job %s of %s", job.Id, target)
+	err := run(runCtx, queue.copy(job))
+	if err == nil && runCtx.Err() != nil {
+		err = runCtx.Err()
+	}
+	return queue.detach(job, attached, err, ctx.Err() != nil)
+}
+
+// attach adds the job of the request, unless it is queued already
+func (queue *Queue) attach(target string, request []byte, attached *attachment) *Job {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	queue.dropExpired(time.Now())
+	request = compactRequest(request)
+	var job *Job
+	for _, candidate := range queue.jobs {
+		if candidate.State == Queued && candidate.Target == target && string(candidate.Request) == string(request) &&
+			queue.attached[candidate.Id] == nil {
+			job = candidate
+			log.Info().Msgf("Job %s of %s requested again, keeping its place in the queue", job.Id, target)
+			break
+		}
+	}
+	if job == nil {
+		job = &Job{Id: newJobId(), Target: target, Request: request, State: Queued, Created: time.Now()}
+		queue.insert(job)
+		log.Info().Msgf("Job %s of %s queued", job.Id, target)
+	}
+	queue.attached[job.Id] = attached
+	queue.schedule()
+	return job
+}
+
+// detach finishes the job with the result of its request, or queues it again when its request was interrupted,
+// starting the next jobs
+func (queue *Queue) detach(job *Job, attached *attachment, err error, interrupted bool) (Job, error) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	delete(queue.attached, job.Id)
+	switch {
+	case attached.canceled:
+		err = ErrCanceled
+		queue.finish(job, Canceled, err)
+	case err == nil:
+		queue.finish(job, Done, nil)
+	case interrupted:
+		log.Info().Msgf("Job %s of %s interrupted, it is queued again", job.Id, job.Target)
+		job.State = Queued
+		job.Started = nil
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		queue.finish(job, Canceled, err)
+	default:
+		queue.finish(job, Failed, err)
+	}
+	queue.schedule()
+	return *job, err
+}
+
+// schedule starts the queued jobs of the requests waiting, in their order, within the limits
+func (queue *Queue) schedule() {
+	running := 0
+	runningByTarget := make(map[string]int)
+	for _, job := range queue.jobs {
+		if job.State == Running {
+			running++
+			runningByTarget[job.Target]++
+		}
+	}
+	for _, job := range queue.jobs {
+		attached, ok := queue.attached[job.Id]
+		if job.State != Queued || !ok {
+			continue
+		}
+		if queue.limits.MaxRunning > 0 && running >= queue.limits.MaxRunning {
+			break
+		}
+		if queue.limits.MaxPerTarget > 0 && runningByTarget[job.Target] >= queue.limits.MaxPerTarget {
+			continue
+		}
+		now := time.Now()
+		job.State = Running
+		job.Started = &now
+		running++
+		runningByTarget[job.Target]++
+		close(attached.ready)
+	}
+	queue.save()
+}
+
+// finish records the end of the job, keeping the last finished jobs after the queued and running ones
+func (queue *Queue) finish(job *Job, state State, err error) {
+	now := time.Now()
+	job.State = state
+	job.Finished = &now
+	if err != nil {
+		job.Error = err.Error()
+		log.Warn().Msgf("Job %s of %s %s: %s", job.Id, job.Target, state, job.Error)
+	} else {
+		log.Info().Msgf("Job %s of %s %s", job.Id, job.Target, state)
+	}
+	jobs := make([]*Job, 0, len(queue.jobs))
+	finished := make([]*Job, 0)
+	for _, other := range queue.jobs {
+		if other == job {
+			continue
+		}
+		if other.State == Queued || other.State == Running {
+			jobs = append(jobs, other)
+		} else {
+			finished = append(finished, other)
+		}
+	}
+	finished = append([]*Job{job}, finished...)
+	if len(finished) > finishedJobsKept {
+		finished = finished[:finishedJobsKept]
+	}
+	queue.jobs = append(jobs, finished...)
+	queue.save()
+}
+
+// dropExpired cancels the jobs of the previous run which were not requested again in time
+func (queue *Queue) dropExpired(now time.Time) {
+	if now.Before(queue.reclaimDeadline) {
+		return
+	}
+	for _, job := range append([]*Job{}, queue.jobs...) {
+		if job.State == Queued && queue.attached[job.Id] == nil {
+			queue.finish(job, Canceled, errors.New("not requested again after the restart"))
+		}
+	}
+}
+
+// insert adds the job after the queued and running jobs
+func (queue *Queue) insert(job *Job) {
+	position := 0
+	for position < len(queue.jobs) && (queue.jobs[position].State == Queued || queue.jobs[position].State == Running) {
+		position++
+	}
+	queue.jobs = append(queue.jobs, nil)
+	copy(queue.jobs[position+1:], queue.jobs[position:])
+	queue.jobs[position] = job
+}
+
+func (queue *Queue) find(id string) *Job {
+	for _, job := range queue.jobs {
+		if job.Id == id {
+			return job
+		}
+	}
+	return nil
+}
+
+func (queue *Queue) copy(job *Job) Job {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	return *job
+}
+
+// save writes the jobs, replacing the previous file only once it is complete. The queue keeps running
+// without file when it cannot be written.
+func (queue *Queue) save() {
+	if len(queue.path) == 0 {
+		return
+	}
+	content, err := json.Marshal(queue.jobs)
+	if err == nil {
+		temporaryPath := queue.path + ".tmp"
+		if err = os.WriteFile(temporaryPath, content, 0600); err == nil {
+			err = os.Rename(temporaryPath, queue.path)
+		}
+	}
+	if err != nil {
+		log.Error().Err(err).Msgf("Error saving the job queue to %s", queue.path)
+	}
+}
+
+// compactRequest returns the request as it is saved, so the requests of the previous run compare equal
+func compactRequest(request []byte) json.RawMessage {
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, request); err != nil {
+		// saved as JSON string
+		quoted, _ := json.Marshal(string(request))
+		return quoted
+	}
+	return compacted.Bytes()
+}
+
+func newJobId() string {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,184 @@
+package jobQueue
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// startJob runs a job of the queue until its release channel is closed, signaling when it started
+func startJob(queue *Queue, target string, request string) (started chan Job, release chan error, result chan error) {
+	started = make(chan Job, 1)
+	release = make(chan error)
+	result = make(chan error, 1)
+	go func() {
+		_, err := queue.Run(context.Background(), target, []byte(request), func(ctx context.Context, job Job) error {
+			started <- job
+			select {
+			case err := <-release:
+				return err
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		result <- err
+	}()
+	return started, release, result
+}
+
+func waitStarted(t *testing.T, started chan Job) Job {
+	select {
+	case job := <-started:
+		return job
+	case <-time.After(5 * time.Second):
+		t.Fatal("job did not start")
+	}
+	return Job{}
+}
+
+func assertNotStarted(t *testing.T, started chan Job) {
+	select {
+	case job := <-started:
+		t.Fatalf("job %s should wait for the limits", job.Id)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func waitQueued(t *testing.T, queue *Queue, count int) {
+	for i := 0; i < 500; i++ {
+		queued := 0
+		for _, job := range queue.List() {
+			if job.State == Queued {
+				queued++
+			}
+		}
+		if queued == count {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expected %d queued jobs, got %v", count, queue.List())
+}
+
+func TestQueueLimitsByTarget(t *testing.T) {
+	queue := Open("", Limits{MaxRunning: 2, MaxPerTarget: 1})
+	firstStarted, firstRelease, firstResult := startJob(queue, "peripheral-1", `{"channels":["a"]}`)
+	waitStarted(t, firstStarted)
+	secondStarted, secondRelease, secondResult := startJob(queue, "peripheral-1", `{"channels":["b"]}`)
+	waitQueued(t, queue, 1)
+	otherStarted, otherRelease, otherResult := startJob(queue, "peripheral-2", `{"channels":["a"]}`)
+
+	waitStarted(t, otherStarted)
+	assertNotStarted(t, secondStarted)
+	firstRelease <- nil
+	if err := <-firstResult; err != nil {
+		t.Fatal(err)
+	}
+	waitStarted(t, secondStarted)
+	secondRelease <- errors.New("export failed")
+	otherRelease <- nil
+
+	if err := <-secondResult; err == nil {
+		t.Errorf("the error of the job should be returned")
+	}
+	if err := <-otherResult; err != nil {
+		t.Fatal(err)
+	}
+	states := make(map[string]State)
+	for _, job := range queue.List() {
+		states[job.Target+string(job.Request)] = job.State
+	}
+	expected := map[string]State{`peripheral-1{"channels":["a"]}`: Done, `peripheral-1{"channels":["b"]}`: Failed,
+		`peripheral-2{"channels":["a"]}`: Done}
+	if !reflect.DeepEqual(states, expected) {
+		t.Errorf("expected the jobs %v, got %v", expected, states)
+	}
+}
+
+func TestQueueCancel(t *testing.T) {
+	queue := Open("", Limits{MaxRunning: 1})
+	runningStarted, _, runningResult := startJob(queue, "peripheral-1", "{}")
+	running := waitStarted(t, runningStarted)
+	queuedStarted, _, queuedResult := startJob(queue, "peripheral-2", "{}")
+	waitQueued(t, queue, 1)
+	queued := queue.List()[1]
+
+	if err := queue.Cancel(queued.Id); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-queuedResult; err != ErrCanceled {
+		t.Errorf("expected the queued job to be canceled, got %v", err)
+	}
+	if err := queue.Cancel(running.Id); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-runningResult; err != ErrCanceled {
+		t.Errorf("expected the running job to be canceled, got %v", err)
+	}
+
+	assertNotStarted(t, queuedStarted)
+	if err := queue.Cancel(running.Id); err != ErrUnknownJob {
+		t.Errorf("finished jobs cannot be canceled, got %v", err)
+	}
+	for _, job := range queue.List() {
+		if job.State != Canceled {
+			t.Errorf("expected job %s to be canceled, got %s", job.Id, job.State)
+		}
+	}
+}
+
+func TestQueuePersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.json")
+	queue := Open(path, Limits{MaxRunning: 1})
+	runningStarted, _, _ := startJob(queue, "peripheral-1", `{"channels":["a"]}`)
+	running := waitStarted(t, runningStarted)
+	startJob(queue, "peripheral-2", `{"channels":["b"]}`)
+	waitQueued(t, queue, 1)
+
+	restarted := Open(path, Limits{MaxRunning: 1})
+
+	jobs := restarted.List()
+	if len(jobs) != 2 || jobs[0].Id != running.Id || jobs[0].State != Queued || jobs[1].State != Queued {
+		t.Fatalf("expected the jobs to be queued again in their order, got %v", jobs)
+	}
+	started, release, result := startJob(restarted, "peripheral-2", `{"channels": ["b"]}`)
+	if job := waitStarted(t, started); job.Id != jobs[1].Id {
+		t.Errorf("expected the job of the previous run to be taken over, got %s", job.Id)
+	}
+	reclaimed, _, _ := startJob(restarted, "peripheral-1", `{"channels":["a"]}`)
+	assertNotStarted(t, reclaimed)
+	release <- nil
+	if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+	if job := waitStarted(t, reclaimed); job.Id != running.Id {
+		t.Errorf("expected the job running before the restart to be taken over, got %s", job.Id)
+	}
+}
+
+func TestQueueInterruptedJobs(t *testing.T) {
+	queue := Open("", Limits{MaxRunning: 1})
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() {
+		_, err := queue.Run(ctx, "peripheral-1", []byte("{}"), func(ctx context.Context, job Job) error {
+			cancel()
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		result <- err
+	}()
+
+	if err := <-result; err != context.Canceled {
+		t.Errorf("expected the interruption to be returned, got %v", err)
+	}
+	if jobs := queue.List(); len(jobs) != 1 || jobs[0].State != Queued || jobs[0].Started != nil {
+		t.Errorf("expected the interrupted job to be queued again, got %v", jobs)
+	}
+	started, release, _ := startJob(queue, "peripheral-1", "{}")
+	waitStarted(t, started)
+	close(release)
+}
@@ -0,0 +1,138 @@
+package kms
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// awsKmsWrapper uses the Encrypt and Decrypt operations of AWS KMS, signing the requests with Signature Version 4
+type awsKmsWrapper struct {
+	keyId        string
+	region       string
+	endpoint     string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	now          func() time.Time
+}
+
+func newAwsKmsWrapper(keyId string) (KeyWrapper, error) {
+	if len(keyId) == 0 {
+		return nil, fmt.Errorf("no key id in the AWS KMS key, expected awskms:<key id, alias or ARN>")
+	}
+	wrapper := awsKmsWrapper{keyId: keyId, region: os.Getenv("AWS_REGION"), accessKey: os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey: os.Getenv("AWS_SECRET_ACCESS_KEY"), sessionToken: os.Getenv("AWS_SESSION_TOKEN"), now: time.Now}
+	// arn:aws:kms:<region>:<account>:key/<id>
+	if arn := strings.Split(keyId, ":"); len(arn) >= 6 && arn[0] == "arn" {
+		wrapper.region = arn[3]
+	}
+	if len(wrapper.region) == 0 {
+		wrapper.region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if len(wrapper.region) == 0 || len(wrapper.accessKey) == 0 || len(wrapper.secretKey) == 0 {
+		return nil, fmt.Errorf("the region, AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are needed to wrap keys with AWS KMS")
+	}
+	wrapper.endpoint = os.Getenv("AWS_ENDPOINT_URL_KMS")
+	if len(wrapper.endpoint) == 0 {
+		wrapper.endpoint = fmt.Sprintf("https://kms.%s.amazonaws.com/", wrapper.region)
+	}
+	return wrapper, nil
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// signedHeaders returns the headers of the request authenticated with Signature Version 4
+func (wrapper awsKmsWrapper) signedHeaders(target string, body []byte) (map[string]string, error) {
+	endpoint, err := url.Parse(wrapper.endpoint)
+	if err != nil {
+		return nil, err
+	}
+	now := wrapper.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	headers := map[string]string{
+		"content-type": "application/x-amz-json-1.1",
+		"host":         endpoint.Host,
+		"x-amz-date":   amzDate,
+		"x-amz-target": target,
+	}
+	if len(wrapper.sessionToken) > 0 {
+		headers["x-amz-security-token"] = wrapper.sessionToken
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	path := endpoint.EscapedPath()
+	if len(path) == 0 {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{"POST", path, "", canonicalHeaders.String(), strings.Join(names, ";"), sha256Hex(body)}, "\n")
+	scope := date + "/" + wrapper.region + "/kms/aws4_request"
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+	signingKey := hmacSha256(hmacSha256(hmacSha256(hmacSha256([]byte("AWS4"+wrapper.secretKey), date), wrapper.region), "kms"), "aws4_request")
+	headers["authorization"] = fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		wrapper.accessKey, scope, strings.Join(names, ";"), hex.EncodeToString(hmacSha256(signingKey, stringToSign)))
+	delete(headers, "host")
+	return headers, nil
+}
+
+func (wrapper awsKmsWrapper) post(operation string, request map[string]string, result interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	headers, err := wrapper.signedHeaders("TrentService."+operation, body)
+	if err != nil {
+		return err
+	}
+	if err := postJson(wrapper.endpoint, headers, json.RawMessage(body), result); err != nil {
+		return fmt.Errorf("error running AWS KMS %s with key %s: %w", operation, wrapper.keyId, err)
+	}
+	return nil
+}
+
+func (wrapper awsKmsWrapper) Wrap(key []byte) ([]byte, error) {
+	response := struct {
+		CiphertextBlob string
+	}{}
+	request := map[string]string{"KeyId": wrapper.keyId, "Plaintext": base64.StdEncoding.EncodeToString(key)}
+	if err := wrapper.post("Encrypt", request, &response); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(response.CiphertextBlob)
+}
+
+func (wrapper awsKmsWrapper) Unwrap(wrapped []byte) ([]byte, error) {
+	response := struct {
+		Plaintext string
+	}{}
+	request := map[string]string{"KeyId": wrapper.keyId, "CiphertextBlob": base64.StdEncoding.EncodeToString(wrapped)}
+	if err := wrapper.post("Decrypt", request, &response); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(response.Plaintext)
+}
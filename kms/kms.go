@@ -0,0 +1,167 @@
+// Package kms wraps the keys encrypting the export archives with the key of an external key management service,
+// so the keys never exist unwrapped outside of the export and import processes.
+package kms
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// KeyWrapper encrypts and decrypts keys with a key held by a key management service
+type KeyWrapper interface {
+	Wrap(key []byte) ([]byte, error)
+	Unwrap(wrapped []byte) ([]byte, error)
+}
+
+const (
+	// vault:<mount>/<key>, or vault:<key> for the transit engine mounted at transit
+	vaultPrefix = "vault:"
+	// awskms:<key id, alias or ARN>
+	awsKmsPrefix = "awskms:"
+	// exec:<command>, run with the argument wrap or unwrap, reading the key from stdin and writing the result to
+	// stdout. Used for PKCS#11 tokens and HSMs with the tools of their vendor.
+	execPrefix = "exec:"
+)
+
+var httpClient = &http.Client{Timeout: 30 * time.Second,
+	Transport: &http.Transport{TLSClientConfig: utils.RestrictTLS(&tls.Config{})}}
+
+// NewKeyWrapper returns the wrapper of the key selected by the URI. The credentials of the services are read from
+// their usual environment variables: VAULT_ADDR, VAULT_TOKEN and VAULT_NAMESPACE for Vault, AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN and AWS_REGION for AWS KMS.
+func NewKeyWrapper(uri string) (KeyWrapper, error) {
+	switch {
+	case strings.HasPrefix(uri, vaultPrefix):
+		return newVaultWrapper(strings.TrimPrefix(uri, vaultPrefix))
+	case strings.HasPrefix(uri, awsKmsPrefix):
+		return newAwsKmsWrapper(strings.TrimPrefix(uri, awsKmsPrefix))
+	case strings.HasPrefix(uri, execPrefix):
+		command := strings.TrimSpace(strings.TrimPrefix(uri, execPrefix))
+		if len(command) == 0 {
+			return nil, fmt.Errorf("no command in key wrapping %s", uri)
+		}
+		return execWrapper{command: command}, nil
+	}
+	return nil, fmt.Errorf("unknown key wrapping %s, allowed are vault:, awskms: and exec: keys", uri)
+}
+
+// postJson posts the request, decoding the successful response into result
+func postJson(url string, headers map[string]string, request interface{}, result interface{}) error {
+	content, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	httpRequest, err := http.NewRequestWithContext(utils.Context(), http.MethodPost, url, bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	for name, value := range headers {
+		httpRequest.Header.Set(name, value)
+	}
+	response, err := httpClient.Do(httpRequest)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response status %s: %s", response.Status, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, result)
+}
+
+// vaultWrapper uses the transit secrets engine of Vault
+type vaultWrapper struct {
+	address   string
+	token     string
+	namespace string
+	mount     string
+	key       string
+}
+
+func newVaultWrapper(key string) (KeyWrapper, error) {
+	wrapper := vaultWrapper{address: strings.TrimSuffix(os.Getenv("VAULT_ADDR"), "/"), token: os.Getenv("VAULT_TOKEN"),
+		namespace: os.Getenv("VAULT_NAMESPACE"), mount: "transit", key: key}
+	if separator := strings.LastIndex(key, "/"); separator >= 0 {
+		wrapper.mount, wrapper.key = key[:separator], key[separator+1:]
+	}
+	if len(wrapper.key) == 0 || len(wrapper.mount) == 0 {
+		return nil, fmt.Errorf("invalid Vault key %s, expected vault:<mount>/<key>", key)
+	}
+	if len(wrapper.address) == 0 || len(wrapper.token) == 0 {
+		return nil, fmt.Errorf("VAULT_ADDR and VAULT_TOKEN are needed to wrap keys with Vault")
+	}
+	return wrapper, nil
+}
+
+func (wrapper vaultWrapper) post(operation string, request map[string]string) (map[string]string, error) {
+	headers := map[string]string{"X-Vault-Token": wrapper.token, "Content-Type": "application/json"}
+	if len(wrapper.namespace) > 0 {
+		headers["X-Vault-Namespace"] = wrapper.namespace
+	}
+	response := struct {
+		Data map[string]string `json:"data"`
+	}{}
+	url := fmt.Sprintf("%s/v1/%s/%s/%s", wrapper.address, wrapper.mount, operation, wrapper.key)
+	if err := postJson(url, headers, request, &response); err != nil {
+		return nil, fmt.Errorf("error running Vault %s with key %s/%s: %w", operation, wrapper.mount, wrapper.key, err)
+	}
+	return response.Data, nil
+}
+
+func (wrapper vaultWrapper) Wrap(key []byte) ([]byte, error) {
+	data, err := wrapper.post("encrypt", map[string]string{"plaintext": base64.StdEncoding.EncodeToString(key)})
+	if err != nil {
+		return nil, err
+	}
+	return []byte(data["ciphertext"]), nil
+}
+
+func (wrapper vaultWrapper) Unwrap(wrapped []byte) ([]byte, error) {
+	data, err := wrapper.post("decrypt", map[string]string{"ciphertext": string(wrapped)})
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(data["plaintext"])
+}
+
+// execWrapper runs a command wrapping and unwrapping the keys
+type execWrapper struct {
+	command string
+}
+
+func (wrapper execWrapper) run(operation string, input []byte) ([]byte, error) {
+	cmd := exec.CommandContext(utils.Context(), wrapper.command, operation)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("error running %s %s: %w", wrapper.command, operation, err)
+	}
+	if len(output) == 0 {
+		return nil, fmt.Errorf("%s %s returned no key", wrapper.command, operation)
+	}
+	return output, nil
+}
+
+func (wrapper execWrapper) Wrap(key []byte) ([]byte, error) {
+	return wrapper.run("wrap", key)
+}
+
+func (wrapper execWrapper) Unwrap(wrapped []byte) ([]byte, error) {
+	return wrapper.run("unwrap", wrapped)
+}
@@ -0,0 +1,103 @@
+package kms
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewKeyWrapper(t *testing.T) {
+	t.Setenv("VAULT_ADDR", "https://vault.example.com/")
+	t.Setenv("VAULT_TOKEN", "token")
+	wrapper, err := NewKeyWrapper("vault:secrets/transit/iss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vault := wrapper.(vaultWrapper); vault.mount != "secrets/transit" || vault.key != "iss" || vault.address != "https://vault.example.com" {
+		t.Errorf("Unexpected Vault key %+v", vault)
+	}
+	if wrapper, _ := NewKeyWrapper("vault:iss"); wrapper.(vaultWrapper).mount != "transit" {
+		t.Errorf("The transit engine must be mounted at transit by default")
+	}
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	aws, err := NewKeyWrapper("awskms:arn:aws:kms:eu-central-1:111122223333:key/1234abcd")
+	if err != nil || aws.(awsKmsWrapper).endpoint != "https://kms.eu-central-1.amazonaws.com/" {
+		t.Errorf("Unexpected AWS KMS key %+v: %v", aws, err)
+	}
+	for _, uri := range []string{"awskms:alias/iss", "exec:", "pkcs11:token", "vault:transit/"} {
+		if _, err := NewKeyWrapper(uri); err == nil {
+			t.Errorf("Key wrapping %s must be refused", uri)
+		}
+	}
+}
+
+func TestVaultWrapper(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := map[string]string{}
+		json.NewDecoder(r.Body).Decode(&request)
+		if r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/transit/encrypt/iss":
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]string{"ciphertext": "vault:v1:" + request["plaintext"]}})
+		case "/v1/transit/decrypt/iss":
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]string{"plaintext": strings.TrimPrefix(request["ciphertext"], "vault:v1:")}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "token")
+	wrapper, err := NewKeyWrapper("vault:iss")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wrapped, err := wrapper.Wrap([]byte("key"))
+	if err != nil || string(wrapped) != "vault:v1:"+base64.StdEncoding.EncodeToString([]byte("key")) {
+		t.Fatalf("Unexpected wrapped key %s: %v", wrapped, err)
+	}
+	if key, err := wrapper.Unwrap(wrapped); err != nil || string(key) != "key" {
+		t.Errorf("Unexpected unwrapped key %s: %v", key, err)
+	}
+}
+
+func TestAwsKmsWrapper(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := map[string]string{}
+		json.NewDecoder(r.Body).Decode(&request)
+		expectedCredential := "AWS4-HMAC-SHA256 Credential=AKID/20260301/eu-central-1/kms/aws4_request, " +
+			"SignedHeaders=content-type;host;x-amz-date;x-amz-security-token;x-amz-target, Signature="
+		if !strings.HasPrefix(r.Header.Get("Authorization"), expectedCredential) || r.Header.Get("X-Amz-Date") != "20260301T020000Z" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.Header.Get("X-Amz-Target") {
+		case "TrentService.Encrypt":
+			json.NewEncoder(w).Encode(map[string]string{"CiphertextBlob": request["Plaintext"]})
+		case "TrentService.Decrypt":
+			json.NewEncoder(w).Encode(map[string]string{"Plaintext": request["CiphertextBlob"]})
+		}
+	}))
+	defer server.Close()
+	wrapper := awsKmsWrapper{keyId: "alias/iss", region: "eu-central-1", endpoint: server.URL + "/", accessKey: "AKID",
+		secretKey: "secret", sessionToken: "session", now: func() time.Time { return time.Date(2026, 3, 1, 2, 0, 0, 0, time.UTC) }}
+
+	wrapped, err := wrapper.Wrap([]byte("key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key, err := wrapper.Unwrap(wrapped); err != nil || string(key) != "key" {
+		t.Errorf("Unexpected unwrapped key %s: %v", key, err)
+	}
+}
@@ -0,0 +1,62 @@
+package legacyXml
+
+import (
+	"compress/gzip"
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// ReadDump reads all the XML files of a satellite-sync dump directory, plain or gzipped, merging their content
+func ReadDump(dumpDir string) SatelliteDump {
+	result := SatelliteDump{}
+	err := filepath.Walk(dumpDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !(strings.HasSuffix(path, ".xml") || strings.HasSuffix(path, ".xml.gz")) {
+			return nil
+		}
+		log.Trace().Msgf("Reading legacy dump file %s", path)
+		dump, err := readDumpFile(path)
+		if err != nil {
+			log.Warn().Err(err).Msgf("Skipping legacy dump file %s", path)
+			return nil
+		}
+		result.Channels = append(result.Channels, dump.Channels...)
+		result.PackagesShort = append(result.PackagesShort, dump.PackagesShort...)
+		result.Packages = append(result.Packages, dump.Packages...)
+		result.Errata = append(result.Errata, dump.Errata...)
+		return nil
+	})
+	if err != nil {
+		utils.Fatal().Err(err).Msgf("error reading legacy dump %s", dumpDir)
+	}
+	return result
+}
+
+func readDumpFile(path string) (SatelliteDump, error) {
+	dump := SatelliteDump{}
+	file, err := os.Open(path)
+	if err != nil {
+		return dump, err
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		gzipReader, err := gzip.NewReader(file)
+		if err != nil {
+			return dump, err
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	}
+	err = xml.NewDecoder(reader).Decode(&dump)
+	return dump, err
+}
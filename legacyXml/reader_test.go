@@ -0,0 +1,100 @@
+package legacyXml
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const channelDump = `<?xml version="1.0" encoding="UTF-8"?>
+<rhn-satellite version="3.6">
+  <rhn-channels>
+    <rhn-channel channel-id="rhn-channel-102" label="child-channel" channel-arch="channel-x86_64"
+        packages="rhn-package-1001" channel-errata="rhn-erratum-7">
+      <rhn-channel-parent-channel>base-channel</rhn-channel-parent-channel>
+      <rhn-channel-name>Child channel</rhn-channel-name>
+      <rhn-channel-summary>Child</rhn-channel-summary>
+    </rhn-channel>
+    <rhn-channel channel-id="rhn-channel-101" label="base-channel" channel-arch="channel-x86_64">
+      <rhn-channel-name>Base channel</rhn-channel-name>
+      <rhn-channel-summary>Base</rhn-channel-summary>
+    </rhn-channel>
+  </rhn-channels>
+</rhn-satellite>`
+
+const packageAndErrataDump = `<?xml version="1.0" encoding="UTF-8"?>
+<rhn-satellite version="3.6">
+  <rhn-packages-short>
+    <rhn-package-short id="rhn-package-1001" name="vim" epoch="" version="7.4" release="1" package-arch="x86_64"/>
+  </rhn-packages-short>
+  <rhn-errata>
+    <rhn-erratum id="rhn-erratum-7" advisory="RHSA-2010:0001" packages="rhn-package-1001">
+      <rhn-erratum-advisory-name>RHSA-2010:0001</rhn-erratum-advisory-name>
+      <rhn-erratum-advisory-rel>1</rhn-erratum-advisory-rel>
+      <rhn-erratum-advisory-type>Security Advisory</rhn-erratum-advisory-type>
+      <rhn-erratum-synopsis>vim update</rhn-erratum-synopsis>
+      <rhn-erratum-issue-date>2010-01-04 00:00:00</rhn-erratum-issue-date>
+    </rhn-erratum>
+  </rhn-errata>
+</rhn-satellite>`
+
+func TestReadDumpAndWriteSql(t *testing.T) {
+	dumpDir := t.TempDir()
+	os.MkdirAll(filepath.Join(dumpDir, "channels"), 0755)
+	os.WriteFile(filepath.Join(dumpDir, "channels", "channel.xml"), []byte(channelDump), 0644)
+	os.WriteFile(filepath.Join(dumpDir, "packages.xml"), []byte(packageAndErrataDump), 0644)
+
+	dump := ReadDump(dumpDir)
+	if len(dump.Channels) != 2 || len(dump.PackagesShort) != 1 || len(dump.Errata) != 1 {
+		t.Fatalf("Unexpected dump content: %+v", dump)
+	}
+
+	var buffer bytes.Buffer
+	writer := bufio.NewWriter(&buffer)
+	WriteSql(writer, dump)
+	writer.Flush()
+	statements := strings.Split(strings.TrimSpace(buffer.String()), ";\n")
+
+	if len(statements) != 6 {
+		t.Fatalf("Expected 6 statements, got %d: %s", len(statements), buffer.String())
+	}
+	if !strings.Contains(statements[0], "'base-channel'") || !strings.Contains(statements[1], "'child-channel'") {
+		t.Errorf("Base channel should be inserted before its children")
+	}
+	if !strings.HasPrefix(statements[2], "INSERT INTO rhnerrata ") ||
+		!strings.Contains(statements[2], "'2010-01-04 00:00:00'::timestamptz") {
+		t.Errorf("Unexpected erratum statement: %s", statements[2])
+	}
+	if !strings.Contains(statements[4], "pn.name = 'vim' AND pe.epoch IS NOT DISTINCT FROM null") {
+		t.Errorf("Unexpected channel package statement: %s", statements[4])
+	}
+}
+
+func TestWriteDumpReadsBack(t *testing.T) {
+	dump := SatelliteDump{
+		Version:       dumpVersion,
+		Channels:      []Channel{{ID: "rhn-channel-101", Label: "base-channel", Arch: "channel-x86_64", Name: "Base", Summary: "Base", PackageIDs: "rhn-package-1001"}},
+		PackagesShort: []Package{{ID: "rhn-package-1001", Name: "vim", Version: "7.4", Release: "1", Arch: "x86_64"}},
+		Errata:        []Erratum{{ID: "rhn-erratum-7", Advisory: "RHSA-2010:0001", AdvisoryType: "Security Advisory", Synopsis: "vim update"}},
+	}
+	outputDir := t.TempDir()
+
+	WriteDump(outputDir, dump)
+	result := ReadDump(outputDir)
+
+	if _, err := os.Stat(filepath.Join(outputDir, "channels", "base-channel", "channel.xml")); err != nil {
+		t.Errorf("Channel file not written: %s", err)
+	}
+	if len(result.Channels) != 1 || result.Channels[0] != dump.Channels[0] {
+		t.Errorf("Expected channel %+v, got %+v", dump.Channels, result.Channels)
+	}
+	if len(result.PackagesShort) != 1 || result.PackagesShort[0] != dump.PackagesShort[0] {
+		t.Errorf("Expected package %+v, got %+v", dump.PackagesShort, result.PackagesShort)
+	}
+	if len(result.Errata) != 1 || result.Errata[0] != dump.Errata[0] {
+		t.Errorf("Expected erratum %+v, got %+v", dump.Errata, result.Errata)
+	}
+}
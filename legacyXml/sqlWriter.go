@@ -0,0 +1,141 @@
+package legacyXml
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+)
+
+// sub query finding a vendor package by its NEVRA, packages are only linked if they exist in the target
+const packageByNevraSql = `SELECT p.id FROM rhnpackage p
+	JOIN rhnpackagename pn ON pn.id = p.name_id
+	JOIN rhnpackageevr pe ON pe.id = p.evr_id
+	JOIN rhnpackagearch pa ON pa.id = p.package_arch_id
+	WHERE pn.name = %s AND pe.epoch IS NOT DISTINCT FROM %s AND pe.version = %s AND pe.release = %s
+	AND pa.label = %s AND p.org_id IS NULL`
+
+// WriteSql writes the statements importing the channels, errata and their packages of a legacy dump
+func WriteSql(writer *bufio.Writer, dump SatelliteDump) {
+	packages := make(map[string]Package)
+	for _, pkg := range append(dump.PackagesShort, dump.Packages...) {
+		packages[pkg.ID] = pkg
+	}
+	errata := make(map[string]Erratum)
+	for _, erratum := range dump.Errata {
+		errata[erratum.ID] = erratum
+	}
+
+	for _, channel := range sortChannelsByParent(dump.Channels) {
+		writer.WriteString(channelInsertStatement(channel) + "\n")
+	}
+	for _, erratum := range dump.Errata {
+		writer.WriteString(erratumInsertStatement(erratum) + "\n")
+		for _, pkg := range findPackages(packages, erratum.PackageIDs) {
+			writer.WriteString(fmt.Sprintf(`INSERT INTO rhnerratapackage (errata_id, package_id)
+	SELECT e.id, p.id FROM rhnerrata e, (%s) p WHERE e.advisory = %s AND e.org_id IS NULL
+	AND NOT EXISTS (SELECT 1 FROM rhnerratapackage ep WHERE ep.errata_id = e.id AND ep.package_id = p.id);`,
+				packageSelect(pkg), pq.QuoteLiteral(erratum.Advisory)) + "\n")
+		}
+	}
+	for _, channel := range dump.Channels {
+		for _, pkg := range findPackages(packages, channel.PackageIDs) {
+			writer.WriteString(fmt.Sprintf(`INSERT INTO rhnchannelpackage (channel_id, package_id)
+	SELECT c.id, p.id FROM rhnchannel c, (%s) p WHERE c.label = %s
+	AND NOT EXISTS (SELECT 1 FROM rhnchannelpackage cp WHERE cp.channel_id = c.id AND cp.package_id = p.id);`,
+				packageSelect(pkg), pq.QuoteLiteral(channel.Label)) + "\n")
+		}
+		for _, erratumID := range strings.Fields(channel.ErrataIDs) {
+			erratum, ok := errata[erratumID]
+			if !ok {
+				log.Warn().Msgf("Erratum %s of channel %s not found in the dump", erratumID, channel.Label)
+				continue
+			}
+			writer.WriteString(fmt.Sprintf(`INSERT INTO rhnchannelerrata (channel_id, errata_id)
+	SELECT c.id, e.id FROM rhnchannel c, rhnerrata e WHERE c.label = %s AND e.advisory = %s AND e.org_id IS NULL
+	AND NOT EXISTS (SELECT 1 FROM rhnchannelerrata ce WHERE ce.channel_id = c.id AND ce.errata_id = e.id);`,
+				pq.QuoteLiteral(channel.Label), pq.QuoteLiteral(erratum.Advisory)) + "\n")
+		}
+	}
+}
+
+// sortChannelsByParent orders base channels before their children, so the parent can be referenced
+func sortChannelsByParent(channels []Channel) []Channel {
+	result := make([]Channel, 0, len(channels))
+	for _, channel := range channels {
+		if len(channel.Parent) == 0 {
+			result = append(result, channel)
+		}
+	}
+	for _, channel := range channels {
+		if len(channel.Parent) > 0 {
+			result = append(result, channel)
+		}
+	}
+	return result
+}
+
+func findPackages(packages map[string]Package, packageIDs string) []Package {
+	result := make([]Package, 0)
+	for _, packageID := range strings.Fields(packageIDs) {
+		pkg, ok := packages[packageID]
+		if !ok {
+			log.Warn().Msgf("Package %s not found in the dump", packageID)
+			continue
+		}
+		result = append(result, pkg)
+	}
+	return result
+}
+
+func channelInsertStatement(channel Channel) string {
+	basedir := channel.Basedir
+	if len(basedir) == 0 {
+		basedir = "/dev/null"
+	}
+	parent := "null"
+	if len(channel.Parent) > 0 {
+		parent = fmt.Sprintf("(SELECT id FROM rhnchannel WHERE label = %s)", pq.QuoteLiteral(channel.Parent))
+	}
+	label := pq.QuoteLiteral(channel.Label)
+	return fmt.Sprintf(`INSERT INTO rhnchannel (id, label, name, summary, description, basedir, channel_arch_id, parent_channel)
+	SELECT nextval('rhn_channel_id_seq'), %s, %s, %s, %s, %s, (SELECT id FROM rhnchannelarch WHERE label = %s), %s
+	WHERE NOT EXISTS (SELECT 1 FROM rhnchannel WHERE label = %s);`,
+		label, pq.QuoteLiteral(channel.Name), pq.QuoteLiteral(channel.Summary), formatOptional(channel.Description),
+		pq.QuoteLiteral(basedir), pq.QuoteLiteral(channel.Arch), parent, label)
+}
+
+func erratumInsertStatement(erratum Erratum) string {
+	advisory := pq.QuoteLiteral(erratum.Advisory)
+	advisoryRel := erratum.AdvisoryRel
+	if len(advisoryRel) == 0 {
+		advisoryRel = "1"
+	}
+	return fmt.Sprintf(`INSERT INTO rhnerrata (id, advisory, advisory_name, advisory_rel, advisory_type, synopsis, topic, description, solution, issue_date, update_date)
+	SELECT nextval('rhn_errata_id_seq'), %s, %s, %s::numeric, %s, %s, %s, %s, %s, %s, %s
+	WHERE NOT EXISTS (SELECT 1 FROM rhnerrata WHERE advisory = %s AND org_id IS NULL);`,
+		advisory, pq.QuoteLiteral(erratum.AdvisoryName), pq.QuoteLiteral(advisoryRel), pq.QuoteLiteral(erratum.AdvisoryType),
+		pq.QuoteLiteral(erratum.Synopsis), formatOptional(erratum.Topic), formatOptional(erratum.Description),
+		pq.QuoteLiteral(erratum.Solution), formatDate(erratum.IssueDate), formatDate(erratum.UpdateDate), advisory)
+}
+
+func packageSelect(pkg Package) string {
+	return fmt.Sprintf(packageByNevraSql, pq.QuoteLiteral(pkg.Name), formatOptional(pkg.Epoch),
+		pq.QuoteLiteral(pkg.Version), pq.QuoteLiteral(pkg.Release), pq.QuoteLiteral(pkg.Arch))
+}
+
+func formatOptional(value string) string {
+	if len(value) == 0 {
+		return "null"
+	}
+	return pq.QuoteLiteral(value)
+}
+
+func formatDate(value string) string {
+	if len(value) == 0 {
+		return "current_timestamp"
+	}
+	return pq.QuoteLiteral(value) + "::timestamptz"
+}
@@ -0,0 +1,51 @@
+package legacyXml
+
+import "encoding/xml"
+
+// SatelliteDump is the root element of the files of a satellite-sync (rhn-satellite-exporter) dump.
+// Each file only contains some of the sections.
+type SatelliteDump struct {
+	XMLName       xml.Name  `xml:"rhn-satellite"`
+	Version       string    `xml:"version,attr,omitempty"`
+	Channels      []Channel `xml:"rhn-channels>rhn-channel,omitempty"`
+	PackagesShort []Package `xml:"rhn-packages-short>rhn-package-short,omitempty"`
+	Packages      []Package `xml:"rhn-packages>rhn-package,omitempty"`
+	Errata        []Erratum `xml:"rhn-errata>rhn-erratum,omitempty"`
+}
+
+type Channel struct {
+	ID          string `xml:"channel-id,attr"`
+	Label       string `xml:"label,attr"`
+	Arch        string `xml:"channel-arch,attr"`
+	PackageIDs  string `xml:"packages,attr,omitempty"`
+	ErrataIDs   string `xml:"channel-errata,attr,omitempty"`
+	Parent      string `xml:"rhn-channel-parent-channel,omitempty"`
+	Basedir     string `xml:"rhn-channel-basedir,omitempty"`
+	Name        string `xml:"rhn-channel-name"`
+	Summary     string `xml:"rhn-channel-summary"`
+	Description string `xml:"rhn-channel-description,omitempty"`
+}
+
+type Package struct {
+	ID      string `xml:"id,attr"`
+	Name    string `xml:"name,attr"`
+	Epoch   string `xml:"epoch,attr,omitempty"`
+	Version string `xml:"version,attr"`
+	Release string `xml:"release,attr"`
+	Arch    string `xml:"package-arch,attr"`
+}
+
+type Erratum struct {
+	ID           string `xml:"id,attr"`
+	Advisory     string `xml:"advisory,attr"`
+	PackageIDs   string `xml:"packages,attr,omitempty"`
+	AdvisoryName string `xml:"rhn-erratum-advisory-name"`
+	AdvisoryRel  string `xml:"rhn-erratum-advisory-rel"`
+	AdvisoryType string `xml:"rhn-erratum-advisory-type"`
+	Synopsis     string `xml:"rhn-erratum-synopsis"`
+	Topic        string `xml:"rhn-erratum-topic,omitempty"`
+	Description  string `xml:"rhn-erratum-description,omitempty"`
+	Solution     string `xml:"rhn-erratum-solution,omitempty"`
+	IssueDate    string `xml:"rhn-erratum-issue-date,omitempty"`
+	UpdateDate   string `xml:"rhn-erratum-update-date,omitempty"`
+}
@@ -0,0 +1,195 @@
+package legacyXml
+
+import (
+	"database/sql"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// legacy dumps only reference objects by these prefixed ids
+const (
+	channelIDPrefix = "rhn-channel-"
+	packageIDPrefix = "rhn-package-"
+	erratumIDPrefix = "rhn-erratum-"
+)
+
+// dump version written for satellite-sync
+const dumpVersion = "3.6"
+
+const channelsSql = `SELECT c.id, c.label, ca.label, c.name, c.summary, c.description, c.basedir, p.label
+	FROM rhnchannel c
+	JOIN rhnchannelarch ca ON ca.id = c.channel_arch_id
+	LEFT JOIN rhnchannel p ON p.id = c.parent_channel
+	WHERE c.label = ANY($1) OR p.label = ANY($2)
+	ORDER BY c.parent_channel NULLS FIRST, c.label;`
+
+const channelPackagesSql = `SELECT p.id, pn.name, pe.epoch, pe.version, pe.release, pa.label
+	FROM rhnchannelpackage cp
+	JOIN rhnpackage p ON p.id = cp.package_id
+	JOIN rhnpackagename pn ON pn.id = p.name_id
+	JOIN rhnpackageevr pe ON pe.id = p.evr_id
+	JOIN rhnpackagearch pa ON pa.id = p.package_arch_id
+	WHERE cp.channel_id = $1
+	ORDER BY p.id;`
+
+// errata may reference packages which are not in any exported channel, they are part of the dump too
+const erratumPackagesSql = `SELECT p.id, pn.name, pe.epoch, pe.version, pe.release, pa.label
+	FROM rhnerratapackage ep
+	JOIN rhnpackage p ON p.id = ep.package_id
+	JOIN rhnpackagename pn ON pn.id = p.name_id
+	JOIN rhnpackageevr pe ON pe.id = p.evr_id
+	JOIN rhnpackagearch pa ON pa.id = p.package_arch_id
+	WHERE ep.errata_id = $1
+	ORDER BY p.id;`
+
+const channelErrataSql = `SELECT e.id, e.advisory, e.advisory_name, e.advisory_rel, e.advisory_type, e.synopsis,
+	e.topic, e.description, e.solution, e.issue_date, e.update_date
+	FROM rhnchannelerrata ce
+	JOIN rhnerrata e ON e.id = ce.errata_id
+	WHERE ce.channel_id = $1
+	ORDER BY e.id;`
+
+// ExportChannels writes the channels, with their children channels when requested, their packages and errata
+// as a legacy satellite-sync dump. Package files are not part of the dump.
+func ExportChannels(db *sql.DB, channelLabels []string, channelWithChildrenLabels []string, outputDir string) {
+	channelRows := sqlUtil.ExecuteQueryWithResults(db, channelsSql,
+		pq.Array(append(channelLabels, channelWithChildrenLabels...)), pq.Array(channelWithChildrenLabels))
+	if len(channelRows) == 0 {
+		utils.Fatal().Msg("no channels found to export")
+	}
+
+	dump := SatelliteDump{Version: dumpVersion}
+	packages := make(map[string]Package)
+	// packages only referenced by errata, in order of reference
+	erratumPackages := make([]string, 0)
+	errata := make(map[string]Erratum)
+	for _, row := range channelRows {
+		channelID := formatText(row[0].Value)
+		channel := Channel{
+			ID:          channelIDPrefix + channelID,
+			Label:       formatText(row[1].Value),
+			Arch:        formatText(row[2].Value),
+			Name:        formatText(row[3].Value),
+			Summary:     formatText(row[4].Value),
+			Description: formatText(row[5].Value),
+			Basedir:     formatText(row[6].Value),
+			Parent:      formatText(row[7].Value),
+		}
+		packageIDs := make([]string, 0)
+		for _, packageRow := range sqlUtil.ExecuteQueryWithResults(db, channelPackagesSql, channelID) {
+			pkg := readPackage(packageRow)
+			packages[pkg.ID] = pkg
+			packageIDs = append(packageIDs, pkg.ID)
+		}
+		channel.PackageIDs = strings.Join(packageIDs, " ")
+
+		errataIDs := make([]string, 0)
+		for _, erratumRow := range sqlUtil.ExecuteQueryWithResults(db, channelErrataSql, channelID) {
+			erratumID := erratumIDPrefix + formatText(erratumRow[0].Value)
+			errataIDs = append(errataIDs, erratumID)
+			if _, ok := errata[erratumID]; ok {
+				continue
+			}
+			erratumPackageIDs := make([]string, 0)
+			for _, packageRow := range sqlUtil.ExecuteQueryWithResults(db, erratumPackagesSql, formatText(erratumRow[0].Value)) {
+				pkg := readPackage(packageRow)
+				if _, ok := packages[pkg.ID]; !ok {
+					packages[pkg.ID] = pkg
+					erratumPackages = append(erratumPackages, pkg.ID)
+				}
+				erratumPackageIDs = append(erratumPackageIDs, pkg.ID)
+			}
+			errata[erratumID] = Erratum{
+				ID:           erratumID,
+				Advisory:     formatText(erratumRow[1].Value),
+				AdvisoryName: formatText(erratumRow[2].Value),
+				AdvisoryRel:  formatText(erratumRow[3].Value),
+				AdvisoryType: formatText(erratumRow[4].Value),
+				Synopsis:     formatText(erratumRow[5].Value),
+				Topic:        formatText(erratumRow[6].Value),
+				Description:  formatText(erratumRow[7].Value),
+				Solution:     formatText(erratumRow[8].Value),
+				IssueDate:    formatText(erratumRow[9].Value),
+				UpdateDate:   formatText(erratumRow[10].Value),
+				PackageIDs:   strings.Join(erratumPackageIDs, " "),
+			}
+			dump.Errata = append(dump.Errata, errata[erratumID])
+		}
+		channel.ErrataIDs = strings.Join(errataIDs, " ")
+		dump.Channels = append(dump.Channels, channel)
+	}
+	packageIDs := make([]string, 0)
+	for _, channel := range dump.Channels {
+		packageIDs = append(packageIDs, strings.Fields(channel.PackageIDs)...)
+	}
+	for _, packageID := range append(packageIDs, erratumPackages...) {
+		if pkg, ok := packages[packageID]; ok {
+			dump.PackagesShort = append(dump.PackagesShort, pkg)
+			delete(packages, packageID)
+		}
+	}
+	WriteDump(outputDir, dump)
+}
+
+// readPackage reads a package row of channelPackagesSql or erratumPackagesSql
+func readPackage(row []sqlUtil.RowDataStructure) Package {
+	return Package{
+		ID:      packageIDPrefix + formatText(row[0].Value),
+		Name:    formatText(row[1].Value),
+		Epoch:   formatText(row[2].Value),
+		Version: formatText(row[3].Value),
+		Release: formatText(row[4].Value),
+		Arch:    formatText(row[5].Value),
+	}
+}
+
+// WriteDump writes the dump in the directory layout of satellite-sync, one file per section
+func WriteDump(outputDir string, dump SatelliteDump) {
+	for _, channel := range dump.Channels {
+		writeDumpFile(filepath.Join(outputDir, "channels", channel.Label, "channel.xml"),
+			SatelliteDump{Version: dump.Version, Channels: []Channel{channel}})
+	}
+	if len(dump.PackagesShort) > 0 {
+		writeDumpFile(filepath.Join(outputDir, "packages_short", "packages_short.xml"),
+			SatelliteDump{Version: dump.Version, PackagesShort: dump.PackagesShort})
+	}
+	if len(dump.Errata) > 0 {
+		writeDumpFile(filepath.Join(outputDir, "errata", "errata.xml"),
+			SatelliteDump{Version: dump.Version, Errata: dump.Errata})
+	}
+}
+
+func writeDumpFile(path string, dump SatelliteDump) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		utils.Fatal().Err(err).Msgf("error creating directory for %s", path)
+	}
+	content, err := xml.MarshalIndent(dump, "", "  ")
+	if err != nil {
+		utils.Fatal().Err(err).Msg("error encoding legacy dump")
+	}
+	if err := os.WriteFile(path, append([]byte(xml.Header), content...), 0644); err != nil {
+		utils.Fatal().Err(err).Msgf("error writing %s", path)
+	}
+}
+
+// formatText returns the value as text in the format used by legacy dumps, with empty text for null values
+func formatText(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format("2006-01-02 15:04:05")
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
@@ -0,0 +1,34 @@
+package legacyXml
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/uyuni-project/inter-server-sync/tests"
+)
+
+func TestExportChannelsWritesErratumPackages(t *testing.T) {
+	repo := tests.CreateDataRepository()
+	packageColumns := []string{"id", "name", "epoch", "version", "release", "label"}
+	repo.ExpectWithRecords(channelsSql, sqlmock.NewRows([]string{"id", "label", "arch", "name", "summary", "description", "basedir", "parent"}).
+		AddRow("101", "base-channel", "channel-x86_64", "Base", "Base", nil, nil, nil))
+	repo.ExpectWithRecords(channelPackagesSql, sqlmock.NewRows(packageColumns).
+		AddRow("1001", "vim", nil, "7.4", "1", "x86_64"))
+	repo.ExpectWithRecords(channelErrataSql, sqlmock.NewRows([]string{"id", "advisory", "advisory_name", "advisory_rel", "advisory_type",
+		"synopsis", "topic", "description", "solution", "issue_date", "update_date"}).
+		AddRow("7", "RHSA-2010:0001", "RHSA-2010:0001", "1", "Security Advisory", "vim update", nil, nil, nil, nil, nil))
+	repo.ExpectWithRecords(erratumPackagesSql, sqlmock.NewRows(packageColumns).
+		AddRow("1001", "vim", nil, "7.4", "1", "x86_64").
+		AddRow("1002", "vim-data", nil, "7.4", "1", "noarch"), "7")
+	outputDir := t.TempDir()
+
+	ExportChannels(repo.DB, []string{"base-channel"}, nil, outputDir)
+	result := ReadDump(outputDir)
+
+	if len(result.PackagesShort) != 2 || result.PackagesShort[0].ID != "rhn-package-1001" || result.PackagesShort[1].ID != "rhn-package-1002" {
+		t.Errorf("Expected the channel package and the package only referenced by the erratum, got %+v", result.PackagesShort)
+	}
+	if len(result.Errata) != 1 || result.Errata[0].PackageIDs != "rhn-package-1001 rhn-package-1002" {
+		t.Errorf("Expected the erratum with both packages, got %+v", result.Errata)
+	}
+}
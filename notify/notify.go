@@ -0,0 +1,242 @@
+// Package notify sends the outcome of the exports and imports to e-mail recipients and webhooks, so scheduled
+// syncs surface their failures without watching the logs.
+package notify
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultSmtpServer is the mail server the e-mail notifications are sent through by default
+const DefaultSmtpServer = "localhost:25"
+
+// prefix of the Slack-compatible webhook targets, like slack+https://hooks.slack.com/services/...
+const slackPrefix = "slack+"
+
+// prefix of the e-mail targets, like mailto:admin@example.com,ops@example.com
+const mailtoPrefix = "mailto:"
+
+// Config selects the targets of the notifications
+type Config struct {
+	// webhooks receiving the summary as JSON (http:// or https://), Slack-compatible webhooks receiving a text
+	// message (slack+https://), and e-mail recipients receiving the summary with the report attached (mailto:)
+	Targets []string
+	// mail server of the e-mail notifications as host:port, DefaultSmtpServer when empty
+	SmtpServer string
+	// sender of the e-mail notifications, inter-server-sync@<host name> when empty
+	Sender string
+}
+
+// Summary is the outcome of an export or import
+type Summary struct {
+	// export or import
+	Operation string    `json:"operation"`
+	Host      string    `json:"host"`
+	Succeeded bool      `json:"succeeded"`
+	Started   time.Time `json:"started"`
+	Finished  time.Time `json:"finished"`
+	ExitCode  int       `json:"exitCode"`
+	ErrorType string    `json:"errorType,omitempty"`
+	Message   string    `json:"message,omitempty"`
+	// statements written per table by the export
+	Tables map[string]int `json:"tables,omitempty"`
+	// JSON report of the run, like the import report, attached to the notifications
+	Report     json.RawMessage `json:"report,omitempty"`
+	ReportName string          `json:"-"`
+}
+
+// Validate returns the error of the first target which is not a webhook or e-mail target
+func Validate(targets []string) error {
+	for _, target := range targets {
+		if _, err := targetKind(target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func targetKind(target string) (string, error) {
+	switch {
+	case strings.HasPrefix(target, slackPrefix+"http://"), strings.HasPrefix(target, slackPrefix+"https://"):
+		return "slack", nil
+	case strings.HasPrefix(target, "http://"), strings.HasPrefix(target, "https://"):
+		return "webhook", nil
+	case strings.HasPrefix(target, mailtoPrefix):
+		if _, err := mail.ParseAddressList(strings.TrimPrefix(target, mailtoPrefix)); err != nil {
+			return "", fmt.Errorf("invalid e-mail addresses in notification target %s: %w", target, err)
+		}
+		return "mail", nil
+	}
+	return "", fmt.Errorf("unknown notification target %s, allowed are http(s)://, slack+https:// and mailto: targets", target)
+}
+
+// Send sends the summary to all targets. Failures to notify are logged, they don't change the outcome of the run.
+func Send(config Config, summary Summary) {
+	if len(summary.Host) == 0 {
+		summary.Host, _ = os.Hostname()
+	}
+	for _, target := range config.Targets {
+		kind, err := targetKind(target)
+		if err == nil {
+			switch kind {
+			case "slack":
+				err = postJson(strings.TrimPrefix(target, slackPrefix), map[string]string{"text": formatText(summary)})
+			case "webhook":
+				err = postJson(target, summary)
+			case "mail":
+				err = sendMail(config, strings.TrimPrefix(target, mailtoPrefix), summary)
+			}
+		}
+		if err != nil {
+			log.Warn().Err(err).Msgf("Error sending the %s notification to %s", summary.Operation, redactTarget(target))
+		} else {
+			log.Info().Msgf("Sent the %s notification to %s", summary.Operation, redactTarget(target))
+		}
+	}
+}
+
+// redactTarget removes the path of the webhooks from the logs, as it often holds the secret of the webhook
+func redactTarget(target string) string {
+	if strings.HasPrefix(target, mailtoPrefix) {
+		return target
+	}
+	schemeEnd := strings.Index(target, "://") + len("://")
+	if pathStart := strings.Index(target[schemeEnd:], "/"); pathStart >= 0 {
+		return target[:schemeEnd+pathStart] + "/..."
+	}
+	return target
+}
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+func postJson(url string, body interface{}) error {
+	content, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	response, err := httpClient.Post(url, "application/json", bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response status %s", response.Status)
+	}
+	return nil
+}
+
+// subject returns the one line outcome of the run
+func subject(summary Summary) string {
+	if summary.Succeeded {
+		return fmt.Sprintf("inter-server-sync %s on %s succeeded", summary.Operation, summary.Host)
+	}
+	return fmt.Sprintf("inter-server-sync %s on %s failed with %s", summary.Operation, summary.Host, summary.ErrorType)
+}
+
+// formatText returns the summary as text, the tables with the most statements first
+func formatText(summary Summary) string {
+	lines := []string{subject(summary)}
+	lines = append(lines, fmt.Sprintf("Started %s, took %s", summary.Started.Format("2006-01-02 15:04:05 MST"),
+		summary.Finished.Sub(summary.Started).Round(time.Second)))
+	if !summary.Succeeded {
+		lines = append(lines, fmt.Sprintf("Exit code %d: %s", summary.ExitCode, summary.Message))
+	}
+	if len(summary.Tables) > 0 {
+		tableNames := make([]string, 0, len(summary.Tables))
+		total := 0
+		for tableName, rows := range summary.Tables {
+			tableNames = append(tableNames, tableName)
+			total += rows
+		}
+		sort.Slice(tableNames, func(i, j int) bool {
+			if summary.Tables[tableNames[i]] != summary.Tables[tableNames[j]] {
+				return summary.Tables[tableNames[i]] > summary.Tables[tableNames[j]]
+			}
+			return tableNames[i] < tableNames[j]
+		})
+		lines = append(lines, fmt.Sprintf("%d statements in %d tables", total, len(tableNames)))
+		for _, tableName := range tableNames {
+			lines = append(lines, fmt.Sprintf("  %s: %d", tableName, summary.Tables[tableName]))
+		}
+	}
+	if len(summary.Report) > 0 {
+		var counts struct {
+			Statements int `json:"statements"`
+			Inserted   int `json:"inserted"`
+			Skipped    int `json:"skipped"`
+			Updated    int `json:"updated"`
+			Deleted    int `json:"deleted"`
+		}
+		if err := json.Unmarshal(summary.Report, &counts); err == nil && counts.Statements > 0 {
+			lines = append(lines, fmt.Sprintf("%d statements: %d rows inserted, %d updated, %d deleted, %d skipped",
+				counts.Statements, counts.Inserted, counts.Updated, counts.Deleted, counts.Skipped))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatMail returns the e-mail message with the text of the summary, and the report as attachment
+func formatMail(sender string, recipients []string, summary Summary) []byte {
+	boundary := fmt.Sprintf("iss-%d", summary.Finished.UnixNano())
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", sender)
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(recipients, ", "))
+	fmt.Fprintf(&message, "Subject: %s\r\n", subject(summary))
+	fmt.Fprintf(&message, "Date: %s\r\n", summary.Finished.Format(time.RFC1123Z))
+	message.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&message, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", boundary)
+	fmt.Fprintf(&message, "--%s\r\n", boundary)
+	message.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	message.WriteString(strings.ReplaceAll(formatText(summary), "\n", "\r\n") + "\r\n")
+	if len(summary.Report) > 0 {
+		reportName := summary.ReportName
+		if len(reportName) == 0 {
+			reportName = summary.Operation + "Report.json"
+		}
+		fmt.Fprintf(&message, "--%s\r\n", boundary)
+		message.WriteString("Content-Type: application/json\r\n")
+		message.WriteString("Content-Transfer-Encoding: base64\r\n")
+		fmt.Fprintf(&message, "Content-Disposition: attachment; filename=%q\r\n\r\n", reportName)
+		encoded := base64.StdEncoding.EncodeToString(summary.Report)
+		for len(encoded) > 76 {
+			message.WriteString(encoded[:76] + "\r\n")
+			encoded = encoded[76:]
+		}
+		message.WriteString(encoded + "\r\n")
+	}
+	fmt.Fprintf(&message, "--%s--\r\n", boundary)
+	return message.Bytes()
+}
+
+var sendSmtpMail = smtp.SendMail
+
+func sendMail(config Config, addresses string, summary Summary) error {
+	parsed, err := mail.ParseAddressList(addresses)
+	if err != nil {
+		return err
+	}
+	recipients := make([]string, 0, len(parsed))
+	for _, address := range parsed {
+		recipients = append(recipients, address.Address)
+	}
+	server := config.SmtpServer
+	if len(server) == 0 {
+		server = DefaultSmtpServer
+	}
+	sender := config.Sender
+	if len(sender) == 0 {
+		sender = "inter-server-sync@" + summary.Host
+	}
+	return sendSmtpMail(server, nil, sender, recipients, formatMail(sender, recipients, summary))
+}
@@ -0,0 +1,120 @@
+package notify
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testSummary() Summary {
+	started := time.Date(2026, 3, 1, 2, 0, 0, 0, time.UTC)
+	return Summary{Operation: "import", Host: "target.example.com", Started: started, Finished: started.Add(90 * time.Second),
+		ExitCode: 8, ErrorType: "partial_import", Message: "import finished without the rolled back tables rhnerrata",
+		Report: json.RawMessage(`{"statements":12,"inserted":7,"skipped":2,"updated":3,"deleted":0}`)}
+}
+
+func TestValidate(t *testing.T) {
+	valid := []string{"https://hooks.example.com/iss", "slack+https://hooks.slack.com/services/T/B/X", "mailto:admin@example.com, ops@example.com"}
+	if err := Validate(valid); err != nil {
+		t.Errorf("Expected valid targets, got %v", err)
+	}
+	for _, target := range []string{"ftp://example.com", "mailto:not an address", "slack+ftp://example.com"} {
+		if err := Validate([]string{target}); err == nil {
+			t.Errorf("Expected target %s to be refused", target)
+		}
+	}
+}
+
+func TestWebhookReceivesSummary(t *testing.T) {
+	var received Summary
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &received); err != nil {
+			t.Errorf("Invalid request %s: %v", body, err)
+		}
+	}))
+	defer server.Close()
+
+	Send(Config{Targets: []string{server.URL + "/iss"}}, testSummary())
+
+	if received.Operation != "import" || received.Succeeded || received.ExitCode != 8 || received.ErrorType != "partial_import" {
+		t.Errorf("Unexpected summary %+v", received)
+	}
+	if !strings.Contains(string(received.Report), `"inserted":7`) {
+		t.Errorf("Expected the report in the summary, got %s", received.Report)
+	}
+}
+
+func TestSlackWebhookReceivesText(t *testing.T) {
+	var received map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(body, &received)
+	}))
+	defer server.Close()
+
+	summary := testSummary()
+	summary.Succeeded = true
+	summary.Operation = "export"
+	summary.Report = nil
+	summary.Tables = map[string]int{"rhnpackage": 20, "rhnchannel": 1, "rhnchannelpackage": 20}
+	Send(Config{Targets: []string{"slack+" + server.URL}}, summary)
+
+	expected := "inter-server-sync export on target.example.com succeeded\n" +
+		"Started 2026-03-01 02:00:00 UTC, took 1m30s\n" +
+		"41 statements in 3 tables\n" +
+		"  rhnchannelpackage: 20\n" +
+		"  rhnpackage: 20\n" +
+		"  rhnchannel: 1"
+	if received["text"] != expected {
+		t.Errorf("Expected text\n%s\ngot\n%s", expected, received["text"])
+	}
+}
+
+func TestMailAttachesReport(t *testing.T) {
+	stored := sendSmtpMail
+	defer func() { sendSmtpMail = stored }()
+	var server, sender string
+	var recipients []string
+	var message []byte
+	sendSmtpMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		server, sender, recipients, message = addr, from, to, msg
+		return nil
+	}
+
+	Send(Config{Targets: []string{"mailto:Admin <admin@example.com>,ops@example.com"}}, testSummary())
+
+	if server != DefaultSmtpServer || sender != "inter-server-sync@target.example.com" {
+		t.Errorf("Unexpected server %s or sender %s", server, sender)
+	}
+	if len(recipients) != 2 || recipients[0] != "admin@example.com" || recipients[1] != "ops@example.com" {
+		t.Errorf("Unexpected recipients %v", recipients)
+	}
+	content := string(message)
+	if !strings.Contains(content, "Subject: inter-server-sync import on target.example.com failed with partial_import\r\n") {
+		t.Errorf("Unexpected subject in\n%s", content)
+	}
+	if !strings.Contains(content, "12 statements: 7 rows inserted, 3 updated, 0 deleted, 2 skipped") {
+		t.Errorf("Expected the import counts in\n%s", content)
+	}
+	// encoded lines have 76 characters
+	encoded := base64.StdEncoding.EncodeToString(testSummary().Report)[:76] + "\r\n"
+	if !strings.Contains(content, `filename="importReport.json"`) || !strings.Contains(content, encoded) {
+		t.Errorf("Expected the attached report in\n%s", content)
+	}
+}
+
+func TestRedactTarget(t *testing.T) {
+	if redacted := redactTarget("slack+https://hooks.slack.com/services/T/B/secret"); redacted != "slack+https://hooks.slack.com/..." {
+		t.Errorf("Unexpected redacted target %s", redacted)
+	}
+	if redacted := redactTarget("mailto:admin@example.com"); redacted != "mailto:admin@example.com" {
+		t.Errorf("Unexpected redacted target %s", redacted)
+	}
+}
@@ -0,0 +1,95 @@
+package placeholders
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// ColumnsFileName is the file of the export listing the columns added to the default placeholder columns
+const ColumnsFileName = "placeholderColumns.txt"
+
+// columns whose values are URLs, paths and pillars of the source server, in the format 'table.column'.
+// Placeholders are only replaced and substituted in these columns, the values of other columns are data.
+var defaultColumns = []string{
+	"rhncontentsource.source_url",
+	"rhnkickstartabletree.base_path",
+	"rhnkickstartcommand.arguments",
+	"suseimagestore.uri",
+	"susesaltpillar.pillar",
+}
+
+var columnPattern = regexp.MustCompile(`^[a-z_][a-z0-9_]*\.[a-z_][a-z0-9_]*$`)
+
+// ColumnSet is the set of the columns with placeholders, in the format 'table.column'
+type ColumnSet map[string]bool
+
+// NewColumnSet returns the default placeholder columns with the added ones, or false if a column has no
+// 'table.column' format
+func NewColumnSet(added []string) (ColumnSet, bool) {
+	result := make(ColumnSet)
+	for _, column := range defaultColumns {
+		result[column] = true
+	}
+	for _, column := range added {
+		column = strings.ToLower(strings.TrimSpace(column))
+		if len(column) == 0 {
+			continue
+		}
+		if !columnPattern.MatchString(column) {
+			return nil, false
+		}
+		result[column] = true
+	}
+	return result, true
+}
+
+// Contains checks if the values of the column of the table can contain placeholders
+func (columns ColumnSet) Contains(tableName string, columnName string) bool {
+	return columns[tableName+"."+columnName]
+}
+
+// ContainsColumnName checks if the values of the column can contain placeholders in any table
+func (columns ColumnSet) ContainsColumnName(columnName string) bool {
+	for column := range columns {
+		if strings.HasSuffix(column, "."+columnName) {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteColumns stores the columns added to the default placeholder columns, if any
+func WriteColumns(outputFolderAbs string, columns ColumnSet) {
+	added := make([]string, 0)
+	for column := range columns {
+		if !utils.Contains(defaultColumns, column) {
+			added = append(added, column+"\n")
+		}
+	}
+	if len(added) == 0 {
+		return
+	}
+	sort.Strings(added)
+	if err := os.WriteFile(fmt.Sprintf("%s/%s", outputFolderAbs, ColumnsFileName), []byte(strings.Join(added, "")), 0644); err != nil {
+		utils.Panic().Err(err).Msg("error creating placeholder columns file")
+	}
+}
+
+// ReadColumns returns the placeholder columns of an export
+func ReadColumns(absImportDir string) ColumnSet {
+	added := make([]string, 0)
+	columnsFile := fmt.Sprintf("%s/%s", absImportDir, ColumnsFileName)
+	if _, err := os.Stat(columnsFile); err == nil {
+		added = utils.ReadFileByLine(columnsFile)
+	}
+	columns, ok := NewColumnSet(added)
+	if !ok {
+		utils.Fatal().Msgf("Invalid placeholder columns in %s", columnsFile)
+	}
+	return columns
+}
@@ -0,0 +1,128 @@
+package placeholders
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// Placeholders replace strings specific to the source server in the exported data,
+// and are substituted with the values of the target server on import.
+const (
+	ServerFQDN = "SERVER_FQDN"
+	MountPoint = "MOUNT_POINT"
+)
+
+// FileName is the file of the export listing the placeholders used in it
+const FileName = "placeholders.txt"
+
+var tokenPattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+// Format returns the placeholder written in the exported data for a token
+func Format(token string) string {
+	return "{" + token + "}"
+}
+
+// DetectValue returns the value of a token read from the server configuration
+func DetectValue(token string, serverConfig string) (string, bool) {
+	value := ""
+	switch token {
+	case ServerFQDN:
+		value = utils.GetCurrentServerFQDN(serverConfig)
+	case MountPoint:
+		value = utils.GetCurrentServerMountPoint(serverConfig)
+	}
+	return value, len(value) > 0
+}
+
+// ParseValues reads the TOKEN=value entries of the placeholders, indexed by token.
+// Tokens without a value take the value detected from the server configuration.
+func ParseValues(entries []string, serverConfig string) (map[string]string, bool) {
+	result := make(map[string]string)
+	for _, entry := range entries {
+		token, value := entry, ""
+		separator := strings.Index(entry, "=")
+		if separator >= 0 {
+			token, value = entry[:separator], entry[separator+1:]
+		}
+		token = strings.TrimSpace(token)
+		if !tokenPattern.MatchString(token) {
+			return nil, false
+		}
+		if separator < 0 {
+			detected, ok := DetectValue(token, serverConfig)
+			if !ok {
+				log.Error().Msgf("Value of placeholder %s cannot be detected", token)
+				return nil, false
+			}
+			value = detected
+		}
+		if len(value) == 0 {
+			return nil, false
+		}
+		result[token] = value
+	}
+	return result, true
+}
+
+// Replace replaces the values in the text with their placeholders, longest values first
+// so values containing other ones are replaced as a whole
+func Replace(text string, values map[string]string) string {
+	tokens := make([]string, 0, len(values))
+	for token := range values {
+		tokens = append(tokens, token)
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		if len(values[tokens[i]]) != len(values[tokens[j]]) {
+			return len(values[tokens[i]]) > len(values[tokens[j]])
+		}
+		return tokens[i] < tokens[j]
+	})
+	for _, token := range tokens {
+		text = strings.ReplaceAll(text, values[token], Format(token))
+	}
+	return text
+}
+
+// Substitute replaces the placeholders in the text with their values
+func Substitute(text string, values map[string]string) string {
+	if !strings.Contains(text, "{") {
+		return text
+	}
+	for token, value := range values {
+		text = strings.ReplaceAll(text, Format(token), value)
+	}
+	return text
+}
+
+// WriteTokens stores the tokens of the placeholders used in the export
+func WriteTokens(outputFolderAbs string, values map[string]string) {
+	tokens := make([]string, 0, len(values))
+	for token := range values {
+		tokens = append(tokens, token+"\n")
+	}
+	sort.Strings(tokens)
+	if err := os.WriteFile(fmt.Sprintf("%s/%s", outputFolderAbs, FileName), []byte(strings.Join(tokens, "")), 0644); err != nil {
+		utils.Panic().Err(err).Msg("error creating placeholders file")
+	}
+}
+
+// ReadTokens returns the tokens of the placeholders used in an export
+func ReadTokens(absImportDir string) []string {
+	tokensFile := fmt.Sprintf("%s/%s", absImportDir, FileName)
+	if _, err := os.Stat(tokensFile); err != nil {
+		return nil
+	}
+	tokens := make([]string, 0)
+	for _, token := range utils.ReadFileByLine(tokensFile) {
+		if token = strings.TrimSpace(token); len(token) > 0 {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,52 @@
+package placeholders
+
+import "testing"
+
+func TestReplaceAndSubstitute(t *testing.T) {
+	values := map[string]string{
+		ServerFQDN: "hub.example.com",
+		"REPO_URL": "https://hub.example.com/repo",
+	}
+	text := "https://hub.example.com/repo/sles and https://hub.example.com/os-images/"
+	replaced := Replace(text, values)
+	expected := "{REPO_URL}/sles and https://{SERVER_FQDN}/os-images/"
+	if replaced != expected {
+		t.Errorf("Expected %s, got %s", expected, replaced)
+	}
+
+	targetValues := map[string]string{ServerFQDN: "peripheral.example.com", "REPO_URL": "http://mirror/repo"}
+	substituted := Substitute(replaced, targetValues)
+	expected = "http://mirror/repo/sles and https://peripheral.example.com/os-images/"
+	if substituted != expected {
+		t.Errorf("Expected %s, got %s", expected, substituted)
+	}
+}
+
+func TestParseValues(t *testing.T) {
+	values, ok := ParseValues([]string{"ORG_NAME=Engineering", "DATA_PATH=/srv/data"}, "")
+	if !ok || values["ORG_NAME"] != "Engineering" || values["DATA_PATH"] != "/srv/data" {
+		t.Errorf("Unexpected placeholder values: %v", values)
+	}
+	if _, ok := ParseValues([]string{"lowercase=value"}, ""); ok {
+		t.Errorf("Invalid token accepted")
+	}
+	if _, ok := ParseValues([]string{"EMPTY="}, ""); ok {
+		t.Errorf("Empty value accepted")
+	}
+}
+
+func TestNewColumnSet(t *testing.T) {
+	columns, ok := NewColumnSet([]string{"rhnChannel.Summary", ""})
+	if !ok {
+		t.Fatalf("Valid placeholder column refused")
+	}
+	if !columns.Contains("rhnchannel", "summary") || !columns.Contains("rhncontentsource", "source_url") {
+		t.Errorf("Unexpected placeholder columns: %v", columns)
+	}
+	if !columns.ContainsColumnName("source_url") || columns.ContainsColumnName("label") {
+		t.Errorf("Unexpected placeholder column names: %v", columns)
+	}
+	if _, ok := NewColumnSet([]string{"summary"}); ok {
+		t.Errorf("Column without table accepted")
+	}
+}
@@ -4,13 +4,35 @@ const (
 	ReadTableNames = `SELECT table_name
 		FROM information_schema.tables
 		WHERE table_schema = 'public'
-			AND table_type = 'BASE TABLE';`
+			AND table_type = 'BASE TABLE'
+			AND table_name NOT IN (SELECT c.relname FROM pg_inherits i JOIN pg_class c ON c.oid = i.inhrelid);`
 
 	ReadColumnNames = `SELECT column_name
 		FROM information_schema.columns
 		WHERE table_schema = 'public' AND table_name = $1
 		ORDER BY ordinal_position;`
 
+	ReadNullableColumnNames = `SELECT column_name
+		FROM information_schema.columns
+		WHERE table_schema = 'public' AND table_name = $1 AND is_nullable = 'YES'
+		ORDER BY ordinal_position;`
+
+	ReadLargeObjectColumnNames = `SELECT column_name
+		FROM information_schema.columns
+		WHERE table_schema = 'public' AND table_name = $1
+			AND (data_type = 'oid' OR domain_name = 'lo')
+		ORDER BY ordinal_position;`
+
+	ReadGeneratedColumns = `SELECT a.attname, a.attidentity, COALESCE(pg_get_serial_sequence(quote_ident(c.relname), a.attname), ''),
+			COALESCE(pg_get_expr(d.adbin, d.adrelid), '')
+		FROM pg_attribute a
+			JOIN pg_class c ON c.oid = a.attrelid
+			JOIN pg_namespace n ON n.oid = c.relnamespace
+			LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+		WHERE n.nspname = 'public' AND c.relname = $1 AND a.attnum > 0 AND NOT a.attisdropped
+			AND (a.attidentity <> '' OR pg_get_expr(d.adbin, d.adrelid) LIKE 'nextval(%')
+		ORDER BY a.attnum;`
+
 	ReadPkColumnNames = `SELECT a.attname
 		FROM pg_index i
 		JOIN pg_attribute a ON a.attrelid = i.indrelid
@@ -23,25 +45,41 @@ const (
 		JOIN pg_attribute a ON a.attrelid = i.indrelid
 			AND a.attnum = ANY(i.indkey)
 		WHERE i.indrelid = $1::regclass
-		AND i.indisunique AND NOT i.indisprimary;`
+		AND i.indisunique AND NOT i.indisprimary
+		ORDER BY 1;`
 
-	ReadIndexColumns = `SELECT DISTINCT a.attname
+	ReadIndexColumns = `SELECT a.attname
 		FROM pg_index i
 		JOIN pg_attribute a ON a.attrelid = i.indrelid
 			AND a.attnum = ANY(i.indkey)
-		WHERE indexrelid::regclass = $1::regclass;`
+		WHERE indexrelid::regclass = $1::regclass
+		GROUP BY a.attname
+		ORDER BY min(array_position(i.indkey::int2[], a.attnum));`
+
+	ReadPartitionParent = `SELECT p.relname
+		FROM pg_inherits i
+		JOIN pg_class p ON p.oid = i.inhparent
+		WHERE i.inhrelid = $1::regclass;`
+
+	ReadPartitionChildren = `SELECT c.relname
+		FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = $1::regclass
+		ORDER BY c.relname;`
 
 	ReadReferenceConstraintNames = `SELECT DISTINCT tc.constraint_name
 		FROM information_schema.table_constraints AS tc
 			JOIN information_schema.constraint_column_usage AS ccu ON ccu.constraint_name = tc.constraint_name
 				AND ccu.table_schema = tc.table_schema
-		WHERE tc.constraint_type = 'FOREIGN KEY' AND tc.table_name = $1;`
+		WHERE tc.constraint_type = 'FOREIGN KEY' AND tc.table_name = $1
+		ORDER BY tc.constraint_name;`
 
 	ReadReferencedByConstraintNames = `SELECT DISTINCT tc.constraint_name
 		FROM information_schema.table_constraints AS tc
 			JOIN information_schema.constraint_column_usage AS ccu ON ccu.constraint_name = tc.constraint_name
 				AND ccu.table_schema = tc.table_schema
-		WHERE tc.constraint_type = 'FOREIGN KEY' AND ccu.table_name = $1;`
+		WHERE tc.constraint_type = 'FOREIGN KEY' AND ccu.table_name = $1
+		ORDER BY tc.constraint_name;`
 
 	ReadReferencedTable = `SELECT DISTINCT ccu.table_name
 	FROM information_schema.constraint_column_usage AS ccu
@@ -62,6 +100,11 @@ const (
 			AND tc.table_name = $1
 			AND tc.constraint_name = $2;`
 
+	ReadCheckConstraints = `SELECT conname, pg_get_constraintdef(oid)
+		FROM pg_constraint
+		WHERE contype = 'c' AND conrelid = $1::regclass
+		ORDER BY conname;`
+
 	ReadPkSequence = `WITH sequences AS (
 		SELECT sequence_name
 			FROM information_schema.sequences
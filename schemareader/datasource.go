@@ -5,9 +5,12 @@ import (
 	"database/sql"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
+	"time"
 
-	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+	"github.com/uyuni-project/inter-server-sync/utils"
 )
 
 type dataSource struct {
@@ -16,13 +19,42 @@ type dataSource struct {
 	dbname   string
 	user     string
 	password string
+	// TLS settings of the db_ssl_enabled and db_sslrootcert keys, no TLS when disabled
+	sslEnabled  bool
+	sslRootCert string
 }
 
-// GetConnectionString return the connection string for the database after reading config file for
-func GetConnectionString(configFilePath string) string {
+// sslMode returns the libpq SSL mode of the configuration: TLS connections check the server certificate,
+// since servers enabling them provide the CA certificate
+func (dataSource *dataSource) sslMode() string {
+	if !dataSource.sslEnabled {
+		return "disable"
+	}
+	return "verify-full"
+}
+
+// keys prefixes of the main database and of the reporting database in the server configuration file
+const (
+	mainDbKeyPrefix   = "db_"
+	reportDbKeyPrefix = "report_db_"
+)
+
+func readDataSource(configFilePath string) *dataSource {
+	return readPrefixedDataSource(configFilePath, mainDbKeyPrefix)
+}
+
+func readPrefixedDataSource(configFilePath string, prefix string) *dataSource {
+	dataSource, err := parseDataSource(configFilePath, prefix)
+	if err != nil {
+		utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitConfigError).Msg("error loading configuration file")
+	}
+	return dataSource
+}
+
+func parseDataSource(configFilePath string, prefix string) (*dataSource, error) {
 	file, err := os.Open(configFilePath)
 	if err != nil {
-		log.Panic().Err(err).Msg("error loading configuration file")
+		return nil, err
 	}
 	defer file.Close()
 
@@ -35,31 +67,131 @@ func GetConnectionString(configFilePath string) string {
 			if len(line) > equal {
 				value = strings.TrimSpace(line[equal+1:])
 			}
-			if key := strings.TrimSpace(line[:equal]); len(key) > 0 {
-				switch key {
-				case "db_host":
+			if key := strings.TrimSpace(line[:equal]); len(key) > 0 && strings.HasPrefix(key, prefix) {
+				switch strings.TrimPrefix(key, prefix) {
+				case "host":
 					dataSource.host = value
-				case "db_port":
+				case "port":
 					dataSource.port = value
-				case "db_name":
+				case "name":
 					dataSource.dbname = value
-				case "db_user":
+				case "user":
 					dataSource.user = value
-				case "db_password":
+				case "password":
 					dataSource.password = value
+				case "ssl_enabled":
+					dataSource.sslEnabled = value == "1" || strings.EqualFold(value, "true") || strings.EqualFold(value, "yes")
+				case "sslrootcert":
+					dataSource.sslRootCert = value
 
 				}
 			}
 		}
 	}
-	return fmt.Sprintf("user='%s' password='%s' dbname='%s' host='%s' port='%s' sslmode=disable", dataSource.user, dataSource.password, dataSource.dbname, dataSource.host, dataSource.port)
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return dataSource, nil
+}
+
+// CheckDataSource checks the configuration file defines the database connection
+func CheckDataSource(configFilePath string) error {
+	dataSource, err := parseDataSource(configFilePath, mainDbKeyPrefix)
+	if err != nil {
+		return err
+	}
+	missing := make([]string, 0)
+	for key, value := range map[string]string{"db_name": dataSource.dbname, "db_user": dataSource.user} {
+		if len(value) == 0 {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("%s does not define %s", configFilePath, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// GetConnectionString return the connection string for the database after reading config file for
+func GetConnectionString(configFilePath string) string {
+	return formatConnectionString(readDataSource(configFilePath))
+}
+
+func formatConnectionString(dataSource *dataSource) string {
+	connectionString := fmt.Sprintf("user='%s' password='%s' dbname='%s' host='%s' port='%s' sslmode=%s", dataSource.user, dataSource.password, dataSource.dbname, dataSource.host, dataSource.port, dataSource.sslMode())
+	if dataSource.sslEnabled && len(dataSource.sslRootCert) > 0 {
+		connectionString += fmt.Sprintf(" sslrootcert='%s'", dataSource.sslRootCert)
+	}
+	if timeout := connectTimeoutSeconds(); len(timeout) > 0 {
+		connectionString += " connect_timeout=" + timeout
+	}
+	return connectionString
+}
+
+// connectTimeoutSeconds returns the connection timeout in the libpq format, empty when connections don't time out
+func connectTimeoutSeconds() string {
+	timeout := sqlUtil.ConnectTimeout()
+	if timeout <= 0 {
+		return ""
+	}
+	// libpq rounds down, and ignores values below 2 seconds
+	seconds := int64((timeout + time.Second - 1) / time.Second)
+	if seconds < 2 {
+		seconds = 2
+	}
+	return fmt.Sprintf("%d", seconds)
+}
+
+// GetConnectionEnvironment return the libpq environment variables to connect to the database with external tools
+func GetConnectionEnvironment(configFilePath string) []string {
+	dataSource := readDataSource(configFilePath)
+	environment := []string{
+		"PGHOST=" + dataSource.host,
+		"PGPORT=" + dataSource.port,
+		"PGDATABASE=" + dataSource.dbname,
+		"PGUSER=" + dataSource.user,
+		"PGPASSWORD=" + dataSource.password,
+		"PGSSLMODE=" + dataSource.sslMode(),
+	}
+	if dataSource.sslEnabled && len(dataSource.sslRootCert) > 0 {
+		environment = append(environment, "PGSSLROOTCERT="+dataSource.sslRootCert)
+	}
+	if timeout := connectTimeoutSeconds(); len(timeout) > 0 {
+		environment = append(environment, "PGCONNECT_TIMEOUT="+timeout)
+	}
+	return environment
 }
 
 //GetDBconnection return the database connection
 func GetDBconnection(configFilePath string) *sql.DB {
 	db, err := sql.Open("postgres", GetConnectionString(configFilePath))
 	if err != nil {
-		log.Panic().Err(err).Msg("error getting connection to the database")
+		utils.Panic().Err(err).Msg("error getting connection to the database")
+	}
+	return db
+}
+
+// HasReportDB tells if the configuration file defines the connection of the reporting database
+func HasReportDB(configFilePath string) bool {
+	dataSource, err := parseDataSource(configFilePath, reportDbKeyPrefix)
+	return err == nil && len(dataSource.dbname) > 0 && len(dataSource.user) > 0
+}
+
+// GetReportDBconnection returns the connection to the reporting database (reportdb) of the server
+func GetReportDBconnection(configFilePath string) *sql.DB {
+	db, err := sql.Open("postgres", formatConnectionString(readPrefixedDataSource(configFilePath, reportDbKeyPrefix)))
+	if err != nil {
+		utils.Panic().Err(err).Msg("error getting connection to the reporting database")
+	}
+	return db
+}
+
+//GetReadOnlyDBconnection return a database connection which cannot modify data
+func GetReadOnlyDBconnection(configFilePath string) *sql.DB {
+	db, err := sql.Open("postgres", GetConnectionString(configFilePath)+" default_transaction_read_only=on")
+	if err != nil {
+		utils.Panic().Err(err).Msg("error getting connection to the database")
 	}
 	return db
 }
@@ -0,0 +1,91 @@
+package schemareader
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+)
+
+func TestCheckDataSource(t *testing.T) {
+	configFile := path.Join(t.TempDir(), "rhn.conf")
+	if err := CheckDataSource(configFile); err == nil {
+		t.Error("missing configuration file should fail")
+	}
+
+	if err := os.WriteFile(configFile, []byte("db_host = localhost\ndb_name = susemanager\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckDataSource(configFile); err == nil || err.Error() != configFile+" does not define db_user" {
+		t.Errorf("missing db_user should be reported, got %v", err)
+	}
+
+	if err := os.WriteFile(configFile, []byte("db_name = susemanager\ndb_user = spacewalk\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckDataSource(configFile); err != nil {
+		t.Errorf("valid configuration should pass, got %v", err)
+	}
+}
+
+func TestConnectTimeoutSeconds(t *testing.T) {
+	defer sqlUtil.SetTimeouts(sqlUtil.Timeouts{})
+	for timeout, expected := range map[time.Duration]string{0: "", time.Second: "2", 30 * time.Second: "30", 1500 * time.Millisecond: "2", 2500 * time.Millisecond: "3"} {
+		sqlUtil.SetTimeouts(sqlUtil.Timeouts{Connect: timeout})
+		if actual := connectTimeoutSeconds(); actual != expected {
+			t.Errorf("Expected %q for %s, got %q", expected, timeout, actual)
+		}
+	}
+}
+
+func TestConnectionSslMode(t *testing.T) {
+	configFile := path.Join(t.TempDir(), "rhn.conf")
+	if err := os.WriteFile(configFile, []byte("db_name = susemanager\ndb_user = spacewalk\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if connection := GetConnectionString(configFile); !strings.Contains(connection, "sslmode=disable") {
+		t.Errorf("Expected connections without TLS by default, got %s", connection)
+	}
+
+	content := "db_name = susemanager\ndb_user = spacewalk\ndb_ssl_enabled = 1\ndb_sslrootcert = /etc/pki/trust/anchors/LOCAL-RHN-ORG-TRUSTED-SSL-CERT\n"
+	if err := os.WriteFile(configFile, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	connection := GetConnectionString(configFile)
+	if !strings.Contains(connection, "sslmode=verify-full sslrootcert='/etc/pki/trust/anchors/LOCAL-RHN-ORG-TRUSTED-SSL-CERT'") {
+		t.Errorf("Expected verified TLS connections, got %s", connection)
+	}
+	environment := strings.Join(GetConnectionEnvironment(configFile), " ")
+	if !strings.Contains(environment, "PGSSLMODE=verify-full PGSSLROOTCERT=/etc/pki/trust/anchors/LOCAL-RHN-ORG-TRUSTED-SSL-CERT") {
+		t.Errorf("Expected the TLS settings in the environment, got %s", environment)
+	}
+}
+
+func TestReportDataSource(t *testing.T) {
+	configFile := path.Join(t.TempDir(), "rhn.conf")
+	if err := os.WriteFile(configFile, []byte("db_name = susemanager\ndb_user = spacewalk\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if HasReportDB(configFile) {
+		t.Error("Expected no reporting database without report_db keys")
+	}
+
+	content := "db_name = susemanager\ndb_user = spacewalk\nreport_db_host = reportdb.example.com\nreport_db_port = 5432\n" +
+		"report_db_name = reportdb\nreport_db_user = pythia\nreport_db_password = secret\n"
+	if err := os.WriteFile(configFile, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if !HasReportDB(configFile) {
+		t.Error("Expected the reporting database of the report_db keys")
+	}
+	expected := "user='pythia' password='secret' dbname='reportdb' host='reportdb.example.com' port='5432' sslmode=disable"
+	if connection := formatConnectionString(readPrefixedDataSource(configFile, reportDbKeyPrefix)); connection != expected {
+		t.Errorf("Expected %s, got %s", expected, connection)
+	}
+	if connection := GetConnectionString(configFile); !strings.Contains(connection, "dbname='susemanager'") {
+		t.Errorf("Expected the main database, got %s", connection)
+	}
+}
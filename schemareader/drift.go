@@ -0,0 +1,69 @@
+package schemareader
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SchemaDifference describes a table definition which is not the same on the source and on the target
+type SchemaDifference struct {
+	TableName string
+	// true when the difference makes the import of the table fail
+	Blocking    bool
+	Description string
+}
+
+func (difference SchemaDifference) String() string {
+	return fmt.Sprintf("%s: %s", difference.TableName, difference.Description)
+}
+
+// CompareSchemas compares the definitions of the source tables with the target ones.
+// Tables and columns only present on the source cannot be imported, while columns only present on the
+// target are filled with their default value.
+func CompareSchemas(source map[string]Table, target map[string]Table) []SchemaDifference {
+	tableNames := make([]string, 0, len(source))
+	for tableName := range source {
+		tableNames = append(tableNames, tableName)
+	}
+	sort.Strings(tableNames)
+
+	differences := make([]SchemaDifference, 0)
+	for _, tableName := range tableNames {
+		sourceTable := source[tableName]
+		targetTable, ok := target[tableName]
+		if !ok {
+			differences = append(differences, SchemaDifference{tableName, true, "table missing on target"})
+			continue
+		}
+		for _, column := range columnsMissingIn(sourceTable, targetTable) {
+			differences = append(differences, SchemaDifference{tableName, true, fmt.Sprintf("column %s missing on target", column)})
+		}
+		for _, column := range columnsMissingIn(targetTable, sourceTable) {
+			differences = append(differences, SchemaDifference{tableName, false, fmt.Sprintf("column %s missing on source", column)})
+		}
+		sourceIndex := sourceTable.UniqueIndexes[sourceTable.MainUniqueIndexName].Columns
+		targetIndex := targetTable.UniqueIndexes[targetTable.MainUniqueIndexName].Columns
+		if strings.Join(sourceIndex, ",") != strings.Join(targetIndex, ",") {
+			differences = append(differences, SchemaDifference{tableName, true,
+				fmt.Sprintf("main unique index is (%s) on source and (%s) on target",
+					strings.Join(sourceIndex, ", "), strings.Join(targetIndex, ", "))})
+		}
+	}
+	return differences
+}
+
+// columnsMissingIn returns the columns of the table which the other table doesn't have
+func columnsMissingIn(table Table, other Table) []string {
+	otherColumns := make(map[string]bool)
+	for _, column := range other.Columns {
+		otherColumns[column] = true
+	}
+	missing := make([]string, 0)
+	for _, column := range table.Columns {
+		if !otherColumns[column] {
+			missing = append(missing, column)
+		}
+	}
+	return missing
+}
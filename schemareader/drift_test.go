@@ -0,0 +1,30 @@
+package schemareader
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompareSchemas(t *testing.T) {
+	index := map[string]UniqueIndex{VirtualIndexName: {Name: VirtualIndexName, Columns: []string{"label"}}}
+	source := map[string]Table{
+		"rhnchannel": {Name: "rhnchannel", Columns: []string{"id", "label", "gpg_check"},
+			MainUniqueIndexName: VirtualIndexName, UniqueIndexes: index},
+		"susechannelextra": {Name: "susechannelextra", Columns: []string{"id"}},
+	}
+	target := map[string]Table{
+		"rhnchannel": {Name: "rhnchannel", Columns: []string{"id", "label", "summary"},
+			MainUniqueIndexName: VirtualIndexName, UniqueIndexes: index},
+	}
+
+	differences := CompareSchemas(source, target)
+
+	expected := []SchemaDifference{
+		{"rhnchannel", true, "column gpg_check missing on target"},
+		{"rhnchannel", false, "column summary missing on source"},
+		{"susechannelextra", true, "table missing on target"},
+	}
+	if !reflect.DeepEqual(differences, expected) {
+		t.Errorf("Expected %v, got %v", expected, differences)
+	}
+}
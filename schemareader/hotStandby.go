@@ -0,0 +1,69 @@
+package schemareader
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// hotStandbySettings returns the session settings of connections to a source which may be a streaming replica
+func hotStandbySettings(statementTimeout time.Duration) map[string]string {
+	return map[string]string{
+		"default_transaction_read_only": "on",
+		"statement_timeout":             fmt.Sprintf("%d", statementTimeout.Milliseconds()),
+	}
+}
+
+// GetHotStandbyDBconnection return a database connection which cannot modify data and cancels the statements
+// running longer than the timeout, so it can be used on a hot standby server
+func GetHotStandbyDBconnection(configFilePath string, statementTimeout time.Duration) *sql.DB {
+	db, err := sql.Open("postgres", GetHotStandbyConnectionString(configFilePath, statementTimeout))
+	if err != nil {
+		utils.Panic().Err(err).Msg("error getting connection to the database")
+	}
+	return db
+}
+
+// GetHotStandbyConnectionString returns the connection string of GetHotStandbyDBconnection
+func GetHotStandbyConnectionString(configFilePath string, statementTimeout time.Duration) string {
+	connectionString := GetConnectionString(configFilePath)
+	settings := hotStandbySettings(statementTimeout)
+	for _, name := range []string{"default_transaction_read_only", "statement_timeout"} {
+		connectionString += fmt.Sprintf(" %s=%s", name, settings[name])
+	}
+	return connectionString
+}
+
+// GetHotStandbyConnectionEnvironment return the libpq environment variables to connect to a hot standby server
+// with external tools, with the same settings as GetHotStandbyDBconnection
+func GetHotStandbyConnectionEnvironment(configFilePath string, statementTimeout time.Duration) []string {
+	settings := hotStandbySettings(statementTimeout)
+	return append(GetConnectionEnvironment(configFilePath), fmt.Sprintf("PGOPTIONS=-c default_transaction_read_only=%s -c statement_timeout=%s",
+		settings["default_transaction_read_only"], settings["statement_timeout"]))
+}
+
+// VerifyHotStandbyConnection checks the session cannot write and has a statement timeout
+func VerifyHotStandbyConnection(db *sql.DB) error {
+	var readOnly, statementTimeout string
+	var inRecovery bool
+	err := db.QueryRowContext(utils.Context(), `SELECT current_setting('transaction_read_only'), current_setting('statement_timeout'),
+		pg_is_in_recovery();`).Scan(&readOnly, &statementTimeout, &inRecovery)
+	if err != nil {
+		return err
+	}
+	if readOnly != "on" {
+		return fmt.Errorf("source connection is not read-only")
+	}
+	if statementTimeout == "0" {
+		return fmt.Errorf("source connection has no statement timeout")
+	}
+	if inRecovery {
+		log.Info().Msgf("Exporting from a hot standby server, statement timeout %s", statementTimeout)
+	} else {
+		log.Info().Msgf("Exporting read-only from a primary server, statement timeout %s", statementTimeout)
+	}
+	return nil
+}
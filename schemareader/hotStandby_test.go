@@ -0,0 +1,22 @@
+package schemareader
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestGetHotStandbyConnectionEnvironment(t *testing.T) {
+	configFile := path.Join(t.TempDir(), "rhn.conf")
+	if err := os.WriteFile(configFile, []byte("db_name = susemanager\ndb_user = spacewalk\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	environment := GetHotStandbyConnectionEnvironment(configFile, 90*time.Second)
+
+	expected := "PGOPTIONS=-c default_transaction_read_only=on -c statement_timeout=90000"
+	if environment[len(environment)-1] != expected {
+		t.Errorf("expected %s, got %s", expected, environment[len(environment)-1])
+	}
+}
@@ -0,0 +1,21 @@
+package schemareader
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// DumpToJSON outputs the schema as JSON, with the table filters already applied.
+// Row modification callbacks are code and are not part of the output.
+func DumpToJSON(writer io.Writer, tables map[string]Table) error {
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(tables)
+}
+
+// ReadFromJSON reads a schema printed by DumpToJSON
+func ReadFromJSON(reader io.Reader) (map[string]Table, error) {
+	tables := make(map[string]Table)
+	err := json.NewDecoder(reader).Decode(&tables)
+	return tables, err
+}
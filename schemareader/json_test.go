@@ -0,0 +1,36 @@
+package schemareader
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+)
+
+func TestDumpToJSON(t *testing.T) {
+	tables := map[string]Table{
+		"rhnpackagename": {
+			Name:                "rhnpackagename",
+			Columns:             []string{"id", "name"},
+			MainUniqueIndexName: VirtualIndexName,
+			UniqueIndexes:       map[string]UniqueIndex{VirtualIndexName: {Name: VirtualIndexName, Columns: []string{"name"}}},
+			RowModCallback: func(value []sqlUtil.RowDataStructure, table Table) []sqlUtil.RowDataStructure {
+				return value
+			},
+		},
+	}
+
+	var buffer bytes.Buffer
+	if err := DumpToJSON(&buffer, tables); err != nil {
+		t.Fatalf("Error dumping schema: %s", err)
+	}
+
+	var result map[string]Table
+	if err := json.Unmarshal(buffer.Bytes(), &result); err != nil {
+		t.Fatalf("Error reading dumped schema: %s", err)
+	}
+	if result["rhnpackagename"].UniqueIndexes[VirtualIndexName].Columns[0] != "name" {
+		t.Errorf("Virtual index missing in dumped schema: %s", buffer.String())
+	}
+}
@@ -0,0 +1,74 @@
+package schemareader
+
+import (
+	"encoding/json"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/placeholders"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// PillarRewriteRule replaces the matches of a regular expression in the exported pillars of a category,
+// to normalize host specific URLs and paths
+type PillarRewriteRule struct {
+	// prefix of the pillar categories the rule applies to
+	Category    string `json:"category"`
+	Regex       string `json:"regex"`
+	Replacement string `json:"replacement"`
+	pattern     *regexp.Regexp
+}
+
+// image pillars refer to the server the images are downloaded from
+var defaultPillarRewriteRules = []PillarRewriteRule{
+	{
+		Category:    "Image",
+		Regex:       `https://[^/]+/os-images/`,
+		Replacement: "https://" + placeholders.Format(placeholders.ServerFQDN) + "/os-images/",
+		pattern:     regexp.MustCompile(`https://[^/]+/os-images/`),
+	},
+}
+
+var pillarRewriteRules = defaultPillarRewriteRules
+
+// ReadPillarRewriteRules reads a JSON file with a list of rules
+func ReadPillarRewriteRules(path string) []PillarRewriteRule {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		utils.Fatal().Err(err).Msgf("Error reading pillar rewrite rules %s", path)
+	}
+	rules := make([]PillarRewriteRule, 0)
+	if err := json.Unmarshal(content, &rules); err != nil {
+		utils.Fatal().Err(err).Msgf("Error parsing pillar rewrite rules %s", path)
+	}
+	for i, rule := range rules {
+		pattern, err := regexp.Compile(rule.Regex)
+		if err != nil {
+			utils.Fatal().Err(err).Msgf("Invalid regular expression in pillar rewrite rule: %s", rule.Regex)
+		}
+		rules[i].pattern = pattern
+	}
+	return rules
+}
+
+// AddPillarRewriteRules adds rules applied to the exported pillars after the default ones
+func AddPillarRewriteRules(rules []PillarRewriteRule) {
+	pillarRewriteRules = append(pillarRewriteRules, rules...)
+}
+
+// ResetPillarRewriteRules removes the rules added to the default ones
+func ResetPillarRewriteRules() {
+	pillarRewriteRules = defaultPillarRewriteRules
+}
+
+func rewritePillar(category string, pillar []byte) []byte {
+	for _, rule := range pillarRewriteRules {
+		if strings.HasPrefix(category, rule.Category) {
+			log.Trace().Msgf("Rewriting pillar %s with %s", category, rule.pattern)
+			pillar = rule.pattern.ReplaceAll(pillar, []byte(rule.Replacement))
+		}
+	}
+	return pillar
+}
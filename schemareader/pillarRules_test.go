@@ -0,0 +1,34 @@
+package schemareader
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+func TestRewritePillar(t *testing.T) {
+	rulesFile := path.Join(t.TempDir(), "rules.json")
+	rules := `[{"category": "formula-", "regex": "http://[^/:]+:8080/", "replacement": "http://{SERVER_FQDN}:8080/"}]`
+	if err := os.WriteFile(rulesFile, []byte(rules), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defaultRules := pillarRewriteRules
+	defer func() { pillarRewriteRules = defaultRules }()
+	AddPillarRewriteRules(ReadPillarRewriteRules(rulesFile))
+
+	cases := []struct {
+		category string
+		pillar   string
+		expected string
+	}{
+		{"Image1", `{"url": "https://hub.example.com/os-images/1/image.tgz"}`,
+			`{"url": "https://{SERVER_FQDN}/os-images/1/image.tgz"}`},
+		{"formula-proxy", `{"url": "http://hub.example.com:8080/api"}`, `{"url": "http://{SERVER_FQDN}:8080/api"}`},
+		{"custom_info", `{"url": "http://hub.example.com:8080/api"}`, `{"url": "http://hub.example.com:8080/api"}`},
+	}
+	for _, c := range cases {
+		if result := string(rewritePillar(c.category, []byte(c.pillar))); result != c.expected {
+			t.Errorf("Rewriting %s pillar: expected %s, got %s", c.category, c.expected, result)
+		}
+	}
+}
@@ -2,20 +2,25 @@ package schemareader
 
 import (
 	"database/sql"
+	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/tracing"
+	"github.com/uyuni-project/inter-server-sync/utils"
 )
 
 func readTableNames(db *sql.DB) []string {
 	sql := `SELECT table_name
 		FROM information_schema.tables
 		WHERE table_schema = 'public'
-			AND table_type = 'BASE TABLE';`
+			AND table_type = 'BASE TABLE'
+			AND table_name NOT IN (SELECT c.relname FROM pg_inherits i JOIN pg_class c ON c.oid = i.inhrelid);`
 
-	rows, err := db.Query(sql)
+	rows, err := db.QueryContext(utils.Context(), sql)
 	if err != nil {
-		log.Panic().Err(err).Msg("error executing database query")
+		utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("error executing database query")
 	}
 
 	result := make([]string, 0)
@@ -23,7 +28,7 @@ func readTableNames(db *sql.DB) []string {
 		var tableName string
 		err := rows.Scan(&tableName)
 		if err != nil {
-			log.Panic().Err(err).Msg("error extracting row")
+			utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("error extracting row")
 		}
 		result = append(result, tableName)
 	}
@@ -31,15 +36,31 @@ func readTableNames(db *sql.DB) []string {
 	return result
 }
 
+// UnknownTables returns the table names which are not tables of the database, sorted
+func UnknownTables(db *sql.DB, tableNames []string) []string {
+	known := make(map[string]bool)
+	for _, tableName := range readTableNames(db) {
+		known[tableName] = true
+	}
+	result := make([]string, 0)
+	for _, tableName := range tableNames {
+		if !known[strings.ToLower(tableName)] {
+			result = append(result, tableName)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
 func readColumnNames(db *sql.DB, tableName string) []string {
 	sql := `SELECT column_name
 		FROM information_schema.columns
 		WHERE table_schema = 'public' AND table_name = $1
 		ORDER BY ordinal_position;`
 
-	rows, err := db.Query(sql, tableName)
+	rows, err := db.QueryContext(utils.Context(), sql, tableName)
 	if err != nil {
-		log.Panic().Err(err).Msg("error accessing the database")
+		utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("error accessing the database")
 	}
 	defer rows.Close()
 
@@ -48,7 +69,59 @@ func readColumnNames(db *sql.DB, tableName string) []string {
 		var columnName string
 		err := rows.Scan(&columnName)
 		if err != nil {
-			log.Panic().Err(err).Msg("error extracting row")
+			utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("error extracting row")
+		}
+		result = append(result, columnName)
+	}
+
+	return result
+}
+
+func readNullableColumnNames(db *sql.DB, tableName string) []string {
+	sql := `SELECT column_name
+		FROM information_schema.columns
+		WHERE table_schema = 'public' AND table_name = $1 AND is_nullable = 'YES'
+		ORDER BY ordinal_position;`
+
+	rows, err := db.QueryContext(utils.Context(), sql, tableName)
+	if err != nil {
+		utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("error accessing the database")
+	}
+	defer rows.Close()
+
+	result := make([]string, 0)
+	for rows.Next() {
+		var columnName string
+		err := rows.Scan(&columnName)
+		if err != nil {
+			utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("error extracting row")
+		}
+		result = append(result, columnName)
+	}
+
+	return result
+}
+
+// readLargeObjectColumnNames returns the columns holding references to large objects
+func readLargeObjectColumnNames(db *sql.DB, tableName string) []string {
+	sql := `SELECT column_name
+		FROM information_schema.columns
+		WHERE table_schema = 'public' AND table_name = $1
+			AND (data_type = 'oid' OR domain_name = 'lo')
+		ORDER BY ordinal_position;`
+
+	rows, err := db.QueryContext(utils.Context(), sql, tableName)
+	if err != nil {
+		utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("error accessing the database")
+	}
+	defer rows.Close()
+
+	result := make([]string, 0)
+	for rows.Next() {
+		var columnName string
+		err := rows.Scan(&columnName)
+		if err != nil {
+			utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("error extracting row")
 		}
 		result = append(result, columnName)
 	}
@@ -65,9 +138,9 @@ func readPKColumnNames(db *sql.DB, tableName string) []string {
 		WHERE  i.indrelid = $1::regclass
 		AND    i.indisprimary;`
 
-	rows, err := db.Query(sql, tableName)
+	rows, err := db.QueryContext(utils.Context(), sql, tableName)
 	if err != nil {
-		log.Panic().Err(err).Msg("error executing query")
+		utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("error executing query")
 	}
 	defer rows.Close()
 
@@ -76,7 +149,7 @@ func readPKColumnNames(db *sql.DB, tableName string) []string {
 		var columnName string
 		err := rows.Scan(&columnName)
 		if err != nil {
-			log.Panic().Err(err).Msg("error getting row data")
+			utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("error getting row data")
 		}
 		result = append(result, columnName)
 	}
@@ -90,11 +163,12 @@ func readUniqueIndexNames(db *sql.DB, tableName string) []string {
 		JOIN pg_attribute a ON a.attrelid = i.indrelid
 			AND a.attnum = ANY(i.indkey)
 		WHERE i.indrelid = $1::regclass
-		AND i.indisunique AND NOT i.indisprimary;`
+		AND i.indisunique AND NOT i.indisprimary
+		ORDER BY 1;`
 
-	rows, err := db.Query(sql, tableName)
+	rows, err := db.QueryContext(utils.Context(), sql, tableName)
 	if err != nil {
-		log.Panic().Err(err).Msg("error executing query")
+		utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("error executing query")
 	}
 	defer rows.Close()
 
@@ -103,7 +177,7 @@ func readUniqueIndexNames(db *sql.DB, tableName string) []string {
 		var name string
 		err := rows.Scan(&name)
 		if err != nil {
-			log.Panic().Err(err).Msg("error getting column data")
+			utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("error getting column data")
 		}
 		result = append(result, name)
 	}
@@ -111,16 +185,19 @@ func readUniqueIndexNames(db *sql.DB, tableName string) []string {
 	return result
 }
 
+// readIndexColumns returns the columns of the index, in the order of its definition
 func readIndexColumns(db *sql.DB, indexName string) []string {
-	sql := `SELECT DISTINCT a.attname
+	sql := `SELECT a.attname
 		FROM pg_index i
 		JOIN pg_attribute a ON a.attrelid = i.indrelid
 			AND a.attnum = ANY(i.indkey)
-		WHERE indexrelid::regclass = $1::regclass;`
+		WHERE indexrelid::regclass = $1::regclass
+		GROUP BY a.attname
+		ORDER BY min(array_position(i.indkey::int2[], a.attnum));`
 
-	rows, err := db.Query(sql, indexName)
+	rows, err := db.QueryContext(utils.Context(), sql, indexName)
 	if err != nil {
-		log.Panic().Err(err).Msg("error executing query")
+		utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("error executing query")
 	}
 	defer rows.Close()
 
@@ -129,7 +206,7 @@ func readIndexColumns(db *sql.DB, indexName string) []string {
 		var name string
 		err := rows.Scan(&name)
 		if err != nil {
-			log.Panic().Err(err).Msg("error getting column data")
+			utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("error getting column data")
 		}
 		result = append(result, name)
 	}
@@ -137,16 +214,94 @@ func readIndexColumns(db *sql.DB, indexName string) []string {
 	return result
 }
 
+// readPartitionParent returns the table the given one is a partition of, either declarative or by inheritance
+func readPartitionParent(db *sql.DB, tableName string) string {
+	sql := `SELECT p.relname
+		FROM pg_inherits i
+		JOIN pg_class p ON p.oid = i.inhparent
+		WHERE i.inhrelid = $1::regclass;`
+
+	rows, err := db.QueryContext(utils.Context(), sql, tableName)
+	if err != nil {
+		utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("error executing query")
+	}
+	defer rows.Close()
+
+	var name string
+	if rows.Next() {
+		if err := rows.Scan(&name); err != nil {
+			utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("error getting column data")
+		}
+	}
+	// a failed read must not look like a table without parent
+	if err := rows.Err(); err != nil {
+		utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("error getting column data")
+	}
+
+	return name
+}
+
+func readPartitionChildren(db *sql.DB, tableName string) []string {
+	sql := `SELECT c.relname
+		FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = $1::regclass
+		ORDER BY c.relname;`
+
+	rows, err := db.QueryContext(utils.Context(), sql, tableName)
+	if err != nil {
+		utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("error executing query")
+	}
+	defer rows.Close()
+
+	result := make([]string, 0)
+	for rows.Next() {
+		var name string
+		err := rows.Scan(&name)
+		if err != nil {
+			utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("error getting column data")
+		}
+		result = append(result, name)
+	}
+
+	return result
+}
+
+// readPartitionedUniqueIndexes collects the unique indexes of all partitions, keeping one index per set of columns.
+// Inheritance partitioning cannot define unique indexes on the parent, so the children ones are the only available.
+func readPartitionedUniqueIndexes(db *sql.DB, partitions []string) ([]string, map[string]UniqueIndex) {
+	indexNames := make([]string, 0)
+	indexes := make(map[string]UniqueIndex)
+	columnSets := make(map[string]bool)
+	for _, partition := range partitions {
+		for _, indexName := range readUniqueIndexNames(db, partition) {
+			indexColumns := readIndexColumns(db, indexName)
+			sortedColumns := make([]string, len(indexColumns))
+			copy(sortedColumns, indexColumns)
+			sort.Strings(sortedColumns)
+			columnSet := strings.Join(sortedColumns, ",")
+			if columnSets[columnSet] {
+				continue
+			}
+			columnSets[columnSet] = true
+			indexNames = append(indexNames, indexName)
+			indexes[indexName] = UniqueIndex{Name: indexName, Columns: indexColumns}
+		}
+	}
+	return indexNames, indexes
+}
+
 func readReferenceConstraintNames(db *sql.DB, tableName string) []string {
 	sql := `SELECT DISTINCT tc.constraint_name
 		FROM information_schema.table_constraints AS tc
 			JOIN information_schema.constraint_column_usage AS ccu ON ccu.constraint_name = tc.constraint_name
 				AND ccu.table_schema = tc.table_schema
-		WHERE tc.constraint_type = 'FOREIGN KEY' AND tc.table_name = $1;`
+		WHERE tc.constraint_type = 'FOREIGN KEY' AND tc.table_name = $1
+		ORDER BY tc.constraint_name;`
 
-	rows, err := db.Query(sql, tableName)
+	rows, err := db.QueryContext(utils.Context(), sql, tableName)
 	if err != nil {
-		log.Panic().Err(err).Msg("error executing query")
+		utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("error executing query")
 	}
 	defer rows.Close()
 
@@ -155,7 +310,7 @@ func readReferenceConstraintNames(db *sql.DB, tableName string) []string {
 		var name string
 		err := rows.Scan(&name)
 		if err != nil {
-			log.Panic().Err(err).Msg("error getting column data")
+			utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("error getting column data")
 		}
 		result = append(result, name)
 	}
@@ -168,11 +323,12 @@ func readReferencedByConstraintNames(db *sql.DB, tableName string) []string {
 		FROM information_schema.table_constraints AS tc
 			JOIN information_schema.constraint_column_usage AS ccu ON ccu.constraint_name = tc.constraint_name
 				AND ccu.table_schema = tc.table_schema
-		WHERE tc.constraint_type = 'FOREIGN KEY' AND ccu.table_name = $1;`
+		WHERE tc.constraint_type = 'FOREIGN KEY' AND ccu.table_name = $1
+		ORDER BY tc.constraint_name;`
 
-	rows, err := db.Query(sql, tableName)
+	rows, err := db.QueryContext(utils.Context(), sql, tableName)
 	if err != nil {
-		log.Panic().Err(err).Msg("error executing query")
+		utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("error executing query")
 	}
 	defer rows.Close()
 
@@ -181,7 +337,7 @@ func readReferencedByConstraintNames(db *sql.DB, tableName string) []string {
 		var name string
 		err := rows.Scan(&name)
 		if err != nil {
-			log.Panic().Err(err).Msg("error getting column data")
+			utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("error getting column data")
 		}
 		result = append(result, name)
 	}
@@ -194,15 +350,22 @@ func readReferencedTable(db *sql.DB, referenceConstraintName string) string {
 	FROM information_schema.constraint_column_usage AS ccu
 	WHERE ccu.constraint_name = $1;`
 
-	rows, err := db.Query(sql, referenceConstraintName)
+	rows, err := db.QueryContext(utils.Context(), sql, referenceConstraintName)
 	if err != nil {
-		log.Panic().Err(err).Msg("error executing query")
+		utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("error executing query")
 	}
 	defer rows.Close()
 
 	var name string
-	rows.Next()
-	rows.Scan(&name)
+	if rows.Next() {
+		if err := rows.Scan(&name); err != nil {
+			utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("error getting column data")
+		}
+	}
+	// a failed read must not look like a reference to no table
+	if err := rows.Err(); err != nil {
+		utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("error getting column data")
+	}
 
 	return name
 }
@@ -212,15 +375,22 @@ func readReferencedByTable(db *sql.DB, referenceConstraintName string) string {
 	FROM information_schema.table_constraints as tc 
 	WHERE tc.constraint_name = $1;`
 
-	rows, err := db.Query(sql, referenceConstraintName)
+	rows, err := db.QueryContext(utils.Context(), sql, referenceConstraintName)
 	if err != nil {
-		log.Panic().Err(err).Msg("error executing query")
+		utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("error executing query")
 	}
 	defer rows.Close()
 
 	var name string
-	rows.Next()
-	rows.Scan(&name)
+	if rows.Next() {
+		if err := rows.Scan(&name); err != nil {
+			utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("error getting column data")
+		}
+	}
+	// a failed read must not look like a constraint of no table
+	if err := rows.Err(); err != nil {
+		utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("error getting column data")
+	}
 
 	return name
 }
@@ -237,9 +407,9 @@ func readReferenceConstraints(db *sql.DB, tableName string, referenceConstraintN
 			AND tc.table_name = $1
 			AND tc.constraint_name = $2;`
 
-	rows, err := db.Query(sql, tableName, referenceConstraintName)
+	rows, err := db.QueryContext(utils.Context(), sql, tableName, referenceConstraintName)
 	if err != nil {
-		log.Panic().Err(err).Msg("error executing query")
+		utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("error executing query")
 	}
 	defer rows.Close()
 
@@ -249,7 +419,7 @@ func readReferenceConstraints(db *sql.DB, tableName string, referenceConstraintN
 		var foreignColumnName string
 		err := rows.Scan(&columnName, &foreignColumnName)
 		if err != nil {
-			log.Panic().Err(err).Msg("error getting column data")
+			utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("error getting column data")
 		}
 		result[columnName] = foreignColumnName
 	}
@@ -257,6 +427,36 @@ func readReferenceConstraints(db *sql.DB, tableName string, referenceConstraintN
 	return result
 }
 
+// readCheckConstraints returns the CHECK constraints of a table, indexed by name, with the boolean expression only
+func readCheckConstraints(db *sql.DB, tableName string) map[string]string {
+	sql := `SELECT conname, pg_get_constraintdef(oid)
+		FROM pg_constraint
+		WHERE contype = 'c' AND conrelid = $1::regclass
+		ORDER BY conname;`
+
+	rows, err := db.QueryContext(utils.Context(), sql, tableName)
+	if err != nil {
+		utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("error executing query")
+	}
+	defer rows.Close()
+
+	result := make(map[string]string)
+	for rows.Next() {
+		var name string
+		var definition string
+		err := rows.Scan(&name, &definition)
+		if err != nil {
+			utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("error getting column data")
+		}
+		// definition looks like "CHECK ((expression))", optionally followed by "NOT VALID"
+		definition = strings.TrimSpace(strings.TrimSuffix(definition, "NOT VALID"))
+		definition = strings.TrimPrefix(definition, "CHECK ")
+		result[name] = definition
+	}
+
+	return result
+}
+
 func findIndex(indexes map[string]UniqueIndex, columnName string) string {
 	for name, index := range indexes {
 		for _, column := range index.Columns {
@@ -307,25 +507,81 @@ func readPKSequence(db *sql.DB, tableName string) string {
 			JOIN sequences
 				ON replace(regexp_replace(constraint_name, '(_id)?_pk(ey)?', ''), '_', '') = replace(regexp_replace(sequence_name, '(_id)?_seq', ''), '_', '')`
 
-	rows, err := db.Query(sql, tableName)
+	rows, err := db.QueryContext(utils.Context(), sql, tableName)
 	if err != nil {
-		log.Panic().Err(err).Msg("error executing query")
+		utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("error executing query")
 	}
 	defer rows.Close()
 
 	var name string
-	rows.Next()
-	rows.Scan(&name)
+	if rows.Next() {
+		if err := rows.Scan(&name); err != nil {
+			utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("error getting column data")
+		}
+	}
+	// a failed read must not look like a table without sequence
+	if err := rows.Err(); err != nil {
+		utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("error getting column data")
+	}
 
 	return name
 }
 
+// sequence of a column default like nextval('rhn_channel_id_seq'::regclass)
+var nextvalDefaultPattern = regexp.MustCompile(`^nextval\('(?:public\.)?"?([^'"]+)"?'::regclass\)$`)
+
+// readGeneratedColumns returns the identity columns of the table with their kind, IdentityAlways or
+// IdentityByDefault, and the sequences generating the values of the identity, serial and nextval default columns
+func readGeneratedColumns(db *sql.DB, tableName string) (map[string]string, map[string]string) {
+	sql := `SELECT a.attname, a.attidentity, COALESCE(pg_get_serial_sequence(quote_ident(c.relname), a.attname), ''),
+			COALESCE(pg_get_expr(d.adbin, d.adrelid), '')
+		FROM pg_attribute a
+			JOIN pg_class c ON c.oid = a.attrelid
+			JOIN pg_namespace n ON n.oid = c.relnamespace
+			LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+		WHERE n.nspname = 'public' AND c.relname = $1 AND a.attnum > 0 AND NOT a.attisdropped
+			AND (a.attidentity <> '' OR pg_get_expr(d.adbin, d.adrelid) LIKE 'nextval(%')
+		ORDER BY a.attnum;`
+
+	rows, err := db.QueryContext(utils.Context(), sql, tableName)
+	if err != nil {
+		utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("error accessing the database")
+	}
+	defer rows.Close()
+
+	identityColumns := make(map[string]string)
+	sequences := make(map[string]string)
+	for rows.Next() {
+		var columnName, identity, sequence, columnDefault string
+		if err := rows.Scan(&columnName, &identity, &sequence, &columnDefault); err != nil {
+			utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("error extracting row")
+		}
+		if len(identity) > 0 {
+			identityColumns[columnName] = identity
+		}
+		if len(sequence) == 0 {
+			if match := nextvalDefaultPattern.FindStringSubmatch(columnDefault); match != nil {
+				sequence = match[1]
+			}
+		}
+		if len(sequence) > 0 {
+			sequences[columnName] = strings.Trim(strings.TrimPrefix(sequence, "public."), `"`)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("error extracting row")
+	}
+	return identityColumns, sequences
+}
+
 // ReadTablesSchema inspects the DB and returns a list of tables
 func ReadAllTablesSchema(db *sql.DB) map[string]Table {
 	return ReadTablesSchema(db, readTableNames(db))
 }
 
 func ReadTablesSchema(db *sql.DB, tableNames []string) map[string]Table {
+	span := tracing.StartSpan("schema read")
+	defer span.End()
 
 	result := make(map[string]Table, 0)
 	for _, tableName := range tableNames {
@@ -340,6 +596,7 @@ func ReadTablesSchema(db *sql.DB, tableNames []string) map[string]Table {
 	for _, table := range result {
 		result = processReferenceTables(db, table, result)
 	}
+	span.SetAttribute("tables", len(result))
 
 	return result
 }
@@ -370,6 +627,11 @@ func processTable(db *sql.DB, tableName string, exportable bool) (Table, bool) {
 		columnIndexes[columnName] = i
 	}
 
+	nullableColumns := make(map[string]bool)
+	for _, columnName := range readNullableColumnNames(db, tableName) {
+		nullableColumns[columnName] = true
+	}
+
 	pkColumns := readPKColumnNames(db, tableName)
 	pkColumnMap := make(map[string]bool)
 	for _, column := range pkColumns {
@@ -378,13 +640,29 @@ func processTable(db *sql.DB, tableName string, exportable bool) (Table, bool) {
 
 	pkSequence := readPKSequence(db, tableName)
 
-	indexNames := readUniqueIndexNames(db, tableName)
+	// partitions share the unique indexes of their parent, while data is inserted through the parent table
+	partitionOf := readPartitionParent(db, tableName)
+	indexTableName := tableName
+	if len(partitionOf) > 0 {
+		log.Debug().Msgf("Table %s is a partition of %s", tableName, partitionOf)
+		indexTableName = partitionOf
+	}
+
+	indexNames := readUniqueIndexNames(db, indexTableName)
 	indexes := make(map[string]UniqueIndex)
 	for _, indexName := range indexNames {
 		indexColumns := readIndexColumns(db, indexName)
 		indexes[indexName] = UniqueIndex{Name: indexName, Columns: indexColumns}
 	}
 
+	partitions := make([]string, 0)
+	if len(partitionOf) == 0 {
+		partitions = readPartitionChildren(db, tableName)
+	}
+	if len(indexNames) == 0 && len(partitions) > 0 {
+		indexNames, indexes = readPartitionedUniqueIndexes(db, partitions)
+	}
+
 	mainUniqueIndexName := ""
 	if len(indexNames) == 1 {
 		mainUniqueIndexName = indexNames[0]
@@ -395,7 +673,7 @@ func processTable(db *sql.DB, tableName string, exportable bool) (Table, bool) {
 			if len(mainUniqueIndexName) == 0 {
 				mainUniqueIndexName = findIndex(indexes, "token")
 				if len(mainUniqueIndexName) == 0 {
-				        mainUniqueIndexName = findIndexMostColumns(indexes)
+					mainUniqueIndexName = findIndexMostColumns(indexes)
 				}
 			}
 		}
@@ -417,17 +695,40 @@ func processTable(db *sql.DB, tableName string, exportable bool) (Table, bool) {
 		referencedBy = append(referencedBy, Reference{TableName: referencedTable, ColumnMapping: columnMap})
 	}
 
+	checkConstraints := readCheckConstraints(db, tableName)
+
+	largeObjectColumns := make(map[string]bool)
+	for _, column := range readLargeObjectColumnNames(db, tableName) {
+		largeObjectColumns[column] = true
+	}
+
+	identityColumns, columnSequences := readGeneratedColumns(db, tableName)
+
 	table := Table{
 		Name:                tableName,
+		PartitionOf:         partitionOf,
+		Partitioned:         len(partitions) > 0,
 		Export:              exportable,
 		Columns:             columns,
 		ColumnIndexes:       columnIndexes,
+		NullableColumns:     nullableColumns,
 		PKColumns:           pkColumnMap,
 		PKSequence:          pkSequence,
 		UniqueIndexes:       indexes,
 		MainUniqueIndexName: mainUniqueIndexName,
 		References:          references,
-		ReferencedBy:        referencedBy}
+		ReferencedBy:        referencedBy,
+		CheckConstraints:    checkConstraints,
+		LargeObjectColumns:  largeObjectColumns,
+		IdentityColumns:     identityColumns}
 	table = applyTableFilters(table)
+	// the sequence generating the id in the database is preferred to the names of the sequences in the table
+	// filters, which break when the id becomes an identity column
+	if len(pkColumns) == 1 {
+		if sequence, ok := columnSequences[pkColumns[0]]; ok && sequence != table.PKSequence {
+			log.Trace().Msgf("Sequence of %s.%s read from the database: %s", tableName, pkColumns[0], sequence)
+			table.PKSequence = sequence
+		}
+	}
 	return table, false
 }
@@ -1,23 +1,31 @@
 package schemareader
 
 import (
+	"errors"
 	"reflect"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/uyuni-project/inter-server-sync/tests"
+	"github.com/uyuni-project/inter-server-sync/utils"
 )
 
 const (
 	TableName    = "TableName"
 	PKColumnName = "PKColumnName"
 
+	// lower case, as ReadTablesSchema reads the tables by their lower case names
+	PartitionName   = "partitionname"
+	PartitionName02 = "partitionname02"
+
 	UniqueIndexName01 = "UniqueIndexName01"
 	UniqueIndexName02 = "UniqueIndexName02"
 	UniqueIndexName03 = "UniqueIndexName03"
 
 	IndexColumnName01 = "IndexColumnName01"
 	IndexColumnName02 = "IndexColumnName02"
+
+	CheckConstraintName = "CheckConstraintName"
 )
 
 func TestProcessTable(t *testing.T) {
@@ -34,13 +42,174 @@ func TestProcessTable(t *testing.T) {
 	if !indexesEqual {
 		t.Errorf("UniqueIndexes do not match: expected %s, got %s", UniqueIndexName03, table.MainUniqueIndexName)
 	}
+	expectedCheck := "((IndexColumnName01 > 0))"
+	if table.CheckConstraints[CheckConstraintName] != expectedCheck {
+		t.Errorf("Check constraint does not match: expected %s, got %s", expectedCheck, table.CheckConstraints[CheckConstraintName])
+	}
+	if !table.LargeObjectColumns[IndexColumnName02] {
+		t.Errorf("Column %s should be detected as large object reference", IndexColumnName02)
+	}
+	if table.IdentityColumns[IndexColumnName01] != IdentityByDefault {
+		t.Errorf("Column %s should be detected as identity by default", IndexColumnName01)
+	}
+	if len(table.PKSequence) > 0 {
+		t.Errorf("Sequences of the columns outside of the primary key should be ignored, got %s", table.PKSequence)
+	}
+}
+
+func TestProcessTableIdentityColumn(t *testing.T) {
+
+	// Arrange
+	repo := tests.CreateDataRepository()
+	IdentityColumnCase(repo)
+
+	// Act
+	table, _ := processTable(repo.DB, TableName, true)
+
+	// Assert
+	if table.IdentityColumns["id"] != IdentityAlways {
+		t.Errorf("Column id should be detected as identity always, got %q", table.IdentityColumns["id"])
+	}
+	if table.PKSequence != "TableName_id_seq" {
+		t.Errorf("Sequence does not match: expected TableName_id_seq, got %s", table.PKSequence)
+	}
+}
+
+func TestProcessTablePartition(t *testing.T) {
+
+	// Arrange
+	repo := tests.CreateDataRepository()
+	PartitionCase(repo)
+
+	// Act
+	table, _ := processTable(repo.DB, PartitionName, true)
+
+	// Assert
+	if table.PartitionOf != TableName {
+		t.Errorf("Partition parent does not match: expected %s, got %s", TableName, table.PartitionOf)
+	}
+	if table.InsertTableName() != TableName {
+		t.Errorf("Insert table does not match: expected %s, got %s", TableName, table.InsertTableName())
+	}
+	if table.Partitioned {
+		t.Errorf("Partition should not be marked as partitioned")
+	}
+	if table.MainUniqueIndexName != UniqueIndexName01 {
+		t.Errorf("UniqueIndexes do not match: expected %s, got %s", UniqueIndexName01, table.MainUniqueIndexName)
+	}
+}
+
+func TestReadTablesSchemaPartition(t *testing.T) {
+
+	// Arrange
+	repo := tests.CreateDataRepository()
+	PartitionCase(repo)
+
+	// Act
+	tables := ReadTablesSchema(repo.DB, []string{PartitionName})
+
+	// Assert
+	table, ok := tables[PartitionName]
+	if !ok {
+		t.Fatalf("Partition %s should be read when it is named, got %v", PartitionName, tables)
+	}
+	if table.InsertTableName() != TableName {
+		t.Errorf("Insert table does not match: expected %s, got %s", TableName, table.InsertTableName())
+	}
+	if err := repo.ExpectationsWereMet(); err != nil {
+		t.Errorf("Not all the schema of the partition was read: %s", err)
+	}
+}
+
+func TestProcessTableInheritancePartitions(t *testing.T) {
+
+	// Arrange
+	repo := tests.CreateDataRepository()
+	InheritancePartitionsCase(repo)
+
+	// Act
+	table, _ := processTable(repo.DB, TableName, true)
+
+	// Assert
+	if !table.Partitioned {
+		t.Errorf("Table should be marked as partitioned")
+	}
+	if len(table.UniqueIndexes) != 1 {
+		t.Errorf("Partition indexes should be aggregated: expected 1, got %d", len(table.UniqueIndexes))
+	}
+	if table.MainUniqueIndexName != UniqueIndexName01 {
+		t.Errorf("UniqueIndexes do not match: expected %s, got %s", UniqueIndexName01, table.MainUniqueIndexName)
+	}
+}
+
+func TestReadPartitionParentFailure(t *testing.T) {
+
+	// Arrange
+	repo := tests.CreateDataRepository()
+	rows := sqlmock.NewRows([]string{"relname"}).AddRow(TableName).RowError(0, errors.New("connection reset"))
+	repo.ExpectWithRecords(ReadPartitionParent, rows, PartitionName)
+
+	// Act
+	defer func() {
+		// Assert
+		failure, ok := recover().(*utils.Failure)
+		if !ok || failure.ExitCode != utils.ExitDatabaseError {
+			t.Errorf("Expected a database failure, got %v", failure)
+		}
+	}()
+	parent := readPartitionParent(repo.DB, PartitionName)
+	t.Errorf("Failed read returned the parent %q", parent)
+}
+
+func PartitionCase(repo *tests.DataRepository) {
+
+	repo.ExpectWithRecords(ReadColumnNames, sqlmock.NewRows([]string{"column_name"}).AddRow(PKColumnName), PartitionName)
+	repo.ExpectWithRecords(ReadNullableColumnNames, sqlmock.NewRows([]string{"column_name"}), PartitionName)
+	repo.ExpectWithRecords(ReadPkColumnNames, sqlmock.NewRows([]string{"attname"}).AddRow(PKColumnName), PartitionName)
+	repo.ExpectWithRecords(ReadPkSequence, sqlmock.NewRows([]string{"sequence_name"}).AddRow(""), PartitionName)
+	repo.ExpectWithRecords(ReadPartitionParent, sqlmock.NewRows([]string{"relname"}).AddRow(TableName), PartitionName)
+
+	// indexes are read from the parent table
+	repo.ExpectWithRecords(ReadUniqueIndexNames, sqlmock.NewRows([]string{"indexrelid"}).AddRow(UniqueIndexName01), TableName)
+	repo.ExpectWithRecords(ReadIndexColumns, sqlmock.NewRows([]string{"attname"}).AddRow(IndexColumnName01), UniqueIndexName01)
+
+	repo.ExpectWithRecords(ReadReferenceConstraintNames, sqlmock.NewRows([]string{"constraint_name"}), PartitionName)
+	repo.ExpectWithRecords(ReadReferencedByConstraintNames, sqlmock.NewRows([]string{"constraint_name"}), PartitionName)
+	repo.ExpectWithRecords(ReadCheckConstraints, sqlmock.NewRows([]string{"conname", "pg_get_constraintdef"}), PartitionName)
+	repo.ExpectWithRecords(ReadLargeObjectColumnNames, sqlmock.NewRows([]string{"column_name"}), PartitionName)
+	repo.ExpectWithRecords(ReadGeneratedColumns, sqlmock.NewRows([]string{"attname", "attidentity", "pg_get_serial_sequence", "pg_get_expr"}), PartitionName)
+}
+
+func InheritancePartitionsCase(repo *tests.DataRepository) {
+
+	repo.ExpectWithRecords(ReadColumnNames, sqlmock.NewRows([]string{"column_name"}).AddRow(PKColumnName), TableName)
+	repo.ExpectWithRecords(ReadNullableColumnNames, sqlmock.NewRows([]string{"column_name"}), TableName)
+	repo.ExpectWithRecords(ReadPkColumnNames, sqlmock.NewRows([]string{"attname"}).AddRow(PKColumnName), TableName)
+	repo.ExpectWithRecords(ReadPkSequence, sqlmock.NewRows([]string{"sequence_name"}).AddRow(""), TableName)
+	repo.ExpectWithRecords(ReadPartitionParent, sqlmock.NewRows([]string{"relname"}), TableName)
+
+	// parent has no unique index, each child has one on the same columns
+	repo.ExpectWithRecords(ReadUniqueIndexNames, sqlmock.NewRows([]string{"indexrelid"}), TableName)
+	repo.ExpectWithRecords(ReadPartitionChildren, sqlmock.NewRows([]string{"relname"}).AddRow(PartitionName).AddRow(PartitionName02), TableName)
+	repo.ExpectWithRecords(ReadUniqueIndexNames, sqlmock.NewRows([]string{"indexrelid"}).AddRow(UniqueIndexName01), PartitionName)
+	repo.ExpectWithRecords(ReadIndexColumns, sqlmock.NewRows([]string{"attname"}).AddRow(IndexColumnName01).AddRow(IndexColumnName02), UniqueIndexName01)
+	repo.ExpectWithRecords(ReadUniqueIndexNames, sqlmock.NewRows([]string{"indexrelid"}).AddRow(UniqueIndexName02), PartitionName02)
+	repo.ExpectWithRecords(ReadIndexColumns, sqlmock.NewRows([]string{"attname"}).AddRow(IndexColumnName02).AddRow(IndexColumnName01), UniqueIndexName02)
+
+	repo.ExpectWithRecords(ReadReferenceConstraintNames, sqlmock.NewRows([]string{"constraint_name"}), TableName)
+	repo.ExpectWithRecords(ReadReferencedByConstraintNames, sqlmock.NewRows([]string{"constraint_name"}), TableName)
+	repo.ExpectWithRecords(ReadCheckConstraints, sqlmock.NewRows([]string{"conname", "pg_get_constraintdef"}), TableName)
+	repo.ExpectWithRecords(ReadLargeObjectColumnNames, sqlmock.NewRows([]string{"column_name"}), TableName)
+	repo.ExpectWithRecords(ReadGeneratedColumns, sqlmock.NewRows([]string{"attname", "attidentity", "pg_get_serial_sequence", "pg_get_expr"}), TableName)
 }
 
 func UniqueIndexMostColumnsCase(repo *tests.DataRepository) {
 
 	repo.ExpectWithRecords(ReadColumnNames, sqlmock.NewRows([]string{"column_name"}).AddRow(""), TableName)
+	repo.ExpectWithRecords(ReadNullableColumnNames, sqlmock.NewRows([]string{"column_name"}), TableName)
 	repo.ExpectWithRecords(ReadPkColumnNames, sqlmock.NewRows([]string{"attname"}).AddRow(""), TableName)
 	repo.ExpectWithRecords(ReadPkSequence, sqlmock.NewRows([]string{"sequence_name"}).AddRow(""), TableName)
+	repo.ExpectWithRecords(ReadPartitionParent, sqlmock.NewRows([]string{"relname"}), TableName)
 
 	// Read indexes information to get three indexes
 	repo.ExpectWithRecords(
@@ -78,7 +247,43 @@ func UniqueIndexMostColumnsCase(repo *tests.DataRepository) {
 			AddRow(IndexColumnName02),
 		UniqueIndexName03,
 	)
+	repo.ExpectWithRecords(ReadPartitionChildren, sqlmock.NewRows([]string{"relname"}), TableName)
+
+	repo.ExpectWithRecords(ReadReferenceConstraintNames, sqlmock.NewRows([]string{"constraint_name"}), TableName)
+	repo.ExpectWithRecords(ReadReferencedByConstraintNames, sqlmock.NewRows([]string{"constraint_name"}), TableName)
+	repo.ExpectWithRecords(
+		ReadCheckConstraints,
+		sqlmock.NewRows([]string{"conname", "pg_get_constraintdef"}).
+			AddRow(CheckConstraintName, "CHECK ((IndexColumnName01 > 0)) NOT VALID"),
+		TableName,
+	)
+	repo.ExpectWithRecords(ReadLargeObjectColumnNames, sqlmock.NewRows([]string{"column_name"}).AddRow(IndexColumnName02), TableName)
+	repo.ExpectWithRecords(
+		ReadGeneratedColumns,
+		sqlmock.NewRows([]string{"attname", "attidentity", "pg_get_serial_sequence", "pg_get_expr"}).
+			AddRow(IndexColumnName01, "d", "public.tablename_column_seq", ""),
+		TableName,
+	)
+}
+
+func IdentityColumnCase(repo *tests.DataRepository) {
 
+	repo.ExpectWithRecords(ReadColumnNames, sqlmock.NewRows([]string{"column_name"}).AddRow("id"), TableName)
+	repo.ExpectWithRecords(ReadNullableColumnNames, sqlmock.NewRows([]string{"column_name"}), TableName)
+	repo.ExpectWithRecords(ReadPkColumnNames, sqlmock.NewRows([]string{"attname"}).AddRow("id"), TableName)
+	// the constraint and the sequence names do not match once the column is an identity
+	repo.ExpectWithRecords(ReadPkSequence, sqlmock.NewRows([]string{"sequence_name"}), TableName)
+	repo.ExpectWithRecords(ReadPartitionParent, sqlmock.NewRows([]string{"relname"}), TableName)
+	repo.ExpectWithRecords(ReadUniqueIndexNames, sqlmock.NewRows([]string{"indexrelid"}), TableName)
+	repo.ExpectWithRecords(ReadPartitionChildren, sqlmock.NewRows([]string{"relname"}), TableName)
 	repo.ExpectWithRecords(ReadReferenceConstraintNames, sqlmock.NewRows([]string{"constraint_name"}), TableName)
 	repo.ExpectWithRecords(ReadReferencedByConstraintNames, sqlmock.NewRows([]string{"constraint_name"}), TableName)
+	repo.ExpectWithRecords(ReadCheckConstraints, sqlmock.NewRows([]string{"conname", "pg_get_constraintdef"}), TableName)
+	repo.ExpectWithRecords(ReadLargeObjectColumnNames, sqlmock.NewRows([]string{"column_name"}), TableName)
+	repo.ExpectWithRecords(
+		ReadGeneratedColumns,
+		sqlmock.NewRows([]string{"attname", "attidentity", "pg_get_serial_sequence", "pg_get_expr"}).
+			AddRow("id", "a", `public."TableName_id_seq"`, ""),
+		TableName,
+	)
 }
@@ -1,10 +1,9 @@
 package schemareader
 
 import (
-	"regexp"
+	"database/sql"
 	"strings"
 
-	"github.com/rs/zerolog/log"
 	"github.com/uyuni-project/inter-server-sync/sqlUtil"
 )
 
@@ -121,6 +120,19 @@ func applyTableFilters(table Table) Table {
 		virtualIndexColumns := []string{"profile_id", "path"}
 		table.UniqueIndexes[VirtualIndexName] = UniqueIndex{Name: VirtualIndexName, Columns: virtualIndexColumns}
 		table.MainUniqueIndexName = VirtualIndexName
+	case "rhnkickstartabletree":
+		// cobbler records are created by the cobbler sync of the target server
+		table.UnexportColumns = map[string]bool{"cobbler_id": true, "cobbler_xen_id": true}
+	case "rhnksdata":
+		table.UnexportColumns = map[string]bool{"cobbler_id": true}
+	case "rhnkickstartcommand":
+		virtualIndexColumns := []string{"kickstart_id", "ks_command_name_id", "arguments"}
+		table.UniqueIndexes[VirtualIndexName] = UniqueIndex{Name: VirtualIndexName, Columns: virtualIndexColumns}
+		table.MainUniqueIndexName = VirtualIndexName
+	case "rhnkickstartscript":
+		virtualIndexColumns := []string{"kickstart_id", "position"}
+		table.UniqueIndexes[VirtualIndexName] = UniqueIndex{Name: VirtualIndexName, Columns: virtualIndexColumns}
+		table.MainUniqueIndexName = VirtualIndexName
 	case "rhnerrata":
 		// this table has two unique indexes with the same size which can be used
 		// we are fixing the usage to one of them to make it deterministic
@@ -135,21 +147,17 @@ func applyTableFilters(table Table) Table {
 		}
 	case "susesaltpillar":
 		table.RowModCallback = func(value []sqlUtil.RowDataStructure, table Table) []sqlUtil.RowDataStructure {
-			isImagePillar := false
-			pillarColumn := 0
+			category := ""
+			pillarColumn := -1
 			for i, column := range value {
-				if strings.Compare(column.ColumnName, "category") == 0 &&
-					strings.HasPrefix(column.Value.(string), "Image") {
-					log.Trace().Msgf("Updating pillar URLs of %s", column.Value)
-					isImagePillar = true
-				} else if strings.Compare(column.ColumnName, "pillar") == 0 {
+				if strings.Compare(column.ColumnName, "category") == 0 && column.Value != nil {
+					category = column.Value.(string)
+				} else if strings.Compare(column.ColumnName, "pillar") == 0 && column.Value != nil {
 					pillarColumn = i
 				}
 			}
-			if isImagePillar {
-				re := regexp.MustCompile(`https://[^/]+/os-images/`)
-				repl := []byte("https://{SERVER_FQDN}/os-images/")
-				value[pillarColumn].Value = re.ReplaceAll(value[pillarColumn].Value.([]byte), repl)
+			if pillarColumn >= 0 {
+				value[pillarColumn].Value = rewritePillar(category, value[pillarColumn].Value.([]byte))
 			}
 			return value
 		}
@@ -164,3 +172,16 @@ func applyTableFilters(table Table) Table {
 	}
 	return table
 }
+
+// ReadFilteredPKSequences returns the primary key sequences set by the table filters, indexed by table name,
+// for the tables of the database
+func ReadFilteredPKSequences(db *sql.DB) map[string]string {
+	result := make(map[string]string)
+	for _, tableName := range readTableNames(db) {
+		table := applyTableFilters(Table{Name: tableName, UniqueIndexes: make(map[string]UniqueIndex)})
+		if len(table.PKSequence) > 0 {
+			result[tableName] = table.PKSequence
+		}
+	}
+	return result
+}
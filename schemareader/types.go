@@ -9,6 +9,7 @@ type Table struct {
 	Columns         []string
 	UnexportColumns map[string]bool
 	ColumnIndexes   map[string]int
+	NullableColumns map[string]bool
 	PKColumns       map[string]bool
 	PKSequence      string
 	UniqueIndexes   map[string]UniqueIndex
@@ -16,9 +17,29 @@ type Table struct {
 	MainUniqueIndexName string
 	References          []Reference
 	ReferencedBy        []Reference
-	RowModCallback      TableCallback
+	// CHECK constraints expressions indexed by constraint name
+	CheckConstraints map[string]string
+	// columns referencing large objects, which are exported by content
+	LargeObjectColumns map[string]bool
+	RowModCallback     TableCallback `json:"-"`
+	// name of the parent table when this table is a partition of it
+	PartitionOf string
+	// true when the table is split in partitions (declarative or by inheritance)
+	Partitioned bool
+	// user provided predicate restricting the rows to export
+	WhereFilter string
+	// GENERATED AS IDENTITY columns, IdentityAlways or IdentityByDefault by column name
+	IdentityColumns map[string]string
 }
 
+// kinds of the identity columns, as in pg_attribute.attidentity
+const (
+	// IdentityAlways columns only take the values of the statements with OVERRIDING SYSTEM VALUE
+	IdentityAlways = "a"
+	// IdentityByDefault columns take the values of the statements, and generate the missing ones
+	IdentityByDefault = "d"
+)
+
 // UniqueIndex represents an index among columns of a Table
 type UniqueIndex struct {
 	Name    string
@@ -34,6 +55,15 @@ type Reference struct {
 // Row modification callback function
 type TableCallback func(value []sqlUtil.RowDataStructure, table Table) []sqlUtil.RowDataStructure
 
+// InsertTableName returns the table where rows should be inserted into.
+// Partitions are always written through their parent, so PostgreSQL can route the rows
+func (table *Table) InsertTableName() string {
+	if len(table.PartitionOf) > 0 {
+		return table.PartitionOf
+	}
+	return table.Name
+}
+
 // we are returning just one reference, the first one which uses the column we want
 func (table *Table) GetFirstReferenceFromColumn(columnName string) Reference {
 	for _, reference := range table.References {
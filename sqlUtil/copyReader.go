@@ -0,0 +1,204 @@
+package sqlUtil
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// environment used to run psql for COPY TO reads, COPY reads are disabled when empty
+var copyEnvironment []string
+
+// EnableCopyReads makes bulk reads use the COPY TO protocol through psql, which is much faster than iterating
+// over the rows of big tables. The driver doesn't support COPY TO, so reads fall back to SELECT if psql is missing.
+func EnableCopyReads(connectionEnvironment []string) {
+	if _, err := exec.LookPath("psql"); err != nil {
+		log.Debug().Msg("psql not found, COPY TO reads are disabled")
+		return
+	}
+	copyEnvironment = connectionEnvironment
+}
+
+// ExecuteBulkQueryWithResults executes a query reading a whole table or a large range of it,
+// with the COPY TO protocol when it is enabled. Small reads, like the rows of a batch of keys, are faster with
+// ExecuteQueryWithResults.
+func ExecuteBulkQueryWithResults(db *sql.DB, query string) [][]RowDataStructure {
+	if len(copyEnvironment) == 0 {
+		return ExecuteQueryWithResults(db, query)
+	}
+	query = strings.TrimSuffix(strings.TrimSpace(query), ";")
+	return runQuery(query, nil, func(ctx context.Context) ([][]RowDataStructure, string, error) {
+		// the types and the rows are read in the same snapshot, the snapshot of the database or the one exported by
+		// the transaction reading the types
+		var queryer interface {
+			QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+		} = db
+		snapshot := databaseSnapshot(db)
+		if len(snapshot) == 0 {
+			tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+			if err != nil {
+				return nil, "error starting the COPY transaction", err
+			}
+			defer tx.Rollback()
+			if err := tx.QueryRowContext(ctx, "SELECT pg_export_snapshot();").Scan(&snapshot); err != nil {
+				return nil, "error exporting the COPY snapshot", err
+			}
+			queryer = tx
+		}
+		// COPY only returns the values as text, the types are read from an empty result
+		rows, err := queryer.QueryContext(ctx, fmt.Sprintf("SELECT * FROM (%s) AS copy_query LIMIT 0;", query))
+		if err != nil {
+			return nil, "error executing query", err
+		}
+		columnTypes, err := rows.ColumnTypes()
+		rows.Close()
+		if err != nil {
+			return nil, "error getting column types", err
+		}
+
+		// verbose errors include the SQLSTATE code of the failure
+		cmd := exec.CommandContext(ctx, "psql", "-X", "-q", "-v", "ON_ERROR_STOP=1", "-v", "VERBOSITY=verbose",
+			"-c", "BEGIN ISOLATION LEVEL REPEATABLE READ READ ONLY;",
+			"-c", fmt.Sprintf("SET TRANSACTION SNAPSHOT %s;", pq.QuoteLiteral(snapshot)),
+			"-c", fmt.Sprintf("COPY (%s) TO STDOUT", query))
+		cmd.Env = append(os.Environ(), copyEnvironment...)
+		var errorOutput bytes.Buffer
+		cmd.Stderr = io.MultiWriter(os.Stderr, &errorOutput)
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("error reading COPY output")
+		}
+		if err := cmd.Start(); err != nil {
+			utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("error starting psql")
+		}
+		computedValues := readCopyRows(bufio.NewReader(stdout), columnTypes)
+		if err := cmd.Wait(); err != nil {
+			return nil, "error executing COPY TO", newCopyError(err, errorOutput.String())
+		}
+		return computedValues, "", nil
+	})
+}
+
+func readCopyRows(reader *bufio.Reader, columnTypes []*sql.ColumnType) [][]RowDataStructure {
+	computedValues := make([][]RowDataStructure, 0)
+	for {
+		line, err := reader.ReadString('\n')
+		if err == io.EOF && len(line) == 0 {
+			break
+		}
+		if err != nil && err != io.EOF {
+			utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("error reading COPY output")
+		}
+		fields := strings.Split(strings.TrimSuffix(line, "\n"), "\t")
+		if len(fields) != len(columnTypes) {
+			utils.Panic().Msgf("COPY row has %d fields, expected %d", len(fields), len(columnTypes))
+		}
+		rowComputedValues := make([]RowDataStructure, 0)
+		for i, field := range fields {
+			columnType := columnTypes[i].DatabaseTypeName()
+			value := decodeCopyField(field, columnType)
+			rowComputedValues = append(rowComputedValues, RowDataStructure{ColumnType: columnType,
+				initialValue: value, Value: value, ColumnName: columnTypes[i].Name()})
+		}
+		computedValues = append(computedValues, rowComputedValues)
+	}
+	return computedValues
+}
+
+// decodeCopyField converts a field in COPY text format to the value the driver would return for the column type
+func decodeCopyField(field string, columnType string) interface{} {
+	if field == `\N` {
+		return nil
+	}
+	text := unescapeCopyField(field)
+	var value interface{}
+	var err error
+	switch columnType {
+	case "INT2", "INT4", "INT8":
+		value, err = strconv.ParseInt(text, 10, 64)
+	case "FLOAT4", "FLOAT8":
+		value, err = strconv.ParseFloat(text, 64)
+	case "BOOL":
+		value = text == "t"
+	case "TIMESTAMPTZ", "TIMESTAMP", "DATE":
+		value, err = pq.ParseTimestamp(nil, text)
+	case "BYTEA":
+		value, err = hex.DecodeString(strings.TrimPrefix(text, `\x`))
+	case "CHAR", "VARCHAR", "TEXT", "BPCHAR":
+		value = text
+	default:
+		value = []byte(text)
+	}
+	if err != nil {
+		utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msgf("error decoding COPY value of type %s", columnType)
+	}
+	return value
+}
+
+// unescapeCopyField replaces the backslash sequences of the COPY text format
+func unescapeCopyField(field string) string {
+	if !strings.Contains(field, `\`) {
+		return field
+	}
+	var result strings.Builder
+	for i := 0; i < len(field); i++ {
+		if field[i] != '\\' || i+1 == len(field) {
+			result.WriteByte(field[i])
+			continue
+		}
+		i++
+		switch field[i] {
+		case 'b':
+			result.WriteByte('\b')
+		case 'f':
+			result.WriteByte('\f')
+		case 'n':
+			result.WriteByte('\n')
+		case 'r':
+			result.WriteByte('\r')
+		case 't':
+			result.WriteByte('\t')
+		case 'v':
+			result.WriteByte('\v')
+		case 'x':
+			end := i + 1
+			for end < len(field) && end < i+3 && isHexDigit(field[end]) {
+				end++
+			}
+			if end == i+1 {
+				result.WriteByte('x')
+				continue
+			}
+			b, _ := strconv.ParseUint(field[i+1:end], 16, 8)
+			result.WriteByte(byte(b))
+			i = end - 1
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			end := i
+			for end < len(field) && end < i+3 && field[end] >= '0' && field[end] <= '7' {
+				end++
+			}
+			b, _ := strconv.ParseUint(field[i:end], 8, 8)
+			result.WriteByte(byte(b))
+			i = end - 1
+		default:
+			result.WriteByte(field[i])
+		}
+	}
+	return result.String()
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
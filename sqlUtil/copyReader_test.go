@@ -0,0 +1,36 @@
+package sqlUtil
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDecodeCopyField(t *testing.T) {
+	cases := []struct {
+		field      string
+		columnType string
+		expected   interface{}
+	}{
+		{`\N`, "TEXT", nil},
+		{`line\twith\\escapes\n`, "TEXT", "line\twith\\escapes\n"},
+		{`\101\x42`, "VARCHAR", "AB"},
+		{"42", "INT8", int64(42)},
+		{"t", "BOOL", true},
+		{`\\x0aff`, "BYTEA", []byte{0x0a, 0xff}},
+		{"1.50", "NUMERIC", []byte("1.50")},
+		{"2022-01-02 03:04:05", "TIMESTAMP", time.Date(2022, 1, 2, 3, 4, 5, 0, time.FixedZone("", 0))},
+	}
+	for _, c := range cases {
+		value := decodeCopyField(c.field, c.columnType)
+		if expectedTime, ok := c.expected.(time.Time); ok {
+			if !expectedTime.Equal(value.(time.Time)) {
+				t.Errorf("Decoding %s as %s: expected %v, got %v", c.field, c.columnType, c.expected, value)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(value, c.expected) {
+			t.Errorf("Decoding %s as %s: expected %#v, got %#v", c.field, c.columnType, c.expected, value)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package sqlUtil
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// prepared statements by database and query. Lookup queries are generated from the table and the column set,
+// so the same query text is executed for every row or key of a table.
+var preparedStatements = make(map[*sql.DB]map[string]*sql.Stmt)
+var preparedStatementsLock sync.Mutex
+
+func getPreparedStatement(db *sql.DB, query string) *sql.Stmt {
+	preparedStatementsLock.Lock()
+	defer preparedStatementsLock.Unlock()
+	statements, ok := preparedStatements[db]
+	if !ok {
+		statements = make(map[string]*sql.Stmt)
+		preparedStatements[db] = statements
+	}
+	if statement, ok := statements[query]; ok {
+		return statement
+	}
+	statement, err := db.PrepareContext(utils.Context(), query)
+	if err != nil {
+		log.Printf("Error : While preparing '%s'", query)
+		utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("error preparing query")
+	}
+	statements[query] = statement
+	return statement
+}
+
+// ExecutePreparedQueryWithResults executes the query like ExecuteQueryWithResults, preparing it on the first call
+// and reusing the prepared statement on later calls with the same query text
+func ExecutePreparedQueryWithResults(db *sql.DB, query string, scanParameters ...interface{}) [][]RowDataStructure {
+	statement := getPreparedStatement(db, query)
+	return runQuery(query, scanParameters, func(ctx context.Context) ([][]RowDataStructure, string, error) {
+		rows, err := statement.QueryContext(ctx, scanParameters...)
+		if err != nil {
+			return nil, "error executing query", err
+		}
+		return readRows(rows)
+	})
+}
+
+// ClosePreparedStatements closes all the statements prepared for the database
+func ClosePreparedStatements(db *sql.DB) {
+	preparedStatementsLock.Lock()
+	defer preparedStatementsLock.Unlock()
+	for _, statement := range preparedStatements[db] {
+		if err := statement.Close(); err != nil {
+			log.Warn().Err(err).Msg("error closing prepared statement")
+		}
+	}
+	delete(preparedStatements, db)
+}
@@ -1,10 +1,9 @@
 package sqlUtil
 
 import (
+	"context"
 	"database/sql"
 	"reflect"
-
-	"github.com/rs/zerolog/log"
 )
 
 type RowDataStructure struct {
@@ -19,19 +18,23 @@ func (row RowDataStructure) GetInitialValue() interface{} {
 }
 
 func ExecuteQueryWithResults(db *sql.DB, sql string, scanParameters ...interface{}) [][]RowDataStructure {
+	return runQuery(sql, scanParameters, func(ctx context.Context) ([][]RowDataStructure, string, error) {
+		rows, err := db.QueryContext(ctx, sql, scanParameters...)
+		if err != nil {
+			return nil, "error executing query", err
+		}
+		return readRows(rows)
+	})
+}
 
-	rows, err := db.Query(sql, scanParameters...)
-
-	if err != nil {
-		log.Printf("Error : While executing '%s', with parameters %s", sql, scanParameters)
-		log.Panic().Err(err).Msg("error executing query")
-	}
+// readRows reads all the rows of a query result, closing it, or returns the failure with its message
+func readRows(rows *sql.Rows) ([][]RowDataStructure, string, error) {
 	defer rows.Close()
 
 	// get column type info
 	columnTypes, err := rows.ColumnTypes()
 	if err != nil {
-		log.Panic().Err(err).Msg("error getting column types")
+		return nil, "error getting column types", err
 	}
 
 	// used for allocation & dereferencing
@@ -53,7 +56,7 @@ func ExecuteQueryWithResults(db *sql.DB, sql string, scanParameters ...interface
 
 		// scan each column Value into the corresponding **T Value
 		if err := rows.Scan(rowResult...); err != nil {
-			log.Panic().Err(err).Msg("error getting rows")
+			return nil, "error getting rows", err
 		}
 
 		// dereference pointers
@@ -74,5 +77,9 @@ func ExecuteQueryWithResults(db *sql.DB, sql string, scanParameters ...interface
 
 		computedValues = append(computedValues, rowComputedValues)
 	}
-	return computedValues
+	// canceled queries end the rows early
+	if err := rows.Err(); err != nil {
+		return nil, "error getting rows", err
+	}
+	return computedValues, "", nil
 }
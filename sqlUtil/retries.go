@@ -0,0 +1,133 @@
+package sqlUtil
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// DefaultRetryableErrors are the SQLSTATE codes of the transient errors of a live server: serialization
+// failures, deadlocks, and locks or objects held by other transactions
+var DefaultRetryableErrors = []string{"40001", "40P01", "55P03", "55006"}
+
+// RetryPolicy repeats the read queries failing with transient errors. Broken connections are already retried
+// by the database driver, before the query fails.
+type RetryPolicy struct {
+	// SQLSTATE codes of the errors repeating the query, DefaultRetryableErrors when empty
+	RetryableErrors []string
+	// attempts of a query before it fails, 1 or less never repeats it
+	MaxAttempts int
+	// wait before the first repetition, doubled before each of the next ones up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// waits used when the policy doesn't set them, so repetitions neither run in a busy loop nor wait without end
+const (
+	defaultRetryBackoff    = 100 * time.Millisecond
+	defaultRetryMaxBackoff = time.Minute
+)
+
+// retry policy of the running export
+var retryPolicy RetryPolicy
+
+// SetRetryPolicy sets the policy of the read queries run afterwards
+func SetRetryPolicy(policy RetryPolicy) {
+	retryPolicy = policy
+}
+
+// copyError is the failure of a COPY TO run by psql, with the SQLSTATE code printed on its error output
+type copyError struct {
+	err  error
+	code string
+}
+
+func (err *copyError) Error() string {
+	return err.err.Error()
+}
+
+// SQLSTATE code of the psql errors printed with VERBOSITY=verbose
+var psqlErrorCode = regexp.MustCompile(`ERROR:\s+([0-9A-Z]{5}):`)
+
+func newCopyError(err error, output string) error {
+	if match := psqlErrorCode.FindStringSubmatch(output); match != nil {
+		return &copyError{err: err, code: match[1]}
+	}
+	return err
+}
+
+// errorCode returns the SQLSTATE code of a query error, empty when the database didn't return one
+func errorCode(err error) string {
+	var pqError *pq.Error
+	if errors.As(err, &pqError) {
+		return string(pqError.Code)
+	}
+	var failedCopy *copyError
+	if errors.As(err, &failedCopy) {
+		return failedCopy.code
+	}
+	return ""
+}
+
+func (policy RetryPolicy) retryable(err error) bool {
+	code := errorCode(err)
+	if len(code) == 0 {
+		return false
+	}
+	codes := policy.RetryableErrors
+	if len(codes) == 0 {
+		codes = DefaultRetryableErrors
+	}
+	return utils.Contains(codes, code)
+}
+
+// runQuery runs the attempts of a read query with the retry policy, each one with its own context. Attempts
+// return the message of their failure with the error, the last failure stops the export.
+func runQuery(query string, parameters []interface{},
+	attempt func(ctx context.Context) ([][]RowDataStructure, string, error)) [][]RowDataStructure {
+	wait := retryPolicy.Backoff
+	if wait <= 0 {
+		wait = defaultRetryBackoff
+	}
+	maxWait := retryPolicy.MaxBackoff
+	if maxWait <= 0 {
+		maxWait = defaultRetryMaxBackoff
+	}
+	for attempts := 1; ; attempts++ {
+		ctx, cancel := queryContext()
+		result, msg, err := attempt(ctx)
+		if err == nil {
+			cancel()
+			return result
+		}
+		if attempts >= retryPolicy.MaxAttempts || !retryPolicy.retryable(err) || utils.Context().Err() != nil {
+			if len(parameters) > 0 {
+				log.Printf("Error : While executing '%s', with parameters %s", query, parameters)
+			} else {
+				log.Printf("Error : While executing '%s'", query)
+			}
+			// canceling keeps the deadline error of the queries which exceeded their timeout
+			cancel()
+			queryFailed(ctx, err, msg)
+			// queryFailed only returns when the logger doesn't stop on panic events
+			panic(err)
+		}
+		cancel()
+		log.Warn().Err(err).Msgf("Transient error of attempt %d of %d, repeating the query in %s",
+			attempts, retryPolicy.MaxAttempts, wait)
+		select {
+		case <-time.After(wait):
+		case <-utils.Context().Done():
+			utils.CheckCanceled()
+		}
+		wait *= 2
+		if wait > maxWait {
+			wait = maxWait
+		}
+	}
+}
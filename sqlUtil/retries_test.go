@@ -0,0 +1,71 @@
+package sqlUtil
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+func TestRetryTransientErrors(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	SetRetryPolicy(RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond})
+	defer SetRetryPolicy(RetryPolicy{})
+
+	query := "SELECT id FROM rhnchannel"
+	mock.ExpectQuery(query).WillReturnError(&pq.Error{Code: "40P01", Message: "deadlock detected"})
+	mock.ExpectQuery(query).WillReturnError(&pq.Error{Code: "55P03", Message: "could not obtain lock"})
+	mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	rows := ExecuteQueryWithResults(db, query)
+	if len(rows) != 1 {
+		t.Errorf("Expected the row of the third attempt, got %v", rows)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNoRetryOfOtherErrors(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	SetRetryPolicy(RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond})
+	defer SetRetryPolicy(RetryPolicy{})
+
+	query := "SELECT id FROM rhnchannel"
+	mock.ExpectQuery(query).WillReturnError(&pq.Error{Code: "42P01", Message: "relation does not exist"})
+	defer func() {
+		recover()
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}()
+	ExecuteQueryWithResults(db, query)
+	t.Errorf("Expected the query to fail")
+}
+
+func TestRetryable(t *testing.T) {
+	policy := RetryPolicy{}
+	if !policy.retryable(&pq.Error{Code: "40001"}) || policy.retryable(&pq.Error{Code: "57014"}) {
+		t.Errorf("Expected the default retryable errors")
+	}
+	if policy.retryable(errors.New("connection refused")) {
+		t.Errorf("Errors without SQLSTATE code must not be retried")
+	}
+	policy.RetryableErrors = []string{"57014"}
+	if !policy.retryable(&pq.Error{Code: "57014"}) || policy.retryable(&pq.Error{Code: "40001"}) {
+		t.Errorf("Expected the configured retryable errors")
+	}
+	copyFailure := newCopyError(errors.New("exit status 1"), "ERROR:  40P01: deadlock detected\nDETAIL: ...")
+	if errorCode(copyFailure) != "40P01" || policy.retryable(copyFailure) {
+		t.Errorf("Expected the SQLSTATE code of the psql error, got %s", errorCode(copyFailure))
+	}
+}
@@ -0,0 +1,218 @@
+package sqlUtil
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// snapshots shared by the connections of the databases opened with OpenSnapshot
+var snapshots = make(map[*sql.DB]string)
+var snapshotsLock sync.Mutex
+
+var errSnapshotTransaction = errors.New("transactions cannot be started on the connections of a snapshot")
+
+// OpenSnapshot returns a database whose connections all read the same REPEATABLE READ snapshot, so the rows read
+// by the queries of a long export are consistent with each other, and the function ending the snapshot. The
+// snapshot is exported by a transaction kept open until then, and every connection of the database runs in a
+// read-only transaction importing it.
+func OpenSnapshot(connectionString string) (*sql.DB, func()) {
+	connector, err := pq.NewConnector(connectionString)
+	if err != nil {
+		utils.Panic().Err(err).Msg("error getting connection to the database")
+	}
+	holder, err := connector.Connect(utils.Context())
+	if err != nil {
+		utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("error opening the snapshot connection")
+	}
+	snapshot, err := exportSnapshot(holder)
+	if err != nil {
+		holder.Close()
+		utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("error exporting the snapshot")
+	}
+	log.Debug().Msgf("Reading the database in snapshot %s", snapshot)
+	db := sql.OpenDB(&snapshotConnector{connector: connector, snapshot: snapshot})
+	snapshotsLock.Lock()
+	snapshots[db] = snapshot
+	snapshotsLock.Unlock()
+	return db, func() {
+		snapshotsLock.Lock()
+		delete(snapshots, db)
+		snapshotsLock.Unlock()
+		if err := execute(holder, "ROLLBACK;"); err != nil {
+			log.Warn().Err(err).Msg("error ending the snapshot transaction")
+		}
+		holder.Close()
+	}
+}
+
+// databaseSnapshot returns the snapshot of the database, empty when it was not opened with OpenSnapshot
+func databaseSnapshot(db *sql.DB) string {
+	snapshotsLock.Lock()
+	defer snapshotsLock.Unlock()
+	return snapshots[db]
+}
+
+// exportSnapshot starts the transaction of the snapshot on the connection, and returns the snapshot id
+func exportSnapshot(conn driver.Conn) (string, error) {
+	if err := execute(conn, "BEGIN ISOLATION LEVEL REPEATABLE READ READ ONLY;"); err != nil {
+		return "", err
+	}
+	rows, err := conn.(driver.QueryerContext).QueryContext(utils.Context(), "SELECT pg_export_snapshot();", nil)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	values := make([]driver.Value, 1)
+	if err := rows.Next(values); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s", values[0]), nil
+}
+
+func execute(conn driver.Conn, statement string) error {
+	_, err := conn.(driver.ExecerContext).ExecContext(utils.Context(), statement, nil)
+	return err
+}
+
+// snapshotConnector opens the connections in the transaction importing the snapshot
+type snapshotConnector struct {
+	connector driver.Connector
+	snapshot  string
+}
+
+func (connector *snapshotConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := connector.connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, statement := range []string{"BEGIN ISOLATION LEVEL REPEATABLE READ READ ONLY;",
+		fmt.Sprintf("SET TRANSACTION SNAPSHOT %s;", pq.QuoteLiteral(connector.snapshot))} {
+		if err := execute(conn, statement); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return &snapshotConn{conn: conn}, nil
+}
+
+func (connector *snapshotConnector) Driver() driver.Driver {
+	return connector.connector.Driver()
+}
+
+// snapshotConn is a connection in the transaction of the snapshot. A failed statement aborts the transaction,
+// so the connection is not used again: retried queries run on a new connection importing the snapshot.
+type snapshotConn struct {
+	conn   driver.Conn
+	failed bool
+}
+
+func (conn *snapshotConn) fail(err error) error {
+	if err != nil && err != io.EOF {
+		conn.failed = true
+	}
+	return err
+}
+
+// IsValid implements driver.Validator, closing the connections whose transaction failed
+func (conn *snapshotConn) IsValid() bool {
+	return !conn.failed
+}
+
+func (conn *snapshotConn) Prepare(query string) (driver.Stmt, error) {
+	statement, err := conn.conn.Prepare(query)
+	if err != nil {
+		return nil, conn.fail(err)
+	}
+	return &snapshotStmt{Stmt: statement, conn: conn}, nil
+}
+
+func (conn *snapshotConn) Close() error {
+	return conn.conn.Close()
+}
+
+func (conn *snapshotConn) Begin() (driver.Tx, error) {
+	return nil, errSnapshotTransaction
+}
+
+func (conn *snapshotConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	rows, err := conn.conn.(driver.QueryerContext).QueryContext(ctx, query, args)
+	if err != nil {
+		return nil, conn.fail(err)
+	}
+	return &snapshotRows{Rows: rows, conn: conn}, nil
+}
+
+func (conn *snapshotConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	result, err := conn.conn.(driver.ExecerContext).ExecContext(ctx, query, args)
+	return result, conn.fail(err)
+}
+
+func (conn *snapshotConn) Ping(ctx context.Context) error {
+	return conn.fail(conn.conn.(driver.Pinger).Ping(ctx))
+}
+
+type snapshotStmt struct {
+	driver.Stmt
+	conn *snapshotConn
+}
+
+func (statement *snapshotStmt) Exec(args []driver.Value) (driver.Result, error) {
+	result, err := statement.Stmt.Exec(args)
+	return result, statement.conn.fail(err)
+}
+
+func (statement *snapshotStmt) Query(args []driver.Value) (driver.Rows, error) {
+	rows, err := statement.Stmt.Query(args)
+	if err != nil {
+		return nil, statement.conn.fail(err)
+	}
+	return &snapshotRows{Rows: rows, conn: statement.conn}, nil
+}
+
+// snapshotRows forwards the column types of the driver rows, read by readRows
+type snapshotRows struct {
+	driver.Rows
+	conn *snapshotConn
+}
+
+func (rows *snapshotRows) Next(dest []driver.Value) error {
+	return rows.conn.fail(rows.Rows.Next(dest))
+}
+
+func (rows *snapshotRows) ColumnTypeScanType(index int) reflect.Type {
+	if typed, ok := rows.Rows.(driver.RowsColumnTypeScanType); ok {
+		return typed.ColumnTypeScanType(index)
+	}
+	return reflect.TypeOf(new(interface{})).Elem()
+}
+
+func (rows *snapshotRows) ColumnTypeDatabaseTypeName(index int) string {
+	if typed, ok := rows.Rows.(driver.RowsColumnTypeDatabaseTypeName); ok {
+		return typed.ColumnTypeDatabaseTypeName(index)
+	}
+	return ""
+}
+
+func (rows *snapshotRows) ColumnTypeLength(index int) (int64, bool) {
+	if typed, ok := rows.Rows.(driver.RowsColumnTypeLength); ok {
+		return typed.ColumnTypeLength(index)
+	}
+	return 0, false
+}
+
+func (rows *snapshotRows) ColumnTypePrecisionScale(index int) (int64, int64, bool) {
+	if typed, ok := rows.Rows.(driver.RowsColumnTypePrecisionScale); ok {
+		return typed.ColumnTypePrecisionScale(index)
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,73 @@
+package sqlUtil
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+// dsnConnector opens the connections of the sqlmock data source name
+type dsnConnector struct {
+	driver driver.Driver
+	dsn    string
+}
+
+func (connector dsnConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return connector.driver.Open(connector.dsn)
+}
+
+func (connector dsnConnector) Driver() driver.Driver {
+	return connector.driver
+}
+
+func TestSnapshotConnectionsAfterFailures(t *testing.T) {
+	mockDb, mock, err := sqlmock.NewWithDSN("snapshot", sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mockDb.Close()
+	db := sql.OpenDB(&snapshotConnector{connector: dsnConnector{driver: mockDb.Driver(), dsn: "snapshot"}, snapshot: "00000003-0000001B-1"})
+	defer db.Close()
+	SetRetryPolicy(RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond})
+	defer SetRetryPolicy(RetryPolicy{})
+
+	query := "SELECT id FROM rhnchannel"
+	for i := 0; i < 2; i++ {
+		// the connection of the failed query is replaced by a new one in the snapshot
+		mock.ExpectExec("BEGIN ISOLATION LEVEL REPEATABLE READ READ ONLY;").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("SET TRANSACTION SNAPSHOT '00000003-0000001B-1';").WillReturnResult(sqlmock.NewResult(0, 0))
+		if i == 0 {
+			mock.ExpectQuery(query).WillReturnError(&pq.Error{Code: "40P01", Message: "deadlock detected"})
+		}
+	}
+	mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	rows := ExecuteQueryWithResults(db, query)
+
+	if len(rows) != 1 {
+		t.Errorf("Expected the row of the second attempt, got %v", rows)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSnapshotTransactions(t *testing.T) {
+	mockDb, mock, err := sqlmock.NewWithDSN("snapshot-transactions", sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mockDb.Close()
+	db := sql.OpenDB(&snapshotConnector{connector: dsnConnector{driver: mockDb.Driver(), dsn: "snapshot-transactions"}, snapshot: "1"})
+	defer db.Close()
+	mock.ExpectExec("BEGIN ISOLATION LEVEL REPEATABLE READ READ ONLY;").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SET TRANSACTION SNAPSHOT '1';").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if _, err := db.Begin(); err != errSnapshotTransaction {
+		t.Errorf("Transactions cannot be nested in the transaction of the snapshot, got %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+package sqlUtil
+
+import (
+	"context"
+	"time"
+
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// Timeouts limits the time spent waiting for the database, 0 for no limit
+type Timeouts struct {
+	// maximum duration of a query, including reading its rows
+	Statement time.Duration
+	// maximum duration of opening a connection
+	Connect time.Duration
+}
+
+// timeouts of the running export or import
+var timeouts Timeouts
+
+// SetTimeouts sets the timeouts of the queries run and connections opened afterwards
+func SetTimeouts(values Timeouts) {
+	timeouts = values
+}
+
+// ConnectTimeout returns the maximum duration of opening a connection, 0 for no limit
+func ConnectTimeout() time.Duration {
+	return timeouts.Connect
+}
+
+// queryContext returns the context of a query, canceled when the statement timeout expires or the export
+// or import is canceled
+func queryContext() (context.Context, context.CancelFunc) {
+	if timeouts.Statement > 0 {
+		return context.WithTimeout(utils.Context(), timeouts.Statement)
+	}
+	return context.WithCancel(utils.Context())
+}
+
+// queryFailed stops the export or import after a failed query, telling if the statement timeout expired
+func queryFailed(ctx context.Context, err error, msg string) {
+	if ctx.Err() == context.DeadlineExceeded && utils.Context().Err() == nil {
+		utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msgf("%s: query exceeded the statement timeout of %s", msg, timeouts.Statement)
+	}
+	utils.Panic().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg(msg)
+}
@@ -0,0 +1,23 @@
+package sqlUtil
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQueryContext(t *testing.T) {
+	defer SetTimeouts(Timeouts{})
+	ctx, cancel := queryContext()
+	if _, ok := ctx.Deadline(); ok {
+		t.Errorf("Queries without statement timeout should have no deadline")
+	}
+	cancel()
+
+	SetTimeouts(Timeouts{Statement: time.Minute})
+	ctx, cancel = queryContext()
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	if !ok || time.Until(deadline) > time.Minute {
+		t.Errorf("Expected a deadline within the statement timeout, got %v", deadline)
+	}
+}
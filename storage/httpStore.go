@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// the transfers of large files are not limited, only the wait for the response headers
+var httpClient = &http.Client{Transport: &http.Transport{Proxy: http.ProxyFromEnvironment,
+	TLSClientConfig: utils.RestrictTLS(&tls.Config{}), ResponseHeaderTimeout: 5 * time.Minute}}
+
+// httpStore reads the files with GET requests and writes them with PUT requests, like WebDAV servers accept them.
+// The user and password of the location authenticate the requests.
+type httpStore struct {
+	base *url.URL
+}
+
+func newHttpStore(location string) (Store, error) {
+	base, err := url.Parse(strings.TrimSuffix(location, "/") + "/")
+	if err != nil {
+		return nil, fmt.Errorf("invalid HTTP file store %s: %w", location, err)
+	}
+	return httpStore{base: base}, nil
+}
+
+func (store httpStore) url(filePath string) (string, error) {
+	cleaned, err := cleanPath(filePath)
+	if err != nil {
+		return "", err
+	}
+	return store.base.String() + escapePath(cleaned, url.PathEscape), nil
+}
+
+func (store httpStore) request(method string, filePath string, body io.Reader, length int64) (*http.Response, error) {
+	fileUrl, err := store.url(filePath)
+	if err != nil {
+		return nil, err
+	}
+	request, err := http.NewRequestWithContext(utils.Context(), method, fileUrl, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		request.ContentLength = length
+	}
+	return httpClient.Do(request)
+}
+
+func (store httpStore) Open(filePath string) (io.ReadCloser, error) {
+	response, err := store.request(http.MethodGet, filePath, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err := responseError(response, filePath); err != nil {
+		return nil, err
+	}
+	return response.Body, nil
+}
+
+func (store httpStore) Create(filePath string) (Writer, error) {
+	return newUploadWriter(func(content *os.File, length int64) error {
+		response, err := store.request(http.MethodPut, filePath, content, length)
+		if err != nil {
+			return err
+		}
+		defer response.Body.Close()
+		return responseError(response, filePath)
+	})
+}
+
+func (store httpStore) Exists(filePath string) (bool, error) {
+	response, err := store.request(http.MethodHead, filePath, nil, 0)
+	if err != nil {
+		return false, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	return true, responseError(response, filePath)
+}
+
+func (store httpStore) Remove(filePath string) error {
+	response, err := store.request(http.MethodDelete, filePath, nil, 0)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	return responseError(response, filePath)
+}
+
+func (store httpStore) List(folder string) ([]string, error) {
+	return nil, fmt.Errorf("the files of HTTP stores cannot be listed, %s", store)
+}
+
+func (store httpStore) Close() error {
+	return nil
+}
+
+func (store httpStore) String() string {
+	return store.base.Redacted()
+}
+
+// responseError returns the error of a failed request, closing its body
+func responseError(response *http.Response, filePath string) error {
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return nil
+	}
+	defer response.Body.Close()
+	message, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+	if response.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%s: %w", filePath, fs.ErrNotExist)
+	}
+	return fmt.Errorf("%s: %s %s", filePath, response.Status, strings.TrimSpace(string(message)))
+}
+
+// escapePath escapes the segments of the path with the function
+func escapePath(filePath string, escape func(segment string) string) string {
+	segments := strings.Split(filePath, "/")
+	for i, segment := range segments {
+		segments[i] = escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,276 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// payloads are not hashed, the files are not read twice to sign the requests
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// s3Store holds the files as the objects of a bucket, under a key prefix, signing the requests with Signature
+// Version 4
+type s3Store struct {
+	bucket       string
+	prefix       string
+	region       string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	// endpoint of S3 compatible services, addressing the buckets in the path. Amazon S3 addresses them in the host
+	endpoint string
+	now      func() time.Time
+}
+
+// newS3Store returns the store of the bucket/prefix location
+func newS3Store(location string) (Store, error) {
+	location = strings.Trim(location, "/")
+	bucket, prefix := location, ""
+	if separator := strings.Index(location, "/"); separator >= 0 {
+		bucket, prefix = location[:separator], location[separator+1:]+"/"
+	}
+	if len(bucket) == 0 {
+		return nil, fmt.Errorf("no bucket in the S3 store s3://%s, expected s3://bucket/prefix", location)
+	}
+	store := s3Store{bucket: bucket, prefix: prefix, region: os.Getenv("AWS_REGION"), accessKey: os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey: os.Getenv("AWS_SECRET_ACCESS_KEY"), sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		endpoint: strings.TrimSuffix(os.Getenv("AWS_ENDPOINT_URL_S3"), "/"), now: time.Now}
+	if len(store.region) == 0 {
+		store.region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if len(store.region) == 0 {
+		store.region = "us-east-1"
+	}
+	if len(store.accessKey) == 0 || len(store.secretKey) == 0 {
+		return nil, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are needed to access the S3 store s3://%s", location)
+	}
+	return store, nil
+}
+
+// objectUrl returns the URL of the object with the key, escaped as it is signed
+func (store s3Store) objectUrl(key string) string {
+	escapedKey := escapePath(key, awsEscape)
+	if len(store.endpoint) > 0 {
+		return store.endpoint + "/" + awsEscape(store.bucket) + "/" + escapedKey
+	}
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", store.bucket, store.region, escapedKey)
+}
+
+func (store s3Store) key(filePath string) (string, error) {
+	cleaned, err := cleanPath(filePath)
+	if err != nil {
+		return "", err
+	}
+	return store.prefix + cleaned, nil
+}
+
+func (store s3Store) request(method string, key string, query url.Values, body io.Reader, length int64) (*http.Response, error) {
+	requestUrl := store.objectUrl(key)
+	if len(query) > 0 {
+		requestUrl += "?" + canonicalQuery(query)
+	}
+	request, err := http.NewRequestWithContext(utils.Context(), method, requestUrl, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		request.ContentLength = length
+	}
+	store.sign(request)
+	return httpClient.Do(request)
+}
+
+// sign adds the Signature Version 4 authorization header of the request, signing the host and the headers set
+func (store s3Store) sign(request *http.Request) {
+	now := store.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	request.Header.Set("x-amz-date", amzDate)
+	if len(request.Header.Get("x-amz-content-sha256")) == 0 {
+		request.Header.Set("x-amz-content-sha256", unsignedPayload)
+	}
+	if len(store.sessionToken) > 0 {
+		request.Header.Set("x-amz-security-token", store.sessionToken)
+	}
+	headers := map[string]string{"host": request.URL.Host}
+	for name, values := range request.Header {
+		headers[strings.ToLower(name)] = strings.Join(values, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	canonicalRequest := strings.Join([]string{request.Method, request.URL.EscapedPath(), canonicalQuery(request.URL.Query()),
+		canonicalHeaders.String(), strings.Join(names, ";"), request.Header.Get("x-amz-content-sha256")}, "\n")
+	scope := date + "/" + store.region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+	signingKey := hmacSha256(hmacSha256(hmacSha256(hmacSha256([]byte("AWS4"+store.secretKey), date), store.region), "s3"), "aws4_request")
+	request.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		store.accessKey, scope, strings.Join(names, ";"), hex.EncodeToString(hmacSha256(signingKey, stringToSign))))
+}
+
+func (store s3Store) Open(filePath string) (io.ReadCloser, error) {
+	key, err := store.key(filePath)
+	if err != nil {
+		return nil, err
+	}
+	response, err := store.request(http.MethodGet, key, nil, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err := responseError(response, filePath); err != nil {
+		return nil, err
+	}
+	return response.Body, nil
+}
+
+func (store s3Store) Create(filePath string) (Writer, error) {
+	key, err := store.key(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return newUploadWriter(func(content *os.File, length int64) error {
+		response, err := store.request(http.MethodPut, key, nil, content, length)
+		if err != nil {
+			return err
+		}
+		defer response.Body.Close()
+		return responseError(response, filePath)
+	})
+}
+
+func (store s3Store) Exists(filePath string) (bool, error) {
+	key, err := store.key(filePath)
+	if err != nil {
+		return false, err
+	}
+	response, err := store.request(http.MethodHead, key, nil, nil, 0)
+	if err != nil {
+		return false, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	return true, responseError(response, filePath)
+}
+
+func (store s3Store) Remove(filePath string) error {
+	key, err := store.key(filePath)
+	if err != nil {
+		return err
+	}
+	response, err := store.request(http.MethodDelete, key, nil, nil, 0)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	return responseError(response, filePath)
+}
+
+// listBucketResult is the page of the objects of a bucket returned by ListObjectsV2
+type listBucketResult struct {
+	Contents []struct {
+		Key string
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+func (store s3Store) List(folder string) ([]string, error) {
+	prefix, err := store.key(folder)
+	if err != nil {
+		return nil, err
+	}
+	if len(prefix) > 0 && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	files := make([]string, 0)
+	query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+	for {
+		response, err := store.request(http.MethodGet, "", query, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		if err := responseError(response, folder); err != nil {
+			return nil, err
+		}
+		result := listBucketResult{}
+		err = xml.NewDecoder(response.Body).Decode(&result)
+		response.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error reading the objects of %s: %w", store, err)
+		}
+		for _, object := range result.Contents {
+			if !strings.HasSuffix(object.Key, "/") {
+				files = append(files, strings.TrimPrefix(object.Key, store.prefix))
+			}
+		}
+		if !result.IsTruncated || len(result.NextContinuationToken) == 0 {
+			return files, nil
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+}
+
+func (store s3Store) Close() error {
+	return nil
+}
+
+func (store s3Store) String() string {
+	return s3Prefix + store.bucket + "/" + store.prefix
+}
+
+// canonicalQuery returns the query sorted by name, escaped as it is signed
+func canonicalQuery(query url.Values) string {
+	parameters := make([]string, 0, len(query))
+	for name, values := range query {
+		for _, value := range values {
+			parameters = append(parameters, awsEscape(name)+"="+awsEscape(value))
+		}
+	}
+	sort.Strings(parameters)
+	return strings.Join(parameters, "&")
+}
+
+// awsEscape escapes all the characters but the unreserved ones, as Signature Version 4 expects
+func awsEscape(value string) string {
+	var escaped strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-' || c == '_' || c == '.' || c == '~' {
+			escaped.WriteByte(c)
+		} else {
+			escaped.WriteString(fmt.Sprintf("%%%02X", c))
+		}
+	}
+	return escaped.String()
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,284 @@
+// Package storage reads and writes the package and image files of the servers where they are kept: local or NFS
+// mounted folders, S3 buckets and HTTP servers, so the files don't need to be staged in the local folders of the
+// servers.
+package storage
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Store holds files by their path relative to the root of the store, with slashes as separators
+type Store interface {
+	// Open returns the content of the file, an error wrapping fs.ErrNotExist when it doesn't exist
+	Open(filePath string) (io.ReadCloser, error)
+	// Create returns the writer of the file, which is stored when the writer is closed without error, and
+	// discarded when it is aborted
+	Create(filePath string) (Writer, error)
+	// Exists checks if the file is in the store
+	Exists(filePath string) (bool, error)
+	// Remove removes the file from the store
+	Remove(filePath string) error
+	// List returns the paths of the files in the folder and its subfolders
+	List(folder string) ([]string, error)
+	// Close releases the store, like the mount of NFS stores
+	Close() error
+	// String describes the store in the log, without its credentials
+	String() string
+}
+
+// Writer writes a file of a store. The file is only stored by Close, a file aborted after a failed write is
+// discarded, leaving the previous content of the file in the store.
+type Writer interface {
+	io.Writer
+	Close() error
+	Abort()
+}
+
+const (
+	filePrefix  = "file://"
+	nfsPrefix   = "nfs://"
+	s3Prefix    = "s3://"
+	httpPrefix  = "http://"
+	httpsPrefix = "https://"
+)
+
+// Open returns the store of the location: a local folder (/path or file:///path), an NFS export mounted while the
+// store is open (nfs://host/export), an S3 bucket (s3://bucket/prefix) or an HTTP server reading files with GET and
+// writing them with PUT (http:// or https://). The S3 credentials are read from AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN, the region from AWS_REGION, and the endpoint of S3 compatible
+// services from AWS_ENDPOINT_URL_S3.
+func Open(location string) (Store, error) {
+	switch {
+	case strings.HasPrefix(location, filePrefix):
+		return newLocalStore(strings.TrimPrefix(location, filePrefix))
+	case strings.HasPrefix(location, nfsPrefix):
+		return mountNfsStore(strings.TrimPrefix(location, nfsPrefix))
+	case strings.HasPrefix(location, s3Prefix):
+		return newS3Store(strings.TrimPrefix(location, s3Prefix))
+	case strings.HasPrefix(location, httpPrefix), strings.HasPrefix(location, httpsPrefix):
+		return newHttpStore(location)
+	case strings.HasPrefix(location, "/"):
+		return newLocalStore(location)
+	}
+	return nil, fmt.Errorf("unknown file store %s, allowed are absolute paths, file://, nfs://, s3://, http:// and https:// locations", location)
+}
+
+// cleanPath returns the path relative to the root of the store, refusing paths leaving it
+func cleanPath(filePath string) (string, error) {
+	cleaned := path.Clean("/" + filepath.ToSlash(filePath))
+	if strings.Contains("/"+filepath.ToSlash(filePath)+"/", "/../") {
+		return "", fmt.Errorf("%s is outside of the store", filePath)
+	}
+	return strings.TrimPrefix(cleaned, "/"), nil
+}
+
+// localStore holds the files in a folder of the local file system
+type localStore struct {
+	root string
+}
+
+func newLocalStore(root string) (Store, error) {
+	if !filepath.IsAbs(root) {
+		return nil, fmt.Errorf("the folder of the file store %s is not an absolute path", root)
+	}
+	if info, err := os.Stat(root); err != nil {
+		return nil, err
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a folder", root)
+	}
+	return localStore{root: filepath.Clean(root)}, nil
+}
+
+func (store localStore) path(filePath string) (string, error) {
+	cleaned, err := cleanPath(filePath)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(store.root, filepath.FromSlash(cleaned)), nil
+}
+
+func (store localStore) Open(filePath string) (io.ReadCloser, error) {
+	localPath, err := store.path(filePath)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a regular file", localPath)
+	}
+	return os.Open(localPath)
+}
+
+func (store localStore) Create(filePath string) (Writer, error) {
+	localPath, err := store.path(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(localPath), 0770); err != nil {
+		return nil, err
+	}
+	// written next to the file, so it is renamed on the same file system
+	file, err := os.CreateTemp(filepath.Dir(localPath), "."+filepath.Base(localPath)+".")
+	if err != nil {
+		return nil, err
+	}
+	return &localWriter{File: file, path: localPath}, nil
+}
+
+// localWriter writes the file of a local store into a temporary file, renamed to the file when it is closed
+type localWriter struct {
+	*os.File
+	path string
+}
+
+func (writer *localWriter) Close() error {
+	if err := writer.File.Close(); err != nil {
+		os.Remove(writer.File.Name())
+		return err
+	}
+	// temporary files are only readable by their owner
+	if err := os.Chmod(writer.File.Name(), 0644); err != nil {
+		os.Remove(writer.File.Name())
+		return err
+	}
+	if err := os.Rename(writer.File.Name(), writer.path); err != nil {
+		os.Remove(writer.File.Name())
+		return err
+	}
+	return nil
+}
+
+func (writer *localWriter) Abort() {
+	writer.File.Close()
+	os.Remove(writer.File.Name())
+}
+
+func (store localStore) Exists(filePath string) (bool, error) {
+	localPath, err := store.path(filePath)
+	if err != nil {
+		return false, err
+	}
+	if _, err := os.Stat(localPath); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (store localStore) Remove(filePath string) error {
+	localPath, err := store.path(filePath)
+	if err != nil {
+		return err
+	}
+	return os.Remove(localPath)
+}
+
+func (store localStore) List(folder string) ([]string, error) {
+	localFolder, err := store.path(folder)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0)
+	err = filepath.WalkDir(localFolder, func(walkedPath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.Type().IsRegular() {
+			relativePath, err := filepath.Rel(store.root, walkedPath)
+			if err != nil {
+				return err
+			}
+			files = append(files, filepath.ToSlash(relativePath))
+		}
+		return nil
+	})
+	return files, err
+}
+
+func (store localStore) Close() error {
+	return nil
+}
+
+func (store localStore) String() string {
+	return store.root
+}
+
+// nfsStore is a local store in the folder the NFS export is mounted on
+type nfsStore struct {
+	localStore
+	export string
+}
+
+// mountNfsStore mounts the export, in the host/path format, on a temporary folder
+func mountNfsStore(export string) (Store, error) {
+	separator := strings.Index(export, "/")
+	if separator <= 0 {
+		return nil, fmt.Errorf("no host and path in the NFS store nfs://%s, expected nfs://host/path", export)
+	}
+	mountPoint, err := os.MkdirTemp("", "inter-server-sync-nfs-")
+	if err != nil {
+		return nil, err
+	}
+	source := export[:separator] + ":" + export[separator:]
+	if output, err := exec.Command("mount", "-t", "nfs", source, mountPoint).CombinedOutput(); err != nil {
+		os.Remove(mountPoint)
+		return nil, fmt.Errorf("error mounting %s: %w: %s", source, err, strings.TrimSpace(string(output)))
+	}
+	return nfsStore{localStore: localStore{root: mountPoint}, export: source}, nil
+}
+
+func (store nfsStore) Close() error {
+	if output, err := exec.Command("umount", store.root).CombinedOutput(); err != nil {
+		return fmt.Errorf("error unmounting %s from %s: %w: %s", store.export, store.root, err, strings.TrimSpace(string(output)))
+	}
+	return os.Remove(store.root)
+}
+
+func (store nfsStore) String() string {
+	return nfsPrefix + strings.Replace(store.export, ":", "", 1)
+}
+
+// uploadWriter keeps the content of a file in a temporary file until it is closed, as the stores writing
+// files over HTTP need their length before sending them
+type uploadWriter struct {
+	*os.File
+	upload func(content *os.File, length int64) error
+}
+
+func newUploadWriter(upload func(content *os.File, length int64) error) (Writer, error) {
+	file, err := os.CreateTemp("", "inter-server-sync-upload-")
+	if err != nil {
+		return nil, err
+	}
+	return &uploadWriter{File: file, upload: upload}, nil
+}
+
+func (writer *uploadWriter) Close() error {
+	defer os.Remove(writer.File.Name())
+	defer writer.File.Close()
+	length, err := writer.File.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := writer.File.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return writer.upload(writer.File, length)
+}
+
+func (writer *uploadWriter) Abort() {
+	writer.File.Close()
+	os.Remove(writer.File.Name())
+}
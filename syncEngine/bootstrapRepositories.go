@@ -0,0 +1,41 @@
+package syncEngine
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/entityDumper"
+	"github.com/uyuni-project/inter-server-sync/tracing"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// runBootstrapRepositorySync copies the exported bootstrap repositories to the target server, so its clients can
+// be onboarded without running mgr-create-bootstrap-repo
+func (run *importRun) runBootstrapRepositorySync(absImportDir string) {
+	repositoriesDir := filepath.Join(absImportDir, entityDumper.BootstrapRepositoriesFolder)
+	if _, err := os.Stat(repositoriesDir); err != nil {
+		log.Debug().Msg("No bootstrap repositories to import")
+		return
+	}
+	defer tracing.StartSpan("copy bootstrap repositories").End()
+	rsyncParams := make([]string, 0)
+	if log.Debug().Enabled() {
+		rsyncParams = append(rsyncParams, "-v")
+	}
+	rsyncParams = append(rsyncParams, "-r", "--chmod=Du=rwx,Dgo=rx,Fu=rw,Fgo=r", repositoriesDir+"/",
+		run.targetPath(entityDumper.BootstrapRepositoriesDir+"/"))
+	log.Info().Msg("Copying bootstrap repositories")
+	cmd := exec.CommandContext(utils.Context(), "rsync", rsyncParams...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		utils.Fatal().Err(err).Msg("Error importing the bootstrap repositories")
+	}
+	listFile := filepath.Join(absImportDir, entityDumper.BootstrapRepositoriesFileName)
+	if _, err := os.Stat(listFile); err == nil {
+		log.Info().Msgf("Bootstrap repositories imported: %s", strings.Join(utils.ReadFileByLine(listFile), ", "))
+	}
+}
@@ -0,0 +1,108 @@
+package syncEngine
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+var foreignKeysSql = `SELECT conname, pg_get_constraintdef(oid) FROM pg_constraint
+	WHERE conrelid = $1::regclass AND contype = 'f' ORDER BY conname`
+
+// indexes not enforcing a constraint
+var secondaryIndexesSql = `SELECT c.relname, pg_get_indexdef(i.indexrelid) FROM pg_index i
+	JOIN pg_class c ON c.oid = i.indexrelid
+	WHERE i.indrelid = $1::regclass AND NOT i.indisunique AND NOT i.indisprimary
+	AND NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conindid = i.indexrelid) ORDER BY c.relname`
+
+var referencedTableSql = `SELECT 1 FROM pg_constraint WHERE confrelid = $1::regclass AND contype = 'f' LIMIT 1`
+
+// bulkLoad drops the foreign keys and secondary indexes of the imported tables, which are restored after the import.
+// The rows are inserted by the statements of the export, not with COPY, as they look up the referenced rows.
+type bulkLoad struct {
+	restoreFile string
+	drop        []string
+	restore     []string
+	validate    []string
+}
+
+// newBulkLoad collects the constraints and indexes to drop. Secondary indexes are kept when the import looks up
+// rows of the table: for tables referenced by others and tables without a unique index to detect conflicts.
+func newBulkLoad(db *sql.DB, tableNames []string, restoreFile string) *bulkLoad {
+	load := &bulkLoad{restoreFile: restoreFile}
+	sort.Strings(tableNames)
+	schemaMetadata := schemareader.ReadTablesSchema(db, tableNames)
+	for _, tableName := range tableNames {
+		for _, row := range sqlUtil.ExecuteQueryWithResults(db, foreignKeysSql, tableName) {
+			name := pq.QuoteIdentifier(fmt.Sprintf("%v", row[0].Value))
+			load.drop = append(load.drop, fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s;", tableName, name))
+			load.restore = append(load.restore, fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s %v NOT VALID;", tableName, name, row[1].Value))
+			load.validate = append(load.validate, fmt.Sprintf("ALTER TABLE %s VALIDATE CONSTRAINT %s;", tableName, name))
+		}
+		table, ok := schemaMetadata[tableName]
+		if !ok || table.MainUniqueIndexName == schemareader.VirtualIndexName ||
+			len(sqlUtil.ExecuteQueryWithResults(db, referencedTableSql, tableName)) > 0 {
+			continue
+		}
+		for _, row := range sqlUtil.ExecuteQueryWithResults(db, secondaryIndexesSql, tableName) {
+			load.drop = append(load.drop, fmt.Sprintf("DROP INDEX %s;", pq.QuoteIdentifier(fmt.Sprintf("%v", row[0].Value))))
+			load.restore = append(load.restore, fmt.Sprintf("%v;", row[1].Value))
+		}
+	}
+	return load
+}
+
+// prepare saves the statements restoring the constraints and indexes, then drops them
+func (load *bulkLoad) prepare(db *sql.DB) {
+	script := strings.Join(append(append([]string{}, load.restore...), load.validate...), "\n") + "\n"
+	if err := os.WriteFile(load.restoreFile, []byte(script), 0600); err != nil {
+		utils.Fatal().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("Error saving the constraints and indexes of the imported tables")
+	}
+	log.Info().Msgf("Dropping %d foreign keys and indexes, restored by %s if the import is interrupted",
+		len(load.drop), load.restoreFile)
+	transaction, err := db.Begin()
+	if err != nil {
+		utils.Fatal().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("Error dropping the constraints and indexes of the imported tables")
+	}
+	for _, statement := range load.drop {
+		if _, err := transaction.Exec(statement); err != nil {
+			transaction.Rollback()
+			utils.Fatal().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msgf("Error running %s", statement)
+		}
+	}
+	if err := transaction.Commit(); err != nil {
+		utils.Fatal().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msg("Error dropping the constraints and indexes of the imported tables")
+	}
+}
+
+// finish creates the indexes and foreign keys again and validates the imported rows against the foreign keys.
+// Constraints violated by the imported rows are kept without validation and reported.
+func (load *bulkLoad) finish(db *sql.DB) {
+	log.Info().Msgf("Restoring %d foreign keys and indexes", len(load.restore))
+	failed := false
+	for _, statement := range load.restore {
+		if _, err := db.Exec(statement); err != nil {
+			failed = true
+			log.Error().Err(err).Msgf("Error running %s", statement)
+		}
+	}
+	for _, statement := range load.validate {
+		if _, err := db.Exec(statement); err != nil {
+			failed = true
+			log.Error().Err(err).Msgf("Imported rows violate a foreign key, %s failed", statement)
+		}
+	}
+	if failed {
+		log.Error().Msgf("Not all constraints and indexes were restored, please check the statements of %s", load.restoreFile)
+		return
+	}
+	os.Remove(load.restoreFile)
+}
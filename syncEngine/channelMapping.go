@@ -0,0 +1,71 @@
+package syncEngine
+
+import (
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// channels are referenced by label in the exported statements, with a lookup followed by the quoted label
+const channelReferenceClause = "FROM rhnchannel WHERE label ="
+
+// parseChannelRenames reads the old-label=new-label entries of the channel renames, indexed by old label
+func parseChannelRenames(entries []string) (map[string]string, bool) {
+	result := make(map[string]string)
+	for _, entry := range entries {
+		separator := strings.Index(entry, "=")
+		if separator < 0 {
+			return nil, false
+		}
+		oldLabel := strings.TrimSpace(entry[:separator])
+		newLabel := strings.TrimSpace(entry[separator+1:])
+		if len(oldLabel) == 0 || len(newLabel) == 0 {
+			return nil, false
+		}
+		result[oldLabel] = newLabel
+	}
+	return result, true
+}
+
+// renamedChannelLabel returns the label a channel is imported with
+func renamedChannelLabel(channelRenames map[string]string, label string) string {
+	if newLabel, ok := channelRenames[label]; ok {
+		return newLabel
+	}
+	return label
+}
+
+// quoteChannelRenames returns the channel renames as SQL literals, as they are found in the statements
+func quoteChannelRenames(channelRenames map[string]string) map[string]string {
+	result := make(map[string]string)
+	for oldLabel, newLabel := range channelRenames {
+		result[strings.TrimSpace(pq.QuoteLiteral(oldLabel))] = pq.QuoteLiteral(newLabel)
+	}
+	return result
+}
+
+// rewriteChannelReferences renames the channels inserted by the statement and the channels it refers to,
+// with the renames quoted as SQL literals. Only the value of the label column and the label lookups are renamed,
+// other literals with the same text are data.
+func rewriteChannelReferences(statement string, quotedRenames map[string]string) string {
+	if !strings.Contains(statement, "rhnchannel") {
+		return statement
+	}
+	parts, _ := splitStatement(statement)
+	rename := func(part *statementPart) {
+		if newLabel, ok := quotedRenames[literalValue(*part)]; ok {
+			part.text = newLabel
+		}
+	}
+	if tableName, values := insertedColumnValues(parts); tableName == "rhnchannel" {
+		if label, ok := values["label"]; ok {
+			rename(&parts[label])
+		}
+	}
+	for i := 1; i < len(parts); i++ {
+		if parts[i].literal && !parts[i-1].literal && followsSql(parts[i-1].text, channelReferenceClause) {
+			rename(&parts[i])
+		}
+	}
+	return joinStatement(parts)
+}
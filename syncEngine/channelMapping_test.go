@@ -0,0 +1,47 @@
+package syncEngine
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRewriteChannelReferencesInsertedLabel(t *testing.T) {
+	// the summary has the label of the channel, which is data
+	statement := exportChannelStatement(t, "dev", "org", "dev")
+
+	result := rewriteChannelReferences(statement, quoteChannelRenames(map[string]string{"dev": "prod"}))
+
+	if !strings.Contains(result, "'prod',(SELECT id FROM web_customer WHERE name = 'org' LIMIT 1),'dev')") {
+		t.Errorf("only the label column should be renamed, got %s", result)
+	}
+}
+
+func TestRewriteChannelReferencesOrgNamedAsChannel(t *testing.T) {
+	statement := exportChannelStatement(t, "other", "dev", "summary")
+
+	result := rewriteChannelReferences(statement, quoteChannelRenames(map[string]string{"dev": "prod"}))
+
+	if result != statement {
+		t.Errorf("organizations named as a renamed channel should not be renamed, got %s", result)
+	}
+}
+
+func TestRewriteChannelReferencesLookup(t *testing.T) {
+	statement := exportDistChannelMapStatement(t, "dev", "dev")
+
+	result := rewriteChannelReferences(statement, quoteChannelRenames(map[string]string{"dev": "prod"}))
+
+	if !strings.Contains(result, "'dev',(SELECT id FROM rhnchannel WHERE label = 'prod' LIMIT 1)") {
+		t.Errorf("only the channel lookup should be renamed, got %s", result)
+	}
+}
+
+func TestRewriteChannelReferencesEscapedLabel(t *testing.T) {
+	statement := exportDistChannelMapStatement(t, "15", `dev\channel`)
+
+	result := rewriteChannelReferences(statement, quoteChannelRenames(map[string]string{`dev\channel`: "prod"}))
+
+	if !strings.Contains(strings.Join(strings.Fields(result), " "), "(SELECT id FROM rhnchannel WHERE label = 'prod' LIMIT 1)") {
+		t.Errorf("channel lookup with an escaped label should be renamed, got %s", result)
+	}
+}
@@ -0,0 +1,79 @@
+package syncEngine
+
+import (
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/entityDumper"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// sharedExportFolder returns the export holding the files shared by the channels, when importing a
+// channel exported into its own subdirectory
+func sharedExportFolder(absImportDir string) (string, bool) {
+	parent := path.Dir(absImportDir)
+	if path.Base(parent) != entityDumper.ChannelSubdirectoriesFolder {
+		return "", false
+	}
+	if _, err := os.Stat(path.Join(absImportDir, entityDumper.PackageFilesListName)); err != nil {
+		return "", false
+	}
+	return path.Dir(parent), true
+}
+
+// runSharedPackageFileSync copies only the package files of the channel from the packages of the export
+func (run *importRun) runSharedPackageFileSync(absImportDir string, exportFolder string) {
+	packageFilesList := path.Join(absImportDir, entityDumper.PackageFilesListName)
+	if info, err := os.Stat(packageFilesList); err != nil || info.Size() == 0 {
+		log.Info().Msg("no package files to import")
+		return
+	}
+	if run.packageStore != nil {
+		log.Info().Msgf("starting uploading package files to %s", run.packageStore)
+		uploadListedFiles(run.packageStore, exportFolder, utils.ReadFileByLine(packageFilesList), "package files")
+		return
+	}
+
+	rsyncParams := make([]string, 0)
+	if log.Debug().Enabled() {
+		rsyncParams = append(rsyncParams, "-v")
+	}
+	rsyncParams = append(rsyncParams, "-og", "--chown=wwwrun:www", "-r", "--files-from="+packageFilesList,
+		exportFolder+"/", run.targetPath("/var/spacewalk/"))
+
+	cmd := exec.CommandContext(utils.Context(), "rsync", rsyncParams...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	log.Info().Msg("starting importing package files")
+	if err := cmd.Run(); err != nil {
+		utils.Fatal().Err(err).Msg("error importing package files")
+	}
+}
+
+// runSharedRepodataSync copies the repository metadata of the channel from the repodata of the export
+func (run *importRun) runSharedRepodataSync(absImportDir string, exportFolder string) {
+	rsyncParams := make([]string, 0)
+	if log.Debug().Enabled() {
+		rsyncParams = append(rsyncParams, "-v")
+	}
+	rsyncParams = append(rsyncParams, "-og", "--chown=wwwrun:www", "-r")
+
+	for _, label := range utils.ReadFileByLine(path.Join(absImportDir, "exportedChannels.txt")) {
+		label = strings.TrimSpace(label)
+		source := path.Join(exportFolder, "repodata", label)
+		if err := utils.FolderExists(source); err != nil || len(label) == 0 {
+			continue
+		}
+		log.Info().Msgf("Copying repository metadata of %s", label)
+		target := run.targetPath(path.Join("/var/cache/rhn/repodata", renamedChannelLabel(run.channelRenames, label)) + "/")
+		cmd := exec.CommandContext(utils.Context(), "rsync", append(append([]string{}, rsyncParams...), source+"/", target)...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			utils.Fatal().Err(err).Msg("Error importing repository metadata")
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package syncEngine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/credentials"
+	"github.com/uyuni-project/inter-server-sync/dumper"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// readCredentialsKey reads the key opening the sealed passwords, nil without key file
+func (run *importRun) readCredentialsKey() []byte {
+	if len(run.CredentialsKeyFile) == 0 {
+		return nil
+	}
+	key, err := credentials.ReadKeyFile(utils.GetAbsPath(run.CredentialsKeyFile))
+	if err != nil {
+		utils.Fatal().Err(err).Int(utils.ExitCodeField, utils.ExitConfigError).Msg("Invalid credentials key file")
+	}
+	return key
+}
+
+// unsealCredentials returns a rewrite function replacing the sealed passwords of the image store credentials with
+// the ones opened with the key, or asked with the prompt when there is no key
+func unsealCredentials(key []byte, prompt func(description string) (string, error),
+	rewrite func(statement string) string) func(statement string) string {
+
+	warned := false
+	return func(statement string) string {
+		if rewrite != nil {
+			statement = rewrite(statement)
+		}
+		if tableName, ok := dumper.StatementTable(statement); !ok || tableName != "susecredentials" {
+			return statement
+		}
+		parts, _ := splitStatement(statement)
+		_, values := insertedColumnValues(parts)
+		passwordPart, ok := values["password"]
+		if !ok {
+			return statement
+		}
+		password := literalContent(parts[passwordPart])
+		description := describeCredentials(parts, values)
+		switch {
+		case password == dumper.LockedPassword:
+			if !warned {
+				log.Warn().Msg("The export has no image store passwords: new image stores need their password to be set on the target")
+				warned = true
+			}
+			return statement
+		case !credentials.IsSealed(password):
+			return statement
+		case key != nil:
+			opened, err := credentials.Unseal(key, password)
+			if err != nil {
+				utils.Fatal().Err(err).Int(utils.ExitCodeField, utils.ExitConfigError).
+					Msgf("The password of the image store credentials %s cannot be opened, check --credentialsKeyFile", description)
+			}
+			password = opened
+		case prompt != nil:
+			entered, err := prompt(description)
+			if err != nil {
+				utils.Fatal().Err(err).Msgf("Error reading the password of the image store credentials %s", description)
+			}
+			password = entered
+		default:
+			log.Warn().Msgf("The password of the image store credentials %s is sealed and no --credentialsKeyFile is set, "+
+				"new image stores need their password to be set on the target", description)
+			password = dumper.LockedPassword
+		}
+		parts[passwordPart].text = pq.QuoteLiteral(password)
+		return joinStatement(parts)
+	}
+}
+
+// describeCredentials returns the user and URL of the inserted credentials, as far as they are exported
+func describeCredentials(parts []statementPart, values map[string]int) string {
+	description := make([]string, 0, 2)
+	if part, ok := values["username"]; ok {
+		description = append(description, fmt.Sprintf("of user %s", literalContent(parts[part])))
+	}
+	if part, ok := values["url"]; ok {
+		description = append(description, fmt.Sprintf("for %s", literalContent(parts[part])))
+	}
+	return strings.Join(description, " ")
+}
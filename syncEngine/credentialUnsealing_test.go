@@ -0,0 +1,63 @@
+package syncEngine
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/uyuni-project/inter-server-sync/credentials"
+)
+
+func credentialsStatement(password string) string {
+	return fmt.Sprintf("INSERT INTO susecredentials (id, type, url, username, password)\tVALUES ((SELECT nextval('suse_credentials_id_seq')),'registrycreds','registry.example.com','builder','%s') "+
+		"ON CONFLICT (id) DO UPDATE SET password = CASE WHEN excluded.password = '!' THEN susecredentials.password ELSE excluded.password END;", password)
+}
+
+func TestUnsealCredentialsWithKey(t *testing.T) {
+	key := []byte(strings.Repeat("k", credentials.KeySize))
+	sealed, _ := credentials.Seal(key, "it's secret")
+
+	rewritten := unsealCredentials(key, nil, nil)(credentialsStatement(sealed))
+
+	if expected := credentialsStatement("it''s secret"); rewritten != expected {
+		t.Errorf("Expected %s, got %s", expected, rewritten)
+	}
+}
+
+func TestUnsealCredentialsWithPrompt(t *testing.T) {
+	sealed, _ := credentials.Seal([]byte(strings.Repeat("k", credentials.KeySize)), "secret")
+	description := ""
+	prompt := func(text string) (string, error) {
+		description = text
+		return "typed", nil
+	}
+
+	rewritten := unsealCredentials(nil, prompt, nil)(credentialsStatement(sealed))
+
+	if expected := credentialsStatement("typed"); rewritten != expected {
+		t.Errorf("Expected %s, got %s", expected, rewritten)
+	}
+	if description != "of user builder for registry.example.com" {
+		t.Errorf("Unexpected prompt description %s", description)
+	}
+}
+
+func TestUnsealCredentialsWithoutKey(t *testing.T) {
+	sealed, _ := credentials.Seal([]byte(strings.Repeat("k", credentials.KeySize)), "secret")
+
+	rewritten := unsealCredentials(nil, nil, nil)(credentialsStatement(sealed))
+
+	if expected := credentialsStatement("!"); rewritten != expected {
+		t.Errorf("Expected %s, got %s", expected, rewritten)
+	}
+}
+
+func TestLiteralContent(t *testing.T) {
+	parts, _ := splitStatement(`SELECT 'it''s', E'back\\slash\'s';`)
+	if content := literalContent(parts[1]); content != "it's" {
+		t.Errorf("Unexpected content %s", content)
+	}
+	if content := literalContent(parts[3]); content != `back\slash's` {
+		t.Errorf("Unexpected content %s", content)
+	}
+}
@@ -0,0 +1,110 @@
+// Package syncEngine runs the exports and imports of inter-server-sync, for the command line and for Go
+// programs embedding them.
+//
+// The engine logs with the global zerolog logger, whose fatal and panic events stop the export or import.
+// Those events are returned as a *utils.Failure error instead of exiting the process, whatever the logger set
+// by the program embedding the engine: each run hooks the global logger with utils.FailureHook.
+package syncEngine
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+	"github.com/uyuni-project/inter-server-sync/tracing"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// Exporter exports server entities to be imported in other server
+type Exporter interface {
+	// Export writes the export selected by the options, returning a *utils.Failure when it fails
+	Export(options ExportOptions) error
+}
+
+// Importer imports an export into the target server
+type Importer interface {
+	// Import imports the export selected by the options, returning a *utils.Failure when it fails
+	Import(options ImportOptions) error
+}
+
+// the statement writers keep the state of the running export in package variables, so exports and imports
+// of all engines run one at a time
+var engineMutex sync.Mutex
+
+type engine struct{}
+
+// NewExporter returns an Exporter running one export at a time
+func NewExporter() Exporter {
+	return engine{}
+}
+
+// NewImporter returns an Importer running one import at a time
+func NewImporter() Importer {
+	return engine{}
+}
+
+// LogWriter returns a log writer for the global zerolog logger, writing to w and stopping the fatal and panic
+// events before the writers following it. Runs stop at those events with any writer.
+func LogWriter(w io.Writer) zerolog.LevelWriter {
+	return zerolog.MultiLevelWriter(w, utils.FailureWriter{})
+}
+
+// startRun sets the context, timeouts, query retry policy and failure hook of the export or import, returning
+// the function restoring them
+func startRun(ctx context.Context, deadline time.Duration, timeouts sqlUtil.Timeouts, retries sqlUtil.RetryPolicy) func() {
+	logger := log.Logger
+	log.Logger = log.Logger.Hook(utils.FailureHook{})
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	cancel := func() {}
+	if deadline > 0 {
+		ctx, cancel = context.WithTimeout(ctx, deadline)
+	}
+	utils.SetContext(ctx)
+	sqlUtil.SetTimeouts(timeouts)
+	sqlUtil.SetRetryPolicy(retries)
+	return func() {
+		cancel()
+		utils.SetContext(nil)
+		sqlUtil.SetTimeouts(sqlUtil.Timeouts{})
+		sqlUtil.SetRetryPolicy(sqlUtil.RetryPolicy{})
+		log.Logger = logger
+	}
+}
+
+// startTracing starts tracing the export or import to the OTLP endpoint, returning the function ending its
+// root span with the error of the run
+func startTracing(endpoint string, name string, err *error) func() {
+	shutdown := tracing.Setup(endpoint)
+	span := tracing.StartSpan(name)
+	return func() {
+		span.SetError(*err)
+		span.End()
+		shutdown()
+	}
+}
+
+// recoverFailure returns the panic stopping an export or import as error. Failures without a cause of their
+// own get the exit code, which tells if the failure left an incomplete export behind.
+func recoverFailure(err *error, exitCode *int) {
+	recovered := recover()
+	if recovered == nil {
+		return
+	}
+	failure, ok := recovered.(*utils.Failure)
+	if !ok {
+		failure = utils.NewFailure(*exitCode, fmt.Sprintf("%v", recovered))
+		failure.Stack = string(debug.Stack())
+	} else if failure.ExitCode == utils.ExitError && *exitCode != utils.ExitError {
+		failure.ExitCode = *exitCode
+		failure.ErrorType = utils.ExitCodeName(*exitCode)
+	}
+	*err = failure
+}
@@ -0,0 +1,111 @@
+package syncEngine
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/notify"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+func useFailureLogger(t *testing.T) {
+	logger := log.Logger
+	log.Logger = zerolog.New(LogWriter(io.Discard))
+	t.Cleanup(func() { log.Logger = logger })
+}
+
+func TestExportReturnsFailure(t *testing.T) {
+	useFailureLogger(t)
+	err := NewExporter().Export(ExportOptions{Format: "csv"})
+	failure, ok := err.(*utils.Failure)
+	if !ok {
+		t.Fatalf("Expected a failure, got %v", err)
+	}
+	if failure.ExitCode != utils.ExitConfigError || failure.ErrorType != "config_error" {
+		t.Errorf("Expected a configuration error, got %d %s", failure.ExitCode, failure.ErrorType)
+	}
+	if failure.Message != "Unknown export format csv" {
+		t.Errorf("Unexpected message %s", failure.Message)
+	}
+}
+
+func TestRecoverFailure(t *testing.T) {
+	run := func(recovered interface{}, exitCode int) (err error) {
+		defer recoverFailure(&err, &exitCode)
+		if recovered != nil {
+			panic(recovered)
+		}
+		return nil
+	}
+	if err := run(nil, utils.ExitError); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	failure := run("unexpected", utils.ExitPartialExport).(*utils.Failure)
+	if failure.ExitCode != utils.ExitPartialExport || failure.Message != "unexpected" || len(failure.Stack) == 0 {
+		t.Errorf("Unexpected failure from panic: %+v", failure)
+	}
+
+	failure = run(utils.NewFailure(utils.ExitError, "stopped"), utils.ExitPartialExport).(*utils.Failure)
+	if failure.ExitCode != utils.ExitPartialExport || failure.ErrorType != "partial_export" {
+		t.Errorf("Failures without cause should get the exit code of the run, got %d", failure.ExitCode)
+	}
+
+	failure = run(utils.NewFailure(utils.ExitDatabaseError, "stopped"), utils.ExitPartialExport).(*utils.Failure)
+	if failure.ExitCode != utils.ExitDatabaseError {
+		t.Errorf("Failures with a cause should keep their exit code, got %d", failure.ExitCode)
+	}
+}
+
+func TestStartRunStopsFatalEventsOfAnyLogger(t *testing.T) {
+	logger := log.Logger
+	var output bytes.Buffer
+	log.Logger = zerolog.New(&output)
+	t.Cleanup(func() { log.Logger = logger })
+
+	run := func(fatal func()) (err error) {
+		exitCode := utils.ExitError
+		defer recoverFailure(&err, &exitCode)
+		defer startRun(nil, 0, sqlUtil.Timeouts{}, sqlUtil.RetryPolicy{})()
+		fatal()
+		return nil
+	}
+
+	failure, ok := run(func() { log.Fatal().Msg("direct fatal") }).(*utils.Failure)
+	if !ok || failure.Message != "direct fatal" {
+		t.Errorf("Expected the failure of the fatal event, got %v", failure)
+	}
+	failure, ok = run(func() { utils.Fatal().Int(utils.ExitCodeField, utils.ExitConfigError).Msg("engine fatal") }).(*utils.Failure)
+	if !ok || failure.ExitCode != utils.ExitConfigError {
+		t.Errorf("Expected the configuration failure, got %v", failure)
+	}
+	if !strings.Contains(output.String(), "direct fatal") || !strings.Contains(output.String(), "engine fatal") {
+		t.Errorf("Failure events should be logged, got %s", output.String())
+	}
+}
+
+func TestFailedExportNotified(t *testing.T) {
+	useFailureLogger(t)
+	var received notify.Summary
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer server.Close()
+
+	err := NewExporter().Export(ExportOptions{Format: "csv", Notifications: notify.Config{Targets: []string{server.URL}}})
+
+	if err == nil || received.Operation != "export" || received.Succeeded {
+		t.Fatalf("Expected the failed export to be notified, got %+v", received)
+	}
+	if received.ExitCode != utils.ExitConfigError || received.Message != "Unknown export format csv" || len(received.Tables) > 0 {
+		t.Errorf("Unexpected summary %+v", received)
+	}
+}
@@ -0,0 +1,265 @@
+package syncEngine
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/dumper"
+	"github.com/uyuni-project/inter-server-sync/entityDumper"
+	"github.com/uyuni-project/inter-server-sync/exportArchive"
+	"github.com/uyuni-project/inter-server-sync/kms"
+	"github.com/uyuni-project/inter-server-sync/legacyXml"
+	"github.com/uyuni-project/inter-server-sync/notify"
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// Export formats
+const (
+	FormatSql       = "sql"
+	FormatLegacyXml = "legacy-xml"
+)
+
+// ExportOptions selects the exported entities, and how the export is written
+type ExportOptions struct {
+	entityDumper.DumperOptions
+	// FormatSql (the default), or FormatLegacyXml for servers using satellite-sync (channels only)
+	Format string
+	// write the export as a single archive at this path instead of the output folder, split into volumes
+	// no larger than VolumeSize when set
+	Archive    string
+	VolumeSize int64
+	// stream the archive to the writer instead of Archive, like the standard output. The export is staged in a
+	// temporary directory
+	ArchiveStream io.Writer
+	// encrypt the archive with a key wrapped by this key of a key management service, see kms.NewKeyWrapper
+	KmsKey string
+	// additional columns, as 'table.column' or 'column', whose values are masked in the trace log
+	SensitiveColumns []string
+	// JSON file with rules rewriting host specific values of the exported pillars
+	PillarRewriteRules string
+	// register this FQDN as ISS peripheral (slave) of the source server after the export
+	RegisterPeripheral string
+	// called when the export starts writing its output, failures from then on leave an incomplete export behind
+	OnOutputStarted func()
+	// stops the export when canceled, marking the output incomplete
+	Context context.Context
+	// maximum duration of the whole export, 0 for no limit
+	Deadline time.Duration
+	// maximum durations of the queries and of opening database connections
+	Timeouts sqlUtil.Timeouts
+	// repetition of the read queries failing with transient errors, like lock conflicts on a live server
+	Retries sqlUtil.RetryPolicy
+	// OTLP/HTTP collector the spans of the export phases are sent to, see tracing.Setup
+	OtlpEndpoint string
+	// targets notified with the outcome of the export
+	Notifications notify.Config
+}
+
+// exportRun holds the state of one export
+type exportRun struct {
+	ExportOptions
+	// exit code of the failures not setting one
+	exitCode *int
+}
+
+func (engine) Export(options ExportOptions) (err error) {
+	engineMutex.Lock()
+	defer engineMutex.Unlock()
+	defer startTracing(options.OtlpEndpoint, "export", &err)()
+	exitCode := utils.ExitError
+	defer notifyOutcome(options.Notifications, "export", time.Now(), &err, exportSummary)
+	defer recoverFailure(&err, &exitCode)
+	defer startRun(options.Context, options.Deadline, options.Timeouts, options.Retries)()
+	run := exportRun{ExportOptions: options, exitCode: &exitCode}
+	run.run()
+	return nil
+}
+
+func (run *exportRun) run() {
+	run.validate()
+	dumper.ResetExportState()
+	schemareader.ResetPillarRewriteRules()
+	dumper.AddSensitiveColumns(run.SensitiveColumns)
+	if len(run.PillarRewriteRules) > 0 {
+		schemareader.AddPillarRewriteRules(schemareader.ReadPillarRewriteRules(utils.GetAbsPath(run.PillarRewriteRules)))
+	}
+
+	archiveFile := ""
+	if len(run.Archive) > 0 {
+		archiveFile = archivePath(run.Archive)
+		stagingDir := stageArchive(archiveFile)
+		defer os.RemoveAll(stagingDir)
+		run.OutputFolder = stagingDir
+	} else if run.ArchiveStream != nil {
+		stagingDir, err := os.MkdirTemp("", ".inter-server-sync-")
+		if err != nil {
+			utils.Fatal().Err(err).Msg("Error creating the archive staging directory")
+		}
+		defer os.RemoveAll(stagingDir)
+		run.OutputFolder = stagingDir
+	}
+
+	completed := false
+	defer func() {
+		// exports written to archives are removed with the staging directory
+		if !completed && *run.exitCode == utils.ExitPartialExport && len(archiveFile) == 0 && run.ArchiveStream == nil {
+			entityDumper.MarkIncomplete(utils.GetAbsPath(run.OutputFolder))
+		}
+	}()
+	if run.Format == FormatLegacyXml {
+		run.runLegacyExport()
+	} else {
+		run.outputStarted()
+		entityDumper.DumpAllEntities(run.DumperOptions)
+		exportId := newExportId()
+		writeVersionFile(run.ServerConfig, run.OutputFolder, exportId)
+		for _, channelFolder := range entityDumper.ChannelSubdirectories(utils.GetAbsPath(run.OutputFolder)) {
+			writeVersionFile(run.ServerConfig, channelFolder, exportId)
+		}
+		log.Info().Msgf("Export done. Directory: %s", run.OutputFolder)
+	}
+	utils.CheckCanceled()
+	completed = true
+
+	if len(archiveFile) > 0 {
+		if run.VolumeSize > 0 {
+			exportArchive.WriteVolumes(run.OutputFolder, archiveFile, run.VolumeSize, run.KmsKey)
+		} else {
+			exportArchive.Write(run.OutputFolder, archiveFile, run.KmsKey)
+		}
+	} else if run.ArchiveStream != nil {
+		exportArchive.WriteStream(run.OutputFolder, run.ArchiveStream, run.KmsKey)
+	}
+	if len(run.RegisterPeripheral) > 0 {
+		registerPeripheral(run.ServerConfig, run.RegisterPeripheral)
+	}
+}
+
+// validate stops the export when options cannot be combined
+func (run *exportRun) validate() {
+	validateNotifications(run.Notifications)
+	if run.Format != "" && run.Format != FormatSql && run.Format != FormatLegacyXml {
+		utils.Fatal().Int(utils.ExitCodeField, utils.ExitConfigError).Msgf("Unknown export format %s", run.Format)
+	}
+	if run.Dedup != "" && run.Dedup != dumper.DedupExact && run.Dedup != dumper.DedupApproximate {
+		utils.Fatal().Int(utils.ExitCodeField, utils.ExitConfigError).Msgf("Unknown dedup mode %s", run.Dedup)
+	}
+	if run.IdStrategy != "" && run.IdStrategy != dumper.IdsAlwaysNew && run.IdStrategy != dumper.IdsPreserveSource &&
+		run.IdStrategy != dumper.IdsLookupUniqueIndex {
+		utils.Fatal().Int(utils.ExitCodeField, utils.ExitConfigError).Msgf("Unknown id strategy %s", run.IdStrategy)
+	}
+	if run.ChangelogLimit < 0 {
+		utils.Fatal().Int(utils.ExitCodeField, utils.ExitConfigError).Msgf("Invalid changelog limit %d", run.ChangelogLimit)
+	}
+	if run.ChannelSubdirectories && len(run.ExportedKeysCache) > 0 {
+		utils.Fatal().Int(utils.ExitCodeField, utils.ExitConfigError).Msg("Channels exported into subdirectories cannot skip the rows of the exported keys cache")
+	}
+	if run.HotStandbySource && len(run.RegisterPeripheral) > 0 {
+		utils.Fatal().Int(utils.ExitCodeField, utils.ExitConfigError).Msg("Peripherals cannot be registered on a hot standby server, --registerPeripheral writes to the source database")
+	}
+	if len(run.Archive) > 0 && run.ArchiveStream != nil {
+		utils.Fatal().Int(utils.ExitCodeField, utils.ExitConfigError).Msg("The archive is either written to a file or streamed")
+	}
+	if run.VolumeSize > 0 && len(run.Archive) == 0 {
+		utils.Fatal().Int(utils.ExitCodeField, utils.ExitConfigError).Msg("Only archive files can be split, --split-media needs --archive with a path")
+	}
+	if len(run.KmsKey) > 0 {
+		if len(run.Archive) == 0 && run.ArchiveStream == nil {
+			utils.Fatal().Int(utils.ExitCodeField, utils.ExitConfigError).Msg("Only archives can be encrypted, --kms-key needs --archive")
+		}
+		// the key is wrapped once the export is written, a wrong configuration must not waste the export
+		if _, err := kms.NewKeyWrapper(run.KmsKey); err != nil {
+			utils.Fatal().Err(err).Int(utils.ExitCodeField, utils.ExitConfigError).Msg("Invalid key management service key")
+		}
+	}
+}
+
+func (run *exportRun) outputStarted() {
+	*run.exitCode = utils.ExitPartialExport
+	if run.OnOutputStarted != nil {
+		run.OnOutputStarted()
+	}
+}
+
+// archivePath returns the absolute path of the archive, with the archive extension
+func archivePath(archiveFile string) string {
+	archiveFile = utils.GetAbsPath(archiveFile)
+	if !strings.HasSuffix(archiveFile, exportArchive.Extension) {
+		archiveFile += exportArchive.Extension
+	}
+	return archiveFile
+}
+
+// stageArchive returns the directory the export is written to before being packed into the archive,
+// on the same file system as the archive
+func stageArchive(archiveFile string) string {
+	if _, err := os.Stat(archiveFile); err == nil {
+		utils.Fatal().Int(utils.ExitCodeField, utils.ExitConfigError).Msgf("Archive already exists: %s", archiveFile)
+	}
+	stagingDir, err := os.MkdirTemp(path.Dir(archiveFile), ".inter-server-sync-")
+	if err != nil {
+		utils.Fatal().Err(err).Msg("Error creating the archive staging directory")
+	}
+	return stagingDir
+}
+
+// WriteVersionFile stores the product and version of the source server in the export directory
+func WriteVersionFile(serverConfig string, outputDir string) {
+	writeVersionFile(serverConfig, outputDir, newExportId())
+}
+
+// writeVersionFile stores the product and version of the source server, and the identifier of the export recorded
+// in the sync history of the target
+func writeVersionFile(serverConfig string, outputDir string, exportId string) {
+	var versionfile string
+	versionfile = path.Join(utils.GetAbsPath(outputDir), "version.txt")
+	vf, err := os.Open(versionfile)
+	defer vf.Close()
+	if os.IsNotExist(err) {
+		f, err := os.Create(versionfile)
+		if err != nil {
+			utils.Panic().Msg("Unable to create version file")
+		}
+		vf = f
+	}
+	version, product := utils.GetCurrentServerVersion(serverConfig)
+	vf.WriteString("product_name = " + product + "\n" + "version = " + version + "\n")
+	// checked by the importer, after the server version which is searched by its key
+	vf.WriteString(toolVersionKey + " = " + Version + "\n" + minImporterVersionKey + " = " + MinImporterVersion + "\n")
+	// used to register this server as hub of the target server on import
+	if fqdn := dumper.AnonymizeHost(utils.GetCurrentServerFQDN(serverConfig)); len(fqdn) > 0 {
+		vf.WriteString("hub_fqdn = " + fqdn + "\n")
+	}
+	vf.WriteString(exportIdKey + " = " + exportId + "\n" + exportDateKey + " = " + time.Now().UTC().Format(time.RFC3339) + "\n")
+}
+
+// runLegacyExport exports the channels as a satellite-sync dump, for servers which cannot import sql exports
+func (run *exportRun) runLegacyExport() {
+	if len(run.ConfigLabels) > 0 || run.OSImages || run.Containers || run.Autoinstall || run.Products || run.MaintenanceSchedules || run.Proxies || run.Users {
+		utils.Fatal().Int(utils.ExitCodeField, utils.ExitConfigError).Msg("Only channels can be exported in legacy-xml format")
+	}
+	outputFolderAbs := utils.GetAbsPath(run.OutputFolder)
+	entityDumper.ValidateExportFolder(outputFolderAbs)
+	var db *sql.DB
+	if run.HotStandbySource {
+		db = schemareader.GetHotStandbyDBconnection(run.ServerConfig, run.SourceStatementTimeout)
+		if err := schemareader.VerifyHotStandbyConnection(db); err != nil {
+			utils.Fatal().Err(err).Int(utils.ExitCodeField, utils.ExitConfigError).Msg("The source connection is not safe for a hot standby server")
+		}
+	} else {
+		db = schemareader.GetDBconnection(run.ServerConfig)
+	}
+	defer db.Close()
+	run.outputStarted()
+	singleChannels, channelsWithChildren := run.ChannelSelection()
+	legacyXml.ExportChannels(db, singleChannels, channelsWithChildren, outputFolderAbs)
+	log.Info().Msgf("Legacy export done. Directory: %s", run.OutputFolder)
+}
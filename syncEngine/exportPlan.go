@@ -0,0 +1,303 @@
+package syncEngine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/dumper"
+	"github.com/uyuni-project/inter-server-sync/entityDumper"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// Planner describes exports for review before they run
+type Planner interface {
+	// Plan returns the description of the export selected by the options, without writing it
+	Plan(options ExportOptions) (*ExportPlan, error)
+}
+
+// NewPlanner returns a Planner running one plan, export or import at a time
+func NewPlanner() Planner {
+	return engine{}
+}
+
+// PlannedExport are the options of a planned export, which are applied unchanged
+type PlannedExport struct {
+	entityDumper.DumperOptions
+	Format             string   `json:",omitempty"`
+	Archive            string   `json:",omitempty"`
+	VolumeSize         int64    `json:",omitempty"`
+	KmsKey             string   `json:",omitempty"`
+	SensitiveColumns   []string `json:",omitempty"`
+	PillarRewriteRules string   `json:",omitempty"`
+	RegisterPeripheral string   `json:",omitempty"`
+}
+
+// PlannedTable is a table written by a planned export, with the number of its statements
+type PlannedTable struct {
+	Name string `json:"name"`
+	Rows int    `json:"rows"`
+	// predicate of the user restricting the rows of the table
+	Filter string `json:"filter,omitempty"`
+	// time writing the rows is estimated to take from the statistics store of the previous exports
+	EstimatedDuration time.Duration `json:"estimatedDuration,omitempty"`
+}
+
+// ExportPlan describes everything an export writes, so it can be reviewed before it runs.
+// The digest covers the options and the files they refer to, so only approved plans are applied.
+type ExportPlan struct {
+	Version         string         `json:"version"`
+	Created         time.Time      `json:"created"`
+	Export          PlannedExport  `json:"export"`
+	Tables          []PlannedTable `json:"tables"`
+	Transformations []string       `json:"transformations"`
+	// time writing the tables with statistics is estimated to take
+	EstimatedDuration time.Duration `json:"estimatedDuration,omitempty"`
+	Digest            string        `json:"digest"`
+}
+
+// Plan runs the export into a temporary folder, counting the statements of every table. Files are not copied,
+// and the source database and the exported keys cache are not changed.
+func (engine) Plan(options ExportOptions) (plan *ExportPlan, err error) {
+	engineMutex.Lock()
+	defer engineMutex.Unlock()
+	defer startTracing(options.OtlpEndpoint, "plan", &err)()
+	exitCode := utils.ExitError
+	defer recoverFailure(&err, &exitCode)
+	defer startRun(options.Context, options.Deadline, options.Timeouts, options.Retries)()
+
+	planned := plannedExport(options)
+	scratchDir := createScratchDir("plan")
+	defer os.RemoveAll(scratchDir)
+	dryRun := dryRunOptions(options, scratchDir)
+	run := exportRun{ExportOptions: dryRun, exitCode: &exitCode}
+	run.run()
+
+	plan = &ExportPlan{
+		Version:         Version,
+		Created:         time.Now().UTC(),
+		Export:          planned,
+		Tables:          plannedTables(dumper.WriteStatistics(), options.WhereFilters),
+		Transformations: plannedTransformations(options),
+	}
+	if len(options.StatisticsStore) > 0 {
+		plan.estimateDurations(dumper.ReadStatisticsStore(utils.GetAbsPath(options.StatisticsStore)))
+	}
+	plan.Digest = planDigest(planned)
+	return plan, nil
+}
+
+// estimateDurations estimates the time writing the planned tables takes from the statistics of the previous exports
+func (plan *ExportPlan) estimateDurations(store *dumper.StatisticsStore) {
+	plan.EstimatedDuration = 0
+	for i, table := range plan.Tables {
+		if duration, ok := store.EstimateDuration(table.Name, table.Rows); ok {
+			plan.Tables[i].EstimatedDuration = duration
+			plan.EstimatedDuration += duration
+		}
+	}
+}
+
+// createScratchDir returns the temporary directory of a dry run, which must be removed
+func createScratchDir(kind string) string {
+	scratchDir, err := os.MkdirTemp("", "inter-server-sync-"+kind+"-")
+	if err != nil {
+		utils.Fatal().Err(err).Msgf("Error creating the %s scratch directory", kind)
+	}
+	return scratchDir
+}
+
+// dryRunOptions returns the options exporting the statements of the export into the scratch directory, without
+// copying files, changing the source database or the exported keys cache
+func dryRunOptions(options ExportOptions, scratchDir string) ExportOptions {
+	dryRun := options
+	dryRun.OutputFolder = filepath.Join(scratchDir, "export")
+	dryRun.MetadataOnly = true
+	dryRun.IncludeRepodata = false
+	dryRun.BootstrapRepositories = false
+	dryRun.Archive = ""
+	dryRun.ArchiveStream = nil
+	dryRun.VolumeSize = 0
+	dryRun.KmsKey = ""
+	dryRun.RegisterPeripheral = ""
+	dryRun.OnOutputStarted = nil
+	if len(options.ExportedKeysCache) > 0 {
+		// the export records its rows in the cache, which must stay as it is until the export runs
+		dryRun.ExportedKeysCache = filepath.Join(scratchDir, "exportedKeys")
+		if _, statErr := os.Stat(utils.GetAbsPath(options.ExportedKeysCache)); statErr == nil {
+			if _, copyErr := dumper.Copy(utils.GetAbsPath(options.ExportedKeysCache), dryRun.ExportedKeysCache); copyErr != nil {
+				utils.Fatal().Err(copyErr).Msg("Error copying the exported keys cache")
+			}
+		}
+	}
+	if len(options.StatisticsStore) > 0 {
+		// the dry run orders the tables like the export, without recording its statistics
+		dryRun.StatisticsStore = filepath.Join(scratchDir, "statistics.json")
+		if _, statErr := os.Stat(utils.GetAbsPath(options.StatisticsStore)); statErr == nil {
+			if _, copyErr := dumper.Copy(utils.GetAbsPath(options.StatisticsStore), dryRun.StatisticsStore); copyErr != nil {
+				utils.Fatal().Err(copyErr).Msg("Error copying the statistics store")
+			}
+		}
+	}
+	return dryRun
+}
+
+func plannedExport(options ExportOptions) PlannedExport {
+	return PlannedExport{
+		DumperOptions:      options.DumperOptions,
+		Format:             options.Format,
+		Archive:            options.Archive,
+		VolumeSize:         options.VolumeSize,
+		KmsKey:             options.KmsKey,
+		SensitiveColumns:   options.SensitiveColumns,
+		PillarRewriteRules: options.PillarRewriteRules,
+		RegisterPeripheral: options.RegisterPeripheral,
+	}
+}
+
+// plannedTables returns the written tables by name, with the user filters of the tables
+func plannedTables(statistics map[string]dumper.TableWriteStatistics, filters map[string]string) []PlannedTable {
+	tables := make([]PlannedTable, 0, len(statistics))
+	for tableName, tableStatistics := range statistics {
+		tables = append(tables, PlannedTable{Name: tableName, Rows: tableStatistics.Rows, Filter: filters[tableName]})
+	}
+	sort.Slice(tables, func(i, j int) bool {
+		return tables[i].Name < tables[j].Name
+	})
+	return tables
+}
+
+// plannedTransformations describes how the options change the exported rows from the ones of the source server
+func plannedTransformations(options ExportOptions) []string {
+	transformations := make([]string, 0)
+	add := func(enabled bool, transformation string) {
+		if enabled {
+			transformations = append(transformations, transformation)
+		}
+	}
+	add(options.Anonymize, "host names, user names, e-mail addresses, IP addresses, organization names, system descriptions and pillar strings are replaced by pseudonyms")
+	add(!options.IncludeRepoCredentials, "credentials are removed from the repository URLs, SSL client certificates and keys of the repositories are not exported")
+	add(options.Users && !options.IncludeUserPasswords, "user passwords are locked")
+	add((options.OSImages || options.Containers) && len(options.CredentialsKeyFile) > 0, "image store passwords are sealed with the credentials key")
+	add((options.OSImages || options.Containers) && len(options.CredentialsKeyFile) == 0, "image store passwords are not exported")
+	add((options.OSImages || options.Containers) && len(options.ImageFilters) > 0, fmt.Sprintf("only images matching %v are exported", options.ImageFilters))
+	add(options.ExcludeFileLists, "package file lists are not exported")
+	add(options.ChangelogLimit > 0, fmt.Sprintf("only the newest %d changelog entries of every package are exported", options.ChangelogLimit))
+	add(len(options.StartingDate) > 0, fmt.Sprintf("only packages modified after %s are exported", options.StartingDate))
+	add(len(options.Orgs) > 0, fmt.Sprintf("only organizations %v are exported", options.Orgs))
+	add(options.MaxDepth > 0, fmt.Sprintf("references are followed up to depth %d", options.MaxDepth))
+	add(len(options.PruneTables) > 0, fmt.Sprintf("tables %v are not followed", options.PruneTables))
+	add(len(options.ReferenceRules) > 0, fmt.Sprintf("foreign keys are followed as limited by the rules of %s", options.ReferenceRules))
+	add(options.MetadataOnly, "package files are not exported")
+	add(len(options.ExportedKeysCache) > 0, fmt.Sprintf("rows recorded in %s are skipped if unchanged", options.ExportedKeysCache))
+	add(options.SkipExistingOnTarget, "rows present on the target database are skipped")
+	add(len(options.PackageInventory) > 0 && !options.MetadataOnly, fmt.Sprintf("package files listed in %s are not exported", options.PackageInventory))
+	add(options.Dedup == dumper.DedupApproximate, "processed rows are remembered approximately, rows may be missed")
+	add(options.IdStrategy == dumper.IdsPreserveSource, "generated ids of the source server are kept, and the sequences of the target moved past them")
+	add(options.IdStrategy == dumper.IdsLookupUniqueIndex, "generated ids of the rows existing on the target are looked up by their unique index")
+	add(len(options.KmsKey) > 0, fmt.Sprintf("the archive is encrypted with a key wrapped by %s", options.KmsKey))
+	add(len(options.PillarRewriteRules) > 0, fmt.Sprintf("pillars are rewritten with the rules of %s", options.PillarRewriteRules))
+	tokens := make([]string, 0, len(options.Placeholders))
+	for token := range options.Placeholders {
+		tokens = append(tokens, token)
+	}
+	sort.Strings(tokens)
+	add(len(tokens) > 0, fmt.Sprintf("values of the source server are replaced by the placeholders %v", tokens))
+	return transformations
+}
+
+// planDigest returns the digest of the planned options and of the content of the rule files they refer to
+func planDigest(planned PlannedExport) string {
+	content, err := json.Marshal(planned)
+	if err != nil {
+		utils.Panic().Err(err).Msg("error encoding the export plan")
+	}
+	hash := sha256.New()
+	hash.Write(content)
+	if len(planned.PillarRewriteRules) > 0 {
+		rules, err := os.ReadFile(utils.GetAbsPath(planned.PillarRewriteRules))
+		if err != nil {
+			utils.Fatal().Err(err).Int(utils.ExitCodeField, utils.ExitConfigError).Msg("error reading the pillar rewrite rules of the plan")
+		}
+		hash.Write(rules)
+	}
+	if len(planned.ReferenceRules) > 0 {
+		rules, err := os.ReadFile(utils.GetAbsPath(planned.ReferenceRules))
+		if err != nil {
+			utils.Fatal().Err(err).Int(utils.ExitCodeField, utils.ExitConfigError).Msg("error reading the reference rules of the plan")
+		}
+		hash.Write(rules)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// WriteExportPlan writes the plan as indented JSON
+func WriteExportPlan(plan *ExportPlan, planFile string) {
+	content, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		utils.Panic().Err(err).Msg("error encoding the export plan")
+	}
+	if err := os.WriteFile(utils.GetAbsPath(planFile), append(content, '\n'), 0644); err != nil {
+		utils.Fatal().Err(err).Msgf("error writing the export plan %s", planFile)
+	}
+}
+
+// ReadExportPlan reads an approved plan, stopping when the plan was changed or made by another version
+func ReadExportPlan(planFile string) *ExportPlan {
+	content, err := os.ReadFile(utils.GetAbsPath(planFile))
+	if err != nil {
+		utils.Fatal().Err(err).Int(utils.ExitCodeField, utils.ExitConfigError).Msgf("error reading the export plan %s", planFile)
+	}
+	plan := &ExportPlan{}
+	if err := json.Unmarshal(content, plan); err != nil {
+		utils.Fatal().Err(err).Int(utils.ExitCodeField, utils.ExitConfigError).Msgf("export plan %s is corrupted", planFile)
+	}
+	if plan.Version != Version {
+		utils.Fatal().Int(utils.ExitCodeField, utils.ExitConfigError).Msgf("export plan %s was made by inter-server-sync %s, it can only be applied by the same version", planFile, plan.Version)
+	}
+	if planDigest(plan.Export) != plan.Digest {
+		utils.Fatal().Int(utils.ExitCodeField, utils.ExitConfigError).Msgf("export plan %s was changed after it was made", planFile)
+	}
+	return plan
+}
+
+// ExportOptions returns the options applying the plan, with the run settings of the options
+func (plan *ExportPlan) ExportOptions(run ExportOptions) ExportOptions {
+	run.DumperOptions = plan.Export.DumperOptions
+	run.Format = plan.Export.Format
+	run.Archive = plan.Export.Archive
+	run.VolumeSize = plan.Export.VolumeSize
+	run.KmsKey = plan.Export.KmsKey
+	run.SensitiveColumns = plan.Export.SensitiveColumns
+	run.PillarRewriteRules = plan.Export.PillarRewriteRules
+	run.RegisterPeripheral = plan.Export.RegisterPeripheral
+	return run
+}
+
+// WarnDifferences logs the tables whose number of written statements differs from the plan, as the data of the
+// source server changed since the plan was made
+func (plan *ExportPlan) WarnDifferences(statistics map[string]dumper.TableWriteStatistics) int {
+	differences := 0
+	planned := make(map[string]int, len(plan.Tables))
+	for _, table := range plan.Tables {
+		planned[table.Name] = table.Rows
+	}
+	for _, table := range plannedTables(statistics, nil) {
+		if rows, ok := planned[table.Name]; !ok || rows != table.Rows {
+			log.Warn().Msgf("Table %s: %d rows planned, %d rows exported", table.Name, rows, table.Rows)
+			differences++
+		}
+		delete(planned, table.Name)
+	}
+	for tableName, rows := range planned {
+		log.Warn().Msgf("Table %s: %d rows planned, none exported", tableName, rows)
+		differences++
+	}
+	return differences
+}
@@ -0,0 +1,75 @@
+package syncEngine
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/uyuni-project/inter-server-sync/dumper"
+	"github.com/uyuni-project/inter-server-sync/entityDumper"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+func TestPlannedTables(t *testing.T) {
+	statistics := map[string]dumper.TableWriteStatistics{
+		"rhnpackage": {Rows: 12},
+		"rhnchannel": {Rows: 1},
+	}
+	tables := plannedTables(statistics, map[string]string{"rhnpackage": "org_id IS NULL"})
+
+	expected := []PlannedTable{{Name: "rhnchannel", Rows: 1}, {Name: "rhnpackage", Rows: 12, Filter: "org_id IS NULL"}}
+	if !reflect.DeepEqual(tables, expected) {
+		t.Errorf("Expected tables %v, got %v", expected, tables)
+	}
+}
+
+func TestPlannedTransformations(t *testing.T) {
+	options := ExportOptions{DumperOptions: entityDumper.DumperOptions{
+		Anonymize: true, IncludeRepoCredentials: true, ChangelogLimit: 5}}
+	transformations := plannedTransformations(options)
+
+	if len(transformations) != 2 || !strings.Contains(transformations[0], "pseudonyms") ||
+		!strings.Contains(transformations[1], "newest 5 changelog entries") {
+		t.Errorf("Unexpected transformations %v", transformations)
+	}
+}
+
+func TestReadExportPlan(t *testing.T) {
+	useFailureLogger(t)
+	planFile := path.Join(t.TempDir(), "plan.json")
+	planned := PlannedExport{DumperOptions: entityDumper.DumperOptions{ChannelLabels: []string{"base"},
+		WhereFilters: map[string]string{"rhnpackage": "org_id IS NULL"}}}
+	plan := &ExportPlan{Version: Version, Export: planned, Tables: []PlannedTable{{Name: "rhnchannel", Rows: 1}},
+		Digest: planDigest(planned)}
+	WriteExportPlan(plan, planFile)
+	read := func() (failure *utils.Failure) {
+		defer func() {
+			failure, _ = recover().(*utils.Failure)
+		}()
+		ReadExportPlan(planFile)
+		return nil
+	}
+
+	if failure := read(); failure != nil {
+		t.Fatalf("Expected the plan to be read, got %v", failure)
+	}
+	content, _ := os.ReadFile(planFile)
+	os.WriteFile(planFile, []byte(strings.Replace(string(content), `"base"`, `"other"`, 1)), 0644)
+	if failure := read(); failure == nil || failure.ExitCode != utils.ExitConfigError {
+		t.Errorf("Expected a changed plan to be refused, got %v", failure)
+	}
+}
+
+func TestWarnDifferences(t *testing.T) {
+	plan := &ExportPlan{Tables: []PlannedTable{{Name: "rhnchannel", Rows: 1}, {Name: "rhnerrata", Rows: 3}}}
+	differences := plan.WarnDifferences(map[string]dumper.TableWriteStatistics{
+		"rhnchannel": {Rows: 1},
+		"rhnerrata":  {Rows: 4},
+		"rhnpackage": {Rows: 2},
+	})
+	if differences != 2 {
+		t.Errorf("Expected 2 differences, got %d", differences)
+	}
+}
@@ -0,0 +1,84 @@
+package syncEngine
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/dumper"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// Previewer prints the statements an export generates for some tables, to develop and debug the export rules
+type Previewer interface {
+	// Preview writes the statements of the tables generated by the export selected by the options to the output,
+	// without writing the export
+	Preview(options ExportOptions, tableNames []string, output io.Writer) error
+}
+
+// NewPreviewer returns a Previewer running one preview, plan, export or import at a time
+func NewPreviewer() Previewer {
+	return engine{}
+}
+
+// Preview runs the export into a temporary folder like Plan, then copies the statements of the tables, in the
+// order of the export
+func (engine) Preview(options ExportOptions, tableNames []string, output io.Writer) (err error) {
+	engineMutex.Lock()
+	defer engineMutex.Unlock()
+	defer startTracing(options.OtlpEndpoint, "preview", &err)()
+	exitCode := utils.ExitError
+	defer recoverFailure(&err, &exitCode)
+	defer startRun(options.Context, options.Deadline, options.Timeouts, options.Retries)()
+
+	scratchDir := createScratchDir("preview")
+	defer os.RemoveAll(scratchDir)
+	dryRun := dryRunOptions(options, scratchDir)
+	// all statements are in the SQL script of the export folder
+	dryRun.ChannelSubdirectories = false
+	run := exportRun{ExportOptions: dryRun, exitCode: &exitCode}
+	run.run()
+
+	statistics := dumper.WriteStatistics()
+	for _, tableName := range tableNames {
+		if _, ok := statistics[strings.ToLower(strings.TrimSpace(tableName))]; !ok {
+			log.Warn().Msgf("The export has no statements for table %s", tableName)
+		}
+	}
+	script := dumper.OpenSqlScript(filepath.Join(utils.GetAbsPath(dryRun.OutputFolder), "sql_statements.sql.gz"))
+	defer script.Close()
+	written := previewStatements(script, tableNames, output)
+	log.Info().Msgf("%d statements previewed", written)
+	return nil
+}
+
+// previewStatements writes the statements of the script changing the tables, without the transaction and
+// comments around them, returning the number of statements
+func previewStatements(script *dumper.SqlScript, tableNames []string, output io.Writer) int {
+	selected := make(map[string]bool)
+	for _, tableName := range tableNames {
+		selected[strings.ToLower(strings.TrimSpace(tableName))] = true
+	}
+	keep := false
+	statements := 0
+	script.ForEachLine(func(line string) {
+		trimmedLine := strings.TrimSpace(line)
+		if tableName, ok := dumper.StatementTable(line); ok {
+			keep = selected[tableName]
+			if keep {
+				statements++
+			}
+		} else if _, ok := dumper.AnalyzedTable(line); ok || trimmedLine == "BEGIN;" || trimmedLine == "COMMIT;" ||
+			strings.HasPrefix(trimmedLine, "--") {
+			keep = false
+		}
+		if keep {
+			if _, err := io.WriteString(output, line); err != nil {
+				utils.Fatal().Err(err).Msg("Error writing the previewed statements")
+			}
+		}
+	})
+	return statements
+}
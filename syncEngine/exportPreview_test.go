@@ -0,0 +1,37 @@
+package syncEngine
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/uyuni-project/inter-server-sync/dumper"
+)
+
+func TestPreviewStatements(t *testing.T) {
+	sqlFile := filepath.Join(t.TempDir(), "sql_statements.sql")
+	script := "BEGIN;\n" +
+		"INSERT INTO rhnchannel (id, label) VALUES (1, 'test');\n" +
+		"INSERT INTO rhnerrata (id, advisory)\n" +
+		"\tVALUES (2, 'SUSE-2024-1') ON CONFLICT DO NOTHING;\n" +
+		"-- organization trusts\n" +
+		"ANALYZE rhnerrata;\n" +
+		"DELETE FROM rhnerrata WHERE id = 3;\n" +
+		"COMMIT;\n"
+	if err := os.WriteFile(sqlFile, []byte(script), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var output bytes.Buffer
+	sqlScript := dumper.OpenSqlScript(sqlFile)
+	defer sqlScript.Close()
+	statements := previewStatements(sqlScript, []string{"RhnErrata"}, &output)
+
+	expected := "INSERT INTO rhnerrata (id, advisory)\n" +
+		"\tVALUES (2, 'SUSE-2024-1') ON CONFLICT DO NOTHING;\n" +
+		"DELETE FROM rhnerrata WHERE id = 3;\n"
+	if statements != 2 || output.String() != expected {
+		t.Errorf("Expected 2 statements\n%s\ngot %d\n%s", expected, statements, output.String())
+	}
+}
@@ -0,0 +1,139 @@
+package syncEngine
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// system groups are referenced by their organization and name in the exported statements, like group scoped
+// pillars, with a sub query starting with this clause
+const groupReferenceClause = "SELECT id FROM rhnservergroup WHERE"
+
+// groupKey identifies a source group by the quoted names of its organization and of the group
+func groupKey(quotedOrgName string, quotedGroupName string) string {
+	return quotedOrgName + "," + quotedGroupName
+}
+
+// loadGroupMapping returns the target group ids indexed by the group key of the source groups
+func loadGroupMapping(absImportDir string, entries []string, mappingFile string) map[string]string {
+	if len(mappingFile) > 0 {
+		entries = append(entries, utils.ReadFileByLine(utils.GetAbsPath(mappingFile))...)
+	}
+	idMapping, ok := parseOrgMapping(entries)
+	if !ok {
+		utils.Fatal().Int(utils.ExitCodeField, utils.ExitConfigError).Msg("Unable to parse the group mapping. Allowed format is 'source_id=target_id'")
+	}
+	if len(idMapping) == 0 {
+		return nil
+	}
+	groupsFile := path.Join(absImportDir, "exportedGroups.txt")
+	orgsFile := path.Join(absImportDir, "exportedOrgs.txt")
+	for _, file := range []string{groupsFile, orgsFile} {
+		if _, err := os.Stat(file); err != nil {
+			utils.Fatal().Err(err).Msg("The export has no group and organization lists, groups cannot be mapped")
+		}
+	}
+	orgNames := make(map[string]string)
+	for _, line := range utils.ReadFileByLine(orgsFile) {
+		if separator := strings.Index(line, ","); separator >= 0 {
+			orgNames[line[:separator]] = line[separator+1:]
+		}
+	}
+	result := make(map[string]string)
+	for _, line := range utils.ReadFileByLine(groupsFile) {
+		fields := strings.SplitN(line, ",", 3)
+		if len(fields) < 3 {
+			continue
+		}
+		targetId, ok := idMapping[fields[0]]
+		if !ok {
+			continue
+		}
+		orgName, ok := orgNames[fields[1]]
+		if !ok {
+			log.Warn().Msgf("Organization %s of group %s not found in the export", fields[1], fields[2])
+			continue
+		}
+		log.Debug().Msgf("Mapping group %s of organization %s to %s", fields[2], orgName, targetId)
+		key := groupKey(strings.TrimSpace(pq.QuoteLiteral(orgName)), strings.TrimSpace(pq.QuoteLiteral(fields[2])))
+		result[key] = targetId
+		delete(idMapping, fields[0])
+	}
+	for sourceId := range idMapping {
+		log.Warn().Msgf("Group %s not found in the export", sourceId)
+	}
+	return result
+}
+
+// rewriteGroupReferences replaces the lookups of the mapped groups with their target ids. The lookups match the
+// organization by name as well, so the groups are rewritten before the organizations.
+func rewriteGroupReferences(statement string, groupMapping map[string]string) string {
+	if !strings.Contains(statement, "rhnservergroup") {
+		return statement
+	}
+	parts, _ := splitStatement(statement)
+	for i := 0; i < len(parts); i++ {
+		if parts[i].literal {
+			continue
+		}
+		start := strings.LastIndex(parts[i].text, groupReferenceClause)
+		if start < 0 {
+			continue
+		}
+		end, closing, orgName, groupName := groupReferenceEnd(parts, i, start)
+		if end <= i {
+			continue
+		}
+		targetId, ok := groupMapping[groupKey(orgName, groupName)]
+		if !ok {
+			continue
+		}
+		parts[i].text = fmt.Sprintf("%sSELECT %s", parts[i].text[:start], targetId)
+		for j := i + 1; j < end; j++ {
+			parts[j].text = ""
+		}
+		parts[end].text = parts[end].text[closing:]
+		i = end - 1
+	}
+	return joinStatement(parts)
+}
+
+// groupReferenceEnd returns the index of the part closing the group lookup starting in the part at the position and
+// the position of the closing parenthesis, with the quoted names of the organization and of the group, or -1 when
+// the lookup is incomplete
+func groupReferenceEnd(parts []statementPart, index int, start int) (int, int, string, string) {
+	orgName, groupName := "", ""
+	depth := 0
+	for i := index; i < len(parts); i++ {
+		if parts[i].literal {
+			switch previous := parts[i-1].text; {
+			case followsSql(previous, orgReferenceClause):
+				orgName = literalValue(parts[i])
+			case followsSql(previous, "name ="):
+				groupName = literalValue(parts[i])
+			}
+			continue
+		}
+		position := 0
+		if i == index {
+			position = start
+		}
+		for ; position < len(parts[i].text); position++ {
+			if parts[i].text[position] == '(' {
+				depth++
+			} else if parts[i].text[position] == ')' {
+				depth--
+			}
+			if depth < 0 {
+				return i, position, orgName, groupName
+			}
+		}
+	}
+	return -1, 0, "", ""
+}
@@ -0,0 +1,74 @@
+package syncEngine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func groupPillarStatement(groupLookup string) string {
+	return "INSERT INTO susesaltpillar (id, server_id, group_id, org_id, category, pillar)\tVALUES ('1'::int8,null,(" +
+		groupLookup + "),null,'formula-apache','{\"port\": 80}'::jsonb) ON CONFLICT DO NOTHING;"
+}
+
+func TestRewriteGroupReferences(t *testing.T) {
+	mapping := map[string]string{groupKey("'org'", "'web'"): "7"}
+	for _, lookup := range []string{
+		"SELECT id FROM rhnservergroup WHERE org_id = (SELECT id FROM web_customer WHERE name = 'org' LIMIT 1) AND name = 'web' LIMIT 1",
+		"SELECT id FROM rhnservergroup WHERE name = 'web' AND org_id = (SELECT id FROM web_customer WHERE name = 'org' LIMIT 1) LIMIT 1",
+	} {
+		result := rewriteGroupReferences(groupPillarStatement(lookup), mapping)
+
+		if expected := groupPillarStatement("SELECT 7"); result != expected {
+			t.Errorf("expected %s, got %s", expected, result)
+		}
+	}
+}
+
+func TestRewriteGroupReferencesOfOtherOrganizations(t *testing.T) {
+	statement := groupPillarStatement("SELECT id FROM rhnservergroup WHERE org_id = (SELECT id FROM web_customer WHERE name = 'other' LIMIT 1) AND name = 'web' LIMIT 1")
+
+	result := rewriteGroupReferences(statement, map[string]string{groupKey("'org'", "'web'"): "7"})
+
+	if result != statement {
+		t.Errorf("groups with the same name in other organizations should not be rewritten, got %s", result)
+	}
+}
+
+func TestStatementRewriterGroupAndOrgReferences(t *testing.T) {
+	statements := []string{
+		groupPillarStatement("SELECT id FROM rhnservergroup WHERE org_id = (SELECT id FROM web_customer WHERE name = 'org' LIMIT 1) AND name = 'web' LIMIT 1") + "\n",
+		groupPillarStatement("SELECT id FROM rhnservergroup WHERE org_id = (SELECT id FROM web_customer WHERE name = 'org' LIMIT 1) AND name = 'db' LIMIT 1") + "\n",
+	}
+	rewrite := statementRewriter(map[string]string{"'org'": "5"}, map[string]string{groupKey("'org'", "'web'"): "7"}, nil, nil, nil, nil)
+
+	result := ""
+	for _, statement := range statements {
+		result += rewrite(statement)
+	}
+
+	expected := groupPillarStatement("SELECT 7") + "\n" +
+		groupPillarStatement("SELECT id FROM rhnservergroup WHERE org_id = (SELECT 5 LIMIT 1) AND name = 'db' LIMIT 1") + "\n"
+	if result != expected {
+		t.Errorf("unmapped groups should be looked up in the mapped organization, expected %s, got %s", expected, result)
+	}
+}
+
+func TestLoadGroupMapping(t *testing.T) {
+	importDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(importDir, "exportedOrgs.txt"), []byte("1,org\n2,other's\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(importDir, "exportedGroups.txt"), []byte("10,1,web\n11,2,web, db\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mapping := loadGroupMapping(importDir, []string{"10=7", " 11 = 8"}, "")
+
+	if len(mapping) != 2 || mapping[groupKey("'org'", "'web'")] != "7" || mapping[groupKey("'other''s'", "'web, db'")] != "8" {
+		t.Errorf("unexpected group mapping %v", mapping)
+	}
+	if mapping := loadGroupMapping(importDir, nil, ""); mapping != nil {
+		t.Errorf("no mapping expected without entries, got %v", mapping)
+	}
+}
@@ -0,0 +1,55 @@
+package syncEngine
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// registerPeripheral records the target server as an ISS slave of this server, as the UI does
+func registerPeripheral(serverConfig string, peripheralFQDN string) {
+	db := schemareader.GetDBconnection(serverConfig)
+	defer db.Close()
+
+	_, err := db.Exec(`UPDATE rhnissslave SET enabled = 'Y', modified = current_timestamp WHERE slave = $1;`, peripheralFQDN)
+	if err == nil {
+		_, err = db.Exec(`INSERT INTO rhnissslave (id, slave, enabled, allow_all_orgs)
+			SELECT nextval('rhn_issslave_seq'), $1, 'Y', 'Y' WHERE NOT EXISTS (SELECT 1 FROM rhnissslave WHERE slave = $1);`,
+			peripheralFQDN)
+	}
+	if err != nil {
+		utils.Fatal().Err(err).Msgf("error registering %s as peripheral server", peripheralFQDN)
+	}
+	log.Info().Msgf("%s registered as peripheral server", peripheralFQDN)
+}
+
+// registerHub records the server the data was exported from as the current ISS master of the target server
+func (run *importRun) registerHub(absImportDir string) {
+	hubFQDN, err := utils.ScannerFunc(absImportDir+"/version.txt", "hub_fqdn")
+	if err != nil || len(strings.TrimSpace(hubFQDN)) == 0 {
+		log.Warn().Msg("Export doesn't contain the hub server name, hub registration skipped")
+		return
+	}
+	label := pq.QuoteLiteral(strings.TrimSpace(hubFQDN))
+	statements := fmt.Sprintf(`BEGIN;
+UPDATE rhnissmaster SET is_current_master = 'N' WHERE label <> %[1]s;
+UPDATE rhnissmaster SET is_current_master = 'Y' WHERE label = %[1]s;
+INSERT INTO rhnissmaster (id, label, is_current_master)
+	SELECT nextval('rhn_issmaster_seq'), %[1]s, 'Y' WHERE NOT EXISTS (SELECT 1 FROM rhnissmaster WHERE label = %[1]s);
+COMMIT;
+`, label)
+
+	cmd := run.sqlImportCommand("-")
+	cmd.Stdin = strings.NewReader(statements)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		utils.Fatal().Err(err).Msgf("error registering %s as hub server", hubFQDN)
+	}
+	log.Info().Msgf("%s registered as hub server", hubFQDN)
+}
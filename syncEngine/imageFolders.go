@@ -0,0 +1,74 @@
+package syncEngine
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// image files are stored in folders named by organization id, in the image pillars as well
+var imageOrgFolderPattern = regexp.MustCompile(`/os-images/(\d+)/`)
+
+// loadImageOrgFolders returns the image folders of the target organizations, indexed by the folder in the export,
+// for the organizations with another id on the target server
+func loadImageOrgFolders(absImportDir string, targetConfig string, orgMapping map[string]string) map[string]string {
+	orgsFile := path.Join(absImportDir, "exportedOrgs.txt")
+	if _, err := os.Stat(path.Join(absImportDir, "images")); err != nil {
+		return nil
+	}
+	if _, err := os.Stat(orgsFile); err != nil {
+		log.Debug().Msg("No organization list in the export, image folders are imported as exported")
+		return nil
+	}
+	db := schemareader.GetDBconnection(targetConfig)
+	defer db.Close()
+
+	result := make(map[string]string)
+	for _, line := range utils.ReadFileByLine(orgsFile) {
+		separator := strings.Index(line, ",")
+		if separator < 0 {
+			continue
+		}
+		sourceId, quotedName := line[:separator], pq.QuoteLiteral(line[separator+1:])
+		targetId, ok := orgMapping[quotedName]
+		if !ok {
+			rows := sqlUtil.ExecuteQueryWithResults(db, fmt.Sprintf("SELECT id FROM web_customer WHERE name = %s;", quotedName))
+			if len(rows) == 0 {
+				continue
+			}
+			targetId = fmt.Sprintf("%v", rows[0][0].Value)
+		}
+		if targetId != sourceId {
+			log.Debug().Msgf("Importing images of organization %s into folder %s", sourceId, targetId)
+			result[sourceId] = targetId
+		}
+	}
+	return result
+}
+
+// imageOrgFolder returns the folder of the target server for an image folder of the export
+func imageOrgFolder(imageOrgFolders map[string]string, folder string) string {
+	if targetFolder, ok := imageOrgFolders[folder]; ok {
+		return targetFolder
+	}
+	return folder
+}
+
+// rewriteImageOrgFolders replaces the image folders in the statement with the ones of the target organizations
+func rewriteImageOrgFolders(statement string, imageOrgFolders map[string]string) string {
+	if !strings.Contains(statement, "/os-images/") {
+		return statement
+	}
+	return imageOrgFolderPattern.ReplaceAllStringFunc(statement, func(folder string) string {
+		sourceId := imageOrgFolderPattern.FindStringSubmatch(folder)[1]
+		return fmt.Sprintf("/os-images/%s/", imageOrgFolder(imageOrgFolders, sourceId))
+	})
+}
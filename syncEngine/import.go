@@ -0,0 +1,734 @@
+package syncEngine
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/dumper"
+	"github.com/uyuni-project/inter-server-sync/dumper/packageDumper"
+	"github.com/uyuni-project/inter-server-sync/dumper/pillarDumper"
+	"github.com/uyuni-project/inter-server-sync/entityDumper"
+	"github.com/uyuni-project/inter-server-sync/exportArchive"
+	"github.com/uyuni-project/inter-server-sync/notify"
+	"github.com/uyuni-project/inter-server-sync/placeholders"
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+	"github.com/uyuni-project/inter-server-sync/storage"
+	"github.com/uyuni-project/inter-server-sync/tracing"
+	"github.com/uyuni-project/inter-server-sync/utils"
+	"github.com/uyuni-project/inter-server-sync/xmlrpc"
+)
+
+// ImportOptions selects the export imported into the target server, and how it is imported
+type ImportOptions struct {
+	// export directory, archive or any volume of a split archive
+	ImportDir string
+	// archive read from the stream instead of ImportDir, like the standard input, see exportArchive.ExtractStream
+	ImportStream io.Reader
+	// key management service key of the export's --kms-key, unwrapping the key of encrypted archives. Archives
+	// encrypted with another key are refused
+	KmsKey string
+	// configuration file of the target server
+	ServerConfig string
+	// import into a remote server through ssh (user@host), instead of the local one
+	TargetSSH string
+	// credentials of the XML-RPC API of the target server
+	XmlRpcUser     string
+	XmlRpcPassword string
+	// register the server the data was exported from as ISS hub (master) of the target server
+	RegisterHub bool
+	// organization mappings, in the format 'source_id=target_id', and a file with one mapping per line
+	OrgMapping     []string
+	OrgMappingFile string
+	// create the organizations the export references which are neither on the target server nor mapped
+	CreateMissingOrgs bool
+	// system group mappings of the group scoped pillars, in the format 'source_id=target_id', and a file with one
+	// mapping per line
+	GroupMapping     []string
+	GroupMappingFile string
+	// channel renames, in the format 'old-label=new-label'
+	ChannelRenames []string
+	// values substituted for the placeholders of the export, in the format 'TOKEN=value'
+	Placeholders []string
+	// import only the statements of these tables
+	OnlyTables []string
+	// commit the statements in batches of this size, recording the progress in ProgressFile
+	BatchSize    int
+	Resume       bool
+	ProgressFile string
+	// drop the foreign keys and secondary indexes of the imported tables during the import
+	BulkLoad bool
+	// roll back the whole import when a statement fails, instead of the statements of its table only
+	Strict bool
+	// file the JSON report of the imported rows is written to
+	ReportFile string
+	// refresh the rows of the imported channels in the reporting database (reportdb) of the target server
+	ReportDb bool
+	// file with the hex encoded key opening the sealed image store passwords of the export. Without key, the
+	// passwords are asked with CredentialsPrompt, if set
+	CredentialsKeyFile string
+	CredentialsPrompt  func(description string) (string, error)
+	// stops the import when canceled: the running SQL transaction is rolled back
+	Context context.Context
+	// maximum duration of the whole import, 0 for no limit
+	Deadline time.Duration
+	// maximum durations of the queries and of opening database connections. The statements of the export
+	// run by psql are only limited by the deadline
+	Timeouts sqlUtil.Timeouts
+	// file stores the package files, by their path relative to /var/spacewalk, and the image files, by their path
+	// relative to /srv/www/os-images, are written to instead of the folders of the target server, see storage.Open
+	PackageStore string
+	ImageStore   string
+	// OTLP/HTTP collector the spans of the import phases are sent to, see tracing.Setup
+	OtlpEndpoint string
+	// targets notified with the outcome of the import and its report
+	Notifications notify.Config
+}
+
+// importRun holds the state of one import
+type importRun struct {
+	ImportOptions
+	// prefix of the files written by the import, like the report
+	statePrefix string
+	// renamed channels, indexed by the label in the export
+	channelRenames map[string]string
+	// tunnel to the target server database when importing remotely
+	remoteTarget *sshTunnel
+	// stores of the package and image files, nil to copy them into the folders of the target server
+	packageStore storage.Store
+	imageStore   storage.Store
+	// tables whose statements failed and were rolled back
+	rolledBackTables []string
+	// report of the SQL import and the file it was written to, nil until the SQL script ran
+	report     *ImportReport
+	reportFile string
+}
+
+func (engine) Import(options ImportOptions) (err error) {
+	engineMutex.Lock()
+	defer engineMutex.Unlock()
+	defer startTracing(options.OtlpEndpoint, "import", &err)()
+	exitCode := utils.ExitError
+	run := importRun{ImportOptions: options}
+	defer notifyOutcome(options.Notifications, "import", time.Now(), &err, run.summary)
+	defer recoverFailure(&err, &exitCode)
+	defer startRun(options.Context, options.Deadline, options.Timeouts, sqlUtil.RetryPolicy{})()
+	run.run()
+	return nil
+}
+
+func (run *importRun) run() {
+	validateNotifications(run.Notifications)
+	absImportDir := utils.GetAbsPath(run.ImportDir)
+	if run.ImportStream != nil {
+		log.Info().Msg("starting import from the archive stream")
+	} else {
+		log.Info().Msg(fmt.Sprintf("starting import from dir %s", absImportDir))
+	}
+	targetConfig := run.ServerConfig
+	if len(run.TargetSSH) > 0 {
+		run.remoteTarget = openSSHTunnel(run.TargetSSH)
+		defer run.remoteTarget.close()
+		targetConfig = run.remoteTarget.configFile
+	}
+	defer run.openFileStores()()
+	// files written by the import are stored in the import directory, next to the archive, or in the working
+	// directory for streamed archives
+	run.statePrefix = absImportDir + "/"
+	if run.ImportStream != nil {
+		run.statePrefix = utils.GetAbsPath(".") + "/"
+		absImportDir = run.extractImportStream()
+		defer os.RemoveAll(absImportDir)
+	} else if exportArchive.IsArchive(absImportDir) || exportArchive.IsVolume(absImportDir) {
+		archivePath := absImportDir
+		if exportArchive.IsVolume(absImportDir) {
+			archivePath = exportArchive.ArchivePath(absImportDir)
+		}
+		run.statePrefix = strings.TrimSuffix(archivePath, exportArchive.Extension) + "."
+		absImportDir = run.extractImportArchive(absImportDir)
+		defer os.RemoveAll(absImportDir)
+	}
+	// an incomplete export may miss its version as well
+	validateFolder(absImportDir)
+	checkImporterVersion(absImportDir)
+	fversion, fproduct := getImportVersionProduct(absImportDir)
+	sversion, sproduct := utils.GetCurrentServerVersion(targetConfig)
+	if fversion != sversion || fproduct != sproduct {
+		utils.Panic().Int(utils.ExitCodeField, utils.ExitSchemaMismatch).Msgf("Wrong version detected. Fileversion = %s ; Serverversion = %s", fversion, sversion)
+	}
+	verifyVendorRows(absImportDir, targetConfig)
+	orgMapping := loadOrgMapping(absImportDir, run.OrgMapping, run.OrgMappingFile)
+	groupMapping := loadGroupMapping(absImportDir, run.GroupMapping, run.GroupMappingFile)
+	renames, ok := parseChannelRenames(run.ChannelRenames)
+	if !ok {
+		utils.Fatal().Int(utils.ExitCodeField, utils.ExitConfigError).Msg("Unable to parse the channel renames. Allowed format is 'old-label=new-label'")
+	}
+	run.channelRenames = renames
+	placeholderValues := run.loadPlaceholderValues(absImportDir, targetConfig)
+	if run.CreateMissingOrgs {
+		// created before the image folders of the organizations are looked up
+		orgReferences := statementRewriter(orgMapping, nil, nil, placeholderValues, placeholders.ReadColumns(absImportDir), nil)
+		if len(run.OnlyTables) > 0 {
+			orgReferences = filterStatements(run.OnlyTables, orgReferences)
+		}
+		createMissingOrgs(absImportDir, targetConfig, orgReferences)
+	}
+	imageOrgFolders := loadImageOrgFolders(absImportDir, targetConfig, orgMapping)
+	run.runPackageFileSync(absImportDir)
+	run.runCompsFileSync(absImportDir)
+	run.runRepodataSync(absImportDir)
+
+	run.runImageFileSync(absImportDir, targetConfig, imageOrgFolders)
+	run.runBootstrapRepositorySync(absImportDir)
+
+	rewrite := statementRewriter(orgMapping, groupMapping, run.channelRenames, placeholderValues, placeholders.ReadColumns(absImportDir), imageOrgFolders)
+	rewrite = unsealCredentials(run.readCredentialsKey(), run.CredentialsPrompt, rewrite)
+	if len(run.OnlyTables) > 0 {
+		log.Info().Msgf("Importing only the tables %s", strings.Join(run.OnlyTables, ", "))
+		rewrite = filterStatements(run.OnlyTables, rewrite)
+	}
+	run.runImportSql(absImportDir, targetConfig, rewrite)
+	if run.RegisterHub {
+		run.registerHub(absImportDir)
+	}
+	if len(run.rolledBackTables) > 0 {
+		utils.Fatal().Int(utils.ExitCodeField, utils.ExitPartialImport).Strs("tables", run.rolledBackTables).
+			Msgf("import finished without the rolled back tables %s", strings.Join(run.rolledBackTables, ", "))
+	}
+	log.Info().Msg("import finished")
+}
+
+func getImportVersionProduct(path string) (string, string) {
+	var versionfile string
+	versionfile = path + "/version.txt"
+	version, err := utils.ScannerFunc(versionfile, "version")
+	if err != nil {
+		log.Error().Msg("Version not found.")
+	}
+	product, err := utils.ScannerFunc(versionfile, "product_name")
+	if err != nil {
+		utils.Fatal().Msg("Product not found")
+	}
+	log.Debug().Msgf("Import Product: %s; Version: %s", product, version)
+	return version, product
+}
+
+func validateFolder(absImportDir string) {
+	if entityDumper.IsIncomplete(absImportDir) {
+		utils.Fatal().Int(utils.ExitCodeField, utils.ExitVerificationFailure).Msgf("The export in %s stopped before completion and cannot be imported", absImportDir)
+	}
+	_, err := os.Stat(fmt.Sprintf("%s/sql_statements.sql.gz", absImportDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			_, err = os.Stat(fmt.Sprintf("%s/sql_statements.sql", absImportDir))
+			if err != nil {
+				utils.Fatal().Err(err).Msg("No usable .sql or .gz file found in import directory")
+			}
+		} else {
+			utils.Fatal().Err(err).Msg("Error reading the SQL script of the import directory")
+		}
+	}
+}
+
+func hasConfigChannels(absImportDir string) bool {
+	_, err := os.Stat(fmt.Sprintf("%s/exportedConfigs.txt", absImportDir))
+	log.Info().Err(err).Msg(fmt.Sprintf("no export config file found: %s/exportedConfigs.txt", absImportDir))
+	return err == nil || os.IsExist(err)
+}
+
+func (run *importRun) runPackageFileSync(absImportDir string) {
+	defer tracing.StartSpan("copy package files").End()
+	if exportFolder, ok := sharedExportFolder(absImportDir); ok {
+		run.runSharedPackageFileSync(absImportDir, exportFolder)
+		return
+	}
+	packagesImportDir := fmt.Sprintf("%s/packages/", absImportDir)
+	err := utils.FolderExists(packagesImportDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Info().Msg("no package files to import")
+			return
+		} else {
+			utils.Fatal().Err(err).Msg("Error getting import packages folder")
+		}
+	}
+	if run.packageStore != nil {
+		log.Info().Msgf("starting uploading package files to %s", run.packageStore)
+		copier := dumper.NewFileCopier()
+		if err := queueFolderUploads(copier, run.packageStore, packagesImportDir, "packages"); err != nil {
+			utils.Fatal().Err(err).Msg("error reading the package files")
+		}
+		copier.Finish("package files")
+		return
+	}
+
+	rsyncParams := make([]string, 0)
+	if log.Debug().Enabled() {
+		rsyncParams = append(rsyncParams, "-v")
+	}
+
+	rsyncParams = append(rsyncParams, "-og", "--chown=wwwrun:www", "-r",
+		packagesImportDir, run.targetPath("/var/spacewalk/packages/"))
+
+	cmd := exec.CommandContext(utils.Context(), "rsync", rsyncParams...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	log.Info().Msg("starting importing package files")
+	err = cmd.Run()
+	if err != nil {
+		utils.Fatal().Err(err).Msg("error importing package files")
+	}
+}
+
+// runCompsFileSync copies the comps and modules files of the channels. Channels in subdirectories list them
+// with their package files.
+func (run *importRun) runCompsFileSync(absImportDir string) {
+	defer tracing.StartSpan("copy comps files").End()
+	if _, ok := sharedExportFolder(absImportDir); ok {
+		return
+	}
+	compsImportDir := path.Join(absImportDir, "rhn")
+	if err := utils.FolderExists(compsImportDir); err != nil {
+		if os.IsNotExist(err) {
+			return
+		}
+		utils.Fatal().Err(err).Msg("Error getting import comps folder")
+	}
+
+	rsyncParams := make([]string, 0)
+	if log.Debug().Enabled() {
+		rsyncParams = append(rsyncParams, "-v")
+	}
+	rsyncParams = append(rsyncParams, "-og", "--chown=wwwrun:www", "-r",
+		compsImportDir+"/", run.targetPath("/var/spacewalk/rhn/"))
+
+	cmd := exec.CommandContext(utils.Context(), "rsync", rsyncParams...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	log.Info().Msg("Copying comps and modules files")
+	if err := cmd.Run(); err != nil {
+		utils.Fatal().Err(err).Msg("error importing comps and modules files")
+	}
+}
+
+func (run *importRun) runRepodataSync(absImportDir string) {
+	defer tracing.StartSpan("copy repository metadata").End()
+	if exportFolder, ok := sharedExportFolder(absImportDir); ok {
+		run.runSharedRepodataSync(absImportDir, exportFolder)
+		return
+	}
+	repodataImportDir := path.Join(absImportDir, "repodata")
+	err := utils.FolderExists(repodataImportDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Info().Msg("no repository metadata to import")
+			return
+		} else {
+			utils.Fatal().Err(err).Msg("Error getting import repository metadata folder")
+		}
+	}
+
+	rsyncParams := make([]string, 0)
+	if log.Debug().Enabled() {
+		rsyncParams = append(rsyncParams, "-v")
+	}
+	rsyncParams = append(rsyncParams, "-og", "--chown=wwwrun:www", "-r")
+
+	// the metadata of renamed channels is copied to the folder of the new label
+	syncs := map[string]string{repodataImportDir + "/": run.targetPath("/var/cache/rhn/repodata/")}
+	excludes := make([]string, 0)
+	for oldLabel, newLabel := range run.channelRenames {
+		excludes = append(excludes, fmt.Sprintf("--exclude=/%s", oldLabel))
+		syncs[path.Join(repodataImportDir, oldLabel)+"/"] = run.targetPath(path.Join("/var/cache/rhn/repodata", newLabel) + "/")
+	}
+	log.Info().Msg("Copying repository metadata")
+	for source, target := range syncs {
+		if _, err := os.Stat(source); err != nil {
+			continue
+		}
+		params := append([]string{}, rsyncParams...)
+		if source == repodataImportDir+"/" {
+			params = append(params, excludes...)
+		}
+		cmd := exec.CommandContext(utils.Context(), "rsync", append(params, source, target)...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		err = cmd.Run()
+		if err != nil {
+			utils.Fatal().Err(err).Msg("Error importing repository metadata")
+		}
+	}
+}
+
+func runConfigFilesSync(labels []string, user string, password string) (interface{}, error) {
+	client := xmlrpc.NewClient(user, password)
+	return client.SyncConfigFiles(labels)
+}
+
+func (run *importRun) runImageFileSync(absImportDir string, serverConfig string, imageOrgFolders map[string]string) {
+	defer tracing.StartSpan("copy image files").End()
+	imagesImportDir := path.Join(absImportDir, "images")
+	err := utils.FolderExists(imagesImportDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Info().Msg("No image files to import")
+			return
+		} else {
+			utils.Fatal().Err(err).Msg("Error reading import folder for images")
+		}
+	}
+
+	rsyncParams := make([]string, 0)
+	if log.Debug().Enabled() {
+		rsyncParams = append(rsyncParams, "-v")
+	}
+	rsyncParams = append(rsyncParams, "-og", "--chown=salt:susemanager", "--chmod=Du=rwx,Dgo=rx,Fu=rw,Fgo=r", "-r")
+
+	log.Info().Msg("Copying image files")
+	orgFolders, err := os.ReadDir(imagesImportDir)
+	if err != nil {
+		utils.Fatal().Err(err).Msg("Error reading import folder for images")
+	}
+	if run.imageStore != nil {
+		copier := dumper.NewFileCopier()
+		for _, orgFolder := range orgFolders {
+			if orgFolder.IsDir() && orgFolder.Name() != "pillars" {
+				err := queueFolderUploads(copier, run.imageStore, path.Join(imagesImportDir, orgFolder.Name()), imageOrgFolder(imageOrgFolders, orgFolder.Name()))
+				if err != nil {
+					utils.Fatal().Err(err).Msg("Error reading import folder for images")
+				}
+			}
+		}
+		copier.Finish("image files")
+		return
+	}
+	for _, orgFolder := range orgFolders {
+		if !orgFolder.IsDir() || orgFolder.Name() == "pillars" {
+			continue
+		}
+		// images are stored in the folder of the organization on the target server
+		targetFolder := path.Join("/srv/www/os-images", imageOrgFolder(imageOrgFolders, orgFolder.Name())) + "/"
+		cmd := exec.CommandContext(utils.Context(), "rsync", append(rsyncParams, path.Join(imagesImportDir, orgFolder.Name())+"/", run.targetPath(targetFolder))...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		err = cmd.Run()
+		if err != nil {
+			utils.Fatal().Err(err).Msg("Error importing image files")
+		}
+	}
+
+	pillarImportDir := path.Join(absImportDir, "images", "pillars")
+	err = utils.FolderExists(pillarImportDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Debug().Msg("No pillar files to import")
+			return
+		} else {
+			utils.Fatal().Err(err).Msg("Error reading import folder for pillars")
+		}
+	}
+
+	if run.remoteTarget != nil {
+		log.Warn().Msgf("Image pillar files are not imported into remote servers, copy %s to the target server and import it locally", pillarImportDir)
+		return
+	}
+	log.Info().Msg("Copying image pillar files")
+	pillarDumper.ImportImagePillars(pillarImportDir, utils.GetCurrentServerFQDN(serverConfig))
+}
+
+// runCobblerSync creates the boot entries of the imported autoinstallable distributions and profiles
+func (run *importRun) runCobblerSync(absImportDir string) {
+	if _, err := os.Stat(fmt.Sprintf("%s/exportedAutoinstall.txt", absImportDir)); err != nil {
+		log.Debug().Msg("No autoinstallation data, NO CALL to cobbler sync")
+		return
+	}
+	if run.remoteTarget != nil {
+		log.Warn().Msg("Cobbler is not synchronized on remote servers. Please run cobbler sync on the target server")
+		return
+	}
+	log.Info().Msg("Synchronizing cobbler with the imported autoinstallation data")
+	client := xmlrpc.NewClient(run.XmlRpcUser, run.XmlRpcPassword)
+	if _, err := client.SyncCobbler(); err != nil {
+		log.Error().Err(err).Msg("Error synchronizing cobbler. Please run cobbler sync")
+	}
+}
+
+// runAutoinstallVariables sets the cobbler variables of the imported profiles. Variables of distributions
+// cannot be set through the API, they are only reported.
+func (run *importRun) runAutoinstallVariables(absImportDir string) {
+	variables := entityDumper.ReadAutoinstallVariables(absImportDir)
+	if len(variables) == 0 {
+		return
+	}
+	if run.remoteTarget != nil {
+		log.Warn().Msgf("Autoinstallation variables are not set on remote servers, they are listed in %s",
+			path.Join(absImportDir, entityDumper.AutoinstallVariablesFileName))
+		return
+	}
+	client := xmlrpc.NewClient(run.XmlRpcUser, run.XmlRpcPassword)
+	for _, entry := range variables {
+		if entry.Table != "rhnksdata" {
+			log.Warn().Msgf("Variables of autoinstallable distribution %s must be set on the target server: %v", entry.Label, entry.Variables)
+			continue
+		}
+		if _, err := client.SetProfileVariables(entry.Label, entry.Variables); err != nil {
+			log.Error().Err(err).Msgf("Error setting the variables of autoinstallation profile %s: %v", entry.Label, entry.Variables)
+		}
+	}
+}
+
+// runRepoSyncSchedules schedules the repository synchronization of the imported channels as on the source server
+func (run *importRun) runRepoSyncSchedules(absImportDir string) {
+	schedules := entityDumper.ReadRepoSyncSchedules(absImportDir)
+	if len(schedules) == 0 {
+		return
+	}
+	if run.remoteTarget != nil {
+		log.Warn().Msgf("Repository synchronization schedules are not created on remote servers, they are listed in %s",
+			path.Join(absImportDir, entityDumper.RepoSyncSchedulesFileName))
+		return
+	}
+	client := xmlrpc.NewClient(run.XmlRpcUser, run.XmlRpcPassword)
+	scheduled := 0
+	for _, schedule := range schedules {
+		channelLabel := renamedChannelLabel(run.channelRenames, schedule.Channel)
+		// the parameters are flags, stored as strings by taskomatic
+		params := make(map[string]interface{}, len(schedule.Params))
+		for name, value := range schedule.Params {
+			params[name] = value == "true"
+		}
+		if _, err := client.ScheduleRepoSync(channelLabel, schedule.CronExpr, params); err != nil {
+			log.Error().Err(err).Msgf("Error scheduling the repository synchronization of channel %s with '%s'. Please schedule it on the target server",
+				channelLabel, schedule.CronExpr)
+			continue
+		}
+		log.Debug().Msgf("Repository synchronization of channel %s scheduled with '%s'", channelLabel, schedule.CronExpr)
+		scheduled++
+	}
+	log.Info().Msgf("Repository synchronization of %d channels scheduled", scheduled)
+}
+
+func (run *importRun) runImportSql(absImportDir string, serverConfig string, rewrite func(statement string) string) {
+
+	sqlFile := fmt.Sprintf("%s/sql_statements.sql.gz", absImportDir)
+	if _, err := os.Stat(sqlFile); err != nil {
+		sqlFile = fmt.Sprintf("%s/sql_statements.sql", absImportDir)
+	}
+	if _, err := os.Stat(sqlFile); err == nil {
+		span := tracing.StartSpan("import sql")
+		defer span.End()
+		report := newImportReport()
+		report.scanTables(sqlFile)
+		if len(run.OnlyTables) > 0 {
+			report.restrictTables(run.OnlyTables)
+		}
+		var checkpoint *importCheckpoint
+		if run.BatchSize > 0 || run.Resume {
+			progressFile := run.ProgressFile
+			if len(progressFile) == 0 {
+				progressFile = run.statePrefix + "importProgress.json"
+			}
+			checkpoint = newImportCheckpoint(utils.GetAbsPath(progressFile), sqlFile, run.BatchSize, run.Resume)
+			// batches after a failed statement must not be committed
+			rewrite = strictTransaction(checkpoint.rewriter(rewrite))
+			report.commitHook = checkpoint.committed
+		} else if run.Strict {
+			rewrite = strictTransaction(rewrite)
+		} else {
+			rewrite = tableSavepoints(rewrite)
+		}
+		db := schemareader.GetDBconnection(serverConfig)
+		rowsBefore := report.countRows(db)
+		var load *bulkLoad
+		if run.BulkLoad {
+			tableNames := make([]string, 0, len(report.Tables))
+			for tableName := range report.Tables {
+				tableNames = append(tableNames, tableName)
+			}
+			load = newBulkLoad(db, tableNames, run.statePrefix+"bulkLoadRestore.sql")
+			load.prepare(db)
+		}
+		if checkpoint != nil {
+			checkpoint.batchStart = time.Now()
+		}
+		err := run.importSqlScript(sqlFile, rewrite, report)
+		if load != nil {
+			load.finish(db)
+		}
+		if err != nil {
+			span.SetError(err)
+			utils.Fatal().Err(err).Int(utils.ExitCodeField, utils.ExitDatabaseError).Msgf("Error running the SQL script")
+		}
+		if checkpoint != nil {
+			checkpoint.finish()
+		}
+		report.reconcile(rowsBefore, report.countRows(db))
+		recordSyncHistory(db, absImportDir, report)
+		span.SetAttribute("tables", len(report.Tables))
+		db.Close()
+		report.print()
+		reportFile := run.ReportFile
+		if len(reportFile) == 0 {
+			reportFile = run.statePrefix + "importReport.json"
+		}
+		report.save(utils.GetAbsPath(reportFile))
+		run.rolledBackTables = report.RolledBackTables
+		run.report = report
+		run.reportFile = reportFile
+	}
+
+	run.queueRepodataRegeneration(absImportDir)
+	run.refreshReportDb(absImportDir, serverConfig)
+	run.runCobblerSync(absImportDir)
+	run.runAutoinstallVariables(absImportDir)
+	run.runRepoSyncSchedules(absImportDir)
+	pillarDumper.UpdateImagePillars(serverConfig)
+
+	if hasConfigChannels(absImportDir) {
+		labels := utils.ReadFileByLine(fmt.Sprintf("%s/exportedConfigs.txt", absImportDir))
+		if run.remoteTarget != nil {
+			log.Warn().Msgf("Configuration files are not recreated on remote servers. Please run spacecmd api configchannel.syncSaltFilesOnDisk -A '[[%s]]' on the target server",
+				strings.Join(labels, ", "))
+			return
+		}
+		log.Debug().Msg("Will call xml-rpc API to update filesystem")
+		_, err := runConfigFilesSync(labels, run.XmlRpcUser, run.XmlRpcPassword)
+		if err != nil {
+			log.Error().Err(err).Msgf(
+				"Error recreating configuration files. Please run spacecmd api configchannel.syncSaltFilesOnDisk -A '[[%s]]'",
+				strings.Join(labels, ", "),
+			)
+		}
+	} else {
+		log.Debug().Msg("No configuration channels, NO CALL to xml-rpc API")
+	}
+}
+
+// loadPlaceholderValues returns the target server values of the placeholders used in the export, indexed by token
+func (run *importRun) loadPlaceholderValues(absImportDir string, targetConfig string) map[string]string {
+	tokens := placeholders.ReadTokens(absImportDir)
+	if len(tokens) == 0 {
+		return nil
+	}
+	entries := make([]string, 0)
+	for _, entry := range run.Placeholders {
+		if !strings.Contains(entry, "=") {
+			utils.Fatal().Int(utils.ExitCodeField, utils.ExitConfigError).Msgf("Placeholder %s has no value. Allowed format is 'TOKEN=value'", entry)
+		}
+		entries = append(entries, entry)
+	}
+	values, ok := placeholders.ParseValues(entries, targetConfig)
+	if !ok {
+		utils.Fatal().Int(utils.ExitCodeField, utils.ExitConfigError).Msg("Unable to parse the placeholders. Allowed format is 'TOKEN=value', with upper case tokens")
+	}
+	result := make(map[string]string)
+	for _, token := range tokens {
+		value, ok := values[token]
+		if !ok {
+			if value, ok = placeholders.DetectValue(token, targetConfig); !ok {
+				utils.Fatal().Int(utils.ExitCodeField, utils.ExitConfigError).Msgf("No value for placeholder %s, set it with --placeholder=%s=value", token, token)
+			}
+		}
+		log.Debug().Msgf("Substituting placeholder %s with %s", token, value)
+		result[token] = value
+	}
+	return result
+}
+
+// statementRewriter returns the function rewriting the imported statements for the organization and group mappings,
+// channel renames, placeholders and image folders, or nil if the statements are imported as exported.
+// Statements whose literals span lines are rewritten as a whole.
+func statementRewriter(orgMapping map[string]string, groupMapping map[string]string, channelRenames map[string]string,
+	placeholderValues map[string]string, placeholderColumns placeholders.ColumnSet, imageOrgFolders map[string]string) func(statement string) string {
+	if len(orgMapping) == 0 && len(groupMapping) == 0 && len(channelRenames) == 0 && len(placeholderValues) == 0 && len(imageOrgFolders) == 0 {
+		return nil
+	}
+	quotedRenames := quoteChannelRenames(channelRenames)
+	return joinStatementLines(func(statement string) string {
+		if len(placeholderValues) > 0 {
+			statement = rewritePlaceholders(statement, placeholderValues, placeholderColumns)
+		}
+		if len(groupMapping) > 0 {
+			statement = rewriteGroupReferences(statement, groupMapping)
+		}
+		if len(orgMapping) > 0 {
+			statement = rewriteOrgReferences(statement, orgMapping)
+		}
+		if len(quotedRenames) > 0 {
+			statement = rewriteChannelReferences(statement, quotedRenames)
+		}
+		if len(imageOrgFolders) > 0 {
+			statement = rewriteImageOrgFolders(statement, imageOrgFolders)
+		}
+		return statement
+	})
+}
+
+// targetPath returns the location of a path on the server the data is imported into
+func (run *importRun) targetPath(path string) string {
+	if run.remoteTarget != nil {
+		return run.remoteTarget.remotePath(path)
+	}
+	return path
+}
+
+// session settings of the import, for the exports written before they set them
+var importSessionEnvironment = []string{"PGTZ=UTC", "PGDATESTYLE=ISO, YMD"}
+
+// sqlImportCommand returns the command running the SQL file, or the standard input for "-", on the target server
+func (run *importRun) sqlImportCommand(sqlFile string) *exec.Cmd {
+	if run.remoteTarget == nil {
+		cmd := exec.CommandContext(utils.Context(), "spacewalk-sql", sqlFile)
+		cmd.Env = append(os.Environ(), importSessionEnvironment...)
+		return cmd
+	}
+	cmd := exec.CommandContext(utils.Context(), "psql", "-X", "-v", "ON_ERROR_STOP=1", "-f", sqlFile)
+	cmd.Env = append(append(os.Environ(), importSessionEnvironment...), schemareader.GetConnectionEnvironment(run.remoteTarget.configFile)...)
+	return cmd
+}
+
+// queueRepodataRegeneration asks taskomatic to generate the repository metadata of the imported channels
+// which were exported without it
+func (run *importRun) queueRepodataRegeneration(absImportDir string) {
+	channelsFile := path.Join(absImportDir, "exportedChannels.txt")
+	if _, err := os.Stat(channelsFile); err != nil {
+		log.Debug().Msg("No channels imported, no repository metadata to generate")
+		return
+	}
+	statements := make([]string, 0)
+	for _, channelLabel := range utils.ReadFileByLine(channelsFile) {
+		channelLabel = strings.TrimSpace(channelLabel)
+		if len(channelLabel) == 0 {
+			continue
+		}
+		if packageDumper.HasRepodata(path.Join(absImportDir, "repodata", channelLabel)) {
+			log.Debug().Msgf("Repository metadata of channel %s imported", channelLabel)
+			continue
+		}
+		channelLabel = renamedChannelLabel(run.channelRenames, channelLabel)
+		statements = append(statements, fmt.Sprintf(`INSERT INTO rhnRepoRegenQueue
+		(id, channel_label, client, reason, force, bypass_filters, next_action, created, modified)
+		VALUES (null, %s, 'inter server sync v2', 'channel sync', 'N', 'N', current_timestamp, current_timestamp, current_timestamp);`,
+			pq.QuoteLiteral(channelLabel)))
+	}
+	if len(statements) == 0 {
+		return
+	}
+
+	cmd := run.sqlImportCommand("-")
+	cmd.Stdin = strings.NewReader(strings.Join(statements, "\n") + "\n")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	log.Info().Msgf("Queueing repository metadata generation for %d channels", len(statements))
+	if err := cmd.Run(); err != nil {
+		utils.Fatal().Err(err).Msg("Error queueing repository metadata generation")
+	}
+}
@@ -0,0 +1,69 @@
+package syncEngine
+
+import (
+	"os"
+	"os/user"
+	"path"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/exportArchive"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// extractImportArchive unpacks the archive, or all volumes of a split archive, into a temporary import directory. The package files are written to
+// the package folder of the local server directly, so only the small files of the export need extra space.
+func (run *importRun) extractImportArchive(archivePath string) string {
+	log.Info().Msgf("Reading archive %s", archivePath)
+	return run.extractInto(func(importDir string, directFolders map[string]string) []string {
+		if exportArchive.IsVolume(archivePath) {
+			return exportArchive.ExtractVolumes(archivePath, importDir, directFolders, run.KmsKey)
+		}
+		return exportArchive.Extract(archivePath, importDir, directFolders, run.KmsKey)
+	})
+}
+
+// extractImportStream unpacks the streamed archive into a temporary import directory, as extractImportArchive does
+func (run *importRun) extractImportStream() string {
+	log.Info().Msg("Reading the archive stream")
+	return run.extractInto(func(importDir string, directFolders map[string]string) []string {
+		return exportArchive.ExtractStream(run.ImportStream, importDir, directFolders, run.KmsKey)
+	})
+}
+
+func (run *importRun) extractInto(extract func(importDir string, directFolders map[string]string) []string) string {
+	importDir, err := os.MkdirTemp("", "inter-server-sync-")
+	if err != nil {
+		utils.Fatal().Err(err).Msg("Error creating the import directory")
+	}
+	directFolders := make(map[string]string)
+	// package files uploaded to a package store are extracted into the import directory
+	if run.remoteTarget == nil && len(run.PackageStore) == 0 {
+		directFolders["packages"] = "/var/spacewalk/packages"
+	}
+	packagePaths := extract(importDir, directFolders)
+	if len(packagePaths) > 0 {
+		setPackageFilesOwner(packagePaths)
+	}
+	return importDir
+}
+
+// setPackageFilesOwner gives the extracted package files to the web server, as the package files sync does
+func setPackageFilesOwner(paths []string) {
+	webUser, err := user.Lookup("wwwrun")
+	if err != nil {
+		utils.Fatal().Err(err).Msg("Error looking up the owner of the package files")
+	}
+	webGroup, err := user.LookupGroup("www")
+	if err != nil {
+		utils.Fatal().Err(err).Msg("Error looking up the group of the package files")
+	}
+	uid, _ := strconv.Atoi(webUser.Uid)
+	gid, _ := strconv.Atoi(webGroup.Gid)
+	for _, packagePath := range paths {
+		if err := os.Lchown(packagePath, uid, gid); err != nil {
+			utils.Fatal().Err(err).Msgf("Error changing the owner of %s", path.Base(packagePath))
+		}
+	}
+	log.Debug().Msgf("Owner of %d package files and folders changed", len(paths))
+}
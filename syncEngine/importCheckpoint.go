@@ -0,0 +1,179 @@
+package syncEngine
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/dumper"
+	"github.com/uyuni-project/inter-server-sync/tracing"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// importProgress is the progress of an import committing its statements in batches, saved after every batch
+type importProgress struct {
+	SqlSha256           string `json:"sqlSha256"`
+	BatchSize           int    `json:"batchSize"`
+	CommittedBatches    int    `json:"committedBatches"`
+	CommittedStatements int    `json:"committedStatements"`
+	// digest of the checksum of the SQL script, SHA-256 when empty
+	Digest string `json:"digest,omitempty"`
+}
+
+// importCheckpoint splits the import into transactions of batchSize statements, recording the committed ones
+// from the command tags of the import command
+type importCheckpoint struct {
+	path     string
+	progress importProgress
+	// statements already committed by a previous run, which are skipped
+	skip int
+	// state of the statements written to the import command
+	statements      int
+	batchStatements int
+	inTransaction   bool
+	// statements committed by each COMMIT written and not acknowledged yet
+	pending []int
+	mutex   sync.Mutex
+	// start of the batch running, traced when it is committed
+	batchStart time.Time
+}
+
+func sqlFileChecksum(sqlFile string, digest string) string {
+	hash, err := utils.NewDigestOf(digest)
+	if err != nil {
+		utils.Fatal().Err(err).Msg("Error checking the SQL script")
+	}
+	file, err := os.Open(sqlFile)
+	if err != nil {
+		utils.Fatal().Err(err).Msg("Error opening the SQL script")
+	}
+	defer file.Close()
+	if _, err := io.Copy(hash, file); err != nil {
+		utils.Fatal().Err(err).Msg("Error reading the SQL script")
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// newImportCheckpoint starts recording the progress of the import, or continues the recorded one on resume
+func newImportCheckpoint(path string, sqlFile string, batchSize int, resume bool) *importCheckpoint {
+	checkpoint := &importCheckpoint{path: path, progress: importProgress{BatchSize: batchSize, Digest: utils.Digest()}}
+	if !resume {
+		checkpoint.progress.SqlSha256 = sqlFileChecksum(sqlFile, checkpoint.progress.Digest)
+		return checkpoint
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		utils.Fatal().Err(err).Msgf("No import progress to resume from in %s", path)
+	}
+	recorded := importProgress{}
+	if err := json.Unmarshal(content, &recorded); err != nil {
+		utils.Fatal().Err(err).Msgf("Import progress %s is corrupted", path)
+	}
+	// the checksum is compared with the digest of the recorded one
+	if recorded.SqlSha256 != sqlFileChecksum(sqlFile, recorded.Digest) {
+		utils.Fatal().Msgf("Import progress %s was recorded for another export", path)
+	}
+	checkpoint.progress = recorded
+	checkpoint.skip = recorded.CommittedStatements
+	log.Info().Msgf("Resuming the import after %d statements committed in %d batches",
+		recorded.CommittedStatements, recorded.CommittedBatches)
+	return checkpoint
+}
+
+// rewriter returns a rewrite function replacing the transaction of the export with batches, and skipping
+// the statements committed before
+func (checkpoint *importCheckpoint) rewriter(rewrite func(statement string) string) func(statement string) string {
+	return func(line string) string {
+		if rewrite != nil {
+			line = rewrite(line)
+		}
+		trimmedLine := strings.TrimSpace(line)
+		switch {
+		case trimmedLine == "BEGIN;":
+			return ""
+		case trimmedLine == "COMMIT;":
+			if !checkpoint.inTransaction {
+				return ""
+			}
+			checkpoint.inTransaction = false
+			checkpoint.addPending(checkpoint.statements)
+			return line
+		}
+		if _, ok := dumper.AnalyzedTable(line); ok {
+			return line
+		}
+		if _, ok := dumper.StatementTable(line); !ok {
+			// session statements like SET apply to the statements which are not skipped as well. Statements spanning
+			// lines are received complete, joined by the statement rewriter
+			return line
+		}
+		checkpoint.statements++
+		if checkpoint.statements <= checkpoint.skip {
+			return ""
+		}
+		prefix := ""
+		if checkpoint.inTransaction && checkpoint.batchStatements >= checkpoint.progress.BatchSize {
+			checkpoint.addPending(checkpoint.statements - 1)
+			prefix = "COMMIT;\n"
+			checkpoint.inTransaction = false
+		}
+		if !checkpoint.inTransaction {
+			prefix += "BEGIN;\n"
+			checkpoint.inTransaction = true
+			checkpoint.batchStatements = 0
+		}
+		checkpoint.batchStatements++
+		return prefix + line
+	}
+}
+
+func (checkpoint *importCheckpoint) addPending(statements int) {
+	checkpoint.mutex.Lock()
+	defer checkpoint.mutex.Unlock()
+	checkpoint.pending = append(checkpoint.pending, statements)
+}
+
+// committed records the batch acknowledged by the COMMIT command tag of the import command
+func (checkpoint *importCheckpoint) committed() {
+	checkpoint.mutex.Lock()
+	defer checkpoint.mutex.Unlock()
+	if len(checkpoint.pending) == 0 {
+		return
+	}
+	span := tracing.RecordSpan("import batch", checkpoint.batchStart)
+	span.SetAttribute("batch", checkpoint.progress.CommittedBatches+1)
+	span.SetAttribute("statements", checkpoint.pending[0]-checkpoint.progress.CommittedStatements)
+	span.End()
+	checkpoint.batchStart = time.Now()
+	checkpoint.progress.CommittedStatements = checkpoint.pending[0]
+	checkpoint.progress.CommittedBatches++
+	checkpoint.pending = checkpoint.pending[1:]
+	checkpoint.save()
+}
+
+func (checkpoint *importCheckpoint) save() {
+	content, err := json.MarshalIndent(checkpoint.progress, "", "  ")
+	if err != nil {
+		utils.Panic().Err(err).Msg("error encoding import progress")
+	}
+	// the previous progress stays valid until the new one is complete
+	if err := os.WriteFile(checkpoint.path+".tmp", content, 0644); err != nil {
+		utils.Fatal().Err(err).Msg("Error writing the import progress")
+	}
+	if err := os.Rename(checkpoint.path+".tmp", checkpoint.path); err != nil {
+		utils.Fatal().Err(err).Msg("Error writing the import progress")
+	}
+}
+
+// finish removes the progress of the completed import, which must not be resumed
+func (checkpoint *importCheckpoint) finish() {
+	if err := os.Remove(checkpoint.path); err != nil && !os.IsNotExist(err) {
+		log.Warn().Err(err).Msgf("Error removing the import progress %s", checkpoint.path)
+	}
+	log.Info().Msgf("%d batches committed", checkpoint.progress.CommittedBatches)
+}
@@ -0,0 +1,32 @@
+package syncEngine
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckpointRewriterKeepsSessionStatementsOnResume(t *testing.T) {
+	checkpoint := &importCheckpoint{progress: importProgress{BatchSize: 10}, skip: 1}
+	rewrite := checkpoint.rewriter(nil)
+	lines := []string{
+		"BEGIN;",
+		"SET session_replication_role = replica;",
+		"INSERT INTO rhnchannel (id, label)\tVALUES ('1','skipped');",
+		"SET search_path = public;",
+		"INSERT INTO rhnchannel (id, label)\tVALUES ('2','imported');",
+		"COMMIT;",
+	}
+
+	output := make([]string, 0)
+	for _, line := range lines {
+		if rewritten := rewrite(line); len(rewritten) > 0 {
+			output = append(output, rewritten)
+		}
+	}
+
+	expected := "SET session_replication_role = replica;\nSET search_path = public;\nBEGIN;\n" +
+		"INSERT INTO rhnchannel (id, label)\tVALUES ('2','imported');\nCOMMIT;"
+	if strings.Join(output, "\n") != expected {
+		t.Errorf("Expected %s, got %s", expected, strings.Join(output, "\n"))
+	}
+}
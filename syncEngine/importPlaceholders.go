@@ -0,0 +1,69 @@
+package syncEngine
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/uyuni-project/inter-server-sync/placeholders"
+)
+
+// rewritePlaceholders substitutes the placeholders in the literals of the placeholder columns: the values inserted
+// into them and the values they are compared with. Placeholders found in other literals are data and kept.
+func rewritePlaceholders(statement string, values map[string]string, columns placeholders.ColumnSet) string {
+	if !strings.Contains(statement, "{") {
+		return statement
+	}
+	parts, _ := splitStatement(statement)
+	substituted := make(map[int]bool)
+	tableName, insertedValues := insertedColumnValues(parts)
+	for column, part := range insertedValues {
+		if columns.Contains(tableName, column) {
+			substituted[part] = true
+		}
+	}
+	for i := 1; i < len(parts); i++ {
+		if !parts[i].literal || parts[i-1].literal {
+			continue
+		}
+		if column, ok := comparedColumn(parts[i-1].text); ok && columns.ContainsColumnName(column) {
+			substituted[i] = true
+		}
+	}
+	for i := range substituted {
+		// JSON values are cast after their literal
+		isJson := i+1 < len(parts) && !parts[i+1].literal && strings.HasPrefix(parts[i+1].text, "::json")
+		parts[i].text = substituteLiteral(parts[i].text, values, isJson)
+	}
+	return joinStatement(parts)
+}
+
+// substituteLiteral replaces the placeholders of the literal with their values, escaped for the kind of literal:
+// quotes are doubled, backslashes are escaped in E'...' literals and JSON strings
+func substituteLiteral(literal string, values map[string]string, isJson bool) string {
+	escapes := isEscapeLiteral(literal, strings.IndexByte(literal, '\''))
+	for token, value := range values {
+		placeholder := placeholders.Format(token)
+		if !strings.Contains(literal, placeholder) {
+			continue
+		}
+		if isJson {
+			value = formatJsonStringContent(value)
+		}
+		if escapes {
+			value = strings.ReplaceAll(value, `\`, `\\`)
+		}
+		literal = strings.ReplaceAll(literal, placeholder, strings.ReplaceAll(value, "'", "''"))
+	}
+	return literal
+}
+
+// formatJsonStringContent returns the value escaped as the content of a JSON string, without the quotes
+func formatJsonStringContent(value string) string {
+	var content bytes.Buffer
+	encoder := json.NewEncoder(&content)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(value)
+	encoded := strings.TrimSpace(content.String())
+	return encoded[1 : len(encoded)-1]
+}
@@ -0,0 +1,51 @@
+package syncEngine
+
+import (
+	"testing"
+
+	"github.com/uyuni-project/inter-server-sync/placeholders"
+)
+
+func TestRewritePlaceholdersOfColumns(t *testing.T) {
+	columns, _ := placeholders.NewColumnSet(nil)
+	values := map[string]string{placeholders.ServerFQDN: "peripheral.example.com"}
+	statement := "INSERT INTO rhncontentsource (id, label, source_url)\tVALUES ((SELECT nextval('rhn_chan_content_src_id_seq')),'{SERVER_FQDN} repo','https://{SERVER_FQDN}/repo') " +
+		"ON CONFLICT (label) DO UPDATE SET source_url = excluded.source_url;"
+
+	rewritten := rewritePlaceholders(statement, values, columns)
+
+	expected := "INSERT INTO rhncontentsource (id, label, source_url)\tVALUES ((SELECT nextval('rhn_chan_content_src_id_seq')),'{SERVER_FQDN} repo','https://peripheral.example.com/repo') " +
+		"ON CONFLICT (label) DO UPDATE SET source_url = excluded.source_url;"
+	if rewritten != expected {
+		t.Errorf("Expected %s, got %s", expected, rewritten)
+	}
+}
+
+func TestRewritePlaceholdersOfComparedColumns(t *testing.T) {
+	columns, _ := placeholders.NewColumnSet(nil)
+	values := map[string]string{placeholders.ServerFQDN: "peripheral.example.com"}
+	statement := "INSERT INTO rhnchannelcontentsource (source_id)\tSELECT (SELECT id FROM rhncontentsource WHERE source_url = 'https://{SERVER_FQDN}/repo' AND label = '{SERVER_FQDN}' LIMIT 1);"
+
+	rewritten := rewritePlaceholders(statement, values, columns)
+
+	expected := "INSERT INTO rhnchannelcontentsource (source_id)\tSELECT (SELECT id FROM rhncontentsource WHERE source_url = 'https://peripheral.example.com/repo' AND label = '{SERVER_FQDN}' LIMIT 1);"
+	if rewritten != expected {
+		t.Errorf("Expected %s, got %s", expected, rewritten)
+	}
+}
+
+func TestRewritePlaceholdersEscapesValues(t *testing.T) {
+	columns, _ := placeholders.NewColumnSet(nil)
+	values := map[string]string{"DATA_PATH": `C:\data's`}
+	statement := `INSERT INTO rhnkickstartabletree (id, base_path)	VALUES ('1', E'{DATA_PATH}\\tree');`
+	expected := `INSERT INTO rhnkickstartabletree (id, base_path)	VALUES ('1', E'C:\\data''s\\tree');`
+	if rewritten := rewritePlaceholders(statement, values, columns); rewritten != expected {
+		t.Errorf("Expected %s, got %s", expected, rewritten)
+	}
+
+	statement = `INSERT INTO susesaltpillar (id, pillar)	VALUES ('1','{"path": "{DATA_PATH}"}'::jsonb);`
+	expected = `INSERT INTO susesaltpillar (id, pillar)	VALUES ('1','{"path": "C:\\data''s"}'::jsonb);`
+	if rewritten := rewritePlaceholders(statement, values, columns); rewritten != expected {
+		t.Errorf("Expected %s, got %s", expected, rewritten)
+	}
+}
@@ -0,0 +1,124 @@
+package syncEngine
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// configuration files read from the target server, concatenated with the defaults first: the last value of a
+// property wins, so the values of rhn.conf override the defaults
+var remoteConfigFiles = []string{
+	"/usr/share/rhn/config-defaults/rhn.conf",
+	"/usr/share/rhn/config-defaults/rhn_web.conf",
+	"/etc/rhn/rhn.conf",
+}
+
+// sshTunnel forwards a local port to the database of the target server
+type sshTunnel struct {
+	destination string
+	cmd         *exec.Cmd
+	// local copy of the target server configuration, with the database connection going through the tunnel
+	configDir  string
+	configFile string
+}
+
+// openSSHTunnel reads the database configuration of the target server and forwards a local port to it
+func openSSHTunnel(destination string) *sshTunnel {
+	configDir, err := os.MkdirTemp("", "inter-server-sync-")
+	if err != nil {
+		utils.Fatal().Err(err).Msg("error creating temporary directory")
+	}
+	tunnel := &sshTunnel{destination: destination, configDir: configDir, configFile: filepath.Join(configDir, "rhn.conf")}
+
+	var remoteConfig bytes.Buffer
+	readConfig := exec.Command("ssh", destination, "cat "+strings.Join(remoteConfigFiles, " ")+" 2>/dev/null")
+	readConfig.Stdout = &remoteConfig
+	readConfig.Stderr = os.Stderr
+	// cat fails when one of the default files is missing, which is only a problem if nothing was read
+	if err := readConfig.Run(); err != nil && remoteConfig.Len() == 0 {
+		tunnel.close()
+		utils.Fatal().Err(err).Msgf("error reading the configuration of %s", destination)
+	}
+	dbHost := readConfigValue(remoteConfig.String(), "db_host")
+	dbPort := readConfigValue(remoteConfig.String(), "db_port")
+	if len(dbHost) == 0 {
+		dbHost = "localhost"
+	}
+	if len(dbPort) == 0 {
+		dbPort = "5432"
+	}
+
+	localPort := getFreeLocalPort()
+	tunnel.cmd = exec.Command("ssh", "-N", "-o", "ExitOnForwardFailure=yes",
+		"-L", fmt.Sprintf("127.0.0.1:%d:%s:%s", localPort, dbHost, dbPort), destination)
+	tunnel.cmd.Stderr = os.Stderr
+	if err := tunnel.cmd.Start(); err != nil {
+		tunnel.close()
+		utils.Fatal().Err(err).Msg("error starting the ssh tunnel")
+	}
+	waitForLocalPort(tunnel, localPort)
+
+	// the last value of a property wins when reading the database connection
+	config := fmt.Sprintf("%s\ndb_host = 127.0.0.1\ndb_port = %d\n", remoteConfig.String(), localPort)
+	if err := os.WriteFile(tunnel.configFile, []byte(config), 0600); err != nil {
+		tunnel.close()
+		utils.Fatal().Err(err).Msg("error writing the target server configuration")
+	}
+	log.Info().Msgf("Connected to the database of %s through local port %d", destination, localPort)
+	return tunnel
+}
+
+func (tunnel *sshTunnel) close() {
+	if tunnel.cmd != nil && tunnel.cmd.Process != nil {
+		tunnel.cmd.Process.Kill()
+		tunnel.cmd.Wait()
+	}
+	os.RemoveAll(tunnel.configDir)
+}
+
+// remotePath returns the rsync destination for a path on the target server
+func (tunnel *sshTunnel) remotePath(path string) string {
+	return fmt.Sprintf("%s:%s", tunnel.destination, path)
+}
+
+// readConfigValue returns the last value of the property, like the database configuration reader
+func readConfigValue(config string, key string) string {
+	value := ""
+	for _, line := range strings.Split(config, "\n") {
+		if equal := strings.Index(line, "="); equal >= 0 && strings.TrimSpace(line[:equal]) == key {
+			value = strings.TrimSpace(line[equal+1:])
+		}
+	}
+	return value
+}
+
+func getFreeLocalPort() int {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		utils.Fatal().Err(err).Msg("error getting a free local port")
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func waitForLocalPort(tunnel *sshTunnel, port int) {
+	for i := 0; i < 100; i++ {
+		connection, err := net.DialTimeout("tcp", fmt.Sprintf("127.0.0.1:%d", port), time.Second)
+		if err == nil {
+			connection.Close()
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	tunnel.close()
+	utils.Fatal().Msgf("ssh tunnel to %s not available", tunnel.destination)
+}
@@ -0,0 +1,20 @@
+package syncEngine
+
+import (
+	"testing"
+)
+
+func TestReadConfigValueOverridesDefaults(t *testing.T) {
+	// defaults first, then rhn.conf, like the concatenated remote configuration files
+	config := "db_port = 5432\ndb_host = localhost\ndb_name = susemanager\ndb_host = db.example.com\n"
+
+	if dbHost := readConfigValue(config, "db_host"); dbHost != "db.example.com" {
+		t.Errorf("Expected the value of rhn.conf, got %s", dbHost)
+	}
+	if dbPort := readConfigValue(config, "db_port"); dbPort != "5432" {
+		t.Errorf("Expected the default value, got %s", dbPort)
+	}
+	if remoteConfigFiles[len(remoteConfigFiles)-1] != "/etc/rhn/rhn.conf" {
+		t.Errorf("rhn.conf must be read last to override the defaults, got %v", remoteConfigFiles)
+	}
+}
@@ -0,0 +1,211 @@
+package syncEngine
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/dumper"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// ImportReport counts what the SQL import did on the target server
+type ImportReport struct {
+	Statements int `json:"statements"`
+	// rows written by inserts, which are new rows or rows updated on conflict
+	Written  int                     `json:"-"`
+	Inserted int                     `json:"inserted"`
+	Skipped  int                     `json:"skipped"`
+	Updated  int                     `json:"updated"`
+	Deleted  int                     `json:"deleted"`
+	Tables   map[string]*TableReport `json:"tables"`
+	// tables whose statements were rolled back after a failure
+	RolledBackTables []string `json:"rolledBackTables,omitempty"`
+	// output of the import command, parsed for the command tags
+	output     *io.PipeWriter
+	outputDone sync.WaitGroup
+	// called for every transaction committed by the import command
+	commitHook func()
+}
+
+// TableReport counts the statements run on a table and its rows before and after the import
+type TableReport struct {
+	Statements int `json:"statements"`
+	RowsBefore int `json:"rowsBefore"`
+	RowsAfter  int `json:"rowsAfter"`
+}
+
+// command tags printed by psql for every statement
+var commandTagPattern = regexp.MustCompile(`^(INSERT 0|UPDATE|DELETE) (\d+)$`)
+
+// command tags of the statements controlling the import, which are not printed
+var silentCommandTags = map[string]bool{"BEGIN": true, "COMMIT": true, "ANALYZE": true, "SAVEPOINT": true,
+	"RELEASE": true, "ROLLBACK": true, "DO": true, "SET": true}
+
+func newImportReport() *ImportReport {
+	return &ImportReport{Tables: make(map[string]*TableReport)}
+}
+
+// scanTables adds the tables changed by the script to the report
+func (report *ImportReport) scanTables(sqlFile string) {
+	script := dumper.OpenSqlScript(sqlFile)
+	defer script.Close()
+	script.ForEachLine(func(line string) {
+		if tableName, ok := dumper.StatementTable(line); ok {
+			if _, ok := report.Tables[tableName]; !ok {
+				report.Tables[tableName] = &TableReport{}
+			}
+		}
+	})
+}
+
+// restrictTables removes the tables not imported from the report
+func (report *ImportReport) restrictTables(tableNames []string) {
+	tables := make(map[string]*TableReport)
+	for _, tableName := range tableNames {
+		tableName = strings.ToLower(strings.TrimSpace(tableName))
+		if table, ok := report.Tables[tableName]; ok {
+			tables[tableName] = table
+		} else {
+			log.Warn().Msgf("The export has no statements for table %s", tableName)
+		}
+	}
+	report.Tables = tables
+}
+
+// countRows returns the rows of the tables in the report, indexed by table name
+func (report *ImportReport) countRows(db *sql.DB) map[string]int {
+	result := make(map[string]int)
+	for tableName := range report.Tables {
+		rows := sqlUtil.ExecuteQueryWithResults(db, fmt.Sprintf("SELECT count(*) FROM %s;", tableName))
+		count, _ := strconv.Atoi(fmt.Sprintf("%v", rows[0][0].Value))
+		result[tableName] = count
+	}
+	return result
+}
+
+func (report *ImportReport) recordStatement(line string) {
+	if tableName, ok := dumper.StatementTable(line); ok {
+		report.Statements++
+		if table, ok := report.Tables[tableName]; ok {
+			table.Statements++
+		}
+	}
+}
+
+// outputWriter returns the writer for the output of the import command, which prints everything but the command tags
+func (report *ImportReport) outputWriter() io.Writer {
+	pr, pw := io.Pipe()
+	report.output = pw
+	report.outputDone.Add(1)
+	go func() {
+		defer report.outputDone.Done()
+		scanner := bufio.NewScanner(pr)
+		scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			if !report.recordCommandTag(scanner.Text()) {
+				fmt.Fprintln(os.Stdout, scanner.Text())
+			}
+		}
+		io.Copy(os.Stdout, pr)
+	}()
+	return pw
+}
+
+func (report *ImportReport) closeOutput() {
+	if report.output != nil {
+		report.output.Close()
+		report.outputDone.Wait()
+	}
+}
+
+func (report *ImportReport) recordCommandTag(line string) bool {
+	line = strings.TrimSpace(line)
+	if rolledBack := rolledBackTablePattern.FindStringSubmatch(line); rolledBack != nil {
+		log.Error().Msgf("The statements of table %s failed and were rolled back", rolledBack[1])
+		report.RolledBackTables = append(report.RolledBackTables, rolledBack[1])
+		return true
+	}
+	match := commandTagPattern.FindStringSubmatch(line)
+	if match == nil {
+		if line == "COMMIT" && report.commitHook != nil {
+			report.commitHook()
+		}
+		return silentCommandTags[line]
+	}
+	rows, _ := strconv.Atoi(match[2])
+	switch match[1] {
+	case "INSERT 0":
+		if rows == 0 {
+			report.Skipped++
+		}
+		report.Written += rows
+	case "UPDATE":
+		report.Updated += rows
+	case "DELETE":
+		report.Deleted += rows
+	}
+	return true
+}
+
+// reconcile splits the written rows in inserted and updated ones, from the rows counted before and after the import
+func (report *ImportReport) reconcile(rowsBefore map[string]int, rowsAfter map[string]int) {
+	newRows := 0
+	for tableName, table := range report.Tables {
+		table.RowsBefore = rowsBefore[tableName]
+		table.RowsAfter = rowsAfter[tableName]
+		newRows += table.RowsAfter - table.RowsBefore
+	}
+	// deleted rows of cleaned tables are inserted again
+	report.Inserted = newRows + report.Deleted
+	if report.Inserted < 0 {
+		report.Inserted = 0
+	}
+	if report.Written > report.Inserted {
+		report.Updated += report.Written - report.Inserted
+	}
+}
+
+// print logs the summary of the import, with the tables which were changed
+func (report *ImportReport) print() {
+	tableNames := make([]string, 0, len(report.Tables))
+	for tableName := range report.Tables {
+		tableNames = append(tableNames, tableName)
+	}
+	sort.Strings(tableNames)
+	var summary strings.Builder
+	summary.WriteString(fmt.Sprintf("%-40s %12s %12s %12s\n", "Table", "Statements", "Rows before", "Rows after"))
+	for _, tableName := range tableNames {
+		table := report.Tables[tableName]
+		summary.WriteString(fmt.Sprintf("%-40s %12d %12d %12d\n", tableName, table.Statements, table.RowsBefore, table.RowsAfter))
+	}
+	summary.WriteString(fmt.Sprintf("Statements: %d, inserted rows: %d, skipped rows: %d, updated rows: %d, deleted rows: %d",
+		report.Statements, report.Inserted, report.Skipped, report.Updated, report.Deleted))
+	if len(report.RolledBackTables) > 0 {
+		summary.WriteString(fmt.Sprintf("\nRolled back tables: %s", strings.Join(report.RolledBackTables, ", ")))
+	}
+	fmt.Println(summary.String())
+}
+
+// save writes the report as JSON
+func (report *ImportReport) save(reportFile string) {
+	content, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		utils.Panic().Err(err).Msg("error encoding import report")
+	}
+	if err := os.WriteFile(reportFile, content, 0644); err != nil {
+		log.Error().Err(err).Msgf("Error writing import report %s", reportFile)
+		return
+	}
+	log.Info().Msgf("Import report written to %s", reportFile)
+}
@@ -0,0 +1,46 @@
+package syncEngine
+
+import (
+	"bufio"
+	"io"
+	"os"
+
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/dumper"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// importSqlScript runs the SQL file on the target server, rewriting its statements line by line if a rewrite
+// function is given, and records the statements and their results in the report
+func (run *importRun) importSqlScript(sqlFile string, rewrite func(statement string) string, report *ImportReport) error {
+	script := dumper.OpenSqlScript(sqlFile)
+	defer script.Close()
+
+	cImport := run.sqlImportCommand("-")
+	pr, pw := io.Pipe()
+	cImport.Stdin = pr
+	cImport.Stdout = report.outputWriter()
+	cImport.Stderr = os.Stderr
+
+	log.Info().Msg("Starting SQL import")
+	if err := cImport.Start(); err != nil {
+		utils.Fatal().Err(err).Msg("Error running the SQL script")
+	}
+	go func() {
+		defer pw.Close()
+		bufferWriter := bufio.NewWriterSize(pw, 32768)
+		defer bufferWriter.Flush()
+		script.ForEachLine(func(line string) {
+			if rewrite != nil {
+				line = rewrite(line)
+			}
+			report.recordStatement(line)
+			bufferWriter.WriteString(line)
+		})
+	}()
+	err := cImport.Wait()
+	// stops the statements writer when the import command was stopped before reading them all
+	pr.Close()
+	report.closeOutput()
+	return err
+}
@@ -0,0 +1,71 @@
+package syncEngine
+
+import (
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/dumper"
+	"github.com/uyuni-project/inter-server-sync/storage"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// openFileStores opens the stores the package and image files are written to, returning the function closing them
+func (run *importRun) openFileStores() func() {
+	run.packageStore = openFileStore(run.PackageStore)
+	run.imageStore = openFileStore(run.ImageStore)
+	return func() {
+		for _, store := range []storage.Store{run.packageStore, run.imageStore} {
+			if store != nil {
+				if err := store.Close(); err != nil {
+					log.Warn().Err(err).Msgf("Error closing the file store %s", store)
+				}
+			}
+		}
+	}
+}
+
+// openFileStore opens the store of the location, nil when empty
+func openFileStore(location string) storage.Store {
+	if len(location) == 0 {
+		return nil
+	}
+	store, err := storage.Open(location)
+	if err != nil {
+		utils.Fatal().Err(err).Int(utils.ExitCodeField, utils.ExitConfigError).Msg("Error opening the file store")
+	}
+	return store
+}
+
+// queueFolderUploads queues the copies of the files of the folder and its subfolders into the store, under the prefix
+func queueFolderUploads(copier *dumper.FileCopier, store storage.Store, folder string, prefix string) error {
+	return filepath.WalkDir(folder, func(walkedPath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		relativePath, err := filepath.Rel(folder, walkedPath)
+		if err != nil {
+			return err
+		}
+		copier.Copy(dumper.FileCopy{Source: walkedPath, Target: path.Join(prefix, filepath.ToSlash(relativePath)), TargetStore: store})
+		return nil
+	})
+}
+
+// uploadListedFiles copies the files of the folder, listed by their path relative to it, into the store
+func uploadListedFiles(store storage.Store, folder string, files []string, kind string) {
+	copier := dumper.NewFileCopier()
+	for _, file := range files {
+		file = strings.TrimSpace(file)
+		if len(file) == 0 {
+			continue
+		}
+		copier.Copy(dumper.FileCopy{Source: path.Join(folder, file), Target: file, TargetStore: store})
+	}
+	copier.Finish(kind)
+}
@@ -0,0 +1,38 @@
+package syncEngine
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/uyuni-project/inter-server-sync/dumper"
+	"github.com/uyuni-project/inter-server-sync/storage"
+)
+
+func TestQueueFolderUploads(t *testing.T) {
+	importDir := t.TempDir()
+	for _, file := range []string{"packages/1/abc/vim/vim.rpm", "packages/1/def/gcc/gcc.rpm"} {
+		os.MkdirAll(filepath.Join(importDir, filepath.Dir(file)), 0755)
+		if err := os.WriteFile(filepath.Join(importDir, file), []byte(file), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store, err := storage.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	copier := dumper.NewFileCopier()
+	if err := queueFolderUploads(copier, store, filepath.Join(importDir, "packages"), "packages"); err != nil {
+		t.Fatal(err)
+	}
+	copier.Finish("package files")
+
+	files, err := store.List("")
+	sort.Strings(files)
+	if expected := []string{"packages/1/abc/vim/vim.rpm", "packages/1/def/gcc/gcc.rpm"}; err != nil || !reflect.DeepEqual(files, expected) {
+		t.Errorf("Expected the uploaded files %v, got %v %v", expected, files, err)
+	}
+}
@@ -0,0 +1,445 @@
+package syncEngine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/credentials"
+	"github.com/uyuni-project/inter-server-sync/dumper"
+	"github.com/uyuni-project/inter-server-sync/exportArchive"
+	"github.com/uyuni-project/inter-server-sync/placeholders"
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// Verifier compares the rows of an export with the rows of the target server, to check that an import applied
+// before is still intact
+type Verifier interface {
+	// Verify reports which rows of the export selected by the options are on the target server, returning a
+	// *utils.Failure with the verification_failure code, and the report, when rows are missing or differ
+	Verify(options VerifyOptions) (*VerifyReport, error)
+}
+
+// NewVerifier returns a Verifier running one verification, export or import at a time
+func NewVerifier() Verifier {
+	return engine{}
+}
+
+// VerifyOptions selects the export verified on the target server. The rows are compared as the import with the
+// same mappings, renames and placeholders writes them, see ImportOptions.
+type VerifyOptions struct {
+	// export directory
+	ImportDir string
+	// configuration file of the target server
+	ServerConfig     string
+	OrgMapping       []string
+	OrgMappingFile   string
+	GroupMapping     []string
+	GroupMappingFile string
+	ChannelRenames   []string
+	Placeholders     []string
+	// verify only the rows of these tables
+	OnlyTables []string
+	// file the JSON report is written to, if set
+	ReportFile string
+	Context    context.Context
+	Deadline   time.Duration
+	Timeouts   sqlUtil.Timeouts
+}
+
+// VerifyReport counts the rows of the export found on the target server
+type VerifyReport struct {
+	Present   int `json:"present"`
+	Missing   int `json:"missing"`
+	Differing int `json:"differing"`
+	// rows which cannot be looked up on the target, like rows inserted with new ids
+	Unverified int                           `json:"unverified"`
+	Tables     map[string]*TableVerification `json:"tables"`
+}
+
+// TableVerification counts the rows of a table found on the target server
+type TableVerification struct {
+	Present    int `json:"present"`
+	Missing    int `json:"missing"`
+	Differing  int `json:"differing"`
+	Unverified int `json:"unverified"`
+	// differing rows by column
+	DifferingColumns map[string]int `json:"differingColumns,omitempty"`
+}
+
+// columns whose type has no equality operator, compared as text
+var textComparedColumnsSql = `SELECT a.attname FROM pg_attribute a
+	WHERE a.attrelid = $1::regclass AND a.attnum > 0 AND NOT a.attisdropped
+	AND NOT EXISTS (SELECT 1 FROM pg_operator o WHERE o.oprname = '=' AND o.oprleft = a.atttypid AND o.oprright = a.atttypid);`
+
+// Verify looks up every row inserted by the SQL script of the export on the target server, by the unique key the
+// statement inserts it with, and compares the other columns. Nothing is written to the target server.
+func (engine) Verify(options VerifyOptions) (report *VerifyReport, err error) {
+	engineMutex.Lock()
+	defer engineMutex.Unlock()
+	exitCode := utils.ExitError
+	defer recoverFailure(&err, &exitCode)
+	defer startRun(options.Context, options.Deadline, options.Timeouts, sqlUtil.RetryPolicy{})()
+	report = newVerifyReport()
+	verifyExport(options, report)
+	return report, nil
+}
+
+func newVerifyReport() *VerifyReport {
+	return &VerifyReport{Tables: make(map[string]*TableVerification)}
+}
+
+func verifyExport(options VerifyOptions, report *VerifyReport) {
+	absImportDir := utils.GetAbsPath(options.ImportDir)
+	if exportArchive.IsArchive(absImportDir) || exportArchive.IsVolume(absImportDir) {
+		utils.Fatal().Int(utils.ExitCodeField, utils.ExitConfigError).Msg("Archives cannot be verified, verify the export directory they were written from, or extract them first")
+	}
+	log.Info().Msgf("verifying the export in %s", absImportDir)
+	validateFolder(absImportDir)
+	fversion, fproduct := getImportVersionProduct(absImportDir)
+	sversion, sproduct := utils.GetCurrentServerVersion(options.ServerConfig)
+	if fversion != sversion || fproduct != sproduct {
+		utils.Fatal().Int(utils.ExitCodeField, utils.ExitSchemaMismatch).Msgf("Wrong version detected. Fileversion = %s ; Serverversion = %s", fversion, sversion)
+	}
+	orgMapping := loadOrgMapping(absImportDir, options.OrgMapping, options.OrgMappingFile)
+	groupMapping := loadGroupMapping(absImportDir, options.GroupMapping, options.GroupMappingFile)
+	renames, ok := parseChannelRenames(options.ChannelRenames)
+	if !ok {
+		utils.Fatal().Int(utils.ExitCodeField, utils.ExitConfigError).Msg("Unable to parse the channel renames. Allowed format is 'old-label=new-label'")
+	}
+	run := importRun{ImportOptions: ImportOptions{Placeholders: options.Placeholders}}
+	placeholderValues := run.loadPlaceholderValues(absImportDir, options.ServerConfig)
+	imageOrgFolders := loadImageOrgFolders(absImportDir, options.ServerConfig, orgMapping)
+	rewrite := statementRewriter(orgMapping, groupMapping, renames, placeholderValues, placeholders.ReadColumns(absImportDir), imageOrgFolders)
+	if len(options.OnlyTables) > 0 {
+		rewrite = filterStatements(options.OnlyTables, rewrite)
+	}
+
+	db := schemareader.GetReadOnlyDBconnection(options.ServerConfig)
+	defer db.Close()
+	verifier := newExportVerifier(report, func(query string, args ...interface{}) [][]sqlUtil.RowDataStructure {
+		return sqlUtil.ExecuteQueryWithResults(db, query, args...)
+	})
+	verifyStatement := joinStatementLines(func(statement string) string {
+		verifier.verifyStatement(statement)
+		return ""
+	})
+	sqlFile := fmt.Sprintf("%s/sql_statements.sql.gz", absImportDir)
+	if _, err := os.Stat(sqlFile); err != nil {
+		sqlFile = fmt.Sprintf("%s/sql_statements.sql", absImportDir)
+	}
+	script := dumper.OpenSqlScript(sqlFile)
+	defer script.Close()
+	script.ForEachLine(func(line string) {
+		if rewrite != nil {
+			line = rewrite(line)
+		}
+		if len(line) > 0 {
+			verifyStatement(line)
+		}
+	})
+
+	report.print()
+	if len(options.ReportFile) > 0 {
+		report.save(utils.GetAbsPath(options.ReportFile))
+	}
+	if report.Missing > 0 || report.Differing > 0 {
+		utils.Fatal().Int(utils.ExitCodeField, utils.ExitVerificationFailure).
+			Msgf("%d rows of the export are missing on the target server, %d differ", report.Missing, report.Differing)
+	}
+	log.Info().Msg("verification finished, the rows of the export are on the target server")
+}
+
+// exportVerifier looks up the exported rows with the query function
+type exportVerifier struct {
+	report *VerifyReport
+	query  func(query string, args ...interface{}) [][]sqlUtil.RowDataStructure
+	// columns compared as text, indexed by table
+	textColumns map[string]map[string]bool
+}
+
+func newExportVerifier(report *VerifyReport, query func(query string, args ...interface{}) [][]sqlUtil.RowDataStructure) *exportVerifier {
+	return &exportVerifier{report: report, query: query, textColumns: make(map[string]map[string]bool)}
+}
+
+// verifyStatement records if the row inserted by the statement is on the target. Other statements are ignored.
+func (verifier *exportVerifier) verifyStatement(statement string) {
+	statement = strings.TrimSpace(statement)
+	tableName, ok := dumper.StatementTable(statement)
+	if !ok || !strings.HasPrefix(statement, "INSERT INTO ") {
+		return
+	}
+	table, ok := verifier.report.Tables[tableName]
+	if !ok {
+		table = &TableVerification{DifferingColumns: make(map[string]int)}
+		verifier.report.Tables[tableName] = table
+	}
+	row, ok := parseVerifiedRow(statement)
+	if !ok {
+		log.Trace().Msgf("Row of %s cannot be looked up: %s", tableName, statement)
+		table.Unverified++
+		verifier.report.Unverified++
+		return
+	}
+	rows := verifier.query(row.query(verifier.tableTextColumns(row.tableName)))
+	switch {
+	case len(rows) == 0:
+		table.Missing++
+		verifier.report.Missing++
+	case len(fmt.Sprintf("%s", rows[0][0].Value)) == 0:
+		table.Present++
+		verifier.report.Present++
+	default:
+		table.Differing++
+		verifier.report.Differing++
+		for _, column := range strings.Split(fmt.Sprintf("%s", rows[0][0].Value), ",") {
+			table.DifferingColumns[column]++
+		}
+	}
+}
+
+func (verifier *exportVerifier) tableTextColumns(tableName string) map[string]bool {
+	if columns, ok := verifier.textColumns[tableName]; ok {
+		return columns
+	}
+	columns := make(map[string]bool)
+	for _, row := range verifier.query(textComparedColumnsSql, tableName) {
+		columns[fmt.Sprintf("%s", row[0].Value)] = true
+	}
+	verifier.textColumns[tableName] = columns
+	return columns
+}
+
+// verifiedRow is a row inserted by an exported statement, with the condition finding it on the target server
+type verifiedRow struct {
+	tableName string
+	columns   []string
+	values    []string
+	// columns compared with the row on the target
+	compared []bool
+	// condition on the columns of the table selecting the row with the unique key of the statement
+	keyCondition string
+}
+
+// parseVerifiedRow reads the columns, values and unique key of the exported INSERT statement. Rows whose key
+// is generated on import, like new ids, cannot be looked up.
+func parseVerifiedRow(statement string) (verifiedRow, bool) {
+	row := verifiedRow{}
+	parts, complete := splitStatement(statement)
+	if !complete {
+		return row, false
+	}
+	// literals are masked, so the SQL text can be searched without matching their content
+	masked := maskLiterals(parts)
+	columnsStart := strings.Index(masked, " (")
+	columnsEnd := strings.Index(masked, ")")
+	if !strings.HasPrefix(masked, "INSERT INTO ") || columnsStart < 0 || columnsEnd < columnsStart {
+		return row, false
+	}
+	row.tableName = masked[len("INSERT INTO "):columnsStart]
+	for _, column := range strings.Split(masked[columnsStart+len(" ("):columnsEnd], ",") {
+		row.columns = append(row.columns, strings.TrimSpace(column))
+	}
+	rest := strings.TrimLeft(strings.TrimPrefix(masked[columnsEnd+1:], " OVERRIDING SYSTEM VALUE"), " \t")
+	valuesStart := len(masked) - len(rest)
+	var valuesEnd int
+	var ok bool
+	keyColumns := make(map[string]bool)
+	switch {
+	case strings.HasPrefix(rest, "VALUES ("):
+		// INSERT ... VALUES (...) ON CONFLICT (columns) [WHERE predicate] DO ...
+		row.values, valuesEnd, ok = splitValues(statement, masked, valuesStart+len("VALUES ("), ")")
+		if !ok || !strings.HasPrefix(masked[valuesEnd:], ") ON CONFLICT (") {
+			return row, false
+		}
+		keysStart := valuesEnd + len(") ON CONFLICT (")
+		keys, keysEnd, ok := splitValues(statement, masked, keysStart, ")")
+		if !ok {
+			return row, false
+		}
+		conditions := make([]string, 0)
+		for _, key := range keys {
+			key = strings.TrimSpace(key)
+			// expressions of the index, like ((evr).type), are not looked up
+			if index := columnIndex(row.columns, key); index >= 0 {
+				keyColumns[key] = true
+				conditions = append(conditions, fmt.Sprintf("%s IS NOT DISTINCT FROM (%s)", key, strings.TrimSpace(row.values[index])))
+			}
+		}
+		if len(conditions) == 0 {
+			return row, false
+		}
+		predicate := masked[keysEnd+1:]
+		if strings.HasPrefix(predicate, " WHERE ") {
+			predicateEnd := strings.Index(predicate, " DO ")
+			if predicateEnd < 0 {
+				return row, false
+			}
+			conditions = append(conditions, fmt.Sprintf("(%s)", statement[keysEnd+1+len(" WHERE "):keysEnd+1+predicateEnd]))
+		}
+		row.keyCondition = strings.Join(conditions, " AND ")
+	case strings.HasPrefix(rest, "SELECT "):
+		// INSERT ... SELECT ... WHERE NOT EXISTS (SELECT 1 FROM table WHERE condition) [AND EXISTS ...]
+		existsClause := " WHERE NOT EXISTS (SELECT 1 FROM " + row.tableName + " WHERE "
+		row.values, valuesEnd, ok = splitValues(statement, masked, valuesStart+len("SELECT "), existsClause)
+		if !ok {
+			return row, false
+		}
+		condition, _, ok := splitValues(statement, masked, valuesEnd+len(existsClause), ")")
+		if !ok {
+			return row, false
+		}
+		row.keyCondition = strings.TrimSpace(strings.Join(condition, ","))
+	default:
+		return row, false
+	}
+	if len(row.values) != len(row.columns) || strings.Contains(maskLiterals(splitStatementParts(row.keyCondition)), "nextval(") {
+		return row, false
+	}
+	for i, column := range row.columns {
+		row.compared = append(row.compared, !keyColumns[column] && isComparedValue(column, row.values[i]))
+	}
+	return row, true
+}
+
+// splitValues splits the comma separated expressions starting at the position, until the terminator outside of
+// parentheses, and returns the position of the terminator
+func splitValues(statement string, masked string, start int, terminator string) ([]string, int, bool) {
+	values := make([]string, 0)
+	depth := 0
+	valueStart := start
+	for i := start; i < len(masked); i++ {
+		if depth == 0 && strings.HasPrefix(masked[i:], terminator) {
+			return append(values, statement[valueStart:i]), i, true
+		}
+		switch masked[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				values = append(values, statement[valueStart:i])
+				valueStart = i + 1
+			}
+		}
+	}
+	return values, len(masked), false
+}
+
+// isComparedValue checks if the exported value is written as is on import, and can be compared with the target.
+// Generated ids and large objects are created by the import, locked and sealed passwords are replaced by it.
+func isComparedValue(column string, value string) bool {
+	parts := splitStatementParts(strings.TrimSpace(value))
+	text := maskLiterals(parts)
+	if strings.Contains(text, "nextval(") || strings.Contains(text, "lo_from_bytea(") {
+		return false
+	}
+	if len(parts) > 0 && parts[0].literal {
+		content := literalContent(parts[0])
+		if (column == "password" && content == dumper.LockedPassword) || credentials.IsSealed(content) {
+			return false
+		}
+	}
+	return true
+}
+
+// query returns the query finding the row on the target server, returning the comma separated differing
+// columns, empty when the row is the same, and no result when it is missing
+func (row verifiedRow) query(textColumns map[string]bool) string {
+	comparisons := make([]string, 0)
+	for i, column := range row.columns {
+		if !row.compared[i] {
+			continue
+		}
+		current := row.tableName + "." + column
+		value := "(" + strings.TrimSpace(row.values[i]) + ")"
+		if textColumns[column] {
+			current += "::text"
+			value += "::text"
+		}
+		comparisons = append(comparisons, fmt.Sprintf("(%s, %s IS NOT DISTINCT FROM %s)", pq.QuoteLiteral(column), current, value))
+	}
+	differing := "''"
+	if len(comparisons) > 0 {
+		differing = fmt.Sprintf("array_to_string(ARRAY(SELECT compared.name FROM (VALUES %s) AS compared (name, equal) WHERE NOT compared.equal), ',')",
+			strings.Join(comparisons, ", "))
+	}
+	return fmt.Sprintf("SELECT %s FROM %s WHERE %s LIMIT 1;", differing, row.tableName, row.keyCondition)
+}
+
+func columnIndex(columns []string, column string) int {
+	for i, name := range columns {
+		if name == column {
+			return i
+		}
+	}
+	return -1
+}
+
+// splitStatementParts splits a piece of a complete statement into SQL text and literals
+func splitStatementParts(text string) []statementPart {
+	parts, _ := splitStatement(text)
+	return parts
+}
+
+// maskLiterals joins the parts replacing the literals with as many underscores, keeping the positions of the SQL text
+func maskLiterals(parts []statementPart) string {
+	var masked strings.Builder
+	for _, part := range parts {
+		if part.literal {
+			masked.WriteString(strings.Repeat("_", len(part.text)))
+		} else {
+			masked.WriteString(part.text)
+		}
+	}
+	return masked.String()
+}
+
+// print writes the summary of the verification, with the rows of every table
+func (report *VerifyReport) print() {
+	tableNames := make([]string, 0, len(report.Tables))
+	for tableName := range report.Tables {
+		tableNames = append(tableNames, tableName)
+	}
+	sort.Strings(tableNames)
+	var summary strings.Builder
+	summary.WriteString(fmt.Sprintf("%-40s %10s %10s %10s %10s\n", "Table", "Present", "Missing", "Differing", "Unverified"))
+	for _, tableName := range tableNames {
+		table := report.Tables[tableName]
+		summary.WriteString(fmt.Sprintf("%-40s %10d %10d %10d %10d", tableName, table.Present, table.Missing, table.Differing, table.Unverified))
+		if len(table.DifferingColumns) > 0 {
+			columns := make([]string, 0, len(table.DifferingColumns))
+			for column, rows := range table.DifferingColumns {
+				columns = append(columns, fmt.Sprintf("%s (%d)", column, rows))
+			}
+			sort.Strings(columns)
+			summary.WriteString("  differing columns: " + strings.Join(columns, ", "))
+		}
+		summary.WriteString("\n")
+	}
+	summary.WriteString(fmt.Sprintf("Present rows: %d, missing rows: %d, differing rows: %d, unverified rows: %d",
+		report.Present, report.Missing, report.Differing, report.Unverified))
+	fmt.Println(summary.String())
+}
+
+// save writes the report as JSON
+func (report *VerifyReport) save(reportFile string) {
+	content, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		utils.Panic().Err(err).Msg("error encoding verification report")
+	}
+	if err := os.WriteFile(reportFile, content, 0644); err != nil {
+		log.Error().Err(err).Msgf("Error writing verification report %s", reportFile)
+		return
+	}
+	log.Info().Msgf("Verification report written to %s", reportFile)
+}
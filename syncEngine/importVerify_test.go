@@ -0,0 +1,112 @@
+package syncEngine
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+)
+
+func TestVerifiedRowOnConflict(t *testing.T) {
+	statement := "INSERT INTO rhnchannel (id, label, name, org_id)\tVALUES ((SELECT nextval('rhn_channel_id_seq')), 'sles15', 'SLES, 15 (x86_64)', " +
+		"(SELECT id FROM web_customer WHERE name = 'Org' LIMIT 1)) ON CONFLICT (label) DO UPDATE SET name = excluded.name,org_id = excluded.org_id;"
+
+	row, ok := parseVerifiedRow(statement)
+
+	if !ok {
+		t.Fatalf("Expected the row of the statement")
+	}
+	expected := "SELECT array_to_string(ARRAY(SELECT compared.name FROM (VALUES ('name', rhnchannel.name IS NOT DISTINCT FROM ('SLES, 15 (x86_64)')), " +
+		"('org_id', rhnchannel.org_id IS NOT DISTINCT FROM ((SELECT id FROM web_customer WHERE name = 'Org' LIMIT 1)))) AS compared (name, equal) WHERE NOT compared.equal), ',') " +
+		"FROM rhnchannel WHERE label IS NOT DISTINCT FROM ('sles15') LIMIT 1;"
+	if query := row.query(nil); query != expected {
+		t.Errorf("Unexpected query %s", query)
+	}
+}
+
+func TestVerifiedRowPartialIndex(t *testing.T) {
+	statement := "INSERT INTO rhnpackageevr (id, epoch, version, release, evr)\tVALUES ((SELECT nextval('rhn_pkg_evr_seq')), NULL, '1.0', '2', " +
+		"'(,1.0,2,rpm)') ON CONFLICT (version, release, ((evr).type)) WHERE epoch IS NULL DO NOTHING;"
+
+	row, ok := parseVerifiedRow(statement)
+
+	if !ok {
+		t.Fatalf("Expected the row of the statement")
+	}
+	expected := "SELECT array_to_string(ARRAY(SELECT compared.name FROM (VALUES ('epoch', rhnpackageevr.epoch IS NOT DISTINCT FROM (NULL)), " +
+		"('evr', rhnpackageevr.evr::text IS NOT DISTINCT FROM ('(,1.0,2,rpm)')::text)) AS compared (name, equal) WHERE NOT compared.equal), ',') " +
+		"FROM rhnpackageevr WHERE version IS NOT DISTINCT FROM ('1.0') AND release IS NOT DISTINCT FROM ('2') AND (epoch IS NULL) LIMIT 1;"
+	if query := row.query(map[string]bool{"evr": true}); query != expected {
+		t.Errorf("Unexpected query %s", query)
+	}
+}
+
+func TestVerifiedRowNotExists(t *testing.T) {
+	statement := "INSERT INTO rhnchannelpackage (channel_id, package_id, modified)\tSELECT (SELECT id FROM rhnchannel WHERE label = 'sles15' LIMIT 1), " +
+		"(SELECT id FROM rhnpackage WHERE name_id = 5 LIMIT 1), '2022-01-02 03:04:05+00:00'::timestamptz WHERE NOT EXISTS (SELECT 1 FROM rhnchannelpackage " +
+		"WHERE channel_id = (SELECT id FROM rhnchannel WHERE label = 'sles15' LIMIT 1) AND package_id = (SELECT id FROM rhnpackage WHERE name_id = 5 LIMIT 1)) " +
+		"AND EXISTS (SELECT id FROM rhnchannel WHERE label = 'sles15' LIMIT 1);"
+
+	row, ok := parseVerifiedRow(statement)
+
+	if !ok {
+		t.Fatalf("Expected the row of the statement")
+	}
+	if !strings.HasSuffix(row.query(nil), " FROM rhnchannelpackage WHERE channel_id = (SELECT id FROM rhnchannel WHERE label = 'sles15' LIMIT 1) "+
+		"AND package_id = (SELECT id FROM rhnpackage WHERE name_id = 5 LIMIT 1) LIMIT 1;") {
+		t.Errorf("Unexpected query %s", row.query(nil))
+	}
+	if expected := []bool{true, true, true}; !reflect.DeepEqual(row.compared, expected) {
+		t.Errorf("Expected the compared columns %v, got %v", expected, row.compared)
+	}
+}
+
+func TestVerifiedRowNotComparedValues(t *testing.T) {
+	statement := "INSERT INTO susecredentials (id, type, username, password, extra_auth)\tVALUES ((SELECT nextval('suse_credentials_id_seq')), 'registrycreds', " +
+		"'admin', '!', (SELECT lo_from_bytea(0, '\\x00'::bytea))) ON CONFLICT (id) DO UPDATE SET type = excluded.type;"
+
+	row, ok := parseVerifiedRow(statement)
+
+	if ok {
+		t.Errorf("Rows inserted with new ids cannot be looked up, got %+v", row)
+	}
+	if isComparedValue("password", " '!'") || isComparedValue("extra_auth", "(SELECT lo_from_bytea(0, '\\x00'::bytea))") || !isComparedValue("username", "'nextval('") {
+		t.Errorf("Locked passwords and large objects should not be compared, literals should")
+	}
+}
+
+func TestVerifyStatements(t *testing.T) {
+	report := newVerifyReport()
+	queries := make([]string, 0)
+	verifier := newExportVerifier(report, func(query string, args ...interface{}) [][]sqlUtil.RowDataStructure {
+		queries = append(queries, query)
+		switch {
+		case query == textComparedColumnsSql:
+			return nil
+		case strings.Contains(query, "'sles15-missing'"):
+			return nil
+		case strings.Contains(query, "'sles15-renamed'"):
+			return [][]sqlUtil.RowDataStructure{{{Value: "name"}}}
+		}
+		return [][]sqlUtil.RowDataStructure{{{Value: ""}}}
+	})
+
+	for _, label := range []string{"sles15", "sles15-missing", "sles15-renamed"} {
+		verifier.verifyStatement("INSERT INTO rhnchannel (label, name)\tVALUES ('" + label + "', 'SLES') ON CONFLICT (label) DO UPDATE SET name = excluded.name;\n")
+	}
+	verifier.verifyStatement("INSERT INTO rhnchannel (id, label)\tVALUES ((SELECT nextval('rhn_channel_id_seq')), 'new') ON CONFLICT (id) DO NOTHING;\n")
+	verifier.verifyStatement("DELETE FROM rhnchannel WHERE (label) IN (SELECT label FROM rhnchannel);\n")
+
+	expected := TableVerification{Present: 1, Missing: 1, Differing: 1, Unverified: 1, DifferingColumns: map[string]int{"name": 1}}
+	if !reflect.DeepEqual(*report.Tables["rhnchannel"], expected) {
+		t.Errorf("Expected %+v, got %+v", expected, *report.Tables["rhnchannel"])
+	}
+	if report.Present != 1 || report.Missing != 1 || report.Differing != 1 || report.Unverified != 1 {
+		t.Errorf("Unexpected totals %+v", report)
+	}
+	// the text compared columns of the table are read once
+	if len(queries) != 4 {
+		t.Errorf("Expected 4 queries, got %v", queries)
+	}
+}
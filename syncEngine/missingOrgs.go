@@ -0,0 +1,87 @@
+package syncEngine
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/dumper"
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// create_new_org creates the organization as the web UI does: with its user groups, its configuration and the
+// server groups of the base entitlements. Its password argument is not used anymore.
+const createOrgSql = "SELECT create_new_org($1::varchar, $1::varchar);"
+
+// createMissingOrgs creates the organizations the statements of the export reference by name which the target
+// server lacks. Statements are read through the rewrite, so mapped organizations are not created.
+func createMissingOrgs(absImportDir string, targetConfig string, rewrite func(statement string) string) {
+	sqlFile := fmt.Sprintf("%s/sql_statements.sql.gz", absImportDir)
+	if _, err := os.Stat(sqlFile); err != nil {
+		sqlFile = fmt.Sprintf("%s/sql_statements.sql", absImportDir)
+	}
+	names := make(map[string]bool)
+	collect := joinStatementLines(func(statement string) string {
+		for _, name := range referencedOrgNames(statement) {
+			names[name] = true
+		}
+		return ""
+	})
+	script := dumper.OpenSqlScript(sqlFile)
+	defer script.Close()
+	script.ForEachLine(func(line string) {
+		if rewrite != nil {
+			line = rewrite(line)
+		}
+		if len(line) > 0 {
+			collect(line)
+		}
+	})
+
+	db := schemareader.GetDBconnection(targetConfig)
+	defer db.Close()
+	missing := missingOrgNames(names, func(query string, args ...interface{}) [][]sqlUtil.RowDataStructure {
+		return sqlUtil.ExecuteQueryWithResults(db, query, args...)
+	})
+	for _, name := range missing {
+		rows := sqlUtil.ExecuteQueryWithResults(db, createOrgSql, name)
+		if len(rows) == 0 || rows[0][0].Value == nil {
+			utils.Fatal().Int(utils.ExitCodeField, utils.ExitDatabaseError).Msgf("Error creating the organization %s", name)
+		}
+		log.Info().Msgf("Organization %s created with id %v", name, rows[0][0].Value)
+	}
+	if len(missing) == 0 {
+		log.Debug().Msgf("The %d organizations referenced by the export exist on the target server", len(names))
+	}
+}
+
+// referencedOrgNames returns the names of the organizations the statement looks up
+func referencedOrgNames(statement string) []string {
+	if !strings.Contains(statement, "web_customer") {
+		return nil
+	}
+	names := make([]string, 0)
+	parts, _ := splitStatement(statement)
+	for i := 1; i < len(parts); i++ {
+		if parts[i].literal && !parts[i-1].literal && followsSql(parts[i-1].text, orgReferenceClause) {
+			names = append(names, literalContent(parts[i]))
+		}
+	}
+	return names
+}
+
+// missingOrgNames returns the sorted names the query finds no organization for
+func missingOrgNames(names map[string]bool, query func(query string, args ...interface{}) [][]sqlUtil.RowDataStructure) []string {
+	missing := make([]string, 0)
+	for name := range names {
+		if len(query("SELECT id FROM web_customer WHERE name = $1;", name)) == 0 {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
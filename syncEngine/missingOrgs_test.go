@@ -0,0 +1,37 @@
+package syncEngine
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+)
+
+func TestReferencedOrgNames(t *testing.T) {
+	statement := "INSERT INTO rhnchannel (label, name, org_id)\tVALUES ('dev', 'SELECT id FROM web_customer WHERE name = ''Data''', " +
+		"(SELECT id FROM web_customer WHERE name = 'O''Brien Labs' LIMIT 1)) ON CONFLICT (label) DO NOTHING;"
+
+	names := referencedOrgNames(statement)
+
+	if expected := []string{"O'Brien Labs"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected the organizations %v, got %v", expected, names)
+	}
+	if names := referencedOrgNames("INSERT INTO rhnchannel (label, org_id)\tVALUES ('dev', (SELECT 5)) ON CONFLICT (label) DO NOTHING;"); len(names) != 0 {
+		t.Errorf("Mapped organizations are not referenced by name, got %v", names)
+	}
+}
+
+func TestMissingOrgNames(t *testing.T) {
+	names := map[string]bool{"Org": true, "Test Org": true, "Dev Org": true}
+
+	missing := missingOrgNames(names, func(query string, args ...interface{}) [][]sqlUtil.RowDataStructure {
+		if args[0] == "Org" {
+			return [][]sqlUtil.RowDataStructure{{{Value: 1}}}
+		}
+		return nil
+	})
+
+	if expected := []string{"Dev Org", "Test Org"}; !reflect.DeepEqual(missing, expected) {
+		t.Errorf("Expected the missing organizations %v, got %v", expected, missing)
+	}
+}
@@ -0,0 +1,66 @@
+package syncEngine
+
+import (
+	"encoding/json"
+	"path"
+	"time"
+
+	"github.com/uyuni-project/inter-server-sync/dumper"
+	"github.com/uyuni-project/inter-server-sync/notify"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// validateNotifications stops the run when a notification target is unknown, before anything is changed
+func validateNotifications(config notify.Config) {
+	if err := notify.Validate(config.Targets); err != nil {
+		utils.Fatal().Err(err).Int(utils.ExitCodeField, utils.ExitConfigError).Msg("Invalid notification target")
+	}
+}
+
+// notifyOutcome sends the outcome of the run to the notification targets. It must be deferred before
+// recoverFailure, so the failure of the run is known.
+func notifyOutcome(config notify.Config, operation string, started time.Time, err *error, fill func(summary *notify.Summary)) {
+	if len(config.Targets) == 0 {
+		return
+	}
+	summary := notify.Summary{Operation: operation, Succeeded: *err == nil, Started: started, Finished: time.Now()}
+	if *err != nil {
+		failure, ok := (*err).(*utils.Failure)
+		if !ok {
+			failure = utils.NewFailure(utils.ExitError, (*err).Error())
+		}
+		summary.ExitCode = failure.ExitCode
+		summary.ErrorType = failure.ErrorType
+		summary.Message = failure.Message
+		if len(failure.Cause) > 0 {
+			summary.Message += ": " + failure.Cause
+		}
+	}
+	fill(&summary)
+	notify.Send(config, summary)
+}
+
+// exportSummary adds the statements written per table to the summary of a successful export. Failed exports
+// may stop before resetting the statistics of the previous export.
+func exportSummary(summary *notify.Summary) {
+	if !summary.Succeeded {
+		return
+	}
+	summary.Tables = make(map[string]int)
+	for tableName, statistics := range dumper.WriteStatistics() {
+		summary.Tables[tableName] = statistics.Rows
+	}
+}
+
+// summary attaches the import report to the summary, once the SQL script ran
+func (run *importRun) summary(summary *notify.Summary) {
+	if run.report == nil {
+		return
+	}
+	content, err := json.Marshal(run.report)
+	if err != nil {
+		return
+	}
+	summary.Report = content
+	summary.ReportName = path.Base(run.reportFile)
+}
@@ -0,0 +1,97 @@
+package syncEngine
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// organizations are referenced by name in the exported statements, with a sub query followed by the quoted name
+const orgReferenceClause = "SELECT id FROM web_customer WHERE name ="
+
+// parseOrgMapping reads the source_id=target_id entries of the organization mapping, indexed by source id
+func parseOrgMapping(entries []string) (map[string]string, bool) {
+	result := make(map[string]string)
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if len(entry) == 0 || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		separator := strings.Index(entry, "=")
+		if separator < 0 {
+			return nil, false
+		}
+		sourceId := strings.TrimSpace(entry[:separator])
+		targetId := strings.TrimSpace(entry[separator+1:])
+		if _, err := strconv.ParseUint(sourceId, 10, 64); err != nil {
+			return nil, false
+		}
+		if _, err := strconv.ParseUint(targetId, 10, 64); err != nil {
+			return nil, false
+		}
+		result[sourceId] = targetId
+	}
+	return result, true
+}
+
+// loadOrgMapping returns the target organization ids indexed by the quoted name of the source organizations
+func loadOrgMapping(absImportDir string, entries []string, mappingFile string) map[string]string {
+	if len(mappingFile) > 0 {
+		entries = append(entries, utils.ReadFileByLine(utils.GetAbsPath(mappingFile))...)
+	}
+	idMapping, ok := parseOrgMapping(entries)
+	if !ok {
+		utils.Fatal().Msg("Unable to parse the organization mapping. Allowed format is 'source_id=target_id'")
+	}
+	if len(idMapping) == 0 {
+		return nil
+	}
+	orgsFile := path.Join(absImportDir, "exportedOrgs.txt")
+	if _, err := os.Stat(orgsFile); err != nil {
+		utils.Fatal().Err(err).Msg("The export has no organization list, organizations cannot be mapped")
+	}
+	result := make(map[string]string)
+	for _, line := range utils.ReadFileByLine(orgsFile) {
+		separator := strings.Index(line, ",")
+		if separator < 0 {
+			continue
+		}
+		if targetId, ok := idMapping[line[:separator]]; ok {
+			log.Debug().Msgf("Mapping organization %s to %s", line[separator+1:], targetId)
+			result[strings.TrimSpace(pq.QuoteLiteral(line[separator+1:]))] = targetId
+			delete(idMapping, line[:separator])
+		}
+	}
+	for sourceId := range idMapping {
+		log.Warn().Msgf("Organization %s not found in the export", sourceId)
+	}
+	return result
+}
+
+// rewriteOrgReferences replaces the lookups of the mapped organizations with their target ids.
+// Only the SQL text of the statement is rewritten, literals with the same text are data.
+func rewriteOrgReferences(statement string, orgMapping map[string]string) string {
+	if !strings.Contains(statement, "web_customer") {
+		return statement
+	}
+	parts, _ := splitStatement(statement)
+	for i := 1; i < len(parts); i++ {
+		if !parts[i].literal || parts[i-1].literal || !followsSql(parts[i-1].text, orgReferenceClause) {
+			continue
+		}
+		targetId, ok := orgMapping[literalValue(parts[i])]
+		if !ok {
+			continue
+		}
+		reference := strings.LastIndex(parts[i-1].text, "SELECT")
+		parts[i-1].text = fmt.Sprintf("%sSELECT %s", parts[i-1].text[:reference], targetId)
+		parts[i].text = ""
+	}
+	return joinStatement(parts)
+}
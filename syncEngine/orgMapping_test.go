@@ -0,0 +1,59 @@
+package syncEngine
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestRewriteOrgReferences(t *testing.T) {
+	statement := exportChannelStatement(t, "dev", "org", "dev summary")
+
+	result := rewriteOrgReferences(statement, map[string]string{"'org'": "5"})
+
+	if !strings.Contains(result, "'dev',(SELECT 5 LIMIT 1),'dev summary'") {
+		t.Errorf("organization lookup should be replaced by the target id, got %s", result)
+	}
+	if strings.Contains(result, "web_customer") {
+		t.Errorf("no organization lookup should be left, got %s", result)
+	}
+}
+
+func TestRewriteOrgReferencesKeepsLiterals(t *testing.T) {
+	summary := "copied with (SELECT id FROM web_customer WHERE name = 'org' LIMIT 1)"
+	statement := exportChannelStatement(t, "dev", "other", summary)
+
+	result := rewriteOrgReferences(statement, map[string]string{"'org'": "5"})
+
+	if result != statement {
+		t.Errorf("literals should not be rewritten, got %s", result)
+	}
+}
+
+func TestRewriteOrgReferencesEscapedName(t *testing.T) {
+	orgName := `dev\org`
+	statement := exportChannelStatement(t, "dev", orgName, "dev summary")
+
+	result := rewriteOrgReferences(statement, map[string]string{strings.TrimSpace(pq.QuoteLiteral(orgName)): "5"})
+
+	if !strings.Contains(result, "(SELECT 5 LIMIT 1)") {
+		t.Errorf("organization lookup with an escaped name should be replaced, got %s", result)
+	}
+}
+
+func TestStatementRewriterOrgReferencesOnManyLines(t *testing.T) {
+	summary := "first line\nSELECT id FROM web_customer WHERE name = 'org'"
+	statement := exportChannelStatement(t, "dev", "org", summary)
+	rewrite := statementRewriter(map[string]string{"'org'": "5"}, nil, nil, nil, nil, nil)
+
+	result := ""
+	for _, line := range strings.SplitAfter(statement, "\n") {
+		result += rewrite(line)
+	}
+
+	expected := strings.Replace(statement, "(SELECT id FROM web_customer WHERE name = 'org' LIMIT 1)", "(SELECT 5 LIMIT 1)", 1)
+	if result != expected {
+		t.Errorf("expected %s, got %s", expected, result)
+	}
+}
@@ -0,0 +1,37 @@
+package syncEngine
+
+import (
+	"strings"
+
+	"github.com/uyuni-project/inter-server-sync/dumper"
+)
+
+// filterStatements returns a rewrite function keeping only the statements changing the given tables, in the order
+// of the export, which respects the dependencies among them. Lines continuing a statement follow its decision.
+func filterStatements(tableNames []string, rewrite func(statement string) string) func(statement string) string {
+	selected := make(map[string]bool)
+	for _, tableName := range tableNames {
+		selected[strings.ToLower(strings.TrimSpace(tableName))] = true
+	}
+	keep := true
+	return func(line string) string {
+		trimmedLine := strings.TrimSpace(line)
+		if tableName, ok := dumper.StatementTable(line); ok {
+			keep = selected[tableName]
+		} else if tableName, ok := dumper.AnalyzedTable(line); ok {
+			keep = selected[tableName]
+		} else if trimmedLine == "BEGIN;" || trimmedLine == "COMMIT;" {
+			// the selected statements are still applied in a single transaction
+			keep = true
+		} else if strings.HasPrefix(trimmedLine, "--") {
+			keep = false
+		}
+		if !keep {
+			return ""
+		}
+		if rewrite != nil {
+			return rewrite(line)
+		}
+		return line
+	}
+}
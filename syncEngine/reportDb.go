@@ -0,0 +1,186 @@
+package syncEngine
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// reportDbMgmId is the id of the local server in the reporting database, the hub uses the ids of its peripherals
+const reportDbMgmId = 1
+
+// reportDbTable is a table of the reporting database refreshed for the imported channels: the rows of the query
+// on the main database of the target server are written to the columns, replacing the rows with the same key
+type reportDbTable struct {
+	name    string
+	columns []string
+	keys    []string
+	query   string
+}
+
+// rows of the reporting database describing the imported channels, their packages and patches. The queries take
+// the labels of the channels.
+var reportDbTables = []reportDbTable{
+	{name: "channel", columns: []string{"channel_id", "name", "label", "arch", "summary", "description", "parent_channel_label", "organization"},
+		keys: []string{"channel_id"},
+		query: `SELECT c.id, c.name, c.label, ca.label, c.summary, c.description, p.label, o.name FROM rhnchannel c
+		JOIN rhnchannelarch ca ON ca.id = c.channel_arch_id
+		LEFT JOIN rhnchannel p ON p.id = c.parent_channel
+		LEFT JOIN web_customer o ON o.id = c.org_id
+		WHERE c.label = ANY($1) ORDER BY c.id;`},
+	{name: "package", columns: []string{"package_id", "name", "epoch", "version", "release", "arch", "type", "package_size", "payload_size", "installed_size", "vendor", "organization"},
+		keys: []string{"package_id"},
+		query: `SELECT DISTINCT p.id, pn.name, evr.epoch, evr.version, evr.release, pa.label, at.label, p.package_size, p.payload_size, p.installed_size, p.vendor, o.name
+		FROM rhnpackage p
+		JOIN rhnchannelpackage cp ON cp.package_id = p.id
+		JOIN rhnchannel c ON c.id = cp.channel_id
+		JOIN rhnpackagename pn ON pn.id = p.name_id
+		JOIN rhnpackageevr evr ON evr.id = p.evr_id
+		JOIN rhnpackagearch pa ON pa.id = p.package_arch_id
+		JOIN rhnarchtype at ON at.id = pa.arch_type_id
+		LEFT JOIN web_customer o ON o.id = p.org_id
+		WHERE c.label = ANY($1) ORDER BY p.id;`},
+	{name: "errata", columns: []string{"errata_id", "advisory_name", "advisory_type", "issue_date", "update_date", "severity", "synopsis", "organization"},
+		keys: []string{"errata_id"},
+		query: `SELECT DISTINCT e.id, e.advisory_name, e.advisory_type, e.issue_date, e.update_date, s.label, e.synopsis, o.name
+		FROM rhnerrata e
+		JOIN rhnchannelerrata ce ON ce.errata_id = e.id
+		JOIN rhnchannel c ON c.id = ce.channel_id
+		LEFT JOIN rhnerrataseverity s ON s.id = e.severity_id
+		LEFT JOIN web_customer o ON o.id = e.org_id
+		WHERE c.label = ANY($1) ORDER BY e.id;`},
+	{name: "channelpackage", columns: []string{"channel_id", "package_id"},
+		keys: []string{"channel_id", "package_id"},
+		query: `SELECT cp.channel_id, cp.package_id FROM rhnchannelpackage cp
+		JOIN rhnchannel c ON c.id = cp.channel_id
+		WHERE c.label = ANY($1) ORDER BY cp.channel_id, cp.package_id;`},
+	{name: "channelerrata", columns: []string{"channel_id", "errata_id", "channel_label"},
+		keys: []string{"channel_id", "errata_id"},
+		query: `SELECT ce.channel_id, ce.errata_id, c.label FROM rhnchannelerrata ce
+		JOIN rhnchannel c ON c.id = ce.channel_id
+		WHERE c.label = ANY($1) ORDER BY ce.channel_id, ce.errata_id;`},
+}
+
+// tables of the reporting database linking the channels, whose rows removed from the channels are deleted
+var reportDbChannelTables = []string{"channelpackage", "channelerrata"}
+
+// refreshReportDb writes the rows of the reporting database for the imported channels, read from the main database
+// of the target server, and loads them into the reporting database when the server has one. Reports keep the
+// previous rows when the reporting database cannot be loaded, as the import itself succeeded.
+func (run *importRun) refreshReportDb(absImportDir string, targetConfig string) {
+	if !run.ReportDb {
+		return
+	}
+	channelLabels := make([]string, 0)
+	channelsFile := path.Join(absImportDir, "exportedChannels.txt")
+	if _, err := os.Stat(channelsFile); err == nil {
+		for _, channelLabel := range utils.ReadFileByLine(channelsFile) {
+			if channelLabel = strings.TrimSpace(channelLabel); len(channelLabel) > 0 {
+				channelLabels = append(channelLabels, renamedChannelLabel(run.channelRenames, channelLabel))
+			}
+		}
+	}
+	if len(channelLabels) == 0 {
+		log.Info().Msg("No channels imported, the reporting database is not refreshed")
+		return
+	}
+	db := schemareader.GetReadOnlyDBconnection(targetConfig)
+	statements := reportDbStatements(func(query string) [][]sqlUtil.RowDataStructure {
+		return sqlUtil.ExecutePreparedQueryWithResults(db, query, pq.Array(channelLabels))
+	}, channelLabels, time.Now())
+	db.Close()
+
+	statementsFile := run.statePrefix + "reportdbStatements.sql"
+	content := "BEGIN;\n" + strings.Join(statements, "\n") + "\nCOMMIT;\n"
+	if err := os.WriteFile(statementsFile, []byte(content), 0600); err != nil {
+		log.Error().Err(err).Msgf("Error writing the reporting database statements %s", statementsFile)
+	}
+	if !schemareader.HasReportDB(targetConfig) {
+		log.Warn().Msgf("The target server has no reporting database configuration, load %s into its reporting database", statementsFile)
+		return
+	}
+	reportDb := schemareader.GetReportDBconnection(targetConfig)
+	defer reportDb.Close()
+	if err := loadReportDb(reportDb, statements); err != nil {
+		log.Error().Err(err).Msgf("Error loading the reporting database, load %s into it once fixed", statementsFile)
+		return
+	}
+	log.Info().Msgf("Reporting database refreshed for %d channels", len(channelLabels))
+}
+
+// reportDbStatements returns the statements replacing the rows of the reporting database of the channels, with
+// the rows returned by the queries of the tables
+func reportDbStatements(query func(query string) [][]sqlUtil.RowDataStructure, channelLabels []string, synced time.Time) []string {
+	labels := make([]string, 0, len(channelLabels))
+	for _, channelLabel := range channelLabels {
+		labels = append(labels, pq.QuoteLiteral(channelLabel))
+	}
+	statements := make([]string, 0)
+	for _, tableName := range reportDbChannelTables {
+		statements = append(statements, fmt.Sprintf("DELETE FROM %s WHERE mgm_id = %d AND channel_id IN (SELECT channel_id FROM channel WHERE mgm_id = %d AND label IN (%s));",
+			tableName, reportDbMgmId, reportDbMgmId, strings.Join(labels, ", ")))
+	}
+	syncedDate := pq.QuoteLiteral(string(pq.FormatTimestamp(synced))) + "::timestamptz"
+	for _, table := range reportDbTables {
+		keys := make(map[string]bool, len(table.keys))
+		for _, key := range table.keys {
+			keys[key] = true
+		}
+		assignments := make([]string, 0, len(table.columns)+1)
+		for _, column := range table.columns {
+			if !keys[column] {
+				assignments = append(assignments, fmt.Sprintf("%s = excluded.%s", column, column))
+			}
+		}
+		assignments = append(assignments, "synced_date = excluded.synced_date")
+		for _, row := range query(table.query) {
+			values := []string{fmt.Sprintf("%d", reportDbMgmId)}
+			for _, value := range row {
+				values = append(values, formatReportDbValue(value.Value))
+			}
+			values = append(values, syncedDate)
+			statements = append(statements, fmt.Sprintf("INSERT INTO %s (mgm_id, %s, synced_date) VALUES (%s) ON CONFLICT (mgm_id, %s) DO UPDATE SET %s;",
+				table.name, strings.Join(table.columns, ", "), strings.Join(values, ", "), strings.Join(table.keys, ", "), strings.Join(assignments, ", ")))
+		}
+	}
+	return statements
+}
+
+// formatReportDbValue formats a value read from the main database as literal
+func formatReportDbValue(value interface{}) string {
+	switch typedValue := value.(type) {
+	case nil:
+		return "NULL"
+	case int64, float64:
+		return fmt.Sprintf("%v", typedValue)
+	case time.Time:
+		return pq.QuoteLiteral(string(pq.FormatTimestamp(typedValue.UTC()))) + "::timestamptz"
+	case []byte:
+		return pq.QuoteLiteral(string(typedValue))
+	}
+	return pq.QuoteLiteral(fmt.Sprintf("%v", value))
+}
+
+// loadReportDb runs the statements in a single transaction
+func loadReportDb(db *sql.DB, statements []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
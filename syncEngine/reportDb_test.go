@@ -0,0 +1,43 @@
+package syncEngine
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+)
+
+func TestReportDbStatements(t *testing.T) {
+	synced := time.Date(2026, 3, 1, 2, 0, 0, 0, time.UTC)
+	rows := map[string][][]sqlUtil.RowDataStructure{
+		"channel": {{{Value: int64(101)}, {Value: "SLES 15 Updates"}, {Value: "sles15-updates"}, {Value: "channel-x86_64"},
+			{Value: "Updates"}, {Value: nil}, {Value: "sles15-pool"}, {Value: "Example Org"}}},
+		"channelpackage": {{{Value: int64(101)}, {Value: int64(5001)}}},
+	}
+	query := func(query string) [][]sqlUtil.RowDataStructure {
+		for _, table := range reportDbTables {
+			if table.query == query {
+				return rows[table.name]
+			}
+		}
+		t.Fatalf("Unexpected query %s", query)
+		return nil
+	}
+
+	statements := reportDbStatements(query, []string{"sles15-updates"}, synced)
+
+	expected := []string{
+		"DELETE FROM channelpackage WHERE mgm_id = 1 AND channel_id IN (SELECT channel_id FROM channel WHERE mgm_id = 1 AND label IN ('sles15-updates'));",
+		"DELETE FROM channelerrata WHERE mgm_id = 1 AND channel_id IN (SELECT channel_id FROM channel WHERE mgm_id = 1 AND label IN ('sles15-updates'));",
+		"INSERT INTO channel (mgm_id, channel_id, name, label, arch, summary, description, parent_channel_label, organization, synced_date) " +
+			"VALUES (1, 101, 'SLES 15 Updates', 'sles15-updates', 'channel-x86_64', 'Updates', NULL, 'sles15-pool', 'Example Org', '2026-03-01 02:00:00Z'::timestamptz) " +
+			"ON CONFLICT (mgm_id, channel_id) DO UPDATE SET name = excluded.name, label = excluded.label, arch = excluded.arch, summary = excluded.summary, " +
+			"description = excluded.description, parent_channel_label = excluded.parent_channel_label, organization = excluded.organization, synced_date = excluded.synced_date;",
+		"INSERT INTO channelpackage (mgm_id, channel_id, package_id, synced_date) VALUES (1, 101, 5001, '2026-03-01 02:00:00Z'::timestamptz) " +
+			"ON CONFLICT (mgm_id, channel_id, package_id) DO UPDATE SET synced_date = excluded.synced_date;",
+	}
+	if strings.Join(statements, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(statements, "\n"))
+	}
+}
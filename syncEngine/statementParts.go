@@ -0,0 +1,238 @@
+package syncEngine
+
+import (
+	"strings"
+)
+
+// statementPart is a piece of an exported statement: SQL text, or a quoted literal with its E prefix
+type statementPart struct {
+	text    string
+	literal bool
+}
+
+// splitStatement splits the statement into SQL text and literals, so rewrites never change the data of literals.
+// The statement is incomplete when it ends inside a literal, which continues on the next line.
+func splitStatement(statement string) (parts []statementPart, complete bool) {
+	parts = make([]statementPart, 0)
+	start := 0
+	for i := 0; i < len(statement); i++ {
+		if statement[i] == '-' && i+1 < len(statement) && statement[i+1] == '-' {
+			// comments end with the line, and their text is never rewritten
+			if end := strings.IndexByte(statement[i:], '\n'); end >= 0 {
+				i += end
+				continue
+			}
+			break
+		}
+		if statement[i] != '\'' {
+			continue
+		}
+		literalStart := i
+		escapes := isEscapeLiteral(statement, i)
+		if escapes {
+			literalStart = i - 1
+		}
+		end, closed := literalEnd(statement, i+1, escapes)
+		if literalStart > start {
+			parts = append(parts, statementPart{text: statement[start:literalStart]})
+		}
+		parts = append(parts, statementPart{text: statement[literalStart:end], literal: true})
+		if !closed {
+			return parts, false
+		}
+		start = end
+		i = end - 1
+	}
+	if start < len(statement) {
+		parts = append(parts, statementPart{text: statement[start:]})
+	}
+	return parts, true
+}
+
+// joinStatement joins the parts of a statement split by splitStatement
+func joinStatement(parts []statementPart) string {
+	var statement strings.Builder
+	for _, part := range parts {
+		statement.WriteString(part.text)
+	}
+	return statement.String()
+}
+
+// isEscapeLiteral checks if the literal starting with the quote at the position is an E'...' literal,
+// whose backslashes escape the next character
+func isEscapeLiteral(text string, position int) bool {
+	if position == 0 || (text[position-1] != 'E' && text[position-1] != 'e') {
+		return false
+	}
+	return position == 1 || !isIdentifierCharacter(text[position-2])
+}
+
+func isIdentifierCharacter(character byte) bool {
+	return character == '_' || (character >= 'a' && character <= 'z') || (character >= 'A' && character <= 'Z') ||
+		(character >= '0' && character <= '9')
+}
+
+// literalEnd returns the position after the closing quote of the literal whose content starts at the position,
+// or the length of the text when the literal is not closed
+func literalEnd(text string, position int, escapes bool) (int, bool) {
+	for i := position; i < len(text); i++ {
+		switch {
+		case escapes && text[i] == '\\':
+			i++
+		case text[i] == '\'':
+			if i+1 < len(text) && text[i+1] == '\'' {
+				i++
+				continue
+			}
+			return i + 1, true
+		}
+	}
+	return len(text), false
+}
+
+// literalValue returns the SQL text of a literal part without the leading spaces of pq.QuoteLiteral,
+// as it is compared with quoted values
+func literalValue(part statementPart) string {
+	return strings.TrimSpace(part.text)
+}
+
+// followsSql checks if the SQL text ends with the clause, like "WHERE name =", ignoring the spacing
+func followsSql(text string, clause string) bool {
+	return strings.HasSuffix(strings.Join(strings.Fields(text), " "), clause)
+}
+
+// insertedColumnValues returns the table of an INSERT statement and the indexes of the literal parts inserted into
+// its columns. Columns whose value is not a literal, like a sub query, are not part of the result.
+func insertedColumnValues(parts []statementPart) (string, map[string]int) {
+	values := make(map[string]int)
+	if len(parts) == 0 || parts[0].literal || !strings.HasPrefix(parts[0].text, "INSERT INTO ") {
+		return "", values
+	}
+	text := parts[0].text
+	columnsStart := strings.Index(text, " (")
+	columnsEnd := strings.Index(text, ")")
+	if columnsStart < 0 || columnsEnd < columnsStart {
+		return "", values
+	}
+	tableName := text[len("INSERT INTO "):columnsStart]
+	columns := strings.Split(text[columnsStart+len(" ("):columnsEnd], ",")
+	// the values follow the columns, after VALUES ( or SELECT
+	valuesStart := strings.Index(text[columnsEnd:], "VALUES (")
+	if valuesStart >= 0 {
+		valuesStart += columnsEnd + len("VALUES (")
+	} else if valuesStart = strings.Index(text[columnsEnd:], "SELECT "); valuesStart >= 0 {
+		valuesStart += columnsEnd + len("SELECT ")
+	} else {
+		return tableName, values
+	}
+	column := 0
+	depth := 0
+	// a value is a literal when nothing but spaces precedes it
+	valueText := ""
+	for i, part := range parts {
+		if part.literal {
+			if depth == 0 && len(strings.TrimSpace(valueText)) == 0 {
+				values[strings.TrimSpace(columns[column])] = i
+			}
+			valueText += part.text
+			continue
+		}
+		text := part.text
+		if i == 0 {
+			text = text[valuesStart:]
+		}
+		for _, character := range text {
+			switch character {
+			case '(':
+				depth++
+			case ')':
+				depth--
+			case ',':
+				if depth == 0 {
+					column++
+					valueText = ""
+					continue
+				}
+			}
+			if depth < 0 || column >= len(columns) {
+				return tableName, values
+			}
+			valueText += string(character)
+		}
+	}
+	return tableName, values
+}
+
+// comparedColumn returns the column of the comparison the SQL text ends with, like "label =" or
+// "org_id IS NOT DISTINCT FROM"
+func comparedColumn(text string) (string, bool) {
+	words := strings.Fields(text)
+	operand := 0
+	switch {
+	case len(words) >= 2 && words[len(words)-1] == "=":
+		operand = len(words) - 2
+	case len(words) >= 5 && strings.Join(words[len(words)-4:], " ") == "IS NOT DISTINCT FROM":
+		operand = len(words) - 5
+	default:
+		return "", false
+	}
+	column := strings.TrimLeft(words[operand], "(")
+	if dot := strings.LastIndex(column, "."); dot >= 0 {
+		column = column[dot+1:]
+	}
+	return column, len(column) > 0
+}
+
+// joinStatementLines returns the rewrite function receiving whole statements, whose literals can span lines.
+// The lines of a statement are kept until it is complete, and are written together in place of its last line.
+func joinStatementLines(rewrite func(statement string) string) func(line string) string {
+	var pending strings.Builder
+	// the statement kept so far ends inside a literal, with backslash escapes or not
+	pendingEscapes := false
+	return func(line string) string {
+		rest := line
+		if pending.Len() > 0 {
+			pending.WriteString(line)
+			end, closed := literalEnd(line, 0, pendingEscapes)
+			if !closed {
+				return ""
+			}
+			rest = line[end:]
+		}
+		if parts, complete := splitStatement(rest); !complete {
+			if pending.Len() == 0 {
+				pending.WriteString(line)
+			}
+			lastLiteral := literalValue(parts[len(parts)-1])
+			pendingEscapes = lastLiteral[0] == 'E' || lastLiteral[0] == 'e'
+			return ""
+		}
+		if pending.Len() == 0 {
+			return rewrite(line)
+		}
+		statement := pending.String()
+		pending.Reset()
+		return rewrite(statement)
+	}
+}
+
+// literalContent returns the string value of a literal part, without its quotes and escapes
+func literalContent(part statementPart) string {
+	text := literalValue(part)
+	escapes := text[0] == 'E' || text[0] == 'e'
+	if escapes {
+		text = text[1:]
+	}
+	text = strings.ReplaceAll(text[1:len(text)-1], "''", "'")
+	if !escapes {
+		return text
+	}
+	var content strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\\' && i+1 < len(text) {
+			i++
+		}
+		content.WriteByte(text[i])
+	}
+	return content.String()
+}
@@ -0,0 +1,152 @@
+package syncEngine
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/uyuni-project/inter-server-sync/dumper"
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/tests"
+)
+
+// channelSchema is the part of the schema of the channels referring to their organization
+var channelSchema = map[string]schemareader.Table{
+	"web_customer": {
+		Name:                "web_customer",
+		Export:              true,
+		Columns:             []string{"id", "name"},
+		PKColumns:           map[string]bool{"id": true},
+		ColumnIndexes:       map[string]int{"id": 0, "name": 1},
+		MainUniqueIndexName: "web_customer_name_uq",
+		UniqueIndexes:       map[string]schemareader.UniqueIndex{"web_customer_name_uq": {Name: "web_customer_name_uq", Columns: []string{"name"}}},
+		ReferencedBy:        []schemareader.Reference{{TableName: "rhnchannel", ColumnMapping: map[string]string{"org_id": "id"}}},
+	},
+	"rhnchannel": {
+		Name:                "rhnchannel",
+		Export:              true,
+		Columns:             []string{"id", "label", "org_id", "summary"},
+		PKColumns:           map[string]bool{"id": true},
+		PKSequence:          "rhn_channel_id_seq",
+		ColumnIndexes:       map[string]int{"id": 0, "label": 1, "org_id": 2, "summary": 3},
+		MainUniqueIndexName: "rhn_channel_label_uq",
+		UniqueIndexes:       map[string]schemareader.UniqueIndex{"rhn_channel_label_uq": {Name: "rhn_channel_label_uq", Columns: []string{"label"}}},
+		References:          []schemareader.Reference{{TableName: "web_customer", ColumnMapping: map[string]string{"org_id": "id"}}},
+	},
+	"rhndistchannelmap": {
+		Name:                "rhndistchannelmap",
+		Export:              true,
+		Columns:             []string{"id", "release", "channel_id"},
+		PKColumns:           map[string]bool{"id": true},
+		PKSequence:          "rhn_dcm_id_seq",
+		ColumnIndexes:       map[string]int{"id": 0, "release": 1, "channel_id": 2},
+		MainUniqueIndexName: "rhn_dcm_release_cid_uq",
+		UniqueIndexes:       map[string]schemareader.UniqueIndex{"rhn_dcm_release_cid_uq": {Name: "rhn_dcm_release_cid_uq", Columns: []string{"release", "channel_id"}}},
+		References:          []schemareader.Reference{{TableName: "rhnchannel", ColumnMapping: map[string]string{"channel_id": "id"}}},
+	},
+}
+
+// exportStatement returns the statement exported for the table, from the data of previously expected queries
+func exportStatement(t *testing.T, repo *tests.DataRepository, tableName string, data dumper.DataDumper) string {
+	var output bytes.Buffer
+	writer := bufio.NewWriter(&output)
+
+	dumper.PrintTableDataOrdered(repo.DB, writer, channelSchema, channelSchema[tableName], data, dumper.PrintSqlOptions{})
+	writer.Flush()
+
+	start := strings.Index(output.String(), "INSERT INTO "+tableName+" ")
+	if start < 0 {
+		t.Fatalf("No %s statement exported: %s", tableName, output.String())
+	}
+	// literals of the statement can continue on the next lines
+	statement := ""
+	for _, line := range strings.SplitAfter(output.String()[start:], "\n") {
+		statement += line
+		if _, complete := splitStatement(statement); complete {
+			break
+		}
+	}
+	return statement
+}
+
+// exportDistChannelMapStatement returns the statement exported for the release mapped to the channel
+func exportDistChannelMapStatement(t *testing.T, release string, label string) string {
+	repo := tests.CreateDataRepository()
+	repo.ExpectWithRecords("SELECT id, release, channel_id FROM rhndistchannelmap WHERE (id) IN (('7')) ORDER BY release, channel_id;",
+		sqlmock.NewRows([]string{"id", "release", "channel_id"}).AddRow("7", release, "101"))
+	repo.ExpectPrepare("SELECT id, label, org_id, summary FROM rhnchannel WHERE id = $1;")
+	repo.ExpectWithRecords("SELECT id, label, org_id, summary FROM rhnchannel WHERE id = $1;",
+		sqlmock.NewRows([]string{"id", "label", "org_id", "summary"}).AddRow("101", label, nil, "summary"))
+	data := dumper.DataDumper{
+		TableData: map[string]dumper.TableDump{
+			"rhndistchannelmap": {TableName: "rhndistchannelmap", Keys: []dumper.TableKey{{Key: []dumper.RowKey{{Column: "id", Value: "'7'"}}}}},
+		},
+		Paths: map[string]bool{"rhndistchannelmap": true},
+	}
+	return exportStatement(t, repo, "rhndistchannelmap", data)
+}
+
+// exportChannelStatement returns the statement exported for the channel of the organization
+func exportChannelStatement(t *testing.T, label string, orgName string, summary string) string {
+	repo := tests.CreateDataRepository()
+	repo.ExpectWithRecords("SELECT id, name FROM web_customer WHERE (id) IN (('1')) ORDER BY name;",
+		sqlmock.NewRows([]string{"id", "name"}).AddRow("1", orgName))
+	repo.ExpectWithRecords("SELECT id, label, org_id, summary FROM rhnchannel WHERE (id) IN (('101')) ORDER BY label;",
+		sqlmock.NewRows([]string{"id", "label", "org_id", "summary"}).AddRow("101", label, "1", summary))
+	repo.ExpectPrepare("SELECT id, name FROM web_customer WHERE id = $1;")
+	repo.ExpectWithRecords("SELECT id, name FROM web_customer WHERE id = $1;", sqlmock.NewRows([]string{"id", "name"}).AddRow("1", orgName))
+	data := dumper.DataDumper{
+		TableData: map[string]dumper.TableDump{
+			"web_customer": {TableName: "web_customer", Keys: []dumper.TableKey{{Key: []dumper.RowKey{{Column: "id", Value: "'1'"}}}}},
+			"rhnchannel":   {TableName: "rhnchannel", Keys: []dumper.TableKey{{Key: []dumper.RowKey{{Column: "id", Value: "'101'"}}}}},
+		},
+		Paths: map[string]bool{"rhnchannel": true, "rhnchannel,web_customer": true},
+	}
+	return exportStatement(t, repo, "rhnchannel", data)
+}
+
+func TestSplitStatement(t *testing.T) {
+	statement := `INSERT INTO t (a, b, c) VALUES ('it''s', E'back\\'' slash', 'x') ON CONFLICT (a) DO NOTHING;`
+
+	parts, complete := splitStatement(statement)
+
+	literals := make([]string, 0)
+	for _, part := range parts {
+		if part.literal {
+			literals = append(literals, part.text)
+		}
+	}
+	expected := []string{`'it''s'`, `E'back\\'' slash'`, `'x'`}
+	if !complete || strings.Join(literals, "|") != strings.Join(expected, "|") {
+		t.Errorf("expected the literals %v, got %v", expected, literals)
+	}
+	if joinStatement(parts) != statement {
+		t.Errorf("joined statement should not change, got %s", joinStatement(parts))
+	}
+	if _, complete := splitStatement("INSERT INTO t (a) VALUES ('first line\n"); complete {
+		t.Errorf("statement ending in a literal should be incomplete")
+	}
+}
+
+func TestJoinStatementLines(t *testing.T) {
+	statements := make([]string, 0)
+	rewrite := joinStatementLines(func(statement string) string {
+		statements = append(statements, statement)
+		return statement
+	})
+	lines := []string{"INSERT INTO t (a) VALUES (E'one\\\n", "two''\n", "three') ON CONFLICT (a) DO NOTHING;\n", "COMMIT;\n"}
+
+	output := ""
+	for _, line := range lines {
+		output += rewrite(line)
+	}
+
+	if len(statements) != 2 || statements[0] != lines[0]+lines[1]+lines[2] {
+		t.Errorf("expected the statement spanning lines as a whole, got %q", statements)
+	}
+	if output != strings.Join(lines, "") {
+		t.Errorf("all the lines should be written, got %q", output)
+	}
+}
@@ -0,0 +1,115 @@
+package syncEngine
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// keys of the version file identifying the export in the sync history of the target
+const (
+	exportIdKey   = "export_id"
+	exportDateKey = "export_date"
+)
+
+// syncHistoryTable records every export applied on the target server, with its source server and row counts
+const syncHistoryTable = "susesynchistory"
+
+const createSyncHistoryTable = `CREATE TABLE IF NOT EXISTS ` + syncHistoryTable + ` (
+	id BIGSERIAL PRIMARY KEY,
+	source_server VARCHAR(256),
+	source_product VARCHAR(128),
+	source_version VARCHAR(128),
+	export_id VARCHAR(64),
+	exported TIMESTAMPTZ,
+	imported TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+	iss_version VARCHAR(32) NOT NULL,
+	entities JSONB NOT NULL,
+	row_counts JSONB NOT NULL
+);`
+
+const insertSyncHistory = `INSERT INTO ` + syncHistoryTable + ` (source_server, source_product, source_version, export_id, exported, iss_version, entities, row_counts)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8);`
+
+// files of the export listing its entities, by entity name
+var exportedEntityFiles = map[string]string{
+	"channels":    "exportedChannels.txt",
+	"configs":     "exportedConfigs.txt",
+	"autoinstall": "exportedAutoinstall.txt",
+	"orgs":        "exportedOrgs.txt",
+}
+
+// newExportId returns a random identifier of an export, shared by its channel subdirectories
+func newExportId() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// readExportedEntities returns the entities listed by the files of the export, by entity name
+func readExportedEntities(absImportDir string) map[string][]string {
+	entities := make(map[string][]string)
+	for name, fileName := range exportedEntityFiles {
+		path := filepath.Join(absImportDir, fileName)
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		if lines := utils.ReadFileByLine(path); len(lines) > 0 {
+			entities[name] = lines
+		}
+	}
+	return entities
+}
+
+// nullableValue returns nil for empty values, stored as NULL
+func nullableValue(value string) interface{} {
+	if len(value) == 0 {
+		return nil
+	}
+	return value
+}
+
+// recordSyncHistory adds the applied export to the sync history of the target server, creating the table if needed.
+// The history is bookkeeping: failing to record it doesn't fail the import.
+func recordSyncHistory(db *sql.DB, absImportDir string, report *ImportReport) {
+	versionFile := filepath.Join(absImportDir, "version.txt")
+	var readVersionValue = func(key string) string {
+		value, _ := utils.ScannerFunc(versionFile, key)
+		return strings.TrimSpace(value)
+	}
+	var exported interface{}
+	if date := readVersionValue(exportDateKey); len(date) > 0 {
+		if parsed, err := time.Parse(time.RFC3339, date); err == nil {
+			exported = parsed
+		}
+	}
+	entities, err := json.Marshal(readExportedEntities(absImportDir))
+	if err != nil {
+		utils.Panic().Err(err).Msg("error encoding the exported entities")
+	}
+	rowCounts, err := json.Marshal(report)
+	if err != nil {
+		utils.Panic().Err(err).Msg("error encoding the import report")
+	}
+	if _, err := db.Exec(createSyncHistoryTable); err != nil {
+		log.Warn().Err(err).Msgf("Error creating the sync history table %s, the import is not recorded", syncHistoryTable)
+		return
+	}
+	version, product := getImportVersionProduct(absImportDir)
+	_, err = db.Exec(insertSyncHistory, nullableValue(readVersionValue("hub_fqdn")), nullableValue(product),
+		nullableValue(version), nullableValue(readVersionValue(exportIdKey)), exported, Version, string(entities),
+		string(rowCounts))
+	if err != nil {
+		log.Warn().Err(err).Msgf("Error recording the import in the sync history table %s", syncHistoryTable)
+		return
+	}
+	log.Info().Msgf("Import recorded in the sync history table %s", syncHistoryTable)
+}
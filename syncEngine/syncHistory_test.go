@@ -0,0 +1,49 @@
+package syncEngine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/uyuni-project/inter-server-sync/tests"
+)
+
+func TestRecordSyncHistory(t *testing.T) {
+	importDir := t.TempDir()
+	os.WriteFile(filepath.Join(importDir, "version.txt"), []byte("product_name = Uyuni\nversion = 2024.01\n"+
+		"hub_fqdn = hub.example.com\nexport_id = 0123abcd\nexport_date = 2024-01-02T03:04:05Z\n"), 0644)
+	os.WriteFile(filepath.Join(importDir, "exportedChannels.txt"), []byte("sles15-pool\nsles15-updates\n"), 0644)
+	report := newImportReport()
+	report.Statements = 3
+	report.Inserted = 2
+	report.Tables["rhnchannel"] = &TableReport{Statements: 3, RowsBefore: 1, RowsAfter: 3}
+
+	repo := tests.CreateDataRepository()
+	repo.ExpectExec(createSyncHistoryTable)
+	repo.ExpectExec(insertSyncHistory, "hub.example.com", "Uyuni", "2024.01", "0123abcd",
+		time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), Version, `{"channels":["sles15-pool","sles15-updates"]}`,
+		`{"statements":3,"inserted":2,"skipped":0,"updated":0,"deleted":0,"tables":{"rhnchannel":{"statements":3,"rowsBefore":1,"rowsAfter":3}}}`)
+
+	recordSyncHistory(repo.DB, importDir, report)
+
+	if err := repo.ExpectationsWereMet(); err != nil {
+		t.Errorf("Sync history not recorded: %v", err)
+	}
+}
+
+func TestRecordSyncHistoryWithoutExportId(t *testing.T) {
+	importDir := t.TempDir()
+	os.WriteFile(filepath.Join(importDir, "version.txt"), []byte("product_name = Uyuni\nversion = 2024.01\n"), 0644)
+
+	repo := tests.CreateDataRepository()
+	repo.ExpectExec(createSyncHistoryTable)
+	repo.ExpectExec(insertSyncHistory, nil, "Uyuni", "2024.01", nil, nil, Version, "{}", sqlmock.AnyArg())
+
+	recordSyncHistory(repo.DB, importDir, newImportReport())
+
+	if err := repo.ExpectationsWereMet(); err != nil {
+		t.Errorf("Sync history not recorded: %v", err)
+	}
+}
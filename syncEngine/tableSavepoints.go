@@ -0,0 +1,67 @@
+package syncEngine
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/uyuni-project/inter-server-sync/dumper"
+)
+
+// line printed by the import command when the statements of a table were rolled back
+var rolledBackTablePattern = regexp.MustCompile(`^inter-server-sync: rolled back table ([a-z0-9_]+)$`)
+
+// tableSavepoints returns a rewrite function running the statements of each table after a savepoint. When one
+// of them fails, psql rolls back to the savepoint and prints the table, and the import goes on with the next table.
+func tableSavepoints(rewrite func(statement string) string) func(statement string) string {
+	started := false
+	savepoint := 0
+	currentTable := ""
+	var closeBlock = func() string {
+		if len(currentTable) == 0 {
+			return ""
+		}
+		block := fmt.Sprintf("\\if :ERROR\nROLLBACK TO SAVEPOINT iss_table_%d;\n\\echo inter-server-sync: rolled back table %s\n"+
+			"\\else\nRELEASE SAVEPOINT iss_table_%d;\n\\endif\n", savepoint, currentTable, savepoint)
+		currentTable = ""
+		return block
+	}
+	return func(line string) string {
+		if rewrite != nil {
+			line = rewrite(line)
+		}
+		prefix := ""
+		if !started {
+			// failed statements must not stop the script, their table is rolled back
+			prefix = "\\set ON_ERROR_STOP off\n"
+			started = true
+		}
+		if tableName, ok := dumper.StatementTable(line); ok {
+			if tableName != currentTable {
+				prefix += closeBlock()
+				savepoint++
+				currentTable = tableName
+				prefix += fmt.Sprintf("SAVEPOINT iss_table_%d;\n", savepoint)
+			}
+		} else if strings.TrimSpace(line) == "COMMIT;" {
+			prefix += closeBlock()
+		}
+		return prefix + line
+	}
+}
+
+// strictTransaction returns a rewrite function stopping the script at the first failed statement, so the whole
+// import is rolled back
+func strictTransaction(rewrite func(statement string) string) func(statement string) string {
+	started := false
+	return func(line string) string {
+		if rewrite != nil {
+			line = rewrite(line)
+		}
+		if !started {
+			started = true
+			return "\\set ON_ERROR_STOP on\n" + line
+		}
+		return line
+	}
+}
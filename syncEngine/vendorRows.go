@@ -0,0 +1,80 @@
+package syncEngine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/dumper"
+	"github.com/uyuni-project/inter-server-sync/schemareader"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// verifyVendorRows stops the import before it changes the target when the target lacks vendor rows referenced by
+// the export, like the architectures of a product the target server doesn't support
+func verifyVendorRows(absImportDir string, targetConfig string) {
+	vendorRows, err := dumper.ReadVendorRows(absImportDir)
+	if err != nil {
+		utils.Fatal().Err(err).Int(utils.ExitCodeField, utils.ExitVerificationFailure).Msg("Error reading the vendor rows of the export")
+	}
+	if vendorRows == nil {
+		log.Debug().Msg("The export lists no vendor rows, they are not verified")
+		return
+	}
+	db := schemareader.GetReadOnlyDBconnection(targetConfig)
+	defer db.Close()
+	missing := missingVendorRows(vendorRows, func(query string) [][]sqlUtil.RowDataStructure {
+		return sqlUtil.ExecuteQueryWithResults(db, query)
+	})
+	if len(missing) > 0 {
+		utils.Fatal().Int(utils.ExitCodeField, utils.ExitSchemaMismatch).Strs("rows", missing).
+			Msgf("The target server lacks %d vendor rows the export references, like %s", len(missing), missing[0])
+	}
+	log.Info().Msgf("Vendor rows of %d tables verified on the target server", len(vendorRows))
+}
+
+// missingVendorRows returns the vendor rows not returned by the queries of their natural keys, as table(key)
+func missingVendorRows(vendorRows dumper.VendorRows, query func(query string) [][]sqlUtil.RowDataStructure) []string {
+	tableNames := make([]string, 0, len(vendorRows))
+	for tableName := range vendorRows {
+		tableNames = append(tableNames, tableName)
+	}
+	sort.Strings(tableNames)
+	missing := make([]string, 0)
+	for _, tableName := range tableNames {
+		tableRows := vendorRows[tableName]
+		if len(tableRows.Rows) == 0 {
+			continue
+		}
+		keys := make([]string, 0, len(tableRows.Rows))
+		for _, row := range tableRows.Rows {
+			values := make([]string, 0, len(row))
+			for _, value := range row {
+				values = append(values, pq.QuoteLiteral(value))
+			}
+			keys = append(keys, "("+strings.Join(values, ", ")+")")
+		}
+		castColumns := make([]string, 0, len(tableRows.Columns))
+		for _, column := range tableRows.Columns {
+			castColumns = append(castColumns, column+"::text")
+		}
+		found := make(map[string]bool)
+		for _, row := range query(fmt.Sprintf("SELECT %s FROM %s WHERE (%s) IN (%s);", strings.Join(castColumns, ", "),
+			tableName, strings.Join(castColumns, ", "), strings.Join(keys, ", "))) {
+			values := make([]string, 0, len(row))
+			for _, column := range row {
+				values = append(values, fmt.Sprintf("%s", column.Value))
+			}
+			found[strings.Join(values, "\x00")] = true
+		}
+		for _, row := range tableRows.Rows {
+			if !found[strings.Join(row, "\x00")] {
+				missing = append(missing, fmt.Sprintf("%s(%s)", tableName, strings.Join(row, ", ")))
+			}
+		}
+	}
+	return missing
+}
@@ -0,0 +1,33 @@
+package syncEngine
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/uyuni-project/inter-server-sync/dumper"
+	"github.com/uyuni-project/inter-server-sync/sqlUtil"
+)
+
+func TestMissingVendorRows(t *testing.T) {
+	vendorRows := dumper.VendorRows{
+		"rhnpackagearch":  {Columns: []string{"label"}, Rows: [][]string{{"aarch64"}, {"x86_64"}}},
+		"rhnchecksumtype": {Columns: []string{"label"}, Rows: [][]string{{"sha256"}}},
+	}
+	queries := make([]string, 0)
+	query := func(query string) [][]sqlUtil.RowDataStructure {
+		queries = append(queries, query)
+		if query == "SELECT label::text FROM rhnpackagearch WHERE (label::text) IN (('aarch64'), ('x86_64'));" {
+			return [][]sqlUtil.RowDataStructure{{{Value: []byte("x86_64")}}}
+		}
+		return [][]sqlUtil.RowDataStructure{{{Value: "sha256"}}}
+	}
+
+	missing := missingVendorRows(vendorRows, query)
+
+	if expected := []string{"rhnpackagearch(aarch64)"}; !reflect.DeepEqual(missing, expected) {
+		t.Errorf("Expected missing rows %v, but got %v", expected, missing)
+	}
+	if len(queries) != 2 || queries[0] != "SELECT label::text FROM rhnchecksumtype WHERE (label::text) IN (('sha256'));" {
+		t.Errorf("Unexpected queries %v", queries)
+	}
+}
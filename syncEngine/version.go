@@ -0,0 +1,74 @@
+package syncEngine
+
+import (
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+// Version is the version of inter-server-sync, written to the exports
+const Version = "0.2.7"
+
+// MinImporterVersion is the oldest version of inter-server-sync able to import the exports of this version.
+// Raise it when the export format changes in a way older importers cannot handle.
+const MinImporterVersion = "0.2.7"
+
+// keys of the versions in the version file of the exports
+const (
+	toolVersionKey        = "iss_version"
+	minImporterVersionKey = "iss_min_importer_version"
+)
+
+// checkImporterVersion stops the import of exports which need a newer version of inter-server-sync
+func checkImporterVersion(absImportDir string) {
+	versionFile := path.Join(absImportDir, "version.txt")
+	minVersion, err := utils.ScannerFunc(versionFile, minImporterVersionKey)
+	if err != nil {
+		// written before the versions were recorded, the import fails on the statements it cannot handle
+		log.Debug().Msg("The export doesn't record the inter-server-sync version it needs")
+		return
+	}
+	exportVersion, _ := utils.ScannerFunc(versionFile, toolVersionKey)
+	log.Debug().Msgf("Export written by inter-server-sync %s, importable by %s or newer", exportVersion, minVersion)
+	if compareVersions(Version, minVersion) < 0 {
+		utils.Fatal().Int(utils.ExitCodeField, utils.ExitSchemaMismatch).
+			Msgf("The export was written by inter-server-sync %s and needs inter-server-sync %s or newer, this is %s. "+
+				"Please upgrade the inter-server-sync package of this server, or export with inter-server-sync %s on the source server",
+				exportVersion, minVersion, Version, Version)
+	}
+}
+
+// compareVersions compares dotted versions like 0.2.7 by their numeric parts, returning -1, 0 or 1
+func compareVersions(first string, second string) int {
+	firstParts := strings.Split(strings.TrimSpace(first), ".")
+	secondParts := strings.Split(strings.TrimSpace(second), ".")
+	for i := 0; i < len(firstParts) || i < len(secondParts); i++ {
+		firstPart, secondPart := 0, 0
+		if i < len(firstParts) {
+			firstPart = versionNumber(firstParts[i])
+		}
+		if i < len(secondParts) {
+			secondPart = versionNumber(secondParts[i])
+		}
+		if firstPart != secondPart {
+			if firstPart < secondPart {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// versionNumber returns the leading number of a version part, ignoring suffixes like in 7rc1
+func versionNumber(part string) int {
+	end := 0
+	for end < len(part) && part[end] >= '0' && part[end] <= '9' {
+		end++
+	}
+	number, _ := strconv.Atoi(part[:end])
+	return number
+}
@@ -0,0 +1,58 @@
+package syncEngine
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/uyuni-project/inter-server-sync/utils"
+)
+
+func TestCompareVersions(t *testing.T) {
+	cases := []struct {
+		first, second string
+		expected      int
+	}{
+		{"0.2.7", "0.2.7", 0},
+		{"0.2.7", "0.2.10", -1},
+		{"0.3", "0.2.9", 1},
+		{"0.2", "0.2.0", 0},
+		{"1.0.0rc1", "1.0.0", 0},
+	}
+	for _, c := range cases {
+		if result := compareVersions(c.first, c.second); result != c.expected {
+			t.Errorf("Expected %d comparing %s with %s, got %d", c.expected, c.first, c.second, result)
+		}
+	}
+}
+
+func TestCheckImporterVersion(t *testing.T) {
+	useFailureLogger(t)
+	dir := t.TempDir()
+	writeVersion := func(content string) {
+		if err := os.WriteFile(path.Join(dir, "version.txt"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	check := func() (failure *utils.Failure) {
+		defer func() {
+			failure, _ = recover().(*utils.Failure)
+		}()
+		checkImporterVersion(dir)
+		return nil
+	}
+
+	// exports of older versions are imported as before
+	writeVersion("product_name = Uyuni\nversion = 2022.10\n")
+	if failure := check(); failure != nil {
+		t.Errorf("Expected no failure without recorded versions, got %v", failure)
+	}
+	writeVersion("product_name = Uyuni\nversion = 2022.10\niss_version = 0.2.7\niss_min_importer_version = " + MinImporterVersion + "\n")
+	if failure := check(); failure != nil {
+		t.Errorf("Expected no failure for a compatible export, got %v", failure)
+	}
+	writeVersion("product_name = Uyuni\nversion = 2022.10\niss_version = 99.0\niss_min_importer_version = 99.0\n")
+	if failure := check(); failure == nil || failure.ExitCode != utils.ExitSchemaMismatch {
+		t.Errorf("Expected a schema mismatch for an export needing a newer importer, got %v", failure)
+	}
+}
@@ -58,6 +58,12 @@ func (repo *DataRepository) Expect(stm string, columns []string, numRecords int,
 
 }
 
+// ExpectPrepare expects the statement to be prepared. Queries executed on the prepared statement are expected
+// afterwards with Expect or ExpectWithRecords.
+func (repo *DataRepository) ExpectPrepare(stm string) {
+	repo.mock.ExpectPrepare(stm)
+}
+
 // ExpectInfoSchema adds data to repository, which can then be retrieved by the tested function.
 func (repo *DataRepository) ExpectWithRecords(stm string, recs *sqlmock.Rows, args ...driver.Value) {
 
@@ -77,6 +83,15 @@ func (repo *DataRepository) ExpectWithRecords(stm string, recs *sqlmock.Rows, ar
 
 }
 
+// ExpectExec expects the statement to be executed with the arguments, changing no rows
+func (repo *DataRepository) ExpectExec(stm string, args ...driver.Value) {
+	expectation := repo.mock.ExpectExec(stm)
+	if len(args) > 0 {
+		expectation = expectation.WithArgs(args...)
+	}
+	expectation.WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
 // ExpectationsWereMet checks whether all queued expectations
 // were met in order. If any of them was not met - an error is returned.
 func (repo *DataRepository) ExpectationsWereMet() error {
@@ -0,0 +1,306 @@
+// Package tracing records the phases of the exports and imports as OpenTelemetry spans, sent to a collector
+// with the OTLP/HTTP protocol in its JSON encoding.
+package tracing
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// service name of the spans when OTEL_SERVICE_NAME is not set
+const defaultServiceName = "inter-server-sync"
+
+// finished spans sent together
+const maxBatchSpans = 512
+
+// OTLP status code of the failed spans, the status of the other spans is unset
+const statusError = 2
+
+// Span is a phase of the export or import. Spans started while tracing is disabled are nil, and all their
+// methods do nothing.
+type Span struct {
+	name       string
+	spanId     string
+	parentId   string
+	start      time.Time
+	end        time.Time
+	attributes []attribute
+	status     int
+	message    string
+}
+
+type attribute struct {
+	key   string
+	value interface{}
+}
+
+// exporter sends the finished spans of one trace to the collector
+type exporter struct {
+	url         string
+	headers     map[string]string
+	serviceName string
+	traceId     string
+	client      *http.Client
+	finished    []*Span
+	// the spans are dropped after the first failure, so an unavailable collector doesn't slow down the run
+	failed bool
+}
+
+var mutex sync.Mutex
+var current *exporter
+
+// spans started and not ended, the last one is the parent of the next span
+var active []*Span
+
+// Setup starts tracing the phases of a run to the OTLP/HTTP collector at the endpoint, like
+// http://localhost:4318, or to the endpoint of the OTEL_EXPORTER_OTLP_TRACES_ENDPOINT and
+// OTEL_EXPORTER_OTLP_ENDPOINT environment variables when empty. Tracing stays disabled when no endpoint is
+// set. It returns the function sending the remaining spans and disabling tracing.
+func Setup(endpoint string) func() {
+	url := tracesUrl(endpoint)
+	if len(url) == 0 {
+		return func() {}
+	}
+	serviceName := os.Getenv("OTEL_SERVICE_NAME")
+	if len(serviceName) == 0 {
+		serviceName = defaultServiceName
+	}
+	traceExporter := &exporter{url: url, headers: parseHeaders(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS")),
+		serviceName: serviceName, traceId: randomId(16), client: &http.Client{Timeout: 10 * time.Second}}
+	mutex.Lock()
+	current = traceExporter
+	active = nil
+	mutex.Unlock()
+	log.Debug().Msgf("Sending the trace %s to %s", traceExporter.traceId, url)
+	return func() {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if current != nil {
+			current.flush()
+		}
+		current = nil
+		active = nil
+	}
+}
+
+// tracesUrl returns the URL of the traces of the collector, empty when tracing is disabled
+func tracesUrl(endpoint string) string {
+	if len(endpoint) == 0 {
+		// the signal specific endpoint is used as is
+		if url := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"); len(url) > 0 {
+			return url
+		}
+		endpoint = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	}
+	if len(endpoint) == 0 {
+		return ""
+	}
+	return strings.TrimSuffix(endpoint, "/") + "/v1/traces"
+}
+
+// parseHeaders parses the headers of the requests, in the format 'key1=value1,key2=value2'
+func parseHeaders(value string) map[string]string {
+	headers := make(map[string]string)
+	for _, header := range strings.Split(value, ",") {
+		parts := strings.SplitN(header, "=", 2)
+		if len(parts) == 2 && len(strings.TrimSpace(parts[0])) > 0 {
+			headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+	}
+	return headers
+}
+
+func randomId(size int) string {
+	id := make([]byte, size)
+	if _, err := rand.Read(id); err != nil {
+		log.Warn().Err(err).Msg("Error generating a trace id")
+	}
+	return hex.EncodeToString(id)
+}
+
+// Enabled tells if the spans are sent to a collector
+func Enabled() bool {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return current != nil
+}
+
+// StartSpan starts a span, child of the last started span not ended yet
+func StartSpan(name string) *Span {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if current == nil {
+		return nil
+	}
+	span := &Span{name: name, spanId: randomId(8), start: time.Now()}
+	if len(active) > 0 {
+		span.parentId = active[len(active)-1].spanId
+	}
+	active = append(active, span)
+	return span
+}
+
+// RecordSpan starts a span which began at the start time, child of the last started span not ended yet.
+// It is not the parent of the next spans, and records phases known once they are done.
+func RecordSpan(name string, start time.Time) *Span {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if current == nil {
+		return nil
+	}
+	span := &Span{name: name, spanId: randomId(8), start: start}
+	if len(active) > 0 {
+		span.parentId = active[len(active)-1].spanId
+	}
+	return span
+}
+
+// SetAttribute adds an attribute to the span. Values are strings, booleans, integers or floats, other
+// values are formatted as strings.
+func (span *Span) SetAttribute(key string, value interface{}) {
+	if span == nil {
+		return
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	span.attributes = append(span.attributes, attribute{key, value})
+}
+
+// SetError marks the phase failed
+func (span *Span) SetError(err error) {
+	if span == nil || err == nil {
+		return
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	span.status = statusError
+	span.message = err.Error()
+}
+
+// End ends the span, which is sent with the next spans
+func (span *Span) End() {
+	if span == nil {
+		return
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	span.end = time.Now()
+	for i := len(active) - 1; i >= 0; i-- {
+		if active[i] == span {
+			active = append(active[:i], active[i+1:]...)
+			break
+		}
+	}
+	if current == nil || current.failed {
+		return
+	}
+	current.finished = append(current.finished, span)
+	if len(current.finished) >= maxBatchSpans {
+		current.flush()
+	}
+}
+
+// flush sends the finished spans to the collector
+func (exporter *exporter) flush() {
+	if len(exporter.finished) == 0 || exporter.failed {
+		return
+	}
+	content, err := json.Marshal(exporter.request(exporter.finished))
+	exporter.finished = nil
+	if err == nil {
+		err = exporter.send(content)
+	}
+	if err != nil {
+		exporter.failed = true
+		log.Warn().Err(err).Msgf("Error sending the trace to %s, the next spans of the run are dropped", exporter.url)
+	}
+}
+
+func (exporter *exporter) send(content []byte) error {
+	request, err := http.NewRequest(http.MethodPost, exporter.url, bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for key, value := range exporter.headers {
+		request.Header.Set(key, value)
+	}
+	response, err := exporter.client.Do(request)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response status %s", response.Status)
+	}
+	return nil
+}
+
+// request returns the body of the OTLP/HTTP request exporting the spans
+func (exporter *exporter) request(spans []*Span) map[string]interface{} {
+	encoded := make([]map[string]interface{}, 0, len(spans))
+	for _, span := range spans {
+		value := map[string]interface{}{
+			"traceId":           exporter.traceId,
+			"spanId":            span.spanId,
+			"name":              span.name,
+			"kind":              1,
+			"startTimeUnixNano": strconv.FormatInt(span.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(span.end.UnixNano(), 10),
+			"attributes":        encodeAttributes(span.attributes),
+			"status":            map[string]interface{}{"code": span.status, "message": span.message},
+		}
+		if len(span.parentId) > 0 {
+			value["parentSpanId"] = span.parentId
+		}
+		encoded = append(encoded, value)
+	}
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": encodeAttributes([]attribute{{"service.name", exporter.serviceName}}),
+			},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]interface{}{"name": defaultServiceName},
+				"spans": encoded,
+			}},
+		}},
+	}
+}
+
+func encodeAttributes(attributes []attribute) []interface{} {
+	encoded := make([]interface{}, 0, len(attributes))
+	for _, attribute := range attributes {
+		encoded = append(encoded, map[string]interface{}{"key": attribute.key, "value": encodeValue(attribute.value)})
+	}
+	return encoded
+}
+
+// encodeValue returns the OTLP any value, whose 64 bits integers are encoded as strings
+func encodeValue(value interface{}) map[string]interface{} {
+	switch v := value.(type) {
+	case string:
+		return map[string]interface{}{"stringValue": v}
+	case bool:
+		return map[string]interface{}{"boolValue": v}
+	case int:
+		return map[string]interface{}{"intValue": strconv.Itoa(v)}
+	case int64:
+		return map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+	case float64:
+		return map[string]interface{}{"doubleValue": v}
+	default:
+		return map[string]interface{}{"stringValue": fmt.Sprintf("%v", v)}
+	}
+}
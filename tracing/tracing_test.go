@@ -0,0 +1,109 @@
+package tracing
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type otlpRequest struct {
+	ResourceSpans []struct {
+		ScopeSpans []struct {
+			Spans []struct {
+				TraceId      string `json:"traceId"`
+				SpanId       string `json:"spanId"`
+				ParentSpanId string `json:"parentSpanId"`
+				Name         string `json:"name"`
+				Attributes   []struct {
+					Key   string                 `json:"key"`
+					Value map[string]interface{} `json:"value"`
+				} `json:"attributes"`
+				Status struct {
+					Code    int    `json:"code"`
+					Message string `json:"message"`
+				} `json:"status"`
+			} `json:"spans"`
+		} `json:"scopeSpans"`
+	} `json:"resourceSpans"`
+}
+
+func TestSpansSentToCollector(t *testing.T) {
+	requests := make([]otlpRequest, 0)
+	paths := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		body, _ := ioutil.ReadAll(r.Body)
+		var request otlpRequest
+		if err := json.Unmarshal(body, &request); err != nil {
+			t.Errorf("Invalid request %s: %v", body, err)
+		}
+		requests = append(requests, request)
+	}))
+	defer server.Close()
+
+	shutdown := Setup(server.URL)
+	root := StartSpan("export")
+	child := StartSpan("schema read")
+	child.SetAttribute("tables", 3)
+	child.End()
+	root.SetError(errors.New("failed"))
+	root.End()
+	shutdown()
+
+	if len(requests) != 1 || paths[0] != "/v1/traces" {
+		t.Fatalf("Expected one request to /v1/traces, got %v", paths)
+	}
+	spans := requests[0].ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 || spans[0].Name != "schema read" || spans[1].Name != "export" {
+		t.Fatalf("Expected the two spans, got %v", spans)
+	}
+	if spans[0].ParentSpanId != spans[1].SpanId || spans[1].ParentSpanId != "" || spans[0].TraceId != spans[1].TraceId {
+		t.Errorf("Expected the schema read child of the export, got %v", spans)
+	}
+	if len(spans[0].Attributes) != 1 || spans[0].Attributes[0].Key != "tables" || spans[0].Attributes[0].Value["intValue"] != "3" {
+		t.Errorf("Expected the tables attribute, got %v", spans[0].Attributes)
+	}
+	if spans[1].Status.Code != statusError || spans[1].Status.Message != "failed" {
+		t.Errorf("Expected the failed status, got %v", spans[1].Status)
+	}
+	if Enabled() {
+		t.Errorf("Tracing still enabled after shutdown")
+	}
+}
+
+func TestDisabledTracing(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	defer Setup("")()
+	span := StartSpan("export")
+	if span != nil || Enabled() {
+		t.Errorf("Expected no span without endpoint")
+	}
+	span.SetAttribute("tables", 1)
+	span.End()
+}
+
+func TestTracesUrl(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://collector:4318/")
+	if url := tracesUrl(""); url != "http://collector:4318/v1/traces" {
+		t.Errorf("Expected the traces path of the environment endpoint, got %s", url)
+	}
+	if url := tracesUrl("http://other:4318"); url != "http://other:4318/v1/traces" {
+		t.Errorf("Expected the traces path of the endpoint, got %s", url)
+	}
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "http://collector:4318/traces")
+	if url := tracesUrl(""); url != "http://collector:4318/traces" {
+		t.Errorf("Expected the traces endpoint as is, got %s", url)
+	}
+}
+
+func TestParseHeaders(t *testing.T) {
+	headers := parseHeaders("api-key=secret, tenant = a=b,invalid")
+	if len(headers) != 2 || headers["api-key"] != "secret" || headers["tenant"] != "a=b" {
+		t.Errorf("Unexpected headers %v", headers)
+	}
+}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"context"
+	"io"
+
+	"github.com/rs/zerolog/log"
+)
+
+// context of the running export or import, canceled to stop it
+var runContext = context.Background()
+
+// SetContext sets the context of the export or import run afterwards. Queries, commands and file copies
+// stop when it is canceled.
+func SetContext(ctx context.Context) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	runContext = ctx
+}
+
+// Context returns the context of the running export or import
+func Context() context.Context {
+	return runContext
+}
+
+// CheckCanceled stops the running export or import when its context was canceled, panicking with a *Failure
+// instead of logging a fatal event, so the deferred calls release the connections and mark the output incomplete
+func CheckCanceled() {
+	err := runContext.Err()
+	if err == nil {
+		return
+	}
+	message := "stopped before completion"
+	if err == context.DeadlineExceeded {
+		message = "stopped at the deadline before completion"
+	}
+	log.Error().Err(err).Msg(message)
+	failure := NewFailure(ExitCanceled, message)
+	failure.Cause = err.Error()
+	panic(failure)
+}
+
+type cancelableReader struct {
+	reader io.Reader
+}
+
+func (r cancelableReader) Read(p []byte) (int, error) {
+	if err := runContext.Err(); err != nil {
+		return 0, err
+	}
+	return r.reader.Read(p)
+}
+
+// CancelableReader returns a reader failing with the error of the context once the export or import is canceled
+func CancelableReader(reader io.Reader) io.Reader {
+	return cancelableReader{reader}
+}
@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestCheckCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	SetContext(ctx)
+	defer SetContext(nil)
+	CheckCanceled()
+
+	cancel()
+	defer func() {
+		failure, ok := recover().(*Failure)
+		if !ok || failure.ExitCode != ExitCanceled || failure.Cause != context.Canceled.Error() {
+			t.Errorf("Expected a canceled failure, got %v", failure)
+		}
+	}()
+	CheckCanceled()
+	t.Errorf("Canceled run was not stopped")
+}
+
+func TestCancelableReader(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	SetContext(ctx)
+	defer SetContext(nil)
+	content, err := ioutil.ReadAll(CancelableReader(strings.NewReader("rows")))
+	if err != nil || string(content) != "rows" {
+		t.Errorf("Expected the content, got %q: %v", content, err)
+	}
+
+	cancel()
+	if _, err := ioutil.ReadAll(CancelableReader(strings.NewReader("rows"))); err != context.Canceled {
+		t.Errorf("Expected the context error, got %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"fmt"
+	"hash"
+	"os"
+	"strings"
+)
+
+// Digest algorithms of the checksums of the exports
+const (
+	DigestSha256 = "sha256"
+	DigestSha384 = "sha384"
+	DigestSha512 = "sha512"
+	DigestSha1   = "sha1"
+)
+
+type digestAlgorithm struct {
+	new func() hash.Hash
+	// approved by FIPS 140 for integrity protection
+	fipsApproved bool
+}
+
+var digestAlgorithms = map[string]digestAlgorithm{
+	DigestSha256: {sha256.New, true},
+	DigestSha384: {sha512.New384, true},
+	DigestSha512: {sha512.New, true},
+	// for checksums compared with tools which don't support SHA-2
+	DigestSha1: {sha1.New, false},
+}
+
+// file of the kernel telling if the host enforces FIPS mode
+var hostFipsFile = "/proc/sys/crypto/fips_enabled"
+
+// digest of the checksums written, and FIPS mode
+var digest = DigestSha256
+var fipsMode bool
+
+// SetCrypto selects the digest of the checksums written by the exports and imports. In FIPS mode only the FIPS
+// 140 approved digests can be selected or verified, and TLS connections only use approved ciphers.
+func SetCrypto(algorithm string, fips bool) error {
+	algorithm = strings.ToLower(algorithm)
+	if len(algorithm) == 0 {
+		algorithm = DigestSha256
+	}
+	selected, ok := digestAlgorithms[algorithm]
+	if !ok {
+		return fmt.Errorf("unknown digest %s, supported digests are sha256, sha384, sha512 and sha1", algorithm)
+	}
+	if fips && !selected.fipsApproved {
+		return fmt.Errorf("digest %s is not approved in FIPS mode, use sha256, sha384 or sha512", algorithm)
+	}
+	digest = algorithm
+	fipsMode = fips
+	return nil
+}
+
+// FipsMode tells if the TLS and checksum algorithms are restricted to the FIPS 140 approved ones
+func FipsMode() bool {
+	return fipsMode
+}
+
+// HostFipsEnabled tells if the kernel of the host enforces FIPS mode
+func HostFipsEnabled() bool {
+	content, err := os.ReadFile(hostFipsFile)
+	return err == nil && strings.TrimSpace(string(content)) == "1"
+}
+
+// Digest returns the digest of the checksums written
+func Digest() string {
+	return digest
+}
+
+// NewDigest returns a hash computing checksums with the selected digest
+func NewDigest() hash.Hash {
+	return digestAlgorithms[digest].new()
+}
+
+// NewDigestOf returns a hash computing checksums with a digest recorded next to them, SHA-256 when empty as
+// for files written before the digest was recorded
+func NewDigestOf(algorithm string) (hash.Hash, error) {
+	if len(algorithm) == 0 {
+		algorithm = DigestSha256
+	}
+	recorded, ok := digestAlgorithms[algorithm]
+	if !ok {
+		return nil, fmt.Errorf("unknown digest %s", algorithm)
+	}
+	if fipsMode && !recorded.fipsApproved {
+		return nil, fmt.Errorf("digest %s is not approved in FIPS mode", algorithm)
+	}
+	return recorded.new(), nil
+}
+
+// RestrictTLS limits the TLS configuration to the FIPS 140 approved versions, ciphers and curves in FIPS mode
+func RestrictTLS(config *tls.Config) *tls.Config {
+	if !fipsMode {
+		return config
+	}
+	// the TLS 1.3 cipher suites cannot be configured, and include ChaCha20 which is not approved
+	config.MinVersion = tls.VersionTLS12
+	config.MaxVersion = tls.VersionTLS12
+	config.CipherSuites = []uint16{
+		tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	}
+	config.CurvePreferences = []tls.CurveID{tls.CurveP256, tls.CurveP384, tls.CurveP521}
+	return config
+}
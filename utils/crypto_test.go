@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"crypto/tls"
+	"encoding/hex"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestSetCrypto(t *testing.T) {
+	defer SetCrypto(DigestSha256, false)
+	if err := SetCrypto("SHA384", true); err != nil || Digest() != DigestSha384 || !FipsMode() {
+		t.Errorf("Expected SHA-384 in FIPS mode, got %s %v: %v", Digest(), FipsMode(), err)
+	}
+	if err := SetCrypto(DigestSha1, true); err == nil {
+		t.Errorf("SHA-1 accepted in FIPS mode")
+	}
+	if err := SetCrypto("md5", false); err == nil {
+		t.Errorf("Unknown digest accepted")
+	}
+	if err := SetCrypto(DigestSha1, false); err != nil || Digest() != DigestSha1 {
+		t.Errorf("Expected SHA-1 outside of FIPS mode, got %s: %v", Digest(), err)
+	}
+	hash := NewDigest()
+	hash.Write([]byte("rows"))
+	if sum := hex.EncodeToString(hash.Sum(nil)); len(sum) != 40 {
+		t.Errorf("Expected a SHA-1 checksum, got %s", sum)
+	}
+}
+
+func TestNewDigestOf(t *testing.T) {
+	defer SetCrypto(DigestSha256, false)
+	if hash, err := NewDigestOf(""); err != nil || hash.Size() != 32 {
+		t.Errorf("Expected SHA-256 for checksums without digest: %v", err)
+	}
+	SetCrypto(DigestSha512, true)
+	if _, err := NewDigestOf(DigestSha1); err == nil {
+		t.Errorf("SHA-1 checksums verified in FIPS mode")
+	}
+}
+
+func TestRestrictTLS(t *testing.T) {
+	defer SetCrypto(DigestSha256, false)
+	if config := RestrictTLS(&tls.Config{}); config.MinVersion != 0 || config.CipherSuites != nil {
+		t.Errorf("Expected the default configuration outside of FIPS mode")
+	}
+	SetCrypto(DigestSha256, true)
+	config := RestrictTLS(&tls.Config{})
+	if config.MaxVersion != tls.VersionTLS12 || len(config.CipherSuites) == 0 {
+		t.Errorf("Expected TLS 1.2 with approved ciphers in FIPS mode, got %v", config)
+	}
+}
+
+func TestHostFipsEnabled(t *testing.T) {
+	defer func(file string) { hostFipsFile = file }(hostFipsFile)
+	hostFipsFile = path.Join(t.TempDir(), "fips_enabled")
+	if HostFipsEnabled() {
+		t.Errorf("FIPS mode detected without the kernel file")
+	}
+	os.WriteFile(hostFipsFile, []byte("1\n"), 0644)
+	if !HostFipsEnabled() {
+		t.Errorf("FIPS mode of the host not detected")
+	}
+}
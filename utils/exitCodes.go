@@ -0,0 +1,35 @@
+package utils
+
+// ExitCodeField is the log field of fatal and panic events setting the exit code of the process
+const ExitCodeField = "exit_code"
+
+// Exit codes of the failures wrappers can act upon. They are part of the command line interface and must not change
+const (
+	ExitError               = 1
+	ExitConfigError         = 2
+	ExitSchemaMismatch      = 3
+	ExitDatabaseError       = 4
+	ExitPartialExport       = 5
+	ExitVerificationFailure = 6
+	ExitCanceled            = 7
+	ExitPartialImport       = 8
+)
+
+var exitCodeNames = map[int]string{
+	ExitError:               "error",
+	ExitConfigError:         "config_error",
+	ExitSchemaMismatch:      "schema_mismatch",
+	ExitDatabaseError:       "database_error",
+	ExitPartialExport:       "partial_export",
+	ExitVerificationFailure: "verification_failure",
+	ExitCanceled:            "canceled",
+	ExitPartialImport:       "partial_import",
+}
+
+// ExitCodeName returns the name of the failure cause of an exit code
+func ExitCodeName(code int) string {
+	if name, ok := exitCodeNames[code]; ok {
+		return name
+	}
+	return exitCodeNames[ExitError]
+}
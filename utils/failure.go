@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Failure describes the fatal or panic log event which stopped an export or import
+type Failure struct {
+	ExitCode  int                    `json:"exit_code"`
+	ErrorType string                 `json:"error_type"`
+	Message   string                 `json:"message"`
+	Cause     string                 `json:"error,omitempty"`
+	Caller    string                 `json:"caller,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	// stack of the panic events and of the panics not raised by a log event
+	Stack string `json:"-"`
+}
+
+func (failure *Failure) Error() string {
+	if len(failure.Cause) > 0 {
+		return fmt.Sprintf("%s: %s", failure.Message, failure.Cause)
+	}
+	return failure.Message
+}
+
+// NewFailure returns a failure with the name of the exit code as error type
+func NewFailure(exitCode int, message string) *Failure {
+	return &Failure{ExitCode: exitCode, ErrorType: ExitCodeName(exitCode), Message: message}
+}
+
+// ParseFailure reads the failure from a JSON log event, with the default exit code if the event doesn't set one
+func ParseFailure(event []byte, defaultExitCode int) *Failure {
+	fields := make(map[string]interface{})
+	json.Unmarshal(event, &fields)
+	exitCode := defaultExitCode
+	if code, ok := fields[ExitCodeField].(float64); ok {
+		exitCode = int(code)
+	}
+	failure := NewFailure(exitCode, "")
+	failure.Message, _ = fields[zerolog.MessageFieldName].(string)
+	failure.Cause, _ = fields[zerolog.ErrorFieldName].(string)
+	failure.Caller, _ = fields[zerolog.CallerFieldName].(string)
+	for _, name := range []string{ExitCodeField, zerolog.MessageFieldName, zerolog.ErrorFieldName,
+		zerolog.CallerFieldName, zerolog.LevelFieldName, zerolog.TimestampFieldName} {
+		delete(fields, name)
+	}
+	if len(fields) > 0 {
+		failure.Details = fields
+	}
+	return failure
+}
+
+// FailureWriter turns fatal and panic log events into panics with the *Failure they describe. Fatal and Panic
+// events stop the run whatever the logger, the writer only stops them before the next writers of the logger.
+type FailureWriter struct{}
+
+func (w FailureWriter) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func (w FailureWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	if level == zerolog.FatalLevel || level == zerolog.PanicLevel {
+		panic(newEventFailure(level, p))
+	}
+	return len(p), nil
+}
+
+func newEventFailure(level zerolog.Level, event []byte) *Failure {
+	failure := ParseFailure(event, ExitError)
+	if level == zerolog.PanicLevel {
+		failure.Stack = string(debug.Stack())
+	}
+	return failure
+}
+
+// Fatal starts a fatal log event, which stops the running export or import. Unlike log.Fatal, the event doesn't
+// exit the process by itself: it is logged with the global logger, whose writers may exit, and then panics with
+// the *Failure it describes. Failures of canceled runs stop them as canceled, since they are likely caused by
+// the cancellation.
+func Fatal() *zerolog.Event {
+	CheckCanceled()
+	return failureLogger.WithLevel(zerolog.FatalLevel)
+}
+
+// Panic starts a panic log event, which stops the running export or import the same way as Fatal
+func Panic() *zerolog.Event {
+	CheckCanceled()
+	return failureLogger.WithLevel(zerolog.PanicLevel)
+}
+
+// failureLogger writes the fatal and panic events to the failureStopper, with the caller of the event
+var failureLogger = zerolog.New(failureStopper{}).With().Caller().Logger()
+
+// frames between the caller of a failure event and the forwarded event: Msg, the event write, the stopper and
+// forwardFailure
+const forwardedFrames = 5
+
+var forwardMutex sync.Mutex
+
+// set while a failure event is forwarded to the global logger, whose FailureHook must let it through
+var forwarding int32
+
+// failureStopper logs the failure events with the global logger, then panics with the *Failure they describe,
+// so the run stops even when no writer of the logger did
+type failureStopper struct{}
+
+func (w failureStopper) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func (w failureStopper) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	failure := newEventFailure(level, p)
+	forwardFailure(level, p)
+	panic(failure)
+}
+
+// forwardFailure logs the event with the global logger, whose context and writers apply to it
+func forwardFailure(level zerolog.Level, event []byte) {
+	fields := make(map[string]interface{})
+	json.Unmarshal(event, &fields)
+	message, _ := fields[zerolog.MessageFieldName].(string)
+	for _, name := range []string{zerolog.MessageFieldName, zerolog.LevelFieldName, zerolog.CallerFieldName} {
+		delete(fields, name)
+	}
+	// the caller of the global logger is the one of the failure event, a few frames up
+	forwardMutex.Lock()
+	defer forwardMutex.Unlock()
+	skipFrameCount := zerolog.CallerSkipFrameCount
+	zerolog.CallerSkipFrameCount += forwardedFrames
+	atomic.StoreInt32(&forwarding, 1)
+	defer func() {
+		zerolog.CallerSkipFrameCount = skipFrameCount
+		atomic.StoreInt32(&forwarding, 0)
+	}()
+	log.WithLevel(level).Fields(fields).Msg(message)
+}
+
+// FailureHook stops the run at the fatal and panic events logged with the global logger itself, like log.Fatal,
+// before they exit the process. They are stopped as the events of Fatal and Panic, with their message only.
+type FailureHook struct{}
+
+func (h FailureHook) Run(e *zerolog.Event, level zerolog.Level, message string) {
+	if (level != zerolog.FatalLevel && level != zerolog.PanicLevel) || atomic.LoadInt32(&forwarding) == 1 {
+		return
+	}
+	e.Discard()
+	failureLogger.WithLevel(level).Msg(message)
+}
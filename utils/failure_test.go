@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+func TestFatalStopsWithAnyLogger(t *testing.T) {
+	logger := log.Logger
+	defer func() { log.Logger = logger }()
+	var output bytes.Buffer
+	log.Logger = zerolog.New(&output).With().Str("run", "test").Caller().Logger()
+
+	defer func() {
+		failure, ok := recover().(*Failure)
+		if !ok || failure.ExitCode != ExitConfigError || failure.Message != "invalid option" || failure.Cause != "cause" {
+			t.Fatalf("Expected the configuration failure, got %v", failure)
+		}
+		if !strings.Contains(failure.Caller, "failure_test.go") {
+			t.Errorf("Expected the caller of the event, got %s", failure.Caller)
+		}
+		fields := make(map[string]interface{})
+		if err := json.Unmarshal(output.Bytes(), &fields); err != nil {
+			t.Fatalf("Expected the event logged once, got %s", output.String())
+		}
+		if fields["level"] != "fatal" || fields["message"] != "invalid option" || fields["run"] != "test" ||
+			fields[ExitCodeField] != float64(ExitConfigError) {
+			t.Errorf("Expected the event with the logger context, got %s", output.String())
+		}
+		if caller, _ := fields["caller"].(string); !strings.Contains(caller, "failure_test.go") {
+			t.Errorf("Expected the caller of the event in the log, got %s", output.String())
+		}
+	}()
+	Fatal().Err(errors.New("cause")).Int(ExitCodeField, ExitConfigError).Msg("invalid option")
+	t.Errorf("Fatal event didn't stop")
+}
+
+func TestPanicWithFailureWriter(t *testing.T) {
+	logger := log.Logger
+	defer func() { log.Logger = logger }()
+	log.Logger = zerolog.New(zerolog.MultiLevelWriter(&bytes.Buffer{}, FailureWriter{}))
+
+	defer func() {
+		failure, ok := recover().(*Failure)
+		if !ok || failure.ExitCode != ExitError || failure.Message != "broken" || len(failure.Stack) == 0 {
+			t.Errorf("Expected the failure with its stack, got %v", failure)
+		}
+	}()
+	Panic().Msgf("%s", "broken")
+	t.Errorf("Panic event didn't stop")
+}
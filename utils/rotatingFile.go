@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// RotatingFile is a log file which is renamed to a numbered backup once it reaches the maximum size,
+// keeping the given number of backups
+type RotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+	mutex      sync.Mutex
+}
+
+// OpenRotatingFile opens the log file for appending, rotating it when it grows above maxSize bytes
+func OpenRotatingFile(path string, maxSize int64, maxBackups int) (*RotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	rotatingFile := &RotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := rotatingFile.open(); err != nil {
+		return nil, err
+	}
+	return rotatingFile, nil
+}
+
+func (rotatingFile *RotatingFile) open() error {
+	file, err := os.OpenFile(rotatingFile.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0640)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	rotatingFile.file = file
+	rotatingFile.size = info.Size()
+	return nil
+}
+
+func backupPath(path string, backup int) string {
+	return fmt.Sprintf("%s.%d", path, backup)
+}
+
+// rotate renames the file to the first backup, shifting the older backups and removing the oldest one
+func (rotatingFile *RotatingFile) rotate() error {
+	if err := rotatingFile.file.Close(); err != nil {
+		return err
+	}
+	if rotatingFile.maxBackups > 0 {
+		os.Remove(backupPath(rotatingFile.path, rotatingFile.maxBackups))
+		for backup := rotatingFile.maxBackups - 1; backup > 0; backup-- {
+			os.Rename(backupPath(rotatingFile.path, backup), backupPath(rotatingFile.path, backup+1))
+		}
+		if err := os.Rename(rotatingFile.path, backupPath(rotatingFile.path, 1)); err != nil {
+			return err
+		}
+	} else if err := os.Remove(rotatingFile.path); err != nil {
+		return err
+	}
+	return rotatingFile.open()
+}
+
+func (rotatingFile *RotatingFile) Write(data []byte) (int, error) {
+	rotatingFile.mutex.Lock()
+	defer rotatingFile.mutex.Unlock()
+	if rotatingFile.maxSize > 0 && rotatingFile.size > 0 && rotatingFile.size+int64(len(data)) > rotatingFile.maxSize {
+		if err := rotatingFile.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := rotatingFile.file.Write(data)
+	rotatingFile.size += int64(n)
+	return n, err
+}
+
+func (rotatingFile *RotatingFile) Close() error {
+	rotatingFile.mutex.Lock()
+	defer rotatingFile.mutex.Unlock()
+	return rotatingFile.file.Close()
+}
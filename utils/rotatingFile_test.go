@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "iss.log")
+	file, err := OpenRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := file.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	file.Close()
+
+	expected := map[string]string{path: "fourth\n", path + ".1": "third\n", path + ".2": "second\n"}
+	for expectedPath, expectedContent := range expected {
+		if content, err := os.ReadFile(expectedPath); err != nil || string(content) != expectedContent {
+			t.Errorf("Expected %q in %s, got %q: %v", expectedContent, expectedPath, content, err)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("Backups above the retention were kept")
+	}
+}
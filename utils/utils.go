@@ -47,7 +47,7 @@ func GetAbsPath(path string) string {
 	} else {
 		homedir, err := os.UserHomeDir()
 		if err != nil {
-			log.Fatal().Msg("Couldn't determine the home directory")
+			Fatal().Msg("Couldn't determine the home directory")
 		}
 		if strings.HasPrefix(path, "~") {
 			result = strings.Replace(path, "~", homedir, -1)
@@ -73,8 +73,22 @@ func FolderExists(path string) error {
 }
 
 func GetCurrentServerVersion(serverConfig string) (string, string) {
-	files := []string{serverConfig}
-	files = append(files, getDefaultConfigs()...)
+	version, product, err := ReadCurrentServerVersion(serverConfig)
+	if err != nil {
+		Fatal().Msg(err.Error())
+	}
+	return version, product
+}
+
+// ReadCurrentServerVersion returns the version and product of the server, or an error if they cannot be detected
+func ReadCurrentServerVersion(serverConfig string) (string, string, error) {
+	files := make([]string, 0)
+	for _, file := range append([]string{serverConfig}, getDefaultConfigs()...) {
+		// missing files would make the property lookups fail
+		if _, err := os.Stat(file); err == nil {
+			files = append(files, file)
+		}
+	}
 	property := []string{"product_name", "web.product_name"}
 	product := "SUSE Manager"
 	p, err := getProperty(files, property)
@@ -89,9 +103,9 @@ func GetCurrentServerVersion(serverConfig string) (string, string) {
 	}
 	version, err := getProperty(files, propertyVersion)
 	if err != nil {
-		log.Fatal().Msgf("No version found for product %s", product)
+		return "", "", fmt.Errorf("No version found for product %s", product)
 	}
-	return version, product
+	return version, product, nil
 }
 
 func GetCurrentServerFQDN(serverConfig string) string {
@@ -106,6 +120,17 @@ func GetCurrentServerFQDN(serverConfig string) string {
 	return p
 }
 
+// GetCurrentServerMountPoint returns the folder where the server stores packages and other files
+func GetCurrentServerMountPoint(serverConfig string) string {
+	files := []string{serverConfig}
+	files = append(files, getDefaultConfigs()...)
+	p, err := getProperty(files, []string{"mount_point"})
+	if err != nil {
+		return "/var/spacewalk"
+	}
+	return p
+}
+
 func getProperty(filePaths []string, names []string) (string, error) {
 	for _, path := range filePaths {
 		for _, search := range names {
@@ -122,7 +147,7 @@ func ScannerFunc(path string, search string) (string, error) {
 	var output string
 	f, err := os.Open(path)
 	if err != nil {
-		log.Fatal().Msgf("Couldn't open file: %s", path)
+		Fatal().Msgf("Couldn't open file: %s", path)
 	}
 	defer f.Close()
 	scanner := bufio.NewScanner(f)
@@ -191,6 +216,6 @@ func ExecInteractivePrompt(name string, input string) error {
 
 func checkError(err error, msg string) {
 	if err != nil {
-		log.Fatal().Err(err).Msg(msg)
+		Fatal().Err(err).Msg(msg)
 	}
 }
@@ -0,0 +1,416 @@
+package wsTransport
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// key suffix of the handshake defined by RFC 6455
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	opText   = 0x1
+	opBinary = 0x2
+	opClose  = 0x8
+	opPing   = 0x9
+	opPong   = 0xa
+)
+
+// CloseNormal and CloseError are the close codes of a completed and of a failed stream
+const (
+	CloseNormal = 1000
+	CloseError  = 1011
+)
+
+// payload size of the frames written by the connection
+const maxFramePayload = 64 * 1024
+
+// time waited for the peer to answer the close frame
+const closeTimeout = 10 * time.Second
+
+// maximum size of the text messages read in one piece
+const maxMessageSize = 1024 * 1024
+
+// Conn is a WebSocket connection streaming data in binary frames. Reading returns the payload of all data frames
+// until the peer closes the connection, answering pings on the way.
+type Conn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	// frames written by the client are masked
+	client     bool
+	writeMutex sync.Mutex
+	// state of the frame being read
+	final     bool
+	remaining int64
+	masked    bool
+	maskKey   [4]byte
+	maskPos   int
+	readErr   error
+}
+
+// closeError is returned by reads when the peer closed the connection with an error
+type closeError struct {
+	code   int
+	reason string
+}
+
+func (err *closeError) Error() string {
+	return fmt.Sprintf("connection closed by peer with code %d: %s", err.code, err.reason)
+}
+
+// acceptKey returns the handshake answer of RFC 6455, which mandates SHA-1. It is no security function, the
+// connections are protected by TLS, so it is also used in FIPS mode.
+func acceptKey(key string) string {
+	hash := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+func headerContains(header http.Header, name string, value string) bool {
+	for _, field := range header.Values(name) {
+		for _, token := range strings.Split(field, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Upgrade answers the handshake of a WebSocket client and takes over the connection of the request
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != http.MethodGet || !headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") || len(key) == 0 {
+		http.Error(w, "WebSocket connection expected", http.StatusBadRequest)
+		return nil, errors.New("not a WebSocket handshake")
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "WebSocket not supported", http.StatusInternalServerError)
+		return nil, errors.New("connection cannot be hijacked")
+	}
+	conn, buffer, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	response := "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n"
+	if _, err := conn.Write([]byte(response)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &Conn{conn: conn, reader: buffer.Reader}, nil
+}
+
+// Dial opens a WebSocket connection to a ws:// or wss:// URL, sending the given headers with the handshake
+func Dial(rawURL string, header http.Header, tlsConfig *tls.Config) (*Conn, error) {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	host := target.Host
+	var conn net.Conn
+	switch target.Scheme {
+	case "ws":
+		if len(target.Port()) == 0 {
+			host += ":80"
+		}
+		conn, err = net.Dial("tcp", host)
+	case "wss":
+		if len(target.Port()) == 0 {
+			host += ":443"
+		}
+		conn, err = tls.Dial("tcp", host, tlsConfig)
+	default:
+		return nil, fmt.Errorf("unsupported URL scheme %s", target.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+	request := &http.Request{Method: http.MethodGet, URL: target, Host: target.Host, Header: http.Header{},
+		Proto: "HTTP/1.1", ProtoMajor: 1, ProtoMinor: 1}
+	for name, values := range header {
+		request.Header[name] = values
+	}
+	request.Header.Set("Upgrade", "websocket")
+	request.Header.Set("Connection", "Upgrade")
+	request.Header.Set("Sec-WebSocket-Key", key)
+	request.Header.Set("Sec-WebSocket-Version", "13")
+	if err := request.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	reader := bufio.NewReaderSize(conn, 32768)
+	response, err := http.ReadResponse(reader, request)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		conn.Close()
+		return nil, fmt.Errorf("WebSocket handshake refused: %s", response.Status)
+	}
+	if response.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		conn.Close()
+		return nil, errors.New("invalid WebSocket handshake response")
+	}
+	return &Conn{conn: conn, reader: reader, client: true}, nil
+}
+
+func (c *Conn) writeFrame(opcode byte, payload []byte) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	header := make([]byte, 2, 14)
+	header[0] = 0x80 | opcode
+	switch {
+	case len(payload) < 126:
+		header[1] = byte(len(payload))
+	case len(payload) <= 0xffff:
+		header[1] = 126
+		header = append(header, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(len(payload)))
+	default:
+		header[1] = 127
+		header = append(header, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(len(payload)))
+	}
+	if c.client {
+		header[1] |= 0x80
+		var maskKey [4]byte
+		if _, err := rand.Read(maskKey[:]); err != nil {
+			return err
+		}
+		header = append(header, maskKey[:]...)
+		masked := make([]byte, len(payload))
+		for i := range payload {
+			masked[i] = payload[i] ^ maskKey[i%4]
+		}
+		payload = masked
+	}
+	if _, err := c.conn.Write(header); err != nil {
+		return err
+	}
+	_, err := c.conn.Write(payload)
+	return err
+}
+
+// Write sends the data in binary frames
+func (c *Conn) Write(data []byte) (int, error) {
+	written := 0
+	for written < len(data) {
+		end := written + maxFramePayload
+		if end > len(data) {
+			end = len(data)
+		}
+		if err := c.writeFrame(opBinary, data[written:end]); err != nil {
+			return written, err
+		}
+		written = end
+	}
+	return written, nil
+}
+
+// WriteText sends the text in a single frame
+func (c *Conn) WriteText(text []byte) error {
+	return c.writeFrame(opText, text)
+}
+
+// KeepAlive pings the peer at the interval until the returned function is called, so proxies don't drop
+// the connection while no data is sent
+func (c *Conn) KeepAlive(interval time.Duration) func() {
+	done := make(chan bool)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := c.writeFrame(opPing, nil); err != nil {
+					return
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// Close sends the close code and reason to the peer and closes the connection once the peer answered,
+// so the data sent before is not lost by a reset of the connection
+func (c *Conn) Close(code int, reason string) error {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, reason...)
+	err := c.writeFrame(opClose, payload)
+	if err == nil && c.readErr == nil {
+		c.conn.SetReadDeadline(time.Now().Add(closeTimeout))
+		for {
+			if _, err := io.CopyN(io.Discard, c.reader, c.remaining); err != nil {
+				break
+			}
+			opcode, err := c.nextFrame()
+			if err != nil || opcode == opClose {
+				break
+			}
+		}
+	}
+	c.conn.Close()
+	return err
+}
+
+// nextFrame reads the header of the next frame, leaving its payload to be read
+func (c *Conn) nextFrame() (byte, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(c.reader, header); err != nil {
+		return 0, err
+	}
+	c.final = header[0]&0x80 != 0
+	opcode := header[0] & 0x0f
+	length := int64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		extended := make([]byte, 2)
+		if _, err := io.ReadFull(c.reader, extended); err != nil {
+			return 0, err
+		}
+		length = int64(binary.BigEndian.Uint16(extended))
+	case 127:
+		extended := make([]byte, 8)
+		if _, err := io.ReadFull(c.reader, extended); err != nil {
+			return 0, err
+		}
+		length = int64(binary.BigEndian.Uint64(extended))
+	}
+	c.masked = header[1]&0x80 != 0
+	if c.masked {
+		if _, err := io.ReadFull(c.reader, c.maskKey[:]); err != nil {
+			return 0, err
+		}
+	}
+	c.maskPos = 0
+	c.remaining = length
+	return opcode, nil
+}
+
+func (c *Conn) readPayload(p []byte) (int, error) {
+	if int64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err := c.reader.Read(p)
+	if c.masked {
+		for i := 0; i < n; i++ {
+			p[i] ^= c.maskKey[c.maskPos%4]
+			c.maskPos++
+		}
+	}
+	c.remaining -= int64(n)
+	return n, err
+}
+
+// handleControlFrame processes the control frame whose header was read
+func (c *Conn) handleControlFrame(opcode byte) error {
+	if c.remaining > 125 {
+		return errors.New("invalid WebSocket control frame")
+	}
+	payload := make([]byte, c.remaining)
+	for read := 0; read < len(payload); {
+		n, err := c.readPayload(payload[read:])
+		if err != nil {
+			return err
+		}
+		read += n
+	}
+	switch opcode {
+	case opPing:
+		// the peer may be closing the connection already, which is noticed on the next read
+		c.writeFrame(opPong, payload)
+	case opClose:
+		code := CloseNormal
+		if len(payload) >= 2 {
+			code = int(binary.BigEndian.Uint16(payload))
+		}
+		c.writeFrame(opClose, payload[:0])
+		c.conn.Close()
+		if code != CloseNormal {
+			return &closeError{code: code, reason: string(payload[2:])}
+		}
+		return io.EOF
+	}
+	return nil
+}
+
+// Read returns the payload of the data frames, and io.EOF once the peer closed the connection normally
+func (c *Conn) Read(p []byte) (int, error) {
+	for c.remaining == 0 {
+		if c.readErr != nil {
+			return 0, c.readErr
+		}
+		opcode, err := c.nextFrame()
+		if err != nil {
+			c.readErr = err
+			return 0, err
+		}
+		if opcode >= opClose {
+			if err := c.handleControlFrame(opcode); err != nil {
+				c.readErr = err
+			}
+			continue
+		}
+	}
+	return c.readPayload(p)
+}
+
+// ReadMessage reads a complete data message, which must not be larger than maxMessageSize
+func (c *Conn) ReadMessage() ([]byte, error) {
+	message := make([]byte, 0)
+	for {
+		opcode, err := c.nextFrame()
+		if err != nil {
+			return nil, err
+		}
+		if opcode >= opClose {
+			if err := c.handleControlFrame(opcode); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if int64(len(message))+c.remaining > maxMessageSize {
+			return nil, errors.New("WebSocket message too large")
+		}
+		start := len(message)
+		message = append(message, make([]byte, c.remaining)...)
+		for read := start; read < len(message); {
+			n, err := c.readPayload(message[read:])
+			if err != nil {
+				return nil, err
+			}
+			read += n
+		}
+		if c.final {
+			return message, nil
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package wsTransport
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func serve(t *testing.T, handler func(conn *Conn)) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			t.Errorf("Upgrade failed: %v", err)
+			return
+		}
+		handler(conn)
+	}))
+}
+
+func TestStreamData(t *testing.T) {
+	data := bytes.Repeat([]byte("inter-server-sync"), 10000)
+	server := serve(t, func(conn *Conn) {
+		message, err := conn.ReadMessage()
+		if err != nil || string(message) != `{"channels":["base"]}` {
+			t.Errorf("Unexpected request %s: %v", message, err)
+		}
+		conn.writeFrame(opPing, []byte("ping"))
+		conn.Write(data)
+		conn.Close(CloseNormal, "")
+	})
+	defer server.Close()
+
+	conn, err := Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.WriteText([]byte(`{"channels":["base"]}`)); err != nil {
+		t.Fatal(err)
+	}
+	received, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if !bytes.Equal(received, data) {
+		t.Errorf("Received %d bytes, expected %d", len(received), len(data))
+	}
+}
+
+func TestStreamError(t *testing.T) {
+	server := serve(t, func(conn *Conn) {
+		conn.Write([]byte("partial"))
+		conn.Close(CloseError, "export failed")
+	})
+	defer server.Close()
+
+	conn, err := Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = io.ReadAll(conn)
+	if err == nil || !strings.Contains(err.Error(), "export failed") {
+		t.Errorf("Expected the close reason, got %v", err)
+	}
+}
+
+func TestAcceptKey(t *testing.T) {
+	// example of RFC 6455
+	if key := acceptKey("dGhlIHNhbXBsZSBub25jZQ=="); key != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("Unexpected accept key %s", key)
+	}
+}
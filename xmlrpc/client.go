@@ -14,10 +14,32 @@ const (
 	Endpoint       = "http://localhost/rpc/api"
 	AuthMethod     = "auth.login"
 	SyncMethod     = "configchannel.syncSaltFilesOnDisk"
+	// variables of the autoinstallation profiles are stored by cobbler, the API updates them
+	ProfileVariablesMethod = "kickstart.profile.setVariables"
+	// schedules the repository synchronization of a channel, replacing its previous schedule
+	RepoSyncMethod = "channel.software.syncRepo"
+	// cobbler accepts the server users credentials
+	CobblerEndpoint   = "http://localhost:25151"
+	CobblerAuthMethod = "login"
+	CobblerSyncMethod = "sync"
+	// cobbler sync writes all the boot entries, which takes longer than any other call
+	CobblerSyncTimeout = 300
+	// taskomatic accepts the calls from the server itself, without credentials. The bunches of the server have
+	// their own methods, the others are scheduled by organization
+	TaskomaticEndpoint            = "http://localhost:2829/RPC2"
+	TaskomaticUnscheduleMethod    = "tasko.unscheduleBunch"
+	TaskomaticScheduleMethod      = "tasko.scheduleBunch"
+	TaskomaticUnscheduleSatMethod = "tasko.unscheduleSatBunch"
+	TaskomaticScheduleSatMethod   = "tasko.scheduleSatBunch"
 )
 
 type Client interface {
 	SyncConfigFiles(labels []string) (interface{}, error)
+	SyncCobbler() (interface{}, error)
+	SetProfileVariables(label string, variables map[string]interface{}) (interface{}, error)
+	ScheduleRepoSync(label string, cronExpr string, params map[string]interface{}) (interface{}, error)
+	UnscheduleBunch(orgId int64, jobLabel string) (interface{}, error)
+	ScheduleBunch(orgId int64, bunchName string, jobLabel string, cronExpr string, params map[string]interface{}) (interface{}, error)
 }
 
 type client struct {
@@ -76,3 +98,50 @@ func (c *client) SyncConfigFiles(labels []string) (interface{}, error) {
 	syncPayload := []interface{}{token, labels}
 	return c.executeCall(c.endpoint, SyncMethod, syncPayload)
 }
+
+func (c *client) SyncCobbler() (interface{}, error) {
+
+	credentials := []interface{}{c.username, c.password}
+	token, err := c.executeCall(CobblerEndpoint, CobblerAuthMethod, credentials)
+	if err != nil {
+		return nil, err
+	}
+	c.requestTimeout = CobblerSyncTimeout
+	return c.executeCall(CobblerEndpoint, CobblerSyncMethod, []interface{}{token})
+}
+
+func (c *client) SetProfileVariables(label string, variables map[string]interface{}) (interface{}, error) {
+
+	credentials := []interface{}{c.username, c.password}
+	token, err := c.executeCall(c.endpoint, AuthMethod, credentials)
+	if err != nil {
+		return nil, err
+	}
+	return c.executeCall(c.endpoint, ProfileVariablesMethod, []interface{}{token, label, variables})
+}
+
+func (c *client) ScheduleRepoSync(label string, cronExpr string, params map[string]interface{}) (interface{}, error) {
+
+	credentials := []interface{}{c.username, c.password}
+	token, err := c.executeCall(c.endpoint, AuthMethod, credentials)
+	if err != nil {
+		return nil, err
+	}
+	return c.executeCall(c.endpoint, RepoSyncMethod, []interface{}{token, label, cronExpr, params})
+}
+
+// UnscheduleBunch stops the taskomatic schedule of the job label, of the organization or of the server for 0
+func (c *client) UnscheduleBunch(orgId int64, jobLabel string) (interface{}, error) {
+	if orgId == 0 {
+		return c.executeCall(TaskomaticEndpoint, TaskomaticUnscheduleSatMethod, []interface{}{jobLabel})
+	}
+	return c.executeCall(TaskomaticEndpoint, TaskomaticUnscheduleMethod, []interface{}{orgId, jobLabel})
+}
+
+// ScheduleBunch schedules the taskomatic bunch with the job label, of the organization or of the server for 0
+func (c *client) ScheduleBunch(orgId int64, bunchName string, jobLabel string, cronExpr string, params map[string]interface{}) (interface{}, error) {
+	if orgId == 0 {
+		return c.executeCall(TaskomaticEndpoint, TaskomaticScheduleSatMethod, []interface{}{bunchName, jobLabel, cronExpr, params})
+	}
+	return c.executeCall(TaskomaticEndpoint, TaskomaticScheduleMethod, []interface{}{orgId, bunchName, jobLabel, cronExpr, params})
+}